@@ -6,24 +6,35 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime/debug"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/auth"
 	"github.com/Frimurare/WulfVault/internal/cleanup"
 	"github.com/Frimurare/WulfVault/internal/config"
 	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
 	"github.com/Frimurare/WulfVault/internal/models"
 	"github.com/Frimurare/WulfVault/internal/server"
+	"github.com/Frimurare/WulfVault/internal/storage"
 )
 
 const (
 	Version = "6.2.3 BloodMoon 🌙"
+
+	// shutdownTimeout is how long uploads and downloads in progress get to finish on shutdown
+	shutdownTimeout = 30 * time.Second
+	// schedulerStopTimeout is how long a running background job gets to finish on shutdown
+	schedulerStopTimeout = 10 * time.Second
 )
 
 var (
@@ -31,6 +42,7 @@ var (
 	dataDir    = flag.String("data", getEnv("DATA_DIR", "./data"), "Data directory")
 	uploadsDir = flag.String("uploads", getEnv("UPLOADS_DIR", "./uploads"), "Uploads directory")
 	serverURL  = flag.String("url", getEnv("SERVER_URL", "http://localhost:8080"), "Server URL")
+	bindAddr   = flag.String("bind", getEnv("BIND_ADDRESS", ""), "Address to listen on (default: all interfaces)")
 	setup      = flag.Bool("setup", false, "Run initial setup")
 )
 
@@ -59,6 +71,13 @@ func main() {
 	}
 	defer database.DB.Close()
 
+	// Recount storage usage, since uploads and deletes only adjust it from here on
+	if fixed, err := database.DB.ReconcileUserStorage(); err != nil {
+		log.Printf("Warning: Could not recount user storage usage: %v", err)
+	} else if fixed > 0 {
+		log.Printf("Corrected storage usage of %d users", fixed)
+	}
+
 	// Ensure uploads directory exists
 	if err := os.MkdirAll(*uploadsDir, 0755); err != nil {
 		log.Fatalf("Failed to create uploads directory: %v", err)
@@ -71,17 +90,6 @@ func main() {
 		}
 	}
 
-	// Cleanup expired sessions periodically
-	safeGo("session-cleanup", func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-		for range ticker.C {
-			if err := auth.CleanupExpiredSessions(); err != nil {
-				log.Printf("Error cleaning up sessions: %v", err)
-			}
-		}
-	})
-
 	// Load or create configuration
 	cfg, err := config.LoadOrCreate(*dataDir)
 	if err != nil {
@@ -91,6 +99,17 @@ func main() {
 	// Set runtime version
 	cfg.Version = Version
 
+	// The bind address only controls where the server listens; links are built from the server URL
+	if getEnv("BIND_ADDRESS", "") != "" || isFlagPassed("bind") {
+		cfg.BindAddress = *bindAddr
+	}
+	if err := config.ValidateBindAddress(cfg.BindAddress); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Forwarded client addresses are only trusted from these reverse proxies
+	cfg.ApplyTrustedProxiesEnv()
+
 	// Load server URL from database first (highest priority)
 	// This allows admin panel settings to override environment variables
 	if dbServerURL, err := database.DB.GetConfigValue("server_url"); err == nil && dbServerURL != "" {
@@ -118,6 +137,15 @@ func main() {
 	// Always override uploads dir if provided
 	cfg.UploadsDir = *uploadsDir
 
+	// File contents are kept in the uploads directory unless object storage is configured
+	cfg.Storage.ApplyEnv()
+	if err := storage.Initialize(cfg.Storage, cfg.UploadsDir); err != nil {
+		log.Fatalf("Failed to set up file storage: %v", err)
+	}
+
+	// Single sign-on through an OpenID Connect provider
+	cfg.OIDC.ApplyEnv()
+
 	// Load trash retention setting from database if available
 	if trashRetentionStr, err := database.DB.GetConfigValue("trash_retention_days"); err == nil && trashRetentionStr != "" {
 		if days, parseErr := strconv.Atoi(trashRetentionStr); parseErr == nil && days > 0 {
@@ -174,82 +202,123 @@ func main() {
 	// Start file expiration cleanup scheduler (runs every 6 hours)
 	cleanup.StartCleanupScheduler(*uploadsDir, 6*time.Hour, cfg.TrashRetentionDays)
 
+	// Flag expired files that were not moved to trash, in case the cleanup scheduler stops (runs every hour)
+	cleanup.StartExpiryReconciliationScheduler(*uploadsDir)
+
 	// Start audit log cleanup scheduler (runs every 24 hours)
 	// Deletes logs older than AuditLogRetentionDays and maintains max size
 	cleanup.StartAuditLogCleanupScheduler(cfg.AuditLogRetentionDays, cfg.AuditLogMaxSizeMB)
 
+	// Start idle download account deactivation (runs every 24 hours, configured in server settings)
+	cleanup.StartDownloadAccountIdleScheduler(cfg.ServerURL, cfg.CompanyName)
+
+	// Email templates in <data>/email-templates replace the built-in ones of the same name
+	email.SetTemplateDir(filepath.Join(*dataDir, "email-templates"))
+
+	// Send queued emails in the background, retrying failed sends with backoff
+	email.StartQueueWorker()
+
+	// Start expiry reminders to file recipients (runs every hour, opt-in per file)
+	cleanup.StartExpiryReminderScheduler(cfg.ServerURL, cfg.CompanyName)
+
+	// Send the download summaries of often downloaded files (checks every 5 minutes)
+	cleanup.StartDownloadDigestScheduler(cfg.ServerURL)
+
+	// Verify stored files against their SHA-256 (checks hourly, off unless an interval is set in server settings)
+	cleanup.StartIntegrityScanScheduler(cfg.ServerURL, cfg.CompanyName)
+
+	// Write database snapshots to the backup target (checks every 10 minutes, off unless an interval is set on the backup page)
+	cleanup.StartBackupScheduler(cfg.ServerURL, cfg.CompanyName)
+
+	// Prune expired sessions, reset/change links, trusted devices, download tokens and failed
+	// login counters (runs every hour)
+	cleanup.StartTokenCleanupScheduler(func() {
+		server.PruneExpiredDownloadTokens()
+		auth.PruneLoginAttempts()
+		auth.PruneSessionTouches()
+	})
+
 	// Cleanup orphaned chunks periodically (runs every hour)
 	// Removes chunks older than 2 hours that were left behind from failed uploads
+	cleanup.ScheduleJob(cleanup.JobUploadChunks, time.Hour)
 	safeGo("chunk-cleanup", func() {
 		ticker := time.NewTicker(1 * time.Hour)
 		defer ticker.Stop()
-		for range ticker.C {
+		for cleanup.NextTick(ticker) {
 			server.CleanupOrphanedChunks(cfg.UploadsDir)
+			cleanup.RecordJobRun(cleanup.JobUploadChunks)
 		}
 	})
 
 	// Cleanup expired file requests periodically (runs every 24 hours)
-	// File requests expire after 24 hours, then show "expired" message for 10 days, then are deleted
+	// File requests expire after 24 hours, then show "expired" message for the configured retention
+	// (10 days by default), then are deleted
+	cleanup.ScheduleJob(cleanup.JobFileRequests, 24*time.Hour)
 	safeGo("file-request-cleanup", func() {
 		// Run immediately on startup
 		if err := database.DB.CleanupExpiredFileRequests(); err != nil {
 			log.Printf("Error cleaning up expired file requests: %v", err)
 		}
+		cleanup.RecordJobRun(cleanup.JobFileRequests)
 
 		// Then run every 24 hours
 		ticker := time.NewTicker(24 * time.Hour)
 		defer ticker.Stop()
-		for range ticker.C {
+		for cleanup.NextTick(ticker) {
 			if err := database.DB.CleanupExpiredFileRequests(); err != nil {
 				log.Printf("Error cleaning up expired file requests: %v", err)
 			}
+			cleanup.RecordJobRun(cleanup.JobFileRequests)
 		}
 	})
 
-	// Cleanup old soft-deleted accounts (runs daily, deletes accounts soft-deleted for 90+ days)
-	safeGo("soft-delete-cleanup", func() {
-		// Run immediately on startup
-		log.Println("Starting 90-day soft delete cleanup...")
-		userCount, err := database.DB.PermanentlyDeleteOldUsers(90)
+	// Cleanup old soft-deleted accounts (runs daily, deletes accounts soft-deleted longer than
+	// the configured retention, 90 days by default)
+	cleanupDeletedAccounts := func() {
+		days := database.DB.GetDeletedAccountRetentionDays()
+		log.Printf("Running %d-day soft delete cleanup...", days)
+		userCount, err := database.DB.PermanentlyDeleteOldUsers(days)
 		if err != nil {
 			log.Printf("Error permanently deleting old users: %v", err)
 		} else if userCount > 0 {
-			log.Printf("Permanently deleted %d users that were soft-deleted 90+ days ago", userCount)
+			log.Printf("Permanently deleted %d users that were soft-deleted %d+ days ago", userCount, days)
 		}
 
-		downloadAccountCount, err := database.DB.PermanentlyDeleteOldDownloadAccounts(90)
+		downloadAccountCount, err := database.DB.PermanentlyDeleteOldDownloadAccounts(days)
 		if err != nil {
 			log.Printf("Error permanently deleting old download accounts: %v", err)
 		} else if downloadAccountCount > 0 {
-			log.Printf("Permanently deleted %d download accounts that were soft-deleted 90+ days ago", downloadAccountCount)
+			log.Printf("Permanently deleted %d download accounts that were soft-deleted %d+ days ago", downloadAccountCount, days)
 		}
+		cleanup.RecordJobRun(cleanup.JobDeletedAccounts)
+	}
+	cleanup.ScheduleJob(cleanup.JobDeletedAccounts, 24*time.Hour)
+	safeGo("soft-delete-cleanup", func() {
+		// Run immediately on startup
+		cleanupDeletedAccounts()
 
 		// Then run every 24 hours
 		ticker := time.NewTicker(24 * time.Hour)
 		defer ticker.Stop()
-		for range ticker.C {
-			log.Println("Running daily 90-day soft delete cleanup...")
-			userCount, err := database.DB.PermanentlyDeleteOldUsers(90)
-			if err != nil {
-				log.Printf("Error permanently deleting old users: %v", err)
-			} else if userCount > 0 {
-				log.Printf("Permanently deleted %d users that were soft-deleted 90+ days ago", userCount)
-			}
-
-			downloadAccountCount, err := database.DB.PermanentlyDeleteOldDownloadAccounts(90)
-			if err != nil {
-				log.Printf("Error permanently deleting old download accounts: %v", err)
-			} else if downloadAccountCount > 0 {
-				log.Printf("Permanently deleted %d download accounts that were soft-deleted 90+ days ago", downloadAccountCount)
-			}
+		for cleanup.NextTick(ticker) {
+			cleanupDeletedAccounts()
 		}
 	})
 
 	log.Printf("Server configuration:")
 	log.Printf("  - URL: %s", cfg.ServerURL)
 	log.Printf("  - Port: %s", cfg.Port)
+	if cfg.BindAddress != "" {
+		log.Printf("  - Bind address: %s", cfg.BindAddress)
+	}
 	log.Printf("  - Data: %s", *dataDir)
 	log.Printf("  - Uploads: %s", cfg.UploadsDir)
+	if cfg.Storage.Backend == config.StorageBackendS3 {
+		log.Printf("  - Storage: S3 bucket %s", cfg.Storage.S3.Bucket)
+	}
+	if cfg.OIDC.Enabled() {
+		log.Printf("  - Single sign-on: %s", cfg.OIDC.IssuerURL)
+	}
 	log.Printf("  - Company: %s", cfg.CompanyName)
 
 	// Create static directory
@@ -257,7 +326,49 @@ func main() {
 
 	// Start web server
 	srv := server.New(cfg)
-	log.Fatal(srv.Start())
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.Start()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	case sig := <-signals:
+		log.Printf("🛑 Received %s, shutting down...", sig)
+	case <-srv.ShutdownRequested():
+		log.Printf("🛑 Restart requested, shutting down...")
+	}
+	signal.Stop(signals)
+
+	shutdown(srv)
+	log.Printf("👋 Server stopped")
+}
+
+// shutdown stops the server gracefully: in-flight requests get shutdownTimeout to finish, then
+// the background schedulers and the email queue get schedulerStopTimeout to finish the job they
+// are running. The deferred calls in main close the logs and the database afterwards.
+func shutdown(srv *server.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Warning: Requests still running after %s were cut off: %v", shutdownTimeout, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), schedulerStopTimeout)
+	defer cancel()
+	if err := cleanup.StopSchedulers(ctx); err != nil {
+		log.Printf("Warning: Background jobs still running after %s: %v", schedulerStopTimeout, err)
+	}
+	if err := email.StopQueueWorker(ctx); err != nil {
+		log.Printf("Warning: Email queue worker still sending after %s: %v", schedulerStopTimeout, err)
+	}
 }
 
 func needsSetup() bool {
@@ -341,9 +452,9 @@ func isFlagPassed(name string) bool {
 	return found
 }
 
-// safeGo runs a goroutine with panic recovery
+// safeGo runs a scheduler goroutine with panic recovery. It is stopped with the other schedulers.
 func safeGo(name string, fn func()) {
-	go func() {
+	cleanup.Go(func() {
 		defer func() {
 			if r := recover(); r != nil {
 				log.Printf("❌ PANIC in goroutine '%s': %v", name, r)
@@ -351,5 +462,5 @@ func safeGo(name string, fn func()) {
 			}
 		}()
 		fn()
-	}()
+	})
 }
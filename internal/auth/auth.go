@@ -11,8 +11,6 @@ import (
 	"errors"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"github.com/Frimurare/WulfVault/internal/database"
 	"github.com/Frimurare/WulfVault/internal/models"
 )
@@ -23,16 +21,15 @@ const (
 	BcryptCost           = 12
 )
 
-// HashPassword hashes a password using bcrypt
+// HashPassword hashes a password with the configured algorithm and cost, see currentHasher.
+// BcryptCost is the bcrypt cost used when password_bcrypt_cost is not configured.
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
-	return string(bytes), err
+	return currentHasher().Hash(password)
 }
 
-// CheckPasswordHash compares a password with a hash
+// CheckPasswordHash compares a password with a hash in any supported format
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	return VerifyPassword(password, hash)
 }
 
 // GenerateSessionID generates a random session ID
@@ -46,25 +43,49 @@ func GenerateSessionID() (string, error) {
 
 // CreateSession creates a new session for a user with specified duration
 func CreateSession(userId int, duration ...time.Duration) (string, error) {
-	sessionId, err := GenerateSessionID()
-	if err != nil {
-		return "", err
-	}
-
 	// Use provided duration or default to SessionDuration
 	sessionDuration := SessionDuration
 	if len(duration) > 0 {
 		sessionDuration = duration[0]
 	}
+	return createSession(userId, sessionDuration, false)
+}
+
+// LoginSessionDuration returns how long a login session lasts, as set in the server settings:
+// the remember-me days if the user ticked "Keep me logged in", otherwise the session hours
+func LoginSessionDuration(rememberMe bool) time.Duration {
+	if rememberMe {
+		if days := database.DB.GetRememberMeDays(); days > 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return time.Duration(database.DB.GetSessionHours()) * time.Hour
+}
+
+// CreateLoginSession creates the session of a user who just logged in and returns it with the
+// duration its cookie should last. The expiry is stored with the session, so changing the
+// settings later doesn't shorten or extend sessions that already exist.
+func CreateLoginSession(userId int, rememberMe bool) (string, time.Duration, error) {
+	rememberMe = rememberMe && database.DB.GetRememberMeDays() > 0
+	duration := LoginSessionDuration(rememberMe)
+	sessionId, err := createSession(userId, duration, rememberMe)
+	return sessionId, duration, err
+}
+
+func createSession(userId int, sessionDuration time.Duration, rememberMe bool) (string, error) {
+	sessionId, err := GenerateSessionID()
+	if err != nil {
+		return "", err
+	}
 
 	validUntil := time.Now().Add(sessionDuration).Unix()
 	now := time.Now().Unix()
 
 	// Insert session
 	_, err = database.DB.Exec(`
-		INSERT INTO Sessions (Id, UserId, ValidUntil)
-		VALUES (?, ?, ?)`,
-		sessionId, userId, validUntil,
+		INSERT INTO Sessions (Id, UserId, ValidUntil, RememberMe, CreatedAt)
+		VALUES (?, ?, ?, ?, ?)`,
+		sessionId, userId, validUntil, rememberMe, now,
 	)
 	if err != nil {
 		return "", err
@@ -79,15 +100,16 @@ func CreateSession(userId int, duration ...time.Duration) (string, error) {
 	return sessionId, nil
 }
 
-// GetUserBySession retrieves a user by session ID
+// GetUserBySession retrieves a user by session ID. For an impersonation session the user has
+// ImpersonatedBy set, and the super admin's last activity is updated instead of the user's.
 func GetUserBySession(sessionId string) (*models.User, error) {
-	var userId int
+	var userId, impersonatorId int
 	var validUntil int64
 
 	err := database.DB.QueryRow(`
-		SELECT UserId, ValidUntil FROM Sessions WHERE Id = ?`,
+		SELECT UserId, ValidUntil, COALESCE(ImpersonatorId, 0) FROM Sessions WHERE Id = ?`,
 		sessionId,
-	).Scan(&userId, &validUntil)
+	).Scan(&userId, &validUntil, &impersonatorId)
 
 	if err != nil {
 		return nil, errors.New("invalid session")
@@ -106,6 +128,17 @@ func GetUserBySession(sessionId string) (*models.User, error) {
 		return nil, err
 	}
 
+	if impersonatorId != 0 {
+		admin, err := impersonatingAdmin(impersonatorId)
+		if err != nil {
+			database.DB.Exec("DELETE FROM Sessions WHERE Id = ?", sessionId)
+			return nil, err
+		}
+		user.ImpersonatedBy = admin
+		database.DB.UpdateUserLastOnline(admin.Id)
+		return user, nil
+	}
+
 	// Update last online
 	database.DB.UpdateUserLastOnline(userId)
 
@@ -118,26 +151,21 @@ func DeleteSession(sessionId string) error {
 	return err
 }
 
-// IsLongSession checks if a session is a "Remember Me" session (validity > 2 days)
-// Long sessions should not be subject to inactivity timeout
+// IsLongSession checks if a session is a "Remember Me" session, created with "Keep me logged in"
+// ticked. Long sessions should not be subject to inactivity timeout
 func IsLongSession(sessionId string) bool {
-	var validUntil int64
+	var rememberMe bool
 
 	err := database.DB.QueryRow(`
-		SELECT ValidUntil FROM Sessions WHERE Id = ?`,
+		SELECT COALESCE(RememberMe, 0) FROM Sessions WHERE Id = ?`,
 		sessionId,
-	).Scan(&validUntil)
-
-	if err != nil {
-		return false
-	}
+	).Scan(&rememberMe)
 
-	// If session expires more than 2 days from now, it's a "Remember Me" session
-	twoDaysFromNow := time.Now().Add(48 * time.Hour).Unix()
-	return validUntil > twoDaysFromNow
+	return err == nil && rememberMe
 }
 
-// CleanupExpiredSessions removes all expired sessions
+// CleanupExpiredSessions removes all expired sessions. Each session expires at its own
+// ValidUntil, set from the lifetime it was created with.
 func CleanupExpiredSessions() error {
 	_, err := database.DB.Exec("DELETE FROM Sessions WHERE ValidUntil < ?", time.Now().Unix())
 	return err
@@ -165,6 +193,7 @@ func AuthenticateUser(emailOrUsername, password string) (*models.User, error) {
 	if !CheckPasswordHash(password, user.Password) {
 		return nil, errors.New("invalid credentials")
 	}
+	UpgradeUserPasswordHash(user, password)
 
 	return user, nil
 }
@@ -230,6 +259,7 @@ func AuthenticateDownloadAccount(email, password string) (*models.DownloadAccoun
 	if !CheckPasswordHash(password, account.Password) {
 		return nil, errors.New("invalid credentials")
 	}
+	UpgradeDownloadAccountPasswordHash(account, password)
 
 	// Update last used
 	database.DB.UpdateDownloadAccountLastUsed(account.Id)
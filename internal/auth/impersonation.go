@@ -0,0 +1,59 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// ImpersonationDuration is how long a super admin can view the app as another user before
+// the impersonation session ends
+const ImpersonationDuration = time.Hour
+
+// CreateImpersonationSession starts a session in which the super admin adminId sees the app as
+// userId. The admin's own session adminSessionId is kept, to return to when the impersonation
+// ends.
+func CreateImpersonationSession(userId, adminId int, adminSessionId string) (string, error) {
+	sessionId, err := GenerateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO Sessions (Id, UserId, ValidUntil, ImpersonatorId, ImpersonatorSessionId, CreatedAt)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		sessionId, userId, time.Now().Add(ImpersonationDuration).Unix(), adminId, adminSessionId, time.Now().Unix(),
+	)
+	if err != nil {
+		return "", err
+	}
+	return sessionId, nil
+}
+
+// GetImpersonatorSession returns the session the super admin was using before starting the
+// impersonation session sessionId, or "" if it is not an impersonation session
+func GetImpersonatorSession(sessionId string) string {
+	var adminSessionId string
+	database.DB.QueryRow(`
+		SELECT COALESCE(ImpersonatorSessionId, '') FROM Sessions WHERE Id = ? AND COALESCE(ImpersonatorId, 0) != 0`,
+		sessionId,
+	).Scan(&adminSessionId)
+	return adminSessionId
+}
+
+// impersonatingAdmin returns the super admin behind an impersonation session. The session ends
+// if the admin was deactivated or is no longer a super admin.
+func impersonatingAdmin(adminId int) (*models.User, error) {
+	admin, err := database.DB.GetUserByID(adminId)
+	if err != nil || !admin.IsActive || !admin.IsSuperAdmin() {
+		return nil, errors.New("impersonation is no longer allowed")
+	}
+	return admin, nil
+}
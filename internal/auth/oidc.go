@@ -0,0 +1,424 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/config"
+)
+
+const (
+	// oidcMetadataTTL is how long the provider's discovery document and signing keys are cached.
+	// Keys are fetched again early when a token is signed with a key that isn't known yet.
+	oidcMetadataTTL = time.Hour
+	// oidcClockSkew is how far the provider's clock may be off when checking token expiry
+	oidcClockSkew = 2 * time.Minute
+)
+
+// OIDCProvider signs users in with an OpenID Connect provider using the authorization code
+// flow with PKCE. Endpoints and signing keys are discovered from the issuer URL.
+type OIDCProvider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURI  string
+	client       *http.Client
+
+	mu            sync.Mutex
+	metadata      *oidcMetadata
+	metadataAt    time.Time
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// oidcMetadata is the part of the discovery document that is used
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity is the user an ID token was issued for
+type OIDCIdentity struct {
+	Subject string
+	Email   string
+	Name    string
+}
+
+// NewOIDCProvider returns a provider for the configuration. redirectURI is used when the
+// configuration doesn't set one.
+func NewOIDCProvider(cfg config.OIDCConfig, redirectURI string) *OIDCProvider {
+	if cfg.RedirectURI != "" {
+		redirectURI = cfg.RedirectURI
+	}
+	return &OIDCProvider{
+		issuer:       strings.TrimSuffix(cfg.IssuerURL, "/"),
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		redirectURI:  redirectURI,
+		client:       &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// PKCEChallenge returns the S256 code challenge of a code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to to sign in
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.getMetadata()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.clientID)
+	query.Set("redirect_uri", p.redirectURI)
+	query.Set("scope", "openid email profile")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", PKCEChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified identity of its ID token
+func (p *OIDCProvider) Exchange(code, nonce, codeVerifier string) (*OIDCIdentity, error) {
+	metadata, err := p.getMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURI},
+		"client_id":     {p.clientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.clientSecret != "" {
+		form.Set("client_secret", p.clientSecret)
+	}
+	resp, err := p.client.PostForm(metadata.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("token request refused: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.IDToken == "" {
+		return nil, fmt.Errorf("token response without ID token (HTTP %d)", resp.StatusCode)
+	}
+
+	return p.verifyIDToken(token.IDToken, nonce, metadata.Issuer)
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *OIDCProvider) verifyIDToken(rawToken, nonce, issuer string) (*OIDCIdentity, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed ID token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed ID token signature")
+	}
+	key, err := p.getKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Issuer        string          `json:"iss"`
+		Subject       string          `json:"sub"`
+		Audience      json.RawMessage `json:"aud"`
+		AuthorizedBy  string          `json:"azp"`
+		Expiry        int64           `json:"exp"`
+		Nonce         string          `json:"nonce"`
+		Email         string          `json:"email"`
+		EmailVerified *bool           `json:"email_verified"`
+		Name          string          `json:"name"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+
+	if claims.Issuer != issuer {
+		return nil, fmt.Errorf("ID token issued by %q, expected %q", claims.Issuer, issuer)
+	}
+	audiences, err := parseAudience(claims.Audience)
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(audiences, p.clientID) {
+		return nil, errors.New("ID token was issued for another client")
+	}
+	if len(audiences) > 1 && claims.AuthorizedBy != "" && claims.AuthorizedBy != p.clientID {
+		return nil, errors.New("ID token was issued for another client")
+	}
+	if time.Unix(claims.Expiry, 0).Add(oidcClockSkew).Before(time.Now()) {
+		return nil, errors.New("ID token has expired")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("ID token nonce doesn't match the login")
+	}
+	if claims.Email == "" {
+		return nil, errors.New("ID token has no email; make sure the provider releases the email scope")
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, errors.New("the provider hasn't verified the email address")
+	}
+
+	return &OIDCIdentity{Subject: claims.Subject, Email: claims.Email, Name: claims.Name}, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// parseAudience reads the aud claim, which is a string or an array of strings
+func parseAudience(raw json.RawMessage) ([]string, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, errors.New("malformed ID token audience")
+	}
+	return list, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyJWTSignature checks an RS256/384/512 or ES256/384/512 signature
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported ID token algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return errors.New("ID token algorithm doesn't match its key")
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errors.New("invalid ID token signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return errors.New("ID token algorithm doesn't match its key")
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid ID token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid ID token signature")
+		}
+	default:
+		return errors.New("unsupported ID token key")
+	}
+	return nil
+}
+
+// getMetadata returns the cached discovery document, fetching it when it is missing or old
+func (p *OIDCProvider) getMetadata() (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && time.Since(p.metadataAt) < oidcMetadataTTL {
+		return p.metadata, nil
+	}
+
+	var metadata oidcMetadata
+	if err := p.getJSON(p.issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, expected %q", metadata.Issuer, p.issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	p.metadata = &metadata
+	p.metadataAt = time.Now()
+	return p.metadata, nil
+}
+
+// getKey returns the provider's signing key with the given ID. The key set is fetched again
+// when it is old or doesn't have the key, since providers rotate keys.
+func (p *OIDCProvider) getKey(kid string) (crypto.PublicKey, error) {
+	metadata, err := p.getMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKeyLocked(kid); ok && time.Since(p.keysFetchedAt) < oidcMetadataTTL {
+		return key, nil
+	}
+	// Don't let tokens with unknown key IDs make us fetch the key set over and over
+	if p.keys != nil && time.Since(p.keysFetchedAt) < 10*time.Second {
+		if key, ok := p.lookupKeyLocked(kid); ok {
+			return key, nil
+		}
+		return nil, errors.New("ID token is signed with an unknown key")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(metadata.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("could not fetch OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKeyLocked(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("ID token is signed with an unknown key")
+}
+
+// lookupKeyLocked finds a key by ID. Tokens without a key ID match a provider's only key.
+func (p *OIDCProvider) lookupKeyLocked(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *OIDCProvider) getJSON(endpoint string, v interface{}) error {
+	resp, err := p.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
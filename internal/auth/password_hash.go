@@ -0,0 +1,248 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// Password hashing algorithms (config password_hash_algorithm)
+const (
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+// Limits of the configurable bcrypt cost (config password_bcrypt_cost). Every step doubles the
+// time a login takes.
+const (
+	MinBcryptCost = 10
+	MaxBcryptCost = 16
+)
+
+// Defaults and limits of the argon2id parameters (config password_argon2_memory_mb and
+// password_argon2_iterations)
+const (
+	DefaultArgon2MemoryMB   = 64
+	MinArgon2MemoryMB       = 16
+	MaxArgon2MemoryMB       = 1024
+	DefaultArgon2Iterations = 3
+	MinArgon2Iterations     = 1
+	MaxArgon2Iterations     = 10
+
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// PasswordHasher hashes passwords in one format and checks passwords against hashes in it
+type PasswordHasher interface {
+	// Hash returns the encoded hash of a password, including its salt and parameters
+	Hash(password string) (string, error)
+	// Verify reports whether a password matches a hash in this hasher's format
+	Verify(password, hash string) bool
+	// Owns reports whether a hash is in this hasher's format, judged by its prefix
+	Owns(hash string) bool
+	// Current reports whether a hash in this hasher's format uses its current parameters
+	Current(hash string) bool
+}
+
+// bcryptHasher hashes passwords with bcrypt ($2a$, $2b$ or $2y$ prefix)
+type bcryptHasher struct {
+	cost int
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(hash), err
+}
+
+func (h bcryptHasher) Verify(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (h bcryptHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h bcryptHasher) Current(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == h.cost
+}
+
+// argon2idHasher hashes passwords with argon2id in the PHC string format:
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>
+type argon2idHasher struct {
+	memoryKiB  uint32
+	iterations uint32
+	threads    uint8
+}
+
+// argon2idParams are the parameters and contents of an encoded argon2id hash
+type argon2idParams struct {
+	memoryKiB  uint32
+	iterations uint32
+	threads    uint8
+	salt, key  []byte
+}
+
+func (h argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memoryKiB, h.threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memoryKiB, h.iterations, h.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h argon2idHasher) Verify(password, hash string) bool {
+	params, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memoryKiB, params.threads, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1
+}
+
+func (h argon2idHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h argon2idHasher) Current(hash string) bool {
+	params, err := parseArgon2idHash(hash)
+	return err == nil && params.memoryKiB == h.memoryKiB && params.iterations == h.iterations && params.threads == h.threads
+}
+
+// parseArgon2idHash decodes a hash made by argon2idHasher
+func parseArgon2idHash(hash string) (*argon2idParams, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("not an argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version")
+	}
+	params := &argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memoryKiB, &params.iterations, &params.threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, fmt.Errorf("invalid argon2 key")
+	}
+	return params, nil
+}
+
+// PasswordHashAlgorithm returns the configured algorithm for new password hashes
+func PasswordHashAlgorithm() string {
+	if algorithm, _ := database.DB.GetConfigValue("password_hash_algorithm"); algorithm == HashAlgorithmArgon2id {
+		return HashAlgorithmArgon2id
+	}
+	return HashAlgorithmBcrypt
+}
+
+// BcryptCostSetting returns the configured bcrypt cost
+func BcryptCostSetting() int {
+	return clampInt(database.DB.GetConfigInt("password_bcrypt_cost", BcryptCost), MinBcryptCost, MaxBcryptCost)
+}
+
+// Argon2Settings returns the configured argon2id memory in MB and iterations
+func Argon2Settings() (memoryMB, iterations int) {
+	memoryMB = clampInt(database.DB.GetConfigInt("password_argon2_memory_mb", DefaultArgon2MemoryMB), MinArgon2MemoryMB, MaxArgon2MemoryMB)
+	iterations = clampInt(database.DB.GetConfigInt("password_argon2_iterations", DefaultArgon2Iterations), MinArgon2Iterations, MaxArgon2Iterations)
+	return memoryMB, iterations
+}
+
+func clampInt(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
+
+// currentHasher returns the hasher new password hashes are made with
+func currentHasher() PasswordHasher {
+	if PasswordHashAlgorithm() == HashAlgorithmArgon2id {
+		memoryMB, iterations := Argon2Settings()
+		return argon2idHasher{memoryKiB: uint32(memoryMB) * 1024, iterations: uint32(iterations), threads: argon2Threads}
+	}
+	return bcryptHasher{cost: BcryptCostSetting()}
+}
+
+// hasherFor returns the hasher that owns a stored hash, or nil if its format is unknown
+func hasherFor(hash string) PasswordHasher {
+	for _, hasher := range []PasswordHasher{bcryptHasher{}, argon2idHasher{}} {
+		if hasher.Owns(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// VerifyPassword checks a password against a stored hash in any supported format, detected
+// by the hash's prefix
+func VerifyPassword(password, hash string) bool {
+	hasher := hasherFor(hash)
+	return hasher != nil && hasher.Verify(password, hash)
+}
+
+// NeedsRehash reports whether a stored hash was made with another algorithm or other
+// parameters than new hashes are, so it should be replaced at the next login
+func NeedsRehash(hash string) bool {
+	current := currentHasher()
+	return !current.Owns(hash) || !current.Current(hash)
+}
+
+// UpgradeUserPasswordHash hashes a user's password again with the current algorithm after a
+// successful login, if the stored hash is older. Failures are logged; the login goes on.
+func UpgradeUserPasswordHash(user *models.User, password string) {
+	if !NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := HashPassword(password)
+	if err == nil {
+		err = database.DB.UpdateUserPassword(user.Id, hash)
+	}
+	if err != nil {
+		log.Printf("Warning: Could not upgrade the password hash of user %d: %v", user.Id, err)
+		return
+	}
+	user.Password = hash
+}
+
+// UpgradeDownloadAccountPasswordHash is UpgradeUserPasswordHash for download accounts
+func UpgradeDownloadAccountPasswordHash(account *models.DownloadAccount, password string) {
+	if !NeedsRehash(account.Password) {
+		return
+	}
+	hash, err := HashPassword(password)
+	if err == nil {
+		err = database.DB.UpdateDownloadAccountPassword(account.Id, hash)
+	}
+	if err != nil {
+		log.Printf("Warning: Could not upgrade the password hash of download account %d: %v", account.Id, err)
+		return
+	}
+	account.Password = hash
+}
@@ -0,0 +1,72 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// DefaultPasswordMinLength is the shortest password allowed when password_min_length is not
+// configured. MinPasswordMinLength is the lowest value an admin can configure.
+const (
+	DefaultPasswordMinLength = 8
+	MinPasswordMinLength     = 6
+)
+
+// MaxPasswordLength is the longest password allowed. bcrypt ignores everything after 72 bytes.
+const MaxPasswordLength = 72
+
+// PasswordMinLength returns the configured minimum password length
+func PasswordMinLength() int {
+	minLength := database.DB.GetConfigInt("password_min_length", DefaultPasswordMinLength)
+	if minLength < MinPasswordMinLength {
+		return MinPasswordMinLength
+	}
+	if minLength > MaxPasswordLength {
+		return MaxPasswordLength
+	}
+	return minLength
+}
+
+// PasswordRequirements describes the password policy for help texts next to password fields
+func PasswordRequirements() string {
+	return fmt.Sprintf("At least %d characters, with at least one letter and one number or symbol", PasswordMinLength())
+}
+
+// ValidatePasswordStrength checks a new password against the password policy. The error
+// says what is wrong with the password and can be shown to the user as it is. Existing
+// passwords are not checked, so accounts with older, weaker passwords can still log in.
+func ValidatePasswordStrength(pw string) error {
+	if minLength := PasswordMinLength(); utf8.RuneCountInString(pw) < minLength {
+		return fmt.Errorf("Password must be at least %d characters", minLength)
+	}
+	if len(pw) > MaxPasswordLength {
+		return fmt.Errorf("Password must be at most %d bytes", MaxPasswordLength)
+	}
+
+	var hasLetter, hasOther bool
+	for _, c := range pw {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsSpace(c):
+		default:
+			hasOther = true
+		}
+	}
+	if !hasLetter {
+		return errors.New("Password must contain at least one letter")
+	}
+	if !hasOther {
+		return errors.New("Password must contain at least one number or symbol")
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// Default login rate limits. An email address is locked out after DefaultLoginMaxAttemptsPerEmail
+// failed logins within the window, an IP address after DefaultLoginMaxAttemptsPerIP. The IP limit
+// is higher because offices share one address.
+const (
+	DefaultLoginMaxAttemptsPerEmail = 5
+	DefaultLoginMaxAttemptsPerIP    = 20
+	DefaultLoginWindowMinutes       = 15
+)
+
+var (
+	// failedLogins holds the times of recent failed logins, keyed by "email:" or "ip:"
+	failedLogins   = make(map[string][]time.Time)
+	failedLoginsMu sync.Mutex
+)
+
+// LoginRateLimits returns the configured failed login limits per email and per IP address, and
+// the window they are counted in. A limit of 0 turns that check off.
+func LoginRateLimits() (perEmail, perIP int, window time.Duration) {
+	perEmail = database.DB.GetConfigInt("login_max_attempts_per_email", DefaultLoginMaxAttemptsPerEmail)
+	perIP = database.DB.GetConfigInt("login_max_attempts_per_ip", DefaultLoginMaxAttemptsPerIP)
+	minutes := database.DB.GetConfigInt("login_window_minutes", DefaultLoginWindowMinutes)
+	if minutes <= 0 {
+		minutes = DefaultLoginWindowMinutes
+	}
+	return perEmail, perIP, time.Duration(minutes) * time.Minute
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// RecordLoginAttempt counts a failed login against the email and IP address. A successful login
+// clears the email's counter only: the address may be shared with, or belong to, someone guessing
+// passwords of other accounts. Record success only once a session is issued, so a correct
+// password followed by wrong 2FA codes keeps counting.
+func RecordLoginAttempt(email, ip string, success bool) {
+	_, _, window := LoginRateLimits()
+
+	failedLoginsMu.Lock()
+	defer failedLoginsMu.Unlock()
+
+	if success {
+		delete(failedLogins, emailKey(email))
+		return
+	}
+
+	now := time.Now()
+	for _, key := range []string{emailKey(email), ipKey(ip)} {
+		failedLogins[key] = append(recentAttemptsLocked(key, now, window), now)
+	}
+}
+
+// IsLockedOut reports whether logins for the email or from the IP address are refused, and
+// until when. Failed logins fall out of the window one by one, so the lockout ends as soon as
+// fewer than the limit remain.
+func IsLockedOut(email, ip string) (bool, time.Time) {
+	perEmail, perIP, window := LoginRateLimits()
+
+	failedLoginsMu.Lock()
+	defer failedLoginsMu.Unlock()
+
+	now := time.Now()
+	var until time.Time
+	for _, check := range []struct {
+		key   string
+		limit int
+	}{{emailKey(email), perEmail}, {ipKey(ip), perIP}} {
+		if check.limit <= 0 {
+			continue
+		}
+		attempts := recentAttemptsLocked(check.key, now, window)
+		if len(attempts) < check.limit {
+			continue
+		}
+		// Unlocked once the attempt that reached the limit is out of the window
+		if t := attempts[len(attempts)-check.limit].Add(window); t.After(until) {
+			until = t
+		}
+	}
+	return !until.IsZero(), until
+}
+
+// recentAttemptsLocked returns the failed logins for key within the window, dropping older
+// ones. failedLoginsMu must be held.
+func recentAttemptsLocked(key string, now time.Time, window time.Duration) []time.Time {
+	attempts := failedLogins[key]
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(attempts) && !attempts[i].After(cutoff) {
+		i++
+	}
+	if i == len(attempts) {
+		delete(failedLogins, key)
+		return nil
+	}
+	attempts = attempts[i:]
+	failedLogins[key] = attempts
+	return attempts
+}
+
+// PruneLoginAttempts forgets failed logins that are out of the window, so addresses that
+// never come back don't stay in memory
+func PruneLoginAttempts() {
+	_, _, window := LoginRateLimits()
+
+	failedLoginsMu.Lock()
+	defer failedLoginsMu.Unlock()
+
+	now := time.Now()
+	for key := range failedLogins {
+		recentAttemptsLocked(key, now, window)
+	}
+}
@@ -0,0 +1,63 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// SessionTouchInterval is how often a session's last-seen time, IP address and user agent are
+// written at most. Requests in between only update them if the address or browser changed.
+const SessionTouchInterval = time.Minute
+
+type sessionTouch struct {
+	at        time.Time
+	ipAddress string
+	userAgent string
+}
+
+var (
+	// sessionTouches holds when each session was last written by TouchSession
+	sessionTouches   = make(map[string]sessionTouch)
+	sessionTouchesMu sync.Mutex
+)
+
+// TouchSession records that a session was used by an authenticated request, throttled so busy
+// pages and uploads don't write to the database on every request
+func TouchSession(sessionId, ipAddress, userAgent string) {
+	now := time.Now()
+
+	sessionTouchesMu.Lock()
+	last, ok := sessionTouches[sessionId]
+	if ok && now.Sub(last.at) < SessionTouchInterval && last.ipAddress == ipAddress && last.userAgent == userAgent {
+		sessionTouchesMu.Unlock()
+		return
+	}
+	sessionTouches[sessionId] = sessionTouch{at: now, ipAddress: ipAddress, userAgent: userAgent}
+	sessionTouchesMu.Unlock()
+
+	if err := database.DB.TouchSession(sessionId, ipAddress, userAgent); err != nil {
+		log.Printf("Warning: Could not record session activity: %v", err)
+	}
+}
+
+// PruneSessionTouches forgets sessions that weren't used within the touch interval, so ended
+// sessions don't stay in memory
+func PruneSessionTouches() {
+	sessionTouchesMu.Lock()
+	defer sessionTouchesMu.Unlock()
+
+	now := time.Now()
+	for sessionId, touch := range sessionTouches {
+		if now.Sub(touch.at) >= SessionTouchInterval {
+			delete(sessionTouches, sessionId)
+		}
+	}
+}
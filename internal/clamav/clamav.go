@@ -0,0 +1,167 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+// Package clamav scans files for malware with a ClamAV daemon (clamd), streaming them over its
+// INSTREAM protocol so clamd doesn't need access to the files
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is how much of a file is sent to clamd at a time
+const chunkSize = 64 * 1024
+
+// dialTimeout is how long connecting to clamd may take
+const dialTimeout = 10 * time.Second
+
+// Result is the outcome of a scan
+type Result struct {
+	Infected  bool
+	Signature string // the name of the malware found, e.g. "Eicar-Test-Signature"
+}
+
+// network returns the network and address to dial for a configured clamd address: a Unix
+// socket path ("/run/clamav/clamd.ctl" or "unix:/run/clamav/clamd.ctl") or a TCP address
+// ("127.0.0.1:3310" or "tcp://127.0.0.1:3310")
+func network(address string) (string, string) {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		return "unix", strings.TrimPrefix(strings.TrimPrefix(address, "unix:"), "//")
+	case strings.HasPrefix(address, "tcp://"):
+		return "tcp", strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "/"):
+		return "unix", address
+	}
+	return "tcp", address
+}
+
+func dial(ctx context.Context, address string) (net.Conn, error) {
+	if strings.TrimSpace(address) == "" {
+		return nil, errors.New("no clamd address configured")
+	}
+	netw, addr := network(address)
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, netw, addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to clamd at %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// command sends a command without payload and returns clamd's reply
+func command(ctx context.Context, address, cmd string) (string, error) {
+	conn, err := dial(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("z" + cmd + "\x00")); err != nil {
+		return "", err
+	}
+	return readReply(conn)
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", fmt.Errorf("reading clamd reply: %w", err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// Ping checks that clamd answers at address
+func Ping(ctx context.Context, address string) error {
+	reply, err := command(ctx, address, "PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected reply from clamd: %q", reply)
+	}
+	return nil
+}
+
+// Version returns the ClamAV and signature database version clamd reports
+func Version(ctx context.Context, address string) (string, error) {
+	return command(ctx, address, "VERSION")
+}
+
+// Scan streams content to clamd at address and returns whether it is infected. Content larger
+// than clamd's StreamMaxLength is an error, not a clean result.
+func Scan(ctx context.Context, address string, content io.Reader) (Result, error) {
+	conn, err := dial(ctx, address)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, err
+	}
+
+	// Each chunk is sent with its length as a 4-byte big-endian prefix; a zero length ends the stream
+	buf := make([]byte, 4+chunkSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		n, readErr := content.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection once the stream is over its size limit and
+				// says so in its reply
+				if reply, replyErr := readReply(conn); replyErr == nil && reply != "" {
+					return parseScanReply(reply)
+				}
+				return Result{}, fmt.Errorf("streaming to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Result{}, err
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, err
+	}
+	return parseScanReply(reply)
+}
+
+// parseScanReply reads clamd's reply to INSTREAM: "stream: OK", "stream: <signature> FOUND"
+// or "<message> ERROR"
+func parseScanReply(reply string) (Result, error) {
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		signature = strings.TrimSpace(strings.TrimPrefix(signature, "stream:"))
+		return Result{Infected: true, Signature: signature}, nil
+	case strings.HasSuffix(reply, "OK"):
+		return Result{}, nil
+	case strings.HasSuffix(reply, "ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSpace(strings.TrimSuffix(reply, "ERROR")))
+	}
+	return Result{}, fmt.Errorf("unexpected reply from clamd: %q", reply)
+}
@@ -0,0 +1,252 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package cleanup
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/config"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// JobBackup writes database snapshots to the backup target
+const JobBackup = "scheduled_backup"
+
+// backupCheckInterval is how often the scheduler checks whether a backup is due
+const backupCheckInterval = 10 * time.Minute
+
+// ErrBackupRunning is returned when a backup is started while one is running
+var ErrBackupRunning = errors.New("a backup is already running")
+
+// backupRunning is set while a backup is in progress, so runs never overlap
+var backupRunning atomic.Bool
+
+// IsBackupRunning reports whether a backup is in progress
+func IsBackupRunning() bool {
+	return backupRunning.Load()
+}
+
+// BackupDirectory returns the directory local backups are written to, by default the backups
+// directory next to the database
+func BackupDirectory(schedule *database.BackupSchedule) string {
+	if schedule.Directory != "" {
+		return schedule.Directory
+	}
+	return filepath.Join(filepath.Dir(database.DB.Path()), "backups")
+}
+
+// BackupTargetName describes where backups go, as shown to admins and stored with each run
+func BackupTargetName(schedule *database.BackupSchedule) string {
+	if schedule.Target == database.BackupTargetS3 {
+		name := "s3://" + schedule.S3Bucket
+		if schedule.S3Prefix != "" {
+			name += "/" + schedule.S3Prefix
+		}
+		return name
+	}
+	return BackupDirectory(schedule)
+}
+
+// openBackupTarget returns the storage backups are written to
+func openBackupTarget(schedule *database.BackupSchedule) (storage.Storage, error) {
+	if schedule.Target != database.BackupTargetS3 {
+		dir := BackupDirectory(schedule)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		return storage.NewLocalStorage(dir), nil
+	}
+
+	secretKey := ""
+	if schedule.S3SecretKey != "" {
+		masterKey, err := email.GetOrCreateMasterKey(database.DB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %w", err)
+		}
+		if secretKey, err = email.DecryptAPIKey(schedule.S3SecretKey, masterKey); err != nil {
+			return nil, fmt.Errorf("failed to decrypt S3 secret key: %w", err)
+		}
+	}
+	return storage.NewS3Storage(config.S3Config{
+		Endpoint:  schedule.S3Endpoint,
+		Bucket:    schedule.S3Bucket,
+		Region:    schedule.S3Region,
+		AccessKey: schedule.S3AccessKey,
+		SecretKey: secretKey,
+		PathStyle: schedule.S3PathStyle,
+		Prefix:    schedule.S3Prefix,
+	})
+}
+
+// RunBackup writes a snapshot of the database to the backup target and deletes the backups
+// there beyond the configured number to keep. The run is recorded whether it succeeds or not,
+// and admins are told when it fails.
+func RunBackup(manual bool, serverURL, companyName string) (*database.BackupRun, error) {
+	if !backupRunning.CompareAndSwap(false, true) {
+		return nil, ErrBackupRunning
+	}
+	defer backupRunning.Store(false)
+
+	RecordJobRun(JobBackup)
+
+	schedule := database.DB.GetBackupSchedule()
+	run := &database.BackupRun{
+		Name:      "wulfvault-" + time.Now().Format("20060102-150405") + ".db",
+		Target:    BackupTargetName(schedule),
+		CreatedAt: time.Now().Unix(),
+		Manual:    manual,
+	}
+
+	size, err := writeBackupSnapshot(schedule, run.Name)
+	run.SizeBytes = size
+	run.Success = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if recordErr := database.DB.RecordBackupRun(run); recordErr != nil {
+		log.Printf("Warning: Could not record backup %s: %v", run.Name, recordErr)
+	}
+
+	if err != nil {
+		log.Printf("❌ Backup %s to %s failed: %v", run.Name, run.Target, err)
+		notifyBackupFailed(run, serverURL, companyName)
+	} else {
+		log.Printf("💾 Backup %s written to %s (%s)", run.Name, run.Target, database.FormatFileSize(run.SizeBytes))
+		pruneBackups(schedule, run.Target)
+	}
+	database.DB.PruneFailedBackupRuns()
+	return run, err
+}
+
+// writeBackupSnapshot takes a snapshot of the database and stores it at the backup target
+// under name, returning its size
+func writeBackupSnapshot(schedule *database.BackupSchedule, name string) (int64, error) {
+	target, err := openBackupTarget(schedule)
+	if err != nil {
+		return 0, err
+	}
+
+	snapshotPath := filepath.Join(filepath.Dir(database.DB.Path()), fmt.Sprintf(".%s.tmp", name))
+	os.Remove(snapshotPath)
+	defer os.Remove(snapshotPath)
+	if err := database.DB.BackupTo(snapshotPath); err != nil {
+		return 0, err
+	}
+
+	snapshot, err := os.Open(snapshotPath)
+	if err != nil {
+		return 0, err
+	}
+	defer snapshot.Close()
+	info, err := snapshot.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := target.Put(name, snapshot); err != nil {
+		return 0, fmt.Errorf("failed to store backup: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// pruneBackups deletes the successful backups at the target beyond the number to keep
+func pruneBackups(schedule *database.BackupSchedule, targetName string) {
+	expired, err := database.DB.GetExpiredBackupRuns(targetName, schedule.RetentionCount)
+	if err != nil || len(expired) == 0 {
+		return
+	}
+	target, err := openBackupTarget(schedule)
+	if err != nil {
+		return
+	}
+	for _, run := range expired {
+		if err := target.Delete(run.Name); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Could not delete old backup %s: %v", run.Name, err)
+			continue
+		}
+		database.DB.DeleteBackupRun(run.Id)
+	}
+	log.Printf("Deleted %d old backup(s) from %s, keeping the last %d", len(expired), targetName, schedule.RetentionCount)
+}
+
+// notifyBackupFailed tells every active admin that a backup failed
+func notifyBackupFailed(run *database.BackupRun, serverURL, companyName string) {
+	users, err := database.DB.GetAllUsers()
+	if err != nil {
+		log.Printf("Warning: Could not load admins for backup failure: %v", err)
+		return
+	}
+
+	message := fmt.Sprintf("Backup %s to %s failed: %s", run.Name, run.Target, run.Error)
+	for _, user := range users {
+		if !user.IsAdmin() || !user.IsActive {
+			continue
+		}
+		if !database.DB.NotifyUser(user.Id, database.NotifyAdminReports, "Backup failed", message, "/admin/backup") {
+			continue
+		}
+		if err := email.SendBackupFailedEmail(user.Email, run.Name, run.Target, run.Error, time.Unix(run.CreatedAt, 0), serverURL, companyName); err != nil {
+			log.Printf("Warning: Could not send backup failure to %s: %v", user.Email, err)
+		}
+	}
+}
+
+// StartBackupScheduler starts a check every 10 minutes for a scheduled backup that is due.
+// How often backups are made, how many are kept and where they go is set on the backup page.
+func StartBackupScheduler(serverURL, companyName string) {
+	interval := time.Duration(database.DB.GetBackupSchedule().IntervalHours) * time.Hour
+	ScheduleJob(JobBackup, interval)
+
+	Go(func() {
+		ticker := time.NewTicker(backupCheckInterval)
+		defer ticker.Stop()
+
+		for NextTick(ticker) {
+			// The interval can be changed at any time
+			if current := time.Duration(database.DB.GetBackupSchedule().IntervalHours) * time.Hour; current != interval {
+				interval = current
+				ScheduleJob(JobBackup, interval)
+			}
+			next := JobNextRun(JobBackup)
+			if next.IsZero() || time.Now().Before(next) {
+				continue
+			}
+
+			run, err := RunBackup(false, serverURL, companyName)
+			if err == ErrBackupRunning {
+				continue
+			}
+			database.DB.LogAction(&database.AuditLogEntry{
+				UserID:     0,
+				UserEmail:  "system",
+				Action:     database.ActionDatabaseBackup,
+				EntityType: database.EntitySystem,
+				EntityID:   JobBackup,
+				Details: database.CreateAuditDetails(map[string]interface{}{
+					"name":      run.Name,
+					"target":    run.Target,
+					"size":      run.SizeBytes,
+					"scheduled": true,
+				}),
+				Success:  err == nil,
+				ErrorMsg: run.Error,
+			})
+		}
+	})
+
+	if interval > 0 {
+		log.Printf("Backup scheduler started (every %v)", interval)
+	} else {
+		log.Printf("Backup scheduler started (scheduled backups are off)")
+	}
+}
@@ -6,12 +6,18 @@
 package cleanup
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+	"github.com/Frimurare/WulfVault/internal/webhooks"
 )
 
 // CleanupExpiredFiles moves expired files to trash (soft delete)
@@ -21,6 +27,9 @@ func CleanupExpiredFiles(uploadsDir string) error {
 		return err
 	}
 
+	// Recorded so that a stopped scheduler shows up on the admin dashboard
+	RecordJobRun(JobExpiredFiles)
+
 	if len(files) == 0 {
 		return nil
 	}
@@ -29,25 +38,29 @@ func CleanupExpiredFiles(uploadsDir string) error {
 
 	cleaned := 0
 	for _, file := range files {
-		// Soft delete (move to trash) - use system user ID (0) for automated cleanup
+		// Soft delete (move to trash) - use system user ID (0) for automated cleanup.
+		// This also releases the file's size from its owner's storage usage.
 		if err := database.DB.DeleteFile(file.Id, 0); err != nil {
 			log.Printf("Warning: Could not move file %s to trash: %v", file.Name, err)
 			continue
 		}
 
-		// Recalculate user storage (soft-deleted files don't count toward quota)
-		newStorage, _ := database.DB.CalculateUserStorage(file.UserId)
-		database.DB.UpdateUserStorage(file.UserId, newStorage)
-
 		cleaned++
 		log.Printf("Moved expired file to trash: %s (ID: %s)", file.Name, file.Id)
+
+		ownerEmail := ""
+		if owner, err := database.DB.GetUserByID(file.UserId); err == nil {
+			ownerEmail = owner.Email
+		}
+		webhooks.Dispatch(database.WebhookEventFileExpired, file.Id, file.Name, ownerEmail, "")
 	}
 
 	log.Printf("Expiration cleanup complete: %d files moved to trash", cleaned)
 	return nil
 }
 
-// CleanupTrash permanently deletes files that have been in trash for retentionDays+ days
+// CleanupTrash permanently deletes files that have been in trash for retentionDays+ days,
+// or for the retention chosen when they were deleted
 func CleanupTrash(uploadsDir string, retentionDays int) error {
 	if retentionDays <= 0 {
 		retentionDays = 5 // default fallback
@@ -57,20 +70,27 @@ func CleanupTrash(uploadsDir string, retentionDays int) error {
 	if err != nil {
 		return err
 	}
+	RecordJobRun(JobTrash)
 
 	if len(files) == 0 {
 		return nil
 	}
 
-	log.Printf("Permanently deleting %d files from trash (retention: %d days)...", len(files), retentionDays)
+	log.Printf("Permanently deleting %d files from trash (default retention: %d days)...", len(files), retentionDays)
 
 	deleted := 0
 	for _, file := range files {
-		// Delete from disk
-		filePath := filepath.Join(uploadsDir, file.Id)
-		if err := os.Remove(filePath); err != nil {
+		// Delete from storage
+		if err := storage.Files.Delete(file.Id); err != nil {
 			if !os.IsNotExist(err) {
-				log.Printf("Warning: Could not delete file %s from disk: %v", file.Name, err)
+				log.Printf("Warning: Could not delete file %s from storage: %v", file.Name, err)
+			}
+		}
+
+		// Delete converted preview copies, if any
+		if converted, err := filepath.Glob(filepath.Join(uploadsDir, ".converted", file.Id+".*")); err == nil {
+			for _, path := range converted {
+				os.Remove(path)
 			}
 		}
 
@@ -88,13 +108,46 @@ func CleanupTrash(uploadsDir string, retentionDays int) error {
 	return nil
 }
 
+// CleanupFileVersions permanently deletes earlier versions of files that were replaced
+// retentionDays+ days ago, and those of files that no longer exist
+func CleanupFileVersions(retentionDays int) error {
+	if retentionDays <= 0 {
+		retentionDays = 5 // default fallback
+	}
+
+	versions, err := database.DB.GetExpiredFileVersions(retentionDays)
+	if err != nil {
+		return err
+	}
+
+	deleted := 0
+	for _, v := range versions {
+		if err := storage.Files.Delete(v.StorageId); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Could not delete version %d of %s from storage: %v", v.Version, v.Name, err)
+			continue
+		}
+		if err := database.DB.DeleteFileVersion(v.Id); err != nil {
+			log.Printf("Warning: Could not delete version %d of %s from database: %v", v.Version, v.Name, err)
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		log.Printf("Version cleanup complete: %d earlier file versions permanently deleted", deleted)
+	}
+	return nil
+}
+
 // StartCleanupScheduler starts a background cleanup scheduler
 func StartCleanupScheduler(uploadsDir string, interval time.Duration, trashRetentionDays int) {
 	if trashRetentionDays <= 0 {
 		trashRetentionDays = 5 // default fallback
 	}
+	ScheduleJob(JobExpiredFiles, interval)
+	ScheduleJob(JobTrash, interval)
 
-	go func() {
+	Go(func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
@@ -105,21 +158,70 @@ func StartCleanupScheduler(uploadsDir string, interval time.Duration, trashReten
 		if err := CleanupTrash(uploadsDir, trashRetentionDays); err != nil {
 			log.Printf("Error during trash cleanup: %v", err)
 		}
+		if err := CleanupFileVersions(trashRetentionDays); err != nil {
+			log.Printf("Error during file version cleanup: %v", err)
+		}
 
-		// Then run on schedule
-		for range ticker.C {
+		// Then run on schedule, picking up retention changes made in the server settings
+		for NextTick(ticker) {
 			if err := CleanupExpiredFiles(uploadsDir); err != nil {
 				log.Printf("Error during expired files cleanup: %v", err)
 			}
-			if err := CleanupTrash(uploadsDir, trashRetentionDays); err != nil {
+			retentionDays := database.DB.GetConfigInt("trash_retention_days", trashRetentionDays)
+			if err := CleanupTrash(uploadsDir, retentionDays); err != nil {
 				log.Printf("Error during trash cleanup: %v", err)
 			}
+			if err := CleanupFileVersions(retentionDays); err != nil {
+				log.Printf("Error during file version cleanup: %v", err)
+			}
 		}
-	}()
+	})
 
 	log.Printf("Cleanup scheduler started (interval: %v, trash retention: %d days)", interval, trashRetentionDays)
 }
 
+// CleanupExpiredTokens removes expired sessions and expired or used single-use tokens,
+// keeping each token type for its configured retention
+func CleanupExpiredTokens() error {
+	removed, err := database.DB.CleanupExpiredTokens()
+	RecordJobRun(JobTokens)
+
+	for _, tokenType := range database.TokenTypes {
+		if count := removed[tokenType.Name]; count > 0 {
+			log.Printf("Token cleanup: removed %d expired %s", count, strings.ToLower(tokenType.Label))
+		}
+	}
+	return err
+}
+
+// StartTokenCleanupScheduler starts an hourly job that prunes expired tokens.
+// extra runs alongside it for tokens kept outside the database.
+func StartTokenCleanupScheduler(extra func()) {
+	ScheduleJob(JobTokens, time.Hour)
+
+	Go(func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		// Run immediately on start
+		if err := CleanupExpiredTokens(); err != nil {
+			log.Printf("Error during token cleanup: %v", err)
+		}
+
+		// Then run on schedule
+		for NextTick(ticker) {
+			if err := CleanupExpiredTokens(); err != nil {
+				log.Printf("Error during token cleanup: %v", err)
+			}
+			if extra != nil {
+				extra()
+			}
+		}
+	})
+
+	log.Printf("Token cleanup scheduler started (interval: 1h)")
+}
+
 // CleanupAuditLogs removes audit logs based on retention policy and size limits
 func CleanupAuditLogs(retentionDays int, maxSizeMB int) error {
 	if retentionDays <= 0 {
@@ -129,6 +231,8 @@ func CleanupAuditLogs(retentionDays int, maxSizeMB int) error {
 		maxSizeMB = 100 // default 100MB
 	}
 
+	RecordJobRun(JobAuditLogs)
+
 	// First, cleanup by retention days
 	deletedByDate, err := database.DB.CleanupOldAuditLogs(retentionDays)
 	if err != nil {
@@ -157,8 +261,9 @@ func StartAuditLogCleanupScheduler(retentionDays int, maxSizeMB int) {
 	if maxSizeMB <= 0 {
 		maxSizeMB = 100 // default 100MB
 	}
+	ScheduleJob(JobAuditLogs, 24*time.Hour)
 
-	go func() {
+	Go(func() {
 		// Run every 24 hours
 		ticker := time.NewTicker(24 * time.Hour)
 		defer ticker.Stop()
@@ -168,13 +273,411 @@ func StartAuditLogCleanupScheduler(retentionDays int, maxSizeMB int) {
 			log.Printf("Error during audit log cleanup: %v", err)
 		}
 
-		// Then run on schedule
-		for range ticker.C {
+		// Then run on schedule, picking up retention changes made in the server settings
+		for NextTick(ticker) {
+			retentionDays := database.DB.GetConfigInt("audit_log_retention_days", retentionDays)
+			maxSizeMB := database.DB.GetConfigInt("audit_log_max_size_mb", maxSizeMB)
 			if err := CleanupAuditLogs(retentionDays, maxSizeMB); err != nil {
 				log.Printf("Error during audit log cleanup: %v", err)
 			}
 		}
-	}()
+	})
 
 	log.Printf("Audit log cleanup scheduler started (retention: %d days, max size: %dMB)", retentionDays, maxSizeMB)
 }
+
+// DeactivateIdleDownloadAccounts deactivates download accounts that haven't been used within the
+// configured threshold and emails the admins that created them. Disabled when the threshold is 0.
+func DeactivateIdleDownloadAccounts(serverURL, companyName string) error {
+	RecordJobRun(JobDownloadAccountIdle)
+
+	idleDays := database.DB.GetConfigInt("download_account_idle_days", 0)
+	if idleDays <= 0 {
+		return nil
+	}
+	graceDays := database.DB.GetConfigInt("download_account_grace_days", database.DefaultDownloadAccountGraceDays)
+
+	accounts, err := database.DB.GetIdleDownloadAccounts(idleDays, graceDays)
+	if err != nil {
+		return err
+	}
+
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	log.Printf("Deactivating %d idle download accounts (idle: %d days, grace: %d days)...", len(accounts), idleDays, graceDays)
+
+	byCreator := make(map[int][]*models.DownloadAccount)
+	for _, account := range accounts {
+		if err := database.DB.DeactivateDownloadAccount(account.Id); err != nil {
+			log.Printf("Warning: Could not deactivate download account %s: %v", account.Email, err)
+			continue
+		}
+
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     0,
+			UserEmail:  "system",
+			Action:     database.ActionDownloadAccountDeactivated,
+			EntityType: database.EntityDownloadAccount,
+			EntityID:   fmt.Sprintf("%d", account.Id),
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"email":      account.Email,
+				"reason":     "idle",
+				"last_used":  account.LastUsed,
+				"idle_days":  idleDays,
+				"created_by": account.CreatedBy,
+			}),
+			Success: true,
+		})
+
+		byCreator[account.CreatedBy] = append(byCreator[account.CreatedBy], account)
+		log.Printf("Deactivated idle download account: %s (ID: %d)", account.Email, account.Id)
+	}
+
+	notifyDeactivatedDownloadAccounts(byCreator, idleDays, serverURL, companyName)
+	return nil
+}
+
+// notifyDeactivatedDownloadAccounts emails each creating admin a digest of their deactivated accounts.
+// Self-registered accounts (and those whose creator is no longer an admin) are reported to all admins.
+func notifyDeactivatedDownloadAccounts(byCreator map[int][]*models.DownloadAccount, idleDays int, serverURL, companyName string) {
+	digests := make(map[string][]*models.DownloadAccount)
+	var unowned []*models.DownloadAccount
+
+	for creatorId, accounts := range byCreator {
+		creator, err := database.DB.GetUserByID(creatorId)
+		if creatorId == 0 || err != nil || !creator.IsAdmin() || !creator.IsActive {
+			unowned = append(unowned, accounts...)
+			continue
+		}
+		digests[creator.Email] = append(digests[creator.Email], accounts...)
+	}
+
+	if len(unowned) > 0 {
+		users, err := database.DB.GetAllUsers()
+		if err != nil {
+			log.Printf("Warning: Could not load admins for deactivation notice: %v", err)
+		}
+		for _, user := range users {
+			if user.IsAdmin() && user.IsActive {
+				digests[user.Email] = append(digests[user.Email], unowned...)
+			}
+		}
+	}
+
+	for adminEmail, accounts := range digests {
+		message := fmt.Sprintf("%d download accounts were deactivated after %d days without use", len(accounts), idleDays)
+		if !database.DB.NotifyUserByEmail(adminEmail, database.NotifyAdminReports, "Download accounts deactivated", message, "/admin/users") {
+			continue
+		}
+		if err := email.SendDownloadAccountsDeactivatedEmail(adminEmail, accounts, idleDays, serverURL, companyName); err != nil {
+			log.Printf("Warning: Could not send deactivation notice to %s: %v", adminEmail, err)
+		}
+	}
+}
+
+// StartDownloadAccountIdleScheduler starts a daily job that deactivates idle download accounts
+func StartDownloadAccountIdleScheduler(serverURL, companyName string) {
+	ScheduleJob(JobDownloadAccountIdle, 24*time.Hour)
+
+	Go(func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		// Run immediately on start
+		if err := DeactivateIdleDownloadAccounts(serverURL, companyName); err != nil {
+			log.Printf("Error during idle download account deactivation: %v", err)
+		}
+
+		// Then run on schedule
+		for NextTick(ticker) {
+			if err := DeactivateIdleDownloadAccounts(serverURL, companyName); err != nil {
+				log.Printf("Error during idle download account deactivation: %v", err)
+			}
+		}
+	})
+
+	log.Printf("Idle download account scheduler started (interval: 24h)")
+}
+
+// expiryPolicyBatchSize is how many files ApplyExpiryPolicy updates per query
+const expiryPolicyBatchSize = 100
+
+// ExpiryPolicyResult summarises a retroactive expiry policy run
+type ExpiryPolicyResult struct {
+	Files    int
+	Owners   int
+	ExpireAt int64
+}
+
+// ApplyExpiryPolicy makes active files that never expire, or expire more than maxDays from now,
+// expire in maxDays instead. Files are updated in batches, and each owner gets one email
+// listing their affected files.
+func ApplyExpiryPolicy(maxDays int, serverURL, companyName string) (*ExpiryPolicyResult, error) {
+	if maxDays <= 0 {
+		return nil, fmt.Errorf("no maximum expiry is configured")
+	}
+
+	expireAt := time.Now().Add(time.Duration(maxDays) * 24 * time.Hour).Unix()
+	result := &ExpiryPolicyResult{ExpireAt: expireAt}
+	byOwner := make(map[int][]*database.FileInfo)
+
+	afterId := ""
+	for {
+		files, err := database.DB.GetFilesExceedingExpiry(expireAt, afterId, expiryPolicyBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			if err := database.DB.SetFileExpireAt(file.Id, expireAt); err != nil {
+				log.Printf("Warning: Could not apply expiry policy to %s: %v", file.Name, err)
+				continue
+			}
+			result.Files++
+			byOwner[file.UserId] = append(byOwner[file.UserId], file)
+		}
+		afterId = files[len(files)-1].Id
+	}
+	result.Owners = len(byOwner)
+
+	log.Printf("Expiry policy applied: %d files of %d owners now expire %s", result.Files, result.Owners, time.Unix(expireAt, 0).Format("2006-01-02 15:04"))
+
+	for ownerId, ownerFiles := range byOwner {
+		owner, err := database.DB.GetUserByID(ownerId)
+		if err != nil || !owner.IsActive {
+			continue
+		}
+		message := fmt.Sprintf("%d of your files now expire on %s", len(ownerFiles), time.Unix(expireAt, 0).In(database.ServerLocation()).Format("2006-01-02"))
+		if !database.DB.NotifyUser(owner.Id, database.NotifyExpiry, "File expiry shortened", message, "/dashboard") {
+			continue
+		}
+		if err := email.SendFileExpiryPolicyEmail(owner.Email, ownerFiles, time.Unix(expireAt, 0), maxDays, serverURL, companyName); err != nil {
+			log.Printf("Warning: Could not notify %s about the expiry policy: %v", owner.Email, err)
+		}
+	}
+
+	return result, nil
+}
+
+// SendExpiryReminders emails the recipients of files with expiry reminders enabled when a
+// configured lead time is reached. Each recipient gets at most one reminder per lead time,
+// and none once they have opted out or reached the daily reminder cap.
+func SendExpiryReminders(serverURL, companyName string) error {
+	leads := database.DB.GetExpiryReminderLeadHours()
+	if len(leads) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	files, err := database.DB.GetFilesDueForExpiryReminders(now.Add(time.Duration(leads[0]) * time.Hour).Unix())
+	if err != nil {
+		return err
+	}
+
+	dailyCap := database.DB.GetExpiryReminderDailyCap()
+	sent := 0
+
+	for _, file := range files {
+		if !file.UnlimitedDownloads && file.DownloadsRemaining <= 0 {
+			continue
+		}
+
+		// Only the closest lead time that has been reached is sent, so a file that was
+		// opted in late doesn't trigger a burst of reminders at once
+		remaining := time.Unix(file.ExpireAt, 0).Sub(now)
+		leadHours := 0
+		for _, lead := range leads {
+			if remaining <= time.Duration(lead)*time.Hour {
+				leadHours = lead
+			}
+		}
+		if leadHours == 0 {
+			continue
+		}
+
+		recipients, err := database.DB.GetFileEmailRecipients(file.Id)
+		if err != nil {
+			log.Printf("Warning: Could not load email recipients for file %s: %v", file.Id, err)
+			continue
+		}
+
+		for _, recipient := range recipients {
+			if database.DB.HasExpiryReminder(file.Id, recipient, leadHours) ||
+				database.DB.IsExpiryReminderOptedOut(recipient, file.Id) {
+				continue
+			}
+			if dailyCap > 0 && database.DB.CountExpiryRemindersSince(recipient, now.Add(-24*time.Hour).Unix()) >= dailyCap {
+				log.Printf("Skipping expiry reminder for %s to %s: daily limit of %d reached", file.Name, recipient, dailyCap)
+				continue
+			}
+
+			reminder, err := database.NewExpiryReminder(file.Id, recipient, leadHours)
+			if err != nil {
+				log.Printf("Warning: Could not create expiry reminder for %s: %v", recipient, err)
+				continue
+			}
+
+			fileURL, err := email.ShareLinkURL(serverURL, file.Id, recipient)
+			if err != nil {
+				log.Printf("Warning: Could not create link for expiry reminder to %s: %v", recipient, err)
+				continue
+			}
+			optOutURL := fmt.Sprintf("%s/reminders/unsubscribe/%s", serverURL, reminder.Token)
+			if err := email.SendFileExpiryReminderEmail(recipient, file.Name, time.Unix(file.ExpireAt, 0), fileURL, optOutURL, companyName); err != nil {
+				log.Printf("Warning: Could not send expiry reminder for %s to %s: %v", file.Name, recipient, err)
+				reminder.Status = database.EmailStatusFailed
+			} else {
+				reminder.Status = database.EmailStatusSent
+				sent++
+			}
+
+			if err := database.DB.LogExpiryReminder(reminder); err != nil {
+				log.Printf("Warning: Could not record expiry reminder for %s: %v", recipient, err)
+			}
+		}
+	}
+
+	if sent > 0 {
+		log.Printf("Sent %d file expiry reminder(s)", sent)
+	}
+	return nil
+}
+
+// StartExpiryReminderScheduler starts an hourly job that sends file expiry reminders
+func StartExpiryReminderScheduler(serverURL, companyName string) {
+	Go(func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		// Run immediately on start
+		if err := SendExpiryReminders(serverURL, companyName); err != nil {
+			log.Printf("Error while sending expiry reminders: %v", err)
+		}
+
+		// Then run on schedule
+		for NextTick(ticker) {
+			if err := SendExpiryReminders(serverURL, companyName); err != nil {
+				log.Printf("Error while sending expiry reminders: %v", err)
+			}
+		}
+	})
+
+	log.Printf("Expiry reminder scheduler started (interval: 1h)")
+}
+
+// SendDownloadDigests emails file owners the downloads that were batched because their files
+// were downloaded more often than the download notification limit allows
+func SendDownloadDigests(serverURL string) error {
+	digests, err := database.DB.GetDueDownloadDigests(database.DB.GetDownloadDigestInterval())
+	if err != nil {
+		return err
+	}
+	limit := database.DB.GetDownloadNotifyLimit()
+
+	for _, digest := range digests {
+		owner, err := database.DB.GetUserByID(digest.File.UserId)
+		if err != nil {
+			log.Printf("Warning: Could not get owner of %s for download summary: %v", digest.File.Id, err)
+			continue
+		}
+		// The owner may have turned download emails off since the downloads were batched
+		if database.DB.GetNotificationPreferences(owner.Id)[database.NotifyDownloads].Email {
+			if err := email.QueueDownloadDigest(digest.File, digest.Downloads, serverURL, owner); err != nil {
+				log.Printf("Warning: Could not queue download summary of %s: %v", digest.File.Id, err)
+				continue
+			}
+			log.Printf("Download summary of %s (%d downloads) to %s queued", digest.File.Name, len(digest.Downloads), owner.Email)
+		}
+		lastLogId := digest.Downloads[len(digest.Downloads)-1].Id
+		if err := database.DB.MarkDownloadDigestSent(digest.File.Id, lastLogId, limit); err != nil {
+			log.Printf("Warning: Could not record download summary of %s: %v", digest.File.Id, err)
+		}
+	}
+	return nil
+}
+
+// StartDownloadDigestScheduler starts sending download summaries in the background
+func StartDownloadDigestScheduler(serverURL string) {
+	Go(func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+
+		for NextTick(ticker) {
+			if err := SendDownloadDigests(serverURL); err != nil {
+				log.Printf("Error while sending download summaries: %v", err)
+			}
+		}
+	})
+
+	log.Printf("Download summary scheduler started (interval: 5m)")
+}
+
+// ReconcileExpiredFiles looks for files that expired longer ago than the configured grace period
+// but are still not in trash, which means the cleanup scheduler has stopped or keeps failing.
+// They are logged and audited so the administrator notices, and moved to trash right away if
+// expired_files_auto_trash is set. Returns the number of overdue files found.
+func ReconcileExpiredFiles(uploadsDir string) (int, error) {
+	grace := database.DB.GetExpiredFileGrace()
+	if grace == 0 {
+		return 0, nil
+	}
+
+	files, err := database.DB.GetOverdueExpiredFiles(grace)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	log.Printf("Warning: %d files expired more than %v ago but are not in trash - check that the cleanup scheduler is running: %s",
+		len(files), grace, strings.Join(names, ", "))
+
+	autoTrash, _ := database.DB.GetConfigValue("expired_files_auto_trash")
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     0,
+		UserEmail:  "system",
+		Action:     database.ActionExpiredFilesOverdue,
+		EntityType: database.EntitySystem,
+		EntityID:   "expired_files",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"files":       len(files),
+			"grace_hours": int(grace.Hours()),
+			"auto_trash":  autoTrash == "true",
+		}),
+		Success: true,
+	})
+
+	if autoTrash == "true" {
+		if err := CleanupExpiredFiles(uploadsDir); err != nil {
+			return len(files), err
+		}
+	}
+	return len(files), nil
+}
+
+// StartExpiryReconciliationScheduler starts an hourly check for expired files that cleanup missed.
+// It runs separately from the cleanup scheduler so it keeps working if that one stops.
+func StartExpiryReconciliationScheduler(uploadsDir string) {
+	Go(func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for NextTick(ticker) {
+			if _, err := ReconcileExpiredFiles(uploadsDir); err != nil {
+				log.Printf("Error during expired file reconciliation: %v", err)
+			}
+		}
+	})
+
+	log.Printf("Expired file reconciliation started (interval: 1h)")
+}
@@ -0,0 +1,229 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package cleanup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// JobIntegrityScan verifies stored files against their SHA-256
+const JobIntegrityScan = "integrity_scan"
+
+// ErrIntegrityScanRunning is returned when an integrity scan is started while one is running
+var ErrIntegrityScanRunning = errors.New("an integrity scan is already running")
+
+// integrityScanRunning is set while an integrity scan is in progress. A scan of a large
+// store can take longer than the scheduler interval, so runs never overlap.
+var integrityScanRunning atomic.Bool
+
+// IsIntegrityScanRunning reports whether an integrity scan is in progress
+func IsIntegrityScanRunning() bool {
+	return integrityScanRunning.Load()
+}
+
+// IntegrityScanResult summarizes an integrity scan
+type IntegrityScanResult struct {
+	Verified int                       // files read and checked
+	Problems []*database.FileIntegrity // files that failed and didn't fail the same way last time
+}
+
+// throttledReader limits how fast the integrity scan reads, so it doesn't saturate the disk.
+// One reader's budget is shared by all files of a scan.
+type throttledReader struct {
+	r           io.Reader
+	bytesPerSec int64
+	start       time.Time
+	read        int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Read in small steps so the pauses stay short and evenly spread
+	if int64(len(p)) > t.bytesPerSec/10+1 {
+		p = p[:t.bytesPerSec/10+1]
+	}
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	expected := time.Duration(float64(t.read) / float64(t.bytesPerSec) * float64(time.Second))
+	if elapsed := time.Since(t.start); expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+	return n, err
+}
+
+// verifyFile hashes a file through the scan's throttled reader and compares it with the stored
+// SHA-256. Files without a stored SHA-256 get the calculated one stored as their baseline.
+func verifyFile(file *database.FileIntegrity, throttle *throttledReader) (string, string) {
+	storedHash := database.DB.GetFileSHA256(file.FileID)
+
+	f, err := storage.Files.Get(file.FileID)
+	if os.IsNotExist(err) {
+		return database.IntegrityMissing, "The file is missing from storage"
+	}
+	if err != nil {
+		return database.IntegrityError, err.Error()
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	throttle.r = f
+	if _, err := io.Copy(hash, throttle); err != nil {
+		return database.IntegrityError, err.Error()
+	}
+	actualHash := hex.EncodeToString(hash.Sum(nil))
+
+	if storedHash == "" {
+		if err := database.DB.SetFileSHA256(file.FileID, actualHash); err != nil {
+			return database.IntegrityError, err.Error()
+		}
+		return database.IntegrityOK, "SHA-256 recorded as baseline"
+	}
+	if actualHash != storedHash {
+		// The file was replaced while it was read (e.g. its metadata was stripped), so the
+		// stored SHA-256 no longer applies. It is verified again on the next run.
+		if database.DB.GetFileSHA256(file.FileID) != storedHash {
+			return "", ""
+		}
+		return database.IntegrityMismatch, fmt.Sprintf("Expected SHA-256 %s, found %s", storedHash, actualHash)
+	}
+	return database.IntegrityOK, ""
+}
+
+// RunIntegrityScan verifies active files that weren't verified within the scan interval, or
+// all active files if all is set, and reports files that are corrupt or missing to the admins.
+// Scheduled scans stop early if the scan is turned off while they run, and every scan stops
+// early when the server shuts down.
+func RunIntegrityScan(all bool, serverURL, companyName string) (*IntegrityScanResult, error) {
+	interval := database.DB.GetIntegrityScanInterval()
+	if interval == 0 && !all {
+		return nil, nil
+	}
+	if !integrityScanRunning.CompareAndSwap(false, true) {
+		return nil, ErrIntegrityScanRunning
+	}
+	defer integrityScanRunning.Store(false)
+
+	RecordJobRun(JobIntegrityScan)
+
+	before := time.Now().Add(-interval).Unix()
+	if all {
+		before = time.Now().Unix() + 1
+	}
+	files, err := database.DB.GetFilesDueForVerification(before)
+	if err != nil {
+		return nil, err
+	}
+
+	throttle := &throttledReader{
+		bytesPerSec: int64(database.DB.GetIntegrityScanRateMB()) * 1024 * 1024,
+		start:       time.Now(),
+	}
+	result := &IntegrityScanResult{}
+	for _, file := range files {
+		if !all && database.DB.GetIntegrityScanInterval() == 0 || Stopping() {
+			break
+		}
+
+		status, detail := verifyFile(file, throttle)
+		if status == "" {
+			continue
+		}
+		if err := database.DB.RecordFileIntegrity(file.FileID, status, detail); err != nil {
+			log.Printf("Warning: Could not record integrity of file %s: %v", file.FileID, err)
+			continue
+		}
+		result.Verified++
+
+		if status == database.IntegrityOK {
+			continue
+		}
+		log.Printf("Warning: Integrity check failed for file %s (%s): %s - %s", file.FileID, file.FileName, status, detail)
+
+		// Files that keep failing the same way are reported once
+		if file.Status == status {
+			continue
+		}
+		file.Status = status
+		file.Detail = detail
+		result.Problems = append(result.Problems, file)
+
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     0,
+			UserEmail:  "system",
+			Action:     database.ActionFileIntegrityFailed,
+			EntityType: database.EntityFile,
+			EntityID:   file.FileID,
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"file_name": file.FileName,
+				"owner_id":  file.UserID,
+				"status":    status,
+				"detail":    detail,
+			}),
+			Success:  false,
+			ErrorMsg: detail,
+		})
+	}
+
+	log.Printf("Integrity scan verified %d of %d files, %d new problem(s)", result.Verified, len(files), len(result.Problems))
+	if len(result.Problems) > 0 {
+		notifyIntegrityProblems(result.Problems, serverURL, companyName)
+	}
+	return result, nil
+}
+
+// notifyIntegrityProblems tells every active admin which files failed the integrity scan
+func notifyIntegrityProblems(problems []*database.FileIntegrity, serverURL, companyName string) {
+	users, err := database.DB.GetAllUsers()
+	if err != nil {
+		log.Printf("Warning: Could not load admins for integrity report: %v", err)
+		return
+	}
+
+	message := fmt.Sprintf("%d files are corrupt or missing from disk", len(problems))
+	for _, user := range users {
+		if !user.IsAdmin() || !user.IsActive {
+			continue
+		}
+		if !database.DB.NotifyUser(user.Id, database.NotifyAdminReports, "File integrity problems", message, "/admin/integrity") {
+			continue
+		}
+		if err := email.SendIntegrityProblemsEmail(user.Email, problems, serverURL, companyName); err != nil {
+			log.Printf("Warning: Could not send integrity report to %s: %v", user.Email, err)
+		}
+	}
+}
+
+// StartIntegrityScanScheduler starts an hourly check for files that are due to be verified
+// against their SHA-256. How often each file is verified and how fast files are read is set
+// in the admin settings.
+func StartIntegrityScanScheduler(serverURL, companyName string) {
+	ScheduleJob(JobIntegrityScan, time.Hour)
+
+	Go(func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for NextTick(ticker) {
+			if _, err := RunIntegrityScan(false, serverURL, companyName); err != nil && err != ErrIntegrityScanRunning {
+				log.Printf("Error during integrity scan: %v", err)
+			}
+		}
+	})
+
+	log.Printf("Integrity scan scheduler started (interval: 1h)")
+}
@@ -0,0 +1,134 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package cleanup
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// Scheduled jobs. Each run is recorded in the <job>_last_run setting, so the retention
+// overview can show when a job last ran and when it runs next.
+const (
+	JobExpiredFiles        = "expired_files_cleanup"
+	JobTrash               = "trash_cleanup"
+	JobAuditLogs           = "audit_log_cleanup"
+	JobTokens              = "token_cleanup"
+	JobDownloadAccountIdle = "download_account_idle"
+	JobFileRequests        = "file_request_cleanup"
+	JobDeletedAccounts     = "deleted_account_cleanup"
+	JobUploadChunks        = "chunk_cleanup"
+)
+
+var (
+	jobIntervals   = make(map[string]time.Duration)
+	jobStarted     = make(map[string]time.Time)
+	jobIntervalsMu sync.RWMutex
+
+	stopping     = make(chan struct{})
+	stopOnce     sync.Once
+	runningLoops sync.WaitGroup
+)
+
+// Go runs a scheduler loop in the background, so StopSchedulers can wait for it to return
+func Go(loop func()) {
+	runningLoops.Add(1)
+	go func() {
+		defer runningLoops.Done()
+		loop()
+	}()
+}
+
+// NextTick waits for the ticker's next tick. It returns false once the schedulers are
+// stopped, so loops end with "for NextTick(ticker)" instead of ranging over the ticker.
+func NextTick(ticker *time.Ticker) bool {
+	select {
+	case <-ticker.C:
+		return true
+	case <-stopping:
+		return false
+	}
+}
+
+// Stopping reports whether the schedulers are being stopped. Long jobs check it between items.
+func Stopping() bool {
+	select {
+	case <-stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// StopSchedulers stops every scheduler started with Go and waits for the jobs that are running
+// to finish, or until ctx is done
+func StopSchedulers(ctx context.Context) error {
+	stopOnce.Do(func() { close(stopping) })
+
+	done := make(chan struct{})
+	go func() {
+		runningLoops.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ScheduleJob records how often a job runs. Schedulers call it when they start.
+func ScheduleJob(job string, interval time.Duration) {
+	jobIntervalsMu.Lock()
+	defer jobIntervalsMu.Unlock()
+	jobIntervals[job] = interval
+	jobStarted[job] = time.Now()
+}
+
+// JobInterval returns how often a job runs, or 0 if its scheduler has not started
+func JobInterval(job string) time.Duration {
+	jobIntervalsMu.RLock()
+	defer jobIntervalsMu.RUnlock()
+	return jobIntervals[job]
+}
+
+// RecordJobRun stores the time a job ran
+func RecordJobRun(job string) {
+	database.DB.SetConfigValue(job+"_last_run", strconv.FormatInt(time.Now().Unix(), 10))
+}
+
+// JobLastRun returns when a job last ran, or the zero time if it never has
+func JobLastRun(job string) time.Time {
+	value, _ := database.DB.GetConfigValue(job + "_last_run")
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
+// JobNextRun returns when a job is due to run next, or the zero time if its scheduler has
+// not started. Jobs that haven't run since the scheduler started run one interval after it.
+func JobNextRun(job string) time.Time {
+	jobIntervalsMu.RLock()
+	interval := jobIntervals[job]
+	started := jobStarted[job]
+	jobIntervalsMu.RUnlock()
+	if interval == 0 {
+		return time.Time{}
+	}
+
+	lastRun := JobLastRun(job)
+	if lastRun.Before(started) {
+		lastRun = started
+	}
+	return lastRun.Add(interval)
+}
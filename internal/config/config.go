@@ -7,8 +7,12 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Frimurare/WulfVault/internal/models"
 )
@@ -17,6 +21,8 @@ import (
 type Config struct {
 	ServerURL           string `json:"serverUrl"`
 	Port                string `json:"port"`
+	BindAddress         string `json:"bindAddress"` // IP address to listen on, e.g. 127.0.0.1 behind a reverse proxy ("" = all interfaces)
+	TrustedProxies      []string `json:"trustedProxies"` // Reverse proxies (addresses or CIDR ranges) whose X-Forwarded-For is trusted (default: the local host)
 	DataDir             string `json:"dataDir"`
 	UploadsDir          string `json:"uploadsDir"`
 	MaxFileSizeMB           int    `json:"maxFileSizeMB"`
@@ -27,6 +33,9 @@ type Config struct {
 	AuditLogRetentionDays   int    `json:"auditLogRetentionDays"`   // Days to keep audit logs (default: 90)
 	AuditLogMaxSizeMB       int    `json:"auditLogMaxSizeMB"`       // Auto-cleanup if log exceeds this size (default: 100MB)
 	ServerLogMaxSizeMB      int    `json:"serverLogMaxSizeMB"`      // Max size for server log file (default: 50MB)
+	HTTPTimeouts            HTTPTimeouts `json:"httpTimeouts"`   // Connection timeouts of the HTTP server (0 = default)
+	Storage                 StorageConfig `json:"storage"`       // Where file contents are kept (default: the uploads directory)
+	OIDC                    OIDCConfig `json:"oidc"`             // OpenID Connect single sign-on (off unless an issuer and client ID are set)
 	SaveIP                  bool   `json:"saveIp"`
 	Version                 string `json:"-"` // Runtime version, not persisted
 	models.Branding     `json:"branding"`
@@ -34,6 +43,161 @@ type Config struct {
 
 var Current *Config
 
+// Default HTTP server timeouts. Ordinary requests are small, so short timeouts keep slow or
+// stalled clients (slowloris) from holding connections open; file uploads and downloads get
+// DefaultTransferTimeoutHours instead, see HTTPTimeouts.
+const (
+	DefaultReadHeaderTimeoutSeconds = 10
+	DefaultReadTimeoutSeconds       = 60
+	DefaultWriteTimeoutSeconds      = 120
+	DefaultIdleTimeoutSeconds       = 120
+	DefaultTransferTimeoutHours     = 8
+)
+
+// HTTPTimeouts configures the timeouts of the HTTP server. ReadTimeout and WriteTimeout limit
+// how long reading a whole request and writing its response may take, which would cut off large
+// files on slow connections, so upload and download routes get TransferTimeoutHours for both
+// instead. A long transfer timeout lets a client that stalls in the middle of a transfer hold
+// its connection that long, which is the price of not cutting off multi-gigabyte files.
+type HTTPTimeouts struct {
+	ReadHeaderTimeoutSeconds int `json:"readHeaderTimeoutSeconds"` // Time to read request headers (default: 10)
+	ReadTimeoutSeconds       int `json:"readTimeoutSeconds"`       // Time to read a whole request, body included (default: 60)
+	WriteTimeoutSeconds      int `json:"writeTimeoutSeconds"`      // Time from reading the request to finishing the response (default: 120)
+	IdleTimeoutSeconds       int `json:"idleTimeoutSeconds"`       // Keep-alive time between requests (default: 120)
+	TransferTimeoutHours     int `json:"transferTimeoutHours"`     // Read and write time of file uploads and downloads (default: 8)
+}
+
+func timeoutOrDefault(value, defaultValue int, unit time.Duration) time.Duration {
+	if value <= 0 {
+		value = defaultValue
+	}
+	return time.Duration(value) * unit
+}
+
+// ReadHeaderTimeout returns the time allowed to read request headers
+func (t HTTPTimeouts) ReadHeaderTimeout() time.Duration {
+	return timeoutOrDefault(t.ReadHeaderTimeoutSeconds, DefaultReadHeaderTimeoutSeconds, time.Second)
+}
+
+// ReadTimeout returns the time allowed to read a whole request
+func (t HTTPTimeouts) ReadTimeout() time.Duration {
+	return timeoutOrDefault(t.ReadTimeoutSeconds, DefaultReadTimeoutSeconds, time.Second)
+}
+
+// WriteTimeout returns the time allowed from reading a request to finishing its response
+func (t HTTPTimeouts) WriteTimeout() time.Duration {
+	return timeoutOrDefault(t.WriteTimeoutSeconds, DefaultWriteTimeoutSeconds, time.Second)
+}
+
+// IdleTimeout returns how long a keep-alive connection may wait for the next request
+func (t HTTPTimeouts) IdleTimeout() time.Duration {
+	return timeoutOrDefault(t.IdleTimeoutSeconds, DefaultIdleTimeoutSeconds, time.Second)
+}
+
+// TransferTimeout returns the read and write time allowed for file uploads and downloads
+func (t HTTPTimeouts) TransferTimeout() time.Duration {
+	return timeoutOrDefault(t.TransferTimeoutHours, DefaultTransferTimeoutHours, time.Hour)
+}
+
+// Storage backends
+const (
+	StorageBackendLocal = "local"
+	StorageBackendS3    = "s3"
+)
+
+// StorageConfig chooses where file contents are kept. The uploads directory is still used for
+// chunks of uploads in progress and converted image previews.
+type StorageConfig struct {
+	Backend string   `json:"backend"` // "local" (default) or "s3"
+	S3      S3Config `json:"s3"`
+}
+
+// S3Config configures an S3-compatible bucket, such as AWS S3 or MinIO
+type S3Config struct {
+	Endpoint  string `json:"endpoint"` // e.g. http://minio:9000 (default: AWS S3 in Region)
+	Bucket    string `json:"bucket"`
+	Region    string `json:"region"` // default: us-east-1
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	PathStyle bool   `json:"pathStyle"` // bucket in the URL path instead of the host name, as MinIO usually needs
+	Prefix    string `json:"prefix"`    // optional key prefix, to share a bucket
+}
+
+// ApplyEnv overrides the storage settings with the STORAGE_BACKEND and S3_* environment
+// variables that are set
+func (c *StorageConfig) ApplyEnv() {
+	setFromEnv := func(target *string, key string) {
+		if value := os.Getenv(key); value != "" {
+			*target = value
+		}
+	}
+	setFromEnv(&c.Backend, "STORAGE_BACKEND")
+	setFromEnv(&c.S3.Endpoint, "S3_ENDPOINT")
+	setFromEnv(&c.S3.Bucket, "S3_BUCKET")
+	setFromEnv(&c.S3.Region, "S3_REGION")
+	setFromEnv(&c.S3.AccessKey, "S3_ACCESS_KEY_ID")
+	setFromEnv(&c.S3.SecretKey, "S3_SECRET_ACCESS_KEY")
+	setFromEnv(&c.S3.Prefix, "S3_PREFIX")
+	if value := os.Getenv("S3_PATH_STYLE"); value != "" {
+		c.S3.PathStyle = value == "true" || value == "1"
+	}
+}
+
+// OIDCConfig configures single sign-on with an OpenID Connect provider, such as Keycloak,
+// Entra ID, Okta or Google
+type OIDCConfig struct {
+	IssuerURL    string `json:"issuerUrl"` // e.g. https://login.example.com/realms/company
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RedirectURI  string `json:"redirectUri"` // default: the server URL + /auth/oidc/callback
+}
+
+// Enabled reports whether single sign-on is configured
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
+// ApplyEnv overrides the single sign-on settings with the OIDC_* environment variables that are set
+func (c *OIDCConfig) ApplyEnv() {
+	for target, key := range map[*string]string{
+		&c.IssuerURL:    "OIDC_ISSUER_URL",
+		&c.ClientID:     "OIDC_CLIENT_ID",
+		&c.ClientSecret: "OIDC_CLIENT_SECRET",
+		&c.RedirectURI:  "OIDC_REDIRECT_URI",
+	} {
+		if value := os.Getenv(key); value != "" {
+			*target = value
+		}
+	}
+}
+
+// DefaultTrustedProxies are trusted when no trusted proxies are configured, which covers a reverse
+// proxy on the same host
+var DefaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// ApplyTrustedProxiesEnv overrides the trusted proxies with the comma-separated TRUSTED_PROXIES
+// environment variable, if it is set
+func (c *Config) ApplyTrustedProxiesEnv() {
+	value := os.Getenv("TRUSTED_PROXIES")
+	if value == "" {
+		return
+	}
+	c.TrustedProxies = nil
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			c.TrustedProxies = append(c.TrustedProxies, proxy)
+		}
+	}
+}
+
+// GetTrustedProxies returns the configured trusted proxies, or DefaultTrustedProxies
+func (c *Config) GetTrustedProxies() []string {
+	if len(c.TrustedProxies) == 0 {
+		return DefaultTrustedProxies
+	}
+	return c.TrustedProxies
+}
+
 // WulfVaultSignature is the watermark constant for attribution
 const WulfVaultSignature = "WulfVault::UlfHolmström::2025"
 
@@ -64,6 +228,13 @@ func LoadOrCreate(dataDir string) (*Config, error) {
 		AuditLogRetentionDays: 90,  // Keep audit logs for 90 days by default
 		AuditLogMaxSizeMB:     100, // Auto-cleanup if log exceeds 100MB
 		ServerLogMaxSizeMB:    50,  // Max size for server log file (default: 50MB)
+		HTTPTimeouts: HTTPTimeouts{
+			ReadHeaderTimeoutSeconds: DefaultReadHeaderTimeoutSeconds,
+			ReadTimeoutSeconds:       DefaultReadTimeoutSeconds,
+			WriteTimeoutSeconds:      DefaultWriteTimeoutSeconds,
+			IdleTimeoutSeconds:       DefaultIdleTimeoutSeconds,
+			TransferTimeoutHours:     DefaultTransferTimeoutHours,
+		},
 		SaveIP:                false,
 		Branding:              models.DefaultBranding(),
 	}
@@ -77,6 +248,20 @@ func LoadOrCreate(dataDir string) (*Config, error) {
 	return cfg, nil
 }
 
+// ValidateBindAddress checks that a bind address is an IP address or "localhost".
+// An empty address listens on all interfaces.
+func ValidateBindAddress(addr string) error {
+	if addr == "" || addr == "localhost" || net.ParseIP(addr) != nil {
+		return nil
+	}
+	return fmt.Errorf("invalid bind address %q: use an IP address such as 127.0.0.1 or 0.0.0.0", addr)
+}
+
+// ListenAddress returns the host:port the server listens on
+func (c *Config) ListenAddress() string {
+	return net.JoinHostPort(c.BindAddress, c.Port)
+}
+
 // Save writes configuration to file
 func (c *Config) Save(path string) error {
 	data, err := json.MarshalIndent(c, "", "  ")
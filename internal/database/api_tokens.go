@@ -0,0 +1,153 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiTokenPrefix starts every API token, so leaked tokens are easy to recognise
+const apiTokenPrefix = "wv_"
+
+// apiTokenLookupLength is how many characters after apiTokenPrefix identify a token. They are
+// stored in plain text to find the token's row; the whole token is only stored as a bcrypt hash.
+const apiTokenLookupLength = 12
+
+// MaxAPITokenNameLength limits the name a user gives a token
+const MaxAPITokenNameLength = 100
+
+// ErrInvalidAPIToken is returned for tokens that don't exist, were revoked or have expired
+var ErrInvalidAPIToken = errors.New("invalid API token")
+
+// APIToken is a personal token that authenticates scripts as its user
+type APIToken struct {
+	Id         int    `json:"id"`
+	UserId     int    `json:"userId"`
+	Name       string `json:"name"`
+	Prefix     string `json:"prefix"` // the start of the token, to tell tokens apart
+	CreatedAt  int64  `json:"createdAt"`
+	ExpiresAt  int64  `json:"expiresAt"` // 0 = never expires
+	LastUsedAt int64  `json:"lastUsedAt"`
+}
+
+// IsExpired reports whether the token can no longer be used
+func (t *APIToken) IsExpired() bool {
+	return t.ExpiresAt > 0 && t.ExpiresAt <= time.Now().Unix()
+}
+
+// CreateAPIToken creates a token that never expires and returns it. The token itself is not
+// stored, so it can only be shown now.
+func (d *Database) CreateAPIToken(userID int, name string) (string, error) {
+	return d.CreateAPITokenWithExpiry(userID, name, 0)
+}
+
+// CreateAPITokenWithExpiry creates a token that stops working at expiresAt (0 = never) and returns it
+func (d *Database) CreateAPITokenWithExpiry(userID int, name string, expiresAt int64) (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(secret)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, created_at, expires_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?, 0)`,
+		userID, name, apiTokenLookup(token), string(hash), time.Now().Unix(), expiresAt,
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// apiTokenLookup returns the part of a token its row is found by, or "" if it isn't an API token
+func apiTokenLookup(token string) string {
+	if !strings.HasPrefix(token, apiTokenPrefix) || len(token) < len(apiTokenPrefix)+apiTokenLookupLength {
+		return ""
+	}
+	return token[len(apiTokenPrefix) : len(apiTokenPrefix)+apiTokenLookupLength]
+}
+
+// GetUserByAPIToken returns the active user an unexpired token belongs to and records that the
+// token was used
+func (d *Database) GetUserByAPIToken(token string) (*models.User, error) {
+	lookup := apiTokenLookup(token)
+	if lookup == "" {
+		return nil, ErrInvalidAPIToken
+	}
+
+	var id, userID int
+	var hash string
+	var expiresAt int64
+	err := d.db.QueryRow("SELECT id, user_id, token_hash, expires_at FROM api_tokens WHERE token_prefix = ?", lookup).
+		Scan(&id, &userID, &hash, &expiresAt)
+	if err != nil {
+		return nil, ErrInvalidAPIToken
+	}
+	if expiresAt > 0 && expiresAt <= time.Now().Unix() {
+		return nil, ErrInvalidAPIToken
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(token)) != nil {
+		return nil, ErrInvalidAPIToken
+	}
+
+	user, err := d.GetUserByID(userID)
+	if err != nil || !user.IsActive {
+		return nil, ErrInvalidAPIToken
+	}
+
+	d.db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", time.Now().Unix(), id)
+	return user, nil
+}
+
+// GetAPITokens returns a user's tokens, newest first
+func (d *Database) GetAPITokens(userID int) ([]*APIToken, error) {
+	rows, err := d.db.Query(`
+		SELECT id, user_id, name, token_prefix, created_at, expires_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		token := &APIToken{}
+		if err := rows.Scan(&token.Id, &token.UserId, &token.Name, &token.Prefix,
+			&token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt); err != nil {
+			return nil, err
+		}
+		token.Prefix = apiTokenPrefix + token.Prefix
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken deletes one of the user's tokens, which stops working immediately
+func (d *Database) RevokeAPIToken(userID, tokenID int) error {
+	result, err := d.db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("API token not found")
+	}
+	return nil
+}
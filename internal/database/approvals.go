@@ -0,0 +1,249 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRejected = "rejected"
+)
+
+// FileApproval tracks a public upload that must be released by an approver
+type FileApproval struct {
+	Id          int
+	FileId      string
+	TeamId      int
+	RequestedBy int
+	RequestedAt int64
+	Status      string
+	DecidedBy   int
+	DecidedAt   int64
+	Note        string
+
+	// Populated via JOIN
+	FileName       string
+	FileSize       string
+	RequesterEmail string
+}
+
+// IsUploadApprovalRequired reports whether a public upload shared to the given teams
+// must be approved. Returns the team whose admins may approve it (0 if only system-wide approvers may).
+func (d *Database) IsUploadApprovalRequired(teamIds []int) (bool, int) {
+	for _, teamId := range teamIds {
+		var requireApproval int
+		err := d.db.QueryRow("SELECT RequireApproval FROM Teams WHERE Id = ? AND IsActive = 1", teamId).Scan(&requireApproval)
+		if err == nil && requireApproval == 1 {
+			return true, teamId
+		}
+	}
+
+	if value, _ := d.GetConfigValue("upload_approval_required"); value == "true" {
+		// Let the admins of the first team the file is shared with approve it as well
+		if len(teamIds) > 0 {
+			return true, teamIds[0]
+		}
+		return true, 0
+	}
+
+	return false, 0
+}
+
+// CreateFileApproval puts a file in the pending approval state
+func (d *Database) CreateFileApproval(fileId string, teamId, requestedBy int) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO FileApprovals (FileId, TeamId, RequestedBy, RequestedAt, Status, DecidedBy, DecidedAt, Note)
+		VALUES (?, ?, ?, ?, ?, 0, 0, '')`,
+		fileId, teamId, requestedBy, time.Now().Unix(), ApprovalStatusPending,
+	)
+	return err
+}
+
+// GetFileApproval returns the approval record for a file
+func (d *Database) GetFileApproval(fileId string) (*FileApproval, error) {
+	approval := &FileApproval{}
+	var note sql.NullString
+
+	err := d.db.QueryRow(`
+		SELECT a.Id, a.FileId, a.TeamId, a.RequestedBy, a.RequestedAt, a.Status, a.DecidedBy, a.DecidedAt, a.Note,
+		       f.Name, f.Size, COALESCE(u.Email, '')
+		FROM FileApprovals a
+		INNER JOIN Files f ON a.FileId = f.Id
+		LEFT JOIN Users u ON a.RequestedBy = u.Id
+		WHERE a.FileId = ?`, fileId).Scan(
+		&approval.Id, &approval.FileId, &approval.TeamId, &approval.RequestedBy, &approval.RequestedAt,
+		&approval.Status, &approval.DecidedBy, &approval.DecidedAt, &note,
+		&approval.FileName, &approval.FileSize, &approval.RequesterEmail,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("approval not found")
+		}
+		return nil, err
+	}
+
+	approval.Note = note.String
+	return approval, nil
+}
+
+// GetFileApprovalStatus returns the approval status of a file, or "" if it never needed approval
+func (d *Database) GetFileApprovalStatus(fileId string) string {
+	var status string
+	if err := d.db.QueryRow("SELECT Status FROM FileApprovals WHERE FileId = ?", fileId).Scan(&status); err != nil {
+		return ""
+	}
+	return status
+}
+
+// GetFileApprovalStatuses returns approval statuses for the given files (files without a record are omitted)
+func (d *Database) GetFileApprovalStatuses(fileIds []string) (map[string]string, error) {
+	result := make(map[string]string)
+	if len(fileIds) == 0 {
+		return result, nil
+	}
+
+	placeholders := make([]string, len(fileIds))
+	args := make([]interface{}, len(fileIds))
+	for i, id := range fileIds {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := d.db.Query(`
+		SELECT FileId, Status FROM FileApprovals
+		WHERE FileId IN (`+strings.Join(placeholders, ",")+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileId, status string
+		if err := rows.Scan(&fileId, &status); err != nil {
+			return nil, err
+		}
+		result[fileId] = status
+	}
+
+	return result, rows.Err()
+}
+
+// GetPendingApprovals returns all pending approvals for files that are not in trash
+func (d *Database) GetPendingApprovals() ([]*FileApproval, error) {
+	rows, err := d.db.Query(`
+		SELECT a.Id, a.FileId, a.TeamId, a.RequestedBy, a.RequestedAt, a.Status, a.DecidedBy, a.DecidedAt, a.Note,
+		       f.Name, f.Size, COALESCE(u.Email, '')
+		FROM FileApprovals a
+		INNER JOIN Files f ON a.FileId = f.Id
+		LEFT JOIN Users u ON a.RequestedBy = u.Id
+		WHERE a.Status = ? AND f.DeletedAt = 0
+		ORDER BY a.RequestedAt ASC`, ApprovalStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []*FileApproval
+	for rows.Next() {
+		approval := &FileApproval{}
+		var note sql.NullString
+		if err := rows.Scan(&approval.Id, &approval.FileId, &approval.TeamId, &approval.RequestedBy, &approval.RequestedAt,
+			&approval.Status, &approval.DecidedBy, &approval.DecidedAt, &note,
+			&approval.FileName, &approval.FileSize, &approval.RequesterEmail); err != nil {
+			return nil, err
+		}
+		approval.Note = note.String
+		approvals = append(approvals, approval)
+	}
+
+	return approvals, rows.Err()
+}
+
+// DecideFileApproval approves or rejects a pending file
+func (d *Database) DecideFileApproval(fileId, status string, decidedBy int, note string) error {
+	if status != ApprovalStatusApproved && status != ApprovalStatusRejected {
+		return errors.New("invalid approval status")
+	}
+
+	result, err := d.db.Exec(`
+		UPDATE FileApprovals
+		SET Status = ?, DecidedBy = ?, DecidedAt = ?, Note = ?
+		WHERE FileId = ? AND Status = ?`,
+		status, decidedBy, time.Now().Unix(), note, fileId, ApprovalStatusPending,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errors.New("no pending approval for this file")
+	}
+	return nil
+}
+
+// IsDesignatedApprover checks if an email is listed in the upload_approver_emails setting
+func (d *Database) IsDesignatedApprover(email string) bool {
+	value, _ := d.GetConfigValue("upload_approver_emails")
+	for _, approver := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(approver), email) && email != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetApproverEmails returns everyone who may approve uploads for a team (0 = global):
+// system admins, designated approvers and, for team uploads, the team's owners and admins
+func (d *Database) GetApproverEmails(teamId int) ([]string, error) {
+	seen := make(map[string]bool)
+	var emails []string
+	add := func(email string) {
+		email = strings.TrimSpace(email)
+		key := strings.ToLower(email)
+		if email != "" && !seen[key] {
+			seen[key] = true
+			emails = append(emails, email)
+		}
+	}
+
+	rows, err := d.db.Query("SELECT Email FROM Users WHERE Userlevel <= 1 AND IsActive = 1 AND DeletedAt = 0")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err == nil {
+			add(email)
+		}
+	}
+	rows.Close()
+
+	value, _ := d.GetConfigValue("upload_approver_emails")
+	for _, approver := range strings.Split(value, ",") {
+		add(approver)
+	}
+
+	if teamId > 0 {
+		members, err := d.GetTeamMembers(teamId)
+		if err != nil {
+			return emails, err
+		}
+		for _, member := range members {
+			if member.CanReviewUploads() {
+				add(member.UserEmail)
+			}
+		}
+	}
+
+	return emails, nil
+}
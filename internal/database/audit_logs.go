@@ -28,8 +28,11 @@ type AuditLogEntry struct {
 // AuditLogFilter for querying audit logs
 type AuditLogFilter struct {
 	UserID      int64
+	UserEmail   string // part of the email address
 	Action      string
 	EntityType  string
+	EntityID    string
+	Status      string // AuditStatusSuccess, AuditStatusFailure or "" for both
 	StartDate   int64
 	EndDate     int64
 	SearchTerm  string
@@ -97,45 +100,75 @@ func (db *Database) LogAction(entry *AuditLogEntry) error {
 	return err
 }
 
-// GetAuditLogs retrieves audit logs with optional filtering
-func (db *Database) GetAuditLogs(filter *AuditLogFilter) ([]*AuditLogEntry, error) {
-	query := `SELECT id, timestamp, user_id, user_email, action, entity_type, entity_id,
-	          details, ip_address, user_agent, success, error_msg
-	          FROM audit_logs WHERE 1=1`
+// Values of AuditLogFilter.Status
+const (
+	AuditStatusSuccess = "success"
+	AuditStatusFailure = "failure"
+)
+
+// whereClause returns the SQL conditions and arguments selecting the logs the filter matches
+func (filter *AuditLogFilter) whereClause() (string, []interface{}) {
+	clause := "1=1"
 	args := []interface{}{}
 
 	if filter.UserID > 0 {
-		query += " AND user_id = ?"
+		clause += " AND user_id = ?"
 		args = append(args, filter.UserID)
 	}
 
+	if filter.UserEmail != "" {
+		clause += " AND user_email LIKE ?"
+		args = append(args, "%"+filter.UserEmail+"%")
+	}
+
 	if filter.Action != "" {
-		query += " AND action = ?"
+		clause += " AND action = ?"
 		args = append(args, filter.Action)
 	}
 
 	if filter.EntityType != "" {
-		query += " AND entity_type = ?"
+		clause += " AND entity_type = ?"
 		args = append(args, filter.EntityType)
 	}
 
+	if filter.EntityID != "" {
+		// Some actions, like ZIP downloads, record several comma separated IDs
+		clause += " AND (entity_id = ? OR ',' || entity_id || ',' LIKE ?)"
+		args = append(args, filter.EntityID, "%,"+filter.EntityID+",%")
+	}
+
+	switch filter.Status {
+	case AuditStatusSuccess:
+		clause += " AND success = 1"
+	case AuditStatusFailure:
+		clause += " AND success = 0"
+	}
+
 	if filter.StartDate > 0 {
-		query += " AND timestamp >= ?"
+		clause += " AND timestamp >= ?"
 		args = append(args, filter.StartDate)
 	}
 
 	if filter.EndDate > 0 {
-		query += " AND timestamp <= ?"
+		clause += " AND timestamp <= ?"
 		args = append(args, filter.EndDate)
 	}
 
 	if filter.SearchTerm != "" {
-		query += " AND (user_email LIKE ? OR action LIKE ? OR details LIKE ? OR entity_id LIKE ?)"
+		clause += " AND (user_email LIKE ? OR action LIKE ? OR details LIKE ? OR entity_id LIKE ?)"
 		searchPattern := "%" + filter.SearchTerm + "%"
 		args = append(args, searchPattern, searchPattern, searchPattern, searchPattern)
 	}
 
-	query += " ORDER BY timestamp DESC"
+	return clause, args
+}
+
+// GetAuditLogs retrieves audit logs with optional filtering
+func (db *Database) GetAuditLogs(filter *AuditLogFilter) ([]*AuditLogEntry, error) {
+	where, args := filter.whereClause()
+	query := `SELECT id, timestamp, user_id, user_email, action, entity_type, entity_id,
+	          details, ip_address, user_agent, success, error_msg
+	          FROM audit_logs WHERE ` + where + " ORDER BY timestamp DESC, id DESC"
 
 	if filter.Limit > 0 {
 		query += " LIMIT ?"
@@ -183,45 +216,35 @@ func (db *Database) GetAuditLogs(filter *AuditLogFilter) ([]*AuditLogEntry, erro
 
 // GetAuditLogCount returns total count of logs matching filter
 func (db *Database) GetAuditLogCount(filter *AuditLogFilter) (int, error) {
-	query := "SELECT COUNT(*) FROM audit_logs WHERE 1=1"
-	args := []interface{}{}
-
-	if filter.UserID > 0 {
-		query += " AND user_id = ?"
-		args = append(args, filter.UserID)
-	}
-
-	if filter.Action != "" {
-		query += " AND action = ?"
-		args = append(args, filter.Action)
-	}
-
-	if filter.EntityType != "" {
-		query += " AND entity_type = ?"
-		args = append(args, filter.EntityType)
-	}
-
-	if filter.StartDate > 0 {
-		query += " AND timestamp >= ?"
-		args = append(args, filter.StartDate)
-	}
-
-	if filter.EndDate > 0 {
-		query += " AND timestamp <= ?"
-		args = append(args, filter.EndDate)
-	}
-
-	if filter.SearchTerm != "" {
-		query += " AND (user_email LIKE ? OR action LIKE ? OR details LIKE ? OR entity_id LIKE ?)"
-		searchPattern := "%" + filter.SearchTerm + "%"
-		args = append(args, searchPattern, searchPattern, searchPattern, searchPattern)
-	}
+	where, args := filter.whereClause()
 
 	var count int
-	err := db.db.QueryRow(query, args...).Scan(&count)
+	err := db.db.QueryRow("SELECT COUNT(*) FROM audit_logs WHERE "+where, args...).Scan(&count)
 	return count, err
 }
 
+// GetAuditLogActions returns the actions and entity types that occur in the audit log, for
+// the filters of the audit log page
+func (db *Database) GetAuditLogActions() (actions []string, entityTypes []string, err error) {
+	for _, list := range []struct {
+		column string
+		values *[]string
+	}{{"action", &actions}, {"entity_type", &entityTypes}} {
+		rows, err := db.db.Query("SELECT DISTINCT " + list.column + " FROM audit_logs WHERE " + list.column + " != '' ORDER BY " + list.column)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&value); err == nil {
+				*list.values = append(*list.values, value)
+			}
+		}
+		rows.Close()
+	}
+	return actions, entityTypes, nil
+}
+
 // CleanupOldAuditLogs removes logs older than specified days
 func (db *Database) CleanupOldAuditLogs(retentionDays int) (int64, error) {
 	cutoffTime := time.Now().AddDate(0, 0, -retentionDays).Unix()
@@ -361,26 +384,67 @@ const (
 	ActionUserDeactivated  = "USER_DEACTIVATED"
 	ActionUserQuotaChanged = "USER_QUOTA_CHANGED"
 	ActionUserRoleChanged  = "USER_ROLE_CHANGED"
+	ActionUserPromoted     = "USER_PROMOTED"
+	ActionUserDemoted      = "USER_DEMOTED"
+	ActionEmailChangeRequested = "EMAIL_CHANGE_REQUESTED"
+	ActionEmailChanged         = "EMAIL_CHANGED"
+	ActionWelcomeEmailsResent  = "WELCOME_EMAILS_RESENT"
+	ActionNotificationPrefsUpdated = "NOTIFICATION_PREFERENCES_UPDATED"
 
 	// Authentication actions
 	ActionLoginSuccess        = "LOGIN_SUCCESS"
 	ActionLoginFailed         = "LOGIN_FAILED"
+	ActionLoginLocked         = "LOGIN_LOCKED"
+	ActionAdminAccessDenied   = "ADMIN_ACCESS_DENIED"
 	ActionLogout              = "LOGOUT"
+	ActionLogoutEverywhere    = "LOGOUT_EVERYWHERE"
+	ActionSessionRevoked      = "SESSION_REVOKED"
 	Action2FAEnabled          = "2FA_ENABLED"
 	Action2FADisabled         = "2FA_DISABLED"
+	ActionBackupCodesGenerated = "BACKUP_CODES_GENERATED"
+	ActionBackupCodeUsed       = "BACKUP_CODE_USED"
+	ActionTrustedDeviceAdded   = "TRUSTED_DEVICE_ADDED"
+	ActionTrustedDeviceRevoked = "TRUSTED_DEVICE_REVOKED"
+	ActionAPITokenCreated      = "API_TOKEN_CREATED"
+	ActionAPITokenRevoked      = "API_TOKEN_REVOKED"
 	ActionPasswordChanged     = "PASSWORD_CHANGED"
 	ActionPasswordResetRequested = "PASSWORD_RESET_REQUESTED"
 	ActionPasswordResetCompleted = "PASSWORD_RESET_COMPLETED"
+	ActionImpersonationStarted   = "IMPERSONATION_STARTED"
+	ActionImpersonationEnded     = "IMPERSONATION_ENDED"
+	ActionImpersonatedRequest    = "IMPERSONATED_REQUEST"
 
 	// File actions
 	ActionFileUploaded       = "FILE_UPLOADED"
+	ActionFileUploadCancelled = "FILE_UPLOAD_CANCELLED"
 	ActionFileDeleted        = "FILE_DELETED"
 	ActionFileRestored       = "FILE_RESTORED"
 	ActionFilePermanentlyDeleted = "FILE_PERMANENTLY_DELETED"
+	ActionFilesBulkDeleted       = "FILES_BULK_DELETED"
+	ActionFilesBulkRestored      = "FILES_BULK_RESTORED"
 	ActionFileShared         = "FILE_SHARED"
 	ActionFileDownloaded     = "FILE_DOWNLOADED"
+	ActionFilesZipDownloaded = "FILES_ZIP_DOWNLOADED"
 	ActionFileExpired        = "FILE_EXPIRED"
 	ActionEmailSent          = "EMAIL_SENT"
+	ActionFileApprovalRequested = "FILE_APPROVAL_REQUESTED"
+	ActionFileApproved          = "FILE_APPROVED"
+	ActionFileRejected          = "FILE_REJECTED"
+	ActionFilesExported         = "FILES_EXPORTED"
+	ActionFileAccessRequested   = "FILE_ACCESS_REQUESTED"
+	ActionFileAccessGranted     = "FILE_ACCESS_GRANTED"
+	ActionFileAccessDenied      = "FILE_ACCESS_DENIED"
+	ActionFileAccessRevoked     = "FILE_ACCESS_REVOKED"
+	ActionFileReshareRequested  = "FILE_RESHARE_REQUESTED"
+	ActionFileDownloadsBlocked   = "FILE_DOWNLOADS_BLOCKED"
+	ActionFileDownloadsUnblocked = "FILE_DOWNLOADS_UNBLOCKED"
+	ActionFileDownloadsReset     = "FILE_DOWNLOADS_RESET"
+	ActionFileBurned             = "FILE_BURNED"
+	ActionFileVersionUploaded    = "FILE_VERSION_UPLOADED"
+	ActionFileVersionRestored    = "FILE_VERSION_RESTORED"
+	ActionFileInfected           = "FILE_INFECTED"
+	ActionExpiryRemindersOptOut = "EXPIRY_REMINDERS_OPT_OUT"
+	ActionExpiryPolicyApplied = "EXPIRY_POLICY_APPLIED"
 
 	// Team actions
 	ActionTeamCreated       = "TEAM_CREATED"
@@ -391,13 +455,23 @@ const (
 	ActionTeamMemberRoleChanged = "TEAM_MEMBER_ROLE_CHANGED"
 	ActionFileSharedWithTeam = "FILE_SHARED_WITH_TEAM"
 	ActionFileUnsharedFromTeam = "FILE_UNSHARED_FROM_TEAM"
+	ActionTeamFilesDownloaded  = "TEAM_FILES_DOWNLOADED"
 
 	// Settings actions
 	ActionSettingsUpdated = "SETTINGS_UPDATED"
+	ActionWebhookCreated  = "WEBHOOK_CREATED"
+	ActionWebhookUpdated  = "WEBHOOK_UPDATED"
+	ActionWebhookDeleted  = "WEBHOOK_DELETED"
+	ActionWebhookFailed   = "WEBHOOK_DELIVERY_FAILED"
+	ActionEmailFailed     = "EMAIL_DELIVERY_FAILED"
+	ActionEmailRetried    = "EMAIL_RETRIED"
+	ActionEmailDiscarded  = "EMAIL_DISCARDED"
 	ActionBrandingUpdated = "BRANDING_UPDATED"
 	ActionEmailConfigUpdated = "EMAIL_CONFIG_UPDATED"
+	ActionEmailTestSent      = "EMAIL_TEST_SENT"
 	ActionLogoUploaded    = "LOGO_UPLOADED"
 	ActionLogoDeleted     = "LOGO_DELETED"
+	ActionDownloadTermsPublished = "DOWNLOAD_TERMS_PUBLISHED"
 
 	// Download account actions
 	ActionDownloadAccountCreated   = "DOWNLOAD_ACCOUNT_CREATED"
@@ -415,7 +489,15 @@ const (
 	ActionSystemStarted = "SYSTEM_STARTED"
 	ActionSystemRestarted = "SYSTEM_RESTARTED"
 	ActionDatabaseBackup = "DATABASE_BACKUP"
+	ActionDatabaseRestored = "DATABASE_RESTORED"
 	ActionAuditLogCleanup = "AUDIT_LOG_CLEANUP"
+	ActionMaintenanceEnabled  = "MAINTENANCE_ENABLED"
+	ActionMaintenanceDisabled = "MAINTENANCE_DISABLED"
+	ActionExpiredFilesOverdue = "EXPIRED_FILES_OVERDUE"
+	ActionExpiredFilesTrashed = "EXPIRED_FILES_TRASHED"
+	ActionFileIntegrityFailed = "FILE_INTEGRITY_FAILED"
+	ActionIntegrityScanRun    = "INTEGRITY_SCAN_RUN"
+	ActionStorageRecalculated = "STORAGE_RECALCULATED"
 )
 
 // Entity type constants
@@ -428,4 +510,6 @@ const (
 	EntityFileRequest     = "FileRequest"
 	EntitySession         = "Session"
 	EntitySystem          = "System"
+	EntityWebhook         = "Webhook"
+	EntityEmail           = "Email"
 )
@@ -0,0 +1,243 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// backupRequiredTables must exist in a database before it can be restored
+var backupRequiredTables = []string{"Users", "Files", "Sessions", "Configuration"}
+
+// Path returns the file the database is stored in
+func (d *Database) Path() string {
+	return d.path
+}
+
+// BackupTo writes a consistent snapshot of the database to path, which must not exist yet.
+// Writes that happen meanwhile are either fully in the snapshot or not at all.
+func (d *Database) BackupTo(path string) error {
+	if _, err := d.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// ValidateBackup checks that the file at path is an intact WulfVault database that can be
+// restored: it passes SQLite's integrity check, has the core tables and a super admin who
+// can sign in afterwards
+func ValidateBackup(path string) error {
+	backup, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer backup.Close()
+
+	var integrity string
+	if err := backup.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return fmt.Errorf("the backup is not a SQLite database: %w", err)
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("the backup database is damaged: %s", integrity)
+	}
+
+	for _, table := range backupRequiredTables {
+		var count int
+		if err := backup.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("the backup is not a WulfVault database: table %s is missing", table)
+		}
+	}
+
+	var superAdmins int
+	if err := backup.QueryRow("SELECT COUNT(*) FROM Users WHERE UserLevel = ?", models.UserLevelSuperAdmin).Scan(&superAdmins); err != nil {
+		return fmt.Errorf("failed to read users from backup: %w", err)
+	}
+	if superAdmins == 0 {
+		return fmt.Errorf("the backup has no super admin account")
+	}
+	return nil
+}
+
+// RestoreFrom replaces the database with the backup at backupPath, which must have passed
+// ValidateBackup and be in the same directory, and opens it as DB. The replaced database is
+// kept next to it and its name returned. Migrations run on the restored database, so backups
+// from older versions are brought up to date. If the backup can't be opened, the replaced
+// database is put back.
+func RestoreFrom(backupPath string) (string, error) {
+	dbPath := DB.path
+	dataDir := filepath.Dir(dbPath)
+	keptPath := fmt.Sprintf("%s.before-restore-%s", dbPath, time.Now().Format("20060102-150405"))
+
+	// Closing checkpoints the write-ahead log into the database file
+	if err := DB.Close(); err != nil {
+		log.Printf("Warning: Error closing database before restore: %v", err)
+	}
+	reopen := func() {
+		if err := Initialize(dataDir); err != nil {
+			log.Printf("❌ Failed to reopen database after failed restore: %v", err)
+		}
+	}
+
+	if err := os.Rename(dbPath, keptPath); err != nil {
+		reopen()
+		return "", fmt.Errorf("failed to move current database aside: %w", err)
+	}
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+
+	if err := os.Rename(backupPath, dbPath); err != nil {
+		os.Rename(keptPath, dbPath)
+		reopen()
+		return "", fmt.Errorf("failed to move backup into place: %w", err)
+	}
+
+	if err := Initialize(dataDir); err != nil {
+		if DB != nil {
+			DB.Close()
+		}
+		os.Remove(dbPath + "-wal")
+		os.Remove(dbPath + "-shm")
+		os.Rename(keptPath, dbPath)
+		reopen()
+		return "", fmt.Errorf("failed to open restored database: %w", err)
+	}
+	return keptPath, nil
+}
+
+// Backup targets of scheduled backups
+const (
+	BackupTargetLocal = "local"
+	BackupTargetS3    = "s3"
+)
+
+// Scheduled backup defaults and limits
+const (
+	DefaultBackupRetentionCount = 7
+	MaxBackupRetentionCount     = 365
+	// backupRunHistory is how many failed runs are kept for the backup page
+	backupRunHistory = 20
+)
+
+// BackupSchedule is the configuration of scheduled backups
+type BackupSchedule struct {
+	IntervalHours  int    // 0 = scheduled backups are off, the default
+	RetentionCount int    // how many successful backups are kept at the target
+	Target         string // BackupTargetLocal or BackupTargetS3
+	Directory      string // local target; "" = the backups directory in the data directory
+	S3Endpoint     string
+	S3Bucket       string
+	S3Region       string
+	S3AccessKey    string
+	S3SecretKey    string // encrypted, as stored
+	S3Prefix       string
+	S3PathStyle    bool
+}
+
+// GetBackupSchedule returns the configuration of scheduled backups
+func (d *Database) GetBackupSchedule() *BackupSchedule {
+	schedule := &BackupSchedule{
+		IntervalHours:  d.GetConfigInt("backup_interval_hours", 0),
+		RetentionCount: d.GetConfigInt("backup_retention_count", DefaultBackupRetentionCount),
+		Target:         BackupTargetLocal,
+	}
+	if schedule.IntervalHours < 0 {
+		schedule.IntervalHours = 0
+	}
+	if schedule.RetentionCount <= 0 {
+		schedule.RetentionCount = DefaultBackupRetentionCount
+	}
+	if target, _ := d.GetConfigValue("backup_target"); target == BackupTargetS3 {
+		schedule.Target = BackupTargetS3
+	}
+	schedule.Directory, _ = d.GetConfigValue("backup_directory")
+	schedule.S3Endpoint, _ = d.GetConfigValue("backup_s3_endpoint")
+	schedule.S3Bucket, _ = d.GetConfigValue("backup_s3_bucket")
+	schedule.S3Region, _ = d.GetConfigValue("backup_s3_region")
+	schedule.S3AccessKey, _ = d.GetConfigValue("backup_s3_access_key")
+	schedule.S3SecretKey, _ = d.GetConfigValue("backup_s3_secret_key")
+	schedule.S3Prefix, _ = d.GetConfigValue("backup_s3_prefix")
+	pathStyle, _ := d.GetConfigValue("backup_s3_path_style")
+	schedule.S3PathStyle = pathStyle == "true"
+	return schedule
+}
+
+// BackupRun is a scheduled or manually started backup to the backup target
+type BackupRun struct {
+	Id        int64
+	Name      string
+	Target    string
+	SizeBytes int64
+	CreatedAt int64
+	Success   bool
+	Error     string
+	Manual    bool
+}
+
+// RecordBackupRun stores the result of a backup
+func (d *Database) RecordBackupRun(run *BackupRun) error {
+	result, err := d.db.Exec(`INSERT INTO backup_runs (name, target, size_bytes, created_at, success, error, manual)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, run.Name, run.Target, run.SizeBytes, run.CreatedAt, run.Success, run.Error, run.Manual)
+	if err != nil {
+		return err
+	}
+	run.Id, _ = result.LastInsertId()
+	return nil
+}
+
+// GetBackupRuns returns the most recent backups, newest first
+func (d *Database) GetBackupRuns(limit int) ([]*BackupRun, error) {
+	return d.queryBackupRuns(`SELECT id, name, target, size_bytes, created_at, success, error, manual
+		FROM backup_runs ORDER BY created_at DESC, id DESC LIMIT ?`, limit)
+}
+
+// GetExpiredBackupRuns returns the successful backups at target beyond the newest keep, which
+// are due to be deleted
+func (d *Database) GetExpiredBackupRuns(target string, keep int) ([]*BackupRun, error) {
+	return d.queryBackupRuns(`SELECT id, name, target, size_bytes, created_at, success, error, manual
+		FROM backup_runs WHERE target = ? AND success = 1
+		ORDER BY created_at DESC, id DESC LIMIT -1 OFFSET ?`, target, keep)
+}
+
+func (d *Database) queryBackupRuns(query string, args ...interface{}) ([]*BackupRun, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*BackupRun
+	for rows.Next() {
+		run := &BackupRun{}
+		if err := rows.Scan(&run.Id, &run.Name, &run.Target, &run.SizeBytes, &run.CreatedAt, &run.Success, &run.Error, &run.Manual); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// DeleteBackupRun removes the record of a backup that was deleted from its target
+func (d *Database) DeleteBackupRun(id int64) error {
+	_, err := d.db.Exec("DELETE FROM backup_runs WHERE id = ?", id)
+	return err
+}
+
+// PruneFailedBackupRuns keeps only the most recent failed backups
+func (d *Database) PruneFailedBackupRuns() error {
+	_, err := d.db.Exec(`DELETE FROM backup_runs WHERE success = 0 AND id NOT IN (
+		SELECT id FROM backup_runs WHERE success = 0 ORDER BY created_at DESC, id DESC LIMIT ?)`, backupRunHistory)
+	return err
+}
@@ -0,0 +1,92 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MaxBulkFileIDs is the most files a single bulk action may change
+const MaxBulkFileIDs = 5000
+
+// BulkFileResult is the outcome of a bulk action for one file
+type BulkFileResult struct {
+	FileID  string `json:"file_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkDeleteFiles soft-deletes files (moves them to trash) in a single transaction, releasing
+// each file's size from its owner's storage. Files that don't exist or are already in trash are
+// reported as failed; the others are deleted.
+func (d *Database) BulkDeleteFiles(fileIds []string, deletedBy int) ([]BulkFileResult, error) {
+	return d.bulkUpdateFiles(fileIds, "already in trash", -1,
+		"UPDATE Files SET DeletedAt = ?, DeletedBy = ?, TrashRetentionDays = 0 WHERE Id = ? AND DeletedAt = 0", time.Now().Unix(), deletedBy)
+}
+
+// BulkRestoreFiles restores files from trash in a single transaction, adding each file's size
+// back to its owner's storage. Files that don't exist or aren't in trash are reported as failed;
+// the others are restored.
+func (d *Database) BulkRestoreFiles(fileIds []string) ([]BulkFileResult, error) {
+	return d.bulkUpdateFiles(fileIds, "not in trash", 1,
+		"UPDATE Files SET DeletedAt = 0, DeletedBy = 0, TrashRetentionDays = 0 WHERE Id = ? AND DeletedAt > 0")
+}
+
+// bulkUpdateFiles runs an update per file and adjusts the owner's storage by sign for each file
+// it changed. The update's parameters are args followed by the file ID.
+func (d *Database) bulkUpdateFiles(fileIds []string, unchangedError string, sign int, query string, args ...interface{}) ([]BulkFileResult, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BulkFileResult, 0, len(fileIds))
+	seen := make(map[string]bool, len(fileIds))
+	for _, fileId := range fileIds {
+		result := BulkFileResult{FileID: fileId}
+		if seen[fileId] {
+			result.Error = "listed more than once"
+			results = append(results, result)
+			continue
+		}
+		seen[fileId] = true
+
+		updated, err := tx.Exec(query, append(args, fileId)...)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := updated.RowsAffected(); n == 0 {
+			var exists int
+			if err := tx.QueryRow("SELECT 1 FROM Files WHERE Id = ?", fileId).Scan(&exists); err == sql.ErrNoRows {
+				result.Error = "file not found"
+			} else if err != nil {
+				return nil, err
+			} else {
+				result.Error = unchangedError
+			}
+			results = append(results, result)
+			continue
+		}
+
+		if err := adjustFileOwnerStorage(tx, fileId, sign); err != nil {
+			return nil, err
+		}
+		if sign > 0 {
+			if err := clearBurnedFile(tx, fileId); err != nil {
+				return nil, err
+			}
+		}
+		result.Success = true
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,104 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// What happens to a burn-after-download file after its download (config burn_after_download_mode)
+const (
+	BurnModeTrash  = "trash"  // moved to trash, so an admin can still restore it
+	BurnModeDelete = "delete" // deleted permanently
+)
+
+// burnClaimTimeout is how long a download may hold a burn-after-download file before the claim
+// is given up, so a server crash in the middle of a download doesn't leave the link unusable
+const burnClaimTimeout = 24 * time.Hour
+
+// BurnedFile is a burn-after-download file that is being downloaded or was downloaded
+type BurnedFile struct {
+	FileId    string
+	FileName  string
+	ClaimedAt int64
+	BurnedAt  int64 // 0 while the download is still running
+}
+
+// GetBurnAfterDownloadMode returns what happens to burn-after-download files after their
+// download, BurnModeTrash or BurnModeDelete
+func (d *Database) GetBurnAfterDownloadMode() string {
+	if mode, _ := d.GetConfigValue("burn_after_download_mode"); mode == BurnModeDelete {
+		return BurnModeDelete
+	}
+	return BurnModeTrash
+}
+
+// SetFileBurnAfterDownload turns burn after download on or off for a file
+func (d *Database) SetFileBurnAfterDownload(fileId string, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET BurnAfterDownload = ? WHERE Id = ?", value, fileId)
+	return err
+}
+
+// ClaimBurnDownload reserves the one download of a burn-after-download file. Only one request
+// can hold the claim, so two simultaneous downloads can't both get the file. Returns false if
+// the file is already being downloaded or was downloaded.
+func (d *Database) ClaimBurnDownload(fileId, fileName string) (bool, error) {
+	now := time.Now().Unix()
+	result, err := d.db.Exec(`
+		INSERT INTO BurnedFiles (FileId, FileName, ClaimedAt) VALUES (?, ?, ?)
+		ON CONFLICT(FileId) DO UPDATE SET ClaimedAt = excluded.ClaimedAt
+		WHERE BurnedAt = 0 AND ClaimedAt < ?`,
+		fileId, fileName, now, now-int64(burnClaimTimeout.Seconds()))
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed > 0, nil
+}
+
+// ReleaseBurnDownload gives up the claim of a download that did not complete, so the file can
+// be downloaded again
+func (d *Database) ReleaseBurnDownload(fileId string) error {
+	_, err := d.db.Exec("DELETE FROM BurnedFiles WHERE FileId = ? AND BurnedAt = 0", fileId)
+	return err
+}
+
+// MarkFileBurned records that the claimed download of a file completed. The record outlives the
+// file, so its link keeps saying it was used.
+func (d *Database) MarkFileBurned(fileId string) error {
+	_, err := d.db.Exec("UPDATE BurnedFiles SET BurnedAt = ? WHERE FileId = ?", time.Now().Unix(), fileId)
+	return err
+}
+
+// GetBurnedFile returns the burn record of a file, or an error if it has none
+func (d *Database) GetBurnedFile(fileId string) (*BurnedFile, error) {
+	burned := &BurnedFile{}
+	err := d.db.QueryRow("SELECT FileId, FileName, ClaimedAt, BurnedAt FROM BurnedFiles WHERE FileId = ?", fileId).Scan(
+		&burned.FileId, &burned.FileName, &burned.ClaimedAt, &burned.BurnedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("file was not burned")
+		}
+		return nil, err
+	}
+	return burned, nil
+}
+
+// clearBurnedFile removes the burn record of a file restored from trash, so it can be
+// downloaded once more
+func clearBurnedFile(tx *sql.Tx, fileId string) error {
+	_, err := tx.Exec("DELETE FROM BurnedFiles WHERE FileId = ?", fileId)
+	return err
+}
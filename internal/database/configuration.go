@@ -8,6 +8,7 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"strconv"
 )
 
 // GetConfigValue gets a configuration value
@@ -31,6 +32,20 @@ func (d *Database) SetConfigValue(key, value string) error {
 	return err
 }
 
+// GetConfigInt gets a configuration value as an integer, falling back to defaultValue
+// when the key is missing or not a valid number
+func (d *Database) GetConfigInt(key string, defaultValue int) int {
+	value, err := d.GetConfigValue(key)
+	if err != nil || value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
 // GetBrandingConfig gets all branding configuration
 func (d *Database) GetBrandingConfig() (map[string]string, error) {
 	rows, err := d.db.Query("SELECT Key, Value FROM Configuration WHERE Key LIKE 'branding_%'")
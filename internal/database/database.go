@@ -16,7 +16,8 @@ import (
 )
 
 type Database struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
 }
 
 var DB *Database
@@ -50,8 +51,9 @@ func Initialize(dataDir string) error {
 	// Database file path
 	dbPath := newDbPath
 
-	// Open SQLite database
-	sqliteDb, err := sql.Open("sqlite", dbPath)
+	// Open SQLite database. Writers wait for each other instead of failing with SQLITE_BUSY,
+	// since background jobs (notifications, cleanup) write while requests are being handled.
+	sqliteDb, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -66,7 +68,7 @@ func Initialize(dataDir string) error {
 		log.Printf("Warning: Could not set WAL mode: %v", err)
 	}
 
-	DB = &Database{db: sqliteDb}
+	DB = &Database{db: sqliteDb, path: dbPath}
 
 	// Create tables
 	if err := DB.createTables(); err != nil {
@@ -0,0 +1,122 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultDownloadBlockMinutes is how long downloads of a file stay locked after unusual
+// activity, when download_anomaly_block_minutes is not configured
+const DefaultDownloadBlockMinutes = 60
+
+// DefaultDownloadCountryHeader is the reverse proxy header read for the downloader's country
+const DefaultDownloadCountryHeader = "CF-IPCountry"
+
+// DownloadAnomalyRules are the thresholds that lock a file's downloads. A threshold of 0 is off.
+type DownloadAnomalyRules struct {
+	MaxPerMinute        int // downloads of the file within one minute
+	MaxCountriesPerHour int // countries the file was downloaded from within one hour
+	BlockMinutes        int
+}
+
+// Enabled reports whether any rule is turned on
+func (r DownloadAnomalyRules) Enabled() bool {
+	return r.MaxPerMinute > 0 || r.MaxCountriesPerHour > 0
+}
+
+// GetDownloadAnomalyRules returns the configured download anomaly thresholds
+func (d *Database) GetDownloadAnomalyRules() DownloadAnomalyRules {
+	rules := DownloadAnomalyRules{
+		MaxPerMinute:        d.GetConfigInt("download_anomaly_max_per_minute", 0),
+		MaxCountriesPerHour: d.GetConfigInt("download_anomaly_max_countries_per_hour", 0),
+		BlockMinutes:        d.GetConfigInt("download_anomaly_block_minutes", DefaultDownloadBlockMinutes),
+	}
+	if rules.BlockMinutes < 1 {
+		rules.BlockMinutes = DefaultDownloadBlockMinutes
+	}
+	return rules
+}
+
+// GetDownloadCountryHeader returns the request header that carries the downloader's country
+func (d *Database) GetDownloadCountryHeader() string {
+	value, _ := d.GetConfigValue("download_country_header")
+	if value == "" {
+		return DefaultDownloadCountryHeader
+	}
+	return value
+}
+
+// DetectDownloadAnomaly checks a file's recent download log against the rules and returns why
+// its downloads should be locked, or "" if nothing unusual happened. Downloads from before the
+// last lock ended are not counted again, so a file isn't locked again right after it is unlocked.
+func (d *Database) DetectDownloadAnomaly(fileId string, rules DownloadAnomalyRules) string {
+	now := time.Now()
+
+	var lastBlockEnd int64
+	d.db.QueryRow("SELECT COALESCE(DownloadsBlockedUntil, 0) FROM Files WHERE Id = ?", fileId).Scan(&lastBlockEnd)
+	since := func(window time.Duration) int64 {
+		start := now.Add(-window).Unix()
+		if lastBlockEnd > start {
+			return lastBlockEnd
+		}
+		return start
+	}
+
+	if rules.MaxPerMinute > 0 {
+		var count int
+		d.db.QueryRow("SELECT COUNT(*) FROM DownloadLogs WHERE FileId = ? AND DownloadedAt >= ?",
+			fileId, since(time.Minute)).Scan(&count)
+		if count > rules.MaxPerMinute {
+			return fmt.Sprintf("%d downloads within one minute (limit %d)", count, rules.MaxPerMinute)
+		}
+	}
+
+	if rules.MaxCountriesPerHour > 0 {
+		var countries int
+		d.db.QueryRow("SELECT COUNT(DISTINCT Country) FROM DownloadLogs WHERE FileId = ? AND DownloadedAt >= ? AND Country != ''",
+			fileId, since(time.Hour)).Scan(&countries)
+		if countries > rules.MaxCountriesPerHour {
+			return fmt.Sprintf("downloads from %d countries within one hour (limit %d)", countries, rules.MaxCountriesPerHour)
+		}
+	}
+
+	return ""
+}
+
+// BlockFileDownloads locks a file's downloads until the given time. Returns false if they were
+// already locked, so callers notify the owner only once.
+func (d *Database) BlockFileDownloads(fileId string, until time.Time, reason string) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE Files SET DownloadsBlockedUntil = ?, DownloadBlockReason = ?
+		WHERE Id = ? AND COALESCE(DownloadsBlockedUntil, 0) <= ?`,
+		until.Unix(), reason, fileId, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// GetFileDownloadBlock returns until when a file's downloads are locked and why, or the zero
+// time if they are not locked
+func (d *Database) GetFileDownloadBlock(fileId string) (time.Time, string) {
+	var until int64
+	var reason string
+	err := d.db.QueryRow("SELECT COALESCE(DownloadsBlockedUntil, 0), COALESCE(DownloadBlockReason, '') FROM Files WHERE Id = ?", fileId).Scan(&until, &reason)
+	if err != nil || until <= time.Now().Unix() {
+		return time.Time{}, ""
+	}
+	return time.Unix(until, 0), reason
+}
+
+// UnblockFileDownloads lifts a file's download lock before it runs out. The lock ends now
+// rather than being cleared, so the downloads that caused it don't count again.
+func (d *Database) UnblockFileDownloads(fileId string) error {
+	_, err := d.db.Exec("UPDATE Files SET DownloadsBlockedUntil = ?, DownloadBlockReason = '' WHERE Id = ?", time.Now().Unix(), fileId)
+	return err
+}
@@ -0,0 +1,36 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import "errors"
+
+// MaxDownloadLimitReset is the most downloads a file's limit can be reset or topped up to
+const MaxDownloadLimitReset = 100000
+
+// ErrUnlimitedDownloads is returned when the download limit of a file without one is reset
+var ErrUnlimitedDownloads = errors.New("file has no download limit")
+
+// ResetFileDownloadsRemaining sets a file's remaining downloads to value, or adds value to them
+// when topUp is set, and returns the new value. The change is a single statement, so downloads
+// claimed at the same time are not lost; a counter at or below zero is topped up from zero, and
+// ClaimFileDownload serves the file again as soon as the counter is positive.
+func (d *Database) ResetFileDownloadsRemaining(fileId string, value int, topUp bool) (int, error) {
+	query := "UPDATE Files SET DownloadsRemaining = MIN(?, ?) WHERE Id = ? AND UnlimitedDownloads = 0"
+	if topUp {
+		query = "UPDATE Files SET DownloadsRemaining = MIN(MAX(DownloadsRemaining, 0) + ?, ?) WHERE Id = ? AND UnlimitedDownloads = 0"
+	}
+	result, err := d.db.Exec(query, value, MaxDownloadLimitReset, fileId)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, ErrUnlimitedDownloads
+	}
+
+	var remaining int
+	err = d.db.QueryRow("SELECT DownloadsRemaining FROM Files WHERE Id = ?", fileId).Scan(&remaining)
+	return remaining, err
+}
@@ -0,0 +1,180 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// Download notification throttling defaults (config download_notify_limit and
+// download_digest_interval_minutes)
+const (
+	DefaultDownloadNotifyLimit           = 5
+	DefaultDownloadDigestIntervalMinutes = 60
+)
+
+// GetDownloadNotifyLimit returns how many download emails a file's owner gets per digest
+// interval before further downloads are batched into a digest (0 = never batch)
+func (d *Database) GetDownloadNotifyLimit() int {
+	limit := d.GetConfigInt("download_notify_limit", DefaultDownloadNotifyLimit)
+	if limit < 0 {
+		return DefaultDownloadNotifyLimit
+	}
+	return limit
+}
+
+// GetDownloadDigestInterval returns how often batched downloads are sent as a digest
+func (d *Database) GetDownloadDigestInterval() time.Duration {
+	minutes := d.GetConfigInt("download_digest_interval_minutes", DefaultDownloadDigestIntervalMinutes)
+	if minutes < 5 {
+		minutes = DefaultDownloadDigestIntervalMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// IsFileNotifyOnDownload reports whether a file's owner is emailed when it is downloaded
+func (d *Database) IsFileNotifyOnDownload(fileId string) bool {
+	notify := 1
+	d.db.QueryRow("SELECT COALESCE(NotifyOnDownload, 1) FROM Files WHERE Id = ?", fileId).Scan(&notify)
+	return notify == 1
+}
+
+// SetFileNotifyOnDownload turns download emails to the file's owner on or off
+func (d *Database) SetFileNotifyOnDownload(fileId string, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET NotifyOnDownload = ? WHERE Id = ?", value, fileId)
+	return err
+}
+
+// ClaimDownloadNotification decides whether the download with the given log ID is emailed to
+// the file's owner right away. Each file gets up to limit emails per interval; once that is
+// reached, or while earlier downloads wait for a digest, the download is left for the next
+// digest instead. A limit of 0 emails every download.
+func (d *Database) ClaimDownloadNotification(fileId string, downloadLogId int, limit int, interval time.Duration) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Write first, so the transaction holds the write lock before it reads
+	if _, err := tx.Exec("UPDATE Files SET DownloadNoticeCount = COALESCE(DownloadNoticeCount, 0) WHERE Id = ?", fileId); err != nil {
+		return false, err
+	}
+
+	var windowStart int64
+	var count, notifiedLogId int
+	if err := tx.QueryRow(`
+		SELECT COALESCE(DownloadNoticeWindowStart, 0), COALESCE(DownloadNoticeCount, 0), COALESCE(DownloadNotifiedLogId, 0)
+		FROM Files WHERE Id = ?`, fileId).Scan(&windowStart, &count, &notifiedLogId); err != nil {
+		return false, err
+	}
+
+	now := time.Now().Unix()
+	if now-windowStart >= int64(interval.Seconds()) {
+		windowStart, count = now, 0
+	}
+
+	var pending int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM DownloadLogs WHERE FileId = ? AND Id > ? AND Id < ?",
+		fileId, notifiedLogId, downloadLogId).Scan(&pending); err != nil {
+		return false, err
+	}
+	if limit > 0 && (count >= limit || pending > 0) {
+		// Keep the window, so the digest goes out when it ends
+		if _, err := tx.Exec("UPDATE Files SET DownloadNoticeWindowStart = ?, DownloadNoticeCount = ? WHERE Id = ?",
+			windowStart, count, fileId); err != nil {
+			return false, err
+		}
+		return false, tx.Commit()
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE Files SET DownloadNoticeWindowStart = ?, DownloadNoticeCount = ?, DownloadNotifiedLogId = MAX(COALESCE(DownloadNotifiedLogId, 0), ?)
+		WHERE Id = ?`, windowStart, count+1, downloadLogId, fileId); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DownloadDigest is a file whose downloads were batched for a digest email to its owner
+type DownloadDigest struct {
+	File      *FileInfo
+	Downloads []*models.DownloadLog // oldest first
+}
+
+// GetDueDownloadDigests returns files with batched downloads whose digest interval has ended
+func (d *Database) GetDueDownloadDigests(interval time.Duration) ([]*DownloadDigest, error) {
+	rows, err := d.db.Query(`
+		SELECT Id FROM Files
+		WHERE DeletedAt = 0 AND COALESCE(NotifyOnDownload, 1) = 1 AND COALESCE(DownloadNoticeWindowStart, 0) <= ?
+		  AND EXISTS (SELECT 1 FROM DownloadLogs WHERE DownloadLogs.FileId = Files.Id AND DownloadLogs.Id > COALESCE(Files.DownloadNotifiedLogId, 0))`,
+		time.Now().Add(-interval).Unix())
+	if err != nil {
+		return nil, err
+	}
+	var fileIds []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		fileIds = append(fileIds, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var digests []*DownloadDigest
+	for _, id := range fileIds {
+		file, err := d.GetFileByID(id)
+		if err != nil {
+			continue
+		}
+		downloads, err := d.getDownloadsAwaitingNotification(id)
+		if err != nil {
+			return nil, err
+		}
+		if len(downloads) > 0 {
+			digests = append(digests, &DownloadDigest{File: file, Downloads: downloads})
+		}
+	}
+	return digests, nil
+}
+
+func (d *Database) getDownloadsAwaitingNotification(fileId string) ([]*models.DownloadLog, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, FileId, DownloadAccountId, Email, IpAddress, UserAgent,
+		       DownloadedAt, FileSize, FileName, IsAuthenticated,
+		       COALESCE(TermsVersion, 0), COALESCE(TermsAcceptedAt, 0),
+		       COALESCE(NoticeAcceptedAt, 0), COALESCE(NoticeAcceptedIP, '')
+		FROM DownloadLogs
+		WHERE FileId = ? AND Id > (SELECT COALESCE(DownloadNotifiedLogId, 0) FROM Files WHERE Id = ?)
+		ORDER BY Id`, fileId, fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDownloadLogs(rows)
+}
+
+// MarkDownloadDigestSent records that a file's downloads up to lastLogId were sent as a digest.
+// A new interval starts with the limit already reached, so a file that keeps being downloaded
+// gets one digest per interval; once it gets quiet, downloads are emailed one by one again.
+func (d *Database) MarkDownloadDigestSent(fileId string, lastLogId int, limit int) error {
+	_, err := d.db.Exec(`
+		UPDATE Files SET DownloadNotifiedLogId = MAX(COALESCE(DownloadNotifiedLogId, 0), ?),
+		       DownloadNoticeWindowStart = ?, DownloadNoticeCount = ?
+		WHERE Id = ?`, lastLogId, time.Now().Unix(), limit, fileId)
+	return err
+}
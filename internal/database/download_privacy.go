@@ -0,0 +1,42 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// IsDownloadLogDetailsDisabled reports whether downloader IPs and user agents are kept out of
+// the download log for every file, regardless of the per-file setting
+func (d *Database) IsDownloadLogDetailsDisabled() bool {
+	value, _ := d.GetConfigValue("download_log_details")
+	return value == "false"
+}
+
+// GetPrivateDownloadLogDefault reports whether new files start with detailed download logging off
+func (d *Database) GetPrivateDownloadLogDefault() bool {
+	value, _ := d.GetConfigValue("private_download_log_default")
+	return value == "true"
+}
+
+// IsFilePrivateDownloadLog reports whether the file's owner turned off detailed download logging
+func (d *Database) IsFilePrivateDownloadLog(fileId string) bool {
+	var private int
+	err := d.db.QueryRow("SELECT COALESCE(PrivateDownloadLog, 0) FROM Files WHERE Id = ?", fileId).Scan(&private)
+	return err == nil && private == 1
+}
+
+// SetFilePrivateDownloadLog turns detailed download logging for a file off (true) or on (false)
+func (d *Database) SetFilePrivateDownloadLog(fileId string, private bool) error {
+	value := 0
+	if private {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET PrivateDownloadLog = ? WHERE Id = ?", value, fileId)
+	return err
+}
+
+// IsDownloadLogPrivate reports whether downloads of a file record only counts and timestamps,
+// either because of the file's own setting or the global one
+func (d *Database) IsDownloadLogPrivate(fileId string) bool {
+	return d.IsDownloadLogDetailsDisabled() || d.IsFilePrivateDownloadLog(fileId)
+}
@@ -0,0 +1,46 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import "time"
+
+// DefaultDownloadRetryWindowMinutes is how long a recipient may retry a download without it
+// counting again, unless download_retry_window_minutes is configured
+const DefaultDownloadRetryWindowMinutes = 10
+
+// MaxDownloadRetryWindowMinutes caps the retry window so a retry can't turn into unlimited downloads
+const MaxDownloadRetryWindowMinutes = 24 * 60
+
+// GetDownloadRetryWindow returns how long after a counted download the same browser may download
+// the file again without using up another download (0 = retries are always counted)
+func (d *Database) GetDownloadRetryWindow() time.Duration {
+	minutes := d.GetConfigInt("download_retry_window_minutes", DefaultDownloadRetryWindowMinutes)
+	if minutes <= 0 {
+		return 0
+	}
+	if minutes > MaxDownloadRetryWindowMinutes {
+		minutes = MaxDownloadRetryWindowMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// IsFileDownloadRetryEnabled reports whether a recipient whose download of the file broke off
+// may retry it without using up another download
+func (d *Database) IsFileDownloadRetryEnabled(fileId string) bool {
+	var enabled int
+	err := d.db.QueryRow("SELECT COALESCE(DownloadRetryEnabled, 0) FROM Files WHERE Id = ?", fileId).Scan(&enabled)
+	return err == nil && enabled == 1
+}
+
+// SetFileDownloadRetryEnabled turns free download retries for a file on or off
+func (d *Database) SetFileDownloadRetryEnabled(fileId string, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET DownloadRetryEnabled = ? WHERE Id = ?", value, fileId)
+	return err
+}
@@ -0,0 +1,112 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DownloadTerms is one published version of the terms recipients accept before downloading.
+// Versions are never edited, so a recorded acceptance always refers to the text that was shown.
+type DownloadTerms struct {
+	Version     int    `json:"version"`
+	Content     string `json:"content"`
+	CreatedAt   int64  `json:"createdAt"`
+	CreatedBy   string `json:"createdBy"`
+	Acceptances int    `json:"acceptances"`
+}
+
+// GetCurrentDownloadTerms returns the latest terms version, or nil if none has been published
+func (d *Database) GetCurrentDownloadTerms() (*DownloadTerms, error) {
+	terms := &DownloadTerms{}
+	err := d.db.QueryRow(`
+		SELECT Version, Content, CreatedAt, COALESCE(CreatedBy, '')
+		FROM DownloadTerms ORDER BY Version DESC LIMIT 1`,
+	).Scan(&terms.Version, &terms.Content, &terms.CreatedAt, &terms.CreatedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// GetDownloadTermsVersions returns all published terms, newest first, with how many downloads accepted each
+func (d *Database) GetDownloadTermsVersions() ([]*DownloadTerms, error) {
+	rows, err := d.db.Query(`
+		SELECT t.Version, t.Content, t.CreatedAt, COALESCE(t.CreatedBy, ''),
+		       (SELECT COUNT(*) FROM DownloadLogs l WHERE l.TermsVersion = t.Version)
+		FROM DownloadTerms t ORDER BY t.Version DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*DownloadTerms
+	for rows.Next() {
+		terms := &DownloadTerms{}
+		if err := rows.Scan(&terms.Version, &terms.Content, &terms.CreatedAt, &terms.CreatedBy, &terms.Acceptances); err != nil {
+			return nil, err
+		}
+		versions = append(versions, terms)
+	}
+	return versions, rows.Err()
+}
+
+// PublishDownloadTerms stores the text as a new terms version and returns it.
+// Publishing the current text again does not create a new version.
+func (d *Database) PublishDownloadTerms(content, createdBy string) (*DownloadTerms, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("terms text cannot be empty")
+	}
+
+	current, err := d.GetCurrentDownloadTerms()
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.Content == content {
+		return current, nil
+	}
+
+	terms := &DownloadTerms{
+		Version:   1,
+		Content:   content,
+		CreatedAt: time.Now().Unix(),
+		CreatedBy: createdBy,
+	}
+	if current != nil {
+		terms.Version = current.Version + 1
+	}
+
+	_, err = d.db.Exec("INSERT INTO DownloadTerms (Version, Content, CreatedAt, CreatedBy) VALUES (?, ?, ?, ?)",
+		terms.Version, terms.Content, terms.CreatedAt, terms.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// IsFileTermsRequired reports whether recipients must accept the download terms before downloading a file
+func (d *Database) IsFileTermsRequired(fileId string) bool {
+	var required int
+	err := d.db.QueryRow("SELECT COALESCE(RequireTerms, 0) FROM Files WHERE Id = ?", fileId).Scan(&required)
+	return err == nil && required == 1
+}
+
+// SetFileTermsRequired turns the download terms requirement for a file on or off
+func (d *Database) SetFileTermsRequired(fileId string, required bool) error {
+	value := 0
+	if required {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET RequireTerms = ? WHERE Id = ?", value, fileId)
+	return err
+}
@@ -35,9 +35,9 @@ func (d *Database) CreateDownloadAccount(account *models.DownloadAccount) error
 	}
 
 	result, err := d.db.Exec(`
-		INSERT INTO DownloadAccounts (Name, Email, Password, CreatedAt, LastUsed, DownloadCount, IsActive)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		account.Name, account.Email, account.Password, account.CreatedAt, account.LastUsed, account.DownloadCount, isActive,
+		INSERT INTO DownloadAccounts (Name, Email, Password, CreatedAt, LastUsed, DownloadCount, IsActive, CreatedBy)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		account.Name, account.Email, account.Password, account.CreatedAt, account.LastUsed, account.DownloadCount, isActive, account.CreatedBy,
 	)
 	if err != nil {
 		return err
@@ -119,14 +119,67 @@ func (d *Database) UpdateDownloadAccount(account *models.DownloadAccount) error
 		isActive = 0
 	}
 
+	// Reactivating an account restarts its idle clock so it isn't deactivated again right away
 	_, err := d.db.Exec(`
-		UPDATE DownloadAccounts SET Email = ?, Password = ?, LastUsed = ?, DownloadCount = ?, IsActive = ?
+		UPDATE DownloadAccounts SET Email = ?, Password = ?, LastUsed = ?, DownloadCount = ?,
+			ReactivatedAt = CASE WHEN IsActive = 0 AND ? = 1 THEN ? ELSE ReactivatedAt END,
+			IsActive = ?
 		WHERE Id = ?`,
-		account.Email, account.Password, account.LastUsed, account.DownloadCount, isActive, account.Id,
+		account.Email, account.Password, account.LastUsed, account.DownloadCount,
+		isActive, time.Now().Unix(), isActive, account.Id,
 	)
 	return err
 }
 
+// UpdateDownloadAccountPassword updates a download account's password
+func (d *Database) UpdateDownloadAccountPassword(id int, hashedPassword string) error {
+	_, err := d.db.Exec("UPDATE DownloadAccounts SET Password = ? WHERE Id = ?", hashedPassword, id)
+	return err
+}
+
+// DefaultDownloadAccountGraceDays is used when download_account_grace_days is not configured
+const DefaultDownloadAccountGraceDays = 30
+
+// GetIdleDownloadAccounts returns active download accounts that should be deactivated for inactivity.
+// Accounts that have been used are idle once their last activity is older than idleDays; accounts that
+// were never used get graceDays from creation (or reactivation) before they count as idle.
+func (d *Database) GetIdleDownloadAccounts(idleDays, graceDays int) ([]*models.DownloadAccount, error) {
+	now := time.Now().Unix()
+	idleCutoff := now - int64(idleDays)*24*60*60
+	graceCutoff := now - int64(graceDays)*24*60*60
+
+	rows, err := d.db.Query(`
+		SELECT Id, Name, Email, CreatedAt, LastUsed, DownloadCount, COALESCE(CreatedBy, 0)
+		FROM DownloadAccounts
+		WHERE IsActive = 1 AND COALESCE(DeletedAt, 0) = 0
+		  AND CreatedAt < ?
+		  AND MAX(LastUsed, COALESCE(ReactivatedAt, 0), CreatedAt) < CASE WHEN LastUsed = 0 THEN ? ELSE ? END
+		ORDER BY CreatedBy, Email`,
+		graceCutoff, graceCutoff, idleCutoff,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*models.DownloadAccount
+	for rows.Next() {
+		account := &models.DownloadAccount{IsActive: true}
+		if err := rows.Scan(&account.Id, &account.Name, &account.Email, &account.CreatedAt,
+			&account.LastUsed, &account.DownloadCount, &account.CreatedBy); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// DeactivateDownloadAccount disables a download account without deleting it
+func (d *Database) DeactivateDownloadAccount(id int) error {
+	_, err := d.db.Exec("UPDATE DownloadAccounts SET IsActive = 0 WHERE Id = ?", id)
+	return err
+}
+
 // UpdateDownloadAccountLastUsed updates the last used timestamp and increments download count
 func (d *Database) UpdateDownloadAccountLastUsed(id int) error {
 	_, err := d.db.Exec(`
@@ -305,10 +358,14 @@ func (d *Database) CreateDownloadLog(log *models.DownloadLog) error {
 
 	result, err := d.db.Exec(`
 		INSERT INTO DownloadLogs (FileId, DownloadAccountId, Email, IpAddress, UserAgent,
-		                          DownloadedAt, FileSize, FileName, IsAuthenticated)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		                          DownloadedAt, FileSize, FileName, IsAuthenticated,
+		                          TermsVersion, TermsAcceptedAt, Country,
+		                          NoticeAcceptedAt, NoticeAcceptedIP)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		log.FileId, downloadAccountId, log.Email, log.IpAddress, log.UserAgent,
 		log.DownloadedAt, log.FileSize, log.FileName, isAuth,
+		log.TermsVersion, log.TermsAcceptedAt, log.Country,
+		log.NoticeAcceptedAt, log.NoticeAcceptedIP,
 	)
 	if err != nil {
 		return err
@@ -322,11 +379,48 @@ func (d *Database) CreateDownloadLog(log *models.DownloadLog) error {
 	return nil
 }
 
+// RecordBytesServed stores the bytes actually sent for a download and adds them to the file's total
+func (d *Database) RecordBytesServed(downloadLogId int, fileId string, bytesSent int64) error {
+	if downloadLogId > 0 {
+		if _, err := d.db.Exec("UPDATE DownloadLogs SET BytesSent = ? WHERE Id = ?", bytesSent, downloadLogId); err != nil {
+			return err
+		}
+	}
+	_, err := d.db.Exec("UPDATE Files SET BytesServed = BytesServed + ? WHERE Id = ?", bytesSent, fileId)
+	return err
+}
+
+// AddBytesServed adds the bytes sent for a further byte range of a download to its log and to
+// the file's total
+func (d *Database) AddBytesServed(downloadLogId int, fileId string, bytesSent int64) error {
+	if downloadLogId > 0 {
+		if _, err := d.db.Exec("UPDATE DownloadLogs SET BytesSent = COALESCE(BytesSent, 0) + ? WHERE Id = ?", bytesSent, downloadLogId); err != nil {
+			return err
+		}
+	}
+	_, err := d.db.Exec("UPDATE Files SET BytesServed = BytesServed + ? WHERE Id = ?", bytesSent, fileId)
+	return err
+}
+
+// GetBytesSentByUser returns total bytes served for files owned by a user
+func (d *Database) GetBytesSentByUser(userId int) (int64, error) {
+	var total int64
+	err := d.db.QueryRow(`
+		SELECT COALESCE(SUM(COALESCE(DownloadLogs.BytesSent, DownloadLogs.FileSize)), 0)
+		FROM DownloadLogs
+		JOIN Files ON DownloadLogs.FileId = Files.Id
+		WHERE Files.UserId = ?
+	`, userId).Scan(&total)
+	return total, err
+}
+
 // GetDownloadLogsByFileID retrieves all download logs for a specific file
 func (d *Database) GetDownloadLogsByFileID(fileId string) ([]*models.DownloadLog, error) {
 	rows, err := d.db.Query(`
 		SELECT Id, FileId, DownloadAccountId, Email, IpAddress, UserAgent,
-		       DownloadedAt, FileSize, FileName, IsAuthenticated
+		       DownloadedAt, FileSize, FileName, IsAuthenticated,
+		       COALESCE(TermsVersion, 0), COALESCE(TermsAcceptedAt, 0),
+		       COALESCE(NoticeAcceptedAt, 0), COALESCE(NoticeAcceptedIP, '')
 		FROM DownloadLogs WHERE FileId = ? ORDER BY DownloadedAt DESC`, fileId)
 	if err != nil {
 		return nil, err
@@ -336,11 +430,39 @@ func (d *Database) GetDownloadLogsByFileID(fileId string) ([]*models.DownloadLog
 	return scanDownloadLogs(rows)
 }
 
+// GetDownloadLogsByFileIDPage retrieves one page of a file's download logs and the number of
+// logs within the page's date range
+func (d *Database) GetDownloadLogsByFileIDPage(fileId string, page LogPage) ([]*models.DownloadLog, int, error) {
+	dateClause, dateArgs := page.where("DownloadedAt")
+	args := append([]interface{}{fileId}, dateArgs...)
+
+	var total int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM DownloadLogs WHERE FileId = ?"+dateClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT Id, FileId, DownloadAccountId, Email, IpAddress, UserAgent,
+		       DownloadedAt, FileSize, FileName, IsAuthenticated,
+		       COALESCE(TermsVersion, 0), COALESCE(TermsAcceptedAt, 0),
+		       COALESCE(NoticeAcceptedAt, 0), COALESCE(NoticeAcceptedIP, '')
+		FROM DownloadLogs WHERE FileId = ?`+dateClause+` ORDER BY DownloadedAt DESC, Id DESC`+page.limit(), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs, err := scanDownloadLogs(rows)
+	return logs, total, err
+}
+
 // GetDownloadLogsByAccountID retrieves all download logs for a specific download account
 func (d *Database) GetDownloadLogsByAccountID(accountId int) ([]*models.DownloadLog, error) {
 	rows, err := d.db.Query(`
 		SELECT Id, FileId, DownloadAccountId, Email, IpAddress, UserAgent,
-		       DownloadedAt, FileSize, FileName, IsAuthenticated
+		       DownloadedAt, FileSize, FileName, IsAuthenticated,
+		       COALESCE(TermsVersion, 0), COALESCE(TermsAcceptedAt, 0),
+		       COALESCE(NoticeAcceptedAt, 0), COALESCE(NoticeAcceptedIP, '')
 		FROM DownloadLogs WHERE DownloadAccountId = ? ORDER BY DownloadedAt DESC`, accountId)
 	if err != nil {
 		return nil, err
@@ -363,7 +485,7 @@ func (d *Database) GetAccessibleFilesByDownloadAccount(accountId int) ([]*FileIn
 		WHERE dl.DownloadAccountId = ?
 		  AND f.DeletedAt = 0
 		  AND (f.UnlimitedDownloads = 1 OR f.DownloadsRemaining > 0)
-		  AND (f.UnlimitedTime = 1 OR f.ExpireAt > ?)
+		  AND (f.UnlimitedTime = 1 OR f.ExpireAt = 0 OR f.ExpireAt >= ?)
 		ORDER BY dl.DownloadedAt DESC`
 
 	rows, err := d.db.Query(query, accountId, time.Now().Unix())
@@ -412,7 +534,9 @@ func (d *Database) GetAccessibleFilesByDownloadAccount(accountId int) ([]*FileIn
 func (d *Database) GetAllDownloadLogs(limit int) ([]*models.DownloadLog, error) {
 	query := `
 		SELECT Id, FileId, DownloadAccountId, Email, IpAddress, UserAgent,
-		       DownloadedAt, FileSize, FileName, IsAuthenticated
+		       DownloadedAt, FileSize, FileName, IsAuthenticated,
+		       COALESCE(TermsVersion, 0), COALESCE(TermsAcceptedAt, 0),
+		       COALESCE(NoticeAcceptedAt, 0), COALESCE(NoticeAcceptedIP, '')
 		FROM DownloadLogs ORDER BY DownloadedAt DESC`
 
 	if limit > 0 {
@@ -443,7 +567,9 @@ func scanDownloadLogs(rows *sql.Rows) ([]*models.DownloadLog, error) {
 		var isAuth int
 
 		err := rows.Scan(&log.Id, &log.FileId, &accountId, &log.Email, &log.IpAddress,
-			&log.UserAgent, &log.DownloadedAt, &log.FileSize, &log.FileName, &isAuth)
+			&log.UserAgent, &log.DownloadedAt, &log.FileSize, &log.FileName, &isAuth,
+			&log.TermsVersion, &log.TermsAcceptedAt,
+			&log.NoticeAcceptedAt, &log.NoticeAcceptedIP)
 		if err != nil {
 			return nil, err
 		}
@@ -496,22 +622,21 @@ func (d *Database) DeleteDownloadAccount(id int) error {
 	return err
 }
 
-// GetBytesSentToday returns total bytes transferred today (includes deleted files for historical accuracy)
+// GetBytesSentToday returns total bytes actually served today, including partial downloads (logs from before byte tracking count the full file size)
 func (d *Database) GetBytesSentToday() (int64, error) {
 	now := time.Now()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
 
 	var total int64
 	err := d.db.QueryRow(`
-		SELECT COALESCE(SUM(Files.SizeBytes), 0)
+		SELECT COALESCE(SUM(COALESCE(BytesSent, FileSize)), 0)
 		FROM DownloadLogs
-		JOIN Files ON DownloadLogs.FileId = Files.Id
-		WHERE DownloadLogs.DownloadedAt >= ?
+		WHERE DownloadedAt >= ?
 	`, startOfDay).Scan(&total)
 	return total, err
 }
 
-// GetBytesSentThisWeek returns total bytes transferred this week (includes deleted files for historical accuracy)
+// GetBytesSentThisWeek returns total bytes actually served this week, including partial downloads (logs from before byte tracking count the full file size)
 func (d *Database) GetBytesSentThisWeek() (int64, error) {
 	now := time.Now()
 	weekday := int(now.Weekday())
@@ -523,40 +648,37 @@ func (d *Database) GetBytesSentThisWeek() (int64, error) {
 
 	var total int64
 	err := d.db.QueryRow(`
-		SELECT COALESCE(SUM(Files.SizeBytes), 0)
+		SELECT COALESCE(SUM(COALESCE(BytesSent, FileSize)), 0)
 		FROM DownloadLogs
-		JOIN Files ON DownloadLogs.FileId = Files.Id
-		WHERE DownloadLogs.DownloadedAt >= ?
+		WHERE DownloadedAt >= ?
 	`, startOfWeek.Unix()).Scan(&total)
 	return total, err
 }
 
-// GetBytesSentThisMonth returns total bytes transferred this month (includes deleted files for historical accuracy)
+// GetBytesSentThisMonth returns total bytes actually served this month, including partial downloads (logs from before byte tracking count the full file size)
 func (d *Database) GetBytesSentThisMonth() (int64, error) {
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Unix()
 
 	var total int64
 	err := d.db.QueryRow(`
-		SELECT COALESCE(SUM(Files.SizeBytes), 0)
+		SELECT COALESCE(SUM(COALESCE(BytesSent, FileSize)), 0)
 		FROM DownloadLogs
-		JOIN Files ON DownloadLogs.FileId = Files.Id
-		WHERE DownloadLogs.DownloadedAt >= ?
+		WHERE DownloadedAt >= ?
 	`, startOfMonth).Scan(&total)
 	return total, err
 }
 
-// GetBytesSentThisYear returns total bytes transferred this year (includes deleted files for historical accuracy)
+// GetBytesSentThisYear returns total bytes actually served this year, including partial downloads (logs from before byte tracking count the full file size)
 func (d *Database) GetBytesSentThisYear() (int64, error) {
 	now := time.Now()
 	startOfYear := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()).Unix()
 
 	var total int64
 	err := d.db.QueryRow(`
-		SELECT COALESCE(SUM(Files.SizeBytes), 0)
+		SELECT COALESCE(SUM(COALESCE(BytesSent, FileSize)), 0)
 		FROM DownloadLogs
-		JOIN Files ON DownloadLogs.FileId = Files.Id
-		WHERE DownloadLogs.DownloadedAt >= ?
+		WHERE DownloadedAt >= ?
 	`, startOfYear).Scan(&total)
 	return total, err
 }
@@ -761,47 +883,6 @@ func (d *Database) GetTop5ActiveUsers() ([]string, []int, error) {
 // TREND DATA
 // ============================================================================
 
-// GetTopFileTypes returns the top 3 most common file types (by extension)
-func (d *Database) GetTopFileTypes() ([]string, []int, error) {
-	rows, err := d.db.Query(`
-		SELECT
-			CASE
-				WHEN INSTR(Name, '.') > 0
-				THEN SUBSTR(Name, INSTR(Name, '.') + 1)
-				ELSE 'no extension'
-			END as extension,
-			COUNT(*) as count
-		FROM Files
-		WHERE DeletedAt = 0
-		GROUP BY extension
-		ORDER BY count DESC
-		LIMIT 3
-	`)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer rows.Close()
-
-	var extensions []string
-	var counts []int
-
-	for rows.Next() {
-		var ext string
-		var count int
-		if err := rows.Scan(&ext, &count); err != nil {
-			return nil, nil, err
-		}
-		extensions = append(extensions, ext)
-		counts = append(counts, count)
-	}
-
-	if len(extensions) == 0 {
-		return []string{"N/A"}, []int{0}, nil
-	}
-
-	return extensions, counts, rows.Err()
-}
-
 // GetMostActiveWeekday returns the weekday with the most downloads
 func (d *Database) GetMostActiveWeekday() (string, int, error) {
 	// SQLite doesn't have built-in day name function, so we'll get day number and convert
@@ -0,0 +1,164 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultEmailChangeExpiryHours is used when email_change_expiry_hours is not configured
+const DefaultEmailChangeExpiryHours = 24
+
+// EmailChangeRequest represents a pending self-service email change
+type EmailChangeRequest struct {
+	Id        int
+	Token     string
+	UserId    int
+	OldEmail  string
+	NewEmail  string
+	ExpiresAt int64
+	Used      bool
+	CreatedAt int64
+}
+
+// GetEmailChangeExpiry returns how long email change verification links are valid
+func (d *Database) GetEmailChangeExpiry() time.Duration {
+	hours := d.GetConfigInt("email_change_expiry_hours", DefaultEmailChangeExpiryHours)
+	if hours <= 0 {
+		hours = DefaultEmailChangeExpiryHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// IsEmailInUse checks if an email address belongs to any user or download account
+func (d *Database) IsEmailInUse(email string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM Users WHERE LOWER(Email) = LOWER(?)) +
+		       (SELECT COUNT(*) FROM DownloadAccounts WHERE LOWER(Email) = LOWER(?))`,
+		email, email,
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateEmailChangeRequest stores a pending email change and returns its verification token.
+// Any earlier pending request for the same user is invalidated.
+func (d *Database) CreateEmailChangeRequest(userId int, oldEmail, newEmail string) (*EmailChangeRequest, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	request := &EmailChangeRequest{
+		Token:     hex.EncodeToString(tokenBytes),
+		UserId:    userId,
+		OldEmail:  oldEmail,
+		NewEmail:  strings.TrimSpace(newEmail),
+		ExpiresAt: now.Add(d.GetEmailChangeExpiry()).Unix(),
+		CreatedAt: now.Unix(),
+	}
+
+	if _, err := d.db.Exec("UPDATE EmailChangeRequests SET Used = 1 WHERE UserId = ? AND Used = 0", userId); err != nil {
+		return nil, err
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO EmailChangeRequests (Token, UserId, OldEmail, NewEmail, ExpiresAt, CreatedAt)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		request.Token, request.UserId, request.OldEmail, request.NewEmail, request.ExpiresAt, request.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	request.Id = int(id)
+	return request, nil
+}
+
+// GetEmailChangeRequest retrieves a valid (unused, unexpired) email change request by token
+func (d *Database) GetEmailChangeRequest(token string) (*EmailChangeRequest, error) {
+	var request EmailChangeRequest
+	var used int
+
+	err := d.db.QueryRow(`
+		SELECT Id, Token, UserId, OldEmail, NewEmail, ExpiresAt, Used, CreatedAt
+		FROM EmailChangeRequests
+		WHERE Token = ?`,
+		token,
+	).Scan(&request.Id, &request.Token, &request.UserId, &request.OldEmail, &request.NewEmail,
+		&request.ExpiresAt, &used, &request.CreatedAt)
+	if err != nil {
+		return nil, errors.New("token not found")
+	}
+
+	request.Used = used == 1
+
+	if request.Used {
+		return nil, errors.New("token already used")
+	}
+	if time.Now().Unix() > request.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+
+	return &request, nil
+}
+
+// ConfirmEmailChange applies a pending email change and marks the token as used
+func (d *Database) ConfirmEmailChange(token string) (*EmailChangeRequest, error) {
+	request, err := d.GetEmailChangeRequest(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-check in case the address was taken after the request was made
+	inUse, err := d.IsEmailInUse(request.NewEmail)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, errors.New("email address is already in use")
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE Users SET Email = ? WHERE Id = ?", request.NewEmail, request.UserId); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE EmailChangeRequests SET Used = 1 WHERE Token = ?", token); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// CleanupExpiredEmailChangeRequests removes expired and used email change requests
+func (d *Database) CleanupExpiredEmailChangeRequests() error {
+	_, err := d.db.Exec(`
+		DELETE FROM EmailChangeRequests
+		WHERE ExpiresAt < ? OR Used = 1`,
+		time.Now().Unix(),
+	)
+	return err
+}
@@ -0,0 +1,111 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// EmailProviderStatus is a configured email provider's place in the delivery order and its
+// delivery counts. Emails are sent through the enabled (IsActive) providers in Priority order,
+// falling back to the next one when a provider fails.
+type EmailProviderStatus struct {
+	Provider    string
+	IsActive    bool
+	Priority    int
+	SentCount   int
+	FailedCount int
+	LastSentAt  int64
+	LastError   string
+	LastErrorAt int64
+}
+
+// GetEmailProviderStatuses returns the configured email providers in delivery order, enabled
+// providers first
+func (d *Database) GetEmailProviderStatuses() ([]*EmailProviderStatus, error) {
+	rows, err := d.db.Query(`
+		SELECT Provider, IsActive, COALESCE(Priority, 0), COALESCE(SentCount, 0), COALESCE(FailedCount, 0),
+		       COALESCE(LastSentAt, 0), COALESCE(LastError, ''), COALESCE(LastErrorAt, 0)
+		FROM EmailProviderConfig
+		ORDER BY IsActive DESC, Priority, Id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []*EmailProviderStatus
+	for rows.Next() {
+		status := &EmailProviderStatus{}
+		var isActive sql.NullInt64
+		if err := rows.Scan(&status.Provider, &isActive, &status.Priority, &status.SentCount, &status.FailedCount,
+			&status.LastSentAt, &status.LastError, &status.LastErrorAt); err != nil {
+			return nil, err
+		}
+		status.IsActive = isActive.Int64 == 1
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// SetEmailProviderOrder sets the delivery order of the providers (first is tried first) and
+// which of them are enabled. Providers that aren't listed are disabled.
+func (d *Database) SetEmailProviderOrder(providers []string, enabled map[string]bool) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec("UPDATE EmailProviderConfig SET IsActive = 0"); err != nil {
+		return err
+	}
+	for i, provider := range providers {
+		if _, err := tx.Exec("UPDATE EmailProviderConfig SET Priority = ?, IsActive = ?, UpdatedAt = ? WHERE Provider = ?",
+			i+1, boolToInt(enabled[provider]), now, provider); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MakeEmailProviderPrimary enables a provider and moves it to the front of the delivery order.
+// The other providers stay enabled as fallbacks.
+func (d *Database) MakeEmailProviderPrimary(provider string) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE EmailProviderConfig
+		SET IsActive = 1, Priority = (SELECT COALESCE(MIN(Priority), 0) - 1 FROM EmailProviderConfig), UpdatedAt = ?
+		WHERE Provider = ?`, time.Now().Unix(), provider)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// AppendEmailProvider moves a provider to the end of the delivery order, where newly
+// configured providers go
+func (d *Database) AppendEmailProvider(provider string) error {
+	_, err := d.db.Exec(`
+		UPDATE EmailProviderConfig
+		SET Priority = (SELECT COALESCE(MAX(Priority), 0) + 1 FROM EmailProviderConfig WHERE Provider <> ?)
+		WHERE Provider = ?`, provider, provider)
+	return err
+}
+
+// RecordEmailProviderResult counts an attempt to send an email through a provider
+func (d *Database) RecordEmailProviderResult(provider string, sendErr error) error {
+	now := time.Now().Unix()
+	if sendErr == nil {
+		_, err := d.db.Exec("UPDATE EmailProviderConfig SET SentCount = COALESCE(SentCount, 0) + 1, LastSentAt = ? WHERE Provider = ?",
+			now, provider)
+		return err
+	}
+	_, err := d.db.Exec("UPDATE EmailProviderConfig SET FailedCount = COALESCE(FailedCount, 0) + 1, LastError = ?, LastErrorAt = ? WHERE Provider = ?",
+		sendErr.Error(), now, provider)
+	return err
+}
@@ -0,0 +1,231 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Status of a queued email. Sent emails are removed from the queue.
+const (
+	EmailQueueStatusQueued  = "queued"  // waiting for its first or next attempt
+	EmailQueueStatusSending = "sending" // being sent right now
+	EmailQueueStatusFailed  = "failed"  // given up; an admin can retry it
+)
+
+// Kinds of queued emails, shown on the admin email queue page
+const (
+	EmailKindFileLink    = "file_link"
+	EmailKindWelcome     = "welcome"
+	EmailKindFileRequest = "file_request"
+
+	EmailKindDownloadNotice = "download_notice"
+	EmailKindDownloadDigest = "download_digest"
+)
+
+var emailKindLabels = map[string]string{
+	EmailKindFileLink:    "Shared file",
+	EmailKindWelcome:     "Welcome",
+	EmailKindFileRequest: "File request",
+
+	EmailKindDownloadNotice: "Download notification",
+	EmailKindDownloadDigest: "Download summary",
+}
+
+// EmailKindLabel returns the display name of a kind of queued email
+func EmailKindLabel(kind string) string {
+	if label, ok := emailKindLabels[kind]; ok {
+		return label
+	}
+	return kind
+}
+
+// Defaults and limits of how often a queued email is tried before it is given up (config
+// email_max_attempts)
+const (
+	DefaultEmailMaxAttempts = 5
+	MinEmailMaxAttempts     = 1
+	MaxEmailMaxAttempts     = 20
+)
+
+// QueuedEmail is an outgoing email in the email queue. File emails carry the file and sender,
+// so the email log of the file is updated when the email is sent or given up.
+type QueuedEmail struct {
+	Id            int64
+	Kind          string
+	Recipient     string
+	Subject       string
+	HTMLBody      string
+	TextBody      string
+	Category      string // notification category the footer offers to unsubscribe from, "" if transactional
+	Status        string
+	Attempts      int
+	NextAttemptAt int64
+	LastError     string
+	CreatedAt     int64
+
+	FileId        string
+	FileName      string
+	FileSize      int64
+	SenderUserId  int
+	SenderMessage string
+	DedupKey      string // EmailLogs key, so every attempt updates the same log row
+}
+
+// GetEmailMaxAttempts returns how often a queued email is tried before it is marked failed
+func (d *Database) GetEmailMaxAttempts() int {
+	attempts := d.GetConfigInt("email_max_attempts", DefaultEmailMaxAttempts)
+	if attempts < MinEmailMaxAttempts {
+		return MinEmailMaxAttempts
+	}
+	if attempts > MaxEmailMaxAttempts {
+		return MaxEmailMaxAttempts
+	}
+	return attempts
+}
+
+// EnqueueEmail adds an email to the queue, due right away
+func (d *Database) EnqueueEmail(email *QueuedEmail) error {
+	now := time.Now().Unix()
+	email.Status = EmailQueueStatusQueued
+	email.NextAttemptAt = now
+	email.CreatedAt = now
+
+	result, err := d.db.Exec(`
+		INSERT INTO email_queue (kind, recipient, subject, html_body, text_body, category, status, next_attempt_at, created_at,
+			file_id, file_name, file_size, sender_user_id, sender_message, dedup_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		email.Kind, email.Recipient, email.Subject, email.HTMLBody, email.TextBody, email.Category, email.Status,
+		email.NextAttemptAt, email.CreatedAt, email.FileId, email.FileName, email.FileSize, email.SenderUserId,
+		email.SenderMessage, email.DedupKey)
+	if err != nil {
+		return err
+	}
+	email.Id, err = result.LastInsertId()
+	return err
+}
+
+const queuedEmailColumns = `id, kind, recipient, subject, html_body, text_body, COALESCE(category, ''), status,
+	COALESCE(attempts, 0), next_attempt_at, COALESCE(last_error, ''), created_at, COALESCE(file_id, ''),
+	COALESCE(file_name, ''), COALESCE(file_size, 0), COALESCE(sender_user_id, 0), COALESCE(sender_message, ''),
+	COALESCE(dedup_key, '')`
+
+func scanQueuedEmail(scanner interface{ Scan(...interface{}) error }) (*QueuedEmail, error) {
+	email := &QueuedEmail{}
+	err := scanner.Scan(&email.Id, &email.Kind, &email.Recipient, &email.Subject, &email.HTMLBody, &email.TextBody,
+		&email.Category, &email.Status, &email.Attempts, &email.NextAttemptAt, &email.LastError, &email.CreatedAt,
+		&email.FileId, &email.FileName, &email.FileSize, &email.SenderUserId, &email.SenderMessage, &email.DedupKey)
+	return email, err
+}
+
+func (d *Database) queryQueuedEmails(query string, args ...interface{}) ([]*QueuedEmail, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []*QueuedEmail
+	for rows.Next() {
+		email, err := scanQueuedEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// GetDueEmails returns queued emails whose next attempt is due, oldest first
+func (d *Database) GetDueEmails(limit int) ([]*QueuedEmail, error) {
+	return d.queryQueuedEmails(`SELECT `+queuedEmailColumns+` FROM email_queue
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		EmailQueueStatusQueued, time.Now().Unix(), limit)
+}
+
+// GetPendingEmails returns the emails still in the queue (waiting, being sent or failed),
+// newest first, for the admin email queue page
+func (d *Database) GetPendingEmails(limit int) ([]*QueuedEmail, error) {
+	return d.queryQueuedEmails(`SELECT `+queuedEmailColumns+` FROM email_queue ORDER BY created_at DESC, id DESC LIMIT ?`, limit)
+}
+
+// GetQueuedEmail returns one email of the queue
+func (d *Database) GetQueuedEmail(id int64) (*QueuedEmail, error) {
+	email, err := scanQueuedEmail(d.db.QueryRow(`SELECT `+queuedEmailColumns+` FROM email_queue WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("email not found in queue")
+	}
+	return email, err
+}
+
+// CountEmailQueue returns how many emails wait in the queue and how many failed
+func (d *Database) CountEmailQueue() (waiting, failed int, err error) {
+	err = d.db.QueryRow(`SELECT
+		COALESCE(SUM(CASE WHEN status = ? THEN 0 ELSE 1 END), 0),
+		COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		FROM email_queue`, EmailQueueStatusFailed, EmailQueueStatusFailed).Scan(&waiting, &failed)
+	return waiting, failed, err
+}
+
+// ClaimQueuedEmail marks a queued email as being sent. Returns false if it is no longer
+// waiting, for example because an admin deleted it.
+func (d *Database) ClaimQueuedEmail(id int64) (bool, error) {
+	result, err := d.db.Exec("UPDATE email_queue SET status = ? WHERE id = ? AND status = ?",
+		EmailQueueStatusSending, id, EmailQueueStatusQueued)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	return claimed > 0, err
+}
+
+// RescheduleQueuedEmail records a failed attempt and puts the email back in the queue
+func (d *Database) RescheduleQueuedEmail(id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := d.db.Exec("UPDATE email_queue SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?",
+		EmailQueueStatusQueued, attempts, nextAttemptAt.Unix(), lastError, id)
+	return err
+}
+
+// MarkQueuedEmailFailed records the last failed attempt of an email that is given up
+func (d *Database) MarkQueuedEmailFailed(id int64, attempts int, lastError string) error {
+	_, err := d.db.Exec("UPDATE email_queue SET status = ?, attempts = ?, last_error = ? WHERE id = ?",
+		EmailQueueStatusFailed, attempts, lastError, id)
+	return err
+}
+
+// RetryQueuedEmail makes a waiting or failed email due right away. A failed email gets all
+// its attempts again. Returns false if the email is being sent or no longer queued.
+func (d *Database) RetryQueuedEmail(id int64) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE email_queue SET
+			attempts = CASE WHEN status = ? THEN 0 ELSE attempts END,
+			status = ?,
+			next_attempt_at = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		EmailQueueStatusFailed, EmailQueueStatusQueued, time.Now().Unix(), id,
+		EmailQueueStatusQueued, EmailQueueStatusFailed)
+	if err != nil {
+		return false, err
+	}
+	retried, err := result.RowsAffected()
+	return retried > 0, err
+}
+
+// DeleteQueuedEmail removes an email from the queue, after it was sent or when an admin
+// discards it
+func (d *Database) DeleteQueuedEmail(id int64) error {
+	_, err := d.db.Exec("DELETE FROM email_queue WHERE id = ?", id)
+	return err
+}
+
+// ResetSendingEmails puts emails that were being sent when the server stopped back in the
+// queue. Called before the queue worker starts.
+func (d *Database) ResetSendingEmails() error {
+	_, err := d.db.Exec("UPDATE email_queue SET status = ? WHERE status = ?", EmailQueueStatusQueued, EmailQueueStatusSending)
+	return err
+}
@@ -6,27 +6,79 @@
 package database
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/models"
 )
 
-// LogEmailSent creates a new email log entry
-func (d *Database) LogEmailSent(fileId string, senderUserId int, recipientEmail, message, fileName string, fileSize int64) error {
+const (
+	EmailStatusSent   = "sent"
+	EmailStatusFailed = "failed"
+)
+
+// NewEmailSendKey returns the dedup key for one send of a file to a recipient.
+// Retries of the same send must reuse the key so they update a single log row.
+func NewEmailSendKey(fileId, recipientEmail string) string {
+	attempt := make([]byte, 8)
+	rand.Read(attempt)
+	return fileId + ":" + strings.ToLower(strings.TrimSpace(recipientEmail)) + ":" + hex.EncodeToString(attempt)
+}
+
+// LogEmailSent records a successfully sent email and the provider that delivered it. Logging
+// the same dedup key again (e.g. after a retry) updates the existing row instead of adding a
+// duplicate.
+func (d *Database) LogEmailSent(dedupKey, fileId string, senderUserId int, recipientEmail, message, fileName string, fileSize int64, provider string) error {
+	return d.logEmailAttempt(dedupKey, EmailStatusSent, fileId, senderUserId, recipientEmail, message, fileName, fileSize, provider)
+}
+
+// LogEmailFailed records a failed email send so a later retry with the same dedup key can mark it sent
+func (d *Database) LogEmailFailed(dedupKey, fileId string, senderUserId int, recipientEmail, message, fileName string, fileSize int64) error {
+	return d.logEmailAttempt(dedupKey, EmailStatusFailed, fileId, senderUserId, recipientEmail, message, fileName, fileSize, "")
+}
+
+// logEmailAttempt inserts or updates the email log row for a dedup key
+func (d *Database) logEmailAttempt(dedupKey, status, fileId string, senderUserId int, recipientEmail, message, fileName string, fileSize int64, provider string) error {
+	if dedupKey == "" {
+		dedupKey = NewEmailSendKey(fileId, recipientEmail)
+	}
 	_, err := d.db.Exec(`
-		INSERT INTO EmailLogs (FileId, SenderUserId, RecipientEmail, Message, SentAt, FileName, FileSize)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		fileId, senderUserId, recipientEmail, message, time.Now().Unix(), fileName, fileSize)
+		INSERT INTO EmailLogs (FileId, SenderUserId, RecipientEmail, Message, SentAt, FileName, FileSize, DedupKey, Status, Attempts, Provider)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?)
+		ON CONFLICT(DedupKey) WHERE DedupKey <> '' DO UPDATE SET
+			Status = excluded.Status,
+			SentAt = excluded.SentAt,
+			Attempts = EmailLogs.Attempts + 1,
+			Provider = excluded.Provider`,
+		fileId, senderUserId, recipientEmail, message, time.Now().Unix(), fileName, fileSize, dedupKey, status, provider)
 	return err
 }
 
 // GetEmailLogsByFileID retrieves all email logs for a specific file
 func (d *Database) GetEmailLogsByFileID(fileId string) ([]*models.EmailLog, error) {
+	logs, _, err := d.GetEmailLogsByFileIDPage(fileId, LogPage{})
+	return logs, err
+}
+
+// GetEmailLogsByFileIDPage retrieves one page of a file's email logs and the number of logs
+// within the page's date range
+func (d *Database) GetEmailLogsByFileIDPage(fileId string, page LogPage) ([]*models.EmailLog, int, error) {
+	dateClause, dateArgs := page.where("SentAt")
+	args := append([]interface{}{fileId}, dateArgs...)
+
+	var total int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM EmailLogs WHERE FileId = ?"+dateClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
 	rows, err := d.db.Query(`
-		SELECT Id, FileId, SenderUserId, RecipientEmail, Message, SentAt, FileName, FileSize
-		FROM EmailLogs WHERE FileId = ? ORDER BY SentAt DESC`, fileId)
+		SELECT Id, FileId, SenderUserId, RecipientEmail, Message, SentAt, FileName, FileSize,
+		       COALESCE(Status, 'sent'), COALESCE(Attempts, 1), COALESCE(Provider, '')
+		FROM EmailLogs WHERE FileId = ?`+dateClause+` ORDER BY SentAt DESC, Id DESC`+page.limit(), args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -34,12 +86,12 @@ func (d *Database) GetEmailLogsByFileID(fileId string) ([]*models.EmailLog, erro
 	for rows.Next() {
 		log := &models.EmailLog{}
 		err := rows.Scan(&log.Id, &log.FileId, &log.SenderUserId, &log.RecipientEmail,
-			&log.Message, &log.SentAt, &log.FileName, &log.FileSize)
+			&log.Message, &log.SentAt, &log.FileName, &log.FileSize, &log.Status, &log.Attempts, &log.Provider)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		logs = append(logs, log)
 	}
 
-	return logs, nil
+	return logs, total, rows.Err()
 }
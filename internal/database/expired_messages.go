@@ -0,0 +1,28 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// GetFileExpiredMessage returns the owner's message for the file's expired page ("" = none)
+func (d *Database) GetFileExpiredMessage(fileId string) string {
+	var message string
+	if err := d.db.QueryRow("SELECT COALESCE(ExpiredMessage, '') FROM Files WHERE Id = ?", fileId).Scan(&message); err != nil {
+		return ""
+	}
+	return message
+}
+
+// SetFileExpiredMessage sets the owner's message for the file's expired page ("" = none).
+// The message must already be validated.
+func (d *Database) SetFileExpiredMessage(fileId, message string) error {
+	_, err := d.db.Exec("UPDATE Files SET ExpiredMessage = ? WHERE Id = ?", message, fileId)
+	return err
+}
+
+// GetDefaultExpiredMessage returns the message shown on expired pages of files without their own
+func (d *Database) GetDefaultExpiredMessage() string {
+	value, _ := d.GetConfigValue("expired_message_default")
+	return value
+}
@@ -0,0 +1,54 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// GetMaxFileExpiryDays returns how many days a file may be shared at most (0 = no limit)
+func (d *Database) GetMaxFileExpiryDays() int {
+	days := d.GetConfigInt("max_file_expiry_days", 0)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// exceedsExpiryCondition matches active files that never expire or expire after the given time
+const exceedsExpiryCondition = `DeletedAt = 0 AND (UnlimitedTime = 1 OR ExpireAt = 0 OR ExpireAt > ?)`
+
+// CountFilesExceedingExpiry counts active files that expire after maxExpireAt (or never), and their owners
+func (d *Database) CountFilesExceedingExpiry(maxExpireAt int64) (files int, owners int, err error) {
+	err = d.db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT UserId) FROM Files
+		WHERE `+exceedsExpiryCondition, maxExpireAt).Scan(&files, &owners)
+	return files, owners, err
+}
+
+// GetFilesExceedingExpiry returns up to limit active files with an ID after afterId that
+// expire after maxExpireAt (or never), ordered by ID so callers can work through them in batches
+func (d *Database) GetFilesExceedingExpiry(maxExpireAt int64, afterId string, limit int) ([]*FileInfo, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
+		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
+		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
+		FROM Files
+		WHERE `+exceedsExpiryCondition+` AND Id > ?
+		ORDER BY Id LIMIT ?`, maxExpireAt, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// SetFileExpireAt makes a file expire at the given time, replacing "never expire"
+func (d *Database) SetFileExpireAt(fileId string, expireAt int64) error {
+	_, err := d.db.Exec(`
+		UPDATE Files SET ExpireAt = ?, ExpireAtString = ?, UnlimitedTime = 0
+		WHERE Id = ?`,
+		expireAt, FormatExpireAt(expireAt), fileId)
+	return err
+}
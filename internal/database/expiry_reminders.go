@@ -0,0 +1,253 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultExpiryReminderLeadHours is used when expiry_reminder_lead_hours is not configured
+	DefaultExpiryReminderLeadHours = "72,24"
+
+	// DefaultExpiryReminderDailyCap is used when expiry_reminder_daily_cap is not configured
+	DefaultExpiryReminderDailyCap = 5
+)
+
+// ExpiryReminder records an expiry reminder sent to one recipient of a file
+type ExpiryReminder struct {
+	Id             int    `json:"id"`
+	FileId         string `json:"fileId"`
+	RecipientEmail string `json:"recipientEmail"`
+	LeadHours      int    `json:"leadHours"`
+	Token          string `json:"-"`
+	SentAt         int64  `json:"sentAt"`
+	Status         string `json:"status"` // EmailStatusSent or EmailStatusFailed
+}
+
+// ParseExpiryReminderLeadHours parses a comma-separated list of lead times in hours,
+// returned largest first without duplicates
+func ParseExpiryReminderLeadHours(value string) ([]int, error) {
+	seen := make(map[int]bool)
+	var leads []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		hours, err := strconv.Atoi(part)
+		if err != nil || hours <= 0 || hours > 24*365 {
+			return nil, errors.New("lead times must be whole hours between 1 and 8760")
+		}
+		if !seen[hours] {
+			seen[hours] = true
+			leads = append(leads, hours)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(leads)))
+	return leads, nil
+}
+
+// GetExpiryReminderLeadHours returns the configured reminder lead times, largest first
+func (d *Database) GetExpiryReminderLeadHours() []int {
+	value, err := d.GetConfigValue("expiry_reminder_lead_hours")
+	if err != nil || value == "" {
+		value = DefaultExpiryReminderLeadHours
+	}
+	leads, err := ParseExpiryReminderLeadHours(value)
+	if err != nil {
+		leads, _ = ParseExpiryReminderLeadHours(DefaultExpiryReminderLeadHours)
+	}
+	return leads
+}
+
+// GetExpiryReminderDailyCap returns how many reminders one recipient may get per 24 hours (0 = unlimited)
+func (d *Database) GetExpiryReminderDailyCap() int {
+	return d.GetConfigInt("expiry_reminder_daily_cap", DefaultExpiryReminderDailyCap)
+}
+
+// IsFileExpiryRemindersEnabled reports whether a file's email recipients get expiry reminders
+func (d *Database) IsFileExpiryRemindersEnabled(fileId string) bool {
+	var enabled int
+	err := d.db.QueryRow("SELECT COALESCE(ExpiryRemindersEnabled, 0) FROM Files WHERE Id = ?", fileId).Scan(&enabled)
+	return err == nil && enabled == 1
+}
+
+// SetFileExpiryRemindersEnabled turns expiry reminders to a file's email recipients on or off
+func (d *Database) SetFileExpiryRemindersEnabled(fileId string, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET ExpiryRemindersEnabled = ? WHERE Id = ?", value, fileId)
+	return err
+}
+
+// GetFilesDueForExpiryReminders returns files with reminders enabled that expire before the given time
+func (d *Database) GetFilesDueForExpiryReminders(until int64) ([]*FileInfo, error) {
+	rows, err := d.db.Query(`
+		SELECT Id FROM Files
+		WHERE ExpiryRemindersEnabled = 1 AND DeletedAt = 0 AND UnlimitedTime = 0
+		  AND ExpireAt > ? AND ExpireAt <= ?`,
+		time.Now().Unix(), until,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var files []*FileInfo
+	for _, id := range ids {
+		if file, err := d.GetFileByID(id); err == nil {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// GetFileEmailRecipients returns the addresses a file was successfully shared with by email.
+// Download notifications are logged as emails to the sender themselves and are left out.
+func (d *Database) GetFileEmailRecipients(fileId string) ([]string, error) {
+	rows, err := d.db.Query(`
+		SELECT DISTINCT LOWER(TRIM(RecipientEmail)) FROM EmailLogs
+		WHERE FileId = ? AND COALESCE(Status, 'sent') = ?
+		  AND LOWER(TRIM(RecipientEmail)) != COALESCE((SELECT LOWER(TRIM(Email)) FROM Users WHERE Users.Id = EmailLogs.SenderUserId), '')
+		ORDER BY 1`, fileId, EmailStatusSent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, email)
+	}
+	return recipients, rows.Err()
+}
+
+// HasExpiryReminder reports whether a reminder with the given lead time was already sent to a recipient
+func (d *Database) HasExpiryReminder(fileId, recipientEmail string, leadHours int) bool {
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM ExpiryReminders
+		WHERE FileId = ? AND RecipientEmail = ? AND LeadHours = ? AND Status = ?`,
+		fileId, recipientEmail, leadHours, EmailStatusSent).Scan(&count)
+	return err == nil && count > 0
+}
+
+// CountExpiryRemindersSince counts reminders sent to a recipient since the given time
+func (d *Database) CountExpiryRemindersSince(recipientEmail string, since int64) int {
+	var count int
+	d.db.QueryRow("SELECT COUNT(*) FROM ExpiryReminders WHERE RecipientEmail = ? AND Status = ? AND SentAt >= ?",
+		recipientEmail, EmailStatusSent, since).Scan(&count)
+	return count
+}
+
+// NewExpiryReminder creates the record for a reminder about to be sent, including its opt-out token
+func NewExpiryReminder(fileId, recipientEmail string, leadHours int) (*ExpiryReminder, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, err
+	}
+	return &ExpiryReminder{
+		FileId:         fileId,
+		RecipientEmail: recipientEmail,
+		LeadHours:      leadHours,
+		Token:          hex.EncodeToString(tokenBytes),
+	}, nil
+}
+
+// LogExpiryReminder stores the outcome of a reminder. A failed reminder is retried on the
+// next run and updates the same row.
+func (d *Database) LogExpiryReminder(reminder *ExpiryReminder) error {
+	reminder.SentAt = time.Now().Unix()
+	_, err := d.db.Exec(`
+		INSERT INTO ExpiryReminders (FileId, RecipientEmail, LeadHours, Token, SentAt, Status)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(FileId, RecipientEmail, LeadHours) DO UPDATE SET
+			Token = excluded.Token,
+			SentAt = excluded.SentAt,
+			Status = excluded.Status`,
+		reminder.FileId, reminder.RecipientEmail, reminder.LeadHours, reminder.Token, reminder.SentAt, reminder.Status,
+	)
+	return err
+}
+
+// GetExpiryRemindersByFileID returns the reminders sent for a file, newest first
+func (d *Database) GetExpiryRemindersByFileID(fileId string) ([]*ExpiryReminder, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, FileId, RecipientEmail, LeadHours, Token, SentAt, Status
+		FROM ExpiryReminders WHERE FileId = ? ORDER BY SentAt DESC`, fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []*ExpiryReminder
+	for rows.Next() {
+		r := &ExpiryReminder{}
+		if err := rows.Scan(&r.Id, &r.FileId, &r.RecipientEmail, &r.LeadHours, &r.Token, &r.SentAt, &r.Status); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+// GetExpiryReminderByToken returns the reminder an opt-out link belongs to
+func (d *Database) GetExpiryReminderByToken(token string) (*ExpiryReminder, error) {
+	r := &ExpiryReminder{}
+	err := d.db.QueryRow(`
+		SELECT Id, FileId, RecipientEmail, LeadHours, Token, SentAt, Status
+		FROM ExpiryReminders WHERE Token = ?`, token).Scan(
+		&r.Id, &r.FileId, &r.RecipientEmail, &r.LeadHours, &r.Token, &r.SentAt, &r.Status,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("reminder not found")
+	}
+	return r, err
+}
+
+// OptOutOfExpiryReminders stops reminders to a recipient for one file, or for all files if fileId is ""
+func (d *Database) OptOutOfExpiryReminders(recipientEmail, fileId string) error {
+	_, err := d.db.Exec(`
+		INSERT OR IGNORE INTO ExpiryReminderOptOuts (RecipientEmail, FileId, CreatedAt)
+		VALUES (?, ?, ?)`,
+		strings.ToLower(strings.TrimSpace(recipientEmail)), fileId, time.Now().Unix(),
+	)
+	return err
+}
+
+// IsExpiryReminderOptedOut reports whether a recipient opted out of reminders for a file (or all files)
+func (d *Database) IsExpiryReminderOptedOut(recipientEmail, fileId string) bool {
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM ExpiryReminderOptOuts
+		WHERE RecipientEmail = ? AND (FileId = ? OR FileId = '')`,
+		strings.ToLower(strings.TrimSpace(recipientEmail)), fileId).Scan(&count)
+	return err == nil && count > 0
+}
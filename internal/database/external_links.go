@@ -0,0 +1,114 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// MaxExternalLinkTemplateLength is the longest external link template that can be saved
+const MaxExternalLinkTemplateLength = 500
+
+// ExternalLinkTemplateFields documents the fields an external link template can use
+var ExternalLinkTemplateFields = []string{"FileID", "FileName", "OwnerID", "OwnerEmail", "SHA1"}
+
+// GetExternalLinkTemplate returns the URL template that links files into an external system
+// such as a CRM or DMS ("" = no link)
+func (d *Database) GetExternalLinkTemplate() string {
+	value, _ := d.GetConfigValue("external_link_template")
+	return value
+}
+
+// GetExternalLinkLabel returns the text of the external link (default "External record")
+func (d *Database) GetExternalLinkLabel() string {
+	if value, _ := d.GetConfigValue("external_link_label"); value != "" {
+		return value
+	}
+	return "External record"
+}
+
+// IsExternalLinkInNotificationsEnabled reports whether owner notifications include the external link
+func (d *Database) IsExternalLinkInNotificationsEnabled() bool {
+	value, _ := d.GetConfigValue("external_link_in_notifications")
+	return value == "true"
+}
+
+// externalLinkData returns the values available to an external link template. They are
+// URL-encoded so a file name can't change the structure of the link.
+func externalLinkData(fileId, fileName string, ownerId int, ownerEmail, sha1 string) map[string]string {
+	return map[string]string{
+		"FileID":     url.QueryEscape(fileId),
+		"FileName":   url.QueryEscape(fileName),
+		"OwnerID":    strconv.Itoa(ownerId),
+		"OwnerEmail": url.QueryEscape(ownerEmail),
+		"SHA1":       url.QueryEscape(sha1),
+	}
+}
+
+// renderExternalLink renders an external link template and checks that it is an http(s) URL
+func renderExternalLink(tmpl string, data map[string]string) (string, error) {
+	t, err := template.New("external_link").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	link := strings.TrimSpace(buf.String())
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", errors.New("the template must render an http:// or https:// URL")
+	}
+	return link, nil
+}
+
+// ValidateExternalLinkTemplate checks an external link template before it is saved: it must
+// parse, use only known fields and render an http(s) URL
+func ValidateExternalLinkTemplate(tmpl string) error {
+	if tmpl == "" {
+		return nil
+	}
+	if len(tmpl) > MaxExternalLinkTemplateLength {
+		return fmt.Errorf("the template can be at most %d characters", MaxExternalLinkTemplateLength)
+	}
+
+	data := externalLinkData("0123456789abcdef0123456789abcdef", "Example file.pdf", 1, "owner@example.com", "da39a3ee5e6b4b0d3255bfef95601890afd80709")
+	if _, err := renderExternalLink(tmpl, data); err != nil {
+		if strings.Contains(err.Error(), "map has no entry for key") {
+			return fmt.Errorf("%v; available fields are %s", err, "{{."+strings.Join(ExternalLinkTemplateFields, "}}, {{.")+"}}")
+		}
+		return err
+	}
+	return nil
+}
+
+// ExternalFileLink returns the link of a file into the external system, or "" if no template is set
+func (d *Database) ExternalFileLink(file *FileInfo) string {
+	tmpl := d.GetExternalLinkTemplate()
+	if tmpl == "" {
+		return ""
+	}
+
+	ownerEmail := ""
+	if owner, err := d.GetUserByID(file.UserId); err == nil {
+		ownerEmail = owner.Email
+	}
+
+	link, err := renderExternalLink(tmpl, externalLinkData(file.Id, file.Name, file.UserId, ownerEmail, file.SHA1))
+	if err != nil {
+		return ""
+	}
+	return link
+}
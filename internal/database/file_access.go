@@ -0,0 +1,246 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// FileAccessGrant allows an email address to download an access-restricted file
+type FileAccessGrant struct {
+	Id        int
+	FileId    string
+	Email     string
+	GrantedBy int
+	GrantedAt int64
+}
+
+// FileAccessRequest is a recipient asking the owner of an auth-required file for access.
+// Status uses the ApprovalStatus constants.
+type FileAccessRequest struct {
+	Id                 int
+	FileId             string
+	Email              string
+	Name               string
+	Message            string
+	Token              string
+	Status             string
+	RequestedAt        int64
+	RequesterIP        string
+	DecidedBy          int
+	DecidedAt          int64
+	AccountProvisioned bool
+}
+
+// normalizeAccessEmail makes grant lookups case-insensitive
+func normalizeAccessEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// IsFileAccessRequestsEnabled reports whether a file only allows granted emails and accepts access requests
+func (d *Database) IsFileAccessRequestsEnabled(fileId string) bool {
+	var enabled int
+	err := d.db.QueryRow("SELECT COALESCE(AccessRequestsEnabled, 0) FROM Files WHERE Id = ?", fileId).Scan(&enabled)
+	return err == nil && enabled == 1
+}
+
+// SetFileAccessRequestsEnabled turns the "request access" flow on or off for a file
+func (d *Database) SetFileAccessRequestsEnabled(fileId string, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET AccessRequestsEnabled = ? WHERE Id = ?", value, fileId)
+	return err
+}
+
+// HasFileAccessGrant reports whether an email has been granted access to a file
+func (d *Database) HasFileAccessGrant(fileId, email string) bool {
+	var count int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM FileAccessGrants WHERE FileId = ? AND Email = ?",
+		fileId, normalizeAccessEmail(email)).Scan(&count)
+	return err == nil && count > 0
+}
+
+// GrantFileAccess adds an email to a file's access list (granting twice is a no-op)
+func (d *Database) GrantFileAccess(fileId, email string, grantedBy int) error {
+	_, err := d.db.Exec(`
+		INSERT OR IGNORE INTO FileAccessGrants (FileId, Email, GrantedBy, GrantedAt)
+		VALUES (?, ?, ?, ?)`,
+		fileId, normalizeAccessEmail(email), grantedBy, time.Now().Unix(),
+	)
+	return err
+}
+
+// RevokeFileAccess removes an email from a file's access list
+func (d *Database) RevokeFileAccess(fileId, email string) error {
+	result, err := d.db.Exec("DELETE FROM FileAccessGrants WHERE FileId = ? AND Email = ?", fileId, normalizeAccessEmail(email))
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("access grant not found")
+	}
+	return nil
+}
+
+// GetFileAccessGrants returns the access list of a file
+func (d *Database) GetFileAccessGrants(fileId string) ([]*FileAccessGrant, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, FileId, Email, GrantedBy, GrantedAt
+		FROM FileAccessGrants WHERE FileId = ?
+		ORDER BY Email`, fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []*FileAccessGrant
+	for rows.Next() {
+		grant := &FileAccessGrant{}
+		if err := rows.Scan(&grant.Id, &grant.FileId, &grant.Email, &grant.GrantedBy, &grant.GrantedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+// CreateFileAccessRequest records an access request. If the email already has a pending request
+// for the file, that request is returned instead and created is false.
+func (d *Database) CreateFileAccessRequest(fileId, email, name, message, requesterIP string) (request *FileAccessRequest, created bool, err error) {
+	email = normalizeAccessEmail(email)
+
+	existing, err := d.scanFileAccessRequest(d.db.QueryRow(fileAccessRequestSelect+
+		" WHERE FileId = ? AND Email = ? AND Status = ?", fileId, email, ApprovalStatusPending))
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, false, err
+	}
+
+	request = &FileAccessRequest{
+		FileId:      fileId,
+		Email:       email,
+		Name:        strings.TrimSpace(name),
+		Message:     strings.TrimSpace(message),
+		Token:       hex.EncodeToString(tokenBytes),
+		Status:      ApprovalStatusPending,
+		RequestedAt: time.Now().Unix(),
+		RequesterIP: requesterIP,
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO FileAccessRequests (FileId, Email, Name, Message, Token, Status, RequestedAt, RequesterIP)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		request.FileId, request.Email, request.Name, request.Message, request.Token,
+		request.Status, request.RequestedAt, request.RequesterIP,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	id, _ := result.LastInsertId()
+	request.Id = int(id)
+	return request, true, nil
+}
+
+// CountFileAccessRequestsFromIP counts access requests sent from an IP address since the given time
+func (d *Database) CountFileAccessRequestsFromIP(requesterIP string, since int64) int {
+	var count int
+	d.db.QueryRow("SELECT COUNT(*) FROM FileAccessRequests WHERE RequesterIP = ? AND RequestedAt >= ?",
+		requesterIP, since).Scan(&count)
+	return count
+}
+
+const fileAccessRequestSelect = `
+		SELECT Id, FileId, Email, COALESCE(Name, ''), COALESCE(Message, ''), Token, Status, RequestedAt,
+		       COALESCE(RequesterIP, ''), DecidedBy, DecidedAt, AccountProvisioned
+		FROM FileAccessRequests`
+
+func (d *Database) scanFileAccessRequest(row *sql.Row) (*FileAccessRequest, error) {
+	request := &FileAccessRequest{}
+	var provisioned int
+	err := row.Scan(&request.Id, &request.FileId, &request.Email, &request.Name, &request.Message, &request.Token,
+		&request.Status, &request.RequestedAt, &request.RequesterIP, &request.DecidedBy, &request.DecidedAt, &provisioned)
+	if err != nil {
+		return nil, err
+	}
+	request.AccountProvisioned = provisioned == 1
+	return request, nil
+}
+
+// GetFileAccessRequestByToken returns the access request an approve link points to
+func (d *Database) GetFileAccessRequestByToken(token string) (*FileAccessRequest, error) {
+	request, err := d.scanFileAccessRequest(d.db.QueryRow(fileAccessRequestSelect+" WHERE Token = ?", token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("access request not found")
+	}
+	return request, err
+}
+
+// GetFileAccessRequestByID returns an access request by its ID
+func (d *Database) GetFileAccessRequestByID(id int) (*FileAccessRequest, error) {
+	request, err := d.scanFileAccessRequest(d.db.QueryRow(fileAccessRequestSelect+" WHERE Id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("access request not found")
+	}
+	return request, err
+}
+
+// GetFileAccessRequests returns the access requests for a file, newest first
+func (d *Database) GetFileAccessRequests(fileId string) ([]*FileAccessRequest, error) {
+	rows, err := d.db.Query(fileAccessRequestSelect+" WHERE FileId = ? ORDER BY RequestedAt DESC", fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*FileAccessRequest
+	for rows.Next() {
+		request := &FileAccessRequest{}
+		var provisioned int
+		if err := rows.Scan(&request.Id, &request.FileId, &request.Email, &request.Name, &request.Message, &request.Token,
+			&request.Status, &request.RequestedAt, &request.RequesterIP, &request.DecidedBy, &request.DecidedAt, &provisioned); err != nil {
+			return nil, err
+		}
+		request.AccountProvisioned = provisioned == 1
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// DecideFileAccessRequest approves or rejects a pending access request
+func (d *Database) DecideFileAccessRequest(id int, status string, decidedBy int, accountProvisioned bool) error {
+	provisioned := 0
+	if accountProvisioned {
+		provisioned = 1
+	}
+
+	result, err := d.db.Exec(`
+		UPDATE FileAccessRequests SET Status = ?, DecidedBy = ?, DecidedAt = ?, AccountProvisioned = ?
+		WHERE Id = ? AND Status = ?`,
+		status, decidedBy, time.Now().Unix(), provisioned, id, ApprovalStatusPending,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("access request has already been decided")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// File content categories stored on each file at upload
+const (
+	FileCategoryImage    = "image"
+	FileCategoryDocument = "document"
+	FileCategoryVideo    = "video"
+	FileCategoryArchive  = "archive"
+	FileCategoryOther    = "other"
+)
+
+// FileCategories lists the categories in display order
+var FileCategories = []string{
+	FileCategoryImage,
+	FileCategoryDocument,
+	FileCategoryVideo,
+	FileCategoryArchive,
+	FileCategoryOther,
+}
+
+// fileCategoryExtensions maps well-known extensions to a category. Browsers often send
+// application/octet-stream, so the extension is checked before the content type.
+var fileCategoryExtensions = map[string]string{
+	"jpg": FileCategoryImage, "jpeg": FileCategoryImage, "png": FileCategoryImage, "gif": FileCategoryImage,
+	"bmp": FileCategoryImage, "webp": FileCategoryImage, "svg": FileCategoryImage, "tif": FileCategoryImage,
+	"tiff": FileCategoryImage, "heic": FileCategoryImage, "ico": FileCategoryImage, "raw": FileCategoryImage,
+
+	"pdf": FileCategoryDocument, "doc": FileCategoryDocument, "docx": FileCategoryDocument, "xls": FileCategoryDocument,
+	"xlsx": FileCategoryDocument, "ppt": FileCategoryDocument, "pptx": FileCategoryDocument, "odt": FileCategoryDocument,
+	"ods": FileCategoryDocument, "odp": FileCategoryDocument, "rtf": FileCategoryDocument, "txt": FileCategoryDocument,
+	"csv": FileCategoryDocument, "md": FileCategoryDocument, "pages": FileCategoryDocument, "numbers": FileCategoryDocument,
+	"key": FileCategoryDocument, "epub": FileCategoryDocument,
+
+	"mp4": FileCategoryVideo, "mov": FileCategoryVideo, "avi": FileCategoryVideo, "mkv": FileCategoryVideo,
+	"wmv": FileCategoryVideo, "webm": FileCategoryVideo, "m4v": FileCategoryVideo, "mpg": FileCategoryVideo,
+	"mpeg": FileCategoryVideo, "flv": FileCategoryVideo, "3gp": FileCategoryVideo,
+
+	"zip": FileCategoryArchive, "rar": FileCategoryArchive, "7z": FileCategoryArchive, "tar": FileCategoryArchive,
+	"gz": FileCategoryArchive, "tgz": FileCategoryArchive, "bz2": FileCategoryArchive, "xz": FileCategoryArchive,
+	"zst": FileCategoryArchive, "iso": FileCategoryArchive, "dmg": FileCategoryArchive,
+}
+
+// IsValidFileCategory reports whether category is one of the known file categories
+func IsValidFileCategory(category string) bool {
+	for _, c := range FileCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectFileCategory classifies a file by its extension, falling back to the content type
+func DetectFileCategory(fileName, contentType string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	if category, ok := fileCategoryExtensions[ext]; ok {
+		return category
+	}
+
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return FileCategoryImage
+	case strings.HasPrefix(contentType, "video/"):
+		return FileCategoryVideo
+	case strings.HasPrefix(contentType, "text/"),
+		strings.Contains(contentType, "pdf"),
+		strings.Contains(contentType, "officedocument"),
+		strings.Contains(contentType, "opendocument"),
+		strings.Contains(contentType, "msword"):
+		return FileCategoryDocument
+	case strings.Contains(contentType, "zip"),
+		strings.Contains(contentType, "compressed"),
+		strings.Contains(contentType, "x-tar"):
+		return FileCategoryArchive
+	}
+	return FileCategoryOther
+}
+
+// FileCategoryLabel returns the display label for a category
+func FileCategoryLabel(category string) string {
+	switch category {
+	case FileCategoryImage:
+		return "🖼️ Images"
+	case FileCategoryDocument:
+		return "📄 Documents"
+	case FileCategoryVideo:
+		return "🎬 Videos"
+	case FileCategoryArchive:
+		return "🗜️ Archives"
+	}
+	return "📦 Other"
+}
+
+// GetFileCategoryCounts returns the number of non-deleted files per category, largest first
+func (d *Database) GetFileCategoryCounts() ([]string, []int, error) {
+	rows, err := d.db.Query(`
+		SELECT Category, COUNT(*) as count
+		FROM Files
+		WHERE DeletedAt = 0
+		GROUP BY Category
+		ORDER BY count DESC`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var categories []string
+	var counts []int
+	for rows.Next() {
+		var category string
+		var count int
+		if err := rows.Scan(&category, &count); err != nil {
+			return nil, nil, err
+		}
+		categories = append(categories, category)
+		counts = append(counts, count)
+	}
+	return categories, counts, rows.Err()
+}
+
+// backfillFileCategories sets the category of files uploaded before categories were stored
+func (d *Database) backfillFileCategories() error {
+	rows, err := d.db.Query("SELECT Id, Name, COALESCE(ContentType, '') FROM Files WHERE Category = ''")
+	if err != nil {
+		return err
+	}
+
+	categories := make(map[string]string)
+	for rows.Next() {
+		var id, name, contentType string
+		if err := rows.Scan(&id, &name, &contentType); err != nil {
+			rows.Close()
+			return err
+		}
+		categories[id] = DetectFileCategory(name, contentType)
+	}
+	rows.Close()
+
+	for id, category := range categories {
+		if _, err := d.db.Exec("UPDATE Files SET Category = ? WHERE Id = ?", category, id); err != nil {
+			return err
+		}
+	}
+	if len(categories) > 0 {
+		log.Printf("Stored content category for %d existing files", len(categories))
+	}
+	return nil
+}
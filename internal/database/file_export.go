@@ -0,0 +1,106 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileExportRow is a single file in the admin inventory export
+type FileExportRow struct {
+	Id             string   `json:"id"`
+	Name           string   `json:"name"`
+	OwnerName      string   `json:"owner_name"`
+	OwnerEmail     string   `json:"owner_email"`
+	SizeBytes      int64    `json:"size_bytes"`
+	Size           string   `json:"size"`
+	Category       string   `json:"category"`
+	CreatedAt      string   `json:"created_at"`
+	ExpiresAt      string   `json:"expires_at"`
+	DownloadCount  int      `json:"download_count"`
+	DownloadsLeft  string   `json:"downloads_remaining"`
+	Teams          []string `json:"teams"`
+	Status         string   `json:"status"`
+	RequireAuth    bool     `json:"require_auth"`
+	PasswordLocked bool     `json:"password_protected"`
+}
+
+// ForEachFileExport streams every file matching the filter to fn, one row at a time,
+// so large inventories are never held in memory. Iteration stops at the first error from fn.
+func (d *Database) ForEachFileExport(filter *FileFilter, fn func(*FileExportRow) error) error {
+	where, args := filter.whereClause()
+
+	rows, err := d.db.Query(`
+		SELECT f.Id, f.Name, COALESCE(u.Name, ''), COALESCE(u.Email, ''), f.SizeBytes, f.Size, f.Category,
+		       f.UploadDate, f.ExpireAt, f.DownloadCount, f.DownloadsRemaining,
+		       f.UnlimitedDownloads, f.UnlimitedTime, f.RequireAuth, f.FilePasswordPlain,
+		       COALESCE(a.Status, ''),
+		       COALESCE((SELECT GROUP_CONCAT(t.Name, char(10)) FROM TeamFiles tf
+		                 INNER JOIN Teams t ON tf.TeamId = t.Id
+		                 WHERE tf.FileId = f.Id), '')
+		FROM Files f
+		LEFT JOIN Users u ON f.UserId = u.Id
+		LEFT JOIN FileApprovals a ON a.FileId = f.Id
+		WHERE `+where+` ORDER BY f.UploadDate DESC`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now().Unix()
+	for rows.Next() {
+		row := &FileExportRow{}
+		var uploadDate, expireAt int64
+		var downloadsRemaining, unlimitedDownloads, unlimitedTime, requireAuth int
+		var filePassword sql.NullString
+		var approvalStatus, teamNames string
+
+		if err := rows.Scan(&row.Id, &row.Name, &row.OwnerName, &row.OwnerEmail, &row.SizeBytes, &row.Size, &row.Category,
+			&uploadDate, &expireAt, &row.DownloadCount, &downloadsRemaining,
+			&unlimitedDownloads, &unlimitedTime, &requireAuth, &filePassword,
+			&approvalStatus, &teamNames); err != nil {
+			return err
+		}
+
+		row.CreatedAt = time.Unix(uploadDate, 0).UTC().Format(time.RFC3339)
+		if unlimitedTime == 0 && expireAt > 0 {
+			row.ExpiresAt = time.Unix(expireAt, 0).UTC().Format(time.RFC3339)
+		}
+		if unlimitedDownloads == 1 {
+			row.DownloadsLeft = "unlimited"
+		} else {
+			row.DownloadsLeft = strconv.Itoa(downloadsRemaining)
+		}
+		row.Teams = []string{}
+		if teamNames != "" {
+			row.Teams = strings.Split(teamNames, "\n")
+		}
+		row.RequireAuth = requireAuth == 1
+		row.PasswordLocked = filePassword.Valid && filePassword.String != ""
+
+		switch {
+		case unlimitedTime == 0 && expireAt > 0 && expireAt <= now:
+			row.Status = "expired"
+		case unlimitedDownloads == 0 && downloadsRemaining <= 0:
+			row.Status = "expired"
+		case !row.RequireAuth && approvalStatus == ApprovalStatusPending:
+			row.Status = "pending_approval"
+		case !row.RequireAuth && approvalStatus == ApprovalStatusRejected:
+			row.Status = "rejected"
+		default:
+			row.Status = "active"
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,131 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Integrity scan results stored per file
+const (
+	IntegrityOK       = "ok"
+	IntegrityMismatch = "mismatch" // the file no longer matches its stored SHA-256
+	IntegrityMissing  = "missing"  // the file is gone from disk
+	IntegrityError    = "error"    // the file could not be read
+)
+
+// Integrity scan defaults and limits
+const (
+	DefaultIntegrityScanRateMB = 20
+	MaxIntegrityScanRateMB     = 1000
+)
+
+// GetIntegrityScanInterval returns how often each file is verified against its stored SHA-256
+// (0 = the integrity scan is off, the default)
+func (d *Database) GetIntegrityScanInterval() time.Duration {
+	return time.Duration(d.GetConfigInt("integrity_scan_interval_hours", 0)) * time.Hour
+}
+
+// GetIntegrityScanRateMB returns how many megabytes per second the integrity scan reads at most
+func (d *Database) GetIntegrityScanRateMB() int {
+	rate := d.GetConfigInt("integrity_scan_rate_mb", DefaultIntegrityScanRateMB)
+	if rate <= 0 {
+		return DefaultIntegrityScanRateMB
+	}
+	return rate
+}
+
+// FileIntegrity is the latest integrity scan result of a file
+type FileIntegrity struct {
+	FileID     string
+	FileName   string
+	UserID     int
+	SizeBytes  int64
+	Status     string // one of the Integrity* constants, "" if not verified yet
+	Detail     string
+	VerifiedAt int64
+}
+
+// GetFilesDueForVerification returns active files that were last verified before the given
+// time, those never verified first
+func (d *Database) GetFilesDueForVerification(before int64) ([]*FileIntegrity, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, Name, UserId, SizeBytes, COALESCE(IntegrityStatus, ''), COALESCE(IntegrityDetail, ''), COALESCE(LastVerifiedAt, 0)
+		FROM Files
+		WHERE DeletedAt = 0 AND COALESCE(LastVerifiedAt, 0) < ?
+		ORDER BY COALESCE(LastVerifiedAt, 0), UploadDate`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileIntegrity(rows)
+}
+
+// RecordFileIntegrity stores the result of verifying a file
+func (d *Database) RecordFileIntegrity(fileId, status, detail string) error {
+	_, err := d.db.Exec("UPDATE Files SET IntegrityStatus = ?, IntegrityDetail = ?, LastVerifiedAt = ? WHERE Id = ?",
+		status, detail, time.Now().Unix(), fileId)
+	return err
+}
+
+// GetFileIntegrityProblems returns active files whose last verification failed, most recent first
+func (d *Database) GetFileIntegrityProblems() ([]*FileIntegrity, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, Name, UserId, SizeBytes, IntegrityStatus, COALESCE(IntegrityDetail, ''), COALESCE(LastVerifiedAt, 0)
+		FROM Files
+		WHERE DeletedAt = 0 AND IntegrityStatus IN (?, ?, ?)
+		ORDER BY LastVerifiedAt DESC`, IntegrityMismatch, IntegrityMissing, IntegrityError)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileIntegrity(rows)
+}
+
+// IntegritySummary counts active files by integrity scan result
+type IntegritySummary struct {
+	Total      int
+	Verified   int
+	Problems   int
+	Unverified int
+	OldestScan int64 // oldest verification time of a verified file, 0 if none
+}
+
+// GetIntegritySummary counts active files by their latest integrity scan result
+func (d *Database) GetIntegritySummary() (*IntegritySummary, error) {
+	summary := &IntegritySummary{}
+	var oldest sql.NullInt64
+	err := d.db.QueryRow(`
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN IntegrityStatus = ? THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN IntegrityStatus IN (?, ?, ?) THEN 1 ELSE 0 END), 0),
+		       MIN(CASE WHEN LastVerifiedAt > 0 THEN LastVerifiedAt END)
+		FROM Files WHERE DeletedAt = 0`,
+		IntegrityOK, IntegrityMismatch, IntegrityMissing, IntegrityError).Scan(&summary.Total, &summary.Verified, &summary.Problems, &oldest)
+	if err != nil {
+		return nil, err
+	}
+	summary.Unverified = summary.Total - summary.Verified - summary.Problems
+	summary.OldestScan = oldest.Int64
+	return summary, nil
+}
+
+// scanFileIntegrity is a helper to scan integrity rows
+func scanFileIntegrity(rows *sql.Rows) ([]*FileIntegrity, error) {
+	var results []*FileIntegrity
+	for rows.Next() {
+		result := &FileIntegrity{}
+		if err := rows.Scan(&result.FileID, &result.FileName, &result.UserID, &result.SizeBytes,
+			&result.Status, &result.Detail, &result.VerifiedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
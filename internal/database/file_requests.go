@@ -30,9 +30,11 @@ func (d *Database) CreateFileRequest(req *models.FileRequest) error {
 	}
 
 	result, err := d.db.Exec(`
-		INSERT INTO FileRequests (UserId, RequestToken, Title, Message, CreatedAt, ExpiresAt, IsActive, MaxFileSize, AllowedFileTypes)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO FileRequests (UserId, RequestToken, Title, Message, CreatedAt, ExpiresAt, IsActive, MaxFileSize, AllowedFileTypes,
+		                          BrandName, BrandColor, TeamId)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		req.UserId, req.RequestToken, req.Title, req.Message, req.CreatedAt, req.ExpiresAt, boolToInt(req.IsActive), req.MaxFileSize, req.AllowedFileTypes,
+		req.BrandName, req.BrandColor, req.TeamId,
 	)
 	if err != nil {
 		return err
@@ -55,11 +57,12 @@ func (d *Database) GetFileRequestByToken(token string) (*models.FileRequest, err
 
 	err := d.db.QueryRow(`
 		SELECT Id, UserId, RequestToken, Title, Message, CreatedAt, ExpiresAt, IsActive, MaxFileSize, AllowedFileTypes,
-		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt
+		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt,
+		       COALESCE(BrandName, ''), COALESCE(BrandColor, ''), COALESCE(TeamId, 0)
 		FROM FileRequests WHERE RequestToken = ?`, token).Scan(
 		&req.Id, &req.UserId, &req.RequestToken, &req.Title, &req.Message,
 		&req.CreatedAt, &req.ExpiresAt, &isActive, &req.MaxFileSize, &req.AllowedFileTypes,
-		&usedByIP, &usedAt,
+		&usedByIP, &usedAt, &req.BrandName, &req.BrandColor, &req.TeamId,
 	)
 
 	if err != nil {
@@ -83,7 +86,8 @@ func (d *Database) GetFileRequestByToken(token string) (*models.FileRequest, err
 func (d *Database) GetFileRequestsByUser(userId int) ([]*models.FileRequest, error) {
 	rows, err := d.db.Query(`
 		SELECT Id, UserId, RequestToken, Title, Message, CreatedAt, ExpiresAt, IsActive, MaxFileSize, AllowedFileTypes,
-		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt
+		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt,
+		       COALESCE(BrandName, ''), COALESCE(BrandColor, ''), COALESCE(TeamId, 0)
 		FROM FileRequests WHERE UserId = ? ORDER BY CreatedAt DESC`, userId)
 	if err != nil {
 		return nil, err
@@ -99,7 +103,7 @@ func (d *Database) GetFileRequestsByUser(userId int) ([]*models.FileRequest, err
 
 		err := rows.Scan(&req.Id, &req.UserId, &req.RequestToken, &req.Title, &req.Message,
 			&req.CreatedAt, &req.ExpiresAt, &isActive, &req.MaxFileSize, &req.AllowedFileTypes,
-			&usedByIP, &usedAt)
+			&usedByIP, &usedAt, &req.BrandName, &req.BrandColor, &req.TeamId)
 		if err != nil {
 			return nil, err
 		}
@@ -117,11 +121,25 @@ func (d *Database) GetFileRequestsByUser(userId int) ([]*models.FileRequest, err
 	return requests, nil
 }
 
+// CountActiveFileRequestsByUser counts a user's upload request links that are active, unused and not expired
+func (d *Database) CountActiveFileRequestsByUser(userId int) (int, error) {
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM FileRequests
+		WHERE UserId = ? AND IsActive = 1
+		  AND (ExpiresAt = 0 OR ExpiresAt > ?)
+		  AND COALESCE(UsedAt, 0) = 0`,
+		userId, time.Now().Unix(),
+	).Scan(&count)
+	return count, err
+}
+
 // GetAllFileRequests retrieves all file requests (Admin only)
 func (d *Database) GetAllFileRequests() ([]*models.FileRequest, error) {
 	rows, err := d.db.Query(`
 		SELECT Id, UserId, RequestToken, Title, Message, CreatedAt, ExpiresAt, IsActive, MaxFileSize, AllowedFileTypes,
-		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt
+		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt,
+		       COALESCE(BrandName, ''), COALESCE(BrandColor, ''), COALESCE(TeamId, 0)
 		FROM FileRequests ORDER BY CreatedAt DESC`)
 	if err != nil {
 		return nil, err
@@ -137,7 +155,7 @@ func (d *Database) GetAllFileRequests() ([]*models.FileRequest, error) {
 
 		err := rows.Scan(&req.Id, &req.UserId, &req.RequestToken, &req.Title, &req.Message,
 			&req.CreatedAt, &req.ExpiresAt, &isActive, &req.MaxFileSize, &req.AllowedFileTypes,
-			&usedByIP, &usedAt)
+			&usedByIP, &usedAt, &req.BrandName, &req.BrandColor, &req.TeamId)
 		if err != nil {
 			return nil, err
 		}
@@ -164,11 +182,12 @@ func (d *Database) GetFileRequestByID(id int) (*models.FileRequest, error) {
 
 	err := d.db.QueryRow(`
 		SELECT Id, UserId, RequestToken, Title, Message, CreatedAt, ExpiresAt, IsActive, MaxFileSize, AllowedFileTypes,
-		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt
+		       COALESCE(UsedByIP, '') as UsedByIP, COALESCE(UsedAt, 0) as UsedAt,
+		       COALESCE(BrandName, ''), COALESCE(BrandColor, ''), COALESCE(TeamId, 0)
 		FROM FileRequests WHERE Id = ?`, id).Scan(
 		&req.Id, &req.UserId, &req.RequestToken, &req.Title, &req.Message,
 		&req.CreatedAt, &req.ExpiresAt, &isActive, &req.MaxFileSize, &req.AllowedFileTypes,
-		&usedByIP, &usedAt,
+		&usedByIP, &usedAt, &req.BrandName, &req.BrandColor, &req.TeamId,
 	)
 
 	if err != nil {
@@ -191,9 +210,11 @@ func (d *Database) GetFileRequestByID(id int) (*models.FileRequest, error) {
 // UpdateFileRequest updates an existing file request
 func (d *Database) UpdateFileRequest(req *models.FileRequest) error {
 	_, err := d.db.Exec(`
-		UPDATE FileRequests SET Title = ?, Message = ?, ExpiresAt = ?, IsActive = ?, MaxFileSize = ?, AllowedFileTypes = ?
+		UPDATE FileRequests SET Title = ?, Message = ?, ExpiresAt = ?, IsActive = ?, MaxFileSize = ?, AllowedFileTypes = ?,
+		       BrandName = ?, BrandColor = ?, TeamId = ?
 		WHERE Id = ?`,
-		req.Title, req.Message, req.ExpiresAt, boolToInt(req.IsActive), req.MaxFileSize, req.AllowedFileTypes, req.Id,
+		req.Title, req.Message, req.ExpiresAt, boolToInt(req.IsActive), req.MaxFileSize, req.AllowedFileTypes,
+		req.BrandName, req.BrandColor, req.TeamId, req.Id,
 	)
 	return err
 }
@@ -213,13 +234,24 @@ func generateRequestToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-// CleanupExpiredFileRequests deletes file requests that have been expired for more than 10 days
-// This keeps the expired message visible for 10 days, then removes the request entirely
+// DefaultFileRequestRetentionDays is used when file_request_retention_days is not configured
+const DefaultFileRequestRetentionDays = 10
+
+// CleanupExpiredFileRequests deletes file requests that have been expired for more than
+// file_request_retention_days (10 by default). This keeps the expired message visible for
+// that long, then removes the request entirely
 func (d *Database) CleanupExpiredFileRequests() error {
-	// Delete requests that expired more than 10 days ago
-	// This means: CreatedAt + 24 hours (expiration) + 10 days < now
-	// Or: ExpiresAt + 10 days < now
-	cutoffTime := time.Now().Add(-10 * 24 * time.Hour).Unix()
+	// Deactivate requests that have expired so they no longer count as active
+	if _, err := d.db.Exec("UPDATE FileRequests SET IsActive = 0 WHERE IsActive = 1 AND ExpiresAt > 0 AND ExpiresAt < ?", time.Now().Unix()); err != nil {
+		return err
+	}
+
+	// Delete requests that expired more than the retention period ago: ExpiresAt + retention < now
+	retentionDays := d.GetConfigInt("file_request_retention_days", DefaultFileRequestRetentionDays)
+	if retentionDays < 1 {
+		retentionDays = DefaultFileRequestRetentionDays
+	}
+	cutoffTime := time.Now().Add(-time.Duration(retentionDays) * 24 * time.Hour).Unix()
 
 	result, err := d.db.Exec("DELETE FROM FileRequests WHERE ExpiresAt > 0 AND ExpiresAt < ?", cutoffTime)
 	if err != nil {
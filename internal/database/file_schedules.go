@@ -0,0 +1,248 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileSchedule limits when a file can be downloaded, independently of its expiry.
+// A zero AvailableFrom/AvailableUntil and empty daily hours/days mean no restriction.
+type FileSchedule struct {
+	AvailableFrom  int64  // Unix time the file becomes available (0 = now)
+	AvailableUntil int64  // Unix time the file stops being available (0 = until expiry)
+	DailyStart     string // "HH:MM" daily opening time ("" = all day)
+	DailyEnd       string // "HH:MM" daily closing time, may be earlier than DailyStart for overnight windows
+	Days           string // Comma-separated weekdays (0 = Sunday ... 6 = Saturday), "" = every day
+	Timezone       string // IANA timezone the daily hours are evaluated in ("" = configured server timezone)
+}
+
+// IsEmpty reports whether the schedule places no restriction on the file
+func (sch *FileSchedule) IsEmpty() bool {
+	return sch.AvailableFrom == 0 && sch.AvailableUntil == 0 && sch.DailyStart == "" && sch.DailyEnd == "" && sch.Days == ""
+}
+
+// Validate checks that the schedule is well-formed
+func (sch *FileSchedule) Validate() error {
+	if sch.AvailableFrom > 0 && sch.AvailableUntil > 0 && sch.AvailableUntil <= sch.AvailableFrom {
+		return errors.New("the availability end must be after the start")
+	}
+	if (sch.DailyStart == "") != (sch.DailyEnd == "") {
+		return errors.New("both daily start and end times are required")
+	}
+	if sch.DailyStart != "" {
+		start, err := parseClockMinutes(sch.DailyStart)
+		if err != nil {
+			return err
+		}
+		end, err := parseClockMinutes(sch.DailyEnd)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return errors.New("daily start and end times must differ")
+		}
+	}
+	if _, err := parseScheduleDays(sch.Days); err != nil {
+		return err
+	}
+	if sch.Timezone != "" {
+		if _, err := time.LoadLocation(sch.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", sch.Timezone)
+		}
+	}
+	return nil
+}
+
+// Location returns the timezone the schedule is evaluated in
+func (sch *FileSchedule) Location() *time.Location {
+	if sch.Timezone != "" {
+		if loc, err := time.LoadLocation(sch.Timezone); err == nil {
+			return loc
+		}
+	}
+	return ServerLocation()
+}
+
+// Availability reports whether the file can be downloaded at the given time.
+// When it can't, next is the next time it opens, or zero if it never will again.
+func (sch *FileSchedule) Availability(now time.Time) (available bool, next time.Time) {
+	loc := sch.Location()
+	now = now.In(loc)
+
+	if sch.AvailableUntil > 0 && now.Unix() >= sch.AvailableUntil {
+		return false, time.Time{}
+	}
+
+	// Start searching from the date window's opening if it's in the future
+	from := now
+	if sch.AvailableFrom > 0 && now.Unix() < sch.AvailableFrom {
+		from = time.Unix(sch.AvailableFrom, 0).In(loc)
+	}
+
+	if sch.inDailyWindow(from) {
+		if from.Equal(now) {
+			return true, time.Time{}
+		}
+		return false, from
+	}
+
+	next = sch.nextDailyOpening(from)
+	if next.IsZero() || (sch.AvailableUntil > 0 && next.Unix() >= sch.AvailableUntil) {
+		return false, time.Time{}
+	}
+	return false, next
+}
+
+// dailyMinutes returns the daily window in minutes since midnight (0-1440 when only days are restricted)
+func (sch *FileSchedule) dailyMinutes() (int, int) {
+	if sch.DailyStart == "" {
+		return 0, 24 * 60
+	}
+	start, _ := parseClockMinutes(sch.DailyStart)
+	end, _ := parseClockMinutes(sch.DailyEnd)
+	return start, end
+}
+
+// dayAllowed reports whether a window may open on the given weekday
+func (sch *FileSchedule) dayAllowed(day time.Weekday) bool {
+	days, _ := parseScheduleDays(sch.Days)
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// inDailyWindow reports whether t falls inside the recurring daily window
+func (sch *FileSchedule) inDailyWindow(t time.Time) bool {
+	start, end := sch.dailyMinutes()
+	minute := t.Hour()*60 + t.Minute()
+
+	if start < end {
+		return minute >= start && minute < end && sch.dayAllowed(t.Weekday())
+	}
+
+	// Overnight window: the early-morning part belongs to the previous day's window
+	if minute >= start {
+		return sch.dayAllowed(t.Weekday())
+	}
+	if minute < end {
+		return sch.dayAllowed(t.AddDate(0, 0, -1).Weekday())
+	}
+	return false
+}
+
+// nextDailyOpening returns the first daily window opening after t
+func (sch *FileSchedule) nextDailyOpening(t time.Time) time.Time {
+	start, _ := sch.dailyMinutes()
+	for i := 0; i <= 7; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, start/60, start%60, 0, 0, t.Location())
+		if day.Before(t) || !sch.dayAllowed(day.Weekday()) {
+			continue
+		}
+		return day
+	}
+	return time.Time{}
+}
+
+// parseClockMinutes parses "HH:MM" into minutes since midnight
+func parseClockMinutes(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseScheduleDays parses a comma-separated list of weekday numbers
+func parseScheduleDays(value string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 6 {
+			return nil, fmt.Errorf("invalid weekday %q", part)
+		}
+		days = append(days, time.Weekday(n))
+	}
+	return days, nil
+}
+
+// NormalizeScheduleDays returns the weekday list sorted and de-duplicated ("" if all days are selected)
+func NormalizeScheduleDays(value string) string {
+	days, err := parseScheduleDays(value)
+	if err != nil {
+		return value
+	}
+	seen := make(map[time.Weekday]bool)
+	var unique []int
+	for _, d := range days {
+		if !seen[d] {
+			seen[d] = true
+			unique = append(unique, int(d))
+		}
+	}
+	if len(unique) == 7 {
+		return ""
+	}
+	sort.Ints(unique)
+	parts := make([]string, len(unique))
+	for i, d := range unique {
+		parts[i] = strconv.Itoa(d)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ServerLocation returns the configured server timezone, falling back to the host's local time
+func ServerLocation() *time.Location {
+	if DB != nil {
+		if name, _ := DB.GetConfigValue("timezone"); name != "" {
+			if loc, err := time.LoadLocation(name); err == nil {
+				return loc
+			}
+		}
+	}
+	return time.Local
+}
+
+// GetFileSchedule returns the availability schedule of a file
+func (d *Database) GetFileSchedule(fileId string) (*FileSchedule, error) {
+	sch := &FileSchedule{}
+	err := d.db.QueryRow(`
+		SELECT COALESCE(AvailableFrom, 0), COALESCE(AvailableUntil, 0), COALESCE(DailyStart, ''),
+		       COALESCE(DailyEnd, ''), COALESCE(ScheduleDays, ''), COALESCE(ScheduleTimezone, '')
+		FROM Files WHERE Id = ?`, fileId).Scan(
+		&sch.AvailableFrom, &sch.AvailableUntil, &sch.DailyStart, &sch.DailyEnd, &sch.Days, &sch.Timezone,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// SetFileSchedule stores the availability schedule of a file (an empty schedule clears it)
+func (d *Database) SetFileSchedule(fileId string, sch *FileSchedule) error {
+	_, err := d.db.Exec(`
+		UPDATE Files
+		SET AvailableFrom = ?, AvailableUntil = ?, DailyStart = ?, DailyEnd = ?, ScheduleDays = ?, ScheduleTimezone = ?
+		WHERE Id = ?`,
+		sch.AvailableFrom, sch.AvailableUntil, sch.DailyStart, sch.DailyEnd, sch.Days, sch.Timezone, fileId,
+	)
+	return err
+}
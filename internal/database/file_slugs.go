@@ -0,0 +1,107 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Length limits of a custom file slug
+const (
+	MinFileSlugLength = 3
+	MaxFileSlugLength = 64
+)
+
+// ErrSlugTaken is returned when another file already uses a slug
+var ErrSlugTaken = errors.New("this link name is already used by another file")
+
+var fileSlugPattern = regexp.MustCompile(`^[a-z0-9]+(?:[-_][a-z0-9]+)*$`)
+
+// reservedFileSlugs can't be used as slugs, since they are or may become paths under /d/ and
+// /s/, or read like system pages in a link
+var reservedFileSlugs = map[string]bool{
+	"zip": true, "admin": true, "api": true, "login": true, "logout": true, "dashboard": true,
+	"download": true, "downloads": true, "upload": true, "uploads": true, "file": true, "files": true,
+	"share": true, "static": true, "preview": true, "settings": true, "help": true, "new": true,
+	"edit": true, "delete": true, "trash": true, "wulfvault": true, "health": true, "metrics": true,
+}
+
+// NormalizeFileSlug returns a slug as it is stored: trimmed and lower case
+func NormalizeFileSlug(slug string) string {
+	return strings.ToLower(strings.TrimSpace(slug))
+}
+
+// ValidateFileSlug checks that a normalized slug may be used in file links: 3-64 lower case
+// letters and digits, separated by single hyphens or underscores, and not a reserved word
+func ValidateFileSlug(slug string) error {
+	if len(slug) < MinFileSlugLength || len(slug) > MaxFileSlugLength {
+		return fmt.Errorf("the link name must be %d-%d characters long", MinFileSlugLength, MaxFileSlugLength)
+	}
+	if !fileSlugPattern.MatchString(slug) {
+		return errors.New("the link name may only contain letters, digits, and single hyphens or underscores between them")
+	}
+	if reservedFileSlugs[slug] {
+		return fmt.Errorf("%q is reserved and can't be used as a link name", slug)
+	}
+	return nil
+}
+
+// CheckFileSlugAvailable reports ErrSlugTaken if a slug is used by a file other than fileId,
+// or is the ID of another file. Files in trash keep their slug until they are deleted.
+func (d *Database) CheckFileSlugAvailable(slug, fileId string) error {
+	var count int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM Files WHERE (Slug = ? OR Id = ?) AND Id != ?", slug, slug, fileId).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSlugTaken
+	}
+	return nil
+}
+
+// GetFileSlug returns the custom slug of a file, "" if it has none
+func (d *Database) GetFileSlug(fileId string) string {
+	var slug string
+	d.db.QueryRow("SELECT COALESCE(Slug, '') FROM Files WHERE Id = ?", fileId).Scan(&slug)
+	return slug
+}
+
+// SetFileSlug sets the custom slug of a file, or removes it when slug is "". The slug must
+// already be normalized and valid.
+func (d *Database) SetFileSlug(fileId, slug string) error {
+	if slug != "" {
+		if err := d.CheckFileSlugAvailable(slug, fileId); err != nil {
+			return err
+		}
+	}
+	_, err := d.db.Exec("UPDATE Files SET Slug = ? WHERE Id = ?", slug, fileId)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrSlugTaken
+	}
+	return err
+}
+
+// ResolveFileID returns the ID of the file a link points to: the ID itself, or the ID of the
+// file with that slug. Links that match neither are returned unchanged.
+func (d *Database) ResolveFileID(idOrSlug string) string {
+	slug := NormalizeFileSlug(idOrSlug)
+	if !fileSlugPattern.MatchString(slug) {
+		return idOrSlug
+	}
+	var id string
+	err := d.db.QueryRow(`
+		SELECT Id FROM Files WHERE Id = ?
+		UNION ALL
+		SELECT Id FROM Files WHERE Slug = ?
+		LIMIT 1`, idOrSlug, slug).Scan(&id)
+	if err != nil {
+		return idOrSlug
+	}
+	return id
+}
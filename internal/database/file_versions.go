@@ -0,0 +1,198 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FileVersion is an earlier version of a file. The current version is the Files row itself;
+// replacing its content moves the previous content to a FileVersion, so share links keep
+// working and always serve the latest version.
+type FileVersion struct {
+	Id          int64
+	FileId      string
+	Version     int
+	StorageId   string // where the content is kept in storage
+	Name        string
+	SizeBytes   int64
+	SHA1        string
+	SHA256      string
+	ContentType string
+	UploadedBy  int
+	UploadedAt  int64
+	ReplacedAt  int64 // 0 for the current version
+	Downloads   int   // downloads while this was the current version
+}
+
+// VersionStorageID returns the storage ID the content of an earlier version is kept under
+func VersionStorageID(fileId string, version int) string {
+	return fmt.Sprintf("%s.v%d", fileId, version)
+}
+
+// GetFileVersion returns the version number of a file's current content
+func (d *Database) GetFileVersion(fileId string) int {
+	version := 1
+	d.db.QueryRow("SELECT COALESCE(Version, 1) FROM Files WHERE Id = ?", fileId).Scan(&version)
+	return version
+}
+
+// GetFileVersions returns every version of a file, newest first, starting with the current one.
+// Downloads are counted from the download log by when each version was current, so the file's
+// DownloadCount is the total across versions.
+func (d *Database) GetFileVersions(fileId string) ([]*FileVersion, error) {
+	current := &FileVersion{FileId: fileId, StorageId: fileId}
+	var versionUploadedBy int
+	err := d.db.QueryRow(`
+		SELECT COALESCE(Version, 1), Name, COALESCE(SizeBytes, 0), COALESCE(SHA1, ''), COALESCE(SHA256, ''),
+		       COALESCE(ContentType, ''), UserId, COALESCE(VersionUploadedBy, 0), UploadDate
+		FROM Files WHERE Id = ?`, fileId).Scan(
+		&current.Version, &current.Name, &current.SizeBytes, &current.SHA1, &current.SHA256,
+		&current.ContentType, &current.UploadedBy, &versionUploadedBy, &current.UploadedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("file not found")
+		}
+		return nil, err
+	}
+	if versionUploadedBy > 0 {
+		current.UploadedBy = versionUploadedBy
+	}
+
+	rows, err := d.db.Query(`
+		SELECT id, file_id, version, storage_id, name, size_bytes, sha1, sha256, content_type,
+		       uploaded_by, uploaded_at, replaced_at
+		FROM file_versions WHERE file_id = ? ORDER BY version DESC`, fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []*FileVersion{current}
+	for rows.Next() {
+		v := &FileVersion{}
+		if err := rows.Scan(&v.Id, &v.FileId, &v.Version, &v.StorageId, &v.Name, &v.SizeBytes, &v.SHA1,
+			&v.SHA256, &v.ContentType, &v.UploadedBy, &v.UploadedAt, &v.ReplacedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Downloads while each version was current. The first version also gets any downloads
+	// logged before its upload time, e.g. from before versioning existed.
+	for i, v := range versions {
+		from, until := v.UploadedAt, v.ReplacedAt
+		if i == len(versions)-1 {
+			from = 0
+		}
+		if until == 0 {
+			until = time.Now().Unix() + 1
+		}
+		d.db.QueryRow("SELECT COUNT(*) FROM DownloadLogs WHERE FileId = ? AND DownloadedAt >= ? AND DownloadedAt < ?",
+			fileId, from, until).Scan(&v.Downloads)
+	}
+	return versions, nil
+}
+
+// GetEarlierFileVersion returns an earlier version of a file
+func (d *Database) GetEarlierFileVersion(fileId string, version int) (*FileVersion, error) {
+	v := &FileVersion{}
+	err := d.db.QueryRow(`
+		SELECT id, file_id, version, storage_id, name, size_bytes, sha1, sha256, content_type,
+		       uploaded_by, uploaded_at, replaced_at
+		FROM file_versions WHERE file_id = ? AND version = ?`, fileId, version).Scan(
+		&v.Id, &v.FileId, &v.Version, &v.StorageId, &v.Name, &v.SizeBytes, &v.SHA1,
+		&v.SHA256, &v.ContentType, &v.UploadedBy, &v.UploadedAt, &v.ReplacedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("version not found")
+	}
+	return v, err
+}
+
+// ReplaceFileVersion records that a file's content was replaced. previous, the content that was
+// current until now, becomes an earlier version; next becomes the file's content as the next
+// version number, not scanned for viruses or stripped of metadata yet. The owner's storage
+// usage changes by the difference in size, since earlier versions, like files in trash, don't
+// count toward it.
+func (d *Database) ReplaceFileVersion(previous, next *FileVersion) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec(`
+		INSERT INTO file_versions (file_id, version, storage_id, name, size_bytes, sha1, sha256,
+		                           content_type, uploaded_by, uploaded_at, replaced_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		previous.FileId, previous.Version, previous.StorageId, previous.Name, previous.SizeBytes,
+		previous.SHA1, previous.SHA256, previous.ContentType, previous.UploadedBy, previous.UploadedAt, now); err != nil {
+		return err
+	}
+
+	if err := adjustFileOwnerStorage(tx, previous.FileId, -1); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE Files
+		SET Version = ?, Name = ?, Size = ?, SizeBytes = ?, SHA1 = ?, SHA256 = ?, ContentType = ?,
+		    VersionUploadedBy = ?, UploadDate = ?, ScanStatus = '', ScanSignature = '', ScannedAt = 0,
+		    MetadataStripped = 0
+		WHERE Id = ? AND COALESCE(Version, 1) = ?`,
+		previous.Version+1, next.Name, FormatFileSize(next.SizeBytes), next.SizeBytes, next.SHA1, next.SHA256,
+		next.ContentType, next.UploadedBy, now, previous.FileId, previous.Version)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("the file was changed by someone else, try again")
+	}
+	if err := adjustFileOwnerStorage(tx, previous.FileId, 1); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetExpiredFileVersions returns earlier versions that were replaced more than retentionDays
+// ago, since they are kept as long as files in trash, and the versions of files that were
+// permanently deleted
+func (d *Database) GetExpiredFileVersions(retentionDays int) ([]*FileVersion, error) {
+	rows, err := d.db.Query(`
+		SELECT id, file_id, version, storage_id, name, size_bytes
+		FROM file_versions
+		WHERE replaced_at < ? OR file_id NOT IN (SELECT Id FROM Files)`,
+		time.Now().Add(-time.Duration(retentionDays)*24*time.Hour).Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFileVersionIDs(rows)
+}
+
+func scanFileVersionIDs(rows *sql.Rows) ([]*FileVersion, error) {
+	var versions []*FileVersion
+	for rows.Next() {
+		v := &FileVersion{}
+		if err := rows.Scan(&v.Id, &v.FileId, &v.Version, &v.StorageId, &v.Name, &v.SizeBytes); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// DeleteFileVersion removes the record of an earlier version
+func (d *Database) DeleteFileVersion(id int64) error {
+	_, err := d.db.Exec("DELETE FROM file_versions WHERE id = ?", id)
+	return err
+}
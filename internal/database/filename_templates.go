@@ -0,0 +1,22 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// GetFileFilenameTemplate returns the template for the name a file is downloaded as ("" = stored name)
+func (d *Database) GetFileFilenameTemplate(fileId string) string {
+	var tmpl string
+	if err := d.db.QueryRow("SELECT COALESCE(FilenameTemplate, '') FROM Files WHERE Id = ?", fileId).Scan(&tmpl); err != nil {
+		return ""
+	}
+	return tmpl
+}
+
+// SetFileFilenameTemplate sets the template for the name a file is downloaded as ("" = stored name).
+// The template must already be validated.
+func (d *Database) SetFileFilenameTemplate(fileId, tmpl string) error {
+	_, err := d.db.Exec("UPDATE Files SET FilenameTemplate = ? WHERE Id = ?", tmpl, fileId)
+	return err
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"errors"
@@ -41,6 +42,51 @@ type FileInfo struct {
 	RequireAuth        bool
 	DeletedAt          int64
 	DeletedBy          int
+	Category           string // FileCategory constant, detected at upload
+	SHA256             string // hex digest, "" until it has been calculated
+	DownloadLimitMBps  int    // download bandwidth limit in MB/s, 0 = the global limit
+	BurnAfterDownload  bool   // removed after its first complete download, see ClaimBurnDownload
+	ScanStatus         string // virus scan result, one of the Scan* constants or "" if not scanned
+	ActivateAt         int64  // Unix time the file's links go live, 0 = at upload; the AvailableFrom of its schedule
+}
+
+// Reasons returned by FileInfo.ExpiredReason
+const (
+	FileExpiredByTime      = "expired"
+	FileExpiredByDownloads = "download_limit_reached"
+)
+
+// ExpiredReason evaluates a file's expiry time and remaining downloads at now and returns
+// why the file can no longer be downloaded, or "" if it has not expired. Expired files stay
+// in the Files table until the cleanup scheduler moves them to trash, so every download path
+// checks this instead of relying on the file being gone.
+func (f *FileInfo) ExpiredReason(now time.Time) string {
+	if !f.UnlimitedTime && f.ExpireAt > 0 && now.Unix() > f.ExpireAt {
+		return FileExpiredByTime
+	}
+	if !f.UnlimitedDownloads && f.DownloadsRemaining <= 0 {
+		return FileExpiredByDownloads
+	}
+	return ""
+}
+
+// IsScheduled reports whether the file's links are not live yet at now
+func (f *FileInfo) IsScheduled(now time.Time) bool {
+	return f.ActivateAt > now.Unix()
+}
+
+// ValidateActivation checks that a file activated at activateAt is still live when it does:
+// a file with an expiry time must expire after it goes live
+func ValidateActivation(activateAt, expireAt int64, unlimitedTime bool) error {
+	if activateAt > 0 && !unlimitedTime && expireAt > 0 && expireAt <= activateAt {
+		return errors.New("the file must expire after its link goes live")
+	}
+	return nil
+}
+
+// FormatExpireAt formats an expiry time for ExpireAtString, in the server's timezone
+func FormatExpireAt(expireAt int64) string {
+	return time.Unix(expireAt, 0).In(ServerLocation()).Format("2006-01-02 15:04")
 }
 
 // SaveFile saves file metadata to the database
@@ -57,6 +103,10 @@ func (d *Database) SaveFile(file *FileInfo) error {
 	if file.RequireAuth {
 		requireAuth = 1
 	}
+	burnAfterDownload := 0
+	if file.BurnAfterDownload {
+		burnAfterDownload = 1
+	}
 
 	// Convert empty password to NULL for database storage
 	var filePassword interface{}
@@ -66,17 +116,29 @@ func (d *Database) SaveFile(file *FileInfo) error {
 		filePassword = file.FilePasswordPlain
 	}
 
+	if file.Category == "" {
+		file.Category = DetectFileCategory(file.Name, file.ContentType)
+	}
+
+	// New files start with the deployment's default download log privacy; uploaders may change it afterwards
+	privateDownloadLog := 0
+	if d.GetPrivateDownloadLogDefault() {
+		privateDownloadLog = 1
+	}
+
 	_, err := d.db.Exec(`
 		INSERT INTO Files (
 			Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
 			AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
 			UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
-			UnlimitedDownloads, UnlimitedTime, RequireAuth
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			UnlimitedDownloads, UnlimitedTime, RequireAuth, Category, PrivateDownloadLog, SHA256,
+			BurnAfterDownload, AvailableFrom
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		file.Id, file.Name, file.Size, file.SHA1, file.PasswordHash, filePassword, file.HotlinkId,
 		file.ContentType, file.AwsBucket, file.ExpireAtString, file.ExpireAt,
 		file.PendingDeletion, file.SizeBytes, file.UploadDate, file.DownloadsRemaining,
 		file.DownloadCount, file.UserId, file.Comment, unlimitedDownloads, unlimitedTime, requireAuth,
+		file.Category, privateDownloadLog, file.SHA256, burnAfterDownload, file.ActivateAt,
 	)
 	return err
 }
@@ -92,13 +154,13 @@ func (d *Database) GetFileByID(id string) (*FileInfo, error) {
 		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
 		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
 		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
-		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
 		FROM Files WHERE Id = ? AND DeletedAt = 0`, id).Scan(
 		&file.Id, &file.Name, &file.Size, &file.SHA1, &file.PasswordHash, &filePassword,
 		&file.HotlinkId, &file.ContentType, &file.AwsBucket, &file.ExpireAtString,
 		&file.ExpireAt, &file.PendingDeletion, &file.SizeBytes, &file.UploadDate,
 		&file.DownloadsRemaining, &file.DownloadCount, &file.UserId, &comment,
-		&unlimitedDownloads, &unlimitedTime, &requireAuth, &file.DeletedAt, &file.DeletedBy,
+		&unlimitedDownloads, &unlimitedTime, &requireAuth, &file.DeletedAt, &file.DeletedBy, &file.Category, &file.SHA256, &file.DownloadLimitMBps, &file.BurnAfterDownload, &file.ScanStatus, &file.ActivateAt,
 	)
 
 	if err != nil {
@@ -131,7 +193,7 @@ func (d *Database) GetFilesByUser(userId int) ([]*FileInfo, error) {
 		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
 		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
 		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
-		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
 		FROM Files WHERE UserId = ? AND DeletedAt = 0 ORDER BY UploadDate DESC`, userId)
 	if err != nil {
 		return nil, err
@@ -147,7 +209,7 @@ func (d *Database) GetAllFiles() ([]*FileInfo, error) {
 		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
 		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
 		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
-		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
 		FROM Files WHERE DeletedAt = 0 ORDER BY UploadDate DESC`)
 	if err != nil {
 		return nil, err
@@ -157,6 +219,107 @@ func (d *Database) GetAllFiles() ([]*FileInfo, error) {
 	return scanFiles(rows)
 }
 
+// FileFilter represents server-side filtering options for the admin and user file lists
+type FileFilter struct {
+	SearchTerm string // Search in file name, comment and owner name/email
+	UserId     int    // Filter by owner (0 = all)
+	Status     string // "active", "scheduled", "expired", "public", "auth" or "" for all
+	Category   string // FileCategory constant or "" for all
+}
+
+// whereClause builds the SQL conditions for the filter. Columns are qualified
+// with the Files (f) and Users (u) table aliases.
+func (filter *FileFilter) whereClause() (string, []interface{}) {
+	clause := "f.DeletedAt = 0"
+	args := []interface{}{}
+
+	if filter == nil {
+		return clause, args
+	}
+
+	if filter.SearchTerm != "" {
+		clause += " AND (f.Name LIKE ? OR f.Comment LIKE ? OR u.Name LIKE ? OR u.Email LIKE ?)"
+		searchPattern := "%" + filter.SearchTerm + "%"
+		args = append(args, searchPattern, searchPattern, searchPattern, searchPattern)
+	}
+
+	if filter.UserId > 0 {
+		clause += " AND f.UserId = ?"
+		args = append(args, filter.UserId)
+	}
+
+	if filter.Category != "" {
+		clause += " AND f.Category = ?"
+		args = append(args, filter.Category)
+	}
+
+	now := time.Now().Unix()
+	switch filter.Status {
+	case "active":
+		clause += " AND (f.UnlimitedTime = 1 OR f.ExpireAt = 0 OR f.ExpireAt >= ?) AND (f.UnlimitedDownloads = 1 OR f.DownloadsRemaining > 0) AND COALESCE(f.AvailableFrom, 0) <= ?"
+		args = append(args, now, now)
+	case "scheduled":
+		clause += " AND COALESCE(f.AvailableFrom, 0) > ? AND (f.UnlimitedTime = 1 OR f.ExpireAt = 0 OR f.ExpireAt >= ?) AND (f.UnlimitedDownloads = 1 OR f.DownloadsRemaining > 0)"
+		args = append(args, now, now)
+	case "expired":
+		clause += " AND ((f.UnlimitedTime = 0 AND f.ExpireAt > 0 AND f.ExpireAt < ?) OR (f.UnlimitedDownloads = 0 AND f.DownloadsRemaining <= 0))"
+		args = append(args, now)
+	case "public":
+		clause += " AND f.RequireAuth = 0"
+	case "auth":
+		clause += " AND f.RequireAuth = 1"
+	}
+
+	return clause, args
+}
+
+// GetFilesFiltered returns non-deleted files matching the filter
+func (d *Database) GetFilesFiltered(filter *FileFilter) ([]*FileInfo, error) {
+	where, args := filter.whereClause()
+
+	rows, err := d.db.Query(`
+		SELECT f.Id, f.Name, f.Size, f.SHA1, f.PasswordHash, f.FilePasswordPlain, f.HotlinkId, f.ContentType,
+		       f.AwsBucket, f.ExpireAtString, f.ExpireAt, f.PendingDeletion, f.SizeBytes,
+		       f.UploadDate, f.DownloadsRemaining, f.DownloadCount, f.UserId, f.Comment,
+		       f.UnlimitedDownloads, f.UnlimitedTime, f.RequireAuth, f.DeletedAt, f.DeletedBy, f.Category, COALESCE(f.SHA256, ''), COALESCE(f.DownloadLimitMBps, 0), COALESCE(f.BurnAfterDownload, 0), COALESCE(f.ScanStatus, ''), COALESCE(f.AvailableFrom, 0)
+		FROM Files f
+		LEFT JOIN Users u ON f.UserId = u.Id
+		WHERE `+where+` ORDER BY f.UploadDate DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// ClaimFileDownload counts a download of a file, but only if the file is still in the Files table,
+// is live, has not expired and has downloads remaining when the update runs. Returns false if the file
+// must not be served; the check and the count are one statement, so concurrent downloads can't
+// use more downloads than the file allows.
+func (d *Database) ClaimFileDownload(fileId string) (bool, error) {
+	now := time.Now().Unix()
+	result, err := d.db.Exec(`
+		UPDATE Files
+		SET DownloadCount = DownloadCount + 1,
+		    DownloadsRemaining = CASE
+		        WHEN UnlimitedDownloads = 1 THEN DownloadsRemaining
+		        ELSE DownloadsRemaining - 1
+		    END
+		WHERE Id = ? AND DeletedAt = 0
+		  AND (UnlimitedDownloads = 1 OR DownloadsRemaining > 0)
+		  AND (UnlimitedTime = 1 OR ExpireAt = 0 OR ExpireAt >= ?)
+		  AND COALESCE(AvailableFrom, 0) <= ?`, fileId, now, now)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed > 0, nil
+}
+
 // UpdateFileDownloadCount increments download count and decrements remaining
 func (d *Database) UpdateFileDownloadCount(fileId string) error {
 	_, err := d.db.Exec(`
@@ -232,18 +395,71 @@ func (d *Database) UpdateFileRequireAuth(fileId string, requireAuth bool) error
 	return err
 }
 
-// DeleteFile soft-deletes a file (moves to trash for 5 days)
+// SetFileDownloadLimit sets a file's download bandwidth limit in MB/s (0 = the global limit)
+func (d *Database) SetFileDownloadLimit(fileId string, limitMBps int) error {
+	if limitMBps < 0 {
+		limitMBps = 0
+	}
+	_, err := d.db.Exec("UPDATE Files SET DownloadLimitMBps = ? WHERE Id = ?", limitMBps, fileId)
+	return err
+}
+
+// DeleteFile soft-deletes a file (moves to trash for the configured retention period)
 func (d *Database) DeleteFile(fileId string, userId int) error {
+	return d.DeleteFileWithRetention(fileId, userId, 0)
+}
+
+// DeleteFileWithRetention soft-deletes a file and keeps it in trash for retentionDays instead of
+// the global retention period (0 uses the global period). The file's size is released from its
+// owner's storage usage in the same transaction; deleting a file that is already in trash does nothing.
+func (d *Database) DeleteFileWithRetention(fileId string, userId int, retentionDays int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	now := time.Now().Unix()
-	_, err := d.db.Exec("UPDATE Files SET DeletedAt = ?, DeletedBy = ? WHERE Id = ?", now, userId, fileId)
-	return err
+	result, err := tx.Exec("UPDATE Files SET DeletedAt = ?, DeletedBy = ?, TrashRetentionDays = ? WHERE Id = ? AND DeletedAt = 0", now, userId, retentionDays, fileId)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	if err := adjustFileOwnerStorage(tx, fileId, -1); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // SoftDeleteUserFiles soft-deletes all files belonging to a user (moves to trash)
 // This is used when deleting a user account to preserve files in trash
 func (d *Database) SoftDeleteUserFiles(userId int, deletedBy int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	now := time.Now().Unix()
-	_, err := d.db.Exec("UPDATE Files SET DeletedAt = ?, DeletedBy = ? WHERE UserId = ? AND DeletedAt = 0", now, deletedBy, userId)
+	if _, err := tx.Exec("UPDATE Files SET DeletedAt = ?, DeletedBy = ? WHERE UserId = ? AND DeletedAt = 0", now, deletedBy, userId); err != nil {
+		return err
+	}
+	// None of the user's files count toward their storage any more
+	if _, err := tx.Exec("UPDATE Users SET StorageUsedMB = 0 WHERE Id = ?", userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// adjustFileOwnerStorage adds (sign 1) or releases (sign -1) a file's size to or from its
+// owner's storage usage, never going below zero
+func adjustFileOwnerStorage(tx *sql.Tx, fileId string, sign int) error {
+	_, err := tx.Exec(`
+		UPDATE Users SET StorageUsedMB = MAX(0, StorageUsedMB + ? * (SELECT `+fileStorageMBSQL+` FROM Files WHERE Id = ?))
+		WHERE Id = (SELECT UserId FROM Files WHERE Id = ?)`, sign, fileId, fileId)
 	return err
 }
 
@@ -266,7 +482,7 @@ func (d *Database) GetDeletedFiles() ([]*FileInfo, error) {
 		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
 		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
 		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
-		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
 		FROM Files WHERE DeletedAt > 0 ORDER BY DeletedAt DESC`)
 	if err != nil {
 		return nil, err
@@ -276,19 +492,22 @@ func (d *Database) GetDeletedFiles() ([]*FileInfo, error) {
 	return scanFiles(rows)
 }
 
-// GetOldDeletedFiles returns files deleted more than retentionDays ago for cleanup
+// GetOldDeletedFiles returns files whose time in trash is up for cleanup: files with a
+// per-file trash retention are kept that many days, all others retentionDays
 func (d *Database) GetOldDeletedFiles(retentionDays int) ([]*FileInfo, error) {
 	if retentionDays <= 0 {
 		retentionDays = 5 // default fallback
 	}
-	cutoffTime := time.Now().Add(-time.Duration(retentionDays) * 24 * time.Hour).Unix()
 
 	rows, err := d.db.Query(`
 		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
 		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
 		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
-		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy
-		FROM Files WHERE DeletedAt > 0 AND DeletedAt < ?`, cutoffTime)
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
+		FROM Files
+		WHERE DeletedAt > 0
+		  AND DeletedAt + (CASE WHEN COALESCE(TrashRetentionDays, 0) > 0 THEN TrashRetentionDays ELSE ? END) * 86400 < ?`,
+		retentionDays, time.Now().Unix())
 	if err != nil {
 		return nil, err
 	}
@@ -297,12 +516,43 @@ func (d *Database) GetOldDeletedFiles(retentionDays int) ([]*FileInfo, error) {
 	return scanFiles(rows)
 }
 
-// RestoreFile restores a file from trash
+// RestoreFile restores a file from trash and counts it toward its owner's storage usage again
 func (d *Database) RestoreFile(fileId string) error {
-	_, err := d.db.Exec("UPDATE Files SET DeletedAt = 0, DeletedBy = 0 WHERE Id = ?", fileId)
+	_, err := d.restoreFile(fileId, "")
 	return err
 }
 
+// UndoDeleteFile restores a file from trash like RestoreFile, but only if userId moved it
+// there. Returns false if the file is not in trash or was deleted by someone else.
+func (d *Database) UndoDeleteFile(fileId string, userId int) (bool, error) {
+	return d.restoreFile(fileId, fmt.Sprintf(" AND DeletedBy = %d", userId))
+}
+
+// restoreFile restores a file in trash that also matches condition, reporting whether it did
+func (d *Database) restoreFile(fileId, condition string) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE Files SET DeletedAt = 0, DeletedBy = 0, TrashRetentionDays = 0 WHERE Id = ? AND DeletedAt > 0"+condition, fileId)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if err := adjustFileOwnerStorage(tx, fileId, 1); err != nil {
+		return false, err
+	}
+	if err := clearBurnedFile(tx, fileId); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
 // GetExpiredFiles returns non-deleted files that should be deleted
 func (d *Database) GetExpiredFiles() ([]*FileInfo, error) {
 	now := time.Now().Unix()
@@ -311,7 +561,7 @@ func (d *Database) GetExpiredFiles() ([]*FileInfo, error) {
 		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
 		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
 		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
-		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
 		FROM Files
 		WHERE DeletedAt = 0 AND ((ExpireAt > 0 AND ExpireAt < ? AND UnlimitedTime = 0)
 		   OR (DownloadsRemaining <= 0 AND UnlimitedDownloads = 0))`, now)
@@ -323,23 +573,60 @@ func (d *Database) GetExpiredFiles() ([]*FileInfo, error) {
 	return scanFiles(rows)
 }
 
+// DefaultExpiredFileGraceHours is how long an expired file may stay out of trash before it is
+// flagged, when expired_file_grace_hours is not configured. Cleanup runs every 6 hours.
+const DefaultExpiredFileGraceHours = 12
+
+// GetExpiredFileGrace returns how long after expiring a file that is not in trash is flagged
+// as overdue (0 = detection disabled)
+func (d *Database) GetExpiredFileGrace() time.Duration {
+	hours := d.GetConfigInt("expired_file_grace_hours", DefaultExpiredFileGraceHours)
+	if hours <= 0 {
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetOverdueExpiredFiles returns files that expired more than grace ago but were not moved to
+// trash, which means the cleanup scheduler is not running or failing. Files that ran out of
+// downloads count from their last download.
+func (d *Database) GetOverdueExpiredFiles(grace time.Duration) ([]*FileInfo, error) {
+	cutoff := time.Now().Add(-grace).Unix()
+	rows, err := d.db.Query(`
+		SELECT Id, Name, Size, SHA1, PasswordHash, FilePasswordPlain, HotlinkId, ContentType,
+		       AwsBucket, ExpireAtString, ExpireAt, PendingDeletion, SizeBytes,
+		       UploadDate, DownloadsRemaining, DownloadCount, UserId, Comment,
+		       UnlimitedDownloads, UnlimitedTime, RequireAuth, DeletedAt, DeletedBy, Category, COALESCE(SHA256, ''), COALESCE(DownloadLimitMBps, 0), COALESCE(BurnAfterDownload, 0), COALESCE(ScanStatus, ''), COALESCE(AvailableFrom, 0)
+		FROM Files
+		WHERE DeletedAt = 0 AND ((ExpireAt > 0 AND ExpireAt < ? AND UnlimitedTime = 0)
+		   OR (DownloadsRemaining <= 0 AND UnlimitedDownloads = 0
+		       AND COALESCE((SELECT MAX(DownloadedAt) FROM DownloadLogs WHERE FileId = Files.Id), UploadDate) < ?))
+		ORDER BY ExpireAt`, cutoff, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
 // CalculateUserStorage calculates total storage used by a user (non-deleted files only)
 func (d *Database) CalculateUserStorage(userId int) (int64, error) {
-	var totalBytes sql.NullInt64
+	var totalMB sql.NullInt64
 
+	// Each file counts in started MB, the same way uploads and deletes adjust the stored usage
 	err := d.db.QueryRow(`
-		SELECT SUM(SizeBytes) FROM Files WHERE UserId = ? AND DeletedAt = 0`, userId).Scan(&totalBytes)
+		SELECT SUM(`+fileStorageMBSQL+`) FROM Files WHERE UserId = ? AND DeletedAt = 0`, userId).Scan(&totalMB)
 
 	if err != nil && err != sql.ErrNoRows {
 		return 0, err
 	}
 
-	if !totalBytes.Valid {
+	if !totalMB.Valid {
 		return 0, nil
 	}
 
-	// Convert bytes to MB
-	return totalBytes.Int64 / (1024 * 1024), nil
+	return totalMB.Int64, nil
 }
 
 // GetTotalFiles returns the count of all non-deleted files
@@ -356,7 +643,21 @@ func (d *Database) GetActiveFiles() (int, error) {
 
 	err := d.db.QueryRow(`
 		SELECT COUNT(*) FROM Files
-		WHERE DeletedAt = 0 AND (ExpireAt = 0 OR ExpireAt > ? OR UnlimitedTime = 1)
+		WHERE DeletedAt = 0 AND (ExpireAt = 0 OR ExpireAt >= ? OR UnlimitedTime = 1)
+		  AND (DownloadsRemaining > 0 OR UnlimitedDownloads = 1)`, now).Scan(&count)
+
+	return count, err
+}
+
+// CountActivePublicFiles counts active, non-expired files that can be downloaded without authentication
+func (d *Database) CountActivePublicFiles() (int, error) {
+	now := time.Now().Unix()
+	var count int
+
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM Files
+		WHERE DeletedAt = 0 AND RequireAuth = 0
+		  AND (ExpireAt = 0 OR ExpireAt >= ? OR UnlimitedTime = 1)
 		  AND (DownloadsRemaining > 0 OR UnlimitedDownloads = 1)`, now).Scan(&count)
 
 	return count, err
@@ -378,6 +679,37 @@ func CalculateFileSHA1(filePath string) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// CalculateFileSHA256 calculates SHA-256 hash of a file
+func CalculateFileSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetFileSHA256 returns the stored SHA-256 of a file, or "" if it hasn't been calculated yet
+func (d *Database) GetFileSHA256(fileId string) string {
+	var hash sql.NullString
+	if err := d.db.QueryRow("SELECT SHA256 FROM Files WHERE Id = ?", fileId).Scan(&hash); err != nil {
+		return ""
+	}
+	return hash.String
+}
+
+// SetFileSHA256 stores the SHA-256 of a file
+func (d *Database) SetFileSHA256(fileId, hash string) error {
+	_, err := d.db.Exec("UPDATE Files SET SHA256 = ? WHERE Id = ?", hash, fileId)
+	return err
+}
+
 // FormatFileSize formats bytes to human-readable size
 func FormatFileSize(bytes int64) string {
 	const unit = 1024
@@ -408,7 +740,7 @@ func scanFiles(rows *sql.Rows) ([]*FileInfo, error) {
 			&file.HotlinkId, &file.ContentType, &file.AwsBucket, &file.ExpireAtString,
 			&file.ExpireAt, &file.PendingDeletion, &file.SizeBytes, &file.UploadDate,
 			&file.DownloadsRemaining, &file.DownloadCount, &file.UserId, &comment,
-			&unlimitedDownloads, &unlimitedTime, &requireAuth, &file.DeletedAt, &file.DeletedBy,
+			&unlimitedDownloads, &unlimitedTime, &requireAuth, &file.DeletedAt, &file.DeletedBy, &file.Category, &file.SHA256, &file.DownloadLimitMBps, &file.BurnAfterDownload, &file.ScanStatus, &file.ActivateAt,
 		)
 		if err != nil {
 			return nil, err
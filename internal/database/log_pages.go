@@ -0,0 +1,58 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import "fmt"
+
+// DefaultHistoryPageSize is how many download or email log entries the file history shows at a
+// time, when history_page_size is not configured
+const DefaultHistoryPageSize = 50
+
+// MaxHistoryPageSize caps the page size a client or administrator can ask for
+const MaxHistoryPageSize = 500
+
+// LogPage selects one page of a file's download or email history, newest first
+type LogPage struct {
+	Limit  int // 0 = all entries
+	Offset int
+	From   int64 // Unix time, 0 = no lower bound
+	To     int64 // Unix time (exclusive), 0 = no upper bound
+}
+
+// GetHistoryPageSize returns the configured page size of the file history
+func (d *Database) GetHistoryPageSize() int {
+	size := d.GetConfigInt("history_page_size", DefaultHistoryPageSize)
+	if size < 1 {
+		return DefaultHistoryPageSize
+	}
+	if size > MaxHistoryPageSize {
+		return MaxHistoryPageSize
+	}
+	return size
+}
+
+// where returns the date conditions of the page on a timestamp column, to append to a WHERE clause
+func (p LogPage) where(column string) (string, []interface{}) {
+	clause := ""
+	var args []interface{}
+	if p.From > 0 {
+		clause += " AND " + column + " >= ?"
+		args = append(args, p.From)
+	}
+	if p.To > 0 {
+		clause += " AND " + column + " < ?"
+		args = append(args, p.To)
+	}
+	return clause, args
+}
+
+// limit returns the LIMIT/OFFSET clause of the page
+func (p LogPage) limit() string {
+	if p.Limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", p.Limit, max(p.Offset, 0))
+}
@@ -0,0 +1,71 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import "errors"
+
+// GetStripMetadataDefault reports whether uploads have their metadata removed unless the
+// uploader turns it off (default off)
+func (d *Database) GetStripMetadataDefault() bool {
+	value, _ := d.GetConfigValue("strip_metadata_default")
+	return value == "true"
+}
+
+// IsDocumentMetadataStrippingEnabled reports whether metadata stripping also removes the
+// author and company properties of Office documents, not just image metadata (default off)
+func (d *Database) IsDocumentMetadataStrippingEnabled() bool {
+	value, _ := d.GetConfigValue("strip_document_metadata")
+	return value == "true"
+}
+
+// IsFileMetadataStripped reports whether a file's metadata was removed after upload
+func (d *Database) IsFileMetadataStripped(fileId string) bool {
+	var stripped int
+	err := d.db.QueryRow("SELECT COALESCE(MetadataStripped, 0) FROM Files WHERE Id = ?", fileId).Scan(&stripped)
+	return err == nil && stripped == 1
+}
+
+// SetFileMetadataStripped records that a file's metadata was removed, with the size and SHA1
+// of the cleaned file. The SHA-256 is cleared so it is calculated again, and the owner's storage
+// usage follows the new size if the file isn't in trash.
+func (d *Database) SetFileMetadataStripped(fileId string, sizeBytes int64, sha1 string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Write first, so the transaction holds the write lock before it reads
+	result, err := tx.Exec("UPDATE Files SET MetadataStripped = 1 WHERE Id = ?", fileId)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("file not found")
+	}
+
+	var deletedAt int64
+	if err := tx.QueryRow("SELECT COALESCE(DeletedAt, 0) FROM Files WHERE Id = ?", fileId).Scan(&deletedAt); err != nil {
+		return err
+	}
+
+	// Storage is counted in whole megabytes per file, like when the file was uploaded
+	if deletedAt == 0 {
+		if err := adjustFileOwnerStorage(tx, fileId, -1); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE Files SET SizeBytes = ?, Size = ?, SHA1 = ?, SHA256 = '' WHERE Id = ?",
+		sizeBytes, FormatFileSize(sizeBytes), sha1, fileId); err != nil {
+		return err
+	}
+	if deletedAt == 0 {
+		if err := adjustFileOwnerStorage(tx, fileId, 1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
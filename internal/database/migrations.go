@@ -45,6 +45,332 @@ func (d *Database) RunMigrations() error {
 		return err
 	}
 
+	// Add upload approval flag to Teams table
+	if err := d.addColumnIfNotExists("Teams", "RequireApproval", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Track bytes actually served (partial and aborted downloads included)
+	if err := d.addColumnIfNotExists("DownloadLogs", "BytesSent", "INTEGER"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "BytesServed", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add SHA-256 checksum shown to recipients for integrity verification
+	if err := d.addColumnIfNotExists("Files", "SHA256", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add download availability schedule columns to Files table
+	if err := d.addColumnIfNotExists("Files", "AvailableFrom", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "AvailableUntil", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "DailyStart", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "DailyEnd", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "ScheduleDays", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "ScheduleTimezone", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add dedup key and status to EmailLogs so retried sends update one row
+	if err := d.addColumnIfNotExists("EmailLogs", "DedupKey", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("EmailLogs", "Status", "TEXT DEFAULT 'sent'"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("EmailLogs", "Attempts", "INTEGER DEFAULT 1"); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_emaillogs_dedupkey ON EmailLogs(DedupKey) WHERE DedupKey <> ''"); err != nil {
+		return err
+	}
+
+	// Track who created download accounts and when they were reactivated (idle account deactivation)
+	if err := d.addColumnIfNotExists("DownloadAccounts", "CreatedBy", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("DownloadAccounts", "ReactivatedAt", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add per-file opt-in for the "request access" flow on auth-required files
+	if err := d.addColumnIfNotExists("Files", "AccessRequestsEnabled", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add per-file opt-in for expiry reminders to email recipients
+	if err := d.addColumnIfNotExists("Files", "ExpiryRemindersEnabled", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add stored content category to files and classify existing uploads
+	if err := d.addColumnIfNotExists("Files", "Category", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec("CREATE INDEX IF NOT EXISTS idx_files_category ON Files(Category)"); err != nil {
+		return err
+	}
+	if err := d.backfillFileCategories(); err != nil {
+		return err
+	}
+
+	// Add per-file option to keep downloader IPs and user agents out of the download log
+	if err := d.addColumnIfNotExists("Files", "PrivateDownloadLog", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add per-file terms requirement and record accepted terms in the download log
+	if err := d.addColumnIfNotExists("Files", "RequireTerms", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("DownloadLogs", "TermsVersion", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("DownloadLogs", "TermsAcceptedAt", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add per-file limit on how many people may have the splash page open at once
+	if err := d.addColumnIfNotExists("Files", "MaxConcurrentViewers", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add per-file template for the name a file is downloaded as
+	if err := d.addColumnIfNotExists("Files", "FilenameTemplate", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add per-file option to let visitors of an expired link ask for a new one
+	if err := d.addColumnIfNotExists("Files", "ReshareRequestsEnabled", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add per-file trash retention chosen at delete time (0 = the global retention)
+	if err := d.addColumnIfNotExists("Files", "TrashRetentionDays", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add temporary download blocks after unusual download activity, and the downloader's
+	// country as reported by the reverse proxy
+	if err := d.addColumnIfNotExists("Files", "DownloadsBlockedUntil", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "DownloadBlockReason", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("DownloadLogs", "Country", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add per-file message shown on the expired page
+	if err := d.addColumnIfNotExists("Files", "ExpiredMessage", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add per-request branding of the upload page
+	if err := d.addColumnIfNotExists("FileRequests", "BrandName", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("FileRequests", "BrandColor", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("FileRequests", "TeamId", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Record which files had their metadata (EXIF, document properties) removed on upload
+	if err := d.addColumnIfNotExists("Files", "MetadataStripped", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add per-file option to let the recipient retry a broken-off download without it counting again
+	if err := d.addColumnIfNotExists("Files", "DownloadRetryEnabled", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add integrity scan results (see StartIntegrityScanScheduler)
+	if err := d.addColumnIfNotExists("Files", "LastVerifiedAt", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "IntegrityStatus", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "IntegrityDetail", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add per-user notification preferences (JSON, see NotificationCategories)
+	if err := d.addColumnIfNotExists("Users", "NotificationPrefs", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add per-user upload windows (daily hours, weekdays and timezone uploads are accepted)
+	if err := d.addColumnIfNotExists("Users", "UploadWindowStart", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Users", "UploadWindowEnd", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Users", "UploadWindowDays", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Users", "UploadWindowTimezone", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add per-file download bandwidth limit (0 = the global limit)
+	if err := d.addColumnIfNotExists("Files", "DownloadLimitMBps", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add burn after download (the file is removed after its first complete download)
+	if err := d.addColumnIfNotExists("Files", "BurnAfterDownload", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add the Mailgun settings of the email provider configuration, which the provider is loaded with
+	if err := d.addColumnIfNotExists("EmailProviderConfig", "MailgunDomain", "TEXT"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("EmailProviderConfig", "MailgunRegion", "TEXT"); err != nil {
+		return err
+	}
+
+	// Add the delivery order of the email providers, which fail over to each other, and their
+	// delivery counts
+	for _, column := range []struct{ name, def string }{
+		{"Priority", "INTEGER DEFAULT 0"},
+		{"SentCount", "INTEGER DEFAULT 0"},
+		{"FailedCount", "INTEGER DEFAULT 0"},
+		{"LastSentAt", "INTEGER DEFAULT 0"},
+		{"LastError", "TEXT DEFAULT ''"},
+		{"LastErrorAt", "INTEGER DEFAULT 0"},
+	} {
+		if err := d.addColumnIfNotExists("EmailProviderConfig", column.name, column.def); err != nil {
+			return err
+		}
+	}
+
+	// Add the provider that delivered a file email
+	if err := d.addColumnIfNotExists("EmailLogs", "Provider", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add the version number of a file's current content, see file_versions
+	if err := d.addColumnIfNotExists("Files", "Version", "INTEGER DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Files", "VersionUploadedBy", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add the ClamAV scan result of uploads, see virus_scans
+	for _, column := range []struct{ name, def string }{
+		{"ScanStatus", "TEXT DEFAULT ''"},
+		{"ScanSignature", "TEXT DEFAULT ''"},
+		{"ScannedAt", "INTEGER DEFAULT 0"},
+	} {
+		if err := d.addColumnIfNotExists("Files", column.name, column.def); err != nil {
+			return err
+		}
+	}
+
+	// Add the download notification option and its throttling state, see download_notifications
+	for _, column := range []struct{ name, def string }{
+		{"NotifyOnDownload", "INTEGER DEFAULT 1"},
+		{"DownloadNotifiedLogId", "INTEGER DEFAULT 0"},
+		{"DownloadNoticeWindowStart", "INTEGER DEFAULT 0"},
+		{"DownloadNoticeCount", "INTEGER DEFAULT 0"},
+	} {
+		if err := d.addColumnIfNotExists("Files", column.name, column.def); err != nil {
+			return err
+		}
+	}
+
+	// Add custom link names of files, see file_slugs
+	if err := d.addColumnIfNotExists("Files", "Slug", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_files_slug ON Files(Slug) WHERE Slug <> ''"); err != nil {
+		return err
+	}
+
+	// Add the splash page template of files, and record acceptance of the legal notice some
+	// templates show in the download log, see splash_templates
+	if err := d.addColumnIfNotExists("Files", "SplashTemplate", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("DownloadLogs", "NoticeAcceptedAt", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("DownloadLogs", "NoticeAcceptedIP", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add the marker of files whose link was emailed signed, see share_links
+	if err := d.addColumnIfNotExists("Files", "SignedLinkSent", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add the super admin behind impersonation sessions, see auth.CreateImpersonationSession
+	if err := d.addColumnIfNotExists("Sessions", "ImpersonatorId", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Sessions", "ImpersonatorSessionId", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Add when a login session was created and whether it is a "Keep me logged in" session.
+	// Sessions of earlier versions were remember-me sessions if they lasted over two days.
+	var hasRememberMe int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('Sessions') WHERE name = 'RememberMe'").Scan(&hasRememberMe); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Sessions", "RememberMe", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if hasRememberMe == 0 {
+		if _, err := d.db.Exec("UPDATE Sessions SET RememberMe = 1 WHERE ValidUntil > ?", time.Now().Add(48*time.Hour).Unix()); err != nil {
+			return err
+		}
+	}
+	if err := d.addColumnIfNotExists("Sessions", "CreatedAt", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add when and where a login session was last used, for the Active Sessions list
+	if err := d.addColumnIfNotExists("Sessions", "LastSeen", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Sessions", "IPAddress", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.addColumnIfNotExists("Sessions", "UserAgent", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Keep the TOTP encryption key out of the database, see totp
+	if err := d.moveTOTPKeyOutOfDatabase(); err != nil {
+		return err
+	}
+
+	// Encrypt TOTP secrets stored in plain text by earlier versions
+	if err := d.encryptPlaintextTOTPSecrets(); err != nil {
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
@@ -129,7 +455,20 @@ func (d *Database) SoftDeleteDownloadAccount(accountId int, deletedBy string) er
 	return err
 }
 
-// PermanentlyDeleteOldUsers permanently deletes users that have been soft-deleted for more than 90 days
+// DefaultDeletedAccountRetentionDays is used when deleted_account_retention_days is not configured
+const DefaultDeletedAccountRetentionDays = 90
+
+// GetDeletedAccountRetentionDays returns how long soft-deleted users and download accounts are
+// kept before they are deleted permanently
+func (d *Database) GetDeletedAccountRetentionDays() int {
+	days := d.GetConfigInt("deleted_account_retention_days", DefaultDeletedAccountRetentionDays)
+	if days < 1 {
+		return DefaultDeletedAccountRetentionDays
+	}
+	return days
+}
+
+// PermanentlyDeleteOldUsers permanently deletes users that have been soft-deleted for more than daysOld days
 func (d *Database) PermanentlyDeleteOldUsers(daysOld int) (int, error) {
 	if daysOld <= 0 {
 		daysOld = 90
@@ -167,7 +506,7 @@ func (d *Database) PermanentlyDeleteOldUsers(daysOld int) (int, error) {
 	return int(affected), nil
 }
 
-// PermanentlyDeleteOldDownloadAccounts permanently deletes download accounts that have been soft-deleted for more than 90 days
+// PermanentlyDeleteOldDownloadAccounts permanently deletes download accounts that have been soft-deleted for more than daysOld days
 func (d *Database) PermanentlyDeleteOldDownloadAccounts(daysOld int) (int, error) {
 	if daysOld <= 0 {
 		daysOld = 90
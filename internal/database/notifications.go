@@ -0,0 +1,206 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Notification categories users can choose channels for
+const (
+	NotifyDownloads       = "downloads"
+	NotifyFileRequests    = "file_requests"
+	NotifyUploadReceipts  = "upload_receipts"
+	NotifyExpiry          = "expiry"
+	NotifyTeams           = "teams"
+	NotifyApprovals       = "approvals"
+	NotifyAccessRequests  = "access_requests"
+	NotifyReshareRequests = "reshare_requests"
+	NotifyDownloadBlocks  = "download_blocks"
+	NotifyAdminReports    = "admin_reports"
+	NotifySecurity        = "security"
+)
+
+// maxUserNotifications is how many in-app notifications are kept per user
+const maxUserNotifications = 200
+
+// NotificationCategory describes a notification category on the preferences page
+type NotificationCategory struct {
+	Key         string
+	Name        string
+	Description string
+	AdminOnly   bool
+	Required    bool // security notifications can't be turned off
+}
+
+// NotificationCategories lists the categories in the order they are shown
+var NotificationCategories = []NotificationCategory{
+	{Key: NotifyDownloads, Name: "Downloads", Description: "Someone downloaded one of your files"},
+	{Key: NotifyFileRequests, Name: "File requests", Description: "A file was uploaded through one of your upload request links"},
+	{Key: NotifyUploadReceipts, Name: "Upload confirmations", Description: "Confirmation that a large upload (over 5 GB) finished"},
+	{Key: NotifyExpiry, Name: "Expiry changes", Description: "The expiry policy shortened how long your files are kept"},
+	{Key: NotifyTeams, Name: "Teams", Description: "You were added to a team"},
+	{Key: NotifyApprovals, Name: "Upload approvals", Description: "Uploads waiting for your approval, and decisions on your uploads"},
+	{Key: NotifyAccessRequests, Name: "Access requests", Description: "Someone asked for access to one of your files"},
+	{Key: NotifyReshareRequests, Name: "New link requests", Description: "A visitor of an expired link asked you for a new one"},
+	{Key: NotifyDownloadBlocks, Name: "Download locks", Description: "Downloads of one of your files were locked after unusual activity"},
+	{Key: NotifyAdminReports, Name: "Admin reports", Description: "Download accounts deactivated for inactivity, and users promoted by other admins", AdminOnly: true},
+	{Key: NotifySecurity, Name: "Security", Description: "Password and email address changes on your account, and malware found in your uploads", Required: true},
+}
+
+// NotificationPreference is a user's choice of channels for one category
+type NotificationPreference struct {
+	Email bool `json:"email"`
+	InApp bool `json:"inApp"`
+}
+
+// UserNotification is an in-app notification shown on the user's notifications page
+type UserNotification struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"userId"`
+	Category  string `json:"category"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Link      string `json:"link"`
+	CreatedAt int64  `json:"createdAt"`
+	ReadAt    int64  `json:"readAt"`
+}
+
+// DefaultNotificationPreferences returns the preferences of a user who never changed them: everything on
+func DefaultNotificationPreferences() map[string]NotificationPreference {
+	prefs := make(map[string]NotificationPreference, len(NotificationCategories))
+	for _, category := range NotificationCategories {
+		prefs[category.Key] = NotificationPreference{Email: true, InApp: true}
+	}
+	return prefs
+}
+
+// GetNotificationPreferences returns a user's notification preferences, with defaults for
+// categories the user never set. Security notifications are always on.
+func (d *Database) GetNotificationPreferences(userId int) map[string]NotificationPreference {
+	prefs := DefaultNotificationPreferences()
+
+	var value sql.NullString
+	if err := d.db.QueryRow("SELECT NotificationPrefs FROM Users WHERE Id = ?", userId).Scan(&value); err != nil {
+		return prefs
+	}
+	if value.String != "" {
+		stored := make(map[string]NotificationPreference)
+		if err := json.Unmarshal([]byte(value.String), &stored); err != nil {
+			log.Printf("Warning: Invalid notification preferences for user %d: %v", userId, err)
+		}
+		for key, pref := range stored {
+			if _, known := prefs[key]; known {
+				prefs[key] = pref
+			}
+		}
+	}
+
+	prefs[NotifySecurity] = NotificationPreference{Email: true, InApp: true}
+	return prefs
+}
+
+// SetNotificationPreferences stores a user's notification preferences. Unknown categories are
+// dropped and security notifications stay on.
+func (d *Database) SetNotificationPreferences(userId int, prefs map[string]NotificationPreference) error {
+	stored := make(map[string]NotificationPreference)
+	for _, category := range NotificationCategories {
+		if pref, ok := prefs[category.Key]; ok && !category.Required {
+			stored[category.Key] = pref
+		}
+	}
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec("UPDATE Users SET NotificationPrefs = ? WHERE Id = ?", string(value), userId)
+	return err
+}
+
+// NotifyUser records an in-app notification for the user if they want one for the category,
+// and reports whether they also want the email. Callers check the result before sending it.
+func (d *Database) NotifyUser(userId int, category, title, message, link string) bool {
+	pref, ok := d.GetNotificationPreferences(userId)[category]
+	if !ok {
+		return true
+	}
+
+	if pref.InApp {
+		if err := d.CreateUserNotification(userId, category, title, message, link); err != nil {
+			log.Printf("Warning: Could not save notification for user %d: %v", userId, err)
+		}
+	}
+	return pref.Email
+}
+
+// NotifyUserByEmail is NotifyUser for notifications addressed by email. Addresses that don't
+// belong to a user (e.g. designated approvers without an account) always get the email.
+func (d *Database) NotifyUserByEmail(emailAddr, category, title, message, link string) bool {
+	user, err := d.GetUserByEmail(emailAddr)
+	if err != nil || user == nil {
+		return true
+	}
+	return d.NotifyUser(user.Id, category, title, message, link)
+}
+
+// CreateUserNotification adds an in-app notification, keeping only the user's newest ones
+func (d *Database) CreateUserNotification(userId int, category, title, message, link string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO UserNotifications (UserId, Category, Title, Message, Link, CreatedAt)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		userId, category, title, message, link, time.Now().Unix(),
+	)
+	if err != nil {
+		return err
+	}
+
+	d.db.Exec(`
+		DELETE FROM UserNotifications
+		WHERE UserId = ? AND Id NOT IN (
+			SELECT Id FROM UserNotifications WHERE UserId = ? ORDER BY Id DESC LIMIT ?
+		)`, userId, userId, maxUserNotifications)
+	return nil
+}
+
+// GetUserNotifications returns a user's newest in-app notifications
+func (d *Database) GetUserNotifications(userId, limit int) ([]*UserNotification, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, UserId, Category, Title, COALESCE(Message, ''), COALESCE(Link, ''), CreatedAt, ReadAt
+		FROM UserNotifications
+		WHERE UserId = ?
+		ORDER BY Id DESC
+		LIMIT ?`, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*UserNotification
+	for rows.Next() {
+		n := &UserNotification{}
+		if err := rows.Scan(&n.Id, &n.UserId, &n.Category, &n.Title, &n.Message, &n.Link, &n.CreatedAt, &n.ReadAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnreadUserNotifications returns how many of a user's in-app notifications are unread
+func (d *Database) CountUnreadUserNotifications(userId int) int {
+	var count int
+	d.db.QueryRow("SELECT COUNT(*) FROM UserNotifications WHERE UserId = ? AND ReadAt = 0", userId).Scan(&count)
+	return count
+}
+
+// MarkUserNotificationsRead marks all of a user's in-app notifications as read
+func (d *Database) MarkUserNotificationsRead(userId int) error {
+	_, err := d.db.Exec("UPDATE UserNotifications SET ReadAt = ? WHERE UserId = ? AND ReadAt = 0", time.Now().Unix(), userId)
+	return err
+}
@@ -16,6 +16,9 @@ const (
 	AccountTypeUser            = "user"
 	AccountTypeDownloadAccount = "download_account"
 	ResetTokenDuration         = 1 * time.Hour
+
+	// DefaultWelcomeEmailDailyCap is used when welcome_email_daily_cap is not configured
+	DefaultWelcomeEmailDailyCap = 3
 )
 
 // PasswordResetToken represents a password reset token
@@ -96,6 +99,12 @@ func (db *Database) MarkPasswordResetTokenUsed(token string) error {
 	return err
 }
 
+// DeletePasswordResetToken removes a token whose email could not be delivered
+func (db *Database) DeletePasswordResetToken(token string) error {
+	_, err := db.Exec("DELETE FROM PasswordResetTokens WHERE Token = ?", token)
+	return err
+}
+
 // CleanupExpiredResetTokens removes expired tokens
 func (db *Database) CleanupExpiredResetTokens() error {
 	_, err := db.Exec(`
@@ -142,3 +151,48 @@ func (db *Database) ResetPasswordWithToken(token, newPassword string) error {
 	// Mark token as used
 	return db.MarkPasswordResetTokenUsed(token)
 }
+
+// PendingPasswordSetup is a user who was sent a welcome email but never set a password
+type PendingPasswordSetup struct {
+	UserId      int
+	Name        string
+	Email       string
+	CreatedAt   int64
+	LastSentAt  int64
+	SentLast24h int
+}
+
+// GetUsersPendingPasswordSetup returns active users who have never used any of their
+// password setup tokens and have never logged in
+func (db *Database) GetUsersPendingPasswordSetup() ([]*PendingPasswordSetup, error) {
+	rows, err := db.Query(`
+		SELECT u.Id, u.Name, u.Email, u.CreatedAt, MAX(t.CreatedAt),
+		       SUM(CASE WHEN t.CreatedAt >= ? THEN 1 ELSE 0 END)
+		FROM Users u
+		INNER JOIN PasswordResetTokens t ON t.Email = u.Email AND t.AccountType = ?
+		WHERE u.IsActive = 1 AND COALESCE(u.DeletedAt, 0) = 0 AND COALESCE(u.LastOnline, 0) = 0
+		GROUP BY u.Id
+		HAVING MAX(t.Used) = 0
+		ORDER BY u.CreatedAt DESC`,
+		time.Now().Add(-24*time.Hour).Unix(), AccountTypeUser,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []*PendingPasswordSetup
+	for rows.Next() {
+		p := &PendingPasswordSetup{}
+		if err := rows.Scan(&p.UserId, &p.Name, &p.Email, &p.CreatedAt, &p.LastSentAt, &p.SentLast24h); err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// GetWelcomeEmailDailyCap returns how many setup emails one user may be sent per 24 hours (0 = unlimited)
+func (db *Database) GetWelcomeEmailDailyCap() int {
+	return db.GetConfigInt("welcome_email_daily_cap", DefaultWelcomeEmailDailyCap)
+}
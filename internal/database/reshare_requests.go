@@ -0,0 +1,138 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ReshareRequest is a visitor of an expired link asking the file owner for a new one
+type ReshareRequest struct {
+	Id          int    `json:"id"`
+	FileId      string `json:"fileId"`
+	Email       string `json:"email"`
+	Name        string `json:"name"`
+	Message     string `json:"message"`
+	Reason      string `json:"reason"` // FileExpiredByTime or FileExpiredByDownloads
+	RequesterIP string `json:"-"`
+	RequestedAt int64  `json:"requestedAt"`
+}
+
+// IsFileReshareRequestsEnabled reports whether visitors of a file's expired link may ask for a new one
+func (d *Database) IsFileReshareRequestsEnabled(fileId string) bool {
+	var enabled int
+	err := d.db.QueryRow("SELECT COALESCE(ReshareRequestsEnabled, 0) FROM Files WHERE Id = ?", fileId).Scan(&enabled)
+	return err == nil && enabled == 1
+}
+
+// SetFileReshareRequestsEnabled turns the "request a new link" button on a file's expired page on or off
+func (d *Database) SetFileReshareRequestsEnabled(fileId string, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := d.db.Exec("UPDATE Files SET ReshareRequestsEnabled = ? WHERE Id = ?", value, fileId)
+	return err
+}
+
+// GetExpiredTrashedFile returns a file the expiry cleanup moved to trash, so its link can still
+// show the expired page. Files deleted by a user are not returned.
+func (d *Database) GetExpiredTrashedFile(id string) (*FileInfo, error) {
+	file := &FileInfo{}
+	var unlimitedDownloads, unlimitedTime int
+	err := d.db.QueryRow(`
+		SELECT Id, Name, UserId, ExpireAt, DownloadsRemaining, UnlimitedDownloads, UnlimitedTime, DeletedAt
+		FROM Files WHERE Id = ? AND DeletedAt > 0 AND DeletedBy = 0`, id).Scan(
+		&file.Id, &file.Name, &file.UserId, &file.ExpireAt, &file.DownloadsRemaining,
+		&unlimitedDownloads, &unlimitedTime, &file.DeletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("file not found")
+		}
+		return nil, err
+	}
+	file.UnlimitedDownloads = unlimitedDownloads == 1
+	file.UnlimitedTime = unlimitedTime == 1
+
+	if file.ExpiredReason(time.Now()) == "" {
+		return nil, errors.New("file not found")
+	}
+	return file, nil
+}
+
+// CreateReshareRequest records a request for a new link. A repeated request from the same
+// email for the same file within a day is not recorded again; created reports whether it was new.
+func (d *Database) CreateReshareRequest(fileId, email, name, message, reason, requesterIP string) (request *ReshareRequest, created bool, err error) {
+	email = normalizeAccessEmail(email)
+	now := time.Now().Unix()
+
+	var existing int
+	err = d.db.QueryRow("SELECT COUNT(*) FROM ReshareRequests WHERE FileId = ? AND Email = ? AND RequestedAt >= ?",
+		fileId, email, now-24*60*60).Scan(&existing)
+	if err != nil {
+		return nil, false, err
+	}
+
+	request = &ReshareRequest{
+		FileId:      fileId,
+		Email:       email,
+		Name:        name,
+		Message:     message,
+		Reason:      reason,
+		RequesterIP: requesterIP,
+		RequestedAt: now,
+	}
+	if existing > 0 {
+		return request, false, nil
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO ReshareRequests (FileId, Email, Name, Message, Reason, RequesterIP, RequestedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		request.FileId, request.Email, request.Name, request.Message, request.Reason, request.RequesterIP, request.RequestedAt,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	id, _ := result.LastInsertId()
+	request.Id = int(id)
+	return request, true, nil
+}
+
+// CountReshareRequestsFromIP counts new link requests sent from an IP address since the given time
+func (d *Database) CountReshareRequestsFromIP(requesterIP string, since int64) int {
+	var count int
+	d.db.QueryRow("SELECT COUNT(*) FROM ReshareRequests WHERE RequesterIP = ? AND RequestedAt >= ?",
+		requesterIP, since).Scan(&count)
+	return count
+}
+
+// GetReshareRequestsByFileID returns the new link requests for a file, newest first
+func (d *Database) GetReshareRequestsByFileID(fileId string) ([]*ReshareRequest, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, FileId, Email, COALESCE(Name, ''), COALESCE(Message, ''), Reason, COALESCE(RequesterIP, ''), RequestedAt
+		FROM ReshareRequests
+		WHERE FileId = ?
+		ORDER BY RequestedAt DESC`, fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*ReshareRequest
+	for rows.Next() {
+		request := &ReshareRequest{}
+		if err := rows.Scan(&request.Id, &request.FileId, &request.Email, &request.Name, &request.Message,
+			&request.Reason, &request.RequesterIP, &request.RequestedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
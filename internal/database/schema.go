@@ -50,6 +50,45 @@ CREATE TABLE IF NOT EXISTS Files (
 	RequireAuth INTEGER DEFAULT 0,
 	DeletedAt INTEGER DEFAULT 0,
 	DeletedBy INTEGER DEFAULT 0,
+	BytesServed INTEGER DEFAULT 0,
+	SHA256 TEXT DEFAULT '',
+	AvailableFrom INTEGER DEFAULT 0,
+	AvailableUntil INTEGER DEFAULT 0,
+	DailyStart TEXT DEFAULT '',
+	DailyEnd TEXT DEFAULT '',
+	ScheduleDays TEXT DEFAULT '',
+	ScheduleTimezone TEXT DEFAULT '',
+	AccessRequestsEnabled INTEGER DEFAULT 0,
+	ExpiryRemindersEnabled INTEGER DEFAULT 0,
+	Category TEXT DEFAULT '',
+	PrivateDownloadLog INTEGER DEFAULT 0,
+	RequireTerms INTEGER DEFAULT 0,
+	MaxConcurrentViewers INTEGER DEFAULT 0,
+	FilenameTemplate TEXT DEFAULT '',
+	ReshareRequestsEnabled INTEGER DEFAULT 0,
+	TrashRetentionDays INTEGER DEFAULT 0,
+	DownloadsBlockedUntil INTEGER DEFAULT 0,
+	DownloadBlockReason TEXT DEFAULT '',
+	ExpiredMessage TEXT DEFAULT '',
+	MetadataStripped INTEGER DEFAULT 0,
+	DownloadRetryEnabled INTEGER DEFAULT 0,
+	LastVerifiedAt INTEGER DEFAULT 0,
+	IntegrityStatus TEXT DEFAULT '',
+	IntegrityDetail TEXT DEFAULT '',
+	DownloadLimitMBps INTEGER DEFAULT 0,
+	BurnAfterDownload INTEGER DEFAULT 0,
+	Version INTEGER DEFAULT 1,
+	VersionUploadedBy INTEGER DEFAULT 0,
+	ScanStatus TEXT DEFAULT '',
+	ScanSignature TEXT DEFAULT '',
+	ScannedAt INTEGER DEFAULT 0,
+	NotifyOnDownload INTEGER DEFAULT 1,
+	DownloadNotifiedLogId INTEGER DEFAULT 0,
+	DownloadNoticeWindowStart INTEGER DEFAULT 0,
+	DownloadNoticeCount INTEGER DEFAULT 0,
+	Slug TEXT DEFAULT '',
+	SplashTemplate TEXT DEFAULT '',
+	SignedLinkSent INTEGER DEFAULT 0,
 	FOREIGN KEY (UserId) REFERENCES Users(Id)
 );
 
@@ -62,7 +101,9 @@ CREATE TABLE IF NOT EXISTS DownloadAccounts (
 	CreatedAt INTEGER NOT NULL,
 	LastUsed INTEGER DEFAULT 0,
 	DownloadCount INTEGER DEFAULT 0,
-	IsActive INTEGER DEFAULT 1
+	IsActive INTEGER DEFAULT 1,
+	CreatedBy INTEGER DEFAULT 0,
+	ReactivatedAt INTEGER DEFAULT 0
 );
 
 -- File Requests table (for requesting file uploads)
@@ -77,6 +118,9 @@ CREATE TABLE IF NOT EXISTS FileRequests (
 	IsActive INTEGER DEFAULT 1,
 	MaxFileSize INTEGER DEFAULT 0,
 	AllowedFileTypes TEXT,
+	BrandName TEXT DEFAULT '',
+	BrandColor TEXT DEFAULT '',
+	TeamId INTEGER DEFAULT 0,
 	FOREIGN KEY (UserId) REFERENCES Users(Id)
 );
 
@@ -92,6 +136,12 @@ CREATE TABLE IF NOT EXISTS DownloadLogs (
 	FileSize INTEGER,
 	FileName TEXT,
 	IsAuthenticated INTEGER DEFAULT 0,
+	BytesSent INTEGER,
+	TermsVersion INTEGER DEFAULT 0,
+	TermsAcceptedAt INTEGER DEFAULT 0,
+	Country TEXT DEFAULT '',
+	NoticeAcceptedAt INTEGER DEFAULT 0,
+	NoticeAcceptedIP TEXT DEFAULT '',
 	FOREIGN KEY (FileId) REFERENCES Files(Id),
 	FOREIGN KEY (DownloadAccountId) REFERENCES DownloadAccounts(Id)
 );
@@ -106,6 +156,9 @@ CREATE TABLE IF NOT EXISTS EmailLogs (
 	SentAt INTEGER NOT NULL,
 	FileName TEXT,
 	FileSize INTEGER,
+	DedupKey TEXT DEFAULT '',
+	Status TEXT DEFAULT 'sent',
+	Attempts INTEGER DEFAULT 1,
 	FOREIGN KEY (FileId) REFERENCES Files(Id),
 	FOREIGN KEY (SenderUserId) REFERENCES Users(Id)
 );
@@ -115,6 +168,13 @@ CREATE TABLE IF NOT EXISTS Sessions (
 	Id TEXT PRIMARY KEY,
 	UserId INTEGER NOT NULL,
 	ValidUntil INTEGER NOT NULL,
+	ImpersonatorId INTEGER DEFAULT 0,
+	ImpersonatorSessionId TEXT DEFAULT '',
+	RememberMe INTEGER DEFAULT 0,
+	CreatedAt INTEGER DEFAULT 0,
+	LastSeen INTEGER DEFAULT 0,
+	IPAddress TEXT DEFAULT '',
+	UserAgent TEXT DEFAULT '',
 	FOREIGN KEY (UserId) REFERENCES Users(Id)
 );
 
@@ -162,6 +222,19 @@ CREATE TABLE IF NOT EXISTS PasswordResetTokens (
 	CreatedAt INTEGER NOT NULL
 );
 
+-- Email Change Requests table (pending self-service email changes)
+CREATE TABLE IF NOT EXISTS EmailChangeRequests (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	Token TEXT NOT NULL UNIQUE,
+	UserId INTEGER NOT NULL,
+	OldEmail TEXT NOT NULL,
+	NewEmail TEXT NOT NULL,
+	ExpiresAt INTEGER NOT NULL,
+	Used INTEGER DEFAULT 0,
+	CreatedAt INTEGER NOT NULL,
+	FOREIGN KEY (UserId) REFERENCES Users(Id) ON DELETE CASCADE
+);
+
 -- Teams table (for team collaboration)
 CREATE TABLE IF NOT EXISTS Teams (
 	Id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -172,6 +245,7 @@ CREATE TABLE IF NOT EXISTS Teams (
 	StorageQuotaMB INTEGER NOT NULL DEFAULT 10240,
 	StorageUsedMB INTEGER NOT NULL DEFAULT 0,
 	IsActive INTEGER DEFAULT 1,
+	RequireApproval INTEGER DEFAULT 0,
 	FOREIGN KEY (CreatedBy) REFERENCES Users(Id)
 );
 
@@ -202,6 +276,207 @@ CREATE TABLE IF NOT EXISTS TeamFiles (
 	UNIQUE(FileId, TeamId)
 );
 
+-- File Approvals table (public uploads awaiting release by an approver)
+CREATE TABLE IF NOT EXISTS FileApprovals (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	FileId TEXT NOT NULL UNIQUE,
+	TeamId INTEGER DEFAULT 0,
+	RequestedBy INTEGER NOT NULL,
+	RequestedAt INTEGER NOT NULL,
+	Status TEXT NOT NULL DEFAULT 'pending',
+	DecidedBy INTEGER DEFAULT 0,
+	DecidedAt INTEGER DEFAULT 0,
+	Note TEXT DEFAULT '',
+	FOREIGN KEY (FileId) REFERENCES Files(Id) ON DELETE CASCADE,
+	FOREIGN KEY (RequestedBy) REFERENCES Users(Id)
+);
+
+-- File Access Grants table (emails allowed to download an access-restricted file)
+CREATE TABLE IF NOT EXISTS FileAccessGrants (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	FileId TEXT NOT NULL,
+	Email TEXT NOT NULL,
+	GrantedBy INTEGER DEFAULT 0,
+	GrantedAt INTEGER NOT NULL,
+	FOREIGN KEY (FileId) REFERENCES Files(Id) ON DELETE CASCADE,
+	UNIQUE(FileId, Email)
+);
+
+-- File Access Requests table (recipients asking the owner for access to a file)
+CREATE TABLE IF NOT EXISTS FileAccessRequests (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	FileId TEXT NOT NULL,
+	Email TEXT NOT NULL,
+	Name TEXT DEFAULT '',
+	Message TEXT DEFAULT '',
+	Token TEXT NOT NULL UNIQUE,
+	Status TEXT NOT NULL DEFAULT 'pending',
+	RequestedAt INTEGER NOT NULL,
+	RequesterIP TEXT DEFAULT '',
+	DecidedBy INTEGER DEFAULT 0,
+	DecidedAt INTEGER DEFAULT 0,
+	AccountProvisioned INTEGER DEFAULT 0,
+	FOREIGN KEY (FileId) REFERENCES Files(Id) ON DELETE CASCADE
+);
+
+-- Expiry Reminders table (reminders sent to a file's email recipients before it expires)
+CREATE TABLE IF NOT EXISTS ExpiryReminders (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	FileId TEXT NOT NULL,
+	RecipientEmail TEXT NOT NULL,
+	LeadHours INTEGER NOT NULL,
+	Token TEXT NOT NULL UNIQUE,
+	SentAt INTEGER NOT NULL,
+	Status TEXT NOT NULL DEFAULT 'sent',
+	FOREIGN KEY (FileId) REFERENCES Files(Id) ON DELETE CASCADE,
+	UNIQUE(FileId, RecipientEmail, LeadHours)
+);
+
+-- Expiry Reminder Opt-Outs table (FileId '' opts out of reminders for all files)
+CREATE TABLE IF NOT EXISTS ExpiryReminderOptOuts (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	RecipientEmail TEXT NOT NULL,
+	FileId TEXT NOT NULL DEFAULT '',
+	CreatedAt INTEGER NOT NULL,
+	UNIQUE(RecipientEmail, FileId)
+);
+
+-- Trusted Devices table (browsers that skip the 2FA step until they expire)
+CREATE TABLE IF NOT EXISTS TrustedDevices (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserId INTEGER NOT NULL,
+	TokenHash TEXT NOT NULL UNIQUE,
+	Name TEXT,
+	IPAddress TEXT,
+	CreatedAt INTEGER NOT NULL,
+	ExpiresAt INTEGER NOT NULL,
+	LastUsedAt INTEGER DEFAULT 0,
+	FOREIGN KEY (UserId) REFERENCES Users(Id) ON DELETE CASCADE
+);
+
+-- Download Terms table (versioned terms recipients accept before downloading; versions are never edited)
+CREATE TABLE IF NOT EXISTS DownloadTerms (
+	Version INTEGER PRIMARY KEY,
+	Content TEXT NOT NULL,
+	CreatedAt INTEGER NOT NULL,
+	CreatedBy TEXT
+);
+
+-- Reshare Requests table (visitors of an expired link asking the owner for a new one)
+CREATE TABLE IF NOT EXISTS ReshareRequests (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	FileId TEXT NOT NULL,
+	Email TEXT NOT NULL,
+	Name TEXT,
+	Message TEXT,
+	Reason TEXT NOT NULL,
+	RequesterIP TEXT,
+	RequestedAt INTEGER NOT NULL,
+	FOREIGN KEY (FileId) REFERENCES Files(Id) ON DELETE CASCADE
+);
+
+-- User Notifications table (in-app notifications, see NotificationCategories)
+CREATE TABLE IF NOT EXISTS UserNotifications (
+	Id INTEGER PRIMARY KEY AUTOINCREMENT,
+	UserId INTEGER NOT NULL,
+	Category TEXT NOT NULL,
+	Title TEXT NOT NULL,
+	Message TEXT,
+	Link TEXT,
+	CreatedAt INTEGER NOT NULL,
+	ReadAt INTEGER DEFAULT 0,
+	FOREIGN KEY (UserId) REFERENCES Users(Id) ON DELETE CASCADE
+);
+
+-- API tokens (personal tokens that authenticate scripts as their user, stored as bcrypt hashes)
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	token_prefix TEXT NOT NULL UNIQUE,
+	token_hash TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER DEFAULT 0,
+	last_used_at INTEGER DEFAULT 0,
+	FOREIGN KEY (user_id) REFERENCES Users(Id) ON DELETE CASCADE
+);
+
+-- Webhooks (endpoints that file events are POSTed to; events is a comma-separated list)
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT NOT NULL,
+	is_active INTEGER DEFAULT 1,
+	created_at INTEGER NOT NULL,
+	last_delivery_at INTEGER DEFAULT 0,
+	last_status TEXT DEFAULT ''
+);
+
+-- Burned files (burn-after-download files that are being or were downloaded; kept after the
+-- file is deleted so its link keeps showing that it was used)
+CREATE TABLE IF NOT EXISTS BurnedFiles (
+	FileId TEXT PRIMARY KEY,
+	FileName TEXT NOT NULL,
+	ClaimedAt INTEGER NOT NULL,
+	BurnedAt INTEGER DEFAULT 0
+);
+
+-- Email queue (outgoing emails waiting to be sent, retried with backoff when sending fails;
+-- the file_* and sender_* columns link file emails to their EmailLogs row)
+CREATE TABLE IF NOT EXISTS email_queue (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	recipient TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	html_body TEXT NOT NULL,
+	text_body TEXT NOT NULL,
+	category TEXT DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'queued',
+	attempts INTEGER DEFAULT 0,
+	next_attempt_at INTEGER NOT NULL,
+	last_error TEXT DEFAULT '',
+	created_at INTEGER NOT NULL,
+	sent_at INTEGER DEFAULT 0,
+	file_id TEXT DEFAULT '',
+	file_name TEXT DEFAULT '',
+	file_size INTEGER DEFAULT 0,
+	sender_user_id INTEGER DEFAULT 0,
+	sender_message TEXT DEFAULT '',
+	dedup_key TEXT DEFAULT ''
+);
+
+-- Earlier versions of files (the current version is the Files row; the content of an earlier
+-- version is kept in storage under storage_id until it is purged with the trash)
+CREATE TABLE IF NOT EXISTS file_versions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	file_id TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	storage_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	size_bytes INTEGER NOT NULL,
+	sha1 TEXT DEFAULT '',
+	sha256 TEXT DEFAULT '',
+	content_type TEXT DEFAULT '',
+	uploaded_by INTEGER DEFAULT 0,
+	uploaded_at INTEGER NOT NULL,
+	replaced_at INTEGER NOT NULL,
+	UNIQUE(file_id, version)
+);
+
+-- Scheduled backups (database snapshots written to the backup target; the newest successful
+-- ones are kept, older ones are deleted from the target and from here)
+CREATE TABLE IF NOT EXISTS backup_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	target TEXT NOT NULL,
+	size_bytes INTEGER DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	success INTEGER DEFAULT 0,
+	error TEXT DEFAULT '',
+	manual INTEGER DEFAULT 0
+);
+
 -- Indices for performance
 CREATE INDEX IF NOT EXISTS idx_files_userid ON Files(UserId);
 CREATE INDEX IF NOT EXISTS idx_files_sha1 ON Files(SHA1);
@@ -209,15 +484,29 @@ CREATE INDEX IF NOT EXISTS idx_downloadlogs_fileid ON DownloadLogs(FileId);
 CREATE INDEX IF NOT EXISTS idx_downloadlogs_accountid ON DownloadLogs(DownloadAccountId);
 CREATE INDEX IF NOT EXISTS idx_downloadlogs_downloadedat ON DownloadLogs(DownloadedAt);
 CREATE INDEX IF NOT EXISTS idx_emaillogs_fileid ON EmailLogs(FileId);
+CREATE INDEX IF NOT EXISTS idx_email_queue_status ON email_queue(status, next_attempt_at);
 CREATE INDEX IF NOT EXISTS idx_emaillogs_sentat ON EmailLogs(SentAt);
+CREATE INDEX IF NOT EXISTS idx_downloadlogs_fileid_downloadedat ON DownloadLogs(FileId, DownloadedAt);
+CREATE INDEX IF NOT EXISTS idx_emaillogs_fileid_sentat ON EmailLogs(FileId, SentAt);
 CREATE INDEX IF NOT EXISTS idx_sessions_userid ON Sessions(UserId);
 CREATE INDEX IF NOT EXISTS idx_apikeys_userid ON ApiKeys(UserId);
 CREATE INDEX IF NOT EXISTS idx_filerequests_userid ON FileRequests(UserId);
 CREATE INDEX IF NOT EXISTS idx_filerequests_token ON FileRequests(RequestToken);
 CREATE INDEX IF NOT EXISTS idx_passwordresets_token ON PasswordResetTokens(Token);
 CREATE INDEX IF NOT EXISTS idx_passwordresets_email ON PasswordResetTokens(Email);
+CREATE INDEX IF NOT EXISTS idx_emailchanges_token ON EmailChangeRequests(Token);
+CREATE INDEX IF NOT EXISTS idx_emailchanges_userid ON EmailChangeRequests(UserId);
+CREATE INDEX IF NOT EXISTS idx_fileapprovals_status ON FileApprovals(Status);
+CREATE INDEX IF NOT EXISTS idx_fileaccessgrants_file ON FileAccessGrants(FileId);
+CREATE INDEX IF NOT EXISTS idx_fileaccessrequests_file ON FileAccessRequests(FileId);
+CREATE INDEX IF NOT EXISTS idx_expiryreminders_recipient ON ExpiryReminders(RecipientEmail, SentAt);
+CREATE INDEX IF NOT EXISTS idx_trusteddevices_user ON TrustedDevices(UserId);
+CREATE INDEX IF NOT EXISTS idx_usernotifications_userid ON UserNotifications(UserId);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_resharerequests_file ON ReshareRequests(FileId);
 CREATE INDEX IF NOT EXISTS idx_team_members_team ON TeamMembers(TeamId);
 CREATE INDEX IF NOT EXISTS idx_team_members_user ON TeamMembers(UserId);
 CREATE INDEX IF NOT EXISTS idx_team_files_team ON TeamFiles(TeamId);
 CREATE INDEX IF NOT EXISTS idx_team_files_file ON TeamFiles(FileId);
+CREATE INDEX IF NOT EXISTS idx_file_versions_file ON file_versions(file_id);
 `
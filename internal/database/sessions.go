@@ -0,0 +1,121 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Login session lifetimes, used when session_hours and remember_me_days are not configured
+const (
+	DefaultSessionHours   = 24
+	MaxSessionHours       = 24 * 7
+	DefaultRememberMeDays = 30
+	MaxRememberMeDays     = 365
+)
+
+// GetSessionHours returns how long a login session lasts when "Keep me logged in" is not ticked
+func (d *Database) GetSessionHours() int {
+	hours := d.GetConfigInt("session_hours", DefaultSessionHours)
+	if hours < 1 || hours > MaxSessionHours {
+		return DefaultSessionHours
+	}
+	return hours
+}
+
+// GetRememberMeDays returns how long a login session lasts when "Keep me logged in" is ticked
+// (0 = the option is not offered)
+func (d *Database) GetRememberMeDays() int {
+	days := d.GetConfigInt("remember_me_days", DefaultRememberMeDays)
+	if days < 0 || days > MaxRememberMeDays {
+		return DefaultRememberMeDays
+	}
+	return days
+}
+
+// DeleteUserSessions signs a user out everywhere by deleting all of their login sessions,
+// including the sessions of a super admin viewing the app as them. It returns how many
+// sessions were deleted.
+func (d *Database) DeleteUserSessions(userId int) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM Sessions WHERE UserId = ?", userId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Session is a login session as shown on the user's Active Sessions list. The session ID itself
+// is a secret and never leaves the server; the list refers to sessions by Key.
+type Session struct {
+	Key        string `json:"id"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastSeen   int64  `json:"lastSeen"`
+	ValidUntil int64  `json:"validUntil"`
+	RememberMe bool   `json:"rememberMe"`
+	IPAddress  string `json:"ipAddress"`
+	UserAgent  string `json:"userAgent"`
+
+	id string
+}
+
+// SessionKey returns the key that refers to a session on the Active Sessions list
+func SessionKey(sessionId string) string {
+	sum := sha256.Sum256([]byte(sessionId))
+	return hex.EncodeToString(sum[:8])
+}
+
+// TouchSession records when a session was last used and from where
+func (d *Database) TouchSession(sessionId, ipAddress, userAgent string) error {
+	_, err := d.db.Exec("UPDATE Sessions SET LastSeen = ?, IPAddress = ?, UserAgent = ? WHERE Id = ?",
+		time.Now().Unix(), ipAddress, userAgent, sessionId)
+	return err
+}
+
+// GetSessionsByUser returns the user's login sessions that have not expired, most recently used
+// first. Sessions of a super admin viewing the app as the user are left out.
+func (d *Database) GetSessionsByUser(userId int) ([]*Session, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, COALESCE(CreatedAt, 0), COALESCE(LastSeen, 0), ValidUntil, COALESCE(RememberMe, 0),
+			COALESCE(IPAddress, ''), COALESCE(UserAgent, '')
+		FROM Sessions
+		WHERE UserId = ? AND ValidUntil > ? AND COALESCE(ImpersonatorId, 0) = 0
+		ORDER BY MAX(COALESCE(LastSeen, 0), COALESCE(CreatedAt, 0)) DESC`, userId, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		session := &Session{}
+		if err := rows.Scan(&session.id, &session.CreatedAt, &session.LastSeen, &session.ValidUntil,
+			&session.RememberMe, &session.IPAddress, &session.UserAgent); err != nil {
+			return nil, err
+		}
+		session.Key = SessionKey(session.id)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteUserSession ends one of the user's login sessions, given its key
+func (d *Database) DeleteUserSession(userId int, key string) error {
+	sessions, err := d.GetSessionsByUser(userId)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.Key != key {
+			continue
+		}
+		_, err := d.db.Exec("DELETE FROM Sessions WHERE Id = ? AND UserId = ?", session.id, userId)
+		return err
+	}
+	return errors.New("session not found")
+}
@@ -0,0 +1,20 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// MarkSignedLinkSent records that a signed link to a file was emailed. From then on the
+// file's links need the signature, unless plain links are allowed.
+func (d *Database) MarkSignedLinkSent(fileId string) error {
+	_, err := d.db.Exec("UPDATE Files SET SignedLinkSent = 1 WHERE Id = ?", fileId)
+	return err
+}
+
+// HasSignedLinkSent reports whether a signed link to a file was ever emailed
+func (d *Database) HasSignedLinkSent(fileId string) bool {
+	var sent int
+	d.db.QueryRow("SELECT COALESCE(SignedLinkSent, 0) FROM Files WHERE Id = ?", fileId).Scan(&sent)
+	return sent == 1
+}
@@ -0,0 +1,41 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// Splash page templates a file's owner can choose from
+const (
+	SplashTemplateDetailed    = "detailed"     // file details, preview and poem (the default)
+	SplashTemplateMinimal     = "minimal"      // just the file and the download button
+	SplashTemplateLegalNotice = "legal-notice" // the details plus a notice the recipient must agree to first
+)
+
+// SplashTemplates lists the splash page templates in the order they are offered
+var SplashTemplates = []string{SplashTemplateDetailed, SplashTemplateMinimal, SplashTemplateLegalNotice}
+
+// IsValidSplashTemplate reports whether a name is one of the splash page templates
+func IsValidSplashTemplate(name string) bool {
+	for _, tmpl := range SplashTemplates {
+		if name == tmpl {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFileSplashTemplate returns the splash page template of a file
+func (d *Database) GetFileSplashTemplate(fileId string) string {
+	var tmpl string
+	if err := d.db.QueryRow("SELECT COALESCE(SplashTemplate, '') FROM Files WHERE Id = ?", fileId).Scan(&tmpl); err != nil || !IsValidSplashTemplate(tmpl) {
+		return SplashTemplateDetailed
+	}
+	return tmpl
+}
+
+// SetFileSplashTemplate sets the splash page template of a file. The name must already be validated.
+func (d *Database) SetFileSplashTemplate(fileId, tmpl string) error {
+	_, err := d.db.Exec("UPDATE Files SET SplashTemplate = ? WHERE Id = ?", tmpl, fileId)
+	return err
+}
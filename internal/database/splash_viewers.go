@@ -0,0 +1,31 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// IsSplashViewerTrackingEnabled reports whether open splash pages are counted, which is
+// needed for per-file viewer limits and the viewer count shown to owners (off by default)
+func (d *Database) IsSplashViewerTrackingEnabled() bool {
+	value, _ := d.GetConfigValue("splash_viewer_tracking")
+	return value == "true"
+}
+
+// GetFileMaxViewers returns how many people may have a file's splash page open at once (0 = no limit)
+func (d *Database) GetFileMaxViewers(fileId string) int {
+	var maxViewers int
+	if err := d.db.QueryRow("SELECT COALESCE(MaxConcurrentViewers, 0) FROM Files WHERE Id = ?", fileId).Scan(&maxViewers); err != nil {
+		return 0
+	}
+	return maxViewers
+}
+
+// SetFileMaxViewers sets how many people may have a file's splash page open at once (0 = no limit)
+func (d *Database) SetFileMaxViewers(fileId string, maxViewers int) error {
+	if maxViewers < 0 {
+		maxViewers = 0
+	}
+	_, err := d.db.Exec("UPDATE Files SET MaxConcurrentViewers = ? WHERE Id = ?", maxViewers, fileId)
+	return err
+}
@@ -0,0 +1,161 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// setupTestDB opens a fresh database in a temporary directory as DB
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	if err := Initialize(t.TempDir()); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(func() { DB.Close() })
+}
+
+// createTestUser adds a user with the given storage quota
+func createTestUser(t *testing.T, email string, quotaMB int64) *models.User {
+	t.Helper()
+	user := &models.User{
+		Name:           email,
+		Email:          email,
+		UserLevel:      models.UserLevelUser,
+		StorageQuotaMB: quotaMB,
+		IsActive:       true,
+	}
+	if err := DB.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// uploadTestFile saves a file and counts it toward its owner's storage, the way the upload
+// handlers do
+func uploadTestFile(userId int, id string, sizeBytes int64) error {
+	file := &FileInfo{
+		Id:                 id,
+		Name:               id + ".bin",
+		SizeBytes:          sizeBytes,
+		UploadDate:         time.Now().Unix(),
+		UserId:             userId,
+		UnlimitedDownloads: true,
+		UnlimitedTime:      true,
+	}
+	if err := DB.SaveFile(file); err != nil {
+		return err
+	}
+	return DB.AdjustUserStorage(userId, StorageMB(sizeBytes))
+}
+
+func TestStorageMB(t *testing.T) {
+	tests := []struct {
+		sizeBytes int64
+		want      int64
+	}{
+		{0, 0},
+		{1, 1},
+		{BytesPerMB - 1, 1},
+		{BytesPerMB, 1},
+		{BytesPerMB + 1, 2},
+		{10 * BytesPerMB, 10},
+	}
+	for _, tt := range tests {
+		if got := StorageMB(tt.sizeBytes); got != tt.want {
+			t.Errorf("StorageMB(%d) = %d, want %d", tt.sizeBytes, got, tt.want)
+		}
+	}
+}
+
+// Small files must use up the quota too, or any number of them could be uploaded
+func TestSmallFilesCountTowardStorage(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "small@example.com", 1000)
+
+	for i := 0; i < 5; i++ {
+		if err := uploadTestFile(user.Id, fmt.Sprintf("small%d", i), 1000); err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+	}
+
+	fresh, err := DB.GetUserByID(user.Id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if fresh.StorageUsedMB != 5 {
+		t.Errorf("StorageUsedMB = %d after five small files, want 5", fresh.StorageUsedMB)
+	}
+	if calculated, _ := DB.CalculateUserStorage(user.Id); calculated != fresh.StorageUsedMB {
+		t.Errorf("CalculateUserStorage = %d, stored usage is %d", calculated, fresh.StorageUsedMB)
+	}
+}
+
+// Parallel uploads and deletes must leave the stored usage equal to what the files add up to
+func TestConcurrentUploadsAndDeletesKeepStorageUsage(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "parallel@example.com", 1000000)
+
+	sizes := []int64{1, 512 * 1024, BytesPerMB, BytesPerMB + 1, 3*BytesPerMB + 7}
+	const existing = 20
+	for i := 0; i < existing; i++ {
+		if err := uploadTestFile(user.Id, fmt.Sprintf("old%d", i), sizes[i%len(sizes)]); err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*existing)
+	for i := 0; i < existing; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- uploadTestFile(user.Id, fmt.Sprintf("new%d", i), sizes[(i+2)%len(sizes)])
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			// Every other file is deleted twice, which must only release it once
+			errs <- DB.DeleteFileWithRetention(fmt.Sprintf("old%d", i/2*2), user.Id, 0)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("parallel upload or delete: %v", err)
+		}
+	}
+
+	var want int64
+	files, err := DB.GetFilesByUser(user.Id)
+	if err != nil {
+		t.Fatalf("GetFilesByUser: %v", err)
+	}
+	for _, file := range files {
+		if file.DeletedAt == 0 {
+			want += StorageMB(file.SizeBytes)
+		}
+	}
+
+	fresh, err := DB.GetUserByID(user.Id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if fresh.StorageUsedMB != want {
+		t.Errorf("StorageUsedMB = %d, the remaining files add up to %d", fresh.StorageUsedMB, want)
+	}
+	if calculated, _ := DB.CalculateUserStorage(user.Id); calculated != want {
+		t.Errorf("CalculateUserStorage = %d, want %d", calculated, want)
+	}
+	if fixed, _ := DB.ReconcileUserStorage(); fixed != 0 {
+		t.Errorf("ReconcileUserStorage corrected %d users, want none", fixed)
+	}
+}
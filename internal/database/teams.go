@@ -8,6 +8,7 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -24,12 +25,16 @@ func (d *Database) CreateTeam(team *models.Team) error {
 	if !team.IsActive {
 		isActive = 0
 	}
+	requireApproval := 0
+	if team.RequireApproval {
+		requireApproval = 1
+	}
 
 	result, err := d.db.Exec(`
-		INSERT INTO Teams (Name, Description, CreatedBy, CreatedAt, StorageQuotaMB, StorageUsedMB, IsActive)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO Teams (Name, Description, CreatedBy, CreatedAt, StorageQuotaMB, StorageUsedMB, IsActive, RequireApproval)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
 		team.Name, team.Description, team.CreatedBy, team.CreatedAt,
-		team.StorageQuotaMB, team.StorageUsedMB, isActive,
+		team.StorageQuotaMB, team.StorageUsedMB, isActive, requireApproval,
 	)
 	if err != nil {
 		return err
@@ -55,13 +60,13 @@ func (d *Database) CreateTeam(team *models.Team) error {
 // GetTeamByID retrieves a team by ID
 func (d *Database) GetTeamByID(id int) (*models.Team, error) {
 	team := &models.Team{}
-	var isActive int
+	var isActive, requireApproval int
 
 	err := d.db.QueryRow(`
-		SELECT Id, Name, Description, CreatedBy, CreatedAt, StorageQuotaMB, StorageUsedMB, IsActive
+		SELECT Id, Name, Description, CreatedBy, CreatedAt, StorageQuotaMB, StorageUsedMB, IsActive, RequireApproval
 		FROM Teams WHERE Id = ?`, id).Scan(
 		&team.Id, &team.Name, &team.Description, &team.CreatedBy, &team.CreatedAt,
-		&team.StorageQuotaMB, &team.StorageUsedMB, &isActive,
+		&team.StorageQuotaMB, &team.StorageUsedMB, &isActive, &requireApproval,
 	)
 
 	if err != nil {
@@ -72,13 +77,14 @@ func (d *Database) GetTeamByID(id int) (*models.Team, error) {
 	}
 
 	team.IsActive = isActive == 1
+	team.RequireApproval = requireApproval == 1
 	return team, nil
 }
 
 // GetAllTeams returns all active teams
 func (d *Database) GetAllTeams() ([]*models.Team, error) {
 	rows, err := d.db.Query(`
-		SELECT Id, Name, Description, CreatedBy, CreatedAt, StorageQuotaMB, StorageUsedMB, IsActive
+		SELECT Id, Name, Description, CreatedBy, CreatedAt, StorageQuotaMB, StorageUsedMB, IsActive, RequireApproval
 		FROM Teams WHERE IsActive = 1 ORDER BY Name ASC`)
 	if err != nil {
 		return nil, err
@@ -88,15 +94,16 @@ func (d *Database) GetAllTeams() ([]*models.Team, error) {
 	var teams []*models.Team
 	for rows.Next() {
 		team := &models.Team{}
-		var isActive int
+		var isActive, requireApproval int
 
 		err := rows.Scan(&team.Id, &team.Name, &team.Description, &team.CreatedBy,
-			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive)
+			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive, &requireApproval)
 		if err != nil {
 			return nil, err
 		}
 
 		team.IsActive = isActive == 1
+		team.RequireApproval = requireApproval == 1
 		teams = append(teams, team)
 	}
 
@@ -107,7 +114,7 @@ func (d *Database) GetAllTeams() ([]*models.Team, error) {
 func (d *Database) GetTeamsByUser(userId int) ([]*models.TeamWithMembers, error) {
 	rows, err := d.db.Query(`
 		SELECT t.Id, t.Name, t.Description, t.CreatedBy, t.CreatedAt,
-		       t.StorageQuotaMB, t.StorageUsedMB, t.IsActive,
+		       t.StorageQuotaMB, t.StorageUsedMB, t.IsActive, t.RequireApproval,
 		       tm.Role,
 		       (SELECT COUNT(*) FROM TeamMembers WHERE TeamId = t.Id) as MemberCount
 		FROM Teams t
@@ -122,11 +129,11 @@ func (d *Database) GetTeamsByUser(userId int) ([]*models.TeamWithMembers, error)
 	var teams []*models.TeamWithMembers
 	for rows.Next() {
 		team := &models.TeamWithMembers{}
-		var isActive int
+		var isActive, requireApproval int
 
 		err := rows.Scan(
 			&team.Id, &team.Name, &team.Description, &team.CreatedBy,
-			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive,
+			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive, &requireApproval,
 			&team.UserRole, &team.MemberCount,
 		)
 		if err != nil {
@@ -134,6 +141,7 @@ func (d *Database) GetTeamsByUser(userId int) ([]*models.TeamWithMembers, error)
 		}
 
 		team.IsActive = isActive == 1
+		team.RequireApproval = requireApproval == 1
 		teams = append(teams, team)
 	}
 
@@ -146,12 +154,16 @@ func (d *Database) UpdateTeam(team *models.Team) error {
 	if !team.IsActive {
 		isActive = 0
 	}
+	requireApproval := 0
+	if team.RequireApproval {
+		requireApproval = 1
+	}
 
 	_, err := d.db.Exec(`
 		UPDATE Teams
-		SET Name = ?, Description = ?, StorageQuotaMB = ?, IsActive = ?
+		SET Name = ?, Description = ?, StorageQuotaMB = ?, IsActive = ?, RequireApproval = ?
 		WHERE Id = ?`,
-		team.Name, team.Description, team.StorageQuotaMB, isActive, team.Id,
+		team.Name, team.Description, team.StorageQuotaMB, isActive, requireApproval, team.Id,
 	)
 	return err
 }
@@ -266,40 +278,250 @@ func (d *Database) IsTeamMember(teamId, userId int) (bool, error) {
 	return count > 0, nil
 }
 
-// UpdateTeamMemberRole updates a member's role in a team
-func (d *Database) UpdateTeamMemberRole(teamId, userId int, role models.TeamRole) error {
-	_, err := d.db.Exec(`
-		UPDATE TeamMembers
-		SET Role = ?
-		WHERE TeamId = ? AND UserId = ?`,
-		role, teamId, userId,
-	)
+var (
+	// ErrNotTeamMember is returned for a user who isn't a member of the team
+	ErrNotTeamMember = errors.New("user is not a member of this team")
+	// ErrLastTeamOwner is returned when a change would leave a team without an owner
+	ErrLastTeamOwner = errors.New("a team must keep at least one owner")
+)
+
+// GetTeamRole returns a user's role in a team, or ErrNotTeamMember
+func (d *Database) GetTeamRole(teamId, userId int) (models.TeamRole, error) {
+	var role models.TeamRole
+	err := d.db.QueryRow("SELECT Role FROM TeamMembers WHERE TeamId = ? AND UserId = ?",
+		teamId, userId).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotTeamMember
+	}
+	return role, err
+}
+
+// CountTeamOwners returns how many owners a team has
+func (d *Database) CountTeamOwners(teamId int) (int, error) {
+	var count int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM TeamMembers WHERE TeamId = ? AND Role = ?",
+		teamId, models.TeamRoleOwner).Scan(&count)
+	return count, err
+}
+
+// SetTeamRole changes a member's role in a team. The last owner can't be demoted.
+func (d *Database) SetTeamRole(teamId, userId int, role models.TeamRole) error {
+	if !role.IsValid() {
+		return errors.New("invalid team role")
+	}
+	current, err := d.GetTeamRole(teamId, userId)
+	if err != nil {
+		return err
+	}
+	if current == role {
+		return nil
+	}
+	if current == models.TeamRoleOwner {
+		owners, err := d.CountTeamOwners(teamId)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return ErrLastTeamOwner
+		}
+	}
+
+	_, err = d.db.Exec("UPDATE TeamMembers SET Role = ? WHERE TeamId = ? AND UserId = ?",
+		role, teamId, userId)
 	return err
 }
 
-// ShareFileToTeam shares a file with a team
+// ErrTeamQuotaExceeded is returned when sharing a file would take a team past its storage quota
+var ErrTeamQuotaExceeded = errors.New("team storage quota exceeded")
+
+// teamStorageUsedSQL sums the sizes of the files shared to a team, not counting files in the trash
+const teamStorageUsedSQL = `
+	SELECT COALESCE(SUM(f.SizeBytes), 0)
+	FROM TeamFiles tf
+	INNER JOIN Files f ON tf.FileId = f.Id
+	WHERE tf.TeamId = %s AND f.DeletedAt = 0`
+
+// GetTeamStorageUsedBytes sums the sizes of the files shared to a team. A file counts against
+// every team it is shared with, independently of its owner's personal quota.
+func (d *Database) GetTeamStorageUsedBytes(teamId int) (int64, error) {
+	var used int64
+	err := d.db.QueryRow(fmt.Sprintf(teamStorageUsedSQL, "?"), teamId).Scan(&used)
+	return used, err
+}
+
+// RecalculateTeamStorage stores a team's current usage in StorageUsedMB and returns it
+func (d *Database) RecalculateTeamStorage(teamId int) (int64, error) {
+	used, err := d.GetTeamStorageUsedBytes(teamId)
+	if err != nil {
+		return 0, err
+	}
+	usedMB := StorageMB(used)
+	return usedMB, d.UpdateTeamStorage(teamId, usedMB)
+}
+
+// RecalculateAllTeamStorage refreshes StorageUsedMB of every team, e.g. after files were deleted
+func (d *Database) RecalculateAllTeamStorage() error {
+	_, err := d.db.Exec(`UPDATE Teams SET StorageUsedMB = ((` +
+		fmt.Sprintf(teamStorageUsedSQL, "Teams.Id") + `) + 1048575) / 1048576`)
+	return err
+}
+
+// IsFileSharedWithTeam checks if a file is shared with a team
+func (d *Database) IsFileSharedWithTeam(fileId string, teamId int) (bool, error) {
+	var count int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM TeamFiles WHERE FileId = ? AND TeamId = ?", fileId, teamId).Scan(&count)
+	return count > 0, err
+}
+
+// CheckTeamQuota returns ErrTeamQuotaExceeded, with the sizes involved, if sharing the file
+// would take the team past its storage quota. Files already shared with the team and teams
+// without a quota always pass.
+func (d *Database) CheckTeamQuota(teamId int, fileId string) error {
+	team, err := d.GetTeamByID(teamId)
+	if err != nil {
+		return err
+	}
+	if team.StorageQuotaMB <= 0 {
+		return nil
+	}
+
+	if shared, err := d.IsFileSharedWithTeam(fileId, teamId); err != nil || shared {
+		return err
+	}
+
+	var size int64
+	if err := d.db.QueryRow("SELECT COALESCE(SizeBytes, 0) FROM Files WHERE Id = ?", fileId).Scan(&size); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("file not found")
+		}
+		return err
+	}
+	return d.checkTeamRoom(team, size)
+}
+
+// CheckTeamQuotaForSize returns ErrTeamQuotaExceeded if a new file of the given size wouldn't
+// fit in the team's storage quota, so an upload shared with the team can be refused up front
+func (d *Database) CheckTeamQuotaForSize(teamId int, size int64) error {
+	team, err := d.GetTeamByID(teamId)
+	if err != nil {
+		return err
+	}
+	if team.StorageQuotaMB <= 0 {
+		return nil
+	}
+	return d.checkTeamRoom(team, size)
+}
+
+func (d *Database) checkTeamRoom(team *models.Team, size int64) error {
+	used, err := d.GetTeamStorageUsedBytes(team.Id)
+	if err != nil {
+		return err
+	}
+
+	quota := team.StorageQuotaMB * BytesPerMB
+	if used+size > quota {
+		return fmt.Errorf("%w: the file needs %d MB but team %s has only %d MB of its %d MB free",
+			ErrTeamQuotaExceeded, StorageMB(size), team.Name, max((quota-used)/BytesPerMB, 0), team.StorageQuotaMB)
+	}
+	return nil
+}
+
+// ShareFileToTeam shares a file with a team, or returns ErrTeamQuotaExceeded if the team
+// hasn't room for it
 func (d *Database) ShareFileToTeam(fileId string, teamId, sharedBy int) error {
+	if err := d.CheckTeamQuota(teamId, fileId); err != nil {
+		return err
+	}
+
 	_, err := d.db.Exec(`
 		INSERT INTO TeamFiles (FileId, TeamId, SharedBy, SharedAt)
 		VALUES (?, ?, ?, ?)`,
 		fileId, teamId, sharedBy, time.Now().Unix(),
 	)
+	if err != nil {
+		return err
+	}
+	_, err = d.RecalculateTeamStorage(teamId)
 	return err
 }
 
 // UnshareFileFromTeam removes a file from a team
 func (d *Database) UnshareFileFromTeam(fileId string, teamId int) error {
 	_, err := d.db.Exec("DELETE FROM TeamFiles WHERE FileId = ? AND TeamId = ?", fileId, teamId)
+	if err != nil {
+		return err
+	}
+	_, err = d.RecalculateTeamStorage(teamId)
 	return err
 }
 
-// GetTeamFiles returns all files shared with a team
-func (d *Database) GetTeamFiles(teamId int) ([]*models.TeamFile, error) {
-	rows, err := d.db.Query(`
-		SELECT Id, FileId, TeamId, SharedBy, SharedAt
-		FROM TeamFiles
-		WHERE TeamId = ?
-		ORDER BY SharedAt DESC`, teamId)
+// TeamFileFilter represents search, sorting and pagination options for a team's files
+type TeamFileFilter struct {
+	SearchTerm string // Search in file name, comment and owner name
+	SortBy     string // Sort field: "date" (shared), "name", "size", "owner"
+	SortOrder  string // Sort order: "asc", "desc"
+	Limit      int
+	Offset     int
+}
+
+// whereClause builds the SQL conditions for the filter. Columns are qualified
+// with the TeamFiles (tf), Files (f) and Users (u, the owner) table aliases.
+func (filter *TeamFileFilter) whereClause(teamId int) (string, []interface{}) {
+	clause := "tf.TeamId = ? AND f.DeletedAt = 0"
+	args := []interface{}{teamId}
+
+	if filter != nil && filter.SearchTerm != "" {
+		clause += " AND (f.Name LIKE ? OR f.Comment LIKE ? OR u.Name LIKE ?)"
+		searchPattern := "%" + filter.SearchTerm + "%"
+		args = append(args, searchPattern, searchPattern, searchPattern)
+	}
+
+	return clause, args
+}
+
+// GetTeamFiles returns the files shared with a team that match the filter (nil = all).
+// Files in the trash are not included.
+func (d *Database) GetTeamFiles(teamId int, filter *TeamFileFilter) ([]*models.TeamFile, error) {
+	where, args := filter.whereClause(teamId)
+	query := `
+		SELECT tf.Id, tf.FileId, tf.TeamId, tf.SharedBy, tf.SharedAt
+		FROM TeamFiles tf
+		INNER JOIN Files f ON tf.FileId = f.Id
+		LEFT JOIN Users u ON f.UserId = u.Id
+		WHERE ` + where
+
+	// Apply sorting
+	sortBy := "tf.SharedAt DESC" // Default sort
+	if filter != nil && filter.SortBy != "" {
+		sortOrder := "ASC"
+		if filter.SortOrder == "desc" {
+			sortOrder = "DESC"
+		}
+		switch filter.SortBy {
+		case "date":
+			sortBy = "tf.SharedAt " + sortOrder
+		case "name":
+			sortBy = "f.Name COLLATE NOCASE " + sortOrder
+		case "size":
+			sortBy = "f.SizeBytes " + sortOrder
+		case "owner":
+			sortBy = "u.Name COLLATE NOCASE " + sortOrder
+		}
+	}
+	query += " ORDER BY " + sortBy + ", tf.Id DESC"
+
+	// Apply pagination
+	if filter != nil && filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+
+		if filter.Offset > 0 {
+			query += " OFFSET ?"
+			args = append(args, filter.Offset)
+		}
+	}
+
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -318,11 +540,25 @@ func (d *Database) GetTeamFiles(teamId int) ([]*models.TeamFile, error) {
 	return files, rows.Err()
 }
 
+// GetTeamFileCount returns the number of files shared with a team that match the filter
+func (d *Database) GetTeamFileCount(teamId int, filter *TeamFileFilter) (int, error) {
+	where, args := filter.whereClause(teamId)
+
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM TeamFiles tf
+		INNER JOIN Files f ON tf.FileId = f.Id
+		LEFT JOIN Users u ON f.UserId = u.Id
+		WHERE `+where, args...).Scan(&count)
+	return count, err
+}
+
 // GetFileTeams returns all teams a file is shared with
 func (d *Database) GetFileTeams(fileId string) ([]*models.Team, error) {
 	rows, err := d.db.Query(`
 		SELECT t.Id, t.Name, t.Description, t.CreatedBy, t.CreatedAt,
-		       t.StorageQuotaMB, t.StorageUsedMB, t.IsActive
+		       t.StorageQuotaMB, t.StorageUsedMB, t.IsActive, t.RequireApproval
 		FROM Teams t
 		INNER JOIN TeamFiles tf ON t.Id = tf.TeamId
 		WHERE tf.FileId = ?`, fileId)
@@ -334,15 +570,16 @@ func (d *Database) GetFileTeams(fileId string) ([]*models.Team, error) {
 	var teams []*models.Team
 	for rows.Next() {
 		team := &models.Team{}
-		var isActive int
+		var isActive, requireApproval int
 
 		err := rows.Scan(&team.Id, &team.Name, &team.Description, &team.CreatedBy,
-			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive)
+			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive, &requireApproval)
 		if err != nil {
 			return nil, err
 		}
 
 		team.IsActive = isActive == 1
+		team.RequireApproval = requireApproval == 1
 		teams = append(teams, team)
 	}
 
@@ -406,17 +643,22 @@ func (d *Database) GetFileTeamNames(fileIds []string) (map[string][]string, erro
 }
 
 // GetFilesByUserWithTeams returns all files the user can access (own files + team files)
-func (d *Database) GetFilesByUserWithTeams(userId int) ([]*FileInfo, error) {
+// that match the filter (nil = all)
+func (d *Database) GetFilesByUserWithTeams(userId int, filter *FileFilter) ([]*FileInfo, error) {
+	where, args := filter.whereClause()
+	args = append(args, userId, userId)
+
 	rows, err := d.db.Query(`
 		SELECT DISTINCT f.Id, f.Name, f.Size, f.SHA1, f.PasswordHash, f.FilePasswordPlain, f.HotlinkId,
 		       f.ContentType, f.AwsBucket, f.ExpireAtString, f.ExpireAt, f.PendingDeletion,
 		       f.SizeBytes, f.UploadDate, f.DownloadsRemaining, f.DownloadCount, f.UserId, f.Comment,
-		       f.UnlimitedDownloads, f.UnlimitedTime, f.RequireAuth, f.DeletedAt, f.DeletedBy
+		       f.UnlimitedDownloads, f.UnlimitedTime, f.RequireAuth, f.DeletedAt, f.DeletedBy, f.Category, COALESCE(f.SHA256, ''), COALESCE(f.DownloadLimitMBps, 0), COALESCE(f.BurnAfterDownload, 0), COALESCE(f.ScanStatus, ''), COALESCE(f.AvailableFrom, 0)
 		FROM Files f
+		LEFT JOIN Users u ON f.UserId = u.Id
 		LEFT JOIN TeamFiles tf ON f.Id = tf.FileId
 		LEFT JOIN TeamMembers tm ON tf.TeamId = tm.TeamId
-		WHERE f.DeletedAt = 0 AND (f.UserId = ? OR tm.UserId = ?)
-		ORDER BY f.UploadDate DESC`, userId, userId)
+		WHERE `+where+` AND (f.UserId = ? OR tm.UserId = ?)
+		ORDER BY f.UploadDate DESC`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -434,7 +676,7 @@ func (d *Database) GetFilesByUserWithTeams(userId int) ([]*FileInfo, error) {
 			&hotlinkId, &file.ContentType, &awsBucket, &expireAtString,
 			&expireAt, &pendingDeletion, &file.SizeBytes, &file.UploadDate,
 			&file.DownloadsRemaining, &file.DownloadCount, &file.UserId, &comment,
-			&unlimitedDownloads, &unlimitedTime, &requireAuth, &deletedAt, &deletedBy,
+			&unlimitedDownloads, &unlimitedTime, &requireAuth, &deletedAt, &deletedBy, &file.Category, &file.SHA256, &file.DownloadLimitMBps, &file.BurnAfterDownload, &file.ScanStatus, &file.ActivateAt,
 		)
 		if err != nil {
 			return nil, err
@@ -464,7 +706,7 @@ func (d *Database) GetFilesByUserWithTeams(userId int) ([]*FileInfo, error) {
 func (d *Database) GetTeamsForFile(fileId string) ([]*models.Team, error) {
 	query := `
 		SELECT t.Id, t.Name, t.Description, t.CreatedBy, t.CreatedAt,
-		       t.StorageQuotaMB, t.StorageUsedMB, t.IsActive
+		       t.StorageQuotaMB, t.StorageUsedMB, t.IsActive, t.RequireApproval
 		FROM Teams t
 		INNER JOIN TeamFiles tf ON t.Id = tf.TeamId
 		WHERE tf.FileId = ?
@@ -479,15 +721,16 @@ func (d *Database) GetTeamsForFile(fileId string) ([]*models.Team, error) {
 	var teams []*models.Team
 	for rows.Next() {
 		team := &models.Team{}
-		var isActive int
+		var isActive, requireApproval int
 		err := rows.Scan(
 			&team.Id, &team.Name, &team.Description, &team.CreatedBy,
-			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive,
+			&team.CreatedAt, &team.StorageQuotaMB, &team.StorageUsedMB, &isActive, &requireApproval,
 		)
 		if err != nil {
 			return nil, err
 		}
 		team.IsActive = isActive == 1
+		team.RequireApproval = requireApproval == 1
 		teams = append(teams, team)
 	}
 
@@ -0,0 +1,71 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"time"
+)
+
+// TokenType is a table of short-lived tokens that CleanupExpiredTokens prunes.
+// New token tables are added here so they are reaped with the others.
+type TokenType struct {
+	Name                  string // used in log messages and the token_retention_hours_<name> setting
+	Label                 string
+	Table                 string
+	ExpiresColumn         string
+	UsedColumn            string // "" for tokens that are not single-use
+	DefaultRetentionHours int    // how long expired or used tokens are kept, e.g. for troubleshooting
+}
+
+// TokenTypes lists every token table that is cleaned up
+var TokenTypes = []TokenType{
+	{Name: "sessions", Label: "Login sessions", Table: "Sessions", ExpiresColumn: "ValidUntil"},
+	{Name: "password_reset", Label: "Password reset links", Table: "PasswordResetTokens", ExpiresColumn: "ExpiresAt", UsedColumn: "Used", DefaultRetentionHours: 24},
+	{Name: "email_change", Label: "Email change links", Table: "EmailChangeRequests", ExpiresColumn: "ExpiresAt", UsedColumn: "Used", DefaultRetentionHours: 24},
+	{Name: "trusted_devices", Label: "Trusted devices", Table: "TrustedDevices", ExpiresColumn: "ExpiresAt"},
+}
+
+// RetentionConfigKey returns the setting that overrides the retention of this token type
+func (t TokenType) RetentionConfigKey() string {
+	return "token_retention_hours_" + t.Name
+}
+
+// GetTokenRetentionHours returns how long expired or used tokens of a type are kept
+func (d *Database) GetTokenRetentionHours(tokenType TokenType) int {
+	hours := d.GetConfigInt(tokenType.RetentionConfigKey(), tokenType.DefaultRetentionHours)
+	if hours < 0 {
+		return 0
+	}
+	return hours
+}
+
+// CleanupExpiredTokens deletes tokens that expired (or, for single-use tokens, were used)
+// longer ago than their retention. It returns how many rows were removed per token type.
+func (d *Database) CleanupExpiredTokens() (map[string]int64, error) {
+	removed := make(map[string]int64)
+	now := time.Now()
+
+	for _, tokenType := range TokenTypes {
+		cutoff := now.Add(-time.Duration(d.GetTokenRetentionHours(tokenType)) * time.Hour).Unix()
+
+		query := "DELETE FROM " + tokenType.Table + " WHERE " + tokenType.ExpiresColumn + " < ?"
+		args := []interface{}{cutoff}
+		if tokenType.UsedColumn != "" {
+			query += " OR (" + tokenType.UsedColumn + " = 1 AND CreatedAt < ?)"
+			args = append(args, cutoff)
+		}
+
+		result, err := d.db.Exec(query, args...)
+		if err != nil {
+			return removed, err
+		}
+		if count, err := result.RowsAffected(); err == nil {
+			removed[tokenType.Name] = count
+		}
+	}
+
+	return removed, nil
+}
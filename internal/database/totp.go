@@ -6,14 +6,224 @@
 package database
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/Frimurare/WulfVault/internal/models"
 	"github.com/Frimurare/WulfVault/internal/totp"
 )
 
-// EnableTOTP enables two-factor authentication for a user
+// TOTPKeyFileName is the file in the data directory that holds the key TOTP secrets are
+// encrypted with. It is kept out of the database, so database backups don't contain it.
+const TOTPKeyFileName = "totp.key"
+
+// TOTPKeyEnv names the environment variable that sets the TOTP encryption key (64 hex digits)
+// instead of the key file
+const TOTPKeyEnv = "TOTP_ENCRYPTION_KEY"
+
+// totpKeyMu lets only one caller create the key file
+var totpKeyMu sync.Mutex
+
+// totpKeyPath returns the path of the key file, next to the database
+func (d *Database) totpKeyPath() string {
+	return filepath.Join(filepath.Dir(d.path), TOTPKeyFileName)
+}
+
+// totpEncryptionKey returns the key TOTP secrets are encrypted with: the one set in the
+// environment, or the one in the key file, which is created the first time
+func (d *Database) totpEncryptionKey() ([]byte, error) {
+	if keyHex := os.Getenv(TOTPKeyEnv); keyHex != "" {
+		key, err := hex.DecodeString(strings.TrimSpace(keyHex))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s must be 64 hex digits", TOTPKeyEnv)
+		}
+		return key, nil
+	}
+
+	totpKeyMu.Lock()
+	defer totpKeyMu.Unlock()
+
+	path := d.totpKeyPath()
+	if data, err := os.ReadFile(path); err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s is not a valid key", path)
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := writeTOTPKeyFile(path, key); err != nil {
+		return nil, err
+	}
+	log.Printf("Created new TOTP encryption key in %s", path)
+	return key, nil
+}
+
+// writeTOTPKeyFile writes a key file readable only by the server, failing if one exists
+func writeTOTPKeyFile(path string, key []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create TOTP key file: %w", err)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write TOTP key file: %w", err)
+	}
+	return f.Close()
+}
+
+// moveTOTPKeyOutOfDatabase removes the TOTP encryption key that earlier versions stored in the
+// database, which also comes back with a restored backup. Without a key file or environment key
+// it becomes the key file; otherwise the secrets it encrypted are encrypted again with the
+// current key.
+func (d *Database) moveTOTPKeyOutOfDatabase() error {
+	legacyHex, err := d.GetConfigValue("totp_encryption_key")
+	if err != nil || legacyHex == "" {
+		return err
+	}
+	legacy, err := hex.DecodeString(legacyHex)
+	if err != nil {
+		return fmt.Errorf("stored TOTP encryption key is not valid: %w", err)
+	}
+
+	_, statErr := os.Stat(d.totpKeyPath())
+	if os.Getenv(TOTPKeyEnv) == "" && os.IsNotExist(statErr) {
+		if err := writeTOTPKeyFile(d.totpKeyPath(), legacy); err != nil {
+			return err
+		}
+		log.Printf("Moved the TOTP encryption key out of the database to %s", d.totpKeyPath())
+	} else {
+		key, err := d.totpEncryptionKey()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(key, legacy) {
+			if err := d.reencryptTOTPSecrets(legacy, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = d.db.Exec("DELETE FROM Configuration WHERE Key = ?", "totp_encryption_key")
+	return err
+}
+
+// reencryptTOTPSecrets encrypts the TOTP secrets that were encrypted with from with to instead
+func (d *Database) reencryptTOTPSecrets(from, to []byte) error {
+	rows, err := d.db.Query("SELECT Id, TOTPSecret FROM Users WHERE TOTPSecret LIKE 'enc:%'")
+	if err != nil {
+		return err
+	}
+	secrets := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			rows.Close()
+			return err
+		}
+		secrets[id] = stored
+	}
+	rows.Close()
+
+	reencrypted := 0
+	for id, stored := range secrets {
+		secret, err := totp.DecryptSecret(stored, from)
+		if err != nil {
+			// Encrypted with the current key already
+			continue
+		}
+		encrypted, err := totp.EncryptSecret(secret, to)
+		if err != nil {
+			return err
+		}
+		if _, err := d.db.Exec("UPDATE Users SET TOTPSecret = ? WHERE Id = ?", encrypted, id); err != nil {
+			return err
+		}
+		reencrypted++
+	}
+	log.Printf("Encrypted the TOTP secrets of %d users with the current TOTP encryption key", reencrypted)
+	return nil
+}
+
+// GetTOTPSecret returns the decrypted TOTP secret of a user with 2FA enabled
+func (d *Database) GetTOTPSecret(user *models.User) (string, error) {
+	key, err := d.totpEncryptionKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to get TOTP encryption key: %w", err)
+	}
+	secret, err := totp.DecryptSecret(user.TOTPSecret, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+// encryptPlaintextTOTPSecrets encrypts TOTP secrets stored before they were encrypted
+func (d *Database) encryptPlaintextTOTPSecrets() error {
+	rows, err := d.db.Query("SELECT Id, TOTPSecret FROM Users WHERE TOTPSecret != '' AND TOTPSecret NOT LIKE 'enc:%'")
+	if err != nil {
+		return err
+	}
+
+	secrets := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return err
+		}
+		secrets[id] = secret
+	}
+	rows.Close()
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	key, err := d.totpEncryptionKey()
+	if err != nil {
+		return err
+	}
+	for id, secret := range secrets {
+		encrypted, err := totp.EncryptSecret(secret, key)
+		if err != nil {
+			return err
+		}
+		if _, err := d.db.Exec("UPDATE Users SET TOTPSecret = ? WHERE Id = ?", encrypted, id); err != nil {
+			return err
+		}
+	}
+	log.Printf("Encrypted the TOTP secrets of %d users", len(secrets))
+	return nil
+}
+
+// EnableTOTP enables two-factor authentication for a user. The secret is stored encrypted.
 func (d *Database) EnableTOTP(userID int, secret string, backupCodes []string) error {
+	key, err := d.totpEncryptionKey()
+	if err != nil {
+		return fmt.Errorf("failed to get TOTP encryption key: %w", err)
+	}
+	encryptedSecret, err := totp.EncryptSecret(secret, key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+
 	// Hash all backup codes
 	hashedCodes := make([]string, len(backupCodes))
 	for i, code := range backupCodes {
@@ -34,7 +244,7 @@ func (d *Database) EnableTOTP(userID int, secret string, backupCodes []string) e
 		UPDATE Users
 		SET TOTPSecret = ?, TOTPEnabled = 1, BackupCodes = ?
 		WHERE Id = ?`,
-		secret, string(backupCodesJSON), userID)
+		encryptedSecret, string(backupCodesJSON), userID)
 
 	if err != nil {
 		return fmt.Errorf("failed to enable TOTP: %w", err)
@@ -58,52 +268,67 @@ func (d *Database) DisableTOTP(userID int) error {
 	return nil
 }
 
-// ValidateBackupCode validates a backup code and removes it if valid (one-time use)
-func (d *Database) ValidateBackupCode(userID int, code string) (bool, error) {
-	// Get user's backup codes
-	var backupCodesJSON string
-	err := d.db.QueryRow(`SELECT BackupCodes FROM Users WHERE Id = ?`, userID).Scan(&backupCodesJSON)
-	if err != nil {
-		return false, fmt.Errorf("failed to get backup codes: %w", err)
-	}
-
-	if backupCodesJSON == "" {
+// ConsumeBackupCode checks a backup code and, if it matches one of the user's unused codes,
+// removes that code so it can't be used again. Two logins racing with the same code can't
+// both succeed: the codes are only replaced if nobody changed them in between.
+func (d *Database) ConsumeBackupCode(userID int, code string) (bool, error) {
+	code = totp.NormalizeBackupCode(code)
+	if code == "" {
 		return false, nil
 	}
 
-	// Parse JSON
-	var hashedCodes []string
-	if err := json.Unmarshal([]byte(backupCodesJSON), &hashedCodes); err != nil {
-		return false, fmt.Errorf("failed to unmarshal backup codes: %w", err)
-	}
+	for attempt := 0; attempt < 3; attempt++ {
+		var backupCodesJSON string
+		err := d.db.QueryRow(`SELECT BackupCodes FROM Users WHERE Id = ?`, userID).Scan(&backupCodesJSON)
+		if err != nil {
+			return false, fmt.Errorf("failed to get backup codes: %w", err)
+		}
 
-	// Check each code
-	for i, hashedCode := range hashedCodes {
-		if totp.ValidateBackupCode(code, hashedCode) {
-			// Remove used code
-			hashedCodes = append(hashedCodes[:i], hashedCodes[i+1:]...)
+		if backupCodesJSON == "" {
+			return false, nil
+		}
 
-			// Save updated codes
-			updatedJSON, err := json.Marshal(hashedCodes)
-			if err != nil {
-				return false, fmt.Errorf("failed to marshal updated codes: %w", err)
-			}
+		var hashedCodes []string
+		if err := json.Unmarshal([]byte(backupCodesJSON), &hashedCodes); err != nil {
+			return false, fmt.Errorf("failed to unmarshal backup codes: %w", err)
+		}
 
-			_, err = d.db.Exec(`UPDATE Users SET BackupCodes = ? WHERE Id = ?`,
-				string(updatedJSON), userID)
-			if err != nil {
-				return false, fmt.Errorf("failed to update backup codes: %w", err)
+		matched := -1
+		for i, hashedCode := range hashedCodes {
+			if totp.ValidateBackupCode(code, hashedCode) {
+				matched = i
+				break
 			}
+		}
+		if matched < 0 {
+			return false, nil
+		}
+
+		// Remove used code
+		hashedCodes = append(hashedCodes[:matched], hashedCodes[matched+1:]...)
+		updatedJSON, err := json.Marshal(hashedCodes)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal updated codes: %w", err)
+		}
 
+		result, err := d.db.Exec(`UPDATE Users SET BackupCodes = ? WHERE Id = ? AND BackupCodes = ?`,
+			string(updatedJSON), userID, backupCodesJSON)
+		if err != nil {
+			return false, fmt.Errorf("failed to update backup codes: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 1 {
 			return true, nil
 		}
+		// The codes changed since we read them; check again against the current set
 	}
 
-	return false, nil
+	return false, fmt.Errorf("backup codes kept changing while being used")
 }
 
-// RegenerateBackupCodes generates new backup codes for a user
-func (d *Database) RegenerateBackupCodes(userID int) ([]string, error) {
+// GenerateBackupCodes replaces the user's backup codes with BackupCodeCount new ones, which
+// invalidates the old set. Only hashes are stored, so the returned codes must be shown to the
+// user now.
+func (d *Database) GenerateBackupCodes(userID int) ([]string, error) {
 	// Generate new codes
 	codes, err := totp.GenerateBackupCodes()
 	if err != nil {
@@ -0,0 +1,85 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/totp"
+)
+
+// storeLegacyTOTPKey sets up a user whose TOTP secret is encrypted with a key stored in the
+// database, the way earlier versions kept it
+func storeLegacyTOTPKey(t *testing.T, secret string) (userId int, legacy []byte) {
+	t.Helper()
+	legacy = bytes.Repeat([]byte{7}, 32)
+	encrypted, err := totp.EncryptSecret(secret, legacy)
+	if err != nil {
+		t.Fatalf("EncryptSecret: %v", err)
+	}
+	user := createTestUser(t, "user@example.com", 1000)
+	if _, err := DB.db.Exec("UPDATE Users SET TOTPSecret = ?, TOTPEnabled = 1 WHERE Id = ?", encrypted, user.Id); err != nil {
+		t.Fatalf("storing TOTP secret: %v", err)
+	}
+	if err := DB.SetConfigValue("totp_encryption_key", hex.EncodeToString(legacy)); err != nil {
+		t.Fatalf("SetConfigValue: %v", err)
+	}
+	return user.Id, legacy
+}
+
+func checkTOTPSecret(t *testing.T, userId int, want string) {
+	t.Helper()
+	user, err := DB.GetUserByID(userId)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if got, err := DB.GetTOTPSecret(user); err != nil || got != want {
+		t.Errorf("GetTOTPSecret = %q, %v; want %q", got, err, want)
+	}
+	if stored, _ := DB.GetConfigValue("totp_encryption_key"); stored != "" {
+		t.Errorf("TOTP encryption key still stored in the database")
+	}
+}
+
+// A key stored in the database by an earlier version moves to the key file
+func TestTOTPKeyMovedToKeyFile(t *testing.T) {
+	t.Setenv(TOTPKeyEnv, "")
+	setupTestDB(t)
+	userId, legacy := storeLegacyTOTPKey(t, "JBSWY3DPEHPK3PXP")
+
+	if err := DB.moveTOTPKeyOutOfDatabase(); err != nil {
+		t.Fatalf("moveTOTPKeyOutOfDatabase: %v", err)
+	}
+	data, err := os.ReadFile(DB.totpKeyPath())
+	if err != nil {
+		t.Fatalf("reading key file: %v", err)
+	}
+	if key, _ := hex.DecodeString(string(bytes.TrimSpace(data))); !bytes.Equal(key, legacy) {
+		t.Errorf("key file does not hold the key from the database")
+	}
+	if info, err := os.Stat(DB.totpKeyPath()); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode %v, want 0600", info.Mode().Perm())
+	}
+	checkTOTPSecret(t, userId, "JBSWY3DPEHPK3PXP")
+}
+
+// With a key set in the environment, secrets encrypted with the old key are encrypted again
+func TestTOTPKeyFromEnvironment(t *testing.T) {
+	t.Setenv(TOTPKeyEnv, hex.EncodeToString(bytes.Repeat([]byte{9}, 32)))
+	setupTestDB(t)
+	userId, _ := storeLegacyTOTPKey(t, "JBSWY3DPEHPK3PXP")
+
+	if err := DB.moveTOTPKeyOutOfDatabase(); err != nil {
+		t.Fatalf("moveTOTPKeyOutOfDatabase: %v", err)
+	}
+	if _, err := os.Stat(DB.totpKeyPath()); !os.IsNotExist(err) {
+		t.Errorf("key file created although the key is set in the environment")
+	}
+	checkTOTPSecret(t, userId, "JBSWY3DPEHPK3PXP")
+}
@@ -0,0 +1,55 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetTrashRetentionOverrideBounds returns the range of days a file may be kept in trash when
+// its retention is chosen at delete time. max is 0 when per-file retention is turned off.
+func (d *Database) GetTrashRetentionOverrideBounds() (min, max int) {
+	min = d.GetConfigInt("trash_retention_min_days", 1)
+	max = d.GetConfigInt("trash_retention_max_days", 0)
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		return min, 0
+	}
+	return min, max
+}
+
+// ValidateTrashRetentionOverride checks a per-file trash retention against the configured bounds
+func (d *Database) ValidateTrashRetentionOverride(days int) error {
+	min, max := d.GetTrashRetentionOverrideBounds()
+	if max == 0 {
+		return fmt.Errorf("per-file trash retention is not enabled")
+	}
+	if days < min || days > max {
+		return fmt.Errorf("trash retention must be between %d and %d days", min, max)
+	}
+	return nil
+}
+
+// GetFileTrashRetention returns the trash retention chosen when a file was deleted, or 0 if it
+// uses the global retention period
+func (d *Database) GetFileTrashRetention(fileId string) int {
+	var days int
+	d.db.QueryRow("SELECT COALESCE(TrashRetentionDays, 0) FROM Files WHERE Id = ?", fileId).Scan(&days)
+	return days
+}
+
+// TrashPurgeTime returns when a file in trash is permanently deleted, given the global
+// retention period
+func (d *Database) TrashPurgeTime(file *FileInfo, defaultRetentionDays int) time.Time {
+	days := d.GetFileTrashRetention(file.Id)
+	if days <= 0 {
+		days = defaultRetentionDays
+	}
+	return time.Unix(file.DeletedAt, 0).Add(time.Duration(days) * 24 * time.Hour)
+}
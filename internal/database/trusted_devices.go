@@ -0,0 +1,188 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTrustedDeviceDays is used when trusted_device_days is not configured
+const DefaultTrustedDeviceDays = 30
+
+// TrustedDevice is a browser that may skip the 2FA step at login until it expires
+type TrustedDevice struct {
+	Id         int    `json:"id"`
+	UserId     int    `json:"userId"`
+	Name       string `json:"name"`
+	IPAddress  string `json:"ipAddress"`
+	CreatedAt  int64  `json:"createdAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	LastUsedAt int64  `json:"lastUsedAt"`
+}
+
+// GetTrustedDeviceDays returns how long a device stays trusted after 2FA (0 = remembering is disabled)
+func (d *Database) GetTrustedDeviceDays() int {
+	return d.GetConfigInt("trusted_device_days", DefaultTrustedDeviceDays)
+}
+
+// trustedDeviceSecret returns the key that signs trusted device tokens, creating it on first use
+func (d *Database) trustedDeviceSecret() ([]byte, error) {
+	secret, err := d.GetConfigValue("trusted_device_secret")
+	if err == nil && secret != "" {
+		return hex.DecodeString(secret)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := d.SetConfigValue("trusted_device_secret", hex.EncodeToString(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// signTrustedDeviceToken returns the signature binding a device token to a user
+func (d *Database) signTrustedDeviceToken(userId int, token string) (string, error) {
+	key, err := d.trustedDeviceSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d:%s", userId, token)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func hashTrustedDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateTrustedDevice remembers a device for the user and returns the signed token for its cookie
+func (d *Database) CreateTrustedDevice(userId int, name, ipAddress string, duration time.Duration) (*TrustedDevice, string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, "", err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	signature, err := d.signTrustedDeviceToken(userId, token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	device := &TrustedDevice{
+		UserId:     userId,
+		Name:       name,
+		IPAddress:  ipAddress,
+		CreatedAt:  now.Unix(),
+		ExpiresAt:  now.Add(duration).Unix(),
+		LastUsedAt: now.Unix(),
+	}
+
+	// Drop this user's expired devices while we're here
+	d.db.Exec("DELETE FROM TrustedDevices WHERE UserId = ? AND ExpiresAt <= ?", userId, now.Unix())
+
+	result, err := d.db.Exec(`
+		INSERT INTO TrustedDevices (UserId, TokenHash, Name, IPAddress, CreatedAt, ExpiresAt, LastUsedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		device.UserId, hashTrustedDeviceToken(token), device.Name, device.IPAddress,
+		device.CreatedAt, device.ExpiresAt, device.LastUsedAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, _ := result.LastInsertId()
+	device.Id = int(id)
+	return device, token + "." + signature, nil
+}
+
+// ValidateTrustedDevice checks a signed device token from a cookie and returns the device if it
+// belongs to the user and hasn't expired or been revoked
+func (d *Database) ValidateTrustedDevice(userId int, signedToken string) (*TrustedDevice, error) {
+	token, signature, ok := strings.Cut(signedToken, ".")
+	if !ok || token == "" {
+		return nil, errors.New("malformed device token")
+	}
+
+	expected, err := d.signTrustedDeviceToken(userId, token)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return nil, errors.New("invalid device token signature")
+	}
+
+	device := &TrustedDevice{}
+	err = d.db.QueryRow(`
+		SELECT Id, UserId, Name, IPAddress, CreatedAt, ExpiresAt, LastUsedAt
+		FROM TrustedDevices
+		WHERE UserId = ? AND TokenHash = ? AND ExpiresAt > ?`,
+		userId, hashTrustedDeviceToken(token), time.Now().Unix(),
+	).Scan(&device.Id, &device.UserId, &device.Name, &device.IPAddress, &device.CreatedAt, &device.ExpiresAt, &device.LastUsedAt)
+	if err != nil {
+		return nil, errors.New("device is not trusted")
+	}
+
+	device.LastUsedAt = time.Now().Unix()
+	d.db.Exec("UPDATE TrustedDevices SET LastUsedAt = ? WHERE Id = ?", device.LastUsedAt, device.Id)
+	return device, nil
+}
+
+// GetTrustedDevices returns a user's unexpired trusted devices, most recently used first
+func (d *Database) GetTrustedDevices(userId int) ([]*TrustedDevice, error) {
+	rows, err := d.db.Query(`
+		SELECT Id, UserId, Name, IPAddress, CreatedAt, ExpiresAt, LastUsedAt
+		FROM TrustedDevices
+		WHERE UserId = ? AND ExpiresAt > ?
+		ORDER BY LastUsedAt DESC`, userId, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*TrustedDevice
+	for rows.Next() {
+		device := &TrustedDevice{}
+		if err := rows.Scan(&device.Id, &device.UserId, &device.Name, &device.IPAddress,
+			&device.CreatedAt, &device.ExpiresAt, &device.LastUsedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// RevokeTrustedDevice removes one of the user's trusted devices
+func (d *Database) RevokeTrustedDevice(userId, deviceId int) error {
+	result, err := d.db.Exec("DELETE FROM TrustedDevices WHERE Id = ? AND UserId = ?", deviceId, userId)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("trusted device not found")
+	}
+	return nil
+}
+
+// RevokeAllTrustedDevices removes all of the user's trusted devices and returns how many there were
+func (d *Database) RevokeAllTrustedDevices(userId int) (int, error) {
+	result, err := d.db.Exec("DELETE FROM TrustedDevices WHERE UserId = ?", userId)
+	if err != nil {
+		return 0, err
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
@@ -0,0 +1,56 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import "strings"
+
+// UploadFileTypePolicy is which files may be uploaded, as set in the server settings. Empty
+// allow lists allow every type that isn't blocked.
+type UploadFileTypePolicy struct {
+	AllowedExtensions []string // lower case, without the dot
+	BlockedExtensions []string
+	AllowedMIMETypes  []string // lower case; "image/*" matches every image type
+	BlockedMIMETypes  []string
+	VerifyContent     bool // reject files whose content doesn't match their extension
+}
+
+// Active reports whether the policy restricts uploads at all
+func (p *UploadFileTypePolicy) Active() bool {
+	return len(p.AllowedExtensions) > 0 || len(p.BlockedExtensions) > 0 ||
+		len(p.AllowedMIMETypes) > 0 || len(p.BlockedMIMETypes) > 0 || p.VerifyContent
+}
+
+// GetUploadFileTypePolicy returns which files may be uploaded
+func (d *Database) GetUploadFileTypePolicy() *UploadFileTypePolicy {
+	list := func(key string, trim string) []string {
+		value, _ := d.GetConfigValue(key)
+		return ParseFileTypeList(value, trim)
+	}
+	verify, _ := d.GetConfigValue("upload_verify_file_type")
+	return &UploadFileTypePolicy{
+		AllowedExtensions: list("upload_allowed_extensions", "*."),
+		BlockedExtensions: list("upload_blocked_extensions", "*."),
+		AllowedMIMETypes:  list("upload_allowed_mime_types", ""),
+		BlockedMIMETypes:  list("upload_blocked_mime_types", ""),
+		VerifyContent:     verify == "true",
+	}
+}
+
+// ParseFileTypeList splits a list of extensions or MIME types separated by commas, spaces or
+// new lines into lower-case entries, with the leading characters in trim removed (e.g. "*." to
+// turn "*.EXE" into "exe")
+func ParseFileTypeList(value, trim string) []string {
+	var entries []string
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	}) {
+		entry = strings.TrimLeft(strings.ToLower(entry), trim)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
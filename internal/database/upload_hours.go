@@ -0,0 +1,56 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+// Upload windows limit the hours and weekdays uploads are accepted. They use the daily hours,
+// days and timezone of a FileSchedule; an empty schedule means uploads are always open.
+
+// GetGlobalUploadWindow returns the hours uploads are open for all users
+func (d *Database) GetGlobalUploadWindow() *FileSchedule {
+	sch := &FileSchedule{}
+	sch.DailyStart, _ = d.GetConfigValue("upload_window_start")
+	sch.DailyEnd, _ = d.GetConfigValue("upload_window_end")
+	sch.Days, _ = d.GetConfigValue("upload_window_days")
+	sch.Timezone, _ = d.GetConfigValue("upload_window_timezone")
+	return sch
+}
+
+// SetGlobalUploadWindow stores the hours uploads are open for all users (an empty schedule clears it)
+func (d *Database) SetGlobalUploadWindow(sch *FileSchedule) error {
+	values := map[string]string{
+		"upload_window_start":    sch.DailyStart,
+		"upload_window_end":      sch.DailyEnd,
+		"upload_window_days":     sch.Days,
+		"upload_window_timezone": sch.Timezone,
+	}
+	for key, value := range values {
+		if err := d.SetConfigValue(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserUploadWindow returns the hours a user may upload, on top of the global window
+func (d *Database) GetUserUploadWindow(userId int) (*FileSchedule, error) {
+	sch := &FileSchedule{}
+	err := d.db.QueryRow(`
+		SELECT COALESCE(UploadWindowStart, ''), COALESCE(UploadWindowEnd, ''),
+		       COALESCE(UploadWindowDays, ''), COALESCE(UploadWindowTimezone, '')
+		FROM Users WHERE Id = ?`, userId).Scan(&sch.DailyStart, &sch.DailyEnd, &sch.Days, &sch.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// SetUserUploadWindow stores the hours a user may upload (an empty schedule clears it)
+func (d *Database) SetUserUploadWindow(userId int, sch *FileSchedule) error {
+	_, err := d.db.Exec(`
+		UPDATE Users SET UploadWindowStart = ?, UploadWindowEnd = ?, UploadWindowDays = ?, UploadWindowTimezone = ?
+		WHERE Id = ?`, sch.DailyStart, sch.DailyEnd, sch.Days, sch.Timezone, userId)
+	return err
+}
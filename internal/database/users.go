@@ -113,6 +113,20 @@ func (d *Database) GetUserByEmail(email string) (*models.User, error) {
 	return user, nil
 }
 
+// GetUserByEmailIgnoreCase retrieves a user by email, ignoring case, for emails that come from
+// other systems such as a single sign-on provider
+func (d *Database) GetUserByEmailIgnoreCase(email string) (*models.User, error) {
+	var id int
+	err := d.db.QueryRow("SELECT Id FROM Users WHERE Email = ? COLLATE NOCASE ORDER BY Id LIMIT 1", email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return d.GetUserByID(id)
+}
+
 // GetUserByName retrieves a user by username
 func (d *Database) GetUserByName(name string) (*models.User, error) {
 	user := &models.User{}
@@ -332,6 +346,42 @@ func (d *Database) UpdateUserStorage(id int, storageUsedMB int64) error {
 	return err
 }
 
+// BytesPerMB is the size of the megabytes storage usage and quotas are counted in
+const BytesPerMB = 1024 * 1024
+
+// fileStorageMBSQL is StorageMB of a file's SizeBytes, for queries on Files
+const fileStorageMBSQL = "((SizeBytes + 1048575) / 1048576)"
+
+// StorageMB returns how many megabytes a file counts toward its owner's storage usage. Every
+// started megabyte counts, so files under a megabyte can't be uploaded past a quota.
+func StorageMB(sizeBytes int64) int64 {
+	return (sizeBytes + BytesPerMB - 1) / BytesPerMB
+}
+
+// AdjustUserStorage adds deltaMB (negative to release space) to a user's storage usage in a
+// single statement, so parallel uploads and deletes can't overwrite each other's changes.
+// Usage never drops below zero.
+func (d *Database) AdjustUserStorage(id int, deltaMB int64) error {
+	_, err := d.db.Exec("UPDATE Users SET StorageUsedMB = MAX(0, StorageUsedMB + ?) WHERE Id = ?", deltaMB, id)
+	return err
+}
+
+// ReconcileUserStorage recounts every user's storage usage from their files. Usage is kept up to
+// date with AdjustUserStorage, which relies on the stored value being right to begin with.
+func (d *Database) ReconcileUserStorage() (int64, error) {
+	result, err := d.db.Exec(`
+		UPDATE Users SET StorageUsedMB = (
+			SELECT COALESCE(SUM(` + fileStorageMBSQL + `), 0) FROM Files WHERE Files.UserId = Users.Id AND Files.DeletedAt = 0
+		)
+		WHERE StorageUsedMB != (
+			SELECT COALESCE(SUM(` + fileStorageMBSQL + `), 0) FROM Files WHERE Files.UserId = Users.Id AND Files.DeletedAt = 0
+		)`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // DeleteUser deletes a user by ID
 // Before deletion, all user's files are moved to trash (soft-deleted)
 func (d *Database) DeleteUser(id int, deletedBy int) error {
@@ -0,0 +1,105 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"time"
+)
+
+// Virus scan results stored per file. Files uploaded while scanning was off have no status.
+const (
+	ScanPending  = "pending"
+	ScanClean    = "clean"
+	ScanInfected = "infected" // quarantined: downloads are blocked
+	ScanError    = "error"    // clamd could not be reached or could not scan the file
+)
+
+// IsVirusScanningEnabled reports whether uploads are scanned with ClamAV (default off)
+func (d *Database) IsVirusScanningEnabled() bool {
+	value, _ := d.GetConfigValue("clamav_enabled")
+	return value == "true"
+}
+
+// GetClamAVAddress returns the clamd socket path or TCP address uploads are scanned with
+func (d *Database) GetClamAVAddress() string {
+	value, _ := d.GetConfigValue("clamav_address")
+	return value
+}
+
+// IsDeleteInfectedEnabled reports whether infected files are deleted right away instead of
+// being kept in quarantine (default off)
+func (d *Database) IsDeleteInfectedEnabled() bool {
+	value, _ := d.GetConfigValue("clamav_delete_infected")
+	return value == "true"
+}
+
+// IsScanFailOpenEnabled reports whether files clamd could not scan can still be downloaded
+// (default off: they are blocked until a rescan clears them)
+func (d *Database) IsScanFailOpenEnabled() bool {
+	value, _ := d.GetConfigValue("clamav_fail_open")
+	return value == "true"
+}
+
+// SetFileScanStatus records the virus scan status of a file and, for infected files, the name
+// of the malware found
+func (d *Database) SetFileScanStatus(fileId, status, signature string) error {
+	scannedAt := int64(0)
+	if status != ScanPending {
+		scannedAt = time.Now().Unix()
+	}
+	_, err := d.db.Exec("UPDATE Files SET ScanStatus = ?, ScanSignature = ?, ScannedAt = ? WHERE Id = ?",
+		status, signature, scannedAt, fileId)
+	return err
+}
+
+// GetFileScanSignature returns the name of the malware found in a file, "" if none was found
+func (d *Database) GetFileScanSignature(fileId string) string {
+	var signature string
+	d.db.QueryRow("SELECT COALESCE(ScanSignature, '') FROM Files WHERE Id = ?", fileId).Scan(&signature)
+	return signature
+}
+
+// CountFilesByScanStatus returns how many active files have each virus scan status
+func (d *Database) CountFilesByScanStatus() (map[string]int, error) {
+	rows, err := d.db.Query(`
+		SELECT COALESCE(ScanStatus, ''), COUNT(*) FROM Files
+		WHERE DeletedAt = 0 AND COALESCE(ScanStatus, '') != ''
+		GROUP BY COALESCE(ScanStatus, '')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetFileIDsByScanStatus returns the active files with a virus scan status
+func (d *Database) GetFileIDsByScanStatus(status string) ([]string, error) {
+	rows, err := d.db.Query("SELECT Id FROM Files WHERE DeletedAt = 0 AND ScanStatus = ?", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,187 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Events webhooks can subscribe to
+const (
+	WebhookEventFileUploaded   = "file.uploaded"
+	WebhookEventFileDownloaded = "file.downloaded"
+	WebhookEventFileExpired    = "file.expired"
+)
+
+// WebhookEvents lists every webhook event in the order they are shown
+var WebhookEvents = []string{WebhookEventFileUploaded, WebhookEventFileDownloaded, WebhookEventFileExpired}
+
+var webhookEventLabels = map[string]string{
+	WebhookEventFileUploaded:   "File uploaded",
+	WebhookEventFileDownloaded: "File downloaded",
+	WebhookEventFileExpired:    "File expired",
+}
+
+// WebhookEventLabel returns the display name of a webhook event
+func WebhookEventLabel(event string) string {
+	if label, ok := webhookEventLabels[event]; ok {
+		return label
+	}
+	return event
+}
+
+// IsValidWebhookEvent reports whether event is one of WebhookEvents
+func IsValidWebhookEvent(event string) bool {
+	_, ok := webhookEventLabels[event]
+	return ok
+}
+
+// Webhook is an endpoint that file events are POSTed to, signed with its secret
+type Webhook struct {
+	Id             int
+	URL            string
+	Secret         string
+	Events         []string
+	IsActive       bool
+	CreatedAt      int64
+	LastDeliveryAt int64
+	LastStatus     string // result of the last delivery, "" if none was made yet
+}
+
+// Subscribes reports whether the webhook wants an event
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhook registers an endpoint for the given events
+func (d *Database) CreateWebhook(url, secret string, events []string) (*Webhook, error) {
+	if len(events) == 0 {
+		return nil, errors.New("select at least one event")
+	}
+	for _, event := range events {
+		if !IsValidWebhookEvent(event) {
+			return nil, errors.New("unknown webhook event: " + event)
+		}
+	}
+
+	webhook := &Webhook{
+		URL:       url,
+		Secret:    secret,
+		Events:    events,
+		IsActive:  true,
+		CreatedAt: time.Now().Unix(),
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO webhooks (url, secret, events, is_active, created_at, last_delivery_at, last_status)
+		VALUES (?, ?, ?, 1, ?, 0, '')`,
+		webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","), webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	webhook.Id = int(id)
+	return webhook, nil
+}
+
+// GetWebhooks returns all webhooks, oldest first
+func (d *Database) GetWebhooks() ([]*Webhook, error) {
+	return d.queryWebhooks("SELECT id, url, secret, events, is_active, created_at, last_delivery_at, last_status FROM webhooks ORDER BY id")
+}
+
+// GetWebhook returns a webhook by ID
+func (d *Database) GetWebhook(id int) (*Webhook, error) {
+	webhooks, err := d.queryWebhooks("SELECT id, url, secret, events, is_active, created_at, last_delivery_at, last_status FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, errors.New("webhook not found")
+	}
+	return webhooks[0], nil
+}
+
+// GetWebhooksForEvent returns the active webhooks that subscribe to an event
+func (d *Database) GetWebhooksForEvent(event string) ([]*Webhook, error) {
+	webhooks, err := d.queryWebhooks("SELECT id, url, secret, events, is_active, created_at, last_delivery_at, last_status FROM webhooks WHERE is_active = 1 ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	var subscribed []*Webhook
+	for _, webhook := range webhooks {
+		if webhook.Subscribes(event) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	return subscribed, nil
+}
+
+func (d *Database) queryWebhooks(query string, args ...interface{}) ([]*Webhook, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		webhook := &Webhook{}
+		var events string
+		var isActive int
+		if err := rows.Scan(&webhook.Id, &webhook.URL, &webhook.Secret, &events, &isActive,
+			&webhook.CreatedAt, &webhook.LastDeliveryAt, &webhook.LastStatus); err != nil {
+			return nil, err
+		}
+		if events != "" {
+			webhook.Events = strings.Split(events, ",")
+		}
+		webhook.IsActive = isActive == 1
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// SetWebhookActive pauses or resumes deliveries to a webhook
+func (d *Database) SetWebhookActive(id int, active bool) error {
+	isActive := 0
+	if active {
+		isActive = 1
+	}
+	result, err := d.db.Exec("UPDATE webhooks SET is_active = ? WHERE id = ?", isActive, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("webhook not found")
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook
+func (d *Database) DeleteWebhook(id int) error {
+	result, err := d.db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("webhook not found")
+	}
+	return nil
+}
+
+// RecordWebhookDelivery stores the result of the latest delivery to a webhook
+func (d *Database) RecordWebhookDelivery(id int, status string) error {
+	_, err := d.db.Exec("UPDATE webhooks SET last_delivery_at = ?, last_status = ? WHERE id = ?", time.Now().Unix(), status, id)
+	return err
+}
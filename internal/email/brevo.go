@@ -110,7 +110,7 @@ func (bp *BrevoProvider) SendEmail(to, subject, htmlBody, textBody string) error
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		var errResp map[string]interface{}
 		json.Unmarshal(respBody, &errResp)
-		return fmt.Errorf("%d %s: %v", resp.StatusCode, resp.Status, errResp)
+		return &statusError{code: resp.StatusCode, err: fmt.Errorf("%d %s: %v", resp.StatusCode, resp.Status, errResp)}
 	}
 
 	return nil
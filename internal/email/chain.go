@@ -0,0 +1,194 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package email
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+const (
+	// ProviderFailureLimit is how many sends in a row a provider may fail before it is skipped
+	ProviderFailureLimit = 3
+	// ProviderSkipDuration is how long a failing provider is skipped before it is tried again
+	ProviderSkipDuration = 10 * time.Minute
+)
+
+// ChainedProvider is an enabled email provider in the delivery order
+type ChainedProvider struct {
+	Name string
+	EmailProvider
+}
+
+// ProviderHealth is how a provider has been doing since the server started
+type ProviderHealth struct {
+	ConsecutiveFailures int
+	SkippedUntil        time.Time // zero unless the provider is being skipped
+}
+
+var (
+	healthMu       sync.Mutex
+	providerHealth = map[string]*ProviderHealth{}
+)
+
+// GetProviderHealth returns the health of a provider
+func GetProviderHealth(name string) ProviderHealth {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	if health, ok := providerHealth[name]; ok {
+		return *health
+	}
+	return ProviderHealth{}
+}
+
+// ResetProviderHealth lets a skipped provider be tried again right away, e.g. after its
+// settings were changed
+func ResetProviderHealth(name string) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	delete(providerHealth, name)
+}
+
+func isProviderSkipped(name string, now time.Time) bool {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	health, ok := providerHealth[name]
+	return ok && now.Before(health.SkippedUntil)
+}
+
+// recordProviderResult updates the health of a provider after a send. A provider that failed
+// ProviderFailureLimit times in a row is skipped for ProviderSkipDuration; after that a single
+// failure skips it again.
+func recordProviderResult(name string, err error) {
+	healthMu.Lock()
+	if err == nil {
+		delete(providerHealth, name)
+	} else {
+		health, ok := providerHealth[name]
+		if !ok {
+			health = &ProviderHealth{}
+			providerHealth[name] = health
+		}
+		health.ConsecutiveFailures++
+		if health.ConsecutiveFailures >= ProviderFailureLimit {
+			health.SkippedUntil = time.Now().Add(ProviderSkipDuration)
+			log.Printf("Email provider %s failed %d times in a row, skipping it until %s", name, health.ConsecutiveFailures, health.SkippedUntil.Format("15:04:05"))
+		}
+	}
+	healthMu.Unlock()
+
+	if dbErr := database.DB.RecordEmailProviderResult(name, err); dbErr != nil {
+		log.Printf("Warning: Could not record result of email provider %s: %v", name, dbErr)
+	}
+}
+
+// GetProviderChain returns the enabled email providers in delivery order. Providers whose
+// settings can't be loaded are left out.
+func GetProviderChain(db *database.Database) ([]ChainedProvider, error) {
+	rows, err := db.Query(`
+		SELECT Provider, ApiKeyEncrypted, SMTPHost, SMTPPort, SMTPUsername,
+		       SMTPPasswordEncrypted, SMTPUseTLS, FromEmail, FromName,
+		       MailgunDomain, MailgunRegion
+		FROM EmailProviderConfig
+		WHERE IsActive = 1
+		ORDER BY Priority, Id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chain []ChainedProvider
+	for rows.Next() {
+		name, provider, err := providerFromRow(db, rows)
+		if err != nil {
+			log.Printf("Skipping email provider %s: %v", name, err)
+			continue
+		}
+		chain = append(chain, ChainedProvider{Name: name, EmailProvider: provider})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no active email provider configured")
+	}
+	return chain, nil
+}
+
+// chainError is the error of a send that failed with every provider
+type chainError struct {
+	message string
+	errs    []error
+}
+
+func (e *chainError) Error() string { return e.message }
+
+// Unwrap returns the providers' errors, so isPermanentSendError sees them
+func (e *chainError) Unwrap() []error { return e.errs }
+
+// SendThroughChain sends an email through the first provider of the chain that accepts it
+// and returns that provider's name. Providers that keep failing are skipped for a while,
+// unless every provider is being skipped. The error of a send that failed everywhere is only
+// permanent if every provider rejected the email outright.
+func SendThroughChain(chain []ChainedProvider, to, subject, htmlBody, textBody string) (string, error) {
+	now := time.Now()
+	tries := make([]ChainedProvider, 0, len(chain))
+	for _, provider := range chain {
+		if !isProviderSkipped(provider.Name, now) {
+			tries = append(tries, provider)
+		}
+	}
+	if len(tries) == 0 {
+		tries = chain
+	}
+
+	var messages []string
+	var errs []error
+	allPermanent := true
+	for _, provider := range tries {
+		err := provider.SendEmail(to, subject, htmlBody, textBody)
+		recordProviderResult(provider.Name, err)
+		if err == nil {
+			if len(errs) > 0 {
+				log.Printf("Email to %s delivered by fallback provider %s after %d failure(s)", to, provider.Name, len(errs))
+			}
+			return provider.Name, nil
+		}
+		log.Printf("Email provider %s failed to send to %s: %v", provider.Name, to, err)
+		messages = append(messages, provider.Name+": "+err.Error())
+		errs = append(errs, err)
+		allPermanent = allPermanent && isPermanentSendError(err)
+	}
+
+	if len(errs) == 0 {
+		return "", errors.New("no active email provider configured")
+	}
+	message := strings.Join(messages, "; ")
+	if !allPermanent {
+		return "", errors.New(message)
+	}
+	return "", &chainError{message: message, errs: errs}
+}
+
+// failoverProvider sends through the provider chain. Only SendEmail walks the chain: it is
+// always wrapped in a footerProvider, which builds the notification emails itself and sends
+// them through SendEmail.
+type failoverProvider struct {
+	EmailProvider
+	chain []ChainedProvider
+}
+
+// SendEmail sends an email through the first provider of the chain that accepts it
+func (fp *failoverProvider) SendEmail(to, subject, htmlBody, textBody string) error {
+	_, err := SendThroughChain(fp.chain, to, subject, htmlBody, textBody)
+	return err
+}
@@ -8,6 +8,7 @@ package email
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 
 	"github.com/Frimurare/WulfVault/internal/database"
@@ -37,124 +38,152 @@ func NewEmailService(provider EmailProvider, db *database.Database) *EmailServic
 	}
 }
 
-// GetActiveProvider hämtar den aktiva e-postleverantören från databasen
+// GetActiveProvider returnerar en leverantör som skickar genom de aktiva leverantörerna i
+// leveransordning (se GetProviderChain) och går vidare till nästa om en misslyckas. Alla mejl
+// som skickas genom den får installationens sidfot (se footer.go).
 func GetActiveProvider(db *database.Database) (EmailProvider, error) {
-	var provider string
-	var apiKeyEncrypted, smtpHost, smtpUsername, smtpPasswordEncrypted, fromEmail, fromName sql.NullString
-	var mailgunDomain, mailgunRegion sql.NullString
-	var smtpPort, smtpUseTLS sql.NullInt64
+	chain, err := GetProviderChain(db)
+	if err != nil {
+		return nil, err
+	}
+	return &footerProvider{EmailProvider: &failoverProvider{EmailProvider: chain[0].EmailProvider, chain: chain}}, nil
+}
 
+// GetSavedProvider hämtar en sparad leverantör oavsett om den är aktiv, t.ex. för att testa
+// inställningarna innan den aktiveras. Den får ingen sidfot.
+func GetSavedProvider(db *database.Database, name string) (EmailProvider, error) {
 	row := db.QueryRow(`
 		SELECT Provider, ApiKeyEncrypted, SMTPHost, SMTPPort, SMTPUsername,
 		       SMTPPasswordEncrypted, SMTPUseTLS, FromEmail, FromName,
 		       MailgunDomain, MailgunRegion
 		FROM EmailProviderConfig
-		WHERE IsActive = 1
+		WHERE Provider = ?
 		LIMIT 1
-	`)
+	`, name)
+	_, provider, err := providerFromRow(db, row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New(name + " provider is not configured")
+	}
+	return provider, err
+}
+
+// rowScanner är en *sql.Row eller *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// providerFromRow skapar leverantören från en rad i EmailProviderConfig och returnerar dess namn
+func providerFromRow(db *database.Database, row rowScanner) (string, EmailProvider, error) {
+	var provider string
+	var apiKeyEncrypted, smtpHost, smtpUsername, smtpPasswordEncrypted, fromEmail, fromName sql.NullString
+	var mailgunDomain, mailgunRegion sql.NullString
+	var smtpPort, smtpUseTLS sql.NullInt64
 
 	err := row.Scan(&provider, &apiKeyEncrypted, &smtpHost, &smtpPort,
 		&smtpUsername, &smtpPasswordEncrypted, &smtpUseTLS, &fromEmail, &fromName,
 		&mailgunDomain, &mailgunRegion)
 	if err != nil {
-		log.Printf("GetActiveProvider scan error: %v", err)
-		return nil, errors.New("no active email provider configured")
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Email provider scan error: %v", err)
+		}
+		return "", nil, err
 	}
 
-	log.Printf("GetActiveProvider found: provider=%s, hasApiKey=%v, fromEmail=%v",
+	log.Printf("Email provider found: provider=%s, hasApiKey=%v, fromEmail=%v",
 		provider, apiKeyEncrypted.Valid, fromEmail.Valid)
 
 	// Hämta master key för dekryptering
 	masterKey, err := GetOrCreateMasterKey(db)
 	if err != nil {
-		return nil, err
+		return provider, nil, err
 	}
 
 	switch provider {
 	case "brevo":
 		if !apiKeyEncrypted.Valid || apiKeyEncrypted.String == "" {
-			return nil, errors.New("brevo API key not configured")
+			return provider, nil, errors.New("brevo API key not configured")
 		}
 		apiKey, err := DecryptAPIKey(apiKeyEncrypted.String, masterKey)
 		if err != nil {
 			log.Printf("Failed to decrypt Brevo API key: %v", err)
-			return nil, err
+			return provider, nil, err
 		}
 		prefix := apiKey
 		if len(apiKey) > 10 {
 			prefix = apiKey[:10]
 		}
 		log.Printf("Decrypted API key length: %d chars, starts with: %s...", len(apiKey), prefix)
-		return NewBrevoProvider(apiKey, fromEmail.String, fromName.String), nil
+		return provider, NewBrevoProvider(apiKey, fromEmail.String, fromName.String), nil
 
 	case "mailgun":
 		if !apiKeyEncrypted.Valid || apiKeyEncrypted.String == "" {
-			return nil, errors.New("mailgun API key not configured")
+			return provider, nil, errors.New("mailgun API key not configured")
 		}
 		if !mailgunDomain.Valid || mailgunDomain.String == "" {
-			return nil, errors.New("mailgun domain not configured")
+			return provider, nil, errors.New("mailgun domain not configured")
 		}
 		apiKey, err := DecryptAPIKey(apiKeyEncrypted.String, masterKey)
 		if err != nil {
 			log.Printf("Failed to decrypt Mailgun API key: %v", err)
-			return nil, err
+			return provider, nil, err
 		}
 		region := "us"
 		if mailgunRegion.Valid && mailgunRegion.String != "" {
 			region = mailgunRegion.String
 		}
 		log.Printf("Mailgun provider: domain=%s, region=%s", mailgunDomain.String, region)
-		return NewMailgunProvider(apiKey, mailgunDomain.String, fromEmail.String, fromName.String, region), nil
+		return provider, NewMailgunProvider(apiKey, mailgunDomain.String, fromEmail.String, fromName.String, region), nil
 
 	case "sendgrid":
 		if !apiKeyEncrypted.Valid || apiKeyEncrypted.String == "" {
-			return nil, errors.New("sendgrid API key not configured")
+			return provider, nil, errors.New("sendgrid API key not configured")
 		}
 		apiKey, err := DecryptAPIKey(apiKeyEncrypted.String, masterKey)
 		if err != nil {
 			log.Printf("Failed to decrypt SendGrid API key: %v", err)
-			return nil, err
+			return provider, nil, err
 		}
 		log.Printf("SendGrid provider loaded")
-		return NewSendGridProvider(apiKey, fromEmail.String, fromName.String), nil
+		return provider, NewSendGridProvider(apiKey, fromEmail.String, fromName.String), nil
 
 	case "resend":
 		if !apiKeyEncrypted.Valid || apiKeyEncrypted.String == "" {
-			return nil, errors.New("resend API key not configured")
+			return provider, nil, errors.New("resend API key not configured")
 		}
 		apiKey, err := DecryptAPIKey(apiKeyEncrypted.String, masterKey)
 		if err != nil {
 			log.Printf("Failed to decrypt Resend API key: %v", err)
-			return nil, err
+			return provider, nil, err
 		}
 		log.Printf("Resend provider loaded")
-		return NewResendProvider(apiKey, fromEmail.String, fromName.String), nil
+		return provider, NewResendProvider(apiKey, fromEmail.String, fromName.String), nil
 
 	case "smtp":
 		if !smtpPasswordEncrypted.Valid || smtpPasswordEncrypted.String == "" {
-			return nil, errors.New("SMTP password not configured")
+			return provider, nil, errors.New("SMTP password not configured")
 		}
 		password, err := DecryptAPIKey(smtpPasswordEncrypted.String, masterKey)
 		if err != nil {
-			return nil, err
+			return provider, nil, err
 		}
 		useTLS := smtpUseTLS.Valid && smtpUseTLS.Int64 == 1
-		return NewSMTPProvider(smtpHost.String, int(smtpPort.Int64), smtpUsername.String, password, fromEmail.String, fromName.String, useTLS), nil
+		return provider, NewSMTPProvider(smtpHost.String, int(smtpPort.Int64), smtpUsername.String, password, fromEmail.String, fromName.String, useTLS), nil
 
 	default:
-		return nil, errors.New("unknown email provider: " + provider)
+		return provider, nil, errors.New("unknown email provider: " + provider)
 	}
 }
 
-// SendFileUploadNotification skickar notifiering när fil laddats upp via request
-func SendFileUploadNotification(request *models.FileRequest, file *database.FileInfo, uploaderIP, serverURL string, recipientEmail string) error {
-	provider, err := GetActiveProvider(database.DB)
-	if err != nil {
-		log.Printf("Email not configured, skipping upload notification: %v", err)
-		return nil // Don't fail the upload if email fails
-	}
-
-	return provider.SendFileUploadNotification(request, file, uploaderIP, serverURL, recipientEmail)
+// QueueFileUploadNotification köar notifieringen när en fil laddats upp via request
+func QueueFileUploadNotification(request *models.FileRequest, file *database.FileInfo, uploaderIP, serverURL string, recipientEmail string) error {
+	return Enqueue(&database.QueuedEmail{
+		Kind:      database.EmailKindFileRequest,
+		Recipient: recipientEmail,
+		Subject:   "Ny fil uppladdad: " + request.Title,
+		HTMLBody:  GenerateUploadNotificationHTML(request, file, uploaderIP, serverURL),
+		TextBody:  GenerateUploadNotificationText(request, file, uploaderIP, serverURL),
+		Category:  database.NotifyFileRequests,
+	})
 }
 
 // SendFileDownloadNotification skickar notifiering när fil laddas ner
@@ -168,6 +197,52 @@ func SendFileDownloadNotification(file *database.FileInfo, downloaderIP, serverU
 	return provider.SendFileDownloadNotification(file, downloaderIP, serverURL, recipientEmail)
 }
 
+// QueueDownloadNotification queues the email telling a file's owner about one download. It
+// goes through the email queue, so it is retried on the provider chain and shows up in the
+// file's email log.
+func QueueDownloadNotification(file *database.FileInfo, download *models.DownloadLog, serverURL string, owner *models.User) error {
+	if _, err := GetActiveProvider(database.DB); err != nil {
+		log.Printf("Email not configured, skipping download notification: %v", err)
+		return nil // Don't fail the download if email fails
+	}
+	return Enqueue(&database.QueuedEmail{
+		Kind:          database.EmailKindDownloadNotice,
+		Recipient:     owner.Email,
+		Subject:       "Your file was downloaded: " + file.Name,
+		HTMLBody:      GenerateDownloadNoticeHTML(file, download, serverURL),
+		TextBody:      GenerateDownloadNoticeText(file, download, serverURL),
+		Category:      database.NotifyDownloads,
+		FileId:        file.Id,
+		FileName:      file.Name,
+		FileSize:      file.SizeBytes,
+		SenderUserId:  owner.Id,
+		SenderMessage: "Download notification",
+		DedupKey:      database.NewEmailSendKey(file.Id, owner.Email),
+	})
+}
+
+// QueueDownloadDigest queues the email that sums up downloads of a file that were batched
+func QueueDownloadDigest(file *database.FileInfo, downloads []*models.DownloadLog, serverURL string, owner *models.User) error {
+	if _, err := GetActiveProvider(database.DB); err != nil {
+		log.Printf("Email not configured, skipping download summary: %v", err)
+		return nil
+	}
+	return Enqueue(&database.QueuedEmail{
+		Kind:          database.EmailKindDownloadDigest,
+		Recipient:     owner.Email,
+		Subject:       fmt.Sprintf("%s was downloaded %d times", file.Name, len(downloads)),
+		HTMLBody:      GenerateDownloadDigestHTML(file, downloads, serverURL),
+		TextBody:      GenerateDownloadDigestText(file, downloads, serverURL),
+		Category:      database.NotifyDownloads,
+		FileId:        file.Id,
+		FileName:      file.Name,
+		FileSize:      file.SizeBytes,
+		SenderUserId:  owner.Id,
+		SenderMessage: fmt.Sprintf("Download summary (%d downloads)", len(downloads)),
+		DedupKey:      database.NewEmailSendKey(file.Id, owner.Email),
+	})
+}
+
 // SendSplashLinkEmail skickar splash link via e-post
 func SendSplashLinkEmail(to, splashLink string, file *database.FileInfo, message string) error {
 	provider, err := GetActiveProvider(database.DB)
@@ -0,0 +1,204 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// MaxFooterLength caps the footer text administrators can add to outgoing emails
+const MaxFooterLength = 1000
+
+// ErrUnsubscribeInvalid is returned for unsubscribe tokens that were altered
+var ErrUnsubscribeInvalid = errors.New("unsubscribe link is not valid")
+
+var (
+	publicURLMu sync.RWMutex
+	publicURL   string
+)
+
+// SetPublicURL sets the address of the server used for links in the email footer
+func SetPublicURL(url string) {
+	publicURLMu.Lock()
+	defer publicURLMu.Unlock()
+	publicURL = strings.TrimSuffix(url, "/")
+}
+
+func getPublicURL() string {
+	publicURLMu.RLock()
+	defer publicURLMu.RUnlock()
+	return publicURL
+}
+
+// GetFooterText returns the footer text (e.g. company name and postal address) added to every email
+func GetFooterText() string {
+	value, _ := database.DB.GetConfigValue("email_footer_text")
+	return strings.TrimSpace(value)
+}
+
+// NormalizeFooterText trims footer text entered in the settings and checks its length
+func NormalizeFooterText(text string) (string, error) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if !utf8.ValidString(text) {
+		return "", errors.New("the footer contains invalid characters")
+	}
+	if utf8.RuneCountInString(text) > MaxFooterLength {
+		return "", fmt.Errorf("the footer can be at most %d characters", MaxFooterLength)
+	}
+	for _, r := range text {
+		if r != '\n' && unicode.IsControl(r) {
+			return "", errors.New("the footer contains invalid characters")
+		}
+	}
+	return text, nil
+}
+
+// footerProvider adds the deployment's footer to every email sent through a provider. Emails
+// of a notification category also get an unsubscribe link when they go to a registered user;
+// emails without one (password resets, security notices, shared files) are transactional.
+type footerProvider struct {
+	EmailProvider
+	category string
+}
+
+// ForCategory marks emails sent through provider as notifications of a category, so the footer
+// offers to unsubscribe from it
+func ForCategory(provider EmailProvider, category string) EmailProvider {
+	if fp, ok := provider.(*footerProvider); ok {
+		return &footerProvider{EmailProvider: fp.EmailProvider, category: category}
+	}
+	return &footerProvider{EmailProvider: provider, category: category}
+}
+
+// SendEmail sends an email with the footer added
+func (fp *footerProvider) SendEmail(to, subject, htmlBody, textBody string) error {
+	htmlBody, textBody = AddFooter(to, fp.category, htmlBody, textBody)
+	return fp.EmailProvider.SendEmail(to, subject, htmlBody, textBody)
+}
+
+// The providers send these through their own SendEmail, so they are built here again to get the footer
+
+func (fp *footerProvider) SendFileUploadNotification(request *models.FileRequest, file *database.FileInfo, uploaderIP, serverURL string, recipientEmail string) error {
+	subject := "Ny fil uppladdad: " + request.Title
+	htmlBody := GenerateUploadNotificationHTML(request, file, uploaderIP, serverURL)
+	textBody := GenerateUploadNotificationText(request, file, uploaderIP, serverURL)
+
+	return ForCategory(fp, database.NotifyFileRequests).SendEmail(recipientEmail, subject, htmlBody, textBody)
+}
+
+func (fp *footerProvider) SendFileDownloadNotification(file *database.FileInfo, downloaderIP, serverURL string, recipientEmail string) error {
+	subject := "Din fil har laddats ner: " + file.Name
+	htmlBody := GenerateDownloadNotificationHTML(file, downloaderIP, serverURL)
+	textBody := GenerateDownloadNotificationText(file, downloaderIP, serverURL)
+
+	return ForCategory(fp, database.NotifyDownloads).SendEmail(recipientEmail, subject, htmlBody, textBody)
+}
+
+func (fp *footerProvider) SendSplashLinkEmail(to, splashLink string, file *database.FileInfo, message string) error {
+	subject := "Delad fil: " + file.Name
+	htmlBody := GenerateSplashLinkHTML(splashLink, file, message)
+	textBody := GenerateSplashLinkText(splashLink, file, message)
+
+	return fp.SendEmail(to, subject, htmlBody, textBody)
+}
+
+func (fp *footerProvider) SendAccountDeletionConfirmation(to, accountName string) error {
+	subject := "Bekräftelse: Ditt konto har raderats"
+	htmlBody := GenerateAccountDeletionHTML(accountName)
+	textBody := GenerateAccountDeletionText(accountName)
+
+	return fp.SendEmail(to, subject, htmlBody, textBody)
+}
+
+// AddFooter appends the footer text and, for notification emails to registered users, an
+// unsubscribe link to both bodies of an email
+func AddFooter(to, category, htmlBody, textBody string) (string, string) {
+	footerText := GetFooterText()
+
+	unsubscribeURL, preferencesURL := "", ""
+	if category != "" && category != database.NotifySecurity {
+		if user, err := database.DB.GetUserByEmail(to); err == nil && user != nil {
+			if token, err := UnsubscribeToken(user.Id, category); err == nil && getPublicURL() != "" {
+				unsubscribeURL = getPublicURL() + "/email/unsubscribe/" + token
+				preferencesURL = getPublicURL() + "/notifications#preferences"
+			}
+		}
+	}
+
+	if footerText == "" && unsubscribeURL == "" {
+		return htmlBody, textBody
+	}
+
+	htmlFooter := `<div style="margin: 24px auto 0; max-width: 600px; padding: 16px 20px; border-top: 1px solid #e0e0e0; color: #888; font-family: Arial, Helvetica, sans-serif; font-size: 12px; line-height: 1.6; text-align: center;">`
+	textFooter := "\n\n--\n"
+	if footerText != "" {
+		htmlFooter += `<p style="margin: 0;">` + strings.ReplaceAll(html.EscapeString(footerText), "\n", "<br>") + `</p>`
+		textFooter += footerText + "\n"
+	}
+	if unsubscribeURL != "" {
+		htmlFooter += fmt.Sprintf(`<p style="margin: 8px 0 0;">You get this email because of your notification settings. <a href="%s" style="color: #888;">Unsubscribe</a> · <a href="%s" style="color: #888;">Email preferences</a></p>`,
+			html.EscapeString(unsubscribeURL), html.EscapeString(preferencesURL))
+		textFooter += "Unsubscribe: " + unsubscribeURL + "\nEmail preferences: " + preferencesURL + "\n"
+	}
+	htmlFooter += `</div>`
+
+	if i := strings.LastIndex(strings.ToLower(htmlBody), "</body>"); i >= 0 {
+		htmlBody = htmlBody[:i] + htmlFooter + htmlBody[i:]
+	} else {
+		htmlBody += htmlFooter
+	}
+	return htmlBody, textBody + textFooter
+}
+
+func unsubscribeSignature(secret []byte, userId int, category string) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "unsubscribe|%d|%s", userId, category)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// UnsubscribeToken returns the token of a link that turns off emails of a category for a user.
+// It is signed with the key of emailed share links, so it can't be made up for other users.
+func UnsubscribeToken(userId int, category string) (string, error) {
+	secret, err := shareLinkSecret()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%s.%s", userId, category, unsubscribeSignature(secret, userId, category)), nil
+}
+
+// VerifyUnsubscribeToken checks an unsubscribe token and returns the user and category it is for
+func VerifyUnsubscribeToken(token string) (int, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, "", ErrUnsubscribeInvalid
+	}
+	userId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", ErrUnsubscribeInvalid
+	}
+
+	secret, err := shareLinkSecret()
+	if err != nil {
+		return 0, "", err
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(unsubscribeSignature(secret, userId, parts[1]))) {
+		return 0, "", ErrUnsubscribeInvalid
+	}
+	return userId, parts[1], nil
+}
@@ -0,0 +1,161 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// ShareLinkTokenParam is the query parameter that carries the signature of an emailed share link
+const ShareLinkTokenParam = "lt"
+
+var (
+	// ErrShareLinkInvalid is returned for share link tokens that were altered or signed for another file
+	ErrShareLinkInvalid = errors.New("share link signature is not valid")
+	// ErrShareLinkExpired is returned for share link tokens whose validity has passed
+	ErrShareLinkExpired = errors.New("share link has expired")
+	// ErrShareLinkMissing is returned when a file that was emailed with a signed link is opened without the signature
+	ErrShareLinkMissing = errors.New("share link signature is missing")
+
+	shareLinkSecretMu sync.Mutex
+)
+
+// IsShareLinkSigningEnabled reports whether emailed share links are signed (on unless disabled in settings)
+func IsShareLinkSigningEnabled() bool {
+	value, _ := database.DB.GetConfigValue("email_link_signing")
+	return value != "false"
+}
+
+// IsPlainShareLinkAllowed reports whether plain links keep working for files that were emailed
+// with a signed link, and are sent when a link can't be signed (off unless enabled in settings)
+func IsPlainShareLinkAllowed() bool {
+	value, _ := database.DB.GetConfigValue("email_link_allow_plain")
+	return value == "true"
+}
+
+// shareLinkSecret returns the key used to sign emailed share links, creating it on first use.
+// It is separate from session tokens so that rotating it only invalidates emailed links.
+func shareLinkSecret() ([]byte, error) {
+	shareLinkSecretMu.Lock()
+	defer shareLinkSecretMu.Unlock()
+
+	secret, err := database.DB.GetConfigValue("email_link_secret")
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		secretBytes := make([]byte, 32)
+		if _, err := rand.Read(secretBytes); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(secretBytes)
+		if err := database.DB.SetConfigValue("email_link_secret", secret); err != nil {
+			return nil, err
+		}
+		log.Printf("Created signing key for emailed share links")
+	}
+	return []byte(secret), nil
+}
+
+// shareLinkRecipientHash identifies the recipient in a link token without putting the address in the URL
+func shareLinkRecipientHash(recipient string) string {
+	recipient = strings.ToLower(strings.TrimSpace(recipient))
+	if recipient == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(recipient))
+	return hex.EncodeToString(sum[:8])
+}
+
+func shareLinkSignature(secret []byte, fileID string, expiresAt int64, recipientHash string) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s|%d|%s", fileID, expiresAt, recipientHash)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// ShareLinkURL returns the splash page link for a file that is sent by email to recipient.
+// When signing is enabled the link carries a token tied to the file, the recipient (if known)
+// and the configured expiry. If the link can't be signed, a plain link is only returned when
+// email_link_allow_plain is set. Files with a custom slug get a link with the slug; the token
+// is still tied to the file ID. Signing a link marks the file, so that its links need a token
+// from then on.
+func ShareLinkURL(serverURL, fileID, recipient string) (string, error) {
+	linkID := fileID
+	if slug := database.DB.GetFileSlug(fileID); slug != "" {
+		linkID = slug
+	}
+	plainLink := serverURL + "/s/" + linkID
+	if !IsShareLinkSigningEnabled() {
+		return plainLink, nil
+	}
+
+	secret, err := shareLinkSecret()
+	if err != nil {
+		if IsPlainShareLinkAllowed() {
+			log.Printf("Warning: Could not sign share link for file %s, sending plain link: %v", fileID, err)
+			return plainLink, nil
+		}
+		return "", fmt.Errorf("could not sign share link: %w", err)
+	}
+
+	var expiresAt int64
+	if hours := database.DB.GetConfigInt("email_link_expiry_hours", 0); hours > 0 {
+		expiresAt = time.Now().Add(time.Duration(hours) * time.Hour).Unix()
+	}
+	recipientHash := shareLinkRecipientHash(recipient)
+	token := fmt.Sprintf("%d.%s.%s", expiresAt, recipientHash, shareLinkSignature(secret, fileID, expiresAt, recipientHash))
+
+	// Once a signed link is out, the plain link must not work around its expiry and recipient
+	if err := database.DB.MarkSignedLinkSent(fileID); err != nil {
+		return "", fmt.Errorf("could not mark file as sent with a signed link: %w", err)
+	}
+
+	return plainLink + "?" + ShareLinkTokenParam + "=" + url.QueryEscape(token), nil
+}
+
+// VerifyShareLinkToken checks a share link token for fileID and returns the recipient hash
+// it was signed for ("" if the recipient was not known)
+func VerifyShareLinkToken(token, fileID string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrShareLinkInvalid
+	}
+	expiresAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", ErrShareLinkInvalid
+	}
+
+	secret, err := shareLinkSecret()
+	if err != nil {
+		return "", err
+	}
+	expected := shareLinkSignature(secret, fileID, expiresAt, parts[1])
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return "", ErrShareLinkInvalid
+	}
+	if expiresAt > 0 && time.Now().Unix() > expiresAt {
+		return "", ErrShareLinkExpired
+	}
+	return parts[1], nil
+}
+
+// ShareLinkRecipientMatches reports whether emailAddr is the recipient a link token was signed for
+func ShareLinkRecipientMatches(recipientHash, emailAddr string) bool {
+	return recipientHash == "" || hmac.Equal([]byte(recipientHash), []byte(shareLinkRecipientHash(emailAddr)))
+}
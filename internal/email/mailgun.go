@@ -110,7 +110,7 @@ func (mp *MailgunProvider) SendEmail(to, subject, htmlBody, textBody string) err
 	log.Printf("📩 Mailgun Response Body: %s", string(respBody))
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("mailgun API error: %d %s - %s", resp.StatusCode, resp.Status, string(respBody))
+		return &statusError{code: resp.StatusCode, err: fmt.Errorf("mailgun API error: %d %s - %s", resp.StatusCode, resp.Status, string(respBody))}
 	}
 
 	log.Printf("✓ Email sent successfully via Mailgun to %s", to)
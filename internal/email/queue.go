@@ -0,0 +1,237 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package email
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+const (
+	// queuePollInterval is how often the queue worker looks for emails whose retry is due
+	queuePollInterval = 30 * time.Second
+	// queueBatchSize is how many due emails the worker loads at a time
+	queueBatchSize = 20
+	// firstRetryDelay doubles with every further attempt, up to maxRetryDelay
+	firstRetryDelay = time.Minute
+	maxRetryDelay   = time.Hour
+)
+
+var (
+	queueStartOnce sync.Once
+	queueStopOnce  sync.Once
+	queueRunning   atomic.Bool
+	queueWake      = make(chan struct{}, 1)
+	queueStop      = make(chan struct{})
+	queueStopped   = make(chan struct{})
+)
+
+// statusError is returned by the API providers when the API refuses an email, so the queue
+// can tell a rejected email from a temporary failure
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+func (e *statusError) Unwrap() error { return e.err }
+
+// isPermanentSendError reports whether sending an email again can't succeed: the API rejected
+// the request itself (4xx other than timeouts and rate limits), or the SMTP server answered
+// with a permanent 5xx reply
+func isPermanentSendError(err error) bool {
+	var apiErr *statusError
+	if errors.As(err, &apiErr) {
+		return apiErr.code >= 400 && apiErr.code < 500 && apiErr.code != 408 && apiErr.code != 429
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500
+	}
+	return false
+}
+
+// retryDelay returns how long to wait after a failed attempt before the next one
+func retryDelay(attempts int) time.Duration {
+	if attempts > 6 {
+		return maxRetryDelay
+	}
+	delay := firstRetryDelay << (attempts - 1)
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// Enqueue adds an email to the persistent queue and wakes the queue worker, which sends it in
+// the background and retries it if sending fails
+func Enqueue(email *database.QueuedEmail) error {
+	if err := database.DB.EnqueueEmail(email); err != nil {
+		return err
+	}
+	WakeQueue()
+	return nil
+}
+
+// WakeQueue makes the queue worker look for due emails right away
+func WakeQueue() {
+	select {
+	case queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartQueueWorker starts the background worker that sends queued emails. Emails that were
+// being sent when the server stopped are sent again.
+func StartQueueWorker() {
+	queueStartOnce.Do(func() {
+		if err := database.DB.ResetSendingEmails(); err != nil {
+			log.Printf("Warning: Could not requeue emails that were being sent: %v", err)
+		}
+
+		queueRunning.Store(true)
+		go func() {
+			defer close(queueStopped)
+			ticker := time.NewTicker(queuePollInterval)
+			defer ticker.Stop()
+
+			for {
+				processQueue()
+				select {
+				case <-ticker.C:
+				case <-queueWake:
+				case <-queueStop:
+					return
+				}
+			}
+		}()
+	})
+
+	log.Printf("Email queue worker started (max attempts: %d)", database.DB.GetEmailMaxAttempts())
+}
+
+// StopQueueWorker stops the queue worker after the email it is sending, waiting until it has
+// stopped or ctx is done. Emails still queued are sent when the server starts again.
+func StopQueueWorker(ctx context.Context) error {
+	queueStopOnce.Do(func() { close(queueStop) })
+	if !queueRunning.Load() {
+		return nil
+	}
+
+	select {
+	case <-queueStopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// processQueue sends every queued email that is due
+func processQueue() {
+	for {
+		emails, err := database.DB.GetDueEmails(queueBatchSize)
+		if err != nil {
+			log.Printf("Error loading queued emails: %v", err)
+			return
+		}
+		for _, email := range emails {
+			select {
+			case <-queueStop:
+				return
+			default:
+			}
+			deliverQueuedEmail(email)
+		}
+		if len(emails) < queueBatchSize {
+			return
+		}
+	}
+}
+
+// deliverQueuedEmail makes one attempt to send a queued email. A sent email leaves the queue;
+// a failed one is tried again after its backoff, until it failed permanently or
+// email_max_attempts is reached.
+func deliverQueuedEmail(email *database.QueuedEmail) {
+	claimed, err := database.DB.ClaimQueuedEmail(email.Id)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Printf("Error claiming queued email %d: %v", email.Id, err)
+		}
+		return
+	}
+
+	var deliveredBy string
+	chain, err := GetProviderChain(database.DB)
+	if err == nil {
+		htmlBody, textBody := AddFooter(email.Recipient, email.Category, email.HTMLBody, email.TextBody)
+		deliveredBy, err = SendThroughChain(chain, email.Recipient, email.Subject, htmlBody, textBody)
+	}
+	attempts := email.Attempts + 1
+	logFileEmail(email, deliveredBy, err == nil)
+
+	if err == nil {
+		log.Printf("Queued email %d (%s) sent to %s via %s after %d attempt(s)", email.Id, email.Kind, email.Recipient, deliveredBy, attempts)
+		if err := database.DB.DeleteQueuedEmail(email.Id); err != nil {
+			log.Printf("Error removing sent email %d from queue: %v", email.Id, err)
+		}
+		return
+	}
+
+	maxAttempts := database.DB.GetEmailMaxAttempts()
+	if !isPermanentSendError(err) && attempts < maxAttempts {
+		delay := retryDelay(attempts)
+		log.Printf("Queued email %d to %s failed (attempt %d of %d), retrying in %s: %v", email.Id, email.Recipient, attempts, maxAttempts, delay, err)
+		if err := database.DB.RescheduleQueuedEmail(email.Id, attempts, time.Now().Add(delay), err.Error()); err != nil {
+			log.Printf("Error rescheduling queued email %d: %v", email.Id, err)
+		}
+		return
+	}
+
+	log.Printf("Queued email %d to %s failed after %d attempt(s), giving up: %v", email.Id, email.Recipient, attempts, err)
+	if err := database.DB.MarkQueuedEmailFailed(email.Id, attempts, err.Error()); err != nil {
+		log.Printf("Error marking queued email %d as failed: %v", email.Id, err)
+	}
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     0,
+		UserEmail:  "system",
+		Action:     database.ActionEmailFailed,
+		EntityType: database.EntityEmail,
+		EntityID:   strconv.FormatInt(email.Id, 10),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"kind":      email.Kind,
+			"recipient": email.Recipient,
+			"subject":   email.Subject,
+			"attempts":  attempts,
+		}),
+		Success:  false,
+		ErrorMsg: err.Error(),
+	})
+}
+
+// logFileEmail records an attempt of a file email, and the provider that delivered it, in the
+// file's email log. All attempts share the email's dedup key, so they update one row.
+func logFileEmail(email *database.QueuedEmail, provider string, sent bool) {
+	if email.FileId == "" {
+		return
+	}
+	var err error
+	if sent {
+		err = database.DB.LogEmailSent(email.DedupKey, email.FileId, email.SenderUserId, email.Recipient, email.SenderMessage, email.FileName, email.FileSize, provider)
+	} else {
+		err = database.DB.LogEmailFailed(email.DedupKey, email.FileId, email.SenderUserId, email.Recipient, email.SenderMessage, email.FileName, email.FileSize)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to log email to database: %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// Email templates rendered from the templates directory. Each has a <name>.html and a
+// <name>.txt file defining its "content", which layout.html and layout.txt wrap.
+const (
+	TemplateFileShare   = "file_share"
+	TemplateWelcome     = "welcome"
+	TemplateFileRequest = "file_request"
+)
+
+// Templates lists the email templates in the order the admin page shows them
+var Templates = []string{TemplateFileShare, TemplateWelcome, TemplateFileRequest}
+
+var templateLabels = map[string]string{
+	TemplateFileShare:   "Shared file",
+	TemplateWelcome:     "Welcome",
+	TemplateFileRequest: "File request",
+}
+
+// defaultSubjects are the subjects of the templates when no override is configured
+// (config email_subject_<name>). Subjects are text templates with the same fields as the body.
+var defaultSubjects = map[string]string{
+	TemplateFileShare:   "{{.SenderName}} has shared a file with you via {{.CompanyName}}",
+	TemplateWelcome:     "Welcome to {{.CompanyName}} - Set Your Password",
+	TemplateFileRequest: "Action Required: Please upload your file",
+}
+
+//go:embed templates/*.html templates/*.txt
+var embeddedTemplates embed.FS
+
+// templateOverrideDir holds template files that replace the built-in ones of the same name
+var templateOverrideDir string
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// TemplateData is what the email templates are rendered with
+type TemplateData struct {
+	CompanyName    string
+	PrimaryColor   string
+	SecondaryColor string
+
+	// Admin-configured snippets shown above and below the content (config email_header_html
+	// and email_footer_html). The text versions have the tags removed.
+	HeaderHTML htmltemplate.HTML
+	FooterHTML htmltemplate.HTML
+	HeaderText string
+	FooterText string
+
+	RecipientEmail string
+	SenderName     string
+	SenderEmail    string
+	Message        string
+	Link           string // what the email's button opens
+
+	File         *TemplateFile // file_share only
+	RequestTitle string        // file_request only
+	ExpiresAt    string        // file_request only
+}
+
+// TemplateFile is the file a file_share email is about
+type TemplateFile struct {
+	Name          string
+	Size          string
+	Comment       string
+	ExpiresAt     string // "" if the file doesn't expire by date
+	DownloadLimit int    // 0 if downloads are unlimited
+}
+
+// SetTemplateDir sets the directory whose template files replace the built-in ones. Files are
+// read at every send, so edits apply right away.
+func SetTemplateDir(dir string) {
+	templateOverrideDir = dir
+}
+
+// TemplateDir returns the directory for template overrides
+func TemplateDir() string {
+	return templateOverrideDir
+}
+
+// TemplateLabel returns the display name of an email template
+func TemplateLabel(name string) string {
+	if label, ok := templateLabels[name]; ok {
+		return label
+	}
+	return name
+}
+
+// DefaultSubject returns the subject of a template when no override is configured
+func DefaultSubject(name string) string {
+	return defaultSubjects[name]
+}
+
+// SubjectConfigKey returns the configuration key of a template's subject override
+func SubjectConfigKey(name string) string {
+	return "email_subject_" + name
+}
+
+// ValidateSubject checks that a subject override is a valid template that only uses fields
+// of TemplateData
+func ValidateSubject(subject string) error {
+	_, err := executeSubject(subject, &TemplateData{File: &TemplateFile{}})
+	return err
+}
+
+func executeSubject(subject string, data *TemplateData) (string, error) {
+	tmpl, err := texttemplate.New("subject").Parse(subject)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	// A subject is one line
+	return strings.Join(strings.Fields(buf.String()), " "), nil
+}
+
+// NewTemplateData returns template data with the company name and colors from the branding
+// settings and the admin's header and footer snippets. fallbackCompanyName is used if no
+// company name is branded.
+func NewTemplateData(fallbackCompanyName string) *TemplateData {
+	branding, _ := database.DB.GetBrandingConfig()
+	data := &TemplateData{
+		CompanyName:    branding["branding_company_name"],
+		PrimaryColor:   colorOrDefault(branding["branding_primary_color"], "#2563eb"),
+		SecondaryColor: colorOrDefault(branding["branding_secondary_color"], "#1e40af"),
+	}
+	if data.CompanyName == "" {
+		data.CompanyName = fallbackCompanyName
+	}
+
+	header, _ := database.DB.GetConfigValue("email_header_html")
+	footer, _ := database.DB.GetConfigValue("email_footer_html")
+	data.HeaderHTML, data.HeaderText = htmltemplate.HTML(header), snippetText(header)
+	data.FooterHTML, data.FooterText = htmltemplate.HTML(footer), snippetText(footer)
+	return data
+}
+
+// SetColors replaces the branding colors, for emails with their own branding such as file
+// requests. Invalid colors are ignored.
+func (data *TemplateData) SetColors(primary, secondary string) {
+	data.PrimaryColor = colorOrDefault(primary, data.PrimaryColor)
+	data.SecondaryColor = colorOrDefault(secondary, data.SecondaryColor)
+}
+
+func colorOrDefault(color, fallback string) string {
+	if hexColor.MatchString(color) {
+		return color
+	}
+	return fallback
+}
+
+// snippetText turns an admin's HTML snippet into plain text for the text body
+func snippetText(snippet string) string {
+	text := strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n").Replace(snippet)
+	text = htmlTag.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+var templateFuncs = map[string]interface{}{
+	// multiline keeps the line breaks of user-entered text in HTML
+	"multiline": func(text string) htmltemplate.HTML {
+		return htmltemplate.HTML(strings.ReplaceAll(htmltemplate.HTMLEscapeString(text), "\n", "<br>"))
+	},
+}
+
+// templateFS serves the template files, with the files in the override directory replacing
+// the built-in ones
+type templateFS struct{}
+
+func (templateFS) Open(name string) (fs.File, error) {
+	if templateOverrideDir != "" {
+		if file, err := os.Open(path.Join(templateOverrideDir, path.Base(name))); err == nil {
+			return file, nil
+		}
+	}
+	return embeddedTemplates.Open(name)
+}
+
+// RenderEmail renders the subject and the HTML and text bodies of an email template
+func RenderEmail(name string, data *TemplateData) (subject, htmlBody, textBody string, err error) {
+	if _, ok := defaultSubjects[name]; !ok {
+		return "", "", "", fmt.Errorf("unknown email template: %s", name)
+	}
+
+	if override, _ := database.DB.GetConfigValue(SubjectConfigKey(name)); strings.TrimSpace(override) != "" {
+		if subject, err = executeSubject(override, data); err != nil {
+			log.Printf("Warning: Invalid subject override for %s email, using the default: %v", name, err)
+		}
+	}
+	if subject == "" {
+		if subject, err = executeSubject(defaultSubjects[name], data); err != nil {
+			return "", "", "", fmt.Errorf("subject of %s: %w", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	htmlTmpl, err := htmltemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS{}, "templates/layout.html", "templates/"+name+".html")
+	if err != nil {
+		return "", "", "", err
+	}
+	buf.Reset()
+	if err := htmlTmpl.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return "", "", "", fmt.Errorf("%s.html: %w", name, err)
+	}
+	htmlBody = buf.String()
+
+	textTmpl, err := texttemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS{}, "templates/layout.txt", "templates/"+name+".txt")
+	if err != nil {
+		return "", "", "", err
+	}
+	buf.Reset()
+	if err := textTmpl.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return "", "", "", fmt.Errorf("%s.txt: %w", name, err)
+	}
+	textBody = buf.String()
+
+	return subject, htmlBody, textBody, nil
+}
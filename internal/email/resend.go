@@ -109,7 +109,7 @@ func (rp *ResendProvider) SendEmail(to, subject, htmlBody, textBody string) erro
 	if resp.StatusCode != http.StatusOK {
 		var errResp map[string]interface{}
 		json.Unmarshal(respBody, &errResp)
-		return fmt.Errorf("resend API error: %d %s - %v", resp.StatusCode, resp.Status, errResp)
+		return &statusError{code: resp.StatusCode, err: fmt.Errorf("resend API error: %d %s - %v", resp.StatusCode, resp.Status, errResp)}
 	}
 
 	log.Printf("✓ Email sent successfully via Resend to %s", to)
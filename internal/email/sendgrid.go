@@ -146,7 +146,7 @@ func (sp *SendGridProvider) SendEmail(to, subject, htmlBody, textBody string) er
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		var errResp map[string]interface{}
 		json.Unmarshal(respBody, &errResp)
-		return fmt.Errorf("sendgrid API error: %d %s - %v", resp.StatusCode, resp.Status, errResp)
+		return &statusError{code: resp.StatusCode, err: fmt.Errorf("sendgrid API error: %d %s - %v", resp.StatusCode, resp.Status, errResp)}
 	}
 
 	log.Printf("✓ Email sent successfully via SendGrid to %s", to)
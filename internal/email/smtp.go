@@ -9,7 +9,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"net/smtp"
+	"strconv"
+	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
 	"github.com/Frimurare/WulfVault/internal/models"
@@ -78,6 +81,70 @@ func (sp *SMTPProvider) SendEmail(to, subject, htmlBody, textBody string) error
 	return nil
 }
 
+// smtpVerifyTimeout bounds Verify, so an unreachable or silent host fails the test instead
+// of hanging it
+const smtpVerifyTimeout = 15 * time.Second
+
+// Verify opens a connection to the SMTP server the way SendEmail does and checks that it
+// accepts the settings: implicit TLS on port 465 or STARTTLS otherwise when TLS is enabled,
+// the username and password, and the from address. No email is sent. Without TLS, sends don't
+// authenticate, so neither does Verify.
+func (sp *SMTPProvider) Verify() error {
+	addr := net.JoinHostPort(sp.host, strconv.Itoa(sp.port))
+	tlsConfig := &tls.Config{ServerName: sp.host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: smtpVerifyTimeout}
+	if sp.useTLS && sp.port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("could not connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpVerifyTimeout))
+
+	c, err := smtp.NewClient(conn, sp.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("%s did not answer as an SMTP server: %w", addr, err)
+	}
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return fmt.Errorf("EHLO failed: %w", err)
+	}
+
+	if sp.useTLS {
+		if sp.port != 465 {
+			if ok, _ := c.Extension("STARTTLS"); !ok {
+				return fmt.Errorf("%s does not support STARTTLS; disable TLS or use port 465 for implicit TLS", addr)
+			}
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		}
+		if sp.username != "" {
+			if ok, _ := c.Extension("AUTH"); !ok {
+				return fmt.Errorf("%s does not offer authentication", addr)
+			}
+			if err := c.Auth(smtp.PlainAuth("", sp.username, sp.password, sp.host)); err != nil {
+				return fmt.Errorf("authentication failed: %w", err)
+			}
+		}
+	}
+
+	if err := c.Mail(sp.fromEmail); err != nil {
+		return fmt.Errorf("MAIL FROM <%s> rejected: %w", sp.fromEmail, err)
+	}
+	if err := c.Reset(); err != nil {
+		return fmt.Errorf("RSET failed: %w", err)
+	}
+	return c.Quit()
+}
+
 // sendPlainSMTP sends email using plain SMTP without TLS (for MailHog, etc.)
 func (sp *SMTPProvider) sendPlainSMTP(to, subject, htmlBody, textBody string) error {
 	log.Printf("⚠️  Using plain SMTP (no TLS) - connection may be insecure")
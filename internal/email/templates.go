@@ -7,6 +7,9 @@ package email
 
 import (
 	"fmt"
+	"html"
+	"net"
+	"net/url"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
@@ -55,7 +58,7 @@ func GenerateUploadNotificationHTML(request *models.FileRequest, file *database.
 				<p><strong>Filename:</strong> %s</p>
 				<p><strong>Size:</strong> %s</p>
 				<p><strong>Uploaded:</strong> %s</p>
-				<p><strong>IP Address:</strong> %s</p>
+				<p><strong>IP Address:</strong> %s</p>%s
 			</div>
 
 			<a href="%s/dashboard" class="button">View in Dashboard</a>
@@ -68,7 +71,7 @@ func GenerateUploadNotificationHTML(request *models.FileRequest, file *database.
 	</div>
 </body>
 </html>
-`, request.Title, file.Name, file.Size, uploadTime, uploaderIP, serverURL)
+`, request.Title, file.Name, file.Size, uploadTime, uploaderIP, getExternalLinkHTML(file), serverURL)
 }
 
 // GenerateUploadNotificationText skapar text-version av uppladdningsnotifiering
@@ -83,19 +86,33 @@ Request: %s
 Filename: %s
 Size: %s
 Uploaded: %s
-IP Address: %s
+IP Address: %s%s
 
 Log in to view and download the file:
 %s/dashboard
 
 ---
 This is an automated message from WulfVault.
-`, request.Title, file.Name, file.Size, uploadTime, uploaderIP, serverURL)
+`, request.Title, file.Name, file.Size, uploadTime, uploaderIP, getExternalLinkText(file), serverURL)
 }
 
 // GenerateDownloadNotificationHTML skapar HTML-version av nedladdningsnotifiering
 func GenerateDownloadNotificationHTML(file *database.FileInfo, downloaderIP, serverURL string) string {
-	downloadTime := time.Now().Format("2006-01-02 15:04:05")
+	return GenerateDownloadNoticeHTML(file, &models.DownloadLog{IpAddress: downloaderIP, DownloadedAt: time.Now().Unix()}, serverURL)
+}
+
+// GenerateDownloadNoticeHTML creates the HTML version of the email telling a file's owner
+// about one download
+func GenerateDownloadNoticeHTML(file *database.FileInfo, download *models.DownloadLog, serverURL string) string {
+	downloadTime := time.Unix(download.DownloadedAt, 0).Format("2006-01-02 15:04:05")
+	downloaderRow := ""
+	if download.Email != "" {
+		downloaderRow = `
+									<tr>
+										<td style="padding: 8px 0; color: #64748b; font-size: 14px;"><strong>Downloaded by:</strong></td>
+										<td style="padding: 8px 0; color: #334155; font-size: 14px;">` + html.EscapeString(download.Email) + `</td>
+									</tr>`
+	}
 
 	return fmt.Sprintf(`
 <!DOCTYPE html>
@@ -138,7 +155,7 @@ func GenerateDownloadNotificationHTML(file *database.FileInfo, downloaderIP, ser
 									<tr>
 										<td style="padding: 8px 0; color: #64748b; font-size: 14px;"><strong>Downloaded:</strong></td>
 										<td style="padding: 8px 0; color: #334155; font-size: 14px;">%s</td>
-									</tr>
+									</tr>%s
 									<tr>
 										<td style="padding: 8px 0; color: #64748b; font-size: 14px;"><strong>IP Address:</strong></td>
 										<td style="padding: 8px 0; color: #334155; font-size: 14px;">%s</td>
@@ -146,7 +163,7 @@ func GenerateDownloadNotificationHTML(file *database.FileInfo, downloaderIP, ser
 									<tr>
 										<td style="padding: 8px 0; color: #64748b; font-size: 14px;"><strong>Downloads remaining:</strong></td>
 										<td style="padding: 8px 0; color: #334155; font-size: 14px;">%s</td>
-									</tr>
+									</tr>%s
 								</table>
 							</div>
 
@@ -154,7 +171,7 @@ func GenerateDownloadNotificationHTML(file *database.FileInfo, downloaderIP, ser
 							<table width="100%%" cellpadding="0" cellspacing="0" style="margin: 30px 0;">
 								<tr>
 									<td align="center">
-										<a href="%s/dashboard" style="display: inline-block; background-color: #2563eb; color: #ffffff; padding: 16px 40px; text-decoration: none; border-radius: 8px; font-size: 16px; font-weight: bold; border: 3px solid #1d4ed8; box-shadow: 0 4px 12px rgba(37, 99, 235, 0.4);">
+										<a href="%s" style="display: inline-block; background-color: #2563eb; color: #ffffff; padding: 16px 40px; text-decoration: none; border-radius: 8px; font-size: 16px; font-weight: bold; border: 3px solid #1d4ed8; box-shadow: 0 4px 12px rgba(37, 99, 235, 0.4);">
 											VIEW IN DASHBOARD
 										</a>
 									</td>
@@ -177,29 +194,146 @@ func GenerateDownloadNotificationHTML(file *database.FileInfo, downloaderIP, ser
 	</table>
 </body>
 </html>
-`, file.Name, file.Size, downloadTime, downloaderIP, getDownloadsRemainingText(file), serverURL)
+`, html.EscapeString(file.Name), file.Size, downloadTime, downloaderRow, html.EscapeString(downloadIP(download)), getDownloadsRemainingText(file), getExternalLinkRowHTML(file), FileHistoryURL(serverURL, file.Id))
 }
 
 // GenerateDownloadNotificationText skapar text-version av nedladdningsnotifiering
 func GenerateDownloadNotificationText(file *database.FileInfo, downloaderIP, serverURL string) string {
-	downloadTime := time.Now().Format("2006-01-02 15:04:05")
+	return GenerateDownloadNoticeText(file, &models.DownloadLog{IpAddress: downloaderIP, DownloadedAt: time.Now().Unix()}, serverURL)
+}
 
-	return fmt.Sprintf(`Din fil har laddats ner!
+// GenerateDownloadNoticeText creates the text version of the email telling a file's owner
+// about one download
+func GenerateDownloadNoticeText(file *database.FileInfo, download *models.DownloadLog, serverURL string) string {
+	downloadTime := time.Unix(download.DownloadedAt, 0).Format("2006-01-02 15:04:05")
+	downloader := ""
+	if download.Email != "" {
+		downloader = "\nDownloaded by: " + download.Email
+	}
 
-Någon har laddat ner en av dina filer:
+	return fmt.Sprintf(`Your file was downloaded!
 
-Filnamn: %s
-Storlek: %s
-Nedladdad: %s
-IP-adress: %s
-Nedladdningar kvar: %s
+Someone has downloaded one of your files:
 
-Logga in för att se detaljer:
-%s/dashboard
+Filename: %s
+Size: %s
+Downloaded: %s%s
+IP Address: %s
+Downloads remaining: %s%s
+
+Log in to see the details:
+%s
 
 ---
-Detta är ett automatiskt meddelande från WulfVault.
-`, file.Name, file.Size, downloadTime, downloaderIP, getDownloadsRemainingText(file), serverURL)
+This is an automated message from WulfVault.
+`, file.Name, file.Size, downloadTime, downloader, downloadIP(download), getDownloadsRemainingText(file), getExternalLinkText(file), FileHistoryURL(serverURL, file.Id))
+}
+
+// GenerateDownloadDigestHTML creates the HTML version of the email that sums up the downloads
+// of a file that were batched instead of being emailed one by one
+func GenerateDownloadDigestHTML(file *database.FileInfo, downloads []*models.DownloadLog, serverURL string) string {
+	rows := ""
+	for _, download := range downloads {
+		downloader := "Anonymous"
+		if download.Email != "" {
+			downloader = html.EscapeString(download.Email)
+		}
+		rows += fmt.Sprintf(`
+									<tr>
+										<td style="padding: 6px 0; color: #334155; font-size: 14px; border-bottom: 1px solid #e2e8f0;">%s</td>
+										<td style="padding: 6px 0; color: #334155; font-size: 14px; border-bottom: 1px solid #e2e8f0;">%s</td>
+										<td style="padding: 6px 0; color: #334155; font-size: 14px; border-bottom: 1px solid #e2e8f0;">%s</td>
+									</tr>`, time.Unix(download.DownloadedAt, 0).Format("2006-01-02 15:04:05"), downloader, html.EscapeString(downloadIP(download)))
+	}
+
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+</head>
+<body style="margin: 0; padding: 0; font-family: Arial, Helvetica, sans-serif;">
+	<table width="100%%" cellpadding="0" cellspacing="0" style="background-color: #f0f0f0; padding: 20px 0;">
+		<tr>
+			<td align="center">
+				<table width="600" cellpadding="0" cellspacing="0" style="background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0,0,0,0.1);">
+					<tr>
+						<td style="background-color: #1e3a5f; padding: 30px; text-align: center;">
+							<h1 style="color: #ffffff; margin: 0; font-size: 24px;">⬇️ %d Downloads</h1>
+							<p style="color: #a0c4e8; margin: 10px 0 0 0; font-size: 14px;">Download Summary</p>
+						</td>
+					</tr>
+
+					<tr>
+						<td style="padding: 40px 30px;">
+							<p style="margin: 0 0 20px 0; color: #334155; font-size: 16px;">
+								Your file was downloaded often, so these downloads are summed up in one email:
+							</p>
+
+							<div style="background-color: #f8fafc; border: 2px solid #e2e8f0; border-radius: 8px; padding: 20px; margin-bottom: 20px;">
+								<h3 style="margin: 0 0 15px 0; color: #1e3a5f; font-size: 18px;">📄 %s</h3>
+								<table width="100%%" cellpadding="0" cellspacing="0">
+									<tr>
+										<td style="padding: 6px 0; color: #64748b; font-size: 14px;"><strong>Downloaded</strong></td>
+										<td style="padding: 6px 0; color: #64748b; font-size: 14px;"><strong>By</strong></td>
+										<td style="padding: 6px 0; color: #64748b; font-size: 14px;"><strong>IP Address</strong></td>
+									</tr>%s
+								</table>
+								<p style="margin: 15px 0 0 0; color: #64748b; font-size: 14px;"><strong>Downloads remaining:</strong> %s</p>
+							</div>
+
+							<table width="100%%" cellpadding="0" cellspacing="0" style="margin: 30px 0;">
+								<tr>
+									<td align="center">
+										<a href="%s" style="display: inline-block; background-color: #2563eb; color: #ffffff; padding: 16px 40px; text-decoration: none; border-radius: 8px; font-size: 16px; font-weight: bold; border: 3px solid #1d4ed8; box-shadow: 0 4px 12px rgba(37, 99, 235, 0.4);">
+											VIEW IN DASHBOARD
+										</a>
+									</td>
+								</tr>
+							</table>
+						</td>
+					</tr>
+
+					<tr>
+						<td style="background-color: #1e3a5f; padding: 20px; text-align: center;">
+							<p style="margin: 0; color: #a0c4e8; font-size: 12px;">
+								This is an automated download summary from WulfVault
+							</p>
+						</td>
+					</tr>
+				</table>
+			</td>
+		</tr>
+	</table>
+</body>
+</html>
+`, len(downloads), html.EscapeString(file.Name), rows, getDownloadsRemainingText(file), FileHistoryURL(serverURL, file.Id))
+}
+
+// GenerateDownloadDigestText creates the text version of the download summary email
+func GenerateDownloadDigestText(file *database.FileInfo, downloads []*models.DownloadLog, serverURL string) string {
+	lines := ""
+	for _, download := range downloads {
+		downloader := "Anonymous"
+		if download.Email != "" {
+			downloader = download.Email
+		}
+		lines += fmt.Sprintf("- %s  %s  (%s)\n", time.Unix(download.DownloadedAt, 0).Format("2006-01-02 15:04:05"), downloader, downloadIP(download))
+	}
+
+	return fmt.Sprintf(`%s was downloaded %d times
+
+Your file was downloaded often, so these downloads are summed up in one email:
+
+%s
+Downloads remaining: %s
+
+Log in to see the details:
+%s
+
+---
+This is an automated message from WulfVault.
+`, file.Name, len(downloads), lines, getDownloadsRemainingText(file), FileHistoryURL(serverURL, file.Id))
 }
 
 // GenerateSplashLinkHTML skapar HTML-version av splash link e-post
@@ -281,6 +415,28 @@ Detta är ett automatiskt meddelande från WulfVault.
 
 // Helper-funktioner
 
+// FileHistoryURL returns the dashboard link used in notifications about a file. Unless
+// disabled in settings, it opens the dashboard at that file with its history shown;
+// the dashboard requires login and only shows files the user may see.
+func FileHistoryURL(serverURL, fileID string) string {
+	if value, _ := database.DB.GetConfigValue("email_file_deep_links"); value == "false" {
+		return serverURL + "/dashboard"
+	}
+	return serverURL + "/dashboard?file=" + url.QueryEscape(fileID)
+}
+
+// downloadIP returns the IP address of a download as shown in notifications, without the port
+// older download log entries include
+func downloadIP(download *models.DownloadLog) string {
+	if download.IpAddress == "" {
+		return "not recorded"
+	}
+	if host, _, err := net.SplitHostPort(download.IpAddress); err == nil {
+		return host
+	}
+	return download.IpAddress
+}
+
 func getDownloadsRemainingText(file *database.FileInfo) string {
 	if file.UnlimitedDownloads {
 		return "Obegränsat"
@@ -291,6 +447,43 @@ func getDownloadsRemainingText(file *database.FileInfo) string {
 	return fmt.Sprintf("%d", file.DownloadsRemaining)
 }
 
+// getExternalLink returns the file's link into the external system if owner notifications include it
+func getExternalLink(file *database.FileInfo) string {
+	if !database.DB.IsExternalLinkInNotificationsEnabled() {
+		return ""
+	}
+	return database.DB.ExternalFileLink(file)
+}
+
+func getExternalLinkHTML(file *database.FileInfo) string {
+	link := getExternalLink(file)
+	if link == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+				<p><strong>%s:</strong> <a href="%s">%s</a></p>`, html.EscapeString(database.DB.GetExternalLinkLabel()), html.EscapeString(link), html.EscapeString(link))
+}
+
+func getExternalLinkRowHTML(file *database.FileInfo) string {
+	link := getExternalLink(file)
+	if link == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+									<tr>
+										<td style="padding: 8px 0; color: #64748b; font-size: 14px;"><strong>%s:</strong></td>
+										<td style="padding: 8px 0; color: #334155; font-size: 14px;"><a href="%s" style="color: #2563eb;">%s</a></td>
+									</tr>`, html.EscapeString(database.DB.GetExternalLinkLabel()), html.EscapeString(link), html.EscapeString(link))
+}
+
+func getExternalLinkText(file *database.FileInfo) string {
+	link := getExternalLink(file)
+	if link == "" {
+		return ""
+	}
+	return "\n" + database.DB.GetExternalLinkLabel() + ": " + link
+}
+
 func getMessageHTML(message string) string {
 	if message == "" {
 		return ""
@@ -389,151 +582,26 @@ Om du har frågor, vänligen kontakta oss.
 `, accountName)
 }
 
-// SendWelcomeEmail sends a welcome email to newly created users with password setup link
-func SendWelcomeEmail(email, resetToken, serverURL, companyName, adminName, adminEmail string) error {
-	resetLink := fmt.Sprintf("%s/reset-password?token=%s", serverURL, resetToken)
-
-	subject := fmt.Sprintf("Welcome to %s - Set Your Password", companyName)
-
-	htmlBody := fmt.Sprintf(`
-<!DOCTYPE html>
-<html lang="en">
-<head>
-	<meta charset="UTF-8">
-	<style>
-		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
-		.header {
-			background: #2563eb;
-			color: white;
-			padding: 35px;
-			border-radius: 10px 10px 0 0;
-			text-align: center;
-		}
-		.header h1 { margin: 0; font-size: 32px; }
-		.header p { margin: 10px 0 0 0; opacity: 0.9; }
-		.content {
-			background: #f9f9f9;
-			padding: 30px;
-			border-radius: 0 0 10px 10px;
-		}
-		.welcome-box {
-			background: #d4edda;
-			border-left: 4px solid #28a745;
-			padding: 20px;
-			margin: 20px 0;
-			border-radius: 5px;
-		}
-		.welcome-box h2 {
-			color: #155724;
-			margin-top: 0;
-		}
-		.setup-box {
-			background: white;
-			padding: 30px;
-			margin: 25px 0;
-			border-radius: 8px;
-			border: 2px solid #2563eb;
-			text-align: center;
-		}
-		.button {
-			display: inline-block;
-			padding: 18px 50px;
-			background: #2563eb;
-			color: white !important;
-			text-decoration: none;
-			border-radius: 8px;
-			margin: 20px 0;
-			font-weight: bold;
-			font-size: 18px;
-		}
-		.footer {
-			margin-top: 30px;
-			padding-top: 20px;
-			border-top: 2px solid #ddd;
-			font-size: 12px;
-			color: #666;
-			text-align: center;
-		}
-		.info-box {
-			background: #e3f2fd;
-			padding: 15px;
-			margin: 20px 0;
-			border-radius: 5px;
-			border-left: 4px solid #2196f3;
-		}
-	</style>
-</head>
-<body>
-	<div class="container">
-		<div class="header">
-			<h1>🎉 Welcome to %s!</h1>
-			<p>Your account has been created</p>
-		</div>
-
-		<div class="content">
-			<div class="welcome-box">
-				<h2>Congratulations!</h2>
-				<p><strong>%s</strong> (%s) has added you to <strong>%s</strong>. You can now share, receive, and request both small and huge files securely.</p>
-			</div>
-
-			<p>To get started, you need to set your password and log in to your account.</p>
+// QueueWelcomeEmail queues a welcome email to a newly created user with a password setup link
+func QueueWelcomeEmail(email, resetToken, serverURL, companyName, adminName, adminEmail string) error {
+	data := NewTemplateData(companyName)
+	data.RecipientEmail = email
+	data.SenderName = adminName
+	data.SenderEmail = adminEmail
+	data.Link = fmt.Sprintf("%s/reset-password?token=%s", serverURL, resetToken)
 
-			<div class="setup-box">
-				<h2 style="color: #2563eb; margin-bottom: 15px;">Set Your Password</h2>
-				<p style="margin-bottom: 25px;">Click the button below to create your password and access your account.</p>
-
-				<a href="%s" class="button">SET PASSWORD &amp; LOGIN</a>
-
-				<p style="font-size: 13px; color: #999; margin-top: 20px;">
-					This link is valid for 1 hour
-				</p>
-			</div>
-
-			<div class="info-box">
-				<p style="margin: 0;"><strong>📧 Your Login Email:</strong></p>
-				<p style="margin: 5px 0 0 0; font-size: 16px; font-weight: bold;">%s</p>
-			</div>
-
-			<p style="text-align: center; color: #666; margin-top: 30px;">
-				If the button doesn't work, copy and paste this link into your browser:
-			</p>
-			<p style="text-align: center; word-break: break-all; font-size: 12px; color: #999;">
-				%s
-			</p>
-		</div>
-
-		<div class="footer">
-			<p>This is an automated message from %s.</p>
-			<p>Do not reply to this email.</p>
-		</div>
-	</div>
-</body>
-</html>`, companyName, adminName, adminEmail, companyName, resetLink, email, resetLink, companyName)
-
-	textBody := fmt.Sprintf(`Welcome to %s!
-
-Congratulations! %s (%s) has added you to %s. You can now share, receive, and request both small and huge files securely.
-
-To get started, you need to set your password and log in to your account.
-
-Your login email: %s
-
-Set your password by visiting this link:
-%s
-
-This link is valid for 1 hour.
-
----
-This is an automated message from %s.
-Do not reply to this email.`, companyName, adminName, adminEmail, companyName, email, resetLink, companyName)
-
-	provider, err := GetActiveProvider(database.DB)
+	subject, htmlBody, textBody, err := RenderEmail(TemplateWelcome, data)
 	if err != nil {
 		return err
 	}
 
-	return provider.SendEmail(email, subject, htmlBody, textBody)
+	return Enqueue(&database.QueuedEmail{
+		Kind:      database.EmailKindWelcome,
+		Recipient: email,
+		Subject:   subject,
+		HTMLBody:  htmlBody,
+		TextBody:  textBody,
+	})
 }
 
 // SendTeamInvitationEmail sends an invitation email when a user is added to a team
@@ -681,7 +749,7 @@ Do not reply to this email.`, teamName, companyName, teamName, companyName, emai
 		return err
 	}
 
-	return provider.SendEmail(email, subject, htmlBody, textBody)
+	return ForCategory(provider, database.NotifyTeams).SendEmail(email, subject, htmlBody, textBody)
 }
 
 // SendPasswordResetEmail sends a password reset email with a humoristic/ironic tone
@@ -867,3 +935,1170 @@ Svara inte på detta mail.`, resetLink)
 
 	return provider.SendEmail(email, subject, htmlBody, textBody)
 }
+
+// SendEmailChangeVerificationEmail sends a verification link to the new address of a pending email change
+func SendEmailChangeVerificationEmail(newEmail, token, serverURL, companyName string, validFor time.Duration) error {
+	verifyLink := fmt.Sprintf("%s/settings/confirm-email?token=%s", serverURL, token)
+	validHours := int(validFor.Hours())
+
+	subject := fmt.Sprintf("Confirm your new email address - %s", companyName)
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #2563eb; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.verify-box { background: white; padding: 25px; margin: 25px 0; border-radius: 8px; border: 2px solid #2563eb; text-align: center; }
+		.button { display: inline-block; padding: 15px 35px; background: #2563eb; color: white !important; text-decoration: none; border-radius: 8px; margin: 20px 0; font-weight: bold; font-size: 16px; }
+		.warning { background: #ffebee; border-left: 4px solid #f44336; padding: 15px; margin: 20px 0; border-radius: 5px; color: #c62828; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>📧 Confirm Your New Email</h1>
+		</div>
+
+		<div class="content">
+			<p>A request was made to change the email address of your %s account to <strong>%s</strong>.</p>
+
+			<div class="verify-box">
+				<p>Click the button below to confirm the change.</p>
+				<a href="%s" class="button">Confirm Email Change</a>
+				<p style="font-size: 13px; color: #999; margin-top: 20px;">This link is valid for %d hours</p>
+			</div>
+
+			<div class="warning">
+				<p style="margin: 0;">If you did not request this change, ignore this email. Your account email will stay the same.</p>
+			</div>
+
+			<p style="text-align: center; color: #666; margin-top: 30px;">
+				If the button doesn't work, copy and paste this link into your browser:
+			</p>
+			<p style="text-align: center; word-break: break-all; font-size: 12px; color: #999;">
+				%s
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, companyName, newEmail, verifyLink, validHours, verifyLink, companyName)
+
+	textBody := fmt.Sprintf(`Confirm Your New Email
+
+A request was made to change the email address of your %s account to %s.
+
+Open the link below to confirm the change:
+%s
+
+This link is valid for %d hours.
+
+If you did not request this change, ignore this email. Your account email will stay the same.
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, companyName, newEmail, verifyLink, validHours, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return provider.SendEmail(newEmail, subject, htmlBody, textBody)
+}
+
+// SendEmailChangedNotification notifies the previous address that the account email was changed
+func SendEmailChangedNotification(oldEmail, newEmail, companyName string) error {
+	subject := fmt.Sprintf("Your email address was changed - %s", companyName)
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #2563eb; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.warning { background: #ffebee; border-left: 4px solid #f44336; padding: 15px; margin: 20px 0; border-radius: 5px; color: #c62828; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>🔔 Email Address Changed</h1>
+		</div>
+
+		<div class="content">
+			<p>The email address of your %s account has been changed from <strong>%s</strong> to <strong>%s</strong>.</p>
+			<p>From now on, use the new address to log in.</p>
+
+			<div class="warning">
+				<p style="margin: 0;">If you did not make this change, contact your administrator immediately.</p>
+			</div>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, companyName, oldEmail, newEmail, companyName)
+
+	textBody := fmt.Sprintf(`Email Address Changed
+
+The email address of your %s account has been changed from %s to %s.
+From now on, use the new address to log in.
+
+If you did not make this change, contact your administrator immediately.
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, companyName, oldEmail, newEmail, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return provider.SendEmail(oldEmail, subject, htmlBody, textBody)
+}
+
+// SendUploadApprovalRequestEmail notifies an approver that a public upload is waiting for release
+func SendUploadApprovalRequestEmail(approverEmail, fileName, uploaderEmail, serverURL, companyName string) error {
+	subject := fmt.Sprintf("Upload awaiting approval - %s", companyName)
+	approvalsURL := serverURL + "/approvals"
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #2563eb; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.file-box { background: white; border: 2px solid #2563eb; padding: 20px; margin: 20px 0; border-radius: 8px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>⏳ Upload Awaiting Approval</h1>
+		</div>
+
+		<div class="content">
+			<p>A file has been uploaded with a public link and must be approved before it can be downloaded.</p>
+
+			<div class="file-box">
+				<p style="margin: 0;"><strong>File:</strong> %s</p>
+				<p style="margin: 0;"><strong>Uploaded by:</strong> %s</p>
+			</div>
+
+			<p style="text-align: center;">
+				<a href="%s" class="button">Review Pending Uploads</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(fileName), html.EscapeString(uploaderEmail), approvalsURL, companyName)
+
+	textBody := fmt.Sprintf(`Upload Awaiting Approval
+
+A file has been uploaded with a public link and must be approved before it can be downloaded.
+
+File: %s
+Uploaded by: %s
+
+Review pending uploads here: %s
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, fileName, uploaderEmail, approvalsURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyApprovals).SendEmail(approverEmail, subject, htmlBody, textBody)
+}
+
+// SendUploadApprovalDecisionEmail tells the uploader whether their public upload was approved or rejected
+func SendUploadApprovalDecisionEmail(uploaderEmail, fileName string, approved bool, note, serverURL, companyName string) error {
+	outcome := "rejected"
+	icon := "❌"
+	headerColor := "#f44336"
+	explanation := "The public link for this file will not work. Contact the approver if you have questions."
+	if approved {
+		outcome = "approved"
+		icon = "✅"
+		headerColor = "#4caf50"
+		explanation = "The public link for this file is now active and can be shared."
+	}
+	subject := fmt.Sprintf("Your upload was %s - %s", outcome, companyName)
+
+	noteHTML := ""
+	noteText := ""
+	if note != "" {
+		noteHTML = fmt.Sprintf(`<p><strong>Note from approver:</strong> %s</p>`, html.EscapeString(note))
+		noteText = fmt.Sprintf("\nNote from approver: %s\n", note)
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: %s; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>%s Upload %s</h1>
+		</div>
+
+		<div class="content">
+			<p>Your file <strong>%s</strong> has been %s.</p>
+			<p>%s</p>
+			%s
+
+			<p style="text-align: center;">
+				<a href="%s/dashboard" class="button">Go to Dashboard</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, headerColor, icon, outcome, html.EscapeString(fileName), outcome, explanation, noteHTML, serverURL, companyName)
+
+	textBody := fmt.Sprintf(`Upload %s
+
+Your file "%s" has been %s.
+%s
+%s
+Dashboard: %s/dashboard
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, outcome, fileName, outcome, explanation, noteText, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyApprovals).SendEmail(uploaderEmail, subject, htmlBody, textBody)
+}
+
+// SendFileAccessRequestEmail asks a file owner to review a recipient's request for access
+func SendFileAccessRequestEmail(ownerEmail, fileName, requesterName, requesterEmail, message, reviewURL, companyName string) error {
+	subject := fmt.Sprintf("Access requested for %s - %s", fileName, companyName)
+
+	requester := requesterEmail
+	if requesterName != "" {
+		requester = fmt.Sprintf("%s <%s>", requesterName, requesterEmail)
+	}
+
+	messageHTML := ""
+	messageText := ""
+	if message != "" {
+		messageHTML = fmt.Sprintf(`<p style="margin: 10px 0 0 0;"><strong>Message:</strong> %s</p>`, html.EscapeString(message))
+		messageText = fmt.Sprintf("Message: %s\n", message)
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #2563eb; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.file-box { background: white; border: 2px solid #2563eb; padding: 20px; margin: 20px 0; border-radius: 8px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>🔑 Access Requested</h1>
+		</div>
+
+		<div class="content">
+			<p>Someone without access has asked to download one of your files.</p>
+
+			<div class="file-box">
+				<p style="margin: 0;"><strong>File:</strong> %s</p>
+				<p style="margin: 0;"><strong>Requested by:</strong> %s</p>
+				%s
+			</div>
+
+			<p style="text-align: center;">
+				<a href="%s" class="button">Review Request</a>
+			</p>
+			<p style="font-size: 13px; color: #666;">Approving adds this email address to the file's access list. Ignore this email to leave the request pending.</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(fileName), html.EscapeString(requester), messageHTML, reviewURL, companyName)
+
+	textBody := fmt.Sprintf(`Access Requested
+
+Someone without access has asked to download one of your files.
+
+File: %s
+Requested by: %s
+%s
+Review the request here: %s
+
+Approving adds this email address to the file's access list. Ignore this email to leave the request pending.
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, fileName, requester, messageText, reviewURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyAccessRequests).SendEmail(ownerEmail, subject, htmlBody, textBody)
+}
+
+// SendFileAccessDecisionEmail tells a requester whether they were granted access to a file.
+// setPasswordURL is set when a download account was created for them.
+func SendFileAccessDecisionEmail(requesterEmail, fileName string, approved bool, fileURL, setPasswordURL, companyName string) error {
+	if !approved {
+		subject := fmt.Sprintf("Access request declined - %s", companyName)
+		textBody := fmt.Sprintf(`Access Request Declined
+
+Your request to download "%s" was declined by the file owner.
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, fileName, companyName)
+		htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #f44336; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>❌ Access Request Declined</h1>
+		</div>
+
+		<div class="content">
+			<p>Your request to download <strong>%s</strong> was declined by the file owner.</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(fileName), companyName)
+
+		provider, err := GetActiveProvider(database.DB)
+		if err != nil {
+			return err
+		}
+		return provider.SendEmail(requesterEmail, subject, htmlBody, textBody)
+	}
+
+	subject := fmt.Sprintf("Access granted to %s - %s", fileName, companyName)
+
+	accountHTML := `<p>Log in with this email address on the download page to get the file.</p>`
+	accountText := "Log in with this email address on the download page to get the file."
+	if setPasswordURL != "" {
+		accountHTML = fmt.Sprintf(`<p>A download account has been created for you. <a href="%s">Choose a password</a> first (the link is valid for 1 hour), then log in on the download page.</p>`, setPasswordURL)
+		accountText = fmt.Sprintf("A download account has been created for you. Choose a password first (the link is valid for 1 hour): %s\nThen log in on the download page.", setPasswordURL)
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #4caf50; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>✅ Access Granted</h1>
+		</div>
+
+		<div class="content">
+			<p>You can now download <strong>%s</strong>.</p>
+			%s
+
+			<p style="text-align: center;">
+				<a href="%s" class="button">Go to Download</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(fileName), accountHTML, fileURL, companyName)
+
+	textBody := fmt.Sprintf(`Access Granted
+
+You can now download "%s".
+%s
+
+Download page: %s
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, fileName, accountText, fileURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return provider.SendEmail(requesterEmail, subject, htmlBody, textBody)
+}
+
+// SendDownloadAccountsDeactivatedEmail tells an admin which download accounts were deactivated for inactivity
+func SendDownloadAccountsDeactivatedEmail(adminEmail string, accounts []*models.DownloadAccount, idleDays int, serverURL, companyName string) error {
+	subject := fmt.Sprintf("%d inactive download account(s) deactivated - %s", len(accounts), companyName)
+
+	rowsHTML := ""
+	rowsText := ""
+	for _, account := range accounts {
+		lastUsed := "Never"
+		if account.LastUsed > 0 {
+			lastUsed = time.Unix(account.LastUsed, 0).Format("2006-01-02")
+		}
+		rowsHTML += fmt.Sprintf(`<tr><td style="padding: 8px; border-bottom: 1px solid #ddd;">%s</td><td style="padding: 8px; border-bottom: 1px solid #ddd;">%s</td></tr>`,
+			html.EscapeString(account.Email), lastUsed)
+		rowsText += fmt.Sprintf("- %s (last used: %s)\n", account.Email, lastUsed)
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #ff9800; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>💤 Download Accounts Deactivated</h1>
+		</div>
+
+		<div class="content">
+			<p>The following download accounts were deactivated for inactivity (no downloads in the last %d days, or never used since they were created):</p>
+			<table style="width: 100%%; border-collapse: collapse; margin: 20px 0;">
+				<tr><th style="padding: 8px; text-align: left; border-bottom: 2px solid #ddd;">Email</th><th style="padding: 8px; text-align: left; border-bottom: 2px solid #ddd;">Last used</th></tr>
+				%s
+			</table>
+			<p>The accounts and their download history are kept. You can reactivate an account from the user management page if it is still needed.</p>
+
+			<p style="text-align: center;">
+				<a href="%s/admin/users" class="button">Manage Accounts</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, idleDays, rowsHTML, serverURL, companyName)
+
+	textBody := fmt.Sprintf(`Download Accounts Deactivated
+
+The following download accounts were deactivated for inactivity (no downloads in the last %d days, or never used since they were created):
+
+%s
+The accounts and their download history are kept. You can reactivate an account from the user management page if it is still needed.
+
+Manage accounts: %s/admin/users
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, idleDays, rowsText, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyAdminReports).SendEmail(adminEmail, subject, htmlBody, textBody)
+}
+
+// SendIntegrityProblemsEmail tells an admin which files failed the scheduled integrity scan
+func SendIntegrityProblemsEmail(adminEmail string, problems []*database.FileIntegrity, serverURL, companyName string) error {
+	subject := fmt.Sprintf("%d file(s) failed the integrity check - %s", len(problems), companyName)
+
+	rowsHTML := ""
+	rowsText := ""
+	for _, problem := range problems {
+		rowsHTML += fmt.Sprintf(`<tr><td style="padding: 8px; border-bottom: 1px solid #ddd;">%s</td><td style="padding: 8px; border-bottom: 1px solid #ddd;">%s</td></tr>`,
+			html.EscapeString(problem.FileName), html.EscapeString(problem.Status))
+		rowsText += fmt.Sprintf("- %s (%s)\n", problem.FileName, problem.Status)
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #dc2626; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>🛡️ File Integrity Problems</h1>
+		</div>
+
+		<div class="content">
+			<p>The integrity scan found files that no longer match their stored SHA-256 checksum or are missing from disk:</p>
+			<table style="width: 100%%; border-collapse: collapse; margin: 20px 0;">
+				<tr><th style="padding: 8px; text-align: left; border-bottom: 2px solid #ddd;">File</th><th style="padding: 8px; text-align: left; border-bottom: 2px solid #ddd;">Problem</th></tr>
+				%s
+			</table>
+			<p>Recipients who download these files get corrupt or no data. Restore them from a backup, or ask their owners to upload them again.</p>
+
+			<p style="text-align: center;">
+				<a href="%s/admin/integrity" class="button">View Integrity Report</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, rowsHTML, serverURL, companyName)
+
+	textBody := fmt.Sprintf(`File Integrity Problems
+
+The integrity scan found files that no longer match their stored SHA-256 checksum or are missing from disk:
+
+%s
+Recipients who download these files get corrupt or no data. Restore them from a backup, or ask their owners to upload them again.
+
+View integrity report: %s/admin/integrity
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, rowsText, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyAdminReports).SendEmail(adminEmail, subject, htmlBody, textBody)
+}
+
+// SendBackupFailedEmail tells an admin that a backup to the backup target failed
+func SendBackupFailedEmail(adminEmail, backupName, target, errorMessage string, failedAt time.Time, serverURL, companyName string) error {
+	subject := fmt.Sprintf("Backup failed - %s", companyName)
+	failedText := failedAt.Format("2006-01-02 15:04")
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #dc2626; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.error { background: #fee2e2; border-left: 4px solid #dc2626; padding: 12px 16px; margin: 20px 0; font-family: monospace; font-size: 13px; word-break: break-word; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>💾 Backup Failed</h1>
+		</div>
+
+		<div class="content">
+			<p>The database backup <strong>%s</strong> to <strong>%s</strong> failed at %s:</p>
+			<div class="error">%s</div>
+			<p>Until a backup succeeds, the latest copy of the database at the backup target is older than planned. Check the backup target and run a backup again.</p>
+
+			<p style="text-align: center;">
+				<a href="%s/admin/backup" class="button">View Backups</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(backupName), html.EscapeString(target), failedText, html.EscapeString(errorMessage), serverURL, companyName)
+
+	textBody := fmt.Sprintf(`Backup Failed
+
+The database backup %s to %s failed at %s:
+
+%s
+
+Until a backup succeeds, the latest copy of the database at the backup target is older than planned. Check the backup target and run a backup again.
+
+View backups: %s/admin/backup
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, backupName, target, failedText, errorMessage, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyAdminReports).SendEmail(adminEmail, subject, htmlBody, textBody)
+}
+
+// SendFileExpiryReminderEmail reminds a recipient that a file shared with them expires soon
+func SendFileExpiryReminderEmail(recipientEmail, fileName string, expiresAt time.Time, fileURL, optOutURL, companyName string) error {
+	subject := fmt.Sprintf("Reminder: %s expires soon - %s", fileName, companyName)
+	expiresText := expiresAt.Format("2006-01-02 15:04")
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #ff9800; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>⏰ File Expires Soon</h1>
+		</div>
+
+		<div class="content">
+			<p>The file <strong>%s</strong> that was shared with you will no longer be available after <strong>%s</strong>.</p>
+			<p>If you still need it, download it before then.</p>
+
+			<p style="text-align: center;">
+				<a href="%s" class="button">Download File</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Don't want these reminders? <a href="%s">Unsubscribe</a></p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(fileName), expiresText, fileURL, companyName, optOutURL)
+
+	textBody := fmt.Sprintf(`File Expires Soon
+
+The file "%s" that was shared with you will no longer be available after %s.
+If you still need it, download it before then.
+
+Download: %s
+
+---
+This is an automated message from %s.
+Don't want these reminders? Unsubscribe: %s`, fileName, expiresText, fileURL, companyName, optOutURL)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return provider.SendEmail(recipientEmail, subject, htmlBody, textBody)
+}
+
+// SendFileExpiryPolicyEmail tells a file owner that a tightened expiry policy now limits how long their files stay available
+func SendFileExpiryPolicyEmail(ownerEmail string, files []*database.FileInfo, expiresAt time.Time, maxDays int, serverURL, companyName string) error {
+	subject := fmt.Sprintf("%d of your file(s) now expire - %s", len(files), companyName)
+	expiresText := expiresAt.Format("2006-01-02 15:04")
+
+	rowsHTML := ""
+	rowsText := ""
+	for _, file := range files {
+		fileURL := FileHistoryURL(serverURL, file.Id)
+		rowsHTML += fmt.Sprintf(`<li><a href="%s">%s</a></li>`, html.EscapeString(fileURL), html.EscapeString(file.Name))
+		rowsText += fmt.Sprintf("- %s: %s\n", file.Name, fileURL)
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #ff9800; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>⏳ File Expiry Policy Changed</h1>
+		</div>
+
+		<div class="content">
+			<p>Files may now be shared for at most %d days. The following files were set to never expire or to expire later, and will now expire on <strong>%s</strong>:</p>
+			<ul>
+				%s
+			</ul>
+			<p>After that date the files are moved to trash and their links stop working. Share them again if they are still needed.</p>
+
+			<p style="text-align: center;">
+				<a href="%s/dashboard" class="button">View My Files</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, maxDays, expiresText, rowsHTML, serverURL, companyName)
+
+	textBody := fmt.Sprintf(`File Expiry Policy Changed
+
+Files may now be shared for at most %d days. The following files were set to never expire or to expire later, and will now expire on %s:
+
+%s
+After that date the files are moved to trash and their links stop working. Share them again if they are still needed.
+
+View your files: %s/dashboard
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, maxDays, expiresText, rowsText, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyExpiry).SendEmail(ownerEmail, subject, htmlBody, textBody)
+}
+
+// SendReshareRequestEmail tells a file owner that a visitor of an expired link asked for a new one.
+// inTrash is set when the expiry cleanup already moved the file to trash.
+func SendReshareRequestEmail(ownerEmail, fileName, requesterName, requesterEmail, message, reason string, inTrash bool, serverURL, companyName string) error {
+	subject := fmt.Sprintf("New link requested for %s - %s", fileName, companyName)
+
+	requester := requesterEmail
+	if requesterName != "" {
+		requester = fmt.Sprintf("%s <%s>", requesterName, requesterEmail)
+	}
+
+	reasonText := "The link expired."
+	if reason == database.FileExpiredByDownloads {
+		reasonText = "The file reached its download limit."
+	}
+
+	nextStep := "Edit the file to extend its expiry or download limit, or share it again."
+	if inTrash {
+		nextStep = "The file has been moved to trash. Restore it or upload it again, then send a new link."
+	}
+
+	messageHTML := ""
+	messageText := ""
+	if message != "" {
+		messageHTML = fmt.Sprintf(`<p style="margin: 10px 0 0 0;"><strong>Message:</strong> %s</p>`, html.EscapeString(message))
+		messageText = fmt.Sprintf("Message: %s\n", message)
+	}
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #2563eb; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.file-box { background: white; border: 2px solid #2563eb; padding: 20px; margin: 20px 0; border-radius: 8px; }
+		.button { display: inline-block; background: #2563eb; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>🔁 New Link Requested</h1>
+		</div>
+
+		<div class="content">
+			<p>Someone opened an expired link to one of your files and asked for a new one. %s</p>
+
+			<div class="file-box">
+				<p style="margin: 0;"><strong>File:</strong> %s</p>
+				<p style="margin: 0;"><strong>Requested by:</strong> %s</p>
+				%s
+			</div>
+
+			<p>%s</p>
+			<p style="text-align: center;">
+				<a href="%s/dashboard" class="button">View My Files</a>
+			</p>
+			<p style="font-size: 13px; color: #666;">Ignore this email if you don't want to share the file again. The requester is not told either way.</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, reasonText, html.EscapeString(fileName), html.EscapeString(requester), messageHTML, nextStep, serverURL, companyName)
+
+	textBody := fmt.Sprintf(`New Link Requested
+
+Someone opened an expired link to one of your files and asked for a new one. %s
+
+File: %s
+Requested by: %s
+%s
+%s
+View your files: %s/dashboard
+
+Ignore this email if you don't want to share the file again. The requester is not told either way.
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, reasonText, fileName, requester, messageText, nextStep, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyReshareRequests).SendEmail(ownerEmail, subject, htmlBody, textBody)
+}
+
+// SendDownloadsLockedEmail tells a file owner that downloads of their file were locked after
+// unusual download activity
+func SendDownloadsLockedEmail(ownerEmail, fileName, reason, until, serverURL, companyName string) error {
+	subject := fmt.Sprintf("Downloads of %s locked - %s", fileName, companyName)
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #e65100; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.file-box { background: white; border: 2px solid #e65100; padding: 20px; margin: 20px 0; border-radius: 8px; }
+		.button { display: inline-block; background: #e65100; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>🛡️ Downloads Locked</h1>
+		</div>
+
+		<div class="content">
+			<p>One of your files was downloaded in an unusual way, so further downloads have been locked for a while. Visitors see a page saying the file is temporarily locked for security.</p>
+
+			<div class="file-box">
+				<p style="margin: 0;"><strong>File:</strong> %s</p>
+				<p style="margin: 0;"><strong>Detected:</strong> %s</p>
+				<p style="margin: 0;"><strong>Locked until:</strong> %s</p>
+			</div>
+
+			<p>If you expected these downloads, you can unlock the file from your dashboard right away. Otherwise, consider deleting the file or changing its link.</p>
+			<p style="text-align: center;">
+				<a href="%s/dashboard" class="button">View My Files</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(fileName), html.EscapeString(reason), until, serverURL, companyName)
+
+	textBody := fmt.Sprintf(`Downloads Locked
+
+One of your files was downloaded in an unusual way, so further downloads have been locked for a while. Visitors see a page saying the file is temporarily locked for security.
+
+File: %s
+Detected: %s
+Locked until: %s
+
+If you expected these downloads, you can unlock the file from your dashboard right away. Otherwise, consider deleting the file or changing its link.
+View your files: %s/dashboard
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, fileName, reason, until, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyDownloadBlocks).SendEmail(ownerEmail, subject, htmlBody, textBody)
+}
+
+// SendUserPromotedEmail tells an admin that another user was given more privileges
+func SendUserPromotedEmail(adminEmail, userName, userEmail, oldLevel, newLevel, promotedBy, serverURL, companyName string) error {
+	subject := fmt.Sprintf("%s is now %s - %s", userEmail, newLevel, companyName)
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #c62828; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.file-box { background: white; border: 2px solid #c62828; padding: 20px; margin: 20px 0; border-radius: 8px; }
+		.button { display: inline-block; background: #c62828; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>🔑 User Promoted</h1>
+		</div>
+
+		<div class="content">
+			<p>A user was given more privileges on %s.</p>
+
+			<div class="file-box">
+				<p style="margin: 0;"><strong>User:</strong> %s (%s)</p>
+				<p style="margin: 0;"><strong>Changed from:</strong> %s</p>
+				<p style="margin: 0;"><strong>Changed to:</strong> %s</p>
+				<p style="margin: 0;"><strong>Changed by:</strong> %s</p>
+			</div>
+
+			<p>If you don't know about this change, review the user and the audit log.</p>
+			<p style="text-align: center;">
+				<a href="%s/admin/users" class="button">Manage Users</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, companyName, html.EscapeString(userName), html.EscapeString(userEmail), oldLevel, newLevel, html.EscapeString(promotedBy), serverURL, companyName)
+
+	textBody := fmt.Sprintf(`User Promoted
+
+A user was given more privileges on %s.
+
+User: %s (%s)
+Changed from: %s
+Changed to: %s
+Changed by: %s
+
+If you don't know about this change, review the user and the audit log.
+Manage users: %s/admin/users
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, companyName, userName, userEmail, oldLevel, newLevel, promotedBy, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifyAdminReports).SendEmail(adminEmail, subject, htmlBody, textBody)
+}
+
+// SendFileInfectedEmail tells a file owner that the virus scan found malware in their upload.
+// action says what happened to the file: it was quarantined or deleted.
+func SendFileInfectedEmail(ownerEmail, fileName, signature, action, serverURL, companyName string) error {
+	subject := fmt.Sprintf("Malware found in %s - %s", fileName, companyName)
+
+	htmlBody := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; }
+		.header { background: #c62828; color: white; padding: 30px; border-radius: 10px 10px 0 0; text-align: center; }
+		.header h1 { margin: 0; font-size: 26px; }
+		.content { background: #f9f9f9; padding: 30px; border-radius: 0 0 10px 10px; }
+		.file-box { background: white; border: 2px solid #c62828; padding: 20px; margin: 20px 0; border-radius: 8px; }
+		.button { display: inline-block; background: #c62828; color: white !important; padding: 15px 40px; text-decoration: none; border-radius: 8px; font-weight: bold; }
+		.footer { margin-top: 30px; padding-top: 20px; border-top: 2px solid #ddd; font-size: 12px; color: #666; text-align: center; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="header">
+			<h1>🦠 Malware Found</h1>
+		</div>
+
+		<div class="content">
+			<p>The virus scan found malware in one of your files. Nobody can download it.</p>
+
+			<div class="file-box">
+				<p style="margin: 0;"><strong>File:</strong> %s</p>
+				<p style="margin: 0;"><strong>Detected:</strong> %s</p>
+				<p style="margin: 0;"><strong>Action taken:</strong> %s</p>
+			</div>
+
+			<p>If you received this file from someone else, let them know their device may be infected. Do not open your own copy of the file.</p>
+			<p style="text-align: center;">
+				<a href="%s/dashboard" class="button">View My Files</a>
+			</p>
+		</div>
+
+		<div class="footer">
+			<p>This is an automated message from %s.</p>
+			<p>Do not reply to this email.</p>
+		</div>
+	</div>
+</body>
+</html>`, html.EscapeString(fileName), html.EscapeString(signature), html.EscapeString(action), serverURL, companyName)
+
+	textBody := fmt.Sprintf(`Malware Found
+
+The virus scan found malware in one of your files. Nobody can download it.
+
+File: %s
+Detected: %s
+Action taken: %s
+
+If you received this file from someone else, let them know their device may be infected. Do not open your own copy of the file.
+View your files: %s/dashboard
+
+---
+This is an automated message from %s.
+Do not reply to this email.`, fileName, signature, action, serverURL, companyName)
+
+	provider, err := GetActiveProvider(database.DB)
+	if err != nil {
+		return err
+	}
+
+	return ForCategory(provider, database.NotifySecurity).SendEmail(ownerEmail, subject, htmlBody, textBody)
+}
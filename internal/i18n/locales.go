@@ -0,0 +1,73 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+// Package i18n describes the languages WulfVault can be configured for
+package i18n
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage is used when no deployment language is configured
+const DefaultLanguage = "en"
+
+const (
+	DirectionLTR = "ltr"
+	DirectionRTL = "rtl"
+)
+
+// Locale describes a supported UI language
+type Locale struct {
+	Code      string // BCP 47 language code, e.g. "en" or "ar"
+	Name      string // Native name shown in language pickers
+	Direction string // Text direction, "ltr" or "rtl"
+}
+
+// IsRTL reports whether the locale is written right-to-left
+func (l Locale) IsRTL() bool {
+	return l.Direction == DirectionRTL
+}
+
+var locales = map[string]Locale{
+	"en": {Code: "en", Name: "English", Direction: DirectionLTR},
+	"sv": {Code: "sv", Name: "Svenska", Direction: DirectionLTR},
+	"de": {Code: "de", Name: "Deutsch", Direction: DirectionLTR},
+	"fr": {Code: "fr", Name: "Français", Direction: DirectionLTR},
+	"es": {Code: "es", Name: "Español", Direction: DirectionLTR},
+	"ar": {Code: "ar", Name: "العربية", Direction: DirectionRTL},
+	"he": {Code: "he", Name: "עברית", Direction: DirectionRTL},
+	"fa": {Code: "fa", Name: "فارسی", Direction: DirectionRTL},
+	"ur": {Code: "ur", Name: "اردو", Direction: DirectionRTL},
+}
+
+// Lookup returns the locale for a language code. Region subtags are ignored,
+// so "ar-SA" resolves to Arabic.
+func Lookup(code string) (Locale, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	locale, ok := locales[code]
+	return locale, ok
+}
+
+// Get returns the locale for a language code, falling back to DefaultLanguage
+func Get(code string) Locale {
+	if locale, ok := Lookup(code); ok {
+		return locale
+	}
+	return locales[DefaultLanguage]
+}
+
+// Supported returns all supported locales sorted by code
+func Supported() []Locale {
+	list := make([]Locale, 0, len(locales))
+	for _, locale := range locales {
+		list = append(list, locale)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
@@ -0,0 +1,335 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// catalogs holds the translated strings of the pages shown to share recipients (splash,
+// password and expired pages). Languages without a catalog show these pages in English.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"splash.title":              "Download File",
+		"splash.note":               "Note from sender",
+		"splash.file_size":          "File Size",
+		"splash.downloads":          "Downloads",
+		"splash.remaining":          "Remaining",
+		"splash.expires":            "Expires",
+		"splash.auth_required":      "Authentication Required",
+		"splash.poem":               "While waiting, here is Poem of the Day",
+		"splash.download":           "Download File",
+		"splash.powered_by":         "Powered by",
+		"splash.language":           "Language",
+		"splash.preview":            "Preview",
+		"splash.preview_truncated":  "Only the beginning of the file is shown",
+		"splash.download_converted": "Download as %s",
+		"splash.burn_notice":        "This link works for one download only",
+		"password.title":            "Password Required",
+		"password.note":             "Note",
+		"password.size":             "Size",
+		"password.info":             "This file is password protected. Please enter the password to download.",
+		"password.label":            "Password",
+		"password.unlock":           "Unlock & Download",
+		"password.invalid_form":     "Invalid form data",
+		"password.required":         "Password required",
+		"password.incorrect":        "Incorrect password",
+		"expired.title":             "File No Longer Available",
+		"expired.message":           "This file has expired and is no longer available for download.",
+		"expired.message_time":      "This link has expired and the file is no longer available for download.",
+		"expired.message_downloads": "This file has reached its download limit and is no longer available for download.",
+		"reshare.intro":             "Still need the file? Ask the sender for a new link.",
+		"reshare.name":              "Your name",
+		"reshare.email":             "Your email address",
+		"reshare.message":           "Message to the sender (optional)",
+		"reshare.button":            "Request a New Link",
+		"reshare.sent_title":        "Request Sent",
+		"reshare.sent_message":      "The sender has been asked for a new link. If they share the file again, they will contact you.",
+		"reshare.invalid_email":     "Please enter a valid email address.",
+		"reshare.too_many":          "Too many requests have been sent from your network. Please try again later.",
+		"locked.title":              "Temporarily Locked",
+		"locked.message":            "Downloads of this file are temporarily locked for security reasons. Please try again after %s.",
+		"burned.title":              "Link Already Used",
+		"burned.message":            "This link could only be used once and the file has already been downloaded. Please ask the sender to share it again.",
+		"terms.title":               "Download Terms",
+		"terms.accept":              "I have read and accept these terms (version %d)",
+		"terms.required":            "Please accept the terms before downloading.",
+		"notice.title":              "Legal Notice",
+		"notice.text":               "This file is confidential and intended only for the recipient it was shared with. By downloading it you confirm that you are an intended recipient and that you will not copy, forward or disclose it without permission.",
+		"notice.agree":              "I agree",
+		"notice.agreed":             "Agreed - the download is unlocked",
+		"notice.required":           "Please read the notice and click \"I agree\" before downloading.",
+	},
+	"sv": {
+		"splash.title":              "Ladda ner fil",
+		"splash.note":               "Meddelande från avsändaren",
+		"splash.file_size":          "Filstorlek",
+		"splash.downloads":          "Nedladdningar",
+		"splash.remaining":          "Återstående",
+		"splash.expires":            "Upphör",
+		"splash.auth_required":      "Inloggning krävs",
+		"splash.poem":               "Medan du väntar, här är dagens dikt",
+		"splash.download":           "Ladda ner fil",
+		"splash.powered_by":         "Drivs av",
+		"splash.language":           "Språk",
+		"splash.preview":            "Förhandsvisning",
+		"splash.preview_truncated":  "Endast början av filen visas",
+		"splash.download_converted": "Ladda ner som %s",
+		"splash.burn_notice":        "Den här länken fungerar för en enda nedladdning",
+		"password.title":            "Lösenord krävs",
+		"password.note":             "Meddelande",
+		"password.size":             "Storlek",
+		"password.info":             "Den här filen är lösenordsskyddad. Ange lösenordet för att ladda ner den.",
+		"password.label":            "Lösenord",
+		"password.unlock":           "Lås upp och ladda ner",
+		"password.invalid_form":     "Ogiltiga formulärdata",
+		"password.required":         "Lösenord krävs",
+		"password.incorrect":        "Fel lösenord",
+		"expired.title":             "Filen är inte längre tillgänglig",
+		"expired.message":           "Den här filen har gått ut och kan inte längre laddas ner.",
+		"expired.message_time":      "Länken har gått ut och filen kan inte längre laddas ner.",
+		"expired.message_downloads": "Filen har nått sin gräns för antal nedladdningar och kan inte längre laddas ner.",
+		"reshare.intro":             "Behöver du fortfarande filen? Be avsändaren om en ny länk.",
+		"reshare.name":              "Ditt namn",
+		"reshare.email":             "Din e-postadress",
+		"reshare.message":           "Meddelande till avsändaren (valfritt)",
+		"reshare.button":            "Begär en ny länk",
+		"reshare.sent_title":        "Begäran skickad",
+		"reshare.sent_message":      "Avsändaren har ombetts om en ny länk. Om filen delas igen hör avsändaren av sig till dig.",
+		"reshare.invalid_email":     "Ange en giltig e-postadress.",
+		"reshare.too_many":          "För många begäranden har skickats från ditt nätverk. Försök igen senare.",
+		"locked.title":              "Tillfälligt låst",
+		"locked.message":            "Nedladdningar av den här filen är tillfälligt låsta av säkerhetsskäl. Försök igen efter %s.",
+		"burned.title":              "Länken har redan använts",
+		"burned.message":            "Den här länken kunde bara användas en gång och filen har redan laddats ner. Be avsändaren att dela den igen.",
+		"terms.title":               "Villkor för nedladdning",
+		"terms.accept":              "Jag har läst och godkänner villkoren (version %d)",
+		"terms.required":            "Godkänn villkoren innan du laddar ner.",
+		"notice.title":              "Juridiskt meddelande",
+		"notice.text":               "Den här filen är konfidentiell och endast avsedd för den mottagare den delades med. Genom att ladda ner den bekräftar du att du är en avsedd mottagare och att du inte kopierar, vidarebefordrar eller röjer den utan tillstånd.",
+		"notice.agree":              "Jag godkänner",
+		"notice.agreed":             "Godkänt - nedladdningen är upplåst",
+		"notice.required":           "Läs meddelandet och klicka på \"Jag godkänner\" innan du laddar ner.",
+	},
+	"de": {
+		"splash.title":              "Datei herunterladen",
+		"splash.note":               "Nachricht vom Absender",
+		"splash.file_size":          "Dateigröße",
+		"splash.downloads":          "Downloads",
+		"splash.remaining":          "Verbleibend",
+		"splash.expires":            "Läuft ab",
+		"splash.auth_required":      "Anmeldung erforderlich",
+		"splash.poem":               "Während Sie warten: das Gedicht des Tages",
+		"splash.download":           "Datei herunterladen",
+		"splash.powered_by":         "Bereitgestellt von",
+		"splash.language":           "Sprache",
+		"splash.preview":            "Vorschau",
+		"splash.preview_truncated":  "Nur der Anfang der Datei wird angezeigt",
+		"splash.download_converted": "Als %s herunterladen",
+		"splash.burn_notice":        "Dieser Link funktioniert nur für einen Download",
+		"password.title":            "Passwort erforderlich",
+		"password.note":             "Hinweis",
+		"password.size":             "Größe",
+		"password.info":             "Diese Datei ist passwortgeschützt. Bitte geben Sie das Passwort ein, um sie herunterzuladen.",
+		"password.label":            "Passwort",
+		"password.unlock":           "Entsperren & herunterladen",
+		"password.invalid_form":     "Ungültige Formulardaten",
+		"password.required":         "Passwort erforderlich",
+		"password.incorrect":        "Falsches Passwort",
+		"expired.title":             "Datei nicht mehr verfügbar",
+		"expired.message":           "Diese Datei ist abgelaufen und kann nicht mehr heruntergeladen werden.",
+		"expired.message_time":      "Dieser Link ist abgelaufen und die Datei kann nicht mehr heruntergeladen werden.",
+		"expired.message_downloads": "Diese Datei hat ihr Download-Limit erreicht und kann nicht mehr heruntergeladen werden.",
+		"reshare.intro":             "Benötigen Sie die Datei noch? Bitten Sie den Absender um einen neuen Link.",
+		"reshare.name":              "Ihr Name",
+		"reshare.email":             "Ihre E-Mail-Adresse",
+		"reshare.message":           "Nachricht an den Absender (optional)",
+		"reshare.button":            "Neuen Link anfordern",
+		"reshare.sent_title":        "Anfrage gesendet",
+		"reshare.sent_message":      "Der Absender wurde um einen neuen Link gebeten. Wenn die Datei erneut geteilt wird, meldet sich der Absender bei Ihnen.",
+		"reshare.invalid_email":     "Bitte geben Sie eine gültige E-Mail-Adresse ein.",
+		"reshare.too_many":          "Aus Ihrem Netzwerk wurden zu viele Anfragen gesendet. Bitte versuchen Sie es später erneut.",
+		"locked.title":              "Vorübergehend gesperrt",
+		"locked.message":            "Downloads dieser Datei sind aus Sicherheitsgründen vorübergehend gesperrt. Bitte versuchen Sie es nach %s erneut.",
+		"burned.title":              "Link bereits verwendet",
+		"burned.message":            "Dieser Link konnte nur einmal verwendet werden und die Datei wurde bereits heruntergeladen. Bitte bitten Sie den Absender, sie erneut zu teilen.",
+		"terms.title":               "Nutzungsbedingungen",
+		"terms.accept":              "Ich habe diese Bedingungen gelesen und akzeptiere sie (Version %d)",
+		"terms.required":            "Bitte akzeptieren Sie die Bedingungen vor dem Herunterladen.",
+		"notice.title":              "Rechtlicher Hinweis",
+		"notice.text":               "Diese Datei ist vertraulich und nur für den Empfänger bestimmt, mit dem sie geteilt wurde. Mit dem Herunterladen bestätigen Sie, dass Sie ein vorgesehener Empfänger sind und die Datei nicht ohne Erlaubnis kopieren, weiterleiten oder offenlegen.",
+		"notice.agree":              "Ich stimme zu",
+		"notice.agreed":             "Zugestimmt - der Download ist freigeschaltet",
+		"notice.required":           "Bitte lesen Sie den Hinweis und klicken Sie vor dem Herunterladen auf \"Ich stimme zu\".",
+	},
+	"fr": {
+		"splash.title":              "Télécharger le fichier",
+		"splash.note":               "Message de l'expéditeur",
+		"splash.file_size":          "Taille du fichier",
+		"splash.downloads":          "Téléchargements",
+		"splash.remaining":          "Restants",
+		"splash.expires":            "Expire le",
+		"splash.auth_required":      "Authentification requise",
+		"splash.poem":               "En attendant, voici le poème du jour",
+		"splash.download":           "Télécharger le fichier",
+		"splash.powered_by":         "Propulsé par",
+		"splash.language":           "Langue",
+		"splash.preview":            "Aperçu",
+		"splash.preview_truncated":  "Seul le début du fichier est affiché",
+		"splash.download_converted": "Télécharger en %s",
+		"splash.burn_notice":        "Ce lien ne fonctionne que pour un seul téléchargement",
+		"password.title":            "Mot de passe requis",
+		"password.note":             "Note",
+		"password.size":             "Taille",
+		"password.info":             "Ce fichier est protégé par un mot de passe. Saisissez le mot de passe pour le télécharger.",
+		"password.label":            "Mot de passe",
+		"password.unlock":           "Déverrouiller et télécharger",
+		"password.invalid_form":     "Données de formulaire invalides",
+		"password.required":         "Mot de passe requis",
+		"password.incorrect":        "Mot de passe incorrect",
+		"expired.title":             "Fichier plus disponible",
+		"expired.message":           "Ce fichier a expiré et ne peut plus être téléchargé.",
+		"expired.message_time":      "Ce lien a expiré et le fichier ne peut plus être téléchargé.",
+		"expired.message_downloads": "Ce fichier a atteint sa limite de téléchargements et ne peut plus être téléchargé.",
+		"reshare.intro":             "Vous avez encore besoin du fichier ? Demandez un nouveau lien à l'expéditeur.",
+		"reshare.name":              "Votre nom",
+		"reshare.email":             "Votre adresse e-mail",
+		"reshare.message":           "Message à l'expéditeur (facultatif)",
+		"reshare.button":            "Demander un nouveau lien",
+		"reshare.sent_title":        "Demande envoyée",
+		"reshare.sent_message":      "Un nouveau lien a été demandé à l'expéditeur. S'il partage à nouveau le fichier, il vous contactera.",
+		"reshare.invalid_email":     "Veuillez saisir une adresse e-mail valide.",
+		"reshare.too_many":          "Trop de demandes ont été envoyées depuis votre réseau. Veuillez réessayer plus tard.",
+		"locked.title":              "Temporairement verrouillé",
+		"locked.message":            "Les téléchargements de ce fichier sont temporairement verrouillés pour des raisons de sécurité. Veuillez réessayer après %s.",
+		"burned.title":              "Lien déjà utilisé",
+		"burned.message":            "Ce lien ne pouvait être utilisé qu'une seule fois et le fichier a déjà été téléchargé. Veuillez demander à l'expéditeur de le partager à nouveau.",
+		"terms.title":               "Conditions de téléchargement",
+		"terms.accept":              "J'ai lu et j'accepte ces conditions (version %d)",
+		"terms.required":            "Veuillez accepter les conditions avant de télécharger.",
+		"notice.title":              "Mention légale",
+		"notice.text":               "Ce fichier est confidentiel et destiné uniquement au destinataire avec qui il a été partagé. En le téléchargeant, vous confirmez être un destinataire prévu et vous engagez à ne pas le copier, le transférer ni le divulguer sans autorisation.",
+		"notice.agree":              "J'accepte",
+		"notice.agreed":             "Accepté - le téléchargement est débloqué",
+		"notice.required":           "Veuillez lire la mention et cliquer sur \"J'accepte\" avant de télécharger.",
+	},
+	"es": {
+		"splash.title":              "Descargar archivo",
+		"splash.note":               "Nota del remitente",
+		"splash.file_size":          "Tamaño del archivo",
+		"splash.downloads":          "Descargas",
+		"splash.remaining":          "Restantes",
+		"splash.expires":            "Caduca",
+		"splash.auth_required":      "Autenticación requerida",
+		"splash.poem":               "Mientras espera, aquí está el poema del día",
+		"splash.download":           "Descargar archivo",
+		"splash.powered_by":         "Con la tecnología de",
+		"splash.language":           "Idioma",
+		"splash.preview":            "Vista previa",
+		"splash.preview_truncated":  "Solo se muestra el comienzo del archivo",
+		"splash.download_converted": "Descargar como %s",
+		"splash.burn_notice":        "Este enlace solo sirve para una descarga",
+		"password.title":            "Contraseña requerida",
+		"password.note":             "Nota",
+		"password.size":             "Tamaño",
+		"password.info":             "Este archivo está protegido con contraseña. Introduzca la contraseña para descargarlo.",
+		"password.label":            "Contraseña",
+		"password.unlock":           "Desbloquear y descargar",
+		"password.invalid_form":     "Datos de formulario no válidos",
+		"password.required":         "Contraseña requerida",
+		"password.incorrect":        "Contraseña incorrecta",
+		"expired.title":             "El archivo ya no está disponible",
+		"expired.message":           "Este archivo ha caducado y ya no se puede descargar.",
+		"expired.message_time":      "Este enlace ha caducado y el archivo ya no se puede descargar.",
+		"expired.message_downloads": "Este archivo ha alcanzado su límite de descargas y ya no se puede descargar.",
+		"reshare.intro":             "¿Todavía necesitas el archivo? Pide un nuevo enlace al remitente.",
+		"reshare.name":              "Tu nombre",
+		"reshare.email":             "Tu dirección de correo electrónico",
+		"reshare.message":           "Mensaje para el remitente (opcional)",
+		"reshare.button":            "Solicitar un nuevo enlace",
+		"reshare.sent_title":        "Solicitud enviada",
+		"reshare.sent_message":      "Se ha pedido un nuevo enlace al remitente. Si vuelve a compartir el archivo, se pondrá en contacto contigo.",
+		"reshare.invalid_email":     "Introduce una dirección de correo electrónico válida.",
+		"reshare.too_many":          "Se han enviado demasiadas solicitudes desde tu red. Inténtalo de nuevo más tarde.",
+		"locked.title":              "Bloqueado temporalmente",
+		"locked.message":            "Las descargas de este archivo están bloqueadas temporalmente por motivos de seguridad. Inténtelo de nuevo después de %s.",
+		"burned.title":              "Enlace ya utilizado",
+		"burned.message":            "Este enlace solo podía usarse una vez y el archivo ya se ha descargado. Pida al remitente que lo comparta de nuevo.",
+		"terms.title":               "Condiciones de descarga",
+		"terms.accept":              "He leído y acepto estas condiciones (versión %d)",
+		"terms.required":            "Acepte las condiciones antes de descargar.",
+		"notice.title":              "Aviso legal",
+		"notice.text":               "Este archivo es confidencial y está destinado únicamente al destinatario con quien se compartió. Al descargarlo, confirma que es un destinatario previsto y que no lo copiará, reenviará ni divulgará sin permiso.",
+		"notice.agree":              "Acepto",
+		"notice.agreed":             "Aceptado - la descarga está desbloqueada",
+		"notice.required":           "Lea el aviso y haga clic en \"Acepto\" antes de descargar.",
+	},
+}
+
+// HasCatalog reports whether recipient pages are translated into the locale's language
+func HasCatalog(code string) bool {
+	_, ok := catalogs[code]
+	return ok
+}
+
+// Translated returns the locales that have a catalog, sorted by code
+func Translated() []Locale {
+	var list []Locale
+	for _, locale := range Supported() {
+		if HasCatalog(locale.Code) {
+			list = append(list, locale)
+		}
+	}
+	return list
+}
+
+// T returns the translation of key for a language code, falling back to English
+func T(code, key string) string {
+	if message, ok := catalogs[code][key]; ok {
+		return message
+	}
+	if message, ok := catalogs[DefaultLanguage][key]; ok {
+		return message
+	}
+	return key
+}
+
+// Match returns the most preferred locale with a catalog from an Accept-Language header
+func Match(acceptLanguage string) (Locale, bool) {
+	type preference struct {
+		code    string
+		quality float64
+	}
+
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+		if quality > 0 {
+			preferences = append(preferences, preference{code: tag, quality: quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].quality > preferences[j].quality })
+
+	for _, p := range preferences {
+		if locale, ok := Lookup(p.code); ok && HasCatalog(locale.Code) {
+			return locale, true
+		}
+	}
+	return Locale{}, false
+}
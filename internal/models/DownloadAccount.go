@@ -23,6 +23,7 @@ type DownloadAccount struct {
 	DeletedAt     int64  `json:"deletedAt" redis:"DeletedAt"`         // Unix timestamp, 0 = not deleted
 	DeletedBy     string `json:"deletedBy" redis:"DeletedBy"`         // "user", "admin", or "system"
 	OriginalEmail string `json:"originalEmail" redis:"OriginalEmail"` // Store original email before deletion
+	CreatedBy     int    `json:"createdBy" redis:"CreatedBy"`         // Admin user ID that created the account, 0 = self-registered
 }
 
 // DownloadLog tracks individual download events
@@ -37,6 +38,11 @@ type DownloadLog struct {
 	FileSize          int64  `json:"fileSize"`          // Size in bytes
 	FileName          string `json:"fileName"`          // Name of file downloaded
 	IsAuthenticated   bool   `json:"isAuthenticated"`   // True if download required authentication
+	TermsVersion      int    `json:"termsVersion"`      // Version of the download terms accepted (0 = none)
+	TermsAcceptedAt   int64  `json:"termsAcceptedAt"`   // Unix timestamp of the acceptance
+	Country           string `json:"country"`           // Country code from the reverse proxy, if it sends one
+	NoticeAcceptedAt  int64  `json:"noticeAcceptedAt"`  // Unix timestamp the legal notice was accepted (0 = none)
+	NoticeAcceptedIP  string `json:"noticeAcceptedIp"`  // IP address the legal notice was accepted from
 }
 
 // EmailLog tracks when files are shared via email
@@ -49,6 +55,9 @@ type EmailLog struct {
 	SentAt         int64  `json:"sentAt"`         // Unix timestamp
 	FileName       string `json:"fileName"`       // Name of file shared
 	FileSize       int64  `json:"fileSize"`       // Size in bytes
+	Status         string `json:"status"`         // "sent" or "failed"
+	Attempts       int    `json:"attempts"`       // Number of send attempts logged for this email
+	Provider       string `json:"provider"`       // Email provider that delivered it, "" if not sent
 }
 
 // GetReadableDate returns the date as YYYY-MM-DD HH:MM
@@ -23,6 +23,9 @@ type FileRequest struct {
 	AllowedFileTypes string `json:"allowedFileTypes"` // comma-separated
 	UsedByIP         string `json:"usedByIP"`         // IP address that used this link
 	UsedAt           int64  `json:"usedAt"`           // Unix timestamp when link was used
+	BrandName        string `json:"brandName"`        // Name shown on the upload page instead of the company name
+	BrandColor       string `json:"brandColor"`       // Accent color of the upload page (normalized hex or color name)
+	TeamId           int    `json:"teamId"`           // Team the request is made on behalf of (0 = none)
 }
 
 // IsExpired checks if the request has expired
@@ -14,24 +14,44 @@ import (
 type TeamRole int
 
 const (
-	// TeamRoleOwner can manage everything in the team
+	// TeamRoleOwner can manage everything in the team: members, their roles and the team itself
 	TeamRoleOwner TeamRole = 0
-	// TeamRoleAdmin can manage members and files
+	// TeamRoleAdmin can review uploads and remove any file from the team
 	TeamRoleAdmin TeamRole = 1
-	// TeamRoleMember can upload and view files
+	// TeamRoleMember can view the team's files and share and unshare their own
 	TeamRoleMember TeamRole = 2
 )
 
+// IsValid reports whether the role is one of the team roles
+func (r TeamRole) IsValid() bool {
+	return r == TeamRoleOwner || r == TeamRoleAdmin || r == TeamRoleMember
+}
+
+// String returns the role as a human-readable string
+func (r TeamRole) String() string {
+	switch r {
+	case TeamRoleOwner:
+		return "Owner"
+	case TeamRoleAdmin:
+		return "Admin"
+	case TeamRoleMember:
+		return "Member"
+	default:
+		return "Unknown"
+	}
+}
+
 // Team represents a collaborative workspace
 type Team struct {
-	Id             int    `json:"id"`
-	Name           string `json:"name"`
-	Description    string `json:"description"`
-	CreatedBy      int    `json:"createdBy"`
-	CreatedAt      int64  `json:"createdAt"`
-	StorageQuotaMB int64  `json:"storageQuotaMB"`
-	StorageUsedMB  int64  `json:"storageUsedMB"`
-	IsActive       bool   `json:"isActive"`
+	Id              int    `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	CreatedBy       int    `json:"createdBy"`
+	CreatedAt       int64  `json:"createdAt"`
+	StorageQuotaMB  int64  `json:"storageQuotaMB"`
+	StorageUsedMB   int64  `json:"storageUsedMB"`
+	IsActive        bool   `json:"isActive"`
+	RequireApproval bool   `json:"requireApproval"`
 }
 
 // TeamMember represents a user's membership in a team
@@ -94,26 +114,28 @@ func (t *Team) HasStorageSpace(fileSizeMB int64) bool {
 
 // GetReadableRole returns the role as a human-readable string
 func (tm *TeamMember) GetReadableRole() string {
-	switch tm.Role {
-	case TeamRoleOwner:
-		return "Owner"
-	case TeamRoleAdmin:
-		return "Admin"
-	case TeamRoleMember:
-		return "Member"
-	default:
-		return "Unknown"
-	}
+	return tm.Role.String()
 }
 
-// CanManageMembers returns true if the member can add/remove other members
+// CanManageMembers returns true if the member can add and remove members, change their roles
+// and delete the team
 func (tm *TeamMember) CanManageMembers() bool {
+	return tm.Role == TeamRoleOwner
+}
+
+// CanReviewUploads returns true if the member can approve or reject uploads shared with the team
+func (tm *TeamMember) CanReviewUploads() bool {
 	return tm.Role == TeamRoleOwner || tm.Role == TeamRoleAdmin
 }
 
-// CanManageFiles returns true if the member can share files to the team
+// CanManageFiles returns true if the member can share their own files to the team and unshare them
 func (tm *TeamMember) CanManageFiles() bool {
-	return tm.Role == TeamRoleOwner || tm.Role == TeamRoleAdmin || tm.Role == TeamRoleMember
+	return tm.Role.IsValid()
+}
+
+// CanUnshareOthersFiles returns true if the member can remove files other members shared
+func (tm *TeamMember) CanUnshareOthersFiles() bool {
+	return tm.Role == TeamRoleOwner || tm.Role == TeamRoleAdmin
 }
 
 // ToJson returns the team as a JSON object
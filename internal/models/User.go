@@ -33,6 +33,7 @@ type User struct {
 	TOTPSecret     string         `json:"-" redis:"TOTPSecret"`                  // TOTP secret (never expose in JSON)
 	TOTPEnabled    bool           `json:"totpEnabled" redis:"TOTPEnabled"`       // Whether 2FA is enabled
 	BackupCodes    string         `json:"-" redis:"BackupCodes"`                 // Hashed backup codes (JSON array)
+	ImpersonatedBy *User          `json:"-" redis:"-"`                           // Super admin viewing the app as this user, set per request and never stored
 }
 
 // GetReadableDate returns the date as YYYY-MM-DD HH:MM
@@ -0,0 +1,178 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// handleAdminBulkDelete moves several files to trash at once (POST /admin/files/bulk-delete).
+// The body is a JSON array of file IDs; the response lists the result per file.
+func (s *Server) handleAdminBulkDelete(w http.ResponseWriter, r *http.Request) {
+	s.handleAdminBulkFileAction(w, r, database.ActionFilesBulkDeleted, "deleted", func(fileIds []string, adminId int) ([]database.BulkFileResult, error) {
+		return database.DB.BulkDeleteFiles(fileIds, adminId)
+	})
+}
+
+// handleAdminBulkRestore restores several files from trash at once (POST /admin/trash/bulk-restore).
+// The body is a JSON array of file IDs; the response lists the result per file.
+func (s *Server) handleAdminBulkRestore(w http.ResponseWriter, r *http.Request) {
+	s.handleAdminBulkFileAction(w, r, database.ActionFilesBulkRestored, "restored", func(fileIds []string, adminId int) ([]database.BulkFileResult, error) {
+		return database.DB.BulkRestoreFiles(fileIds)
+	})
+}
+
+// handleAdminBulkFileAction reads the file IDs of a bulk action, runs it and records a single
+// audit entry listing the files it changed
+func (s *Server) handleAdminBulkFileAction(w http.ResponseWriter, r *http.Request, action, verb string,
+	run func(fileIds []string, adminId int) ([]database.BulkFileResult, error)) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	var fileIds []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&fileIds); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Expected a JSON array of file IDs")
+		return
+	}
+	if len(fileIds) == 0 {
+		s.sendError(w, http.StatusBadRequest, "No files selected")
+		return
+	}
+	if len(fileIds) > database.MaxBulkFileIDs {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("At most %d files can be changed at once", database.MaxBulkFileIDs))
+		return
+	}
+
+	results, err := run(fileIds, admin.Id)
+	if err != nil {
+		log.Printf("Error: Bulk file action %s failed: %v", action, err)
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(admin.Id),
+			UserEmail:  admin.Email,
+			Action:     action,
+			EntityType: database.EntityFile,
+			EntityID:   "bulk",
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"requested": len(fileIds),
+			}),
+			IPAddress: clientIP(r),
+			UserAgent: r.UserAgent(),
+			Success:   false,
+			ErrorMsg:  err.Error(),
+		})
+		s.sendError(w, http.StatusInternalServerError, "Failed to update files, nothing was changed")
+		return
+	}
+
+	changed := []string{}
+	for _, result := range results {
+		if result.Success {
+			changed = append(changed, result.FileID)
+		}
+	}
+	failed := len(results) - len(changed)
+
+	log.Printf("Admin %s bulk %s %d file(s), %d failed", admin.Email, verb, len(changed), failed)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     action,
+		EntityType: database.EntityFile,
+		EntityID:   "bulk",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"requested": len(fileIds),
+			"count":     len(changed),
+			"failed":    failed,
+			"file_ids":  changed,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"message":   fmt.Sprintf("%d file(s) %s, %d failed", len(changed), verb, failed),
+		"succeeded": len(changed),
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// bulkSelectionHTML renders the bar that selects files for a bulk action and the script that
+// sends the selected IDs to the endpoint. Every file on the page gets a bulkCheckboxHTML
+// checkbox; "select all" only selects files the search currently shows.
+func bulkSelectionHTML(endpoint, buttonLabel, confirmText, buttonColor string) string {
+	return `
+        <div class="bulk-bar" style="display: flex; gap: 16px; align-items: center; flex-wrap: wrap; background: white; padding: 12px 20px; border-radius: 12px; box-shadow: 0 2px 8px rgba(0,0,0,0.1); margin-bottom: 16px;">
+            <label style="display: flex; gap: 8px; align-items: center; font-size: 14px; cursor: pointer;">
+                <input type="checkbox" id="bulkSelectAll" onchange="toggleBulkSelectAll(this.checked)"> Select all visible
+            </label>
+            <span id="bulkCount" style="color: #666; font-size: 14px;">0 selected</span>
+            <button id="bulkActionButton" onclick="runBulkAction()" disabled style="margin-left: auto; padding: 8px 16px; border: none; border-radius: 8px; font-size: 14px; font-weight: 500; background: ` + buttonColor + `; color: white; cursor: pointer;">` + buttonLabel + `</button>
+        </div>
+    <script>
+        function bulkVisibleCheckboxes() {
+            return Array.from(document.querySelectorAll('.bulk-select')).filter(cb => cb.closest('.file-item').style.display !== 'none');
+        }
+
+        function toggleBulkSelectAll(checked) {
+            bulkVisibleCheckboxes().forEach(cb => cb.checked = checked);
+            updateBulkSelection();
+        }
+
+        function updateBulkSelection() {
+            const count = document.querySelectorAll('.bulk-select:checked').length;
+            document.getElementById('bulkCount').textContent = count + ' selected';
+            document.getElementById('bulkActionButton').disabled = count === 0;
+        }
+
+        async function runBulkAction() {
+            const fileIds = Array.from(document.querySelectorAll('.bulk-select:checked')).map(cb => cb.value);
+            if (fileIds.length === 0 || !confirm('` + confirmText + `'.replace('%d', fileIds.length))) return;
+
+            try {
+                const response = await fetch('` + endpoint + `', {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/json'},
+                    body: JSON.stringify(fileIds)
+                });
+                const result = await response.json();
+                if (!response.ok) {
+                    alert('Failed: ' + (result.error || 'Unknown error'));
+                    return;
+                }
+
+                const failures = result.results.filter(r => !r.success);
+                if (failures.length > 0) {
+                    alert(result.message + '\n\n' + failures.slice(0, 10).map(r => r.file_id + ': ' + r.error).join('\n'));
+                }
+                location.reload();
+            } catch (error) {
+                alert('Failed: ' + error.message);
+            }
+        }
+    </script>`
+}
+
+// bulkCheckboxHTML renders the checkbox that selects a file for a bulk action
+func bulkCheckboxHTML(fileID string) string {
+	return `<input type="checkbox" class="bulk-select" value="` + template.HTMLEscapeString(fileID) + `" onchange="updateBulkSelection()" style="width: 18px; height: 18px; margin-right: 16px; flex-shrink: 0; cursor: pointer;">`
+}
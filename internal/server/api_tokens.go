@@ -0,0 +1,371 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// apiTokenExpiryOptions are the lifetimes offered when creating an API token, in days (0 = never)
+var apiTokenExpiryOptions = []int{30, 90, 365, 0}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// browserOnly refuses requests made with an API token, for account and server settings a leaked
+// token must not be able to change, such as minting more tokens or turning off 2FA
+func (s *Server) browserOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, isToken := bearerToken(r); isToken {
+			log.Printf("⚠️  API token refused for browser-only page | Path: %s | IP: %s", r.URL.Path, clientIP(r))
+			s.sendError(w, http.StatusForbidden, "This can only be done from the browser, not with an API token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// serveWithAPIToken authenticates a request by its API token instead of a session and passes it
+// on with the token's user in the context, so the handlers behind requireAuth and requireAdmin
+// work the same for scripts. Failures get a JSON error rather than the login page.
+func (s *Server) serveWithAPIToken(w http.ResponseWriter, r *http.Request, token string, adminOnly bool, next http.HandlerFunc) {
+	user, err := database.DB.GetUserByAPIToken(token)
+	if err != nil {
+		log.Printf("⚠️  API token auth failed | Path: %s | IP: %s", r.URL.Path, clientIP(r))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="WulfVault"`)
+		s.sendError(w, http.StatusUnauthorized, "Invalid or expired API token")
+		return
+	}
+
+	if adminOnly && !user.IsAdmin() {
+		log.Printf("⚠️  Admin auth failed: API token of %s (ID: %d) is not admin | Path: %s", user.Email, user.Id, r.URL.Path)
+		s.sendError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	next(w, r.WithContext(contextWithUser(r.Context(), user)))
+}
+
+// handleAPITokens shows the user's API tokens (GET) or creates or revokes one (POST)
+func (s *Server) handleAPITokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.renderAPITokens(w, user, "", "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.FormValue("action") {
+	case "create":
+		s.createAPIToken(w, r, user)
+	case "revoke":
+		tokenID, err := strconv.Atoi(r.FormValue("token_id"))
+		if err != nil {
+			s.renderAPITokens(w, user, "Error: Invalid token", "")
+			return
+		}
+		if err := database.DB.RevokeAPIToken(user.Id, tokenID); err != nil {
+			s.renderAPITokens(w, user, "Error: "+err.Error(), "")
+			return
+		}
+
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     database.ActionAPITokenRevoked,
+			EntityType: database.EntityUser,
+			EntityID:   fmt.Sprintf("%d", user.Id),
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"token_id": tokenID,
+			}),
+			IPAddress: clientIP(r),
+			UserAgent: r.UserAgent(),
+			Success:   true,
+		})
+		s.renderAPITokens(w, user, "Token revoked. Scripts using it can no longer sign in.", "")
+	default:
+		s.renderAPITokens(w, user, "Error: Unknown action", "")
+	}
+}
+
+// createAPIToken creates a token from the form and shows it once
+func (s *Server) createAPIToken(w http.ResponseWriter, r *http.Request, user *models.User) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		s.renderAPITokens(w, user, "Error: Give the token a name, e.g. the pipeline that uses it", "")
+		return
+	}
+	if len(name) > database.MaxAPITokenNameLength {
+		s.renderAPITokens(w, user, fmt.Sprintf("Error: The name can be at most %d characters", database.MaxAPITokenNameLength), "")
+		return
+	}
+
+	days, err := strconv.Atoi(r.FormValue("expires_days"))
+	if err != nil || days < 0 {
+		s.renderAPITokens(w, user, "Error: Invalid expiry", "")
+		return
+	}
+	var expiresAt int64
+	if days > 0 {
+		expiresAt = time.Now().AddDate(0, 0, days).Unix()
+	}
+
+	token, err := database.DB.CreateAPITokenWithExpiry(user.Id, name, expiresAt)
+	if err != nil {
+		log.Printf("Failed to create API token for %s: %v", user.Email, err)
+		s.renderAPITokens(w, user, "Error: Failed to create token", "")
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionAPITokenCreated,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"name":       name,
+			"expires_at": expiresAt,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.renderAPITokens(w, user, "", token)
+}
+
+// renderAPITokens renders the API token page. newToken is shown once, right after it was created.
+func (s *Server) renderAPITokens(w http.ResponseWriter, user *models.User, message, newToken string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The page may contain a new token, which must not end up in any cache
+	w.Header().Set("Cache-Control", "no-store")
+
+	tokens, err := database.DB.GetAPITokens(user.Id)
+	if err != nil {
+		log.Printf("Failed to load API tokens of %s: %v", user.Email, err)
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>API Tokens - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1000px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        .card p { color: #555; font-size: 14px; line-height: 1.6; margin-bottom: 10px; }
+        .btn {
+            padding: 10px 20px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        .btn-danger { background: #f44336; padding: 6px 14px; }
+        .form-row { display: flex; gap: 12px; flex-wrap: wrap; align-items: flex-end; }
+        .form-row label { display: block; font-size: 13px; font-weight: 600; color: #555; margin-bottom: 6px; }
+        .form-row input, .form-row select {
+            padding: 10px 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+        }
+        .form-row input { min-width: 280px; }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        .new-token {
+            background: #fff8e1;
+            border: 1px solid #ffc107;
+        }
+        .new-token code {
+            display: block;
+            background: #333;
+            color: #fff;
+            padding: 12px;
+            border-radius: 6px;
+            font-size: 14px;
+            word-break: break-all;
+            margin: 10px 0;
+        }
+        pre {
+            background: #f5f5f5;
+            padding: 12px;
+            border-radius: 6px;
+            font-size: 13px;
+            overflow-x: auto;
+        }
+        table { width: 100%; border-collapse: collapse; font-size: 14px; }
+        th, td { text-align: left; padding: 12px; border-bottom: 1px solid #eee; }
+        th { background: #fafafa; color: #555; font-weight: 600; }
+        .expired { color: #c33; font-weight: 600; }
+    </style>
+</head>
+<body>
+    ` + s.getHeaderHTML(user, user.IsAdmin()) + `
+    <div class="container">
+        <h2>🔑 API Tokens</h2>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	if newToken != "" {
+		html += `
+        <div class="card new-token">
+            <h3>Your new token</h3>
+            <p>Copy it now. It is stored hashed and can't be shown again.</p>
+            <code>` + template.HTMLEscapeString(newToken) + `</code>
+        </div>`
+	}
+
+	expiryOptions := ""
+	for _, days := range apiTokenExpiryOptions {
+		label := fmt.Sprintf("%d days", days)
+		if days == 0 {
+			label = "Never"
+		}
+		expiryOptions += fmt.Sprintf(`<option value="%d">%s</option>`, days, label)
+	}
+
+	html += `
+        <div class="card">
+            <h3>Create Token</h3>
+            <p>API tokens let scripts, e.g. CI pipelines, use the API as you. Send the token in an <code>Authorization: Bearer</code> header:</p>
+            <pre>curl -H "Authorization: Bearer wv_…" -F "file=@build.zip" ` + template.HTMLEscapeString(s.getPublicURL()) + `/api/v1/upload</pre>
+            <form method="POST" style="margin-top: 16px;">
+                <input type="hidden" name="action" value="create">
+                <div class="form-row">
+                    <div>
+                        <label for="tokenName">Name</label>
+                        <input type="text" id="tokenName" name="name" maxlength="` + strconv.Itoa(database.MaxAPITokenNameLength) + `" placeholder="e.g. Release pipeline" required>
+                    </div>
+                    <div>
+                        <label for="tokenExpiry">Expires after</label>
+                        <select id="tokenExpiry" name="expires_days">` + expiryOptions + `</select>
+                    </div>
+                    <button type="submit" class="btn">Create Token</button>
+                </div>
+            </form>
+        </div>
+
+        <div class="card">
+            <h3>Your Tokens</h3>`
+
+	if len(tokens) == 0 {
+		html += `
+            <p>You have no API tokens.</p>`
+	} else {
+		html += `
+            <table>
+                <tr><th>Name</th><th>Token</th><th>Created</th><th>Last used</th><th>Expires</th><th></th></tr>`
+		for _, token := range tokens {
+			expires := "Never"
+			if token.ExpiresAt > 0 {
+				expires = time.Unix(token.ExpiresAt, 0).Format("2006-01-02")
+			}
+			if token.IsExpired() {
+				expires = `<span class="expired">Expired ` + expires + `</span>`
+			}
+			lastUsed := "Never"
+			if token.LastUsedAt > 0 {
+				lastUsed = time.Unix(token.LastUsedAt, 0).Format("2006-01-02 15:04")
+			}
+
+			html += `
+                <tr>
+                    <td><strong>` + template.HTMLEscapeString(token.Name) + `</strong></td>
+                    <td><code>` + token.Prefix + `…</code></td>
+                    <td>` + time.Unix(token.CreatedAt, 0).Format("2006-01-02") + `</td>
+                    <td>` + lastUsed + `</td>
+                    <td>` + expires + `</td>
+                    <td>
+                        <form method="POST" onsubmit="return confirm('Revoke this token? Scripts using it stop working immediately.');">
+                            <input type="hidden" name="action" value="revoke">
+                            <input type="hidden" name="token_id" value="` + strconv.Itoa(token.Id) + `">
+                            <button type="submit" class="btn btn-danger">Revoke</button>
+                        </form>
+                    </td>
+                </tr>`
+		}
+		html += `
+            </table>`
+	}
+
+	html += `
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
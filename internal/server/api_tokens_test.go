@@ -0,0 +1,53 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// A valid API token can't manage tokens or change the account it belongs to
+func TestBrowserOnlyRefusesAPIToken(t *testing.T) {
+	s := newTestServer(t)
+	user := createTestUser(t, "user@example.com", models.UserLevelUser, 1000)
+	token, err := database.DB.CreateAPIToken(user.Id, "ci")
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+
+	withToken := func(method, path string, form url.Values) *http.Request {
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Authorization", "Bearer "+token)
+		return r
+	}
+
+	// The token itself works for the API
+	if w := serve(s.requireAuth(s.handleAPITokens), withToken(http.MethodGet, "/settings/api-tokens", nil)); w.Code != http.StatusOK {
+		t.Fatalf("token without browserOnly: status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w := serve(s.browserOnly(s.requireAuth(s.handleAPITokens)),
+		withToken(http.MethodPost, "/settings/api-tokens", url.Values{"action": {"create"}, "name": {"more"}}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("creating a token with a token: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if tokens, _ := database.DB.GetAPITokens(user.Id); len(tokens) != 1 {
+		t.Errorf("%d tokens after the refused request, want 1", len(tokens))
+	}
+
+	w = serve(s.browserOnly(s.requireAuth(s.handleSignOutEverywhere)), withToken(http.MethodPost, "/settings/sign-out-everywhere", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("signing out everywhere with a token: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -7,7 +7,6 @@ package server
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/Frimurare/WulfVault/internal/database"
 	"github.com/Frimurare/WulfVault/internal/models"
@@ -48,7 +47,7 @@ func (al *AuditLogger) LogAction(
 
 	// Add IP and User-Agent if request provided
 	if r != nil {
-		entry.IPAddress = al.getClientIP(r)
+		entry.IPAddress = clientIP(r)
 		entry.UserAgent = r.UserAgent()
 	}
 
@@ -410,26 +409,32 @@ func (al *AuditLogger) Log2FADisabled(user *models.User, r *http.Request) error
 	)
 }
 
-// getClientIP extracts the real client IP from request
-func (al *AuditLogger) getClientIP(r *http.Request) string {
-	// Try X-Forwarded-For first (for proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP in the list
-		if idx := strings.Index(xff, ","); idx != -1 {
-			return strings.TrimSpace(xff[:idx])
-		}
-		return strings.TrimSpace(xff)
-	}
-
-	// Try X-Real-IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// Fall back to RemoteAddr
-	if idx := strings.LastIndex(r.RemoteAddr, ":"); idx != -1 {
-		return r.RemoteAddr[:idx]
-	}
+// LogBackupCodesGenerated logs a new set of 2FA backup codes
+func (al *AuditLogger) LogBackupCodesGenerated(user *models.User, count int, r *http.Request) error {
+	return al.LogUserAction(
+		user,
+		database.ActionBackupCodesGenerated,
+		database.EntityUser,
+		fmt.Sprintf("%d", user.Id),
+		map[string]interface{}{
+			"email": user.Email,
+			"count": count,
+		},
+		r,
+	)
+}
 
-	return r.RemoteAddr
+// LogBackupCodeUsed logs a login with a 2FA backup code
+func (al *AuditLogger) LogBackupCodeUsed(user *models.User, remaining int, r *http.Request) error {
+	return al.LogUserAction(
+		user,
+		database.ActionBackupCodeUsed,
+		database.EntityUser,
+		fmt.Sprintf("%d", user.Id),
+		map[string]interface{}{
+			"email":     user.Email,
+			"remaining": remaining,
+		},
+		r,
+	)
 }
@@ -0,0 +1,925 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/auth"
+	"github.com/Frimurare/WulfVault/internal/cleanup"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/totp"
+)
+
+// Names inside a backup archive
+const (
+	backupManifestName = "backup.json"
+	backupDatabaseName = "wulfvault.db"
+	backupConfigName   = "config.json"
+	backupUploadsDir   = "uploads/"
+)
+
+// backupSkippedUploadDirs hold chunks of uploads in progress and previews that are made again
+// when needed, so they are left out of backups
+var backupSkippedUploadDirs = map[string]bool{".chunks": true, ".converted": true}
+
+// restoreMu lets only one restore run at a time
+var restoreMu sync.Mutex
+
+// backupManifest describes a backup archive
+type backupManifest struct {
+	Version         string `json:"version"`
+	CreatedAt       int64  `json:"created_at"`
+	IncludesUploads bool   `json:"includes_uploads"`
+}
+
+// writeBackup writes a backup archive of the database and config.json to w, and the uploads
+// directory too if includeUploads is set. The database is a consistent snapshot taken first.
+func (s *Server) writeBackup(w io.Writer, includeUploads bool) error {
+	snapshot, err := os.CreateTemp(s.config.DataDir, "backup-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	snapshotPath := snapshot.Name()
+	snapshot.Close()
+	// VACUUM INTO refuses to write to an existing file
+	os.Remove(snapshotPath)
+	defer os.Remove(snapshotPath)
+
+	if err := database.DB.BackupTo(snapshotPath); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	manifest, _ := json.MarshalIndent(backupManifest{
+		Version:         s.config.Version,
+		CreatedAt:       time.Now().Unix(),
+		IncludesUploads: includeUploads,
+	}, "", "  ")
+	if err := writeZipBytes(zw, backupManifestName, manifest); err != nil {
+		return err
+	}
+	if err := addFileToZip(zw, backupDatabaseName, snapshotPath, zip.Deflate); err != nil {
+		return err
+	}
+	configPath := filepath.Join(s.config.DataDir, backupConfigName)
+	if _, err := os.Stat(configPath); err == nil {
+		if err := addFileToZip(zw, backupConfigName, configPath, zip.Deflate); err != nil {
+			return err
+		}
+	}
+
+	if includeUploads {
+		err := filepath.WalkDir(s.config.UploadsDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if backupSkippedUploadDirs[d.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(s.config.UploadsDir, path)
+			if err != nil {
+				return err
+			}
+			// Uploaded files are mostly compressed already
+			return addFileToZip(zw, backupUploadsDir+filepath.ToSlash(rel), path, zip.Store)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to archive uploads: %w", err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeZipBytes(zw *zip.Writer, name string, data []byte) error {
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = entry.Write(data)
+	return err
+}
+
+func addFileToZip(zw *zip.Writer, name, path string, method uint16) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = method
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// extractZipFile writes an archive entry to path, replacing what is there only once the whole
+// entry was written
+func extractZipFile(f *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".restore-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// handleAdminBackupPage shows the backup and restore page
+func (s *Server) handleAdminBackupPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.renderAdminBackup(w, user, "")
+}
+
+// handleAdminBackup streams a backup archive of the database and config.json, and the
+// uploaded files if asked to, as a download
+func (s *Server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !user.IsSuperAdmin() || user.ImpersonatedBy != nil {
+		http.Error(w, "Only the super admin can download backups", http.StatusForbidden)
+		return
+	}
+
+	includeUploads := r.URL.Query().Get("uploads") == "1"
+	filename := "wulfvault-backup-" + time.Now().Format("20060102-150405") + ".zip"
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	counter := newCountingResponseWriter(w)
+	err := s.writeBackup(counter, includeUploads)
+	if err != nil {
+		log.Printf("❌ Backup by %s failed: %v", user.Email, err)
+		if counter.bytesWritten == 0 {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		}
+	} else {
+		log.Printf("💾 Backup downloaded by %s (%s, uploads included: %v)", user.Email, database.FormatFileSize(counter.bytesWritten), includeUploads)
+	}
+
+	entry := &database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionDatabaseBackup,
+		EntityType: database.EntitySystem,
+		EntityID:   "database",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"filename":         filename,
+			"includes_uploads": includeUploads,
+			"size":             counter.bytesWritten,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   err == nil,
+	}
+	if err != nil {
+		entry.ErrorMsg = err.Error()
+	}
+	database.DB.LogAction(entry)
+}
+
+// checkRestoreReauth checks the password, and the 2FA code if 2FA is on, that the super admin
+// enters to confirm a restore. Returns why they don't match, or "".
+func checkRestoreReauth(user *models.User, password, code string) string {
+	account, err := database.DB.GetUserByID(user.Id)
+	if err != nil {
+		return "Could not verify your identity"
+	}
+	if password == "" || !auth.CheckPasswordHash(password, account.Password) {
+		return "Incorrect password"
+	}
+	if account.TOTPEnabled {
+		secret, err := database.DB.GetTOTPSecret(account)
+		if err != nil || !totp.ValidateCode(strings.TrimSpace(code), secret) {
+			return "Invalid two-factor authentication code"
+		}
+	}
+	return ""
+}
+
+// handleAdminRestore replaces the database, and optionally config.json and the uploaded files,
+// with those in an uploaded backup archive. The super admin has to confirm with their password
+// (and 2FA code). The server is in maintenance mode while the database is swapped.
+func (s *Server) handleAdminRestore(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/backup", http.StatusSeeOther)
+		return
+	}
+	if !user.IsSuperAdmin() || user.ImpersonatedBy != nil {
+		http.Error(w, "Only the super admin can restore backups", http.StatusForbidden)
+		return
+	}
+
+	if !restoreMu.TryLock() {
+		s.renderAdminBackup(w, user, "Error: A restore is already running")
+		return
+	}
+	defer restoreMu.Unlock()
+
+	// The archive stays on disk while it is read, whatever its size
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		s.renderAdminBackup(w, user, "Error: Failed to read the uploaded backup")
+		return
+	}
+
+	logRestore := func(success bool, details map[string]interface{}, errorMsg string) {
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     database.ActionDatabaseRestored,
+			EntityType: database.EntitySystem,
+			EntityID:   "database",
+			Details:    database.CreateAuditDetails(details),
+			IPAddress:  clientIP(r),
+			UserAgent:  r.UserAgent(),
+			Success:    success,
+			ErrorMsg:   errorMsg,
+		})
+	}
+
+	if reason := checkRestoreReauth(user, r.FormValue("password"), r.FormValue("code")); reason != "" {
+		log.Printf("❌ Restore by %s refused: %s", user.Email, reason)
+		logRestore(false, map[string]interface{}{"reason": "reauthentication_failed"}, reason)
+		s.renderAdminBackup(w, user, "Error: "+reason)
+		return
+	}
+
+	file, header, err := r.FormFile("backup")
+	if err != nil {
+		s.renderAdminBackup(w, user, "Error: Choose a backup file to restore")
+		return
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		s.renderAdminBackup(w, user, "Error: The file is not a WulfVault backup archive")
+		return
+	}
+	var databaseEntry, configEntry *zip.File
+	var uploadEntries []*zip.File
+	var manifest backupManifest
+	for _, f := range archive.File {
+		switch {
+		case f.Name == backupDatabaseName:
+			databaseEntry = f
+		case f.Name == backupConfigName:
+			configEntry = f
+		case f.Name == backupManifestName:
+			if rc, err := f.Open(); err == nil {
+				json.NewDecoder(io.LimitReader(rc, 1<<20)).Decode(&manifest)
+				rc.Close()
+			}
+		case strings.HasPrefix(f.Name, backupUploadsDir) && !f.FileInfo().IsDir():
+			uploadEntries = append(uploadEntries, f)
+		}
+	}
+	if databaseEntry == nil {
+		s.renderAdminBackup(w, user, "Error: The archive has no "+backupDatabaseName)
+		return
+	}
+
+	restoreConfig := r.FormValue("restore_config") == "on" && configEntry != nil
+	restoreUploads := r.FormValue("restore_uploads") == "on" && len(uploadEntries) > 0
+	for _, f := range uploadEntries {
+		if !filepath.IsLocal(filepath.FromSlash(strings.TrimPrefix(f.Name, backupUploadsDir))) {
+			s.renderAdminBackup(w, user, "Error: The archive contains an unsafe file path: "+f.Name)
+			return
+		}
+	}
+
+	// The backup is validated before anything is replaced. It is extracted next to the
+	// database so it can be moved into place.
+	restorePath := filepath.Join(filepath.Dir(database.DB.Path()), fmt.Sprintf("restore-%d.db", time.Now().UnixNano()))
+	defer os.Remove(restorePath)
+	if err := extractZipFile(databaseEntry, restorePath); err != nil {
+		log.Printf("❌ Restore by %s failed to extract the database: %v", user.Email, err)
+		s.renderAdminBackup(w, user, "Error: Failed to extract the database from the backup")
+		return
+	}
+	if err := database.ValidateBackup(restorePath); err != nil {
+		log.Printf("❌ Restore by %s refused: %v", user.Email, err)
+		logRestore(false, map[string]interface{}{"filename": header.Filename, "reason": "invalid_backup"}, err.Error())
+		s.renderAdminBackup(w, user, "Error: Invalid backup: "+err.Error())
+		return
+	}
+
+	log.Printf("🚧 Restoring backup %s (made %s by version %s) for %s - maintenance mode until done",
+		header.Filename, time.Unix(manifest.CreatedAt, 0).Format("2006-01-02 15:04:05"), manifest.Version, user.Email)
+	maintenanceEnabled.Store(true)
+	keptPath, err := database.RestoreFrom(restorePath)
+	if err != nil {
+		loadMaintenanceMode()
+		log.Printf("❌ Restore by %s failed, the previous database is back in use: %v", user.Email, err)
+		logRestore(false, map[string]interface{}{"filename": header.Filename, "reason": "swap_failed"}, err.Error())
+		s.renderAdminBackup(w, user, "Error: "+err.Error()+". The previous database is back in use.")
+		return
+	}
+
+	var problems []string
+	if restoreConfig {
+		configPath := filepath.Join(s.config.DataDir, backupConfigName)
+		if data, err := os.ReadFile(configPath); err == nil {
+			os.WriteFile(configPath+".before-restore-"+time.Now().Format("20060102-150405"), data, 0600)
+		}
+		if err := extractZipFile(configEntry, configPath); err != nil {
+			problems = append(problems, "config.json could not be restored: "+err.Error())
+		}
+	}
+	restoredUploads := 0
+	if restoreUploads {
+		for _, f := range uploadEntries {
+			path := filepath.Join(s.config.UploadsDir, filepath.FromSlash(strings.TrimPrefix(f.Name, backupUploadsDir)))
+			if err := extractZipFile(f, path); err != nil {
+				problems = append(problems, fmt.Sprintf("%s could not be restored: %v", f.Name, err))
+				continue
+			}
+			restoredUploads++
+		}
+	}
+
+	// Bring what the server keeps in memory in line with the restored database
+	if _, err := database.DB.ReconcileUserStorage(); err != nil {
+		log.Printf("Warning: Could not recount storage usage after restore: %v", err)
+	}
+	s.loadBrandingConfig()
+	loadMaintenanceMode()
+	loadIPAccessRules()
+
+	details := map[string]interface{}{
+		"filename":          header.Filename,
+		"backup_created_at": manifest.CreatedAt,
+		"backup_version":    manifest.Version,
+		"previous_database": filepath.Base(keptPath),
+		"config_restored":   restoreConfig,
+		"uploads_restored":  restoredUploads,
+	}
+	if len(problems) > 0 {
+		details["problems"] = problems
+	}
+	logRestore(len(problems) == 0, details, strings.Join(problems, "; "))
+	log.Printf("✅ Backup %s restored by %s (previous database kept as %s, %d uploaded files restored)", header.Filename, user.Email, filepath.Base(keptPath), restoredUploads)
+
+	message := fmt.Sprintf("Backup restored. The previous database was kept as %s.", filepath.Base(keptPath))
+	if restoredUploads > 0 {
+		message += fmt.Sprintf(" %d uploaded files were restored.", restoredUploads)
+	}
+	if len(problems) > 0 {
+		message = "Error: " + message + " " + strings.Join(problems, " ")
+	}
+	message += " Restart the server so every setting from the backup takes effect. You may have to sign in again."
+	s.renderAdminBackup(w, user, message)
+}
+
+// renderAdminBackup renders the backup and restore page
+func (s *Server) renderAdminBackup(w http.ResponseWriter, user *models.User, message string) {
+	canChange := user.IsSuperAdmin() && user.ImpersonatedBy == nil
+	disabledAttr := ""
+	if !canChange {
+		disabledAttr = " disabled"
+	}
+
+	codeField := ""
+	if user.TOTPEnabled {
+		codeField = `
+                <div class="form-group">
+                    <label for="code">Two-Factor Authentication Code</label>
+                    <input type="text" id="code" name="code" inputmode="numeric" autocomplete="one-time-code" maxlength="6" required` + disabledAttr + `>
+                </div>`
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Backup & Restore - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 800px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            padding: 30px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            margin-bottom: 20px;
+        }
+        .card h2 {
+            color: #333;
+            margin-bottom: 20px;
+            font-size: 20px;
+        }
+        .form-group {
+            margin-bottom: 20px;
+        }
+        label {
+            display: block;
+            margin-bottom: 8px;
+            color: #333;
+            font-weight: 500;
+            font-size: 14px;
+        }
+        label.checkbox {
+            display: flex;
+            align-items: center;
+            gap: 8px;
+            font-weight: normal;
+        }
+        input[type="password"], input[type="text"], input[type="file"], input[type="number"], select {
+            width: 100%;
+            padding: 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+            font-family: inherit;
+        }
+        .help-text {
+            color: #666;
+            font-size: 12px;
+            margin-top: 4px;
+        }
+        .btn {
+            padding: 12px 24px;
+            border: none;
+            border-radius: 6px;
+            font-size: 14px;
+            font-weight: 600;
+            cursor: pointer;
+        }
+        .btn:disabled {
+            opacity: 0.5;
+            cursor: not-allowed;
+        }
+        .btn-primary {
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+        }
+        .btn-danger {
+            background: #dc3545;
+            color: white;
+        }
+        .success {
+            background: #d4edda;
+            border: 1px solid #c3e6cb;
+            color: #155724;
+            padding: 12px;
+            border-radius: 6px;
+            margin-bottom: 20px;
+        }
+        .error {
+            background: #f8d7da;
+            border: 1px solid #f5c6cb;
+            color: #721c24;
+            padding: 12px;
+            border-radius: 6px;
+            margin-bottom: 20px;
+        }
+        .schedule-status {
+            color: #555;
+            font-size: 14px;
+            margin-bottom: 20px;
+            line-height: 1.8;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+            margin-top: 20px;
+            font-size: 13px;
+        }
+        th, td {
+            text-align: left;
+            padding: 8px;
+            border-bottom: 1px solid #eee;
+            vertical-align: top;
+        }
+        th {
+            color: #666;
+            font-weight: 600;
+        }
+        .failed {
+            color: #c62828;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">`
+
+	if message != "" {
+		if strings.HasPrefix(message, "Error") {
+			html += `<div class="error">` + template.HTMLEscapeString(message) + `</div>`
+		} else {
+			html += `<div class="success">` + template.HTMLEscapeString(message) + `</div>`
+		}
+	}
+
+	if !canChange {
+		html += `
+        <p class="help-text" style="margin-bottom: 20px;">Only the super admin can download and restore backups.</p>`
+	}
+
+	html += `
+        <div class="card">
+            <h2>💾 Download Backup</h2>
+            <form method="GET" action="/admin/backup/download">
+                <div class="form-group">
+                    <label class="checkbox"><input type="checkbox" name="uploads" value="1"` + disabledAttr + `> Include uploaded files</label>
+                    <p class="help-text">Adds the uploads directory (` + template.HTMLEscapeString(s.config.UploadsDir) + `) to the archive. File contents kept in S3 storage are not included.</p>
+                </div>
+                <button type="submit" class="btn btn-primary"` + disabledAttr + `>Download Backup</button>
+            </form>
+            <p class="help-text" style="margin-top: 20px;">The archive contains a consistent snapshot of the database and config.json, taken while the server keeps running. It holds password hashes and secrets - store it safely. The key the 2FA secrets are encrypted with, totp.key in the data directory, is not included; keep a copy of it separately.</p>
+        </div>
+
+        <div class="card">
+            <h2>♻️ Restore Backup</h2>
+            <form method="POST" action="/admin/backup/restore" enctype="multipart/form-data" onsubmit="return confirm('Replace the entire database with this backup? Everything changed since the backup was made is lost.')">
+                <div class="form-group">
+                    <label for="backup">Backup Archive</label>
+                    <input type="file" id="backup" name="backup" accept=".zip,application/zip" required` + disabledAttr + `>
+                </div>
+                <div class="form-group">
+                    <label class="checkbox"><input type="checkbox" name="restore_config"` + disabledAttr + `> Also restore config.json</label>
+                    <label class="checkbox"><input type="checkbox" name="restore_uploads"` + disabledAttr + `> Also restore uploaded files in the backup</label>
+                    <p class="help-text">Uploaded files in the backup replace files with the same name. Other files are left in place.</p>
+                </div>
+                <div class="form-group">
+                    <label for="password">Confirm With Your Password</label>
+                    <input type="password" id="password" name="password" autocomplete="current-password" required` + disabledAttr + `>
+                </div>` + codeField + `
+                <button type="submit" class="btn btn-danger"` + disabledAttr + `>Restore Backup</button>
+            </form>
+            <p class="help-text" style="margin-top: 20px;">The backup is checked before anything is replaced. While the database is swapped the server is in maintenance mode. The replaced database is kept next to the new one in the data directory.</p>
+        </div>
+` + s.backupScheduleHTML(disabledAttr) + `
+    </div>
+    <script>
+        function toggleBackupTarget() {
+            document.getElementById('s3Settings').style.display = document.getElementById('backup_target').value === 's3' ? 'block' : 'none';
+        }
+        toggleBackupTarget();
+    </script>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+// maxBackupIntervalHours is the longest interval scheduled backups can be set to (30 days)
+const maxBackupIntervalHours = 720
+
+// handleAdminBackupSchedule saves how often scheduled backups are made, how many are kept and
+// where they are written
+func (s *Server) handleAdminBackupSchedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/backup#schedule", http.StatusSeeOther)
+		return
+	}
+	if !user.IsSuperAdmin() || user.ImpersonatedBy != nil {
+		http.Error(w, "Only the super admin can change scheduled backups", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.renderAdminBackup(w, user, "Error: Invalid form data")
+		return
+	}
+
+	intervalHours, err := strconv.Atoi(r.FormValue("backup_interval_hours"))
+	if err != nil || intervalHours < 0 || intervalHours > maxBackupIntervalHours {
+		s.renderAdminBackup(w, user, fmt.Sprintf("Error: Backup interval must be between 0 and %d hours", maxBackupIntervalHours))
+		return
+	}
+	retentionCount, err := strconv.Atoi(r.FormValue("backup_retention_count"))
+	if err != nil || retentionCount < 1 || retentionCount > database.MaxBackupRetentionCount {
+		s.renderAdminBackup(w, user, fmt.Sprintf("Error: Backups to keep must be between 1 and %d", database.MaxBackupRetentionCount))
+		return
+	}
+	target := database.BackupTargetLocal
+	if r.FormValue("backup_target") == database.BackupTargetS3 {
+		target = database.BackupTargetS3
+	}
+	directory := strings.TrimSpace(r.FormValue("backup_directory"))
+	if directory != "" && !filepath.IsAbs(directory) {
+		s.renderAdminBackup(w, user, "Error: The backup directory must be an absolute path")
+		return
+	}
+
+	current := database.DB.GetBackupSchedule()
+	bucket := strings.TrimSpace(r.FormValue("backup_s3_bucket"))
+	accessKey := strings.TrimSpace(r.FormValue("backup_s3_access_key"))
+	// The secret key is never shown again; leaving the field empty keeps it
+	secretKey := current.S3SecretKey
+	if newSecret := strings.TrimSpace(r.FormValue("backup_s3_secret_key")); newSecret != "" {
+		masterKey, err := email.GetOrCreateMasterKey(database.DB)
+		if err == nil {
+			secretKey, err = email.EncryptAPIKey(newSecret, masterKey)
+		}
+		if err != nil {
+			s.renderAdminBackup(w, user, "Error: Failed to encrypt the S3 secret key")
+			return
+		}
+	}
+	if target == database.BackupTargetS3 && (bucket == "" || accessKey == "" || secretKey == "") {
+		s.renderAdminBackup(w, user, "Error: Backups to S3 need a bucket, an access key and a secret key")
+		return
+	}
+
+	pathStyle := "false"
+	if r.FormValue("backup_s3_path_style") == "on" {
+		pathStyle = "true"
+	}
+	settings := map[string]string{
+		"backup_interval_hours":  strconv.Itoa(intervalHours),
+		"backup_retention_count": strconv.Itoa(retentionCount),
+		"backup_target":          target,
+		"backup_directory":       directory,
+		"backup_s3_endpoint":     strings.TrimSpace(r.FormValue("backup_s3_endpoint")),
+		"backup_s3_bucket":       bucket,
+		"backup_s3_region":       strings.TrimSpace(r.FormValue("backup_s3_region")),
+		"backup_s3_access_key":   accessKey,
+		"backup_s3_secret_key":   secretKey,
+		"backup_s3_prefix":       strings.Trim(strings.TrimSpace(r.FormValue("backup_s3_prefix")), "/"),
+		"backup_s3_path_style":   pathStyle,
+	}
+	for key, value := range settings {
+		if err := database.DB.SetConfigValue(key, value); err != nil {
+			s.renderAdminBackup(w, user, "Error: Failed to save backup schedule")
+			return
+		}
+	}
+
+	NewAuditLogger(s).LogSettingsUpdated(user, map[string]interface{}{
+		"backup_interval_hours":  intervalHours,
+		"backup_retention_count": retentionCount,
+		"backup_target":          cleanup.BackupTargetName(database.DB.GetBackupSchedule()),
+	}, r)
+
+	if intervalHours == 0 {
+		s.renderAdminBackup(w, user, "Backup schedule saved. Scheduled backups are off.")
+		return
+	}
+	s.renderAdminBackup(w, user, fmt.Sprintf("Backup schedule saved. A backup is made every %d hours and the last %d are kept.", intervalHours, retentionCount))
+}
+
+// handleAdminBackupRun writes a backup to the backup target now, in the background
+func (s *Server) handleAdminBackupRun(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/backup#schedule", http.StatusSeeOther)
+		return
+	}
+	if !user.IsSuperAdmin() || user.ImpersonatedBy != nil {
+		http.Error(w, "Only the super admin can run backups", http.StatusForbidden)
+		return
+	}
+	if cleanup.IsBackupRunning() {
+		s.renderAdminBackup(w, user, "Error: A backup is already running")
+		return
+	}
+
+	ipAddress := clientIP(r)
+	userAgent := r.UserAgent()
+	go func() {
+		run, err := cleanup.RunBackup(true, s.getPublicURL(), s.config.CompanyName)
+		if err == cleanup.ErrBackupRunning {
+			return
+		}
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     database.ActionDatabaseBackup,
+			EntityType: database.EntitySystem,
+			EntityID:   cleanup.JobBackup,
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"name":   run.Name,
+				"target": run.Target,
+				"size":   run.SizeBytes,
+				"manual": true,
+			}),
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+			Success:   err == nil,
+			ErrorMsg:  run.Error,
+		})
+	}()
+
+	s.renderAdminBackup(w, user, "Backup started in the background. Reload this page to see the result. Admins are notified if it fails.")
+}
+
+// backupScheduleHTML renders the scheduled backups card of the backup page
+func (s *Server) backupScheduleHTML(disabledAttr string) string {
+	schedule := database.DB.GetBackupSchedule()
+
+	nextRun := "Off"
+	if schedule.IntervalHours > 0 {
+		nextRun = formatRetentionTime(cleanup.JobNextRun(cleanup.JobBackup), "Not scheduled")
+	}
+	status := `<div class="schedule-status">
+                <strong>Target:</strong> ` + template.HTMLEscapeString(cleanup.BackupTargetName(schedule)) + `<br>
+                <strong>Last run:</strong> ` + formatRetentionTime(cleanup.JobLastRun(cleanup.JobBackup), "Never") + `<br>
+                <strong>Next run:</strong> ` + nextRun
+	if cleanup.IsBackupRunning() {
+		status += `<br><strong>⏳ A backup is running</strong>`
+	}
+	status += `
+            </div>`
+
+	selected := func(target string) string {
+		if schedule.Target == target {
+			return " selected"
+		}
+		return ""
+	}
+	pathStyleChecked := ""
+	if schedule.S3PathStyle {
+		pathStyleChecked = " checked"
+	}
+	secretPlaceholder := ""
+	if schedule.S3SecretKey != "" {
+		secretPlaceholder = "Saved - leave empty to keep it"
+	}
+
+	runsHTML := `<p class="help-text" style="margin-top: 20px;">No backups have been made to the backup target yet.</p>`
+	if runs, err := database.DB.GetBackupRuns(20); err == nil && len(runs) > 0 {
+		runsHTML = `
+            <table>
+                <tr><th>Backup</th><th>Made</th><th>Size</th><th>Result</th></tr>`
+		for _, run := range runs {
+			kind := "Scheduled"
+			if run.Manual {
+				kind = "Manual"
+			}
+			result := "✅ Stored in " + template.HTMLEscapeString(run.Target)
+			size := database.FormatFileSize(run.SizeBytes)
+			if !run.Success {
+				result = `<span class="failed">❌ ` + template.HTMLEscapeString(run.Error) + `</span>`
+				size = "—"
+			}
+			runsHTML += `
+                <tr>
+                    <td>` + template.HTMLEscapeString(run.Name) + `<div class="help-text">` + kind + `</div></td>
+                    <td>` + formatRetentionTime(time.Unix(run.CreatedAt, 0), "") + `</td>
+                    <td>` + size + `</td>
+                    <td>` + result + `</td>
+                </tr>`
+		}
+		runsHTML += `
+            </table>`
+	}
+
+	return `
+        <div class="card" id="schedule">
+            <h2>🕒 Scheduled Backups</h2>
+            ` + status + `
+            <form method="POST" action="/admin/backup/schedule">
+                <div class="form-group">
+                    <label for="backup_interval_hours">Backup Every (Hours)</label>
+                    <input type="number" id="backup_interval_hours" name="backup_interval_hours" value="` + strconv.Itoa(schedule.IntervalHours) + `" min="0" max="` + strconv.Itoa(maxBackupIntervalHours) + `" required` + disabledAttr + `>
+                    <p class="help-text">0 turns scheduled backups off (default). Each backup is a snapshot of the database; uploaded files are not included.</p>
+                </div>
+                <div class="form-group">
+                    <label for="backup_retention_count">Backups to Keep</label>
+                    <input type="number" id="backup_retention_count" name="backup_retention_count" value="` + strconv.Itoa(schedule.RetentionCount) + `" min="1" max="` + strconv.Itoa(database.MaxBackupRetentionCount) + `" required` + disabledAttr + `>
+                    <p class="help-text">Older backups are deleted from the target after each successful backup (default: ` + strconv.Itoa(database.DefaultBackupRetentionCount) + `)</p>
+                </div>
+                <div class="form-group">
+                    <label for="backup_target">Backup Target</label>
+                    <select id="backup_target" name="backup_target" onchange="toggleBackupTarget()"` + disabledAttr + `>
+                        <option value="local"` + selected(database.BackupTargetLocal) + `>Directory on this server</option>
+                        <option value="s3"` + selected(database.BackupTargetS3) + `>S3-compatible bucket</option>
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="backup_directory">Backup Directory</label>
+                    <input type="text" id="backup_directory" name="backup_directory" value="` + template.HTMLEscapeString(schedule.Directory) + `" placeholder="` + template.HTMLEscapeString(filepath.Join(filepath.Dir(database.DB.Path()), "backups")) + `"` + disabledAttr + `>
+                    <p class="help-text">Absolute path used when the target is a directory. Empty uses the backups directory in the data directory - preferably point it at another disk.</p>
+                </div>
+                <div id="s3Settings">
+                    <div class="form-group">
+                        <label for="backup_s3_endpoint">S3 Endpoint</label>
+                        <input type="text" id="backup_s3_endpoint" name="backup_s3_endpoint" value="` + template.HTMLEscapeString(schedule.S3Endpoint) + `" placeholder="https://s3.eu-north-1.amazonaws.com"` + disabledAttr + `>
+                        <p class="help-text">Empty uses AWS S3 in the region below</p>
+                    </div>
+                    <div class="form-group">
+                        <label for="backup_s3_bucket">Bucket</label>
+                        <input type="text" id="backup_s3_bucket" name="backup_s3_bucket" value="` + template.HTMLEscapeString(schedule.S3Bucket) + `"` + disabledAttr + `>
+                    </div>
+                    <div class="form-group">
+                        <label for="backup_s3_region">Region</label>
+                        <input type="text" id="backup_s3_region" name="backup_s3_region" value="` + template.HTMLEscapeString(schedule.S3Region) + `" placeholder="us-east-1"` + disabledAttr + `>
+                    </div>
+                    <div class="form-group">
+                        <label for="backup_s3_access_key">Access Key</label>
+                        <input type="text" id="backup_s3_access_key" name="backup_s3_access_key" value="` + template.HTMLEscapeString(schedule.S3AccessKey) + `" autocomplete="off"` + disabledAttr + `>
+                    </div>
+                    <div class="form-group">
+                        <label for="backup_s3_secret_key">Secret Key</label>
+                        <input type="password" id="backup_s3_secret_key" name="backup_s3_secret_key" placeholder="` + secretPlaceholder + `" autocomplete="new-password"` + disabledAttr + `>
+                        <p class="help-text">Stored encrypted</p>
+                    </div>
+                    <div class="form-group">
+                        <label for="backup_s3_prefix">Key Prefix</label>
+                        <input type="text" id="backup_s3_prefix" name="backup_s3_prefix" value="` + template.HTMLEscapeString(schedule.S3Prefix) + `" placeholder="wulfvault-backups"` + disabledAttr + `>
+                    </div>
+                    <div class="form-group">
+                        <label class="checkbox"><input type="checkbox" name="backup_s3_path_style"` + pathStyleChecked + disabledAttr + `> Path-style URLs (usually needed for MinIO)</label>
+                    </div>
+                </div>
+                <button type="submit" class="btn btn-primary"` + disabledAttr + `>Save Schedule</button>
+            </form>
+            <form method="POST" action="/admin/backup/run" style="margin-top: 12px;">
+                <button type="submit" class="btn btn-primary"` + disabledAttr + `>Run Backup Now</button>
+            </form>
+            <p class="help-text" style="margin-top: 20px;">Each backup is recorded in the audit log, and admins get an email when one fails.</p>` + runsHTML + `
+        </div>`
+}
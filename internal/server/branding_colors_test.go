@@ -0,0 +1,69 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"#2563EB", "#2563eb"},
+		{"  #abc ", "#aabbcc"},
+		{"#11223344", "#11223344"},
+		{"RebeccaPurple", "rebeccapurple"},
+		{"red", "red"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeColor(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeColor(%q) = %q, %v; want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+// Branding colors are written into style blocks and attributes, so nothing but a plain color
+// may get through
+func TestNormalizeColorRejectsCSSInjection(t *testing.T) {
+	for _, value := range []string{
+		"red;}</style><script>alert(1)</script>",
+		"red;}",
+		"#fff;background:url(https://evil.example/x.png)",
+		"url(https://evil.example/x.png)",
+		"expression(alert(1))",
+		"#abc\" onmouseover=\"alert(1)",
+		"red</style>",
+		"#12345g",
+		"#1234",
+		"#1234567",
+		"#",
+		"rgb(1,2,3)",
+		"javascript:alert(1)",
+		"",
+	} {
+		if got, err := normalizeColor(value); err == nil {
+			t.Errorf("normalizeColor(%q) = %q, want an error", value, got)
+		}
+		if got := brandingColorOrDefault(value, "#2563eb"); got != "#2563eb" {
+			t.Errorf("brandingColorOrDefault(%q) = %q, want the fallback", value, got)
+		}
+	}
+}
+
+// The error names the lengths the check accepts
+func TestNormalizeColorErrorMatchesAcceptedLengths(t *testing.T) {
+	_, err := normalizeColor("#12345")
+	if err == nil || !strings.Contains(err.Error(), "3, 6 or 8 hex digits") {
+		t.Errorf("normalizeColor(#12345) error = %v, want it to name 3, 6 or 8 hex digits", err)
+	}
+	if _, err := normalizeColor("#1122334"); err == nil {
+		t.Error("normalizeColor accepted 7 hex digits")
+	}
+}
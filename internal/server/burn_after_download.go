@@ -0,0 +1,125 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/i18n"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// isBurnedLink reports whether a file's link was used up by burn after download: its one
+// download is running or done
+func isBurnedLink(fileID string) bool {
+	_, err := database.DB.GetBurnedFile(fileID)
+	return err == nil
+}
+
+// renderBurnedLink renders the page shown for the link of a burn-after-download file that
+// was already used
+func (s *Server) renderBurnedLink(w http.ResponseWriter, r *http.Request) {
+	locale := recipientLocale(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	s.renderLocalizedSplashPageUnavailable(w, locale, "🔥", i18n.T(locale.Code, "burned.title"), i18n.T(locale.Code, "burned.message"), "", languageSwitcherHTML(locale))
+}
+
+// claimBurnDownload reserves the one download of a burn-after-download file before it is
+// counted. A request that loses the race is shown that the link was used. Returns false if
+// the request was answered.
+func (s *Server) claimBurnDownload(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo) bool {
+	claimed, err := database.DB.ClaimBurnDownload(fileInfo.Id, fileInfo.Name)
+	if err != nil {
+		log.Printf("Error: Could not claim burn-after-download file %s: %v", fileInfo.Id, err)
+		http.Error(w, "Download failed, please try again", http.StatusInternalServerError)
+		return false
+	}
+	if !claimed {
+		log.Printf("Refused download of %s: burn-after-download link already used", fileInfo.Id)
+		s.renderBurnedLink(w, r)
+		return false
+	}
+	return true
+}
+
+// burnAfterDownload finishes the claimed download of a burn-after-download file. A complete
+// download moves the file to trash or deletes it, depending on burn_after_download_mode; a
+// download that broke off gives up the claim, so the recipient can try again.
+func (s *Server) burnAfterDownload(r *http.Request, fileInfo *database.FileInfo, convertedPath string, bytesSent int64, userID int64, userEmail string) {
+	if !downloadWasComplete(r, fileInfo, convertedPath, bytesSent) {
+		log.Printf("Burn-after-download file %s was not downloaded completely (%d bytes sent), it can be downloaded again", fileInfo.Id, bytesSent)
+		if err := database.DB.ReleaseBurnDownload(fileInfo.Id); err != nil {
+			log.Printf("Error: Could not release burn-after-download file %s: %v", fileInfo.Id, err)
+		}
+		return
+	}
+
+	if err := database.DB.MarkFileBurned(fileInfo.Id); err != nil {
+		log.Printf("Error: Could not mark %s as burned: %v", fileInfo.Id, err)
+	}
+
+	mode := database.DB.GetBurnAfterDownloadMode()
+	var err error
+	if mode == database.BurnModeDelete {
+		if err = storage.Files.Delete(fileInfo.Id); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Could not delete burned file %s from storage: %v", fileInfo.Id, err)
+		}
+		s.removeConvertedImages(fileInfo.Id)
+		err = database.DB.PermanentDeleteFile(fileInfo.Id)
+	} else {
+		err = database.DB.DeleteFile(fileInfo.Id, 0)
+	}
+	errorMessage := ""
+	if err != nil {
+		log.Printf("Error: Could not remove burned file %s: %v", fileInfo.Id, err)
+		errorMessage = err.Error()
+	} else {
+		log.Printf("Burn-after-download file %s (%s) was downloaded and removed (%s)", fileInfo.Name, fileInfo.Id, mode)
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     userID,
+		UserEmail:  userEmail,
+		Action:     database.ActionFileBurned,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name": fileInfo.Name,
+			"mode":      mode,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   err == nil,
+		ErrorMsg:  errorMessage,
+	})
+}
+
+// downloadWasComplete reports whether a download sent the whole file (or its converted copy)
+// and was not cancelled by the client
+func downloadWasComplete(r *http.Request, fileInfo *database.FileInfo, convertedPath string, bytesSent int64) bool {
+	if r.Context().Err() != nil {
+		return false
+	}
+	var size int64
+	if convertedPath != "" {
+		info, err := os.Stat(convertedPath)
+		if err != nil {
+			return false
+		}
+		size = info.Size()
+	} else {
+		info, err := storage.Files.Stat(fileInfo.Id)
+		if err != nil {
+			return false
+		}
+		size = info.Size
+	}
+	return bytesSent >= size
+}
@@ -0,0 +1,154 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// isChecksumDisplayEnabled reports whether the splash page shows the SHA-256 helper (default on)
+func isChecksumDisplayEnabled() bool {
+	value, _ := database.DB.GetConfigValue("show_file_checksum")
+	return value != "false"
+}
+
+// uploadHasher calculates the SHA1 and SHA-256 of an upload while it is stored, so large
+// uploads aren't read a second time to hash them
+type uploadHasher struct {
+	sha1   hash.Hash
+	sha256 hash.Hash
+}
+
+func newUploadHasher() *uploadHasher {
+	return &uploadHasher{sha1: sha1.New(), sha256: sha256.New()}
+}
+
+// copy writes src to dst and adds the data to the hashes
+func (h *uploadHasher) copy(dst io.Writer, src io.Reader) (int64, error) {
+	return io.Copy(dst, h.tee(src))
+}
+
+// tee returns a reader that adds everything read from src to the hashes
+func (h *uploadHasher) tee(src io.Reader) io.Reader {
+	return io.TeeReader(src, io.MultiWriter(h.sha1, h.sha256))
+}
+
+// hashStoredFile reads a stored file and returns its hashes
+func hashStoredFile(fileID string) (*uploadHasher, error) {
+	f, err := storage.Files.Get(fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hasher := newUploadHasher()
+	if _, err := hasher.copy(io.Discard, f); err != nil {
+		return nil, err
+	}
+	return hasher, nil
+}
+
+// SHA1 returns the hex SHA1 of the data copied so far
+func (h *uploadHasher) SHA1() string {
+	return hex.EncodeToString(h.sha1.Sum(nil))
+}
+
+// SHA256 returns the hex SHA-256 of the data copied so far
+func (h *uploadHasher) SHA256() string {
+	return hex.EncodeToString(h.sha256.Sum(nil))
+}
+
+// queueFileSHA256 calculates and stores a file's SHA-256 in the background.
+// Large files take a while to hash, so uploads and splash pages never wait for it.
+func (s *Server) queueFileSHA256(fileID string) {
+	fileProcessing.Submit("sha256:"+fileID, func() error {
+		hasher, err := hashStoredFile(fileID)
+		if err != nil {
+			return err
+		}
+		return database.DB.SetFileSHA256(fileID, hasher.SHA256())
+	})
+}
+
+// getFileSHA256 returns the stored SHA-256 of a file. Files uploaded before checksums
+// were stored get one calculated in the background and return "" until it's ready.
+func (s *Server) getFileSHA256(fileID string) string {
+	hash := database.DB.GetFileSHA256(fileID)
+	if hash == "" {
+		s.queueFileSHA256(fileID)
+	}
+	return hash
+}
+
+// setDigestHeaders advertises the file's SHA-256 on download responses, in the standard digest
+// headers and as plain hex in X-File-SHA256 for scripts
+func setDigestHeaders(w http.ResponseWriter, hexHash string) {
+	raw, err := hex.DecodeString(hexHash)
+	if err != nil {
+		return
+	}
+	w.Header().Set("X-File-SHA256", hexHash)
+	encoded := base64.StdEncoding.EncodeToString(raw)
+	w.Header().Set("Digest", "sha-256="+encoded)
+	w.Header().Set("Repr-Digest", "sha-256=:"+encoded+":")
+}
+
+// renderChecksumSection renders the splash page checksum box with copyable verify commands
+func renderChecksumSection(fileName, hash, primaryColor string) string {
+	// Quote the file name for each shell
+	bashName := "'" + strings.ReplaceAll(fileName, "'", `'\''`) + "'"
+	psName := "'" + strings.ReplaceAll(fileName, "'", "''") + "'"
+
+	bashCommand := "echo '" + hash + "  '" + bashName + " | sha256sum -c -"
+	macCommand := "shasum -a 256 " + bashName
+	psCommand := "(Get-FileHash -Algorithm SHA256 " + psName + ").Hash -eq '" + strings.ToUpper(hash) + "'"
+
+	commandBox := func(id, label, command string) string {
+		return `
+                <p style="margin: 12px 0 6px; color: #555; font-size: 13px; font-weight: 600;">` + label + `</p>
+                <div style="display: flex; gap: 8px; align-items: stretch;">
+                    <code id="` + id + `" style="flex: 1; background: #1e293b; color: #e2e8f0; padding: 10px; border-radius: 6px; font-size: 12px; word-break: break-all; text-align: left;">` + template.HTMLEscapeString(command) + `</code>
+                    <button type="button" onclick="copyChecksum('` + id + `', this)" style="padding: 6px 12px; border: none; border-radius: 6px; background: ` + primaryColor + `; color: white; cursor: pointer; font-size: 12px;">Copy</button>
+                </div>`
+	}
+
+	return `
+        <div style="margin: 25px 0; padding: 20px; background: #f9f9f9; border-radius: 8px; text-align: left;">
+            <h3 style="color: ` + primaryColor + `; font-size: 15px; margin-bottom: 10px;">🛡️ SHA-256 Checksum</h3>
+            <div style="display: flex; gap: 8px; align-items: stretch;">
+                <code id="checksumValue" style="flex: 1; font-size: 12px; word-break: break-all; background: white; border: 1px solid #e0e0e0; padding: 10px; border-radius: 6px;">` + hash + `</code>
+                <button type="button" onclick="copyChecksum('checksumValue', this)" style="padding: 6px 12px; border: none; border-radius: 6px; background: ` + primaryColor + `; color: white; cursor: pointer; font-size: 12px;">Copy</button>
+            </div>
+            <details style="margin-top: 12px;">
+                <summary style="cursor: pointer; color: #555; font-size: 14px;">How do I verify my download?</summary>
+                <p style="margin-top: 10px; color: #666; font-size: 13px; line-height: 1.6;">After downloading, open a terminal in the folder where the file was saved and run the command for your system. It confirms the file arrived complete and unmodified.</p>` +
+		commandBox("verifyLinux", "Linux (prints \"OK\" if the file matches)", bashCommand) +
+		commandBox("verifyMac", "macOS (compare the output with the checksum above)", macCommand) +
+		commandBox("verifyWindows", "Windows PowerShell (prints \"True\" if the file matches)", psCommand) + `
+            </details>
+        </div>
+        <script>
+            function copyChecksum(id, btn) {
+                const text = document.getElementById(id).textContent;
+                navigator.clipboard.writeText(text).then(function() {
+                    const original = btn.textContent;
+                    btn.textContent = 'Copied!';
+                    setTimeout(function() { btn.textContent = original; }, 1500);
+                });
+            }
+        </script>`
+}
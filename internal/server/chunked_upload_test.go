@@ -0,0 +1,203 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// uploadRequest builds a request of user to one of the chunked upload endpoints
+func uploadRequest(user *models.User, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, body)
+	return r.WithContext(contextWithUser(r.Context(), user))
+}
+
+// startChunkedUpload starts an upload of totalSize bytes and returns its id
+func startChunkedUpload(t *testing.T, s *Server, user *models.User, totalSize int64) string {
+	t.Helper()
+	body := `{"filename":"report.txt","total_size":` + strconv.FormatInt(totalSize, 10) + `,"metadata":{"unlimited_time":"true"}}`
+	w := serve(s.handleChunkedUploadInit, uploadRequest(user, "/api/upload/init", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("init: status %d, body %q", w.Code, w.Body.String())
+	}
+	var resp struct {
+		UploadID string `json:"upload_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.UploadID == "" {
+		t.Fatalf("init: response %q: %v", w.Body.String(), err)
+	}
+	return resp.UploadID
+}
+
+// sendChunk sends chunk index of total chunks of an upload
+func sendChunk(s *Server, user *models.User, uploadID string, index, total int, data []byte) *httptest.ResponseRecorder {
+	r := uploadRequest(user, "/api/upload/chunk?upload_id="+uploadID, bytes.NewReader(data))
+	r.Header.Set("X-Chunk-Index", strconv.Itoa(index))
+	r.Header.Set("X-Chunk-Total", strconv.Itoa(total))
+	return serve(s.handleChunkedUploadChunk, r)
+}
+
+// storedUploadBytes returns how many bytes of file data and upload chunks are on disk
+func storedUploadBytes(t *testing.T, s *Server) int64 {
+	t.Helper()
+	var total int64
+	err := filepath.WalkDir(s.config.UploadsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking uploads directory: %v", err)
+	}
+	return total
+}
+
+// assertUploadDiscarded checks that an upload left nothing behind: no session, no data on
+// disk, no file and no storage usage
+func assertUploadDiscarded(t *testing.T, s *Server, user *models.User, uploadID string) {
+	t.Helper()
+	activeUploadsMu.RLock()
+	_, active := activeUploads[uploadID]
+	activeUploadsMu.RUnlock()
+	if active {
+		t.Error("upload session is still active")
+	}
+	if stored := storedUploadBytes(t, s); stored != 0 {
+		t.Errorf("%d bytes left in the uploads directory", stored)
+	}
+	if _, err := database.DB.GetFileByID(uploadID); err == nil {
+		t.Error("a file was saved for the upload")
+	}
+	fresh, err := database.DB.GetUserByID(user.Id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if fresh.StorageUsedMB != 0 {
+		t.Errorf("StorageUsedMB = %d, want 0", fresh.StorageUsedMB)
+	}
+}
+
+// The completed upload shows what the discarded ones must not leave behind
+func TestChunkedUploadCompleteStoresFile(t *testing.T) {
+	s := newTestServer(t)
+	user := createTestUser(t, "uploader@example.com", models.UserLevelUser, 1000)
+
+	uploadID := startChunkedUpload(t, s, user, 1024*1024)
+	for i := 0; i < 2; i++ {
+		if w := sendChunk(s, user, uploadID, i, 2, bytes.Repeat([]byte("x"), 512*1024)); w.Code != http.StatusOK {
+			t.Fatalf("chunk %d: status %d, body %q", i, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(s.handleChunkedUploadComplete, uploadRequest(user, "/api/upload/complete?upload_id="+uploadID, nil)); w.Code != http.StatusOK {
+		t.Fatalf("complete: status %d, body %q", w.Code, w.Body.String())
+	}
+
+	if stored := storedUploadBytes(t, s); stored != 1024*1024 {
+		t.Errorf("%d bytes in the uploads directory, want the 1 MB of the file", stored)
+	}
+	fresh, err := database.DB.GetUserByID(user.Id)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if fresh.StorageUsedMB != 1 {
+		t.Errorf("StorageUsedMB = %d after the upload, want 1", fresh.StorageUsedMB)
+	}
+}
+
+func TestChunkedUploadCancelLeavesNothing(t *testing.T) {
+	s := newTestServer(t)
+	user := createTestUser(t, "uploader@example.com", models.UserLevelUser, 1000)
+
+	uploadID := startChunkedUpload(t, s, user, 3000)
+	for i := 0; i < 2; i++ {
+		if w := sendChunk(s, user, uploadID, i, 3, bytes.Repeat([]byte("x"), 1000)); w.Code != http.StatusOK {
+			t.Fatalf("chunk %d: status %d, body %q", i, w.Code, w.Body.String())
+		}
+	}
+	if stored := storedUploadBytes(t, s); stored != 2000 {
+		t.Fatalf("%d bytes received before cancelling, want 2000", stored)
+	}
+
+	w := serve(s.handleChunkedUploadCancel, uploadRequest(user, "/api/upload/cancel?upload_id="+uploadID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel: status %d, body %q", w.Code, w.Body.String())
+	}
+	assertUploadDiscarded(t, s, user, uploadID)
+
+	// Neither the last chunk nor completing it brings the upload back
+	if w := sendChunk(s, user, uploadID, 2, 3, bytes.Repeat([]byte("x"), 1000)); w.Code != http.StatusNotFound {
+		t.Errorf("chunk after cancel: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := serve(s.handleChunkedUploadComplete, uploadRequest(user, "/api/upload/complete?upload_id="+uploadID, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("complete after cancel: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	assertUploadDiscarded(t, s, user, uploadID)
+}
+
+// Only the uploader may cancel an upload
+func TestChunkedUploadCancelByOtherUserRefused(t *testing.T) {
+	s := newTestServer(t)
+	user := createTestUser(t, "uploader@example.com", models.UserLevelUser, 1000)
+	other := createTestUser(t, "other@example.com", models.UserLevelUser, 1000)
+
+	uploadID := startChunkedUpload(t, s, user, 1000)
+	w := serve(s.handleChunkedUploadCancel, uploadRequest(other, "/api/upload/cancel?upload_id="+uploadID, nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("cancel by other user: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := sendChunk(s, user, uploadID, 0, 1, bytes.Repeat([]byte("x"), 1000)); w.Code != http.StatusOK {
+		t.Errorf("chunk after refused cancel: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// Completing an upload with chunks missing rolls it back
+func TestChunkedUploadIncompleteLeavesNothing(t *testing.T) {
+	s := newTestServer(t)
+	user := createTestUser(t, "uploader@example.com", models.UserLevelUser, 1000)
+
+	uploadID := startChunkedUpload(t, s, user, 3000)
+	if w := sendChunk(s, user, uploadID, 0, 3, bytes.Repeat([]byte("x"), 1000)); w.Code != http.StatusOK {
+		t.Fatalf("chunk 0: status %d, body %q", w.Code, w.Body.String())
+	}
+	w := serve(s.handleChunkedUploadComplete, uploadRequest(user, "/api/upload/complete?upload_id="+uploadID, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("complete without all chunks: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	assertUploadDiscarded(t, s, user, uploadID)
+}
+
+// An upload that sends more than it announced is aborted
+func TestChunkedUploadAbortedOnOversizeLeavesNothing(t *testing.T) {
+	s := newTestServer(t)
+	user := createTestUser(t, "uploader@example.com", models.UserLevelUser, 1000)
+
+	uploadID := startChunkedUpload(t, s, user, 1500)
+	if w := sendChunk(s, user, uploadID, 0, 2, bytes.Repeat([]byte("x"), 1000)); w.Code != http.StatusOK {
+		t.Fatalf("chunk 0: status %d, body %q", w.Code, w.Body.String())
+	}
+	if w := sendChunk(s, user, uploadID, 1, 2, bytes.Repeat([]byte("x"), 1000)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized chunk: status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	assertUploadDiscarded(t, s, user, uploadID)
+}
@@ -0,0 +1,55 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"io"
+	"net/http"
+)
+
+// countingResponseWriter wraps a ResponseWriter and counts the body bytes
+// actually written, so ranged, partial and aborted downloads are measured
+// by what was sent rather than by file size
+type countingResponseWriter struct {
+	http.ResponseWriter
+	bytesWritten int64
+}
+
+func newCountingResponseWriter(w http.ResponseWriter) *countingResponseWriter {
+	return &countingResponseWriter{ResponseWriter: w}
+}
+
+func (c *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.bytesWritten += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the sendfile fast path of the underlying writer available to http.ServeFile
+func (c *countingResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if rf, ok := c.ResponseWriter.(io.ReaderFrom); ok {
+		n, err := rf.ReadFrom(src)
+		c.bytesWritten += n
+		return n, err
+	}
+	return io.Copy(writerOnly{c}, src)
+}
+
+func (c *countingResponseWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// BytesWritten returns the number of body bytes sent so far
+func (c *countingResponseWriter) BytesWritten() int64 {
+	return c.bytesWritten
+}
+
+// writerOnly hides ReadFrom so io.Copy falls back to Write and does not recurse
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,159 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// Undo window after deleting a file, used when delete_undo_seconds is not configured
+const (
+	DefaultDeleteUndoSeconds = 30
+	MaxDeleteUndoSeconds     = 600
+)
+
+// deleteUndo lets the user who deleted a file take it back out of trash for a short while,
+// without going through the trash
+type deleteUndo struct {
+	FileID    string
+	UserID    int
+	ExpiresAt time.Time
+}
+
+var (
+	deleteUndos   = make(map[string]*deleteUndo)
+	deleteUndosMu sync.Mutex
+)
+
+// getDeleteUndoWindow returns how long a delete can be undone, or 0 if undo is turned off
+func getDeleteUndoWindow() time.Duration {
+	seconds := database.DB.GetConfigInt("delete_undo_seconds", DefaultDeleteUndoSeconds)
+	if seconds <= 0 {
+		return 0
+	}
+	if seconds > MaxDeleteUndoSeconds {
+		seconds = MaxDeleteUndoSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// issueDeleteUndoToken creates a single-use token that undoes deleting a file
+func issueDeleteUndoToken(fileID string, userID int, window time.Duration) string {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.Printf("Warning: Failed to generate undo token: %v", err)
+		return ""
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := time.Now()
+	deleteUndosMu.Lock()
+	defer deleteUndosMu.Unlock()
+
+	// Tokens live for seconds, so expired ones are pruned here instead of by a scheduler
+	for t, undo := range deleteUndos {
+		if now.After(undo.ExpiresAt) {
+			delete(deleteUndos, t)
+		}
+	}
+
+	deleteUndos[token] = &deleteUndo{
+		FileID:    fileID,
+		UserID:    userID,
+		ExpiresAt: now.Add(window),
+	}
+	return token
+}
+
+// claimDeleteUndoToken returns the delete a token undoes and uses the token up, or nil if it is
+// unknown, expired or issued to another user
+func claimDeleteUndoToken(token string, userID int) *deleteUndo {
+	deleteUndosMu.Lock()
+	defer deleteUndosMu.Unlock()
+
+	undo, ok := deleteUndos[token]
+	if !ok || undo.UserID != userID {
+		return nil
+	}
+	delete(deleteUndos, token)
+	if time.Now().After(undo.ExpiresAt) {
+		return nil
+	}
+	return undo
+}
+
+// handleFileUndelete takes a file the user just deleted back out of trash, using the undo
+// token returned by handleFileDelete. Its size counts toward the owner's storage again.
+func (s *Server) handleFileUndelete(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	token := r.FormValue("undo_token")
+	if token == "" {
+		s.sendError(w, http.StatusBadRequest, "Missing undo_token")
+		return
+	}
+
+	undo := claimDeleteUndoToken(token, user.Id)
+	if undo == nil {
+		s.sendError(w, http.StatusGone, "This delete can no longer be undone. An admin can still restore the file from trash")
+		return
+	}
+
+	// The file may have been restored or purged from trash meanwhile
+	restored, err := database.DB.UndoDeleteFile(undo.FileID, user.Id)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to restore file")
+		return
+	}
+	if !restored {
+		s.sendError(w, http.StatusConflict, "The file is no longer in trash")
+		return
+	}
+	fileInfo, err := database.DB.GetFileByID(undo.FileID)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to load restored file")
+		return
+	}
+
+	log.Printf("File delete undone: %s by user %d", fileInfo.Name, user.Id)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionFileRestored,
+		EntityType: database.EntityFile,
+		EntityID:   undo.FileID,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name": fileInfo.Name,
+			"size":      fileInfo.SizeBytes,
+			"undo":      true,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]string{
+		"message": "File restored",
+		"file_id": undo.FileID,
+	})
+}
@@ -0,0 +1,150 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/i18n"
+)
+
+// downloadLockTimeLayout is how the end of a download lock is shown
+const downloadLockTimeLayout = "2006-01-02 15:04 MST"
+
+// downloadCountry returns the downloader's two-letter country code from the header the reverse
+// proxy sets, or "" if there is none. Unknown and Tor codes (XX, T1) are ignored.
+func downloadCountry(r *http.Request) string {
+	country := strings.ToUpper(strings.TrimSpace(r.Header.Get(database.DB.GetDownloadCountryHeader())))
+	if len(country) != 2 || country == "XX" || country == "T1" {
+		return ""
+	}
+	return country
+}
+
+// checkDownloadAnomalies locks further downloads of a file when its download log shows unusual
+// activity, and tells the owner. Called after each download is logged.
+func (s *Server) checkDownloadAnomalies(fileInfo *database.FileInfo) {
+	rules := database.DB.GetDownloadAnomalyRules()
+	if !rules.Enabled() {
+		return
+	}
+
+	reason := database.DB.DetectDownloadAnomaly(fileInfo.Id, rules)
+	if reason == "" {
+		return
+	}
+
+	until := time.Now().Add(time.Duration(rules.BlockMinutes) * time.Minute)
+	blocked, err := database.DB.BlockFileDownloads(fileInfo.Id, until, reason)
+	if err != nil {
+		log.Printf("Error: Could not lock downloads of %s: %v", fileInfo.Id, err)
+		return
+	}
+	if !blocked {
+		return
+	}
+
+	log.Printf("Downloads of %s (%s) locked until %s: %s", fileInfo.Name, fileInfo.Id, until.Format(time.RFC3339), reason)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     0,
+		UserEmail:  "system",
+		Action:     database.ActionFileDownloadsBlocked,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":     fileInfo.Name,
+			"reason":        reason,
+			"blocked_until": until.Unix(),
+		}),
+		Success: true,
+	})
+
+	go func() {
+		owner, err := database.DB.GetUserByID(fileInfo.UserId)
+		if err != nil {
+			log.Printf("Could not get file owner for download lock notification: %v", err)
+			return
+		}
+		untilText := until.In(database.ServerLocation()).Format(downloadLockTimeLayout)
+		if !database.DB.NotifyUser(owner.Id, database.NotifyDownloadBlocks, "Downloads locked",
+			fmt.Sprintf("Downloads of %s are locked until %s: %s", fileInfo.Name, untilText, reason), "/dashboard") {
+			return
+		}
+		if err := email.SendDownloadsLockedEmail(owner.Email, fileInfo.Name, reason, untilText, s.getPublicURL(), s.config.CompanyName); err != nil {
+			log.Printf("Failed to send download lock notification to %s: %v", owner.Email, err)
+		}
+	}()
+}
+
+// renderDownloadsLocked renders the splash page shown while a file's downloads are locked
+func (s *Server) renderDownloadsLocked(w http.ResponseWriter, locale i18n.Locale, until time.Time) {
+	message := fmt.Sprintf(i18n.T(locale.Code, "locked.message"), until.In(database.ServerLocation()).Format(downloadLockTimeLayout))
+	s.renderLocalizedSplashPageUnavailable(w, locale, "🛡️", i18n.T(locale.Code, "locked.title"), message, "", languageSwitcherHTML(locale))
+}
+
+// handleFileUnblockDownloads lets the owner of a file (or an admin) confirm that recent
+// downloads were expected and lift the lock before it runs out (POST /file/unblock-downloads)
+func (s *Server) handleFileUnblockDownloads(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	fileInfo, err := database.DB.GetFileByID(r.FormValue("file_id"))
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if fileInfo.UserId != user.Id && !user.IsAdmin() {
+		s.sendError(w, http.StatusForbidden, "Not authorized to change this file")
+		return
+	}
+
+	until, reason := database.DB.GetFileDownloadBlock(fileInfo.Id)
+	if until.IsZero() {
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+		return
+	}
+
+	if err := database.DB.UnblockFileDownloads(fileInfo.Id); err != nil {
+		log.Printf("Error unlocking downloads of %s: %v", fileInfo.Id, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to unlock downloads")
+		return
+	}
+
+	log.Printf("Downloads of %s (%s) unlocked by %s", fileInfo.Name, fileInfo.Id, user.Email)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionFileDownloadsUnblocked,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":     fileInfo.Name,
+			"reason":        reason,
+			"blocked_until": until.Unix(),
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
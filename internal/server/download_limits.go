@@ -0,0 +1,113 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// handleFileResetDownloads lets the owner of a file (or an admin) set its remaining downloads to
+// a value (mode=set) or add downloads to them (mode=add). A file whose downloads ran out works
+// again, and is taken back out of trash if the expiry cleanup already moved it there
+// (POST /file/reset-downloads)
+func (s *Server) handleFileResetDownloads(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	fileID := r.FormValue("file_id")
+	topUp := r.FormValue("mode") == "add"
+	value, err := strconv.Atoi(r.FormValue("downloads"))
+	if err != nil || value < 1 || value > database.MaxDownloadLimitReset {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Downloads must be between 1 and %d", database.MaxDownloadLimitReset))
+		return
+	}
+
+	// Files whose downloads ran out may already have been moved to trash by the cleanup
+	restore := false
+	fileInfo, err := database.DB.GetFileByID(fileID)
+	if err != nil {
+		fileInfo, err = database.DB.GetExpiredTrashedFile(fileID)
+		if err != nil || fileInfo.ExpiredReason(time.Now()) != database.FileExpiredByDownloads {
+			s.sendError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		restore = true
+	}
+	if fileInfo.UserId != user.Id && !user.IsAdmin() {
+		s.sendError(w, http.StatusForbidden, "Not authorized to change this file")
+		return
+	}
+	if fileInfo.UnlimitedDownloads {
+		s.sendError(w, http.StatusBadRequest, "This file has no download limit")
+		return
+	}
+
+	oldRemaining := fileInfo.DownloadsRemaining
+	newRemaining, err := database.DB.ResetFileDownloadsRemaining(fileID, value, topUp)
+	if err != nil {
+		if errors.Is(err, database.ErrUnlimitedDownloads) {
+			s.sendError(w, http.StatusBadRequest, "This file has no download limit")
+			return
+		}
+		log.Printf("Error resetting downloads of %s: %v", fileID, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to reset downloads")
+		return
+	}
+
+	if restore {
+		if err := database.DB.RestoreFile(fileID); err != nil {
+			log.Printf("Error restoring %s after resetting its downloads: %v", fileID, err)
+			s.sendError(w, http.StatusInternalServerError, "Downloads were reset, but the file could not be restored from trash")
+			return
+		}
+	}
+
+	log.Printf("Downloads remaining of %s (%s) changed from %d to %d by %s", fileInfo.Name, fileID, oldRemaining, newRemaining, user.Email)
+
+	mode := "set"
+	if topUp {
+		mode = "add"
+	}
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionFileDownloadsReset,
+		EntityType: database.EntityFile,
+		EntityID:   fileID,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":     fileInfo.Name,
+			"mode":          mode,
+			"value":         value,
+			"old_remaining": oldRemaining,
+			"new_remaining": newRemaining,
+			"restored":      restore,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":             true,
+		"downloads_remaining": newRemaining,
+		"restored":            restore,
+	})
+}
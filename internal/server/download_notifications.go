@@ -0,0 +1,48 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"log"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// notifyOwnerOfDownload tells a file's owner about a download: in the app, and by email if
+// the file has download emails on and the owner wants them. Once a file is downloaded more
+// often than the configured limit, further downloads wait for the digest the cleanup
+// scheduler sends at the end of the interval.
+func (s *Server) notifyOwnerOfDownload(fileInfo *database.FileInfo, downloadLog *models.DownloadLog, client downloadClient) {
+	owner, err := database.DB.GetUserByID(fileInfo.UserId)
+	if err != nil {
+		log.Printf("Could not get file owner for download notification: %v", err)
+		return
+	}
+
+	if !database.DB.NotifyUser(owner.Id, database.NotifyDownloads, "File downloaded", fileInfo.Name+" was downloaded", "/dashboard") {
+		return
+	}
+	if !database.DB.IsFileNotifyOnDownload(fileInfo.Id) {
+		return
+	}
+
+	send, err := database.DB.ClaimDownloadNotification(fileInfo.Id, downloadLog.Id,
+		database.DB.GetDownloadNotifyLimit(), database.DB.GetDownloadDigestInterval())
+	if err != nil {
+		log.Printf("Could not throttle download notification of %s: %v", fileInfo.Id, err)
+	}
+	if !send {
+		return
+	}
+
+	download := *downloadLog
+	download.IpAddress = client.notificationIP()
+	if err := email.QueueDownloadNotification(fileInfo, &download, s.getPublicURL(), owner); err != nil {
+		log.Printf("Failed to queue download notification email: %v", err)
+	}
+}
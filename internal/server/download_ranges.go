@@ -0,0 +1,137 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// downloadRangeWindow is how long the browser of a counted download may fetch further byte
+// ranges of the file without them being counted, e.g. to resume a broken-off download or when
+// a download manager fetches the file in parallel parts
+const downloadRangeWindow = 24 * time.Hour
+
+// downloadRangeCookieName is the cookie that carries a file's range grant
+func downloadRangeCookieName(fileID string) string {
+	return "download_range_" + fileID
+}
+
+// downloadETag identifies the stored content of a file, so clients can resume a download with
+// If-Range and are sent the whole file again if it was replaced
+func downloadETag(fileInfo *database.FileInfo, convertedPath string) string {
+	checksum := fileInfo.SHA256
+	if checksum == "" {
+		checksum = fileInfo.SHA1
+	}
+	if len(checksum) > 16 {
+		checksum = checksum[:16]
+	}
+	if convertedPath != "" {
+		// The converted copy is different content than the original
+		return fmt.Sprintf(`"%s-%s-converted"`, fileInfo.Id, checksum)
+	}
+	return fmt.Sprintf(`"%s-%s"`, fileInfo.Id, checksum)
+}
+
+// isRangeContinuation reports whether a request only fetches part of a file after its start,
+// i.e. it continues a download rather than starting one. Requests whose first range starts at
+// byte 0 count as a new download, and so do resumes whose If-Range doesn't match the file, as
+// they are sent the whole file.
+func isRangeContinuation(r *http.Request, etag string) bool {
+	rangeHeader := r.Header.Get("Range")
+	if !strings.HasPrefix(rangeHeader, "bytes=") {
+		return false
+	}
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && strings.HasPrefix(ifRange, `"`) && ifRange != etag {
+		return false
+	}
+
+	first := strings.TrimSpace(strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), ",")[0])
+	start, _, _ := strings.Cut(first, "-")
+	return strings.TrimLeft(strings.TrimSpace(start), "0") != "" || strings.HasPrefix(first, "-")
+}
+
+// hasDownloadRangeGrant reports whether a request continues a download its browser already
+// made, so it may still reach a file whose download limit was reached. Who the downloader is
+// gets checked when the range is served, see downloadRangeGrant.
+func hasDownloadRangeGrant(r *http.Request, fileInfo *database.FileInfo) bool {
+	return r.Header.Get("Range") != "" && lookupDownloadGrant(r, downloadRangeCookieName(fileInfo.Id), fileInfo.Id) != nil
+}
+
+// downloadRangeGrant returns the grant of the counted download a range request continues, or
+// nil if the request must be handled as a new download. Burn-after-download files have no
+// range grants, their one download must send the whole file.
+func (s *Server) downloadRangeGrant(r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount, etag string) *downloadGrant {
+	if fileInfo.BurnAfterDownload || !isRangeContinuation(r, etag) {
+		return nil
+	}
+	grant := lookupDownloadGrant(r, downloadRangeCookieName(fileInfo.Id), fileInfo.Id)
+	if grant == nil || grant.Consumer != s.downloadConsumer(r, account) {
+		return nil
+	}
+	return grant
+}
+
+// grantDownloadRanges gives the browser of a counted download a grant to fetch further byte
+// ranges of it for the range window, adding their bytes to the download's log
+func (s *Server) grantDownloadRanges(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount, downloadLogID int) {
+	if fileInfo.BurnAfterDownload {
+		return
+	}
+	issueDownloadGrant(w, downloadRangeCookieName(fileInfo.Id), &downloadGrant{
+		FileID:    fileInfo.Id,
+		Consumer:  s.downloadConsumer(r, account),
+		LogID:     downloadLogID,
+		ExpiresAt: time.Now().Add(downloadRangeWindow),
+	})
+}
+
+// serveDownloadContent sends a file, or its converted copy if convertedPath is set, or the byte
+// ranges the request asks for, and returns the number of bytes that reached the client. Range,
+// If-Range and conditional requests are handled by http.ServeContent using the upload date and
+// the file's ETag. The file's bandwidth limit, if any, applies to what is sent.
+func serveDownloadContent(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, convertedPath, etag string) (int64, error) {
+	var content io.ReadCloser
+	var err error
+	if convertedPath != "" {
+		content, err = os.Open(convertedPath)
+	} else {
+		content, err = storage.Files.Get(fileInfo.Id)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+
+	f, ok := content.(io.ReadSeeker)
+	if !ok {
+		return 0, errors.New("stored file can't seek")
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+
+	// Downloads of a file with a bandwidth limit share it
+	limiter, release := acquireDownloadLimiter(fileInfo)
+	defer release()
+	if limiter != nil {
+		w = newThrottledWriter(w, r, limiter)
+	}
+
+	cw := newCountingResponseWriter(w)
+	http.ServeContent(cw, r, "", time.Unix(fileInfo.UploadDate, 0), f)
+	return cw.BytesWritten(), nil
+}
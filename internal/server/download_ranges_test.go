@@ -0,0 +1,94 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// startRangedDownload makes a counted download of a file and returns its response, which
+// carries the range grant
+func startRangedDownload(t *testing.T, s *Server, fileID string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/"+fileID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("download: status %d, body %q", w.Code, w.Body.String())
+	}
+	return w
+}
+
+// rangeRequest asks for the rest of a file from byte 100, as a resumed download does
+func rangeRequest(fileID string, grant *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/d/"+fileID, nil)
+	r.Header.Set("Range", "bytes=100-")
+	return withCookies(r, grant)
+}
+
+func TestDownloadRangeServedWithGrant(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	createTestFile(t, owner, "ranged", content, nil)
+
+	first := startRangedDownload(t, s, "ranged")
+	w := serve(s.handleDownload, rangeRequest("ranged", first))
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("range: status %d, want %d", w.Code, http.StatusPartialContent)
+	}
+	if !bytes.Equal(w.Body.Bytes(), content[100:]) {
+		t.Errorf("range: got %d bytes, want the %d after byte 100", w.Body.Len(), len(content)-100)
+	}
+	if got := getFile(t, "ranged").DownloadCount; got != 1 {
+		t.Errorf("DownloadCount = %d, want 1: ranges of a counted download must not count", got)
+	}
+}
+
+// A range grant must not outlive a download lock set after the download started
+func TestDownloadRangeRefusedWhenDownloadsLocked(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "locked", bytes.Repeat([]byte("x"), 1000), nil)
+
+	first := startRangedDownload(t, s, "locked")
+	if _, err := database.DB.BlockFileDownloads("locked", time.Now().Add(time.Hour), "test"); err != nil {
+		t.Fatalf("BlockFileDownloads: %v", err)
+	}
+
+	r := rangeRequest("locked", first)
+	fileInfo := getFile(t, "locked")
+	w := httptest.NewRecorder()
+	s.performDownload(w, r, fileInfo, nil)
+	if w.Code != http.StatusLocked {
+		t.Errorf("range after lock: status %d, want %d", w.Code, http.StatusLocked)
+	}
+}
+
+// Nor may it outlive the file's expiry
+func TestDownloadRangeRefusedAfterExpiry(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "expiring", bytes.Repeat([]byte("x"), 1000), nil)
+
+	first := startRangedDownload(t, s, "expiring")
+	fileInfo := getFile(t, "expiring")
+	if _, err := database.DB.Exec("UPDATE Files SET UnlimitedTime = 0, ExpireAt = ? WHERE Id = ?", time.Now().Add(-time.Minute).Unix(), "expiring"); err != nil {
+		t.Fatalf("expiring file: %v", err)
+	}
+
+	// fileInfo was loaded before the file expired, as for a request that was already under way
+	w := httptest.NewRecorder()
+	s.performDownload(w, rangeRequest("expiring", first), fileInfo, nil)
+	if w.Code != http.StatusGone {
+		t.Errorf("range after expiry: status %d, want %d", w.Code, http.StatusGone)
+	}
+}
@@ -0,0 +1,144 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// downloadGrant lets the browser that made a counted download reach the file again without
+// using up another download: to retry it within the retry window, e.g. when the transfer broke
+// off, or to fetch further byte ranges of it (see download_ranges.go). Other browsers and
+// sessions don't have the grant, so they are counted as new downloads.
+type downloadGrant struct {
+	FileID    string
+	Consumer  string // who made the counted download, see downloadConsumer
+	LogID     int    // download log of the counted download
+	ExpiresAt time.Time
+}
+
+var (
+	downloadGrants   = make(map[string]*downloadGrant)
+	downloadGrantsMu sync.Mutex
+)
+
+// downloadRetryCookieName is the cookie that carries a file's retry grant
+func downloadRetryCookieName(fileID string) string {
+	return "download_retry_" + fileID
+}
+
+// lookupDownloadGrant returns the unexpired grant for a file that a request carries in the
+// named cookie, or nil
+func lookupDownloadGrant(r *http.Request, cookieName, fileID string) *downloadGrant {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	downloadGrantsMu.Lock()
+	defer downloadGrantsMu.Unlock()
+
+	grant, exists := downloadGrants[cookie.Value]
+	if !exists || grant.FileID != fileID {
+		return nil
+	}
+	if time.Now().After(grant.ExpiresAt) {
+		delete(downloadGrants, cookie.Value)
+		return nil
+	}
+	return grant
+}
+
+// issueDownloadGrant stores a grant and gives it to the browser in the named cookie
+func issueDownloadGrant(w http.ResponseWriter, cookieName string, grant *downloadGrant) {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.Printf("Warning: Failed to generate download grant: %v", err)
+		return
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	downloadGrantsMu.Lock()
+	pruneDownloadGrantsLocked(time.Now())
+	downloadGrants[token] = grant
+	downloadGrantsMu.Unlock()
+
+	// Sent for the whole site, as the splash page checks retry grants too
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  grant.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// downloadConsumer identifies who is downloading: the download account or logged-in user, or
+// "" for anonymous recipients, who are only recognised by the retry grant in their browser
+func (s *Server) downloadConsumer(r *http.Request, account *models.DownloadAccount) string {
+	if account != nil {
+		return "account:" + strconv.Itoa(account.Id)
+	}
+	if user, err := s.getUserFromSession(r); err == nil && user != nil {
+		return "user:" + strconv.Itoa(user.Id)
+	}
+	return ""
+}
+
+// hasDownloadRetryGrant reports whether a request may still reach a file whose download limit
+// was reached, because it retries a download it already made. Who the downloader is gets
+// checked when the download is served, see isDownloadRetry.
+func hasDownloadRetryGrant(r *http.Request, fileInfo *database.FileInfo) bool {
+	return database.DB.IsFileDownloadRetryEnabled(fileInfo.Id) && lookupDownloadGrant(r, downloadRetryCookieName(fileInfo.Id), fileInfo.Id) != nil
+}
+
+// isDownloadRetry reports whether a download retries one the same browser and downloader made
+// within the retry window, so it must not be counted again
+func (s *Server) isDownloadRetry(r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount) bool {
+	if fileInfo.BurnAfterDownload || !database.DB.IsFileDownloadRetryEnabled(fileInfo.Id) {
+		return false
+	}
+	grant := lookupDownloadGrant(r, downloadRetryCookieName(fileInfo.Id), fileInfo.Id)
+	return grant != nil && grant.Consumer == s.downloadConsumer(r, account)
+}
+
+// grantDownloadRetry gives the browser of a counted download a grant to retry it for the
+// retry window. The window starts with the counted download and is not extended by retries.
+func (s *Server) grantDownloadRetry(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount) {
+	window := database.DB.GetDownloadRetryWindow()
+	if window == 0 || fileInfo.BurnAfterDownload || !database.DB.IsFileDownloadRetryEnabled(fileInfo.Id) {
+		return
+	}
+
+	issueDownloadGrant(w, downloadRetryCookieName(fileInfo.Id), &downloadGrant{
+		FileID:    fileInfo.Id,
+		Consumer:  s.downloadConsumer(r, account),
+		ExpiresAt: time.Now().Add(window),
+	})
+}
+
+// pruneDownloadGrantsLocked removes expired download grants and returns how many were removed.
+// downloadGrantsMu must be held.
+func pruneDownloadGrantsLocked(now time.Time) int {
+	removed := 0
+	for token, grant := range downloadGrants {
+		if now.After(grant.ExpiresAt) {
+			delete(downloadGrants, token)
+			removed++
+		}
+	}
+	return removed
+}
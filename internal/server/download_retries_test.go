@@ -0,0 +1,142 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// downloadNotifications returns how many download notifications a user has
+func downloadNotifications(t *testing.T, userId int) int {
+	t.Helper()
+	notifications, err := database.DB.GetUserNotifications(userId, 100)
+	if err != nil {
+		t.Fatalf("GetUserNotifications: %v", err)
+	}
+	count := 0
+	for _, n := range notifications {
+		if n.Category == database.NotifyDownloads {
+			count++
+		}
+	}
+	return count
+}
+
+// waitForDownloadNotifications waits for the owner to be notified of want downloads, which
+// happens in the background, then a little longer so that any extra notification shows up too
+func waitForDownloadNotifications(t *testing.T, userId, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for downloadNotifications(t, userId) < want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := downloadNotifications(t, userId); got != want {
+		t.Fatalf("owner has %d download notifications, want %d", got, want)
+	}
+}
+
+// newRetryTestFile adds a file with download retries on that can be downloaded three times
+func newRetryTestFile(t *testing.T) (*Server, *models.User) {
+	t.Helper()
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "retry", []byte("hello"), func(f *database.FileInfo) {
+		f.UnlimitedDownloads = false
+		f.DownloadsRemaining = 3
+	})
+	if err := database.DB.SetFileDownloadRetryEnabled("retry", true); err != nil {
+		t.Fatalf("SetFileDownloadRetryEnabled: %v", err)
+	}
+	return s, owner
+}
+
+// download downloads the retry test file and checks that it was served
+func download(t *testing.T, s *Server, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	w := serve(s.handleDownload, r)
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("download: status %d, body %q", w.Code, w.Body.String())
+	}
+	return w
+}
+
+// assertDownloadsCounted checks how many downloads of the retry test file were counted
+func assertDownloadsCounted(t *testing.T, want int) {
+	t.Helper()
+	fileInfo := getFile(t, "retry")
+	if fileInfo.DownloadCount != want || fileInfo.DownloadsRemaining != 3-want {
+		t.Errorf("DownloadCount = %d, DownloadsRemaining = %d; want %d counted", fileInfo.DownloadCount, fileInfo.DownloadsRemaining, want)
+	}
+}
+
+// A retry from the same browser inside the window is neither counted nor notified again
+func TestDownloadRetryWithinWindowNotCounted(t *testing.T) {
+	s, owner := newRetryTestFile(t)
+
+	first := download(t, s, httptest.NewRequest(http.MethodGet, "/d/retry", nil))
+	waitForDownloadNotifications(t, owner.Id, 1)
+
+	download(t, s, withCookies(httptest.NewRequest(http.MethodGet, "/d/retry", nil), first))
+	assertDownloadsCounted(t, 1)
+	waitForDownloadNotifications(t, owner.Id, 1)
+}
+
+// Another browser or session has no grant, so its download is a new one
+func TestDownloadRetryFromNewSessionCounted(t *testing.T) {
+	s, owner := newRetryTestFile(t)
+
+	download(t, s, httptest.NewRequest(http.MethodGet, "/d/retry", nil))
+	waitForDownloadNotifications(t, owner.Id, 1)
+
+	download(t, s, httptest.NewRequest(http.MethodGet, "/d/retry", nil))
+	assertDownloadsCounted(t, 2)
+	waitForDownloadNotifications(t, owner.Id, 2)
+}
+
+// Once the window is over, the same browser's download is counted again
+func TestDownloadRetryAfterWindowCounted(t *testing.T) {
+	s, owner := newRetryTestFile(t)
+
+	first := download(t, s, httptest.NewRequest(http.MethodGet, "/d/retry", nil))
+	waitForDownloadNotifications(t, owner.Id, 1)
+
+	var retryCookie *http.Cookie
+	for _, cookie := range first.Result().Cookies() {
+		if cookie.Name == downloadRetryCookieName("retry") {
+			retryCookie = cookie
+		}
+	}
+	if retryCookie == nil {
+		t.Fatal("counted download set no retry grant")
+	}
+	downloadGrantsMu.Lock()
+	downloadGrants[retryCookie.Value].ExpiresAt = time.Now().Add(-time.Second)
+	downloadGrantsMu.Unlock()
+
+	download(t, s, withCookies(httptest.NewRequest(http.MethodGet, "/d/retry", nil), first))
+	assertDownloadsCounted(t, 2)
+	waitForDownloadNotifications(t, owner.Id, 2)
+}
+
+// Without a retry window every download is counted
+func TestDownloadRetryWindowOffCounted(t *testing.T) {
+	s, owner := newRetryTestFile(t)
+	if err := database.DB.SetConfigValue("download_retry_window_minutes", "0"); err != nil {
+		t.Fatalf("SetConfigValue: %v", err)
+	}
+
+	first := download(t, s, httptest.NewRequest(http.MethodGet, "/d/retry", nil))
+	download(t, s, withCookies(httptest.NewRequest(http.MethodGet, "/d/retry", nil), first))
+	assertDownloadsCounted(t, 2)
+	waitForDownloadNotifications(t, owner.Id, 2)
+}
@@ -0,0 +1,166 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// DefaultDownloadTokenTTLSeconds is used when download_token_ttl_seconds is not configured
+const DefaultDownloadTokenTTLSeconds = 300
+
+// downloadToken is a single-use token issued by the splash page so that
+// repeated clicks on the download button only count as one download
+type downloadToken struct {
+	FileID    string
+	ExpiresAt time.Time
+	Used      bool
+}
+
+type downloadTokenStatus int
+
+const (
+	downloadTokenValid downloadTokenStatus = iota
+	downloadTokenUsed
+	downloadTokenInvalid
+)
+
+var (
+	downloadTokens   = make(map[string]*downloadToken)
+	downloadTokensMu sync.Mutex
+)
+
+// getDownloadTokenTTL returns the configured token lifetime, or 0 if tokens are disabled
+func getDownloadTokenTTL() time.Duration {
+	seconds := database.DB.GetConfigInt("download_token_ttl_seconds", DefaultDownloadTokenTTLSeconds)
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// issueDownloadToken creates a new single-use token for a file
+func issueDownloadToken(fileID string, ttl time.Duration) string {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.Printf("Warning: Failed to generate download token: %v", err)
+		return ""
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := time.Now()
+	downloadTokensMu.Lock()
+	defer downloadTokensMu.Unlock()
+
+	// Prune expired tokens while we hold the lock
+	pruneDownloadTokensLocked(now)
+
+	downloadTokens[token] = &downloadToken{
+		FileID:    fileID,
+		ExpiresAt: now.Add(ttl),
+	}
+	return token
+}
+
+// pruneDownloadTokensLocked removes expired tokens and returns how many were removed.
+// downloadTokensMu must be held.
+func pruneDownloadTokensLocked(now time.Time) int {
+	removed := 0
+	for t, dt := range downloadTokens {
+		if now.After(dt.ExpiresAt) {
+			delete(downloadTokens, t)
+			removed++
+		}
+	}
+	return removed
+}
+
+// PruneExpiredDownloadTokens removes expired download tokens and download retry grants,
+// which are otherwise only pruned when a new one is issued
+func PruneExpiredDownloadTokens() {
+	downloadTokensMu.Lock()
+	removed := pruneDownloadTokensLocked(time.Now())
+	downloadTokensMu.Unlock()
+
+	downloadGrantsMu.Lock()
+	removed += pruneDownloadGrantsLocked(time.Now())
+	downloadGrantsMu.Unlock()
+
+	if removed > 0 {
+		log.Printf("Token cleanup: removed %d expired download tokens", removed)
+	}
+}
+
+// consumeDownloadToken marks a token as used. Only the first call for a
+// token returns downloadTokenValid; later calls return downloadTokenUsed
+// until the token expires.
+func consumeDownloadToken(token, fileID string) downloadTokenStatus {
+	downloadTokensMu.Lock()
+	defer downloadTokensMu.Unlock()
+
+	dt, exists := downloadTokens[token]
+	if !exists || dt.FileID != fileID {
+		return downloadTokenInvalid
+	}
+	if time.Now().After(dt.ExpiresAt) {
+		delete(downloadTokens, token)
+		return downloadTokenInvalid
+	}
+	if dt.Used {
+		return downloadTokenUsed
+	}
+
+	dt.Used = true
+	return downloadTokenValid
+}
+
+// checkDownloadToken consumes the splash page token carried by a download request.
+// Downloads started from a splash page must carry one, so repeated clicks count once. Direct
+// /d/ links, which are shared as such and used by scripts, never pass the splash page and
+// are counted per request instead.
+// Returns false if the request was answered and the download must not proceed.
+func (s *Server) checkDownloadToken(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo) bool {
+	token := r.URL.Query().Get("dt")
+	if token == "" {
+		if getDownloadTokenTTL() > 0 && startedFromSplashPage(r) {
+			// The splash page gives out a fresh token
+			http.Redirect(w, r, "/s/"+fileInfo.Id, http.StatusSeeOther)
+			return false
+		}
+		return true
+	}
+
+	switch consumeDownloadToken(token, fileInfo.Id) {
+	case downloadTokenValid:
+		return true
+	case downloadTokenUsed:
+		log.Printf("Ignoring repeated download request for %s (token already used) from %s", fileInfo.Id, clientIP(r))
+		http.Error(w, "This download has already started. Reload the download page to download again.", http.StatusConflict)
+		return false
+	default:
+		// Expired or unknown token: send the user back to the splash page for a fresh one
+		http.Redirect(w, r, "/s/"+fileInfo.Id, http.StatusSeeOther)
+		return false
+	}
+}
+
+// startedFromSplashPage reports whether a request came from one of this server's splash pages
+func startedFromSplashPage(r *http.Request) bool {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || !strings.EqualFold(referer.Host, r.Host) {
+		return false
+	}
+	return strings.HasPrefix(referer.Path, "/s/")
+}
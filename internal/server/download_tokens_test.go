@@ -0,0 +1,107 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// Clicking the splash page's download button several times in a row must only use up one
+// download
+func TestDownloadTokenCountsRapidRequestsOnce(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "limited", []byte("hello"), func(f *database.FileInfo) {
+		f.UnlimitedDownloads = false
+		f.DownloadsRemaining = 5
+	})
+	token := issueDownloadToken("limited", time.Minute)
+
+	const requests = 10
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/limited?dt="+token, nil))
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != requests-1 {
+		t.Errorf("status codes = %v, want one %d and %d %d", counts, http.StatusOK, requests-1, http.StatusConflict)
+	}
+
+	fileInfo := getFile(t, "limited")
+	if fileInfo.DownloadCount != 1 {
+		t.Errorf("DownloadCount = %d, want 1", fileInfo.DownloadCount)
+	}
+	if fileInfo.DownloadsRemaining != 4 {
+		t.Errorf("DownloadsRemaining = %d, want 4", fileInfo.DownloadsRemaining)
+	}
+}
+
+// Unknown, expired or other files' tokens send the user back to the splash page without
+// counting a download
+func TestDownloadTokenInvalidRedirectsToSplash(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "file", []byte("hello"), nil)
+
+	expired := issueDownloadToken("file", time.Minute)
+	downloadTokensMu.Lock()
+	downloadTokens[expired].ExpiresAt = time.Now().Add(-time.Second)
+	downloadTokensMu.Unlock()
+
+	for name, token := range map[string]string{
+		"unknown":    "0123456789abcdef0123456789abcdef",
+		"expired":    expired,
+		"other file": issueDownloadToken("other", time.Minute),
+	} {
+		w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/file?dt="+token, nil))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/s/file" {
+			t.Errorf("%s token: status %d to %q, want %d to /s/file", name, w.Code, w.Header().Get("Location"), http.StatusSeeOther)
+		}
+	}
+	if got := getFile(t, "file").DownloadCount; got != 0 {
+		t.Errorf("DownloadCount = %d, want 0", got)
+	}
+}
+
+// A download started from the splash page without its token gets a fresh one there, while
+// direct links keep working without
+func TestDownloadTokenRequiredFromSplashPage(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "file", []byte("hello"), nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/d/file", nil)
+	r.Header.Set("Referer", "http://"+r.Host+"/s/file")
+	if w := serve(s.handleDownload, r); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/s/file" {
+		t.Errorf("tokenless download from the splash page: status %d to %q, want %d to /s/file", w.Code, w.Header().Get("Location"), http.StatusSeeOther)
+	}
+	if got := getFile(t, "file").DownloadCount; got != 0 {
+		t.Errorf("DownloadCount = %d, want 0", got)
+	}
+
+	if w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/file", nil)); w.Code != http.StatusOK {
+		t.Errorf("direct link: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,216 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+)
+
+var emailProviderLabels = map[string]string{
+	"resend":   "Resend",
+	"brevo":    "Brevo (Sendinblue)",
+	"mailgun":  "Mailgun",
+	"sendgrid": "SendGrid",
+	"smtp":     "SMTP Server",
+}
+
+func emailProviderLabel(provider string) string {
+	if label, ok := emailProviderLabels[provider]; ok {
+		return label
+	}
+	return provider
+}
+
+// handleEmailProviderOrder saves the delivery order of the email providers and which of them
+// are enabled. Emails go through the first enabled provider and fall back to the next ones.
+func (s *Server) handleEmailProviderOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Providers []struct {
+			Provider string `json:"provider"`
+			Enabled  bool   `json:"enabled"`
+		} `json:"providers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	statuses, err := database.DB.GetEmailProviderStatuses()
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	configured := map[string]bool{}
+	for _, status := range statuses {
+		configured[status.Provider] = true
+	}
+
+	var order []string
+	enabled := map[string]bool{}
+	for _, p := range req.Providers {
+		if !configured[p.Provider] || enabled[p.Provider] {
+			s.sendError(w, http.StatusBadRequest, "Unknown or repeated provider: "+p.Provider)
+			return
+		}
+		order = append(order, p.Provider)
+		enabled[p.Provider] = p.Enabled
+	}
+	if len(order) != len(configured) {
+		s.sendError(w, http.StatusBadRequest, "Every configured provider must be listed")
+		return
+	}
+
+	if err := database.DB.SetEmailProviderOrder(order, enabled); err != nil {
+		log.Printf("Failed to save email provider order: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to save the provider order")
+		return
+	}
+
+	var enabledOrder []string
+	for _, provider := range order {
+		if enabled[provider] {
+			enabledOrder = append(enabledOrder, provider)
+		}
+	}
+	log.Printf("Email delivery order set to %v", enabledOrder)
+
+	user, _ := userFromContext(r.Context())
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionEmailConfigUpdated,
+		EntityType: database.EntitySettings,
+		EntityID:   "email_providers",
+		Details:    database.CreateAuditDetails(map[string]interface{}{"delivery_order": enabledOrder}),
+		IPAddress:  clientIP(r),
+		UserAgent:  r.UserAgent(),
+		Success:    true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// getProviderChainHTML renders the delivery order of the configured providers with controls
+// to reorder, enable and disable them, and how each has been delivering
+func getProviderChainHTML() string {
+	statuses, err := database.DB.GetEmailProviderStatuses()
+	if err != nil {
+		log.Printf("Failed to load email providers: %v", err)
+	}
+
+	if len(statuses) == 0 {
+		return `
+        <div style="background: #fff3cd; border: 1px solid #ffc107; color: #856404; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
+            <strong>⚠ No Active Provider:</strong> Configure Resend, Brevo, Mailgun, SendGrid, or SMTP to enable email notifications
+        </div>`
+	}
+
+	html := ""
+	if !statuses[0].IsActive {
+		// Enabled providers come first, so none is enabled
+		html += `
+        <div style="background: #fff3cd; border: 1px solid #ffc107; color: #856404; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
+            <strong>⚠ No Active Provider:</strong> Enable a provider in the delivery order below to send email notifications
+        </div>`
+	}
+
+	html += `
+        <div style="background: #f8fafc; border: 1px solid #e2e8f0; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
+            <strong>Delivery order</strong>
+            <p style="color: #666; font-size: 13px; margin: 6px 0 12px 0;">Emails are sent through the first enabled provider. If it fails, the next one is tried. A provider that fails ` + strconv.Itoa(email.ProviderFailureLimit) + ` times in a row is skipped for ` + strconv.Itoa(int(email.ProviderSkipDuration.Minutes())) + ` minutes.</p>
+            <ul id="provider-chain" style="list-style: none;">`
+
+	now := time.Now()
+	for _, status := range statuses {
+		checked := ""
+		if status.IsActive {
+			checked = " checked"
+		}
+
+		stats := fmt.Sprintf("Sent %d · Failed %d", status.SentCount, status.FailedCount)
+		if status.LastSentAt > 0 {
+			stats += " · Last sent " + formatTimestamp(status.LastSentAt)
+		}
+		health := email.GetProviderHealth(status.Provider)
+		if now.Before(health.SkippedUntil) {
+			stats += ` · <span style="color: #c33; font-weight: 600;">Skipped until ` + health.SkippedUntil.Format("15:04") + `</span>`
+		} else if health.ConsecutiveFailures > 0 {
+			stats += fmt.Sprintf(` · <span style="color: #b45309;">%d failure(s) in a row</span>`, health.ConsecutiveFailures)
+		}
+		lastError := ""
+		if status.LastError != "" && status.LastErrorAt > status.LastSentAt {
+			lastError = `<div style="color: #c33; font-size: 12px; margin-top: 2px; word-break: break-word;">Last error (` + formatTimestamp(status.LastErrorAt) + `): ` + template.HTMLEscapeString(status.LastError) + `</div>`
+		}
+
+		html += `
+                <li data-provider="` + template.HTMLEscapeString(status.Provider) + `" style="display: flex; align-items: center; gap: 10px; padding: 8px 0; border-top: 1px solid #e2e8f0;">
+                    <span style="display: flex; flex-direction: column;">
+                        <button type="button" class="chain-up" title="Move up" style="border: none; background: none; cursor: pointer;">▲</button>
+                        <button type="button" class="chain-down" title="Move down" style="border: none; background: none; cursor: pointer;">▼</button>
+                    </span>
+                    <label style="display: flex; align-items: center; gap: 6px; min-width: 190px; font-weight: 600;">
+                        <input type="checkbox" class="chain-enabled"` + checked + `> ` + emailProviderLabel(status.Provider) + `
+                    </label>
+                    <div style="font-size: 13px; color: #555;">` + stats + lastError + `</div>
+                </li>`
+	}
+
+	html += `
+            </ul>
+            <button type="button" class="btn-secondary" id="save-provider-chain" style="margin-top: 10px;">Save delivery order</button>
+        </div>
+        <script>
+        (function() {
+            const list = document.getElementById('provider-chain');
+            list.addEventListener('click', function(e) {
+                const item = e.target.closest('li');
+                if (e.target.classList.contains('chain-up') && item.previousElementSibling) {
+                    list.insertBefore(item, item.previousElementSibling);
+                } else if (e.target.classList.contains('chain-down') && item.nextElementSibling) {
+                    list.insertBefore(item.nextElementSibling, item);
+                }
+            });
+            document.getElementById('save-provider-chain').addEventListener('click', async function() {
+                const providers = Array.from(list.querySelectorAll('li')).map(li => ({
+                    provider: li.dataset.provider,
+                    enabled: li.querySelector('.chain-enabled').checked
+                }));
+                try {
+                    const response = await fetch('/api/email/providers/order', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        credentials: 'same-origin',
+                        body: JSON.stringify({ providers: providers })
+                    });
+                    if (response.ok) {
+                        showSuccess('Delivery order saved.');
+                        setTimeout(() => location.reload(), 1000);
+                    } else {
+                        const error = await response.json();
+                        showError('Error: ' + error.error);
+                    }
+                } catch (err) {
+                    showError('Error: ' + err.message);
+                }
+            });
+        })();
+        </script>`
+	return html
+}
@@ -0,0 +1,93 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+)
+
+// handleEmailUnsubscribe handles the unsubscribe link in the footer of notification emails
+// (/email/unsubscribe/<token>). It works without logging in and turns off emails of the link's
+// category, or all emails except security notifications, in the user's notification preferences.
+func (s *Server) handleEmailUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	userId, categoryKey, err := email.VerifyUnsubscribeToken(strings.TrimPrefix(r.URL.Path, "/email/unsubscribe/"))
+	var category *database.NotificationCategory
+	for i := range database.NotificationCategories {
+		if database.NotificationCategories[i].Key == categoryKey {
+			category = &database.NotificationCategories[i]
+		}
+	}
+	if err != nil || category == nil || category.Required {
+		s.renderSplashPageUnavailable(w, "🔗", "Invalid Link", "This unsubscribe link is not valid.")
+		return
+	}
+
+	user, err := database.DB.GetUserByID(userId)
+	if err != nil {
+		s.renderSplashPageUnavailable(w, "🔗", "Invalid Link", "This unsubscribe link is not valid.")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		buttonStyle := "padding: 12px 24px; margin: 20px 6px 0; border: none; border-radius: 8px; font-size: 15px; cursor: pointer; color: white; background: " + s.getPrimaryColor() + ";"
+		s.renderSplashPageUnavailable(w, "✉️", "Email Notifications",
+			`Stop sending emails to <strong>`+template.HTMLEscapeString(user.Email)+`</strong>?
+            <form method="POST">
+                <button type="submit" name="scope" value="category" style="`+buttonStyle+`">Only `+template.HTMLEscapeString(strings.ToLower(category.Name))+`</button>
+                <button type="submit" name="scope" value="all" style="`+buttonStyle+`">All notifications</button>
+            </form>
+            <p style="margin-top: 20px; font-size: 14px;">Security notifications and emails you ask for, like password resets, are always sent.</p>`)
+		return
+	}
+
+	unsubscribeAll := r.FormValue("scope") == "all"
+	prefs := database.DB.GetNotificationPreferences(user.Id)
+	emailOff := []string{}
+	for _, c := range database.NotificationCategories {
+		if c.Required || (!unsubscribeAll && c.Key != category.Key) {
+			continue
+		}
+		pref := prefs[c.Key]
+		pref.Email = false
+		prefs[c.Key] = pref
+		emailOff = append(emailOff, c.Key)
+	}
+
+	if err := database.DB.SetNotificationPreferences(user.Id, prefs); err != nil {
+		log.Printf("Failed to unsubscribe %s from %s emails: %v", user.Email, category.Key, err)
+		s.renderSplashPageUnavailable(w, "⚠️", "Something Went Wrong", "Could not update your email settings. Please try again later.")
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionNotificationPrefsUpdated,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"via":             "email_unsubscribe",
+			"unsubscribe_all": unsubscribeAll,
+			"email_off":       emailOff,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	message := "You will no longer get emails about " + template.HTMLEscapeString(strings.ToLower(category.Name)) + "."
+	if unsubscribeAll {
+		message = "You will no longer get notification emails. Security notifications are still sent."
+	}
+	s.renderSplashPageUnavailable(w, "✅", "Unsubscribed", message+` You can change this on your <a href="/notifications#preferences">notifications page</a>.`)
+}
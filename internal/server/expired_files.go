@@ -0,0 +1,142 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/cleanup"
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+var (
+	// overdueExpiryWarned limits the warning about a requested overdue file to once an hour per file
+	overdueExpiryWarned   = make(map[string]time.Time)
+	overdueExpiryWarnedMu sync.Mutex
+)
+
+// fileExpiredReason evaluates a file's expiry for a splash page, preview or download request and
+// returns database.FileExpiredByTime, database.FileExpiredByDownloads or "". A file that expired
+// longer ago than the grace period and is still not in trash is logged, since it means the
+// cleanup scheduler is not running.
+func fileExpiredReason(fileInfo *database.FileInfo) string {
+	now := time.Now()
+	reason := fileInfo.ExpiredReason(now)
+	if reason != database.FileExpiredByTime {
+		return reason
+	}
+
+	grace := database.DB.GetExpiredFileGrace()
+	expiredAt := time.Unix(fileInfo.ExpireAt, 0)
+	if grace == 0 || now.Sub(expiredAt) <= grace {
+		return reason
+	}
+
+	overdueExpiryWarnedMu.Lock()
+	defer overdueExpiryWarnedMu.Unlock()
+	if now.Sub(overdueExpiryWarned[fileInfo.Id]) > time.Hour {
+		overdueExpiryWarned[fileInfo.Id] = now
+		log.Printf("Warning: File %s (%s) was requested %v after it expired but is not in trash - check that the cleanup scheduler is running",
+			fileInfo.Id, fileInfo.Name, now.Sub(expiredAt).Round(time.Minute))
+	}
+	return reason
+}
+
+// expiredFilesBannerHTML returns a warning for the admin dashboard when expired files have not
+// been moved to trash within the grace period, or "" if there are none
+func expiredFilesBannerHTML() string {
+	grace := database.DB.GetExpiredFileGrace()
+	if grace == 0 {
+		return ""
+	}
+	files, err := database.DB.GetOverdueExpiredFiles(grace)
+	if err != nil || len(files) == 0 {
+		return ""
+	}
+
+	lastRun := "has not run since this check was added"
+	if ranAt := cleanup.JobLastRun(cleanup.JobExpiredFiles); !ranAt.IsZero() {
+		lastRun = "last ran " + ranAt.In(database.ServerLocation()).Format("2006-01-02 15:04")
+	}
+
+	heading := strconv.Itoa(len(files)) + " expired files were not moved to trash"
+	if len(files) == 1 {
+		heading = "1 expired file was not moved to trash"
+	}
+
+	names := ""
+	for i, file := range files {
+		if i == 5 {
+			names += fmt.Sprintf(" and %d more", len(files)-5)
+			break
+		}
+		if i > 0 {
+			names += ", "
+		}
+		names += template.HTMLEscapeString(file.Name)
+	}
+
+	return `
+        <div class="rounded-2xl mb-8 p-6" style="background: #fff3cd; border: 2px solid #ffc107; color: #664d03;">
+            <div class="flex items-start gap-4">
+                <span class="text-3xl">⚠️</span>
+                <div class="flex-1">
+                    <div class="font-bold text-lg mb-2">` + heading + `</div>
+                    <p class="mb-2">These files expired more than ` + strconv.Itoa(int(grace.Hours())) + ` hours ago. Downloads of them are refused, but they still use storage. Expired file cleanup ` + lastRun + `; check the server log for errors.</p>
+                    <p class="mb-4 text-sm">` + names + `</p>
+                    <form method="POST" action="/admin/expired-files/trash" onsubmit="return confirm('Move all expired files to trash now?')">
+                        <button type="submit" style="background: #ffc107; color: #000; border: none; padding: 8px 16px; border-radius: 6px; font-weight: 600; cursor: pointer;">🗑️ Move Expired Files to Trash Now</button>
+                    </form>
+                </div>
+            </div>
+        </div>
+`
+}
+
+// handleAdminTrashExpiredFiles runs the expired file cleanup right away (POST /admin/expired-files/trash)
+func (s *Server) handleAdminTrashExpiredFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	overdue, _ := database.DB.GetOverdueExpiredFiles(database.DB.GetExpiredFileGrace())
+	err := cleanup.CleanupExpiredFiles(s.config.UploadsDir)
+	errorMessage := ""
+	if err != nil {
+		log.Printf("Error while moving expired files to trash: %v", err)
+		errorMessage = err.Error()
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionExpiredFilesTrashed,
+		EntityType: database.EntitySystem,
+		EntityID:   "expired_files",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"overdue_files": len(overdue),
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   err == nil,
+		ErrorMsg:  errorMessage,
+	})
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
@@ -0,0 +1,107 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// zipEntries returns the names and contents of the files in a ZIP download
+func zipEntries(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("reading ZIP: %v", err)
+	}
+	entries := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s in ZIP: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s in ZIP: %v", f.Name, err)
+		}
+		entries[f.Name] = string(content)
+	}
+	return entries
+}
+
+// A file whose time is up must not be served in any way while it waits for the cleanup
+// scheduler to move it to trash, whether it just expired or cleanup is overdue
+func TestExpiredFileRefusedBeforeCleanup(t *testing.T) {
+	for name, expiredFor := range map[string]time.Duration{
+		"just expired":    time.Minute,
+		"cleanup overdue": 72 * time.Hour,
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+			createTestFile(t, owner, "fresh", []byte("fresh content"), nil)
+			createTestFile(t, owner, "expired", []byte("expired content"), func(f *database.FileInfo) {
+				f.UnlimitedTime = false
+				f.ExpireAt = time.Now().Add(-expiredFor).Unix()
+			})
+
+			// Still there for cleanup to find, not in trash
+			expired := getFile(t, "expired")
+
+			w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/expired", nil))
+			if w.Code != http.StatusGone || strings.Contains(w.Body.String(), "expired content") {
+				t.Errorf("download: status %d, want %d without the content", w.Code, http.StatusGone)
+			}
+
+			if s.canPreviewFile(httptest.NewRequest(http.MethodGet, "/s/expired", nil), expired) {
+				t.Error("canPreviewFile allowed a preview of the expired file")
+			}
+			w = serve(s.handleFilePreview, httptest.NewRequest(http.MethodGet, "/preview/expired", nil))
+			if w.Code != http.StatusGone || strings.Contains(w.Body.String(), "expired content") {
+				t.Errorf("preview: status %d, want %d without the content", w.Code, http.StatusGone)
+			}
+
+			w = serve(s.handleFilesZip, httptest.NewRequest(http.MethodGet, "/d/zip?ids=expired", nil))
+			if w.Code != http.StatusGone {
+				t.Errorf("ZIP of only the expired file: status %d, want %d", w.Code, http.StatusGone)
+			}
+
+			w = serve(s.handleFilesZip, httptest.NewRequest(http.MethodGet, "/d/zip?ids=expired,fresh", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("ZIP with the expired file: status %d, body %q", w.Code, w.Body.String())
+			}
+			entries := zipEntries(t, w.Body.Bytes())
+			if entries["fresh.txt"] != "fresh content" {
+				t.Errorf("ZIP entries %v, want fresh.txt", entries)
+			}
+			if _, ok := entries["expired.txt"]; ok {
+				t.Error("ZIP included the expired file")
+			}
+			if !strings.Contains(entries["SKIPPED.txt"], "expired.txt: expired") {
+				t.Errorf("SKIPPED.txt = %q, want it to list expired.txt as expired", entries["SKIPPED.txt"])
+			}
+
+			// Team ZIPs skip it too, even for members with access to the file
+			if reason := s.zipSkipReason(expired, true); reason != "expired" {
+				t.Errorf("zipSkipReason = %q, want %q", reason, "expired")
+			}
+
+			if got := getFile(t, "expired").DownloadCount; got != 0 {
+				t.Errorf("DownloadCount of the expired file = %d, want 0", got)
+			}
+		})
+	}
+}
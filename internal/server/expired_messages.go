@@ -0,0 +1,54 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// maxExpiredMessageLength is the longest expired page message that can be saved, in characters
+const maxExpiredMessageLength = 500
+
+// normalizeExpiredMessage trims an expired page message and checks that it is plain text of a
+// reasonable length. Line breaks are kept; other control characters are refused.
+func normalizeExpiredMessage(message string) (string, error) {
+	message = strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n"))
+	if !utf8.ValidString(message) {
+		return "", errors.New("the message contains invalid characters")
+	}
+	if utf8.RuneCountInString(message) > maxExpiredMessageLength {
+		return "", fmt.Errorf("the message can be at most %d characters", maxExpiredMessageLength)
+	}
+	for _, r := range message {
+		if r != '\n' && unicode.IsControl(r) {
+			return "", errors.New("the message contains invalid characters")
+		}
+	}
+	return message, nil
+}
+
+// expiredMessageHTML returns the file owner's message for a dead link, or the deployment default
+// when the file has none, escaped for the expired page. Returns "" if neither is set.
+func expiredMessageHTML(fileInfo *database.FileInfo) string {
+	message := database.DB.GetFileExpiredMessage(fileInfo.Id)
+	if message == "" {
+		message = database.DB.GetDefaultExpiredMessage()
+	}
+	if message == "" {
+		return ""
+	}
+
+	escaped := strings.ReplaceAll(template.HTMLEscapeString(message), "\n", "<br>")
+	return `
+        <div style="margin-top: 20px; padding: 15px; background: #f8f9fa; border-left: 4px solid #ccc; border-radius: 4px; text-align: left; color: #333; white-space: normal; overflow-wrap: anywhere;">` + escaped + `</div>`
+}
@@ -0,0 +1,289 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/cleanup"
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// expiryPolicyRunning is set while a retroactive expiry policy run is in progress
+var expiryPolicyRunning atomic.Bool
+
+// expireDateLayout is the format of the expire_date form value of uploads and file edits
+const expireDateLayout = "2006-01-02"
+
+// parseExpireDate returns the expiry time of an expire_date form value: the end of that day in
+// the server's timezone. Dates that have already ended are refused.
+func parseExpireDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation(expireDateLayout, strings.TrimSpace(value), database.ServerLocation())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date, use YYYY-MM-DD", value)
+	}
+	expireTime := time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, date.Location())
+	if expireTime.Before(time.Now()) {
+		return time.Time{}, fmt.Errorf("%s is in the past", date.Format(expireDateLayout))
+	}
+	return expireTime, nil
+}
+
+// expireDateOf returns the date a file expires on in the server's timezone, as an expire_date
+// form value, or "" if it doesn't expire by time
+func expireDateOf(fileInfo *database.FileInfo) string {
+	if fileInfo.UnlimitedTime || fileInfo.ExpireAt <= 0 {
+		return ""
+	}
+	return time.Unix(fileInfo.ExpireAt, 0).In(database.ServerLocation()).Format(expireDateLayout)
+}
+
+// requestedFileExpiry returns the expiry time asked for with an upload's expire_date or
+// expiration_days value, or 0 if neither is set. The date takes precedence.
+func requestedFileExpiry(expireDate, expirationDays string) (int64, error) {
+	if strings.TrimSpace(expireDate) != "" {
+		expireTime, err := parseExpireDate(expireDate)
+		if err != nil {
+			return 0, err
+		}
+		return expireTime.Unix(), nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSpace(expirationDays)); err == nil && days > 0 {
+		return time.Now().Add(time.Duration(days) * 24 * time.Hour).Unix(), nil
+	}
+	return 0, nil
+}
+
+// limitFileExpiry applies the maximum file expiry to the expiry chosen for a new or edited file.
+// Files may not be set to never expire while a maximum is configured.
+func limitFileExpiry(expireAt int64, expireAtString string, unlimitedTime bool) (int64, string, bool) {
+	maxDays := database.DB.GetMaxFileExpiryDays()
+	if maxDays == 0 {
+		return expireAt, expireAtString, unlimitedTime
+	}
+
+	maxExpireTime := time.Now().Add(time.Duration(maxDays) * 24 * time.Hour)
+	if unlimitedTime || expireAt == 0 || expireAt > maxExpireTime.Unix() {
+		return maxExpireTime.Unix(), database.FormatExpireAt(maxExpireTime.Unix()), false
+	}
+	return expireAt, expireAtString, unlimitedTime
+}
+
+// handleAdminExpiryPolicy previews (GET) or applies (POST) the maximum file expiry to existing files
+func (s *Server) handleAdminExpiryPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.renderAdminExpiryPolicy(w, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	maxDays := database.DB.GetMaxFileExpiryDays()
+	if maxDays == 0 {
+		s.renderAdminExpiryPolicy(w, "Error: Set a maximum file expiry in Settings first")
+		return
+	}
+
+	if !expiryPolicyRunning.CompareAndSwap(false, true) {
+		s.renderAdminExpiryPolicy(w, "Error: The expiry policy is already being applied")
+		return
+	}
+
+	ipAddress := clientIP(r)
+	userAgent := r.UserAgent()
+	go func() {
+		defer expiryPolicyRunning.Store(false)
+
+		result, err := cleanup.ApplyExpiryPolicy(maxDays, s.getPublicURL(), s.config.CompanyName)
+		if err != nil {
+			log.Printf("Error while applying expiry policy: %v", err)
+		}
+
+		details := map[string]interface{}{
+			"max_days": maxDays,
+		}
+		if result != nil {
+			details["files"] = result.Files
+			details["owners"] = result.Owners
+			details["expire_at"] = time.Unix(result.ExpireAt, 0).Format("2006-01-02 15:04")
+		}
+		errorMessage := ""
+		if err != nil {
+			errorMessage = err.Error()
+		}
+
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(admin.Id),
+			UserEmail:  admin.Email,
+			Action:     database.ActionExpiryPolicyApplied,
+			EntityType: database.EntitySettings,
+			EntityID:   "max_file_expiry_days",
+			Details:    database.CreateAuditDetails(details),
+			IPAddress:  ipAddress,
+			UserAgent:  userAgent,
+			Success:    err == nil,
+			ErrorMsg:   errorMessage,
+		})
+	}()
+
+	s.renderAdminExpiryPolicy(w, "Applying the expiry policy in the background. Owners are emailed a list of their affected files")
+}
+
+// renderAdminExpiryPolicy renders the dry-run preview of applying the expiry policy to existing files
+func (s *Server) renderAdminExpiryPolicy(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	maxDays := database.DB.GetMaxFileExpiryDays()
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>File Expiry Policy - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        .card p { color: #555; font-size: 14px; line-height: 1.6; margin-bottom: 10px; }
+        .btn {
+            margin-top: 12px;
+            padding: 10px 20px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+            text-decoration: none;
+            display: inline-block;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+        }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        .stat {
+            font-size: 32px;
+            font-weight: 700;
+            color: ` + s.getPrimaryColor() + `;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <h2>⏳ File Expiry Policy</h2>
+
+        <div class="info-box">
+            The maximum file expiry in Settings applies to new uploads and edits. Files shared before it was set (or tightened) keep their old expiry until you apply the policy here. Applying it makes those files expire when the maximum is reached, counted from today, and emails each owner a list of their affected files.
+        </div>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	if maxDays == 0 {
+		html += `
+        <div class="card">
+            <h3>No Maximum Expiry</h3>
+            <p>Files can currently be shared for any length of time, including forever.</p>
+            <a href="/admin/settings" class="btn">Open Settings</a>
+        </div>`
+	} else {
+		expireTime := time.Now().Add(time.Duration(maxDays) * 24 * time.Hour)
+		files, owners, err := database.DB.CountFilesExceedingExpiry(expireTime.Unix())
+		if err != nil {
+			log.Printf("Failed to count files exceeding expiry policy: %v", err)
+		}
+
+		html += `
+        <div class="card">
+            <h3>Preview</h3>
+            <p>Maximum expiry: <strong>` + fmt.Sprintf("%d", maxDays) + ` days</strong></p>
+            <div class="stat">` + fmt.Sprintf("%d", files) + `</div>
+            <p>existing files of ` + fmt.Sprintf("%d", owners) + ` owner(s) never expire or expire after ` + expireTime.Format("2006-01-02 15:04") + `. Applying the policy sets them to expire at that time.</p>`
+
+		if expiryPolicyRunning.Load() {
+			html += `
+            <p><strong>The policy is being applied right now.</strong> Reload this page to see the result.</p>`
+		} else if files > 0 {
+			html += `
+            <form method="POST" onsubmit="return confirm('Shorten the expiry of ` + fmt.Sprintf("%d", files) + ` file(s) and notify their owners?');">
+                <button type="submit" class="btn">Apply to ` + fmt.Sprintf("%d", files) + ` File(s)</button>
+            </form>`
+		}
+
+		html += `
+        </div>`
+	}
+
+	html += `
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
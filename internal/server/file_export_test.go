@@ -0,0 +1,176 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// uploadedAt is when the export test files were uploaded, 2023-11-14T22:13:20Z
+const uploadedAt = 1700000000
+
+// createExportTestFiles adds files covering the columns of the file export: an unlimited file
+// shared with a team and with a name CSV has to quote, a limited file behind a login and a
+// password, a file that expired and a file in trash, which is not exported
+func createExportTestFiles(t *testing.T) {
+	t.Helper()
+	owner := createTestUser(t, "alice@example.com", models.UserLevelUser, 1000)
+	owner.Name = "Alice Andersson"
+	if err := database.DB.UpdateUser(owner); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	createTestFile(t, owner, "report", bytes.Repeat([]byte("r"), 2048), func(f *database.FileInfo) {
+		f.Name = `report, "final".pdf`
+		f.Category = database.FileCategoryDocument
+		f.UploadDate = uploadedAt
+	})
+	team := &models.Team{Name: "Sales", CreatedBy: owner.Id, StorageQuotaMB: 1000, IsActive: true}
+	if err := database.DB.CreateTeam(team); err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if err := database.DB.ShareFileToTeam("report", team.Id, owner.Id); err != nil {
+		t.Fatalf("ShareFileToTeam: %v", err)
+	}
+
+	createTestFile(t, owner, "limited", []byte("hello"), func(f *database.FileInfo) {
+		f.Category = database.FileCategoryOther
+		f.UploadDate = uploadedAt + 200
+		f.UnlimitedDownloads = false
+		f.DownloadsRemaining = 2
+		f.DownloadCount = 3
+		f.UnlimitedTime = false
+		f.ExpireAt = 4102444800 // 2100-01-01T00:00:00Z
+		f.RequireAuth = true
+		f.FilePasswordPlain = "secret"
+	})
+
+	createTestFile(t, owner, "old", []byte("hello"), func(f *database.FileInfo) {
+		f.Category = database.FileCategoryOther
+		f.UploadDate = uploadedAt + 100
+		f.UnlimitedTime = false
+		f.ExpireAt = uploadedAt + 3600
+	})
+
+	createTestFile(t, owner, "trashed", []byte("hello"), func(f *database.FileInfo) {
+		f.UploadDate = uploadedAt + 300
+	})
+	if err := database.DB.DeleteFile("trashed", owner.Id); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+}
+
+// exportRequest asks for the file export with the given query as admin
+func exportRequest(s *Server, admin *models.User, query string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/admin/files/export?"+query, nil)
+	return serve(s.handleAdminFilesExport, r.WithContext(contextWithUser(r.Context(), admin)))
+}
+
+func TestAdminFilesExportCSV(t *testing.T) {
+	s := newTestServer(t)
+	admin := createTestUser(t, "admin@example.com", models.UserLevelAdmin, 1000)
+	createExportTestFiles(t)
+
+	w := exportRequest(s, admin, "format=csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: status %d, body %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing CSV: %v", err)
+	}
+	want := [][]string{
+		{"ID", "Name", "Owner Name", "Owner Email", "Size Bytes", "Size", "Category", "Created", "Expires", "Download Count", "Downloads Remaining", "Teams", "Status", "Require Auth", "Password Protected"},
+		{"limited", "limited.txt", "Alice Andersson", "alice@example.com", "5", "5 B", "other", "2023-11-14T22:16:40Z", "2100-01-01T00:00:00Z", "3", "2", "", "active", "true", "true"},
+		{"old", "old.txt", "Alice Andersson", "alice@example.com", "5", "5 B", "other", "2023-11-14T22:15:00Z", "2023-11-14T23:13:20Z", "0", "unlimited", "", "expired", "false", "false"},
+		{"report", `report, "final".pdf`, "Alice Andersson", "alice@example.com", "2048", "2.0 KB", "document", "2023-11-14T22:13:20Z", "", "0", "unlimited", "Sales", "active", "false", "false"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV export:\n got %q\nwant %q", records, want)
+	}
+}
+
+func TestAdminFilesExportJSON(t *testing.T) {
+	s := newTestServer(t)
+	admin := createTestUser(t, "admin@example.com", models.UserLevelAdmin, 1000)
+	createExportTestFiles(t)
+
+	w := exportRequest(s, admin, "format=json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: status %d, body %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	var rows []database.FileExportRow
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatalf("parsing JSON: %v\n%s", err, w.Body.String())
+	}
+	want := []database.FileExportRow{
+		{Id: "limited", Name: "limited.txt", OwnerName: "Alice Andersson", OwnerEmail: "alice@example.com", SizeBytes: 5, Size: "5 B", Category: "other",
+			CreatedAt: "2023-11-14T22:16:40Z", ExpiresAt: "2100-01-01T00:00:00Z", DownloadCount: 3, DownloadsLeft: "2", Teams: []string{},
+			Status: "active", RequireAuth: true, PasswordLocked: true},
+		{Id: "old", Name: "old.txt", OwnerName: "Alice Andersson", OwnerEmail: "alice@example.com", SizeBytes: 5, Size: "5 B", Category: "other",
+			CreatedAt: "2023-11-14T22:15:00Z", ExpiresAt: "2023-11-14T23:13:20Z", DownloadsLeft: "unlimited", Teams: []string{},
+			Status: "expired"},
+		{Id: "report", Name: `report, "final".pdf`, OwnerName: "Alice Andersson", OwnerEmail: "alice@example.com", SizeBytes: 2048, Size: "2.0 KB", Category: "document",
+			CreatedAt: "2023-11-14T22:13:20Z", DownloadsLeft: "unlimited", Teams: []string{"Sales"},
+			Status: "active"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("JSON export:\n got %+v\nwant %+v", rows, want)
+	}
+}
+
+// The export honors the admin file list filters
+func TestAdminFilesExportFiltered(t *testing.T) {
+	s := newTestServer(t)
+	admin := createTestUser(t, "admin@example.com", models.UserLevelAdmin, 1000)
+	createExportTestFiles(t)
+
+	for query, wantIDs := range map[string][]string{
+		"status=expired":     {"old"},
+		"status=auth":        {"limited"},
+		"category=document":  {"report"},
+		"search=final":       {"report"},
+		"search=nobody-else": nil,
+	} {
+		w := exportRequest(s, admin, "format=json&"+query)
+		var rows []database.FileExportRow
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("%s: parsing JSON: %v", query, err)
+		}
+		var ids []string
+		for _, row := range rows {
+			ids = append(ids, row.Id)
+		}
+		if !reflect.DeepEqual(ids, wantIDs) {
+			t.Errorf("%s: exported %v, want %v", query, ids, wantIDs)
+		}
+	}
+}
+
+func TestAdminFilesExportUnknownFormat(t *testing.T) {
+	s := newTestServer(t)
+	admin := createTestUser(t, "admin@example.com", models.UserLevelAdmin, 1000)
+	if w := exportRequest(s, admin, "format=xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("format=xml: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
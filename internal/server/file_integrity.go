@@ -0,0 +1,272 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/cleanup"
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// integrityStatusLabels describes integrity scan results in the report
+var integrityStatusLabels = map[string]string{
+	database.IntegrityMismatch: "Checksum mismatch",
+	database.IntegrityMissing:  "Missing",
+	database.IntegrityError:    "Unreadable",
+}
+
+// handleAdminIntegrity shows the integrity report (GET) or verifies all files now (POST)
+func (s *Server) handleAdminIntegrity(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.renderAdminIntegrity(w, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if cleanup.IsIntegrityScanRunning() {
+		s.renderAdminIntegrity(w, "Error: An integrity scan is already running")
+		return
+	}
+
+	ipAddress := clientIP(r)
+	userAgent := r.UserAgent()
+	go func() {
+		result, err := cleanup.RunIntegrityScan(true, s.getPublicURL(), s.config.CompanyName)
+		if err != nil {
+			log.Printf("Error during integrity scan: %v", err)
+		}
+
+		details := map[string]interface{}{
+			"manual": true,
+		}
+		if result != nil {
+			details["verified"] = result.Verified
+			details["problems"] = len(result.Problems)
+		}
+		errorMessage := ""
+		if err != nil {
+			errorMessage = err.Error()
+		}
+
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(admin.Id),
+			UserEmail:  admin.Email,
+			Action:     database.ActionIntegrityScanRun,
+			EntityType: database.EntitySystem,
+			EntityID:   cleanup.JobIntegrityScan,
+			Details:    database.CreateAuditDetails(details),
+			IPAddress:  ipAddress,
+			UserAgent:  userAgent,
+			Success:    err == nil,
+			ErrorMsg:   errorMessage,
+		})
+	}()
+
+	s.renderAdminIntegrity(w, "Verifying all files in the background. Admins are notified of any problems found")
+}
+
+// renderAdminIntegrity renders the integrity report: scan settings, counts and the files that failed
+func (s *Server) renderAdminIntegrity(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	interval := database.DB.GetIntegrityScanInterval()
+	summary, err := database.DB.GetIntegritySummary()
+	if err != nil {
+		log.Printf("Failed to count file integrity results: %v", err)
+		summary = &database.IntegritySummary{}
+	}
+	problems, err := database.DB.GetFileIntegrityProblems()
+	if err != nil {
+		log.Printf("Failed to load file integrity problems: %v", err)
+	}
+
+	scheduleText := "Off — files are only verified when you start a scan here."
+	if interval > 0 {
+		scheduleText = fmt.Sprintf("Every file is verified every %d hours, reading at most %d MB/s.", int(interval.Hours()), database.DB.GetIntegrityScanRateMB())
+	}
+	lastRun := formatRetentionTime(cleanup.JobLastRun(cleanup.JobIntegrityScan), "Never")
+	oldestScan := formatRetentionTime(time.Unix(summary.OldestScan, 0), "")
+	if summary.OldestScan == 0 {
+		oldestScan = "—"
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>File Integrity - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        .card p { color: #555; font-size: 14px; line-height: 1.6; margin-bottom: 10px; }
+        .btn {
+            margin-top: 12px;
+            padding: 10px 20px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+            text-decoration: none;
+            display: inline-block;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+        }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        .stats { display: flex; gap: 40px; flex-wrap: wrap; margin: 10px 0; }
+        .stat {
+            font-size: 32px;
+            font-weight: 700;
+            color: ` + s.getPrimaryColor() + `;
+        }
+        .stat.bad { color: #c33; }
+        table { width: 100%; border-collapse: collapse; font-size: 14px; }
+        th, td { text-align: left; padding: 12px; border-bottom: 1px solid #eee; vertical-align: top; }
+        th { background: #fafafa; color: #555; font-weight: 600; }
+        .muted { color: #888; font-size: 12px; margin-top: 4px; word-break: break-all; }
+        .status { color: #c33; font-weight: 600; }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <h2>🛡️ File Integrity</h2>
+
+        <div class="info-box">
+            The integrity scan reads every stored file and compares it with the SHA-256 checksum recorded when it was uploaded, so files damaged or lost on disk are found before a recipient downloads them. Files without a checksum get one recorded on their first scan. Set how often files are verified and how fast they are read in <a href="/admin/settings#integrity_scan_interval_hours">Settings</a>.
+        </div>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	problemClass := "stat"
+	if summary.Problems > 0 {
+		problemClass = "stat bad"
+	}
+	html += `
+        <div class="card">
+            <h3>Scan Status</h3>
+            <p>` + scheduleText + `</p>
+            <p>Last scan: <strong>` + lastRun + `</strong> · Oldest verification: <strong>` + oldestScan + `</strong></p>
+            <div class="stats">
+                <div><div class="stat">` + fmt.Sprintf("%d", summary.Verified) + `</div><p>files verified</p></div>
+                <div><div class="` + problemClass + `">` + fmt.Sprintf("%d", summary.Problems) + `</div><p>corrupt or missing</p></div>
+                <div><div class="stat">` + fmt.Sprintf("%d", summary.Unverified) + `</div><p>not verified yet</p></div>
+            </div>`
+
+	if cleanup.IsIntegrityScanRunning() {
+		html += `
+            <p><strong>A scan is running right now.</strong> Reload this page to see the result.</p>`
+	} else {
+		html += `
+            <form method="POST" onsubmit="return confirm('Read and verify all ` + fmt.Sprintf("%d", summary.Total) + ` file(s) now?');">
+                <button type="submit" class="btn">Verify All Files Now</button>
+            </form>`
+	}
+
+	html += `
+        </div>
+
+        <div class="card">
+            <h3>Problems</h3>`
+
+	if len(problems) == 0 {
+		html += `
+            <p>No corrupt or missing files found.</p>`
+	} else {
+		html += `
+            <table>
+                <tr><th>File</th><th>Owner</th><th>Problem</th><th>Found</th></tr>`
+		for _, problem := range problems {
+			owner := fmt.Sprintf("User #%d", problem.UserID)
+			if user, err := database.DB.GetUserByID(problem.UserID); err == nil {
+				owner = user.Email
+			}
+			html += `
+                <tr>
+                    <td><strong>` + template.HTMLEscapeString(problem.FileName) + `</strong><div class="muted">` + template.HTMLEscapeString(problem.FileID) + ` · ` + database.FormatFileSize(problem.SizeBytes) + `</div></td>
+                    <td>` + template.HTMLEscapeString(owner) + `</td>
+                    <td><span class="status">` + template.HTMLEscapeString(integrityStatusLabels[problem.Status]) + `</span><div class="muted">` + template.HTMLEscapeString(problem.Detail) + `</div></td>
+                    <td>` + formatRetentionTime(time.Unix(problem.VerifiedAt, 0), "") + `</td>
+                </tr>`
+		}
+		html += `
+            </table>`
+	}
+
+	html += `
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
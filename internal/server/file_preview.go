@@ -0,0 +1,311 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// Kinds of inline preview shown on the splash page
+const (
+	previewImage = "image"
+	previewPDF   = "pdf"
+	previewText  = "text"
+)
+
+// previewTextMaxBytes is how much of a text file is shown in its preview
+const previewTextMaxBytes = 512 * 1024
+
+// previewImageTypes are the image types browsers show safely. SVG is left out, since it can
+// carry scripts.
+var previewImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/avif": true,
+	"image/bmp":  true,
+}
+
+// previewTextTypes are the non-text/* types that are shown as text
+var previewTextTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+	"application/x-yaml":     true,
+	"application/yaml":       true,
+	"application/toml":       true,
+	"application/sql":        true,
+}
+
+// previewTextExtensions are source and config files that browsers often upload without a
+// useful MIME type
+var previewTextExtensions = map[string]string{
+	".md":         "text/markdown",
+	".markdown":   "text/markdown",
+	".go":         "text/x-go",
+	".py":         "text/x-python",
+	".rs":         "text/x-rust",
+	".rb":         "text/x-ruby",
+	".java":       "text/x-java",
+	".kt":         "text/x-kotlin",
+	".c":          "text/x-c",
+	".h":          "text/x-c",
+	".cpp":        "text/x-c++",
+	".cs":         "text/x-csharp",
+	".php":        "text/x-php",
+	".ts":         "text/x-typescript",
+	".swift":      "text/x-swift",
+	".sh":         "application/x-sh",
+	".ps1":        "text/x-powershell",
+	".yml":        "application/yaml",
+	".yaml":       "application/yaml",
+	".toml":       "application/toml",
+	".ini":        "text/plain",
+	".conf":       "text/plain",
+	".log":        "text/plain",
+	".sql":        "application/sql",
+	".dockerfile": "text/plain",
+}
+
+// previewable returns the kind of inline preview a MIME type gets on the splash page, or ""
+// if it gets none
+func previewable(mimeType string) string {
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+	switch {
+	case previewImageTypes[mimeType]:
+		return previewImage
+	case mimeType == "application/pdf":
+		return previewPDF
+	case strings.HasPrefix(mimeType, "text/"), previewTextTypes[mimeType]:
+		return previewText
+	}
+	return ""
+}
+
+// previewMIME returns the MIME type a file is previewed as: the uploaded type, or one from
+// the file extension when the browser didn't send a useful one
+func previewMIME(fileInfo *database.FileInfo) string {
+	if previewable(fileInfo.ContentType) != "" {
+		return fileInfo.ContentType
+	}
+	ext := strings.ToLower(filepath.Ext(fileInfo.Name))
+	if mimeType, ok := previewTextExtensions[ext]; ok {
+		return mimeType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// canPreviewFile reports whether a file's content may be shown to this request without
+// downloading it: the same checks as a download, with the password already entered and, for
+// files that need a login, a signed-in user with access. A preview would show the content of
+// a burn-after-download file without using up its link, so those never get one.
+func (s *Server) canPreviewFile(r *http.Request, fileInfo *database.FileInfo) bool {
+	if fileInfo.BurnAfterDownload || fileExpiredReason(fileInfo) != "" {
+		return false
+	}
+	switch s.getPublicLinkApprovalStatus(fileInfo) {
+	case database.ApprovalStatusPending, database.ApprovalStatusRejected:
+		return false
+	}
+	if fileScheduleUnavailableMessage(fileInfo) != "" || fileScanUnavailableMessage(fileInfo) != "" {
+		return false
+	}
+	if until, _ := database.DB.GetFileDownloadBlock(fileInfo.Id); !until.IsZero() {
+		return false
+	}
+	if _, err := s.checkShareLink(r, fileInfo); err != nil {
+		return false
+	}
+	// Content that needs terms or a legal notice accepted first isn't shown before acceptance
+	if requiredDownloadTerms(fileInfo) != nil || requiresLegalNotice(fileInfo) {
+		return false
+	}
+
+	if fileInfo.FilePasswordPlain != "" {
+		cookie, err := r.Cookie("password_verified_" + fileInfo.Id)
+		if err != nil || cookie.Value != "true" {
+			return false
+		}
+	}
+	if fileInfo.RequireAuth {
+		user, err := s.getUserFromSession(r)
+		if err != nil || user == nil || !userHasFileAccess(fileInfo, user) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleFilePreview serves the content shown inline on the splash page (/preview/ID): the
+// converted copy of HEIC and similar images, or the file itself for previewable types. It is
+// always served inline with a restrictive Content-Security-Policy, and text is served as
+// plain text and cut off at previewTextMaxBytes.
+func (s *Server) handleFilePreview(w http.ResponseWriter, r *http.Request) {
+	fileID := strings.TrimPrefix(r.URL.Path, "/preview/")
+
+	fileInfo, err := database.DB.GetFileByID(fileID)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	switch fileExpiredReason(fileInfo) {
+	case database.FileExpiredByTime:
+		http.Error(w, "File has expired", http.StatusGone)
+		return
+	case database.FileExpiredByDownloads:
+		http.Error(w, "Download limit reached", http.StatusGone)
+		return
+	}
+	if !s.canPreviewFile(r, fileInfo) {
+		http.Error(w, "Preview not available", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	if path := s.getConvertedImage(fileInfo); path != "" {
+		setPreviewHeaders(w, previewImage, convertedContentType(path), convertedDownloadName(fileInfo.Name, path))
+		http.ServeFile(w, r, path)
+		return
+	}
+
+	mimeType := previewMIME(fileInfo)
+	kind := previewable(mimeType)
+	if kind == "" {
+		http.Error(w, "Preview not available", http.StatusNotFound)
+		return
+	}
+
+	content, err := storage.Files.Get(fileInfo.Id)
+	if err != nil {
+		log.Printf("Error: Could not open %s for preview: %v", fileInfo.Id, err)
+		http.Error(w, "File not found on disk", http.StatusNotFound)
+		return
+	}
+	defer content.Close()
+
+	if kind == previewText {
+		setPreviewHeaders(w, kind, "text/plain; charset=utf-8", fileInfo.Name)
+		if fileInfo.SizeBytes > previewTextMaxBytes {
+			w.Header().Set("X-Preview-Truncated", "true")
+		}
+		io.Copy(w, io.LimitReader(content, previewTextMaxBytes))
+		return
+	}
+
+	setPreviewHeaders(w, kind, mimeType, fileInfo.Name)
+	if f, ok := content.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", time.Unix(fileInfo.UploadDate, 0), f)
+		return
+	}
+	io.Copy(w, content)
+}
+
+// setPreviewHeaders sets the headers of a preview. Nothing in a preview may run scripts or load
+// other content; only PDFs are not sandboxed, since browsers won't show sandboxed PDFs.
+func setPreviewHeaders(w http.ResponseWriter, kind, contentType, fileName string) {
+	csp := "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; frame-ancestors 'self'"
+	if kind != previewPDF {
+		csp += "; sandbox"
+	}
+	w.Header().Set("Content-Security-Policy", csp)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", fileName))
+}
+
+// renderFilePreview returns the splash page block that shows a file's preview, or "" if the
+// file has none or the recipient may not see it yet
+func (s *Server) renderFilePreview(r *http.Request, fileInfo *database.FileInfo, label, truncatedNotice string) string {
+	if !s.canPreviewFile(r, fileInfo) {
+		return ""
+	}
+
+	kind := previewable(previewMIME(fileInfo))
+	if s.getConvertedImage(fileInfo) != "" {
+		kind = previewImage
+	}
+	src := "/preview/" + fileInfo.Id
+	alt := template.HTMLEscapeString(label)
+
+	switch kind {
+	case previewImage:
+		return `
+        <div style="margin: 25px 0; text-align: center;">
+            <img src="` + src + `" alt="` + alt + `" style="max-width: 100%; max-height: 400px; border-radius: 8px; box-shadow: 0 2px 8px rgba(0,0,0,0.15);">
+        </div>`
+	case previewPDF:
+		return `
+        <div style="margin: 25px 0;">
+            <iframe src="` + src + `" title="` + alt + `" style="width: 100%; height: 500px; border: 1px solid #e0e0e0; border-radius: 8px;"></iframe>
+        </div>`
+	case previewText:
+		language := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileInfo.Name)), ".")
+		return `
+        <div style="margin: 25px 0; text-align: left;">
+            <pre style="max-height: 400px; overflow: auto; background: #1e1e2e; color: #e0e0e0; padding: 15px; border-radius: 8px; font-size: 13px; line-height: 1.5;"><code id="textPreview" data-src="` + src + `" data-language="` + template.HTMLEscapeString(language) + `" aria-label="` + alt + `"></code></pre>
+            <p id="textPreviewTruncated" style="display: none; margin-top: 8px; color: #999; font-size: 13px;">` + template.HTMLEscapeString(truncatedNotice) + `</p>
+        </div>
+        <script>
+        (function() {
+            var code = document.getElementById('textPreview');
+            var escape = function(s) {
+                return s.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+            };
+            // A light highlight: comments, strings, numbers and common keywords, or headings
+            // and emphasis in markdown
+            var highlight = function(text, language) {
+                if (language === 'md' || language === 'markdown') {
+                    return text.split('\n').map(function(line) {
+                        if (/^#{1,6}\s/.test(line)) {
+                            return '<span style="color:#89b4fa;font-weight:bold">' + escape(line) + '</span>';
+                        }
+                        return escape(line)
+                            .replace(/(\*\*[^*]+\*\*)/g, '<strong>$1</strong>')
+                            .replace(/(` + "`" + `[^` + "`" + `]+` + "`" + `)/g, '<span style="color:#a6e3a1">$1</span>');
+                    }).join('\n');
+                }
+                var token = /(\/\/[^\n]*|#[^\n]*|\/\*[\s\S]*?\*\/)|("(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*')|(\b\d+(?:\.\d+)?\b)|(\b(?:func|function|def|class|return|if|else|elif|for|while|import|from|package|const|let|var|type|struct|interface|public|private|static|void|int|string|bool|true|false|null|nil|None|True|False|new|try|catch|except|finally|throw|raise|switch|case|break|continue|select|insert|update|delete|where)\b)/g;
+                var out = '', last = 0, match;
+                while ((match = token.exec(text)) !== null) {
+                    out += escape(text.slice(last, match.index));
+                    var color = match[1] ? '#7f849c' : match[2] ? '#a6e3a1' : match[3] ? '#fab387' : '#cba6f7';
+                    out += '<span style="color:' + color + '">' + escape(match[0]) + '</span>';
+                    last = token.lastIndex;
+                }
+                return out + escape(text.slice(last));
+            };
+            fetch(code.dataset.src, { credentials: 'same-origin' }).then(function(response) {
+                if (!response.ok) { throw new Error(response.status); }
+                if (response.headers.get('X-Preview-Truncated') === 'true') {
+                    document.getElementById('textPreviewTruncated').style.display = 'block';
+                }
+                return response.text();
+            }).then(function(text) {
+                code.innerHTML = highlight(text, code.dataset.language);
+            }).catch(function() {
+                code.closest('div').style.display = 'none';
+            });
+        })();
+        </script>`
+	}
+	return ""
+}
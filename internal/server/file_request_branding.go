@@ -0,0 +1,130 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// Length limits of the text an upload request owner can put on the upload page
+const (
+	maxFileRequestTitleLength     = 200
+	maxFileRequestMessageLength   = 2000
+	maxFileRequestBrandNameLength = 100
+)
+
+// fileRequestBranding is how an upload request's public pages are branded
+type fileRequestBranding struct {
+	Name           string // shown in place of the company name
+	PrimaryColor   string
+	SecondaryColor string
+	TeamName       string // "" if the request isn't made on behalf of a team
+}
+
+// fileRequestBranding returns the branding of an upload request's pages. The name falls back to
+// the team's name and then the company name, and the color to the server's branding.
+func (s *Server) fileRequestBranding(fileRequest *models.FileRequest) fileRequestBranding {
+	branding := fileRequestBranding{
+		Name:           fileRequest.BrandName,
+		PrimaryColor:   s.getPrimaryColor(),
+		SecondaryColor: s.getSecondaryColor(),
+	}
+
+	if fileRequest.TeamId > 0 {
+		if team, err := database.DB.GetTeamByID(fileRequest.TeamId); err == nil {
+			branding.TeamName = team.Name
+		}
+	}
+
+	if branding.Name == "" {
+		branding.Name = branding.TeamName
+	}
+	if branding.Name == "" {
+		branding.Name = s.config.CompanyName
+	}
+
+	// Stored colors were normalized when the request was created, but check again since they
+	// end up in a stylesheet
+	if color, err := normalizeColor(fileRequest.BrandColor); err == nil && fileRequest.BrandColor != "" {
+		branding.PrimaryColor = color
+		branding.SecondaryColor = color
+	}
+
+	return branding
+}
+
+// parseFileRequestForm reads and validates the title, instructions and branding of a new
+// upload request. The returned error message is meant for the user.
+func parseFileRequestForm(r *http.Request, user *models.User) (*models.FileRequest, string) {
+	fileRequest := &models.FileRequest{
+		UserId:    user.Id,
+		Title:     strings.TrimSpace(r.FormValue("title")),
+		Message:   strings.TrimSpace(r.FormValue("message")),
+		BrandName: strings.TrimSpace(r.FormValue("brand_name")),
+	}
+
+	if fileRequest.Title == "" {
+		return nil, "Title is required"
+	}
+	if utf8.RuneCountInString(fileRequest.Title) > maxFileRequestTitleLength {
+		return nil, fmt.Sprintf("Title can be at most %d characters", maxFileRequestTitleLength)
+	}
+	if utf8.RuneCountInString(fileRequest.Message) > maxFileRequestMessageLength {
+		return nil, fmt.Sprintf("Instructions can be at most %d characters", maxFileRequestMessageLength)
+	}
+	if utf8.RuneCountInString(fileRequest.BrandName) > maxFileRequestBrandNameLength {
+		return nil, fmt.Sprintf("Brand name can be at most %d characters", maxFileRequestBrandNameLength)
+	}
+
+	if brandColor := strings.TrimSpace(r.FormValue("brand_color")); brandColor != "" {
+		color, err := normalizeColor(brandColor)
+		if err != nil {
+			return nil, "Brand color: " + err.Error()
+		}
+		fileRequest.BrandColor = color
+	}
+
+	if teamValue := r.FormValue("team_id"); teamValue != "" && teamValue != "0" {
+		teamId, err := strconv.Atoi(teamValue)
+		if err != nil {
+			return nil, "Invalid team"
+		}
+		isMember, err := database.DB.IsTeamMember(teamId, user.Id)
+		if err != nil || !isMember {
+			return nil, "You can only brand upload requests with teams you are a member of"
+		}
+		fileRequest.TeamId = teamId
+	}
+
+	return fileRequest, ""
+}
+
+// fileRequestTeamOptionsHTML returns the <option>s of the teams a user can brand an upload
+// request with
+func fileRequestTeamOptionsHTML(userId int) string {
+	teams, err := database.DB.GetTeamsByUser(userId)
+	if err != nil {
+		return ""
+	}
+	options := ""
+	for _, team := range teams {
+		options += fmt.Sprintf(`<option value="%d">%s</option>`, team.Id, html.EscapeString(team.Name))
+	}
+	return options
+}
+
+// fileRequestMessageHTML escapes an upload request's instructions and keeps their line breaks
+func fileRequestMessageHTML(message string) string {
+	return strings.ReplaceAll(html.EscapeString(message), "\n", "<br>")
+}
@@ -0,0 +1,40 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// checkRequestedSlug normalizes a custom slug entered at upload or edit and checks that it
+// is valid and not used by a file other than fileID ("" for a new upload). An empty slug is
+// valid and removes the file's slug.
+func checkRequestedSlug(slug, fileID string) (string, error) {
+	slug = database.NormalizeFileSlug(slug)
+	if slug == "" {
+		return "", nil
+	}
+	if err := database.ValidateFileSlug(slug); err != nil {
+		return "", err
+	}
+	if err := database.DB.CheckFileSlugAvailable(slug, fileID); err != nil {
+		if errors.Is(err, database.ErrSlugTaken) {
+			return "", errors.New("the link name \"" + slug + "\" is already used by another file, please choose another one")
+		}
+		return "", err
+	}
+	return slug, nil
+}
+
+// fileLinkID returns what a file's share links are built from: its custom slug, or its ID
+func fileLinkID(fileID string) string {
+	if slug := database.DB.GetFileSlug(fileID); slug != "" {
+		return slug
+	}
+	return fileID
+}
@@ -0,0 +1,559 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// fileVersionLocks serializes content changes per file, so two uploads of a new version can't
+// archive the same content twice
+var fileVersionLocks sync.Map
+
+func lockFileVersions(fileID string) func() {
+	mu, _ := fileVersionLocks.LoadOrStore(fileID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// copyStoredContent copies a stored file to another storage ID
+func copyStoredContent(fromID, toID string) error {
+	src, err := storage.Files.Get(fromID)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return storage.Files.Put(toID, src)
+}
+
+// replaceFileContent makes content the new current version of a file, keeping the file's ID and
+// so its share links. The content that was current is kept as an earlier version. The new
+// content's metadata is removed in the background if stripMetadata is set, as for uploads.
+func (s *Server) replaceFileContent(user *models.User, fileInfo *database.FileInfo, content io.Reader, name, contentType string, stripMetadata bool) (int, error) {
+	unlock := lockFileVersions(fileInfo.Id)
+	defer unlock()
+
+	versions, err := database.DB.GetFileVersions(fileInfo.Id)
+	if err != nil {
+		return 0, err
+	}
+	previous := versions[0]
+	previous.StorageId = database.VersionStorageID(fileInfo.Id, previous.Version)
+
+	// Keep the current content before it is replaced
+	if err := copyStoredContent(fileInfo.Id, previous.StorageId); err != nil {
+		return 0, fmt.Errorf("could not keep the current version: %w", err)
+	}
+
+	hasher := newUploadHasher()
+	counter := &countingReader{r: hasher.tee(content)}
+	if err := storage.Files.Put(fileInfo.Id, counter); err != nil {
+		storage.Files.Delete(previous.StorageId)
+		return 0, fmt.Errorf("could not store the new version: %w", err)
+	}
+
+	next := &database.FileVersion{
+		Name:        name,
+		SizeBytes:   counter.n,
+		SHA1:        hasher.SHA1(),
+		SHA256:      hasher.SHA256(),
+		ContentType: contentType,
+		UploadedBy:  user.Id,
+	}
+	if metadataStrippingApplies(name, next.SizeBytes, stripMetadata) {
+		next.SHA256 = ""
+	}
+	if err := database.DB.ReplaceFileVersion(previous, next); err != nil {
+		// Put the previous content back, so the file matches its metadata again
+		if restoreErr := copyStoredContent(previous.StorageId, fileInfo.Id); restoreErr != nil {
+			log.Printf("Error: Could not put back version %d of %s: %v", previous.Version, fileInfo.Id, restoreErr)
+		} else {
+			storage.Files.Delete(previous.StorageId)
+		}
+		return 0, err
+	}
+
+	// Previews were made from the previous content
+	s.removeConvertedImages(fileInfo.Id)
+	s.queueImageConversion(fileInfo.Id, name, next.SizeBytes)
+	s.queueVirusScan(fileInfo.Id)
+	s.queueMetadataStripping(fileInfo.Id, name, next.SizeBytes, stripMetadata)
+
+	return previous.Version + 1, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// fileForVersions loads the file of a versions request and checks that the user may manage it:
+// its owner or an admin
+func (s *Server) fileForVersions(w http.ResponseWriter, user *models.User, fileID string) (*database.FileInfo, bool) {
+	fileInfo, err := database.DB.GetFileByID(fileID)
+	if err != nil || fileInfo.DeletedAt > 0 {
+		s.sendError(w, http.StatusNotFound, "File not found")
+		return nil, false
+	}
+	if fileInfo.UserId != user.Id && !user.IsAdmin() {
+		s.sendError(w, http.StatusForbidden, "Not authorized to change this file")
+		return nil, false
+	}
+	return fileInfo, true
+}
+
+// handleFileVersionUpload replaces a file's content with an uploaded new version. The share
+// links stay the same and serve the new version from now on.
+func (s *Server) handleFileVersionUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	if message := uploadWindowClosedMessage(user); message != "" {
+		s.sendError(w, http.StatusForbidden, message)
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
+		return
+	}
+
+	fileInfo, ok := s.fileForVersions(w, user, r.FormValue("file_id"))
+	if !ok {
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "No file uploaded")
+		return
+	}
+	defer file.Close()
+
+	if err := checkUploadedFileType(header.Filename, file); err != nil {
+		s.sendError(w, uploadFileTypeStatus(err), err.Error())
+		return
+	}
+
+	// Only the growth counts toward the owner's quota; earlier versions don't count
+	owner, err := database.DB.GetUserByID(fileInfo.UserId)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "File owner not found")
+		return
+	}
+	if growthMB := database.StorageMB(header.Size) - database.StorageMB(fileInfo.SizeBytes); growthMB > 0 && !owner.HasStorageSpace(growthMB) {
+		s.sendError(w, http.StatusRequestEntityTooLarge, "Insufficient storage quota")
+		return
+	}
+
+	// Teams the file is shared with must have room for the growth too
+	if growth := header.Size - fileInfo.SizeBytes; growth > 0 {
+		var teamIds []int
+		if teams, err := database.DB.GetFileTeams(fileInfo.Id); err == nil {
+			for _, team := range teams {
+				teamIds = append(teamIds, team.Id)
+			}
+		}
+		if err := checkUploadTeamQuotas(owner, teamIds, growth); err != nil {
+			s.sendError(w, http.StatusRequestEntityTooLarge, "Not enough team storage space: "+strings.TrimPrefix(err.Error(), database.ErrTeamQuotaExceeded.Error()+": "))
+			return
+		}
+	}
+
+	// Approved content can't be swapped: the new version needs approval like an upload, and
+	// public links wait for it from before the content changes
+	pendingApproval, err := s.requestUploadApprovalIfNeeded(user, fileInfo, r)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "The new version was not uploaded: "+err.Error())
+		return
+	}
+
+	version, err := s.replaceFileContent(user, fileInfo, file, header.Filename, header.Header.Get("Content-Type"), wantsMetadataStripping(r.FormValue("strip_metadata")))
+	if err != nil {
+		log.Printf("❌ New version of %s failed: %v", fileInfo.Id, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to upload the new version: "+err.Error())
+		return
+	}
+
+	log.Printf("✅ Version %d of '%s' uploaded by %s (File ID: %s)", version, header.Filename, user.Email, fileInfo.Id)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionFileVersionUploaded,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":     header.Filename,
+			"previous_name": fileInfo.Name,
+			"version":       version,
+			"size":          header.Size,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":          true,
+		"version":          version,
+		"pending_approval": pendingApproval,
+	})
+}
+
+// handleFileVersionRestore makes an earlier version current again. It becomes a new version,
+// so the version it replaces can be restored as well.
+func (s *Server) handleFileVersionRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	fileInfo, ok := s.fileForVersions(w, user, r.FormValue("file_id"))
+	if !ok {
+		return
+	}
+
+	restoreVersion, err := strconv.Atoi(r.FormValue("version"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid version")
+		return
+	}
+	earlier, err := database.DB.GetEarlierFileVersion(fileInfo.Id, restoreVersion)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	content, err := storage.Files.Get(earlier.StorageId)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.sendError(w, http.StatusGone, "The content of this version was already deleted")
+		} else {
+			s.sendError(w, http.StatusInternalServerError, "Failed to read the version")
+		}
+		return
+	}
+	defer content.Close()
+
+	// A restored version is approved again like a new one
+	if _, err := s.requestUploadApprovalIfNeeded(user, fileInfo, r); err != nil {
+		s.sendError(w, http.StatusInternalServerError, "The version was not restored: "+err.Error())
+		return
+	}
+
+	version, err := s.replaceFileContent(user, fileInfo, content, earlier.Name, earlier.ContentType, wantsMetadataStripping(""))
+	if err != nil {
+		log.Printf("❌ Restoring version %d of %s failed: %v", restoreVersion, fileInfo.Id, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to restore the version: "+err.Error())
+		return
+	}
+
+	log.Printf("File %s: version %d restored as version %d by %s", fileInfo.Id, restoreVersion, version, user.Email)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionFileVersionRestored,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":        earlier.Name,
+			"restored_version": restoreVersion,
+			"version":          version,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"version": version,
+	})
+}
+
+// handleFileVersions shows the versions of a file to its owner or an admin, with a form to
+// upload a new version and buttons to restore earlier ones
+func (s *Server) handleFileVersions(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	fileInfo, ok := s.fileForVersions(w, user, r.URL.Query().Get("file_id"))
+	if !ok {
+		return
+	}
+
+	versions, err := database.DB.GetFileVersions(fileInfo.Id)
+	if err != nil {
+		log.Printf("Failed to load versions of %s: %v", fileInfo.Id, err)
+		http.Error(w, "Failed to load versions", http.StatusInternalServerError)
+		return
+	}
+
+	s.renderFileVersions(w, user, fileInfo, versions)
+}
+
+// renderFileVersions renders the versions page of a file
+func (s *Server) renderFileVersions(w http.ResponseWriter, user *models.User, fileInfo *database.FileInfo, versions []*database.FileVersion) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	retentionDays := database.DB.GetConfigInt("trash_retention_days", s.config.TrashRetentionDays)
+	backURL := "/dashboard"
+	if user.IsAdmin() && fileInfo.UserId != user.Id {
+		backURL = "/admin/files"
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Versions - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1400px;
+            margin: 40px auto;
+            padding: 0 20px;
+            padding-top: 40px;
+        }
+        h2 {
+            margin: 30px 0 20px 0;
+            color: #333;
+            word-wrap: break-word;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 1px 3px rgba(0,0,0,0.08);
+            padding: 20px 24px;
+            margin-bottom: 20px;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        th, td {
+            text-align: left;
+            padding: 12px 8px;
+            border-bottom: 1px solid #eee;
+            font-size: 14px;
+            vertical-align: top;
+        }
+        th {
+            color: #666;
+            font-weight: 600;
+        }
+        .checksum {
+            font-family: monospace;
+            font-size: 12px;
+            color: #555;
+            word-break: break-all;
+        }
+        .btn {
+            padding: 8px 16px;
+            border: none;
+            border-radius: 6px;
+            font-size: 14px;
+            font-weight: 600;
+            cursor: pointer;
+            white-space: nowrap;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+        }
+        .badge {
+            background: #4caf50;
+            color: white;
+            padding: 2px 8px;
+            border-radius: 10px;
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getHeaderHTML(user, user.IsAdmin()) + `
+    <div class="container">
+        <h2>🕘 Versions of ` + template.HTMLEscapeString(fileInfo.Name) + `</h2>
+
+        <div class="info-box">
+            Uploading a new version keeps the file's share links, which serve the new version from now on. Earlier versions are kept for ` + strconv.Itoa(retentionDays) + ` days, like files in trash, and can be restored until then. Downloads are counted for the file as a whole and for the version that was current at the time.
+        </div>
+
+        <div class="card">
+            <form id="versionForm" style="display: flex; gap: 12px; align-items: center; flex-wrap: wrap;">
+                <input type="file" id="versionFile" required>
+                <button type="submit" class="btn">⬆️ Upload new version</button>
+                <span id="versionStatus" style="color: #666; font-size: 14px;"></span>
+            </form>
+        </div>
+
+        <div class="card">
+            <table>
+                <thead>
+                    <tr><th>Version</th><th>Name</th><th>Size</th><th>Checksum</th><th>Uploaded</th><th>Downloads</th><th></th></tr>
+                </thead>
+                <tbody>`
+
+	for i, v := range versions {
+		uploader := "Unknown"
+		if u, err := database.DB.GetUserByID(v.UploadedBy); err == nil {
+			uploader = u.Name
+		}
+		checksum := "SHA1: " + template.HTMLEscapeString(v.SHA1)
+		if v.SHA256 != "" {
+			checksum += "<br>SHA-256: " + template.HTMLEscapeString(v.SHA256)
+		}
+
+		action := `<span class="badge">Current</span>`
+		if i > 0 {
+			purgeAt := time.Unix(v.ReplacedAt, 0).AddDate(0, 0, retentionDays)
+			action = fmt.Sprintf(`<button class="btn" onclick="restoreVersion(%d)">↩️ Restore</button>
+                        <div style="color: #999; font-size: 12px; margin-top: 4px;">Kept until %s</div>`,
+				v.Version, purgeAt.Format("2006-01-02"))
+		}
+
+		html += fmt.Sprintf(`
+                    <tr>
+                        <td><strong>v%d</strong></td>
+                        <td>%s</td>
+                        <td>%s</td>
+                        <td class="checksum">%s</td>
+                        <td>%s<br><span style="color: #999;">%s</span></td>
+                        <td>%d</td>
+                        <td>%s</td>
+                    </tr>`,
+			v.Version,
+			template.HTMLEscapeString(v.Name),
+			database.FormatFileSize(v.SizeBytes),
+			checksum,
+			formatTimestamp(v.UploadedAt),
+			template.HTMLEscapeString(uploader),
+			v.Downloads,
+			action)
+	}
+
+	html += `
+                </tbody>
+            </table>
+            <p style="margin-top: 12px; color: #666; font-size: 14px;">Total downloads across versions: ` + strconv.Itoa(fileInfo.DownloadCount) + `</p>
+        </div>
+
+        <p><a href="` + backURL + `">← Back</a></p>
+    </div>
+
+    <script>
+        const fileId = '` + template.JSEscapeString(fileInfo.Id) + `';
+
+        document.getElementById('versionForm').addEventListener('submit', async function(e) {
+            e.preventDefault();
+            const input = document.getElementById('versionFile');
+            if (!input.files.length) return;
+
+            const formData = new FormData();
+            formData.append('file_id', fileId);
+            formData.append('file', input.files[0]);
+            document.getElementById('versionStatus').textContent = 'Uploading...';
+
+            try {
+                const response = await fetch('/file/versions/upload', { method: 'POST', body: formData });
+                const result = await response.json();
+                if (response.ok) {
+                    if (result.pending_approval) {
+                        alert('The new version is awaiting approval. Its links work again once it is approved.');
+                    }
+                    location.reload();
+                } else {
+                    document.getElementById('versionStatus').textContent = '';
+                    alert('Failed: ' + (result.error || 'Unknown error'));
+                }
+            } catch (error) {
+                document.getElementById('versionStatus').textContent = '';
+                alert('Failed: ' + error.message);
+            }
+        });
+
+        async function restoreVersion(version) {
+            if (!confirm('Restore version ' + version + '? The current version is kept and can be restored later.')) {
+                return;
+            }
+            try {
+                const response = await fetch('/file/versions/restore', {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+                    body: 'file_id=' + encodeURIComponent(fileId) + '&version=' + version
+                });
+                if (response.ok) {
+                    location.reload();
+                } else {
+                    const result = await response.json();
+                    alert('Failed: ' + (result.error || 'Unknown error'));
+                }
+            } catch (error) {
+                alert('Failed: ' + error.message);
+            }
+        }
+    </script>
+    <div style="text-align:center; font-size: 0.8em; margin-top: 2em; padding: 1em; color:#777;">
+        Powered by WulfVault © Ulf Holmström – AGPL-3.0
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
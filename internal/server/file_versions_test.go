@@ -0,0 +1,104 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// uploadVersion uploads content as the new version of fileID
+func uploadVersion(t *testing.T, s *Server, user *models.User, fileID, name string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("file_id", fileID)
+	part, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write(content)
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/file/versions/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return serve(s.handleFileVersionUpload, r.WithContext(contextWithUser(r.Context(), user)))
+}
+
+// storedContent returns the current content of a stored file
+func storedContent(t *testing.T, fileID string) []byte {
+	t.Helper()
+	f, err := storage.Files.Get(fileID)
+	if err != nil {
+		t.Fatalf("reading %s: %v", fileID, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("reading %s: %v", fileID, err)
+	}
+	return data
+}
+
+// A new version must fit in the quota of the teams the file is shared with
+func TestFileVersionUploadChecksTeamQuota(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	original := bytes.Repeat([]byte("a"), 512*1024)
+	createTestFile(t, owner, "shared", original, nil)
+
+	team := &models.Team{Name: "Sales", CreatedBy: owner.Id, StorageQuotaMB: 1, IsActive: true}
+	if err := database.DB.CreateTeam(team); err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	if err := database.DB.ShareFileToTeam("shared", team.Id, owner.Id); err != nil {
+		t.Fatalf("ShareFileToTeam: %v", err)
+	}
+
+	w := uploadVersion(t, s, owner, "shared", "shared.txt", bytes.Repeat([]byte("b"), 2*1024*1024))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("version over the team quota: status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if !bytes.Equal(storedContent(t, "shared"), original) {
+		t.Errorf("content replaced although the team has no room for it")
+	}
+}
+
+// Approved content can't be swapped for unapproved content through a new version
+func TestFileVersionUploadRequestsApproval(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "approved", []byte("approved content"), nil)
+	if err := database.DB.SetConfigValue("upload_approval_required", "true"); err != nil {
+		t.Fatalf("SetConfigValue: %v", err)
+	}
+
+	w := uploadVersion(t, s, owner, "approved", "approved.txt", []byte("swapped content"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("version upload: status %d, body %q", w.Code, w.Body.String())
+	}
+	var resp struct {
+		PendingApproval bool `json:"pending_approval"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || !resp.PendingApproval {
+		t.Errorf("response %q does not report the pending approval", w.Body.String())
+	}
+	if status := database.DB.GetFileApprovalStatus("approved"); status != database.ApprovalStatusPending {
+		t.Errorf("approval status %q, want %q", status, database.ApprovalStatusPending)
+	}
+	if w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/approved", nil)); w.Code != http.StatusForbidden {
+		t.Errorf("download of the new version before approval: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
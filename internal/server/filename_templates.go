@@ -0,0 +1,181 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+const (
+	// maxFilenameTemplateLength is the longest filename template that can be saved
+	maxFilenameTemplateLength = 200
+	// maxDownloadNameLength is the longest rendered download name, in bytes
+	maxDownloadNameLength = 200
+)
+
+// filenameTemplateFields documents the fields a filename template can use. RecipientEmail and
+// RecipientName are only set when the downloader is known.
+var filenameTemplateFields = []string{"FileName", "BaseName", "Ext", "Date", "Time", "RecipientEmail", "RecipientName"}
+
+var filenameTemplateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// errDownloadNameTooLong stops templates that would render an overly long name, e.g. with a loop
+var errDownloadNameTooLong = errors.New("rendered file name is too long")
+
+// limitedNameBuffer collects template output up to a fixed size
+type limitedNameBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedNameBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxDownloadNameLength*4 {
+		return 0, errDownloadNameTooLong
+	}
+	return b.Buffer.Write(p)
+}
+
+// filenameTemplateData returns the values available to a filename template. Recipient fields are
+// left out when the recipient is unknown, so templates using them fall back to the stored name.
+func filenameTemplateData(fileInfo *database.FileInfo, recipientEmail, recipientName string, now time.Time) map[string]string {
+	ext := filepath.Ext(fileInfo.Name)
+	now = now.In(database.ServerLocation())
+	data := map[string]string{
+		"FileName": fileInfo.Name,
+		"BaseName": strings.TrimSuffix(fileInfo.Name, ext),
+		"Ext":      ext,
+		"Date":     now.Format("2006-01-02"),
+		"Time":     now.Format("1504"),
+	}
+	if recipientEmail != "" {
+		data["RecipientEmail"] = recipientEmail
+	}
+	if recipientName != "" {
+		data["RecipientName"] = recipientName
+	}
+	return data
+}
+
+// renderFilenameTemplate renders a filename template and returns a sanitized file name.
+// Missing data (e.g. no known recipient) is an error, so callers can fall back to the stored name.
+func renderFilenameTemplate(tmpl string, data map[string]string, storedName string) (string, error) {
+	t, err := template.New("filename").Funcs(filenameTemplateFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf limitedNameBuffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	name := sanitizeDownloadName(buf.String())
+	if name == "" {
+		return "", errors.New("the template renders an empty file name")
+	}
+
+	// Keep the stored extension so the file still opens with the right program
+	if ext := filepath.Ext(storedName); ext != "" && !strings.EqualFold(filepath.Ext(name), ext) {
+		name += ext
+	}
+	return truncateDownloadName(name), nil
+}
+
+// sanitizeDownloadName makes a rendered name safe to use as a file name and in a
+// Content-Disposition header: path separators, quotes, characters Windows doesn't allow
+// in file names and control characters are replaced, and leading or trailing dots and
+// spaces are removed
+func sanitizeDownloadName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			continue
+		case strings.ContainsRune(`/\:*?"<>|;`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Trim(b.String(), ". ")
+}
+
+// truncateDownloadName shortens a name to maxDownloadNameLength bytes, keeping its extension
+func truncateDownloadName(name string) string {
+	if len(name) <= maxDownloadNameLength {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > 20 {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	limit := maxDownloadNameLength - len(ext)
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+	return base[:limit] + ext
+}
+
+// validateFilenameTemplate checks a filename template before it is saved: it must parse, use
+// only known fields and render a usable name when all fields are set
+func validateFilenameTemplate(tmpl string, fileInfo *database.FileInfo) error {
+	if tmpl == "" {
+		return nil
+	}
+	if len(tmpl) > maxFilenameTemplateLength {
+		return fmt.Errorf("the template can be at most %d characters", maxFilenameTemplateLength)
+	}
+
+	data := filenameTemplateData(fileInfo, "recipient@example.com", "Recipient Name", time.Now())
+	if _, err := renderFilenameTemplate(tmpl, data, fileInfo.Name); err != nil {
+		if strings.Contains(err.Error(), "map has no entry for key") {
+			return fmt.Errorf("%v; available fields are %s", err, "{{."+strings.Join(filenameTemplateFields, "}}, {{.")+"}}")
+		}
+		return err
+	}
+	return nil
+}
+
+// downloadFileName returns the name a file is downloaded as: the file's filename template
+// rendered for the downloader when it has one and the data it needs is known, otherwise the stored name
+func (s *Server) downloadFileName(r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount) string {
+	tmpl := database.DB.GetFileFilenameTemplate(fileInfo.Id)
+	if tmpl == "" {
+		return fileInfo.Name
+	}
+
+	var recipientEmail, recipientName string
+	if account != nil {
+		recipientEmail, recipientName = account.Email, account.Name
+	} else if user, err := s.getUserFromSession(r); err == nil && user != nil {
+		recipientEmail, recipientName = user.Email, user.Name
+	}
+
+	name, err := renderFilenameTemplate(tmpl, filenameTemplateData(fileInfo, recipientEmail, recipientName, time.Now()), fileInfo.Name)
+	if err != nil {
+		if recipientEmail != "" {
+			log.Printf("Warning: Could not render filename template of %s: %v", fileInfo.Id, err)
+		}
+		return fileInfo.Name
+	}
+	return name
+}
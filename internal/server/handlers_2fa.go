@@ -6,9 +6,14 @@
 package server
 
 import (
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/auth"
@@ -16,7 +21,78 @@ import (
 	"github.com/Frimurare/WulfVault/internal/totp"
 )
 
-// handle2FASetup initiates 2FA setup by generating a secret and QR code
+// totpPendingTimeout is how long a user has to enter their code, both when logging in and
+// when setting up 2FA
+const totpPendingTimeout = 5 * time.Minute
+
+// totpPendingLogin is a login whose password was checked and that waits for the 2FA code. The
+// browser only holds the random key to it, so it can't name a different user.
+type totpPendingLogin struct {
+	UserID     int
+	RememberMe bool
+	Redirect   string
+	ExpiresAt  time.Time
+}
+
+// totpPendingSetup is a secret shown to a user that becomes active once they enter a code
+// from it
+type totpPendingSetup struct {
+	Secret      string
+	BackupCodes []string
+	ExpiresAt   time.Time
+}
+
+var (
+	totpPendingLogins = make(map[string]*totpPendingLogin)
+	totpPendingSetups = make(map[int]*totpPendingSetup) // by user ID
+	totpPendingMu     sync.Mutex
+)
+
+// pruneTOTPPendingLocked removes expired logins and setups. totpPendingMu must be held.
+func pruneTOTPPendingLocked(now time.Time) {
+	for key, pending := range totpPendingLogins {
+		if now.After(pending.ExpiresAt) {
+			delete(totpPendingLogins, key)
+		}
+	}
+	for userID, pending := range totpPendingSetups {
+		if now.After(pending.ExpiresAt) {
+			delete(totpPendingSetups, userID)
+		}
+	}
+}
+
+// addTOTPPendingLogin stores a login waiting for its code and returns the random key the
+// browser gets in the totp_pending cookie
+func addTOTPPendingLogin(pending *totpPendingLogin) (string, error) {
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(keyBytes)
+
+	totpPendingMu.Lock()
+	defer totpPendingMu.Unlock()
+	pruneTOTPPendingLocked(time.Now())
+	totpPendingLogins[key] = pending
+	return key, nil
+}
+
+// getTOTPPendingLogin returns the login waiting for a code in this browser, or nil
+func getTOTPPendingLogin(r *http.Request) (string, *totpPendingLogin) {
+	cookie, err := r.Cookie("totp_pending")
+	if err != nil || cookie.Value == "" {
+		return "", nil
+	}
+
+	totpPendingMu.Lock()
+	defer totpPendingMu.Unlock()
+	pruneTOTPPendingLocked(time.Now())
+	return cookie.Value, totpPendingLogins[cookie.Value]
+}
+
+// handle2FASetup initiates 2FA setup by generating a secret and QR code. The user must enter
+// their password first.
 func (s *Server) handle2FASetup(w http.ResponseWriter, r *http.Request) {
 	user, err := s.getUserFromSession(r)
 	if err != nil {
@@ -25,6 +101,15 @@ func (s *Server) handle2FASetup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == http.MethodPost {
+		if _, err := auth.AuthenticateUser(user.Email, r.FormValue("password")); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   "Invalid password",
+			})
+			return
+		}
+
 		// Generate new TOTP secret
 		key, err := totp.GenerateSecret(user.Email, s.config.CompanyName)
 		if err != nil {
@@ -46,32 +131,24 @@ func (s *Server) handle2FASetup(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Store secret temporarily in session (not in database yet)
-		// We'll only save it after the user verifies it works
-		sessionData := map[string]interface{}{
-			"totp_secret":   key.Secret(),
-			"backup_codes":  backupCodes,
-			"user_id":       user.Id,
-			"setup_started": time.Now().Unix(),
+		// Keep the secret on the server until the user verifies it works; only then is it
+		// stored (encrypted) in the database
+		now := time.Now()
+		totpPendingMu.Lock()
+		pruneTOTPPendingLocked(now)
+		totpPendingSetups[user.Id] = &totpPendingSetup{
+			Secret:      key.Secret(),
+			BackupCodes: backupCodes,
+			ExpiresAt:   now.Add(totpPendingTimeout),
 		}
+		totpPendingMu.Unlock()
 
-		sessionJSON, _ := json.Marshal(sessionData)
-
-		// Store in a temporary setup cookie (5 minutes expiry)
-		http.SetCookie(w, &http.Cookie{
-			Name:     "totp_setup",
-			Value:    base64.StdEncoding.EncodeToString(sessionJSON),
-			Path:     "/",
-			Expires:  time.Now().Add(5 * time.Minute),
-			HttpOnly: true,
-			SameSite: http.SameSiteStrictMode,
-		})
-
-		// Return QR code and backup codes as JSON
+		// Return QR code, otpauth:// URI and backup codes as JSON
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success":      true,
 			"qr_code":      base64.StdEncoding.EncodeToString(qrCode),
+			"otpauth_url":  key.URL(),
 			"secret":       key.Secret(),
 			"backup_codes": backupCodes,
 		})
@@ -94,28 +171,17 @@ func (s *Server) handle2FAEnable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get setup data from cookie
-	cookie, err := r.Cookie("totp_setup")
-	if err != nil {
-		http.Error(w, "Setup session not found", http.StatusBadRequest)
-		return
-	}
-
-	sessionData := make(map[string]interface{})
-	decodedData, err := base64.StdEncoding.DecodeString(cookie.Value)
-	if err != nil {
-		http.Error(w, "Invalid setup session", http.StatusBadRequest)
-		return
-	}
-
-	if err := json.Unmarshal(decodedData, &sessionData); err != nil {
-		http.Error(w, "Invalid setup session", http.StatusBadRequest)
-		return
-	}
-
-	secret, ok := sessionData["totp_secret"].(string)
-	if !ok {
-		http.Error(w, "Invalid setup session", http.StatusBadRequest)
+	// Get the secret generated by handle2FASetup
+	totpPendingMu.Lock()
+	pruneTOTPPendingLocked(time.Now())
+	setup := totpPendingSetups[user.Id]
+	totpPendingMu.Unlock()
+	if setup == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Setup expired. Please generate a new QR code.",
+		})
 		return
 	}
 
@@ -136,7 +202,7 @@ func (s *Server) handle2FAEnable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate the code
-	if !totp.ValidateCode(code, secret) {
+	if !totp.ValidateCode(code, setup.Secret) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -146,25 +212,17 @@ func (s *Server) handle2FAEnable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Code is valid, enable 2FA
-	backupCodesRaw, _ := sessionData["backup_codes"].([]interface{})
-	backupCodes := make([]string, len(backupCodesRaw))
-	for i, v := range backupCodesRaw {
-		backupCodes[i] = v.(string)
-	}
-
-	if err := database.DB.EnableTOTP(user.Id, secret, backupCodes); err != nil {
+	if err := database.DB.EnableTOTP(user.Id, setup.Secret, setup.BackupCodes); err != nil {
 		http.Error(w, "Failed to enable 2FA", http.StatusInternalServerError)
 		return
 	}
 
-	// Clear setup cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "totp_setup",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	totpPendingMu.Lock()
+	delete(totpPendingSetups, user.Id)
+	totpPendingMu.Unlock()
+
+	NewAuditLogger(s).Log2FAEnabled(user, r)
+	NewAuditLogger(s).LogBackupCodesGenerated(user, len(setup.BackupCodes), r)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -218,6 +276,26 @@ func (s *Server) handle2FADisable(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to disable 2FA", http.StatusInternalServerError)
 		return
 	}
+	NewAuditLogger(s).Log2FADisabled(user, r)
+
+	// Devices trusted to skip 2FA must not carry over if it is enabled again
+	if count, err := database.DB.RevokeAllTrustedDevices(user.Id); err == nil && count > 0 {
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     database.ActionTrustedDeviceRevoked,
+			EntityType: database.EntityUser,
+			EntityID:   fmt.Sprintf("%d", user.Id),
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"all":    true,
+				"count":  count,
+				"reason": "2fa_disabled",
+			}),
+			IPAddress: clientIP(r),
+			UserAgent: r.UserAgent(),
+			Success:   true,
+		})
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -238,36 +316,13 @@ func (s *Server) handle2FAVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get pending user ID from temporary cookie
-	cookie, err := r.Cookie("totp_pending")
-	if err != nil {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return
-	}
-
-	var pendingData struct {
-		UserID     int   `json:"user_id"`
-		CreatedAt  int64 `json:"created_at"`
-		RememberMe bool  `json:"remember_me"`
-	}
-
-	decodedData, err := base64.StdEncoding.DecodeString(cookie.Value)
-	if err != nil {
+	// Get the login waiting for this code (expired after 5 minutes)
+	pendingKey, pendingData := getTOTPPendingLogin(r)
+	if pendingData == nil {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 
-	if err := json.Unmarshal(decodedData, &pendingData); err != nil {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return
-	}
-
-	// Check if cookie has expired (5 minutes)
-	if time.Now().Unix()-pendingData.CreatedAt > 300 {
-		http.Redirect(w, r, "/login?error=Session expired", http.StatusSeeOther)
-		return
-	}
-
 	// Get user
 	user, err := database.DB.GetUserByID(pendingData.UserID)
 	if err != nil || !user.TOTPEnabled {
@@ -275,6 +330,16 @@ func (s *Server) handle2FAVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Wrong codes count towards the failed login limit, so codes can't be guessed
+	ip := clientIP(r)
+	if locked, until := auth.IsLockedOut(user.Email, ip); locked {
+		totpPendingMu.Lock()
+		delete(totpPendingLogins, pendingKey)
+		totpPendingMu.Unlock()
+		s.renderLoginLockedOut(w, r, until)
+		return
+	}
+
 	// Parse form
 	if err := r.ParseForm(); err != nil {
 		s.render2FAVerifyPage(w, r, "Invalid form data")
@@ -288,21 +353,51 @@ func (s *Server) handle2FAVerify(w http.ResponseWriter, r *http.Request) {
 
 	if useBackup {
 		// Validate backup code
-		valid, err = database.DB.ValidateBackupCode(user.Id, code)
+		valid, err = database.DB.ConsumeBackupCode(user.Id, code)
 		if err != nil {
 			s.render2FAVerifyPage(w, r, "Error validating backup code")
 			return
 		}
+		if valid {
+			remaining, _ := database.DB.GetRemainingBackupCodesCount(user.Id)
+			NewAuditLogger(s).LogBackupCodeUsed(user, remaining, r)
+		}
 	} else {
 		// Validate TOTP code
-		valid = totp.ValidateCode(code, user.TOTPSecret)
+		secret, err := database.DB.GetTOTPSecret(user)
+		if err != nil {
+			log.Printf("Error reading TOTP secret of %s: %v", user.Email, err)
+			s.render2FAVerifyPage(w, r, "Error validating verification code")
+			return
+		}
+		valid = totp.ValidateCode(code, secret)
 	}
 
 	if !valid {
+		auth.RecordLoginAttempt(user.Email, ip, false)
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     database.ActionLoginFailed,
+			EntityType: "Session",
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"email":   user.Email,
+				"success": false,
+				"reason":  "invalid_2fa_code",
+			}),
+			IPAddress: ip,
+			UserAgent: r.UserAgent(),
+			Success:   false,
+			ErrorMsg:  "Invalid verification code",
+		})
 		s.render2FAVerifyPage(w, r, "Invalid verification code")
 		return
 	}
 
+	totpPendingMu.Lock()
+	delete(totpPendingLogins, pendingKey)
+	totpPendingMu.Unlock()
+
 	// Clear pending cookie
 	http.SetCookie(w, &http.Cookie{
 		Name:     "totp_pending",
@@ -312,17 +407,17 @@ func (s *Server) handle2FAVerify(w http.ResponseWriter, r *http.Request) {
 		HttpOnly: true,
 	})
 
-	// Create session with appropriate duration
-	sessionDuration := 24 * time.Hour
-	if pendingData.RememberMe {
-		sessionDuration = 30 * 24 * time.Hour // 30 days
+	if r.FormValue("remember_device") == "1" {
+		s.rememberDevice(w, r, user)
 	}
 
-	sessionID, err := auth.CreateSession(user.Id, sessionDuration)
+	// Create session with the duration set in the server settings
+	sessionID, sessionDuration, err := auth.CreateLoginSession(user.Id, pendingData.RememberMe)
 	if err != nil {
 		s.render2FAVerifyPage(w, r, "Failed to create session")
 		return
 	}
+	auth.RecordLoginAttempt(user.Email, ip, true)
 
 	// Set session cookie with same expiration
 
@@ -336,8 +431,10 @@ func (s *Server) handle2FAVerify(w http.ResponseWriter, r *http.Request) {
 		Secure: false, // Set to true if using HTTPS
 	})
 
-	// Redirect to appropriate dashboard
-	if user.IsAdmin() {
+	// Redirect to the page the user was sent to login from, or the appropriate dashboard
+	if redirect := s.localRedirectPath(pendingData.Redirect); redirect != "" {
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+	} else if user.IsAdmin() {
 		http.Redirect(w, r, "/admin", http.StatusSeeOther)
 	} else {
 		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
@@ -348,13 +445,13 @@ func (s *Server) handle2FAVerify(w http.ResponseWriter, r *http.Request) {
 func (s *Server) render2FAVerifyPage(w http.ResponseWriter, r *http.Request, errorMsg string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
     <title>Two-Factor Authentication - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -452,6 +549,12 @@ func (s *Server) render2FAVerifyPage(w http.ResponseWriter, r *http.Request, err
         #backup-form {
             display: none;
         }
+        .remember-device label {
+            font-weight: normal;
+            font-size: 14px;
+            color: #555;
+            cursor: pointer;
+        }
     </style>
 </head>
 <body>
@@ -465,13 +568,21 @@ func (s *Server) render2FAVerifyPage(w http.ResponseWriter, r *http.Request, err
 		html += `<div class="error">` + errorMsg + `</div>`
 	}
 
+	rememberDeviceHTML := ""
+	if days := database.DB.GetTrustedDeviceDays(); days > 0 {
+		rememberDeviceHTML = fmt.Sprintf(`
+            <div class="form-group remember-device">
+                <label><input type="checkbox" name="remember_device" value="1"> Remember this device for %d days</label>
+            </div>`, days)
+	}
+
 	html += `
         <form method="POST" action="/2fa/verify" id="totp-form">
             <div class="form-group">
                 <label for="code">Enter the 6-digit code from your authenticator app</label>
                 <input type="text" id="code" name="code" maxlength="6" pattern="[0-9]{6}" required autofocus autocomplete="off">
                 <input type="hidden" name="use_backup" value="0">
-            </div>
+            </div>` + rememberDeviceHTML + `
             <button type="submit" class="btn">Verify</button>
         </form>
 
@@ -480,7 +591,7 @@ func (s *Server) render2FAVerifyPage(w http.ResponseWriter, r *http.Request, err
                 <label for="backup-code">Enter a backup code</label>
                 <input type="text" id="backup-code" name="code" maxlength="16" required autocomplete="off">
                 <input type="hidden" name="use_backup" value="1">
-            </div>
+            </div>` + rememberDeviceHTML + `
             <button type="submit" class="btn">Verify Backup Code</button>
         </form>
 
@@ -545,12 +656,13 @@ func (s *Server) handle2FARegenerateBackupCodes(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Regenerate codes
-	codes, err := database.DB.RegenerateBackupCodes(user.Id)
+	// Regenerate codes; the old ones stop working
+	codes, err := database.DB.GenerateBackupCodes(user.Id)
 	if err != nil {
 		http.Error(w, "Failed to regenerate backup codes", http.StatusInternalServerError)
 		return
 	}
+	NewAuditLogger(s).LogBackupCodesGenerated(user, len(codes), r)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -8,24 +8,30 @@ package server
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/auth"
+	"github.com/Frimurare/WulfVault/internal/config"
 	"github.com/Frimurare/WulfVault/internal/database"
 	emailpkg "github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/i18n"
 	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
 )
 
 // Helper function for select option
@@ -182,7 +188,7 @@ func (s *Server) handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
 	top5ActiveUsers, top5FileCounts, _ := database.DB.GetTop5ActiveUsers()
 
 	// Get trend data
-	topFileTypes, fileTypeCounts, _ := database.DB.GetTopFileTypes()
+	fileCategories, fileCategoryCounts, _ := database.DB.GetFileCategoryCounts()
 	topWeekday, weekdayCount, _ := database.DB.GetMostActiveWeekday()
 	storagePast, storageNow, _ := database.DB.GetStorageTrendLastMonth()
 
@@ -215,7 +221,7 @@ func (s *Server) handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
 		activeFiles7Days, activeFiles30Days, avgFileSize, avgDownloadsPerFile,
 		twoFAAdoption, avgBackupCodes,
 		largestFileName, largestFileSize, top5ActiveUsers, top5FileCounts,
-		topFileTypes, fileTypeCounts, topWeekday, weekdayCount, storagePast, storageNow,
+		fileCategories, fileCategoryCounts, topWeekday, weekdayCount, storagePast, storageNow,
 		mostDownloadedFile, downloadCount, uploadsUsed, diskAvailable, duplicateFiles)
 }
 
@@ -317,7 +323,8 @@ func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
 		downloadCount = 0
 	}
 
-	s.renderAdminUsers(w, users, downloadAccounts, userFilter, userCount, downloadFilter, downloadCount)
+	viewer, _ := userFromContext(r.Context())
+	s.renderAdminUsers(w, viewer, users, downloadAccounts, userFilter, userCount, downloadFilter, downloadCount)
 }
 
 // handleAdminUserCreate creates a new user
@@ -356,6 +363,12 @@ func (s *Server) handleAdminUserCreate(w http.ResponseWriter, r *http.Request) {
 		s.renderAdminUserForm(w, nil, "Password is required (or check 'Send welcome email')")
 		return
 	}
+	if !sendWelcomeEmail {
+		if err := auth.ValidatePasswordStrength(password); err != nil {
+			s.renderAdminUserForm(w, nil, err.Error())
+			return
+		}
+	}
 
 	// Hash password (use temporary password if sending welcome email)
 	var err error
@@ -411,7 +424,7 @@ func (s *Server) handleAdminUserCreate(w http.ResponseWriter, r *http.Request) {
 		EntityType: "User",
 		EntityID:   fmt.Sprintf("%d", newUser.Id),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"user_level\":%d,\"quota_mb\":%d}", newUser.Email, newUser.Name, newUser.UserLevel, newUser.StorageQuotaMB),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 	})
@@ -442,12 +455,12 @@ func (s *Server) handleAdminUserCreate(w http.ResponseWriter, r *http.Request) {
 				log.Printf("Corrected server URL from HTTPS to HTTP for email: %s", emailServerURL)
 			}
 
-			// Send welcome email with admin info
-			if err := emailpkg.SendWelcomeEmail(email, resetToken, emailServerURL, companyName, admin.Name, admin.Email); err != nil {
-				log.Printf("Failed to send welcome email to %s: %v", email, err)
+			// Queue welcome email with admin info
+			if err := emailpkg.QueueWelcomeEmail(email, resetToken, emailServerURL, companyName, admin.Name, admin.Email); err != nil {
+				log.Printf("Failed to queue welcome email to %s: %v", email, err)
 				// Don't fail user creation, just log the error
 			} else {
-				log.Printf("Welcome email sent to new user: %s (%s) by admin %s", name, email, admin.Name)
+				log.Printf("Welcome email queued for new user: %s (%s) by admin %s", name, email, admin.Name)
 			}
 		}
 	}
@@ -485,15 +498,26 @@ func (s *Server) handleAdminUserEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	oldLevel := existingUser.UserLevel
 	existingUser.Name = r.FormValue("name")
 	existingUser.Email = r.FormValue("email")
 	existingUser.StorageQuotaMB, _ = strconv.ParseInt(r.FormValue("quota_mb"), 10, 64)
 	existingUser.UserLevel = models.UserRank(mustParseInt(r.FormValue("user_level")))
 	existingUser.IsActive = r.FormValue("is_active") == "1"
 
+	uploadWindow, err := uploadWindowFromForm(r, "upload_window")
+	if err != nil {
+		s.renderAdminUserForm(w, existingUser, "Invalid upload hours: "+template.HTMLEscapeString(err.Error()))
+		return
+	}
+
 	// Update password if provided
 	newPassword := r.FormValue("password")
 	if newPassword != "" {
+		if err := auth.ValidatePasswordStrength(newPassword); err != nil {
+			s.renderAdminUserForm(w, existingUser, err.Error())
+			return
+		}
 		hashedPassword, err := auth.HashPassword(newPassword)
 		if err != nil {
 			s.renderAdminUserForm(w, existingUser, "Failed to hash password")
@@ -506,6 +530,10 @@ func (s *Server) handleAdminUserEdit(w http.ResponseWriter, r *http.Request) {
 		s.renderAdminUserForm(w, existingUser, "Failed to update user: "+err.Error())
 		return
 	}
+	if err := database.DB.SetUserUploadWindow(existingUser.Id, uploadWindow); err != nil {
+		s.renderAdminUserForm(w, existingUser, "Failed to save upload hours: "+err.Error())
+		return
+	}
 
 	// Log the action
 	admin, _ := userFromContext(r.Context())
@@ -516,10 +544,11 @@ func (s *Server) handleAdminUserEdit(w http.ResponseWriter, r *http.Request) {
 		EntityType: "User",
 		EntityID:   fmt.Sprintf("%d", existingUser.Id),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"user_level\":%d,\"is_active\":%t}", existingUser.Email, existingUser.Name, existingUser.UserLevel, existingUser.IsActive),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 	})
+	s.logUserLevelChange(admin, existingUser, oldLevel, r)
 
 	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
 }
@@ -565,7 +594,7 @@ func (s *Server) handleAdminUserDelete(w http.ResponseWriter, r *http.Request) {
 		EntityType: "User",
 		EntityID:   fmt.Sprintf("%d", userID),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"user_level\":%d}", userToDelete.Email, userToDelete.Name, userToDelete.UserLevel),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 	})
@@ -630,6 +659,10 @@ func (s *Server) handleAdminCreateDownloadAccount(w http.ResponseWriter, r *http
 		s.renderAdminDownloadAccountForm(w, nil, "All fields are required")
 		return
 	}
+	if err := auth.ValidatePasswordStrength(password); err != nil {
+		s.renderAdminDownloadAccountForm(w, nil, err.Error())
+		return
+	}
 
 	// Check if account already exists
 	existing, _ := database.DB.GetDownloadAccountByEmail(email)
@@ -645,12 +678,15 @@ func (s *Server) handleAdminCreateDownloadAccount(w http.ResponseWriter, r *http
 		return
 	}
 
+	admin, _ := userFromContext(r.Context())
+
 	// Create account
 	account := &models.DownloadAccount{
-		Name:     name,
-		Email:    email,
-		Password: hashedPassword,
-		IsActive: true,
+		Name:      name,
+		Email:     email,
+		Password:  hashedPassword,
+		IsActive:  true,
+		CreatedBy: admin.Id,
 	}
 
 	if err := database.DB.CreateDownloadAccount(account); err != nil {
@@ -661,7 +697,6 @@ func (s *Server) handleAdminCreateDownloadAccount(w http.ResponseWriter, r *http
 	log.Printf("Admin created download account: %s", email)
 
 	// Log the action
-	admin, _ := userFromContext(r.Context())
 	database.DB.LogAction(&database.AuditLogEntry{
 		UserID:     int64(admin.Id),
 		UserEmail:  admin.Email,
@@ -669,7 +704,7 @@ func (s *Server) handleAdminCreateDownloadAccount(w http.ResponseWriter, r *http
 		EntityType: "DownloadAccount",
 		EntityID:   fmt.Sprintf("%d", account.Id),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"admin_created\":true}", account.Email, account.Name),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 	})
@@ -714,6 +749,10 @@ func (s *Server) handleAdminEditDownloadAccount(w http.ResponseWriter, r *http.R
 	// Update password if provided
 	newPassword := r.FormValue("password")
 	if newPassword != "" {
+		if err := auth.ValidatePasswordStrength(newPassword); err != nil {
+			s.renderAdminDownloadAccountForm(w, existingAccount, err.Error())
+			return
+		}
 		hashedPassword, err := auth.HashPassword(newPassword)
 		if err != nil {
 			s.renderAdminDownloadAccountForm(w, existingAccount, "Failed to hash password")
@@ -768,7 +807,7 @@ func (s *Server) handleAdminDeleteDownloadAccount(w http.ResponseWriter, r *http
 		EntityType: "DownloadAccount",
 		EntityID:   fmt.Sprintf("%d", accountID),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"soft_delete\":true,\"admin_deleted\":true}", account.Email, account.Name),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -797,7 +836,7 @@ func (s *Server) renderAdminDownloadAccountForm(w http.ResponseWriter, account *
 	}
 
 	html := `<!DOCTYPE html>
-<html>
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta name="author" content="Ulf Holmström">
     <title>` + title + `</title>
@@ -841,6 +880,7 @@ func (s *Server) renderAdminDownloadAccountForm(w http.ResponseWriter, account *
 		}
 		return ""
 	}() + `>
+            <div style="color: #666; font-size: 13px; margin-top: 4px;">` + template.HTMLEscapeString(auth.PasswordRequirements()) + `</div>
 
             <br><br>
             <label style="display: flex; align-items: center; cursor: pointer;">
@@ -868,7 +908,7 @@ func (s *Server) renderAdminDownloadAccountForm(w http.ResponseWriter, account *
 
 // handleAdminFiles lists all files in the system
 func (s *Server) handleAdminFiles(w http.ResponseWriter, r *http.Request) {
-	files, err := database.DB.GetAllFiles()
+	files, err := database.DB.GetFilesFiltered(fileFilterFromRequest(r))
 	if err != nil {
 		s.sendError(w, http.StatusInternalServerError, "Failed to fetch files")
 		return
@@ -883,6 +923,149 @@ func (s *Server) handleAdminFiles(w http.ResponseWriter, r *http.Request) {
 	s.renderAdminFiles(w, files, totalStorage)
 }
 
+// fileFilterFromRequest builds the admin file filter from query parameters
+func fileFilterFromRequest(r *http.Request) *database.FileFilter {
+	filter := &database.FileFilter{
+		SearchTerm: strings.TrimSpace(r.URL.Query().Get("search")),
+		Status:     r.URL.Query().Get("status"),
+		Category:   fileCategoryFromRequest(r),
+	}
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		if userID, err := strconv.Atoi(userIDStr); err == nil {
+			filter.UserId = userID
+		}
+	}
+	return filter
+}
+
+// fileCategoryFromRequest returns the category query parameter, or "" if it isn't a known category
+func fileCategoryFromRequest(r *http.Request) string {
+	if category := r.URL.Query().Get("category"); database.IsValidFileCategory(category) {
+		return category
+	}
+	return ""
+}
+
+// fileCategoryOptionsHTML renders the <option> elements of a file category filter
+func fileCategoryOptionsHTML(selected string) string {
+	html := ""
+	for _, category := range database.FileCategories {
+		selectedAttr := ""
+		if category == selected {
+			selectedAttr = " selected"
+		}
+		html += fmt.Sprintf(`<option value="%s"%s>%s</option>`, category, selectedAttr, database.FileCategoryLabel(category))
+	}
+	return html
+}
+
+// handleAdminFilesExport streams the file inventory as CSV or JSON, honoring the admin file filters
+func (s *Server) handleAdminFilesExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		s.sendError(w, http.StatusBadRequest, "Format must be csv or json")
+		return
+	}
+
+	filter := fileFilterFromRequest(r)
+	filename := fmt.Sprintf("wulfvault-files-%s.%s", time.Now().Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	flusher, _ := w.(http.Flusher)
+	count := 0
+	var err error
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("["))
+		err = database.DB.ForEachFileExport(filter, func(row *database.FileExportRow) error {
+			data, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				w.Write([]byte(","))
+			}
+			if _, err := w.Write(append([]byte("\n"), data...)); err != nil {
+				return err
+			}
+			count++
+			if flusher != nil && count%500 == 0 {
+				flusher.Flush()
+			}
+			return nil
+		})
+		w.Write([]byte("\n]\n"))
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"ID", "Name", "Owner Name", "Owner Email", "Size Bytes", "Size", "Category", "Created", "Expires", "Download Count", "Downloads Remaining", "Teams", "Status", "Require Auth", "Password Protected"})
+		err = database.DB.ForEachFileExport(filter, func(row *database.FileExportRow) error {
+			count++
+			if err := writer.Write([]string{
+				row.Id,
+				row.Name,
+				row.OwnerName,
+				row.OwnerEmail,
+				strconv.FormatInt(row.SizeBytes, 10),
+				row.Size,
+				row.Category,
+				row.CreatedAt,
+				row.ExpiresAt,
+				strconv.Itoa(row.DownloadCount),
+				row.DownloadsLeft,
+				strings.Join(row.Teams, "; "),
+				row.Status,
+				strconv.FormatBool(row.RequireAuth),
+				strconv.FormatBool(row.PasswordLocked),
+			}); err != nil {
+				return err
+			}
+			if count%500 == 0 {
+				writer.Flush()
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			return nil
+		})
+		writer.Flush()
+	}
+
+	// Headers are already sent, so a failure mid-stream can only be logged
+	if err != nil {
+		log.Printf("File export failed after %d rows: %v", count, err)
+	}
+
+	user, _ := userFromContext(r.Context())
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionFilesExported,
+		EntityType: database.EntityFile,
+		EntityID:   "all",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"format":   format,
+			"rows":     count,
+			"search":   filter.SearchTerm,
+			"status":   filter.Status,
+			"category": filter.Category,
+			"user_id":  filter.UserId,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   err == nil,
+	})
+}
+
 // handleAdminDuplicates shows duplicate files with pagination
 func (s *Server) handleAdminDuplicates(w http.ResponseWriter, r *http.Request) {
 	// Get all duplicate file groups
@@ -946,6 +1129,24 @@ func (s *Server) handleAdminBranding(w http.ResponseWriter, r *http.Request) {
 	primaryColor := r.FormValue("primary_color")
 	secondaryColor := r.FormValue("secondary_color")
 
+	// Colors are written into the CSS of every page, so nothing is saved unless both are valid
+	if primaryColor != "" {
+		color, err := normalizeColor(primaryColor)
+		if err != nil {
+			s.renderAdminBranding(w, "Invalid primary color: "+err.Error())
+			return
+		}
+		primaryColor = color
+	}
+	if secondaryColor != "" {
+		color, err := normalizeColor(secondaryColor)
+		if err != nil {
+			s.renderAdminBranding(w, "Invalid secondary color: "+err.Error())
+			return
+		}
+		secondaryColor = color
+	}
+
 	// Handle logo upload
 	logoData := ""
 	file, _, err := r.FormFile("logo")
@@ -977,6 +1178,28 @@ func (s *Server) handleAdminBranding(w http.ResponseWriter, r *http.Request) {
 		database.DB.SetConfigValue("branding_logo", logoData)
 	}
 
+	// Login page customization
+	ssoURL := strings.TrimSpace(r.FormValue("login_sso_url"))
+	if ssoURL != "" && !strings.HasPrefix(ssoURL, "https://") && !strings.HasPrefix(ssoURL, "http://") && !strings.HasPrefix(ssoURL, "/") {
+		s.renderAdminBranding(w, "SSO login URL must start with https://, http:// or /")
+		return
+	}
+	loginBackground := strings.TrimSpace(r.FormValue("login_background"))
+	if loginBackground != "" && loginBackgroundCSS(loginBackground) == "" {
+		s.renderAdminBranding(w, "Login background must be a color (e.g. #1e293b) or an image URL")
+		return
+	}
+	database.DB.SetConfigValue("branding_login_welcome_text", strings.TrimSpace(r.FormValue("login_welcome_text")))
+	database.DB.SetConfigValue("branding_login_support_contact", strings.TrimSpace(r.FormValue("login_support_contact")))
+	database.DB.SetConfigValue("branding_login_background", loginBackground)
+	database.DB.SetConfigValue("branding_login_sso_url", ssoURL)
+	database.DB.SetConfigValue("branding_login_sso_label", strings.TrimSpace(r.FormValue("login_sso_label")))
+	if r.FormValue("login_sso_only") == "on" {
+		database.DB.SetConfigValue("branding_login_sso_only", "true")
+	} else {
+		database.DB.SetConfigValue("branding_login_sso_only", "false")
+	}
+
 	// Reload config
 	s.loadBrandingConfig()
 
@@ -988,8 +1211,8 @@ func (s *Server) handleAdminBranding(w http.ResponseWriter, r *http.Request) {
 		Action:     "BRANDING_UPDATED",
 		EntityType: "Settings",
 		EntityID:   "branding",
-		Details:    fmt.Sprintf("{\"company_name\":\"%s\",\"has_logo\":%v}", companyName, logoData != ""),
-		IPAddress:  getClientIP(r),
+		Details:    fmt.Sprintf("{\"company_name\":\"%s\",\"has_logo\":%v,\"sso_only\":%v}", companyName, logoData != "", r.FormValue("login_sso_only") == "on"),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -1016,6 +1239,43 @@ func (s *Server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle bind address change - like the port, it takes effect after a restart
+	bindAddress := strings.TrimSpace(r.FormValue("bind_address"))
+	if err := config.ValidateBindAddress(bindAddress); err != nil {
+		s.renderAdminSettings(w, "Error: "+err.Error())
+		return
+	}
+	if bindAddress != s.getConfiguredBindAddress() {
+		if err := s.updateConfigJSON("bindAddress", bindAddress); err != nil {
+			log.Printf("Error updating config.json: %v", err)
+			s.renderAdminSettings(w, "Error: Failed to save bind address to config file")
+			return
+		}
+		database.DB.SetConfigValue("bind_address", bindAddress)
+	}
+
+	// IP access lists. An admin allowlist that leaves out the admin saving it would lock them out.
+	if r.Form.Has(configAdminIPAllowlist) {
+		lists := map[string][]*net.IPNet{}
+		for _, key := range []string{configAdminIPAllowlist, configAdminIPDenylist, configDownloadIPAllowlist, configDownloadIPDenylist} {
+			nets, err := parseIPNetList(r.FormValue(key))
+			if err != nil {
+				s.renderAdminSettings(w, "Error: IP access lists: "+err.Error())
+				return
+			}
+			lists[key] = nets
+		}
+		adminList := ipAccessList{Allow: lists[configAdminIPAllowlist], Deny: lists[configAdminIPDenylist]}
+		if ip := clientIP(r); !adminList.Permits(ip) {
+			s.renderAdminSettings(w, "Error: The admin IP lists would block your own address ("+ip+"). Add it to the allowlist first.")
+			return
+		}
+		for key, nets := range lists {
+			database.DB.SetConfigValue(key, formatIPNetList(nets))
+		}
+		loadIPAccessRules()
+	}
+
 	// Update settings in database and config
 	serverURL := r.FormValue("server_url")
 	if serverURL != "" {
@@ -1023,6 +1283,7 @@ func (s *Server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
 		serverURL = stripPortFromURL(serverURL)
 		database.DB.SetConfigValue("server_url", serverURL)
 		s.config.ServerURL = serverURL
+		emailpkg.SetPublicURL(s.getPublicURL())
 	}
 
 	// Handle port change - ONLY if port actually changed
@@ -1077,6 +1338,25 @@ func (s *Server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Bounds for the trash retention users may choose when deleting a file (max 0 turns it off)
+	if r.Form.Has("trash_retention_min_days") {
+		minDays, _ := strconv.Atoi(r.FormValue("trash_retention_min_days"))
+		maxDays, _ := strconv.Atoi(r.FormValue("trash_retention_max_days"))
+		if minDays < 1 {
+			minDays = 1
+		}
+		if maxDays < 0 || (maxDays > 0 && maxDays < minDays) {
+			maxDays = 0
+		}
+		database.DB.SetConfigValue("trash_retention_min_days", strconv.Itoa(minDays))
+		database.DB.SetConfigValue("trash_retention_max_days", strconv.Itoa(maxDays))
+	}
+
+	// How long users can undo deleting a file (0 turns undo off)
+	if seconds, err := strconv.Atoi(r.FormValue("delete_undo_seconds")); err == nil && seconds >= 0 && seconds <= MaxDeleteUndoSeconds {
+		database.DB.SetConfigValue("delete_undo_seconds", strconv.Itoa(seconds))
+	}
+
 	auditLogRetentionDays := r.FormValue("audit_log_retention_days")
 	if auditLogRetentionDays != "" {
 		database.DB.SetConfigValue("audit_log_retention_days", auditLogRetentionDays)
@@ -1085,6 +1365,39 @@ func (s *Server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Failed login limits (0 turns a limit off; the window must be at least a minute)
+	for _, key := range []string{"login_max_attempts_per_email", "login_max_attempts_per_ip"} {
+		if attempts, err := strconv.Atoi(r.FormValue(key)); err == nil && attempts >= 0 {
+			database.DB.SetConfigValue(key, strconv.Itoa(attempts))
+		}
+	}
+	if minutes, err := strconv.Atoi(r.FormValue("login_window_minutes")); err == nil && minutes >= 1 {
+		database.DB.SetConfigValue("login_window_minutes", strconv.Itoa(minutes))
+	}
+
+	// Minimum password length, applied when passwords are set or changed
+	if minLength, err := strconv.Atoi(r.FormValue("password_min_length")); err == nil && minLength >= auth.MinPasswordMinLength && minLength <= auth.MaxPasswordLength {
+		database.DB.SetConfigValue("password_min_length", strconv.Itoa(minLength))
+	}
+	if algorithm := r.FormValue("password_hash_algorithm"); algorithm == auth.HashAlgorithmBcrypt || algorithm == auth.HashAlgorithmArgon2id {
+		database.DB.SetConfigValue("password_hash_algorithm", algorithm)
+	}
+	if cost, err := strconv.Atoi(r.FormValue("password_bcrypt_cost")); err == nil && cost >= auth.MinBcryptCost && cost <= auth.MaxBcryptCost {
+		database.DB.SetConfigValue("password_bcrypt_cost", strconv.Itoa(cost))
+	}
+	if memoryMB, err := strconv.Atoi(r.FormValue("password_argon2_memory_mb")); err == nil && memoryMB >= auth.MinArgon2MemoryMB && memoryMB <= auth.MaxArgon2MemoryMB {
+		database.DB.SetConfigValue("password_argon2_memory_mb", strconv.Itoa(memoryMB))
+	}
+	if iterations, err := strconv.Atoi(r.FormValue("password_argon2_iterations")); err == nil && iterations >= auth.MinArgon2Iterations && iterations <= auth.MaxArgon2Iterations {
+		database.DB.SetConfigValue("password_argon2_iterations", strconv.Itoa(iterations))
+	}
+
+	if r.FormValue("notify_admins_on_promotion") == "on" {
+		database.DB.SetConfigValue("notify_admins_on_promotion", "true")
+	} else {
+		database.DB.SetConfigValue("notify_admins_on_promotion", "false")
+	}
+
 	auditLogMaxSizeMB := r.FormValue("audit_log_max_size_mb")
 	if auditLogMaxSizeMB != "" {
 		database.DB.SetConfigValue("audit_log_max_size_mb", auditLogMaxSizeMB)
@@ -1101,159 +1414,548 @@ func (s *Server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Handle dashboard style preference
-	dashboardStyle := r.FormValue("dashboard_style")
-	if dashboardStyle == "on" {
-		database.DB.SetConfigValue("dashboard_style", "plain")
-	} else {
-		database.DB.SetConfigValue("dashboard_style", "colorful")
+	for _, key := range []string{"deleted_account_retention_days", "file_request_retention_days"} {
+		if days, err := strconv.Atoi(r.FormValue(key)); err == nil && days >= 1 {
+			database.DB.SetConfigValue(key, strconv.Itoa(days))
+		}
 	}
 
-	// Log the action
-	user, _ := userFromContext(r.Context())
-	database.DB.LogAction(&database.AuditLogEntry{
-		UserID:     int64(user.Id),
-		UserEmail:  user.Email,
-		Action:     "SETTINGS_UPDATED",
-		EntityType: "Settings",
-		EntityID:   "general",
-		Details:    fmt.Sprintf("{\"server_url\":\"%s\",\"port_changed\":%v}", serverURL, portChanged),
-		IPAddress:  getClientIP(r),
-		UserAgent:  r.UserAgent(),
-		Success:    true,
-		ErrorMsg:   "",
-	})
-
-	// Show appropriate success message
-	if portChanged {
-		s.renderAdminSettings(w, fmt.Sprintf("Port changed to %s. ⚠️ RESTART REQUIRED: Stop and start the server for changes to take effect.", port))
-	} else {
-		s.renderAdminSettings(w, "Settings updated successfully!")
+	maxFileExpiryDays := r.FormValue("max_file_expiry_days")
+	if maxFileExpiryDays != "" {
+		if days, err := strconv.Atoi(maxFileExpiryDays); err == nil && days >= 0 {
+			database.DB.SetConfigValue("max_file_expiry_days", maxFileExpiryDays)
+		}
 	}
-}
 
-// handleAdminTrash lists all deleted files (trash)
-func (s *Server) handleAdminTrash(w http.ResponseWriter, r *http.Request) {
-	files, err := database.DB.GetDeletedFiles()
-	if err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Failed to fetch trash")
-		return
+	for _, tokenType := range database.TokenTypes {
+		retentionHours := r.FormValue(tokenType.RetentionConfigKey())
+		if retentionHours != "" {
+			if hours, err := strconv.Atoi(retentionHours); err == nil && hours >= 0 {
+				database.DB.SetConfigValue(tokenType.RetentionConfigKey(), retentionHours)
+			}
+		}
 	}
 
-	s.renderAdminTrash(w, files)
-}
+	maxPublicLinks := r.FormValue("max_public_links")
+	if maxPublicLinks != "" {
+		if limit, err := strconv.Atoi(maxPublicLinks); err == nil && limit >= 0 {
+			database.DB.SetConfigValue("max_public_links", maxPublicLinks)
+		}
+	}
 
-// handleAdminPermanentDelete permanently deletes a file
-func (s *Server) handleAdminPermanentDelete(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
+	downloadTokenTTL := r.FormValue("download_token_ttl_seconds")
+	if downloadTokenTTL != "" {
+		if seconds, err := strconv.Atoi(downloadTokenTTL); err == nil && seconds >= 0 {
+			database.DB.SetConfigValue("download_token_ttl_seconds", downloadTokenTTL)
+		}
+	}
+	if minutes, err := strconv.Atoi(r.FormValue("download_retry_window_minutes")); err == nil && minutes >= 0 && minutes <= database.MaxDownloadRetryWindowMinutes {
+		database.DB.SetConfigValue("download_retry_window_minutes", strconv.Itoa(minutes))
+	}
+	if limit, err := strconv.Atoi(r.FormValue("download_notify_limit")); err == nil && limit >= 0 {
+		database.DB.SetConfigValue("download_notify_limit", strconv.Itoa(limit))
+	}
+	if minutes, err := strconv.Atoi(r.FormValue("download_digest_interval_minutes")); err == nil && minutes >= 5 && minutes <= 1440 {
+		database.DB.SetConfigValue("download_digest_interval_minutes", strconv.Itoa(minutes))
 	}
 
-	fileID := r.FormValue("file_id")
-	if fileID == "" {
-		s.sendError(w, http.StatusBadRequest, "Missing file_id")
-		return
+	teamZipMaxMB := r.FormValue("team_zip_max_mb")
+	if teamZipMaxMB != "" {
+		if mb, err := strconv.Atoi(teamZipMaxMB); err == nil && mb >= 0 {
+			database.DB.SetConfigValue("team_zip_max_mb", teamZipMaxMB)
+		}
+	}
+	if mb, err := strconv.Atoi(r.FormValue("zip_download_max_mb")); err == nil && mb >= 0 {
+		database.DB.SetConfigValue("zip_download_max_mb", strconv.Itoa(mb))
+	}
+	if mbps, err := strconv.Atoi(r.FormValue("download_max_mbps")); err == nil && mbps >= 0 {
+		database.DB.SetConfigValue("download_max_mbps", strconv.Itoa(mbps))
+	}
+	if mode := r.FormValue("burn_after_download_mode"); mode == database.BurnModeTrash || mode == database.BurnModeDelete {
+		database.DB.SetConfigValue("burn_after_download_mode", mode)
 	}
 
-	// Get file info before deletion
-	// We need to use a special query to get deleted files
-	rows, err := database.DB.GetDeletedFiles()
-	if err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Failed to fetch file info")
-		return
+	processingWorkers := r.FormValue("processing_workers")
+	if processingWorkers != "" {
+		if workers, err := strconv.Atoi(processingWorkers); err == nil && workers >= 1 && workers <= 32 {
+			database.DB.SetConfigValue("processing_workers", processingWorkers)
+		}
+	}
+	if hours, err := strconv.Atoi(r.FormValue("integrity_scan_interval_hours")); err == nil && hours >= 0 {
+		database.DB.SetConfigValue("integrity_scan_interval_hours", strconv.Itoa(hours))
+	}
+	if rate, err := strconv.Atoi(r.FormValue("integrity_scan_rate_mb")); err == nil && rate >= 1 && rate <= database.MaxIntegrityScanRateMB {
+		database.DB.SetConfigValue("integrity_scan_rate_mb", strconv.Itoa(rate))
 	}
 
-	var fileInfo *database.FileInfo
-	for _, f := range rows {
-		if f.Id == fileID {
-			fileInfo = f
-			break
+	emailChangeExpiryHours := r.FormValue("email_change_expiry_hours")
+	if emailChangeExpiryHours != "" {
+		if hours, err := strconv.Atoi(emailChangeExpiryHours); err == nil && hours > 0 {
+			database.DB.SetConfigValue("email_change_expiry_hours", emailChangeExpiryHours)
 		}
 	}
 
-	if fileInfo == nil {
-		s.sendError(w, http.StatusNotFound, "File not found in trash")
-		return
+	if r.FormValue("show_file_checksum") == "on" {
+		database.DB.SetConfigValue("show_file_checksum", "true")
+	} else {
+		database.DB.SetConfigValue("show_file_checksum", "false")
 	}
 
-	// Delete from disk
-	filePath := filepath.Join(s.config.UploadsDir, fileID)
-	if err := os.Remove(filePath); err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Warning: Could not delete file from disk: %v", err)
-		}
+	if r.FormValue("email_file_deep_links") == "on" {
+		database.DB.SetConfigValue("email_file_deep_links", "true")
+	} else {
+		database.DB.SetConfigValue("email_file_deep_links", "false")
 	}
 
-	// Permanently delete from database
-	if err := database.DB.PermanentDeleteFile(fileID); err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Failed to delete file")
-		return
+	if r.FormValue("email_link_signing") == "on" {
+		database.DB.SetConfigValue("email_link_signing", "true")
+	} else {
+		database.DB.SetConfigValue("email_link_signing", "false")
 	}
 
-	log.Printf("File permanently deleted by admin: %s (ID: %s)", fileInfo.Name, fileID)
+	emailLinkExpiryHours := r.FormValue("email_link_expiry_hours")
+	if emailLinkExpiryHours != "" {
+		if hours, err := strconv.Atoi(emailLinkExpiryHours); err == nil && hours >= 0 {
+			database.DB.SetConfigValue("email_link_expiry_hours", emailLinkExpiryHours)
+		}
+	}
 
-	// Log the action
-	user, _ := userFromContext(r.Context())
-	database.DB.LogAction(&database.AuditLogEntry{
-		UserID:     int64(user.Id),
-		UserEmail:  user.Email,
-		Action:     "FILE_PERMANENTLY_DELETED",
-		EntityType: "File",
-		EntityID:   fileID,
-		Details:    fmt.Sprintf("{\"filename\":\"%s\",\"size\":\"%s\"}", fileInfo.Name, fileInfo.Size),
-		IPAddress:  getClientIP(r),
-		UserAgent:  r.UserAgent(),
-		Success:    true,
-	})
+	if r.FormValue("email_link_allow_plain") == "on" {
+		database.DB.SetConfigValue("email_link_allow_plain", "true")
+	} else {
+		database.DB.SetConfigValue("email_link_allow_plain", "false")
+	}
 
-	s.sendJSON(w, http.StatusOK, map[string]string{
-		"message": "File permanently deleted",
-	})
-}
+	if footer, err := emailpkg.NormalizeFooterText(r.FormValue("email_footer_text")); err == nil {
+		database.DB.SetConfigValue("email_footer_text", footer)
+	}
 
-// handleAdminEmptyAllTrash permanently deletes all files in trash
-func (s *Server) handleAdminEmptyAllTrash(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
+	if message, err := normalizeExpiredMessage(r.FormValue("expired_message_default")); err == nil {
+		database.DB.SetConfigValue("expired_message_default", message)
 	}
 
-	// Get all deleted files
-	files, err := database.DB.GetDeletedFiles()
-	if err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Failed to fetch trash files")
-		return
+	if notice, err := normalizeLegalNotice(r.FormValue("legal_notice_text")); err == nil {
+		database.DB.SetConfigValue("legal_notice_text", notice)
 	}
 
-	deletedCount := 0
-	for _, fileInfo := range files {
-		// Delete from disk
-		filePath := filepath.Join(s.config.UploadsDir, fileInfo.Id)
-		if err := os.Remove(filePath); err != nil {
-			if !os.IsNotExist(err) {
-				log.Printf("Warning: Could not delete file from disk: %v", err)
-			}
+	expiredFileGraceHours := r.FormValue("expired_file_grace_hours")
+	if expiredFileGraceHours != "" {
+		if hours, err := strconv.Atoi(expiredFileGraceHours); err == nil && hours >= 0 {
+			database.DB.SetConfigValue("expired_file_grace_hours", expiredFileGraceHours)
 		}
+	}
 
-		// Delete from database
-		if err := database.DB.PermanentDeleteFile(fileInfo.Id); err != nil {
-			log.Printf("Warning: Could not delete file from database: %v", err)
-			continue
-		}
+	if r.FormValue("expired_files_auto_trash") == "on" {
+		database.DB.SetConfigValue("expired_files_auto_trash", "true")
+	} else {
+		database.DB.SetConfigValue("expired_files_auto_trash", "false")
+	}
 
-		deletedCount++
+	if r.FormValue("splash_viewer_tracking") == "on" {
+		database.DB.SetConfigValue("splash_viewer_tracking", "true")
+	} else {
+		database.DB.SetConfigValue("splash_viewer_tracking", "false")
+	}
 
-		// Audit log each deletion
-		user, _ := userFromContext(r.Context())
-		database.DB.LogAction(&database.AuditLogEntry{
-			UserID:     int64(user.Id),
-			UserEmail:  user.Email,
-			Action:     "FILE_PERMANENTLY_DELETED",
-			EntityType: "File",
+	if r.FormValue("image_conversion_enabled") == "on" {
+		database.DB.SetConfigValue("image_conversion_enabled", "true")
+	} else {
+		database.DB.SetConfigValue("image_conversion_enabled", "false")
+	}
+
+	if r.FormValue("image_conversion_download") == "on" {
+		database.DB.SetConfigValue("image_conversion_download", "true")
+	} else {
+		database.DB.SetConfigValue("image_conversion_download", "false")
+	}
+
+	if format := r.FormValue("image_conversion_format"); format == "jpeg" || format == "png" {
+		database.DB.SetConfigValue("image_conversion_format", format)
+	}
+
+	imageConversionMaxMB := r.FormValue("image_conversion_max_mb")
+	if imageConversionMaxMB != "" {
+		if maxMB, err := strconv.Atoi(imageConversionMaxMB); err == nil && maxMB >= 1 {
+			database.DB.SetConfigValue("image_conversion_max_mb", imageConversionMaxMB)
+		}
+	}
+
+	if r.FormValue("clamav_enabled") == "on" {
+		database.DB.SetConfigValue("clamav_enabled", "true")
+	} else {
+		database.DB.SetConfigValue("clamav_enabled", "false")
+	}
+
+	database.DB.SetConfigValue("clamav_address", strings.TrimSpace(r.FormValue("clamav_address")))
+
+	if r.FormValue("clamav_delete_infected") == "on" {
+		database.DB.SetConfigValue("clamav_delete_infected", "true")
+	} else {
+		database.DB.SetConfigValue("clamav_delete_infected", "false")
+	}
+
+	if r.FormValue("clamav_fail_open") == "on" {
+		database.DB.SetConfigValue("clamav_fail_open", "true")
+	} else {
+		database.DB.SetConfigValue("clamav_fail_open", "false")
+	}
+
+	if r.FormValue("download_log_details") == "on" {
+		database.DB.SetConfigValue("download_log_details", "true")
+	} else {
+		database.DB.SetConfigValue("download_log_details", "false")
+	}
+
+	if r.FormValue("private_download_log_default") == "on" {
+		database.DB.SetConfigValue("private_download_log_default", "true")
+	} else {
+		database.DB.SetConfigValue("private_download_log_default", "false")
+	}
+
+	if r.FormValue("strip_metadata_default") == "on" {
+		database.DB.SetConfigValue("strip_metadata_default", "true")
+	} else {
+		database.DB.SetConfigValue("strip_metadata_default", "false")
+	}
+
+	if r.FormValue("strip_document_metadata") == "on" {
+		database.DB.SetConfigValue("strip_document_metadata", "true")
+	} else {
+		database.DB.SetConfigValue("strip_document_metadata", "false")
+	}
+
+	// Allowed and blocked file types are stored as normalized lists, e.g. "exe, msi"
+	for key, trim := range map[string]string{
+		"upload_allowed_extensions": "*.",
+		"upload_blocked_extensions": "*.",
+		"upload_allowed_mime_types": "",
+		"upload_blocked_mime_types": "",
+	} {
+		if r.Form.Has(key) {
+			database.DB.SetConfigValue(key, strings.Join(database.ParseFileTypeList(r.FormValue(key), trim), ", "))
+		}
+	}
+	if r.FormValue("upload_verify_file_type") == "on" {
+		database.DB.SetConfigValue("upload_verify_file_type", "true")
+	} else {
+		database.DB.SetConfigValue("upload_verify_file_type", "false")
+	}
+
+	for _, key := range []string{"download_anomaly_max_per_minute", "download_anomaly_max_countries_per_hour"} {
+		if limit, err := strconv.Atoi(r.FormValue(key)); err == nil && limit >= 0 {
+			database.DB.SetConfigValue(key, strconv.Itoa(limit))
+		}
+	}
+	if minutes, err := strconv.Atoi(r.FormValue("download_anomaly_block_minutes")); err == nil && minutes >= 1 {
+		database.DB.SetConfigValue("download_anomaly_block_minutes", strconv.Itoa(minutes))
+	}
+	if r.Form.Has("siem_target") {
+		siemTarget := strings.TrimSpace(r.FormValue("siem_target"))
+		if err := validateSIEMTarget(siemTarget); err != nil {
+			s.renderAdminSettings(w, "Error: "+err.Error())
+			return
+		}
+		siemFormat := r.FormValue("siem_format")
+		if siemFormat != siemFormatCEF && siemFormat != siemFormatLEEF {
+			siemFormat = ""
+		}
+		database.DB.SetConfigValue("siem_format", siemFormat)
+		database.DB.SetConfigValue("siem_target", siemTarget)
+	}
+
+	if r.Form.Has("external_link_template") {
+		externalLinkTemplate := strings.TrimSpace(r.FormValue("external_link_template"))
+		if err := database.ValidateExternalLinkTemplate(externalLinkTemplate); err != nil {
+			s.renderAdminSettings(w, "Error: Invalid external link template: "+err.Error())
+			return
+		}
+		database.DB.SetConfigValue("external_link_template", externalLinkTemplate)
+		database.DB.SetConfigValue("external_link_label", strings.TrimSpace(r.FormValue("external_link_label")))
+		if r.FormValue("external_link_in_notifications") == "on" {
+			database.DB.SetConfigValue("external_link_in_notifications", "true")
+		} else {
+			database.DB.SetConfigValue("external_link_in_notifications", "false")
+		}
+	}
+
+	if r.Form.Has("download_country_header") {
+		database.DB.SetConfigValue("download_country_header", strings.TrimSpace(r.FormValue("download_country_header")))
+	}
+	if size, err := strconv.Atoi(r.FormValue("history_page_size")); err == nil && size >= 1 && size <= database.MaxHistoryPageSize {
+		database.DB.SetConfigValue("history_page_size", strconv.Itoa(size))
+	}
+
+	if r.FormValue("upload_approval_required") == "on" {
+		database.DB.SetConfigValue("upload_approval_required", "true")
+	} else {
+		database.DB.SetConfigValue("upload_approval_required", "false")
+	}
+	database.DB.SetConfigValue("upload_approver_emails", strings.TrimSpace(r.FormValue("upload_approver_emails")))
+
+	timezone := strings.TrimSpace(r.FormValue("timezone"))
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			s.renderAdminSettings(w, "Error: Unknown timezone "+template.HTMLEscapeString(timezone)+" (use an IANA name such as Europe/Stockholm)")
+			return
+		}
+	}
+	database.DB.SetConfigValue("timezone", timezone)
+
+	uploadWindow, err := uploadWindowFromForm(r, "upload_window")
+	if err != nil {
+		s.renderAdminSettings(w, "Error: Invalid upload hours: "+err.Error())
+		return
+	}
+	database.DB.SetGlobalUploadWindow(uploadWindow)
+
+	if language := r.FormValue("default_language"); language != "" {
+		if _, ok := i18n.Lookup(language); !ok {
+			s.renderAdminSettings(w, "Error: Unsupported language")
+			return
+		}
+		database.DB.SetConfigValue("default_language", language)
+	}
+
+	if r.FormValue("recipient_language_detection") == "on" {
+		database.DB.SetConfigValue("recipient_language_detection", "true")
+	} else {
+		database.DB.SetConfigValue("recipient_language_detection", "false")
+	}
+
+	maxActiveFileRequests := r.FormValue("max_active_file_requests")
+	if maxActiveFileRequests != "" {
+		if limit, err := strconv.Atoi(maxActiveFileRequests); err == nil && limit >= 0 {
+			database.DB.SetConfigValue("max_active_file_requests", maxActiveFileRequests)
+		}
+	}
+	if r.FormValue("file_request_limit_exempt_admins") == "on" {
+		database.DB.SetConfigValue("file_request_limit_exempt_admins", "true")
+	} else {
+		database.DB.SetConfigValue("file_request_limit_exempt_admins", "false")
+	}
+
+	welcomeEmailDailyCap := r.FormValue("welcome_email_daily_cap")
+	if welcomeEmailDailyCap != "" {
+		if limit, err := strconv.Atoi(welcomeEmailDailyCap); err == nil && limit >= 0 {
+			database.DB.SetConfigValue("welcome_email_daily_cap", welcomeEmailDailyCap)
+		}
+	}
+
+	emailMaxAttempts := r.FormValue("email_max_attempts")
+	if emailMaxAttempts != "" {
+		if attempts, err := strconv.Atoi(emailMaxAttempts); err == nil && attempts >= database.MinEmailMaxAttempts && attempts <= database.MaxEmailMaxAttempts {
+			database.DB.SetConfigValue("email_max_attempts", emailMaxAttempts)
+		}
+	}
+
+	if r.Form.Has("redirect_allowed_paths") {
+		var prefixes []string
+		for _, prefix := range strings.FieldsFunc(r.FormValue("redirect_allowed_paths"), func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+			if prefix = strings.TrimSpace(prefix); strings.HasPrefix(prefix, "/") && !strings.HasPrefix(prefix, "//") {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		database.DB.SetConfigValue("redirect_allowed_paths", strings.Join(prefixes, "\n"))
+	}
+
+	if hours, err := strconv.Atoi(r.FormValue("session_hours")); err == nil && hours >= 1 && hours <= database.MaxSessionHours {
+		database.DB.SetConfigValue("session_hours", strconv.Itoa(hours))
+	}
+	if days, err := strconv.Atoi(r.FormValue("remember_me_days")); err == nil && days >= 0 && days <= database.MaxRememberMeDays {
+		database.DB.SetConfigValue("remember_me_days", strconv.Itoa(days))
+	}
+
+	trustedDeviceDays := r.FormValue("trusted_device_days")
+	if trustedDeviceDays != "" {
+		if days, err := strconv.Atoi(trustedDeviceDays); err == nil && days >= 0 && days <= 365 {
+			database.DB.SetConfigValue("trusted_device_days", trustedDeviceDays)
+		}
+	}
+
+	expiryReminderLeadHours := r.FormValue("expiry_reminder_lead_hours")
+	if expiryReminderLeadHours != "" {
+		if leads, err := database.ParseExpiryReminderLeadHours(expiryReminderLeadHours); err == nil && len(leads) > 0 {
+			values := make([]string, len(leads))
+			for i, lead := range leads {
+				values[i] = strconv.Itoa(lead)
+			}
+			database.DB.SetConfigValue("expiry_reminder_lead_hours", strings.Join(values, ","))
+		}
+	}
+
+	expiryReminderDailyCap := r.FormValue("expiry_reminder_daily_cap")
+	if expiryReminderDailyCap != "" {
+		if limit, err := strconv.Atoi(expiryReminderDailyCap); err == nil && limit >= 0 {
+			database.DB.SetConfigValue("expiry_reminder_daily_cap", expiryReminderDailyCap)
+		}
+	}
+
+	downloadAccountIdleDays := r.FormValue("download_account_idle_days")
+	if downloadAccountIdleDays != "" {
+		if days, err := strconv.Atoi(downloadAccountIdleDays); err == nil && days >= 0 {
+			database.DB.SetConfigValue("download_account_idle_days", downloadAccountIdleDays)
+		}
+	}
+	downloadAccountGraceDays := r.FormValue("download_account_grace_days")
+	if downloadAccountGraceDays != "" {
+		if days, err := strconv.Atoi(downloadAccountGraceDays); err == nil && days >= 0 {
+			database.DB.SetConfigValue("download_account_grace_days", downloadAccountGraceDays)
+		}
+	}
+
+	// Handle dashboard style preference
+	dashboardStyle := r.FormValue("dashboard_style")
+	if dashboardStyle == "on" {
+		database.DB.SetConfigValue("dashboard_style", "plain")
+	} else {
+		database.DB.SetConfigValue("dashboard_style", "colorful")
+	}
+
+	// Log the action
+	user, _ := userFromContext(r.Context())
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     "SETTINGS_UPDATED",
+		EntityType: "Settings",
+		EntityID:   "general",
+		Details:    fmt.Sprintf("{\"server_url\":\"%s\",\"port_changed\":%v}", serverURL, portChanged),
+		IPAddress:  clientIP(r),
+		UserAgent:  r.UserAgent(),
+		Success:    true,
+		ErrorMsg:   "",
+	})
+
+	// Show appropriate success message
+	if portChanged {
+		s.renderAdminSettings(w, fmt.Sprintf("Port changed to %s. ⚠️ RESTART REQUIRED: Stop and start the server for changes to take effect.", port))
+	} else {
+		s.renderAdminSettings(w, "Settings updated successfully!")
+	}
+}
+
+// handleAdminTrash lists all deleted files (trash)
+func (s *Server) handleAdminTrash(w http.ResponseWriter, r *http.Request) {
+	files, err := database.DB.GetDeletedFiles()
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+
+	s.renderAdminTrash(w, files)
+}
+
+// handleAdminPermanentDelete permanently deletes a file
+func (s *Server) handleAdminPermanentDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	fileID := r.FormValue("file_id")
+	if fileID == "" {
+		s.sendError(w, http.StatusBadRequest, "Missing file_id")
+		return
+	}
+
+	// Get file info before deletion
+	// We need to use a special query to get deleted files
+	rows, err := database.DB.GetDeletedFiles()
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to fetch file info")
+		return
+	}
+
+	var fileInfo *database.FileInfo
+	for _, f := range rows {
+		if f.Id == fileID {
+			fileInfo = f
+			break
+		}
+	}
+
+	if fileInfo == nil {
+		s.sendError(w, http.StatusNotFound, "File not found in trash")
+		return
+	}
+
+	// Delete from storage
+	if err := storage.Files.Delete(fileID); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Could not delete file from disk: %v", err)
+		}
+	}
+	s.removeConvertedImages(fileID)
+
+	// Permanently delete from database
+	if err := database.DB.PermanentDeleteFile(fileID); err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to delete file")
+		return
+	}
+
+	log.Printf("File permanently deleted by admin: %s (ID: %s)", fileInfo.Name, fileID)
+
+	// Log the action
+	user, _ := userFromContext(r.Context())
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     "FILE_PERMANENTLY_DELETED",
+		EntityType: "File",
+		EntityID:   fileID,
+		Details:    fmt.Sprintf("{\"filename\":\"%s\",\"size\":\"%s\"}", fileInfo.Name, fileInfo.Size),
+		IPAddress:  clientIP(r),
+		UserAgent:  r.UserAgent(),
+		Success:    true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]string{
+		"message": "File permanently deleted",
+	})
+}
+
+// handleAdminEmptyAllTrash permanently deletes all files in trash
+func (s *Server) handleAdminEmptyAllTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Get all deleted files
+	files, err := database.DB.GetDeletedFiles()
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to fetch trash files")
+		return
+	}
+
+	deletedCount := 0
+	for _, fileInfo := range files {
+		// Delete from storage
+		if err := storage.Files.Delete(fileInfo.Id); err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Warning: Could not delete file from disk: %v", err)
+			}
+		}
+		s.removeConvertedImages(fileInfo.Id)
+
+		// Delete from database
+		if err := database.DB.PermanentDeleteFile(fileInfo.Id); err != nil {
+			log.Printf("Warning: Could not delete file from database: %v", err)
+			continue
+		}
+
+		deletedCount++
+
+		// Audit log each deletion
+		user, _ := userFromContext(r.Context())
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     "FILE_PERMANENTLY_DELETED",
+			EntityType: "File",
 			EntityID:   fileInfo.Id,
 			Details:    fmt.Sprintf("{\"filename\":\"%s\",\"size\":\"%s\",\"method\":\"Empty All Trash\"}", fileInfo.Name, fileInfo.Size),
-			IPAddress:  getClientIP(r),
+			IPAddress:  clientIP(r),
 			UserAgent:  r.UserAgent(),
 			Success:    true,
 		})
@@ -1316,7 +2018,7 @@ func (s *Server) handleAdminRestoreFile(w http.ResponseWriter, r *http.Request)
 		EntityType: "File",
 		EntityID:   fileID,
 		Details:    fmt.Sprintf("{\"filename\":\"%s\",\"size\":\"%s\"}", fileInfo.Name, fileInfo.Size),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 	})
@@ -1337,7 +2039,7 @@ func (s *Server) renderAdminDashboard(w http.ResponseWriter, user *models.User,
 	activeFiles7Days, activeFiles30Days int, avgFileSize int64, avgDownloadsPerFile float64,
 	twoFAAdoption, avgBackupCodes float64,
 	largestFileName string, largestFileSize int64, top5ActiveUsers []string, top5FileCounts []int,
-	topFileTypes []string, fileTypeCounts []int, topWeekday string, weekdayCount int, storagePast, storageNow int64,
+	fileCategories []string, fileCategoryCounts []int, topWeekday string, weekdayCount int, storagePast, storageNow int64,
 	mostDownloadedFile string, downloadCount int, uploadsUsed, diskAvailable int64, duplicateFiles []DuplicateFile) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
@@ -1350,6 +2052,15 @@ func (s *Server) renderAdminDashboard(w http.ResponseWriter, user *models.User,
 	// Get joke of the day
 	joke := models.GetJokeOfTheDay()
 
+	// Queue depth and worker usage of background file processing
+	processing := fileProcessing.Stats()
+
+	// Storage used by each team's shared files
+	if err := database.DB.RecalculateAllTeamStorage(); err != nil {
+		log.Printf("Warning: Could not refresh team storage usage: %v", err)
+	}
+	teams, _ := database.DB.GetAllTeams()
+
 	// Helper function to format bytes
 	formatBytes := func(bytes int64) string {
 		const unit = 1024
@@ -1382,14 +2093,16 @@ func (s *Server) renderAdminDashboard(w http.ResponseWriter, user *models.User,
 	// Format file statistics
 	largestFileSizeStr := formatBytes(largestFileSize)
 
-	// Format trend data - file types string
-	fileTypesStr := ""
-	if len(topFileTypes) > 0 {
-		for i, ext := range topFileTypes {
+	// Format trend data - file category breakdown
+	fileCategoriesStr := "N/A"
+	if len(fileCategories) > 0 {
+		fileCategoriesStr = ""
+		for i, category := range fileCategories {
 			if i > 0 {
-				fileTypesStr += ", "
+				fileCategoriesStr += "<br>"
 			}
-			fileTypesStr += fmt.Sprintf(".%s (%d)", ext, fileTypeCounts[i])
+			fileCategoriesStr += fmt.Sprintf(`<a href="/admin/files?category=%s" style="color: inherit; text-decoration: none;">%s (%d)</a>`,
+				category, database.FileCategoryLabel(category), fileCategoryCounts[i])
 		}
 	}
 
@@ -1406,7 +2119,7 @@ func (s *Server) renderAdminDashboard(w http.ResponseWriter, user *models.User,
 	diskAvailableStr := formatBytes(diskAvailable)
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -1550,7 +2263,7 @@ func (s *Server) renderAdminDashboard(w http.ResponseWriter, user *models.User,
     ` + s.getAdminHeaderHTML("") + `
 
     <div class="main-content max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-8">
-
+` + expiredFilesBannerHTML() + `
         <!-- File Sharing Wisdom Banner -->
         <div class="wisdom-banner relative overflow-hidden rounded-2xl mb-12 transition-all duration-500 hover:scale-[1.02]">
             <div class="p-6 sm:p-8">
@@ -1753,12 +2466,65 @@ func (s *Server) renderAdminDashboard(w http.ResponseWriter, user *models.User,
             </div>
         </div>
 
-        <!-- Trend Data -->
-        <h2 class="section-title text-3xl mb-8">⚡ Trend Data</h2>
-        <div class="grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-4 gap-6 mb-16">
-            <div class="glass-card rounded-2xl p-6">
-                <h3 class="text-xs font-bold text-slate-700 uppercase tracking-widest mb-4">Top File Types</h3>
-                <div class="text-lg font-bold text-slate-900 break-words">` + fileTypesStr + `</div>
+        <!-- Background Processing -->
+        <h2 class="section-title text-3xl mb-8">⚙️ Background Processing</h2>
+        <div class="grid grid-cols-1 md:grid-cols-3 gap-6 mb-16">
+            <div class="glass-card rounded-2xl p-8">
+                <h3 class="text-xs font-bold text-cyan-600 uppercase tracking-widest mb-5">Queue Depth</h3>
+                <div class="stat-number text-5xl font-extrabold mb-3">` + fmt.Sprintf("%d", processing.Queued) + `</div>
+                <p class="text-sm text-slate-600 font-medium">Jobs waiting for a free worker</p>
+            </div>
+            <div class="glass-card rounded-2xl p-8">
+                <h3 class="text-xs font-bold text-cyan-600 uppercase tracking-widest mb-5">Worker Saturation</h3>
+                <div class="stat-number text-5xl font-extrabold mb-3">` + fmt.Sprintf("%.0f%%", processing.Saturation()) + `</div>
+                <p class="text-sm text-slate-600 font-medium">` + fmt.Sprintf("%d of %d workers busy", processing.Active, processing.Workers) + `</p>
+            </div>
+            <div class="glass-card rounded-2xl p-8">
+                <h3 class="text-xs font-bold text-cyan-600 uppercase tracking-widest mb-5">Processed Since Start</h3>
+                <div class="stat-number text-5xl font-extrabold mb-3">` + fmt.Sprintf("%d", processing.Completed) + `</div>
+                <p class="text-sm text-slate-600 font-medium">` + fmt.Sprintf("%d failed", processing.Failed) + `</p>
+            </div>
+        </div>
+
+        <!-- Team Storage -->
+        <h2 class="section-title text-3xl mb-8">👥 Team Storage</h2>
+        <div class="glass-card rounded-2xl p-8 mb-16">
+            ` + func() string {
+		if len(teams) == 0 {
+			return `<p class="text-slate-600 text-center">No teams yet.</p>`
+		}
+
+		html := `<div class="space-y-4">`
+		for _, team := range teams {
+			percent := team.GetStoragePercentage()
+			barColor := "#10b981"
+			if percent >= 90 {
+				barColor = "#ef4444"
+			} else if percent >= 75 {
+				barColor = "#f59e0b"
+			}
+			html += `
+                <div>
+                    <div class="flex justify-between text-sm mb-1">
+                        <span class="font-bold text-slate-900">` + template.HTMLEscapeString(team.Name) + `</span>
+                        <span class="text-slate-600">` + formatBytes(team.StorageUsedMB*1024*1024) + ` of ` + formatBytes(team.StorageQuotaMB*1024*1024) + fmt.Sprintf(" (%d%%)", percent) + `</span>
+                    </div>
+                    <div style="height: 8px; background: #e2e8f0; border-radius: 4px; overflow: hidden;">
+                        <div style="height: 100%; width: ` + fmt.Sprintf("%d", min(percent, 100)) + `%; background: ` + barColor + `;"></div>
+                    </div>
+                </div>`
+		}
+		html += `</div>`
+		return html
+	}() + `
+        </div>
+
+        <!-- Trend Data -->
+        <h2 class="section-title text-3xl mb-8">⚡ Trend Data</h2>
+        <div class="grid grid-cols-1 sm:grid-cols-2 lg:grid-cols-4 gap-6 mb-16">
+            <div class="glass-card rounded-2xl p-6">
+                <h3 class="text-xs font-bold text-slate-700 uppercase tracking-widest mb-4">File Categories</h3>
+                <div class="text-lg font-bold text-slate-900 break-words">` + fileCategoriesStr + `</div>
             </div>
             <div class="glass-card rounded-2xl p-6">
                 <h3 class="text-xs font-bold text-slate-700 uppercase tracking-widest mb-4">Most Active Day</h3>
@@ -1841,12 +2607,12 @@ func (s *Server) renderAdminDashboard(w http.ResponseWriter, user *models.User,
 	w.Write([]byte(html))
 }
 
-func (s *Server) renderAdminUsers(w http.ResponseWriter, users []*models.User, downloadAccounts []*models.DownloadAccount,
+func (s *Server) renderAdminUsers(w http.ResponseWriter, viewer *models.User, users []*models.User, downloadAccounts []*models.DownloadAccount,
 	userFilter *database.UserFilter, userCount int, dlFilter *database.DownloadAccountFilter, dlCount int) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -2108,7 +2874,10 @@ func (s *Server) renderAdminUsers(w http.ResponseWriter, users []*models.User, d
     <div class="container">
         <div class="actions">
             <h2>Manage Users</h2>
-            <a href="/admin/users/create" class="btn">+ Create User</a>
+            <div style="display: flex; gap: 10px; flex-wrap: wrap;">
+                <a href="/admin/users/pending-setup" class="btn">📨 Pending Setup</a>
+                <a href="/admin/users/create" class="btn">+ Create User</a>
+            </div>
         </div>
 
         <!-- User Filters -->
@@ -2192,6 +2961,13 @@ func (s *Server) renderAdminUsers(w http.ResponseWriter, users []*models.User, d
 			status = "Inactive"
 		}
 
+		// The super admin can view the app as any other active user, for support
+		viewAsLink := ""
+		if viewer != nil && viewer.IsSuperAdmin() && viewer.ImpersonatedBy == nil && !u.IsSuperAdmin() && u.IsActive {
+			viewAsLink = fmt.Sprintf(`
+                        <a href="#" onclick="impersonateUser(%d, '%s'); return false;">View as</a>`, u.Id, template.JSEscapeString(template.HTMLEscapeString(u.Name)))
+		}
+
 		html += fmt.Sprintf(`
                 <tr>
                     <td data-label="Name">%s</td>
@@ -2201,11 +2977,11 @@ func (s *Server) renderAdminUsers(w http.ResponseWriter, users []*models.User, d
                     <td data-label="Used">%d MB</td>
                     <td data-label="Status">%s</td>
                     <td data-label="Actions" class="action-links">
-                        <a href="/admin/users/edit?id=%d">Edit</a>
+                        <a href="/admin/users/edit?id=%d">Edit</a>%s
                         <a href="#" onclick="deleteUser(%d); return false;">Delete</a>
                     </td>
                 </tr>`,
-			u.Name, u.Email, levelBadge, u.StorageQuotaMB/1000, u.StorageUsedMB, status, u.Id, u.Id)
+			u.Name, u.Email, levelBadge, u.StorageQuotaMB/1000, u.StorageUsedMB, status, u.Id, viewAsLink, u.Id)
 	}
 
 	html += `
@@ -2403,6 +3179,21 @@ func (s *Server) renderAdminUsers(w http.ResponseWriter, users []*models.User, d
             window.location.href = '/admin/users?' + params.toString();
         }
 
+        function impersonateUser(id, name) {
+            if (!confirm('View the app as ' + name + '?\n\nYou will see their dashboard and can act as them until you exit. Everything you change is recorded in the audit log with your name.')) return;
+
+            const form = document.createElement('form');
+            form.method = 'POST';
+            form.action = '/admin/users/impersonate';
+            const input = document.createElement('input');
+            input.type = 'hidden';
+            input.name = 'user_id';
+            input.value = id;
+            form.appendChild(input);
+            document.body.appendChild(form);
+            form.submit();
+        }
+
         async function deleteUser(id) {
             if (!confirm('Are you sure you want to delete this user?\n\nIf you choose yes, the account will be deleted and all the user\'s uploaded files will be available in the trash for 5 days if not deleted manually.')) return;
 
@@ -2470,7 +3261,7 @@ func (s *Server) renderAdminUserForm(w http.ResponseWriter, user *models.User, e
 	}
 
 	html := `<!DOCTYPE html>
-<html>
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta name="author" content="Ulf Holmström">
     <title>` + title + `</title>
@@ -2526,7 +3317,8 @@ func (s *Server) renderAdminUserForm(w http.ResponseWriter, user *models.User, e
 		return ""
 	}() + `>
             <button type="button" onclick="togglePassword('password')" style="position: absolute; right: 8px; top: 50%; transform: translateY(-50%); background: transparent; border: none; cursor: pointer; font-size: 20px; padding: 0; width: 30px; height: 30px;">👁️</button>
-        </div>` + func() string {
+        </div>
+        <div style="color: #666; font-size: 13px; margin-top: 4px;">` + template.HTMLEscapeString(auth.PasswordRequirements()) + `</div>` + func() string {
 		if !isEdit {
 			return `
 
@@ -2571,6 +3363,21 @@ func (s *Server) renderAdminUserForm(w http.ResponseWriter, user *models.User, e
 	}() + ` style="width: auto; margin-right: 8px;">
             <span>Active (user can log in)</span>
         </label>` + func() string {
+		if isEdit {
+			window, err := database.DB.GetUserUploadWindow(user.Id)
+			if err != nil {
+				window = &database.FileSchedule{}
+			}
+			return `
+
+        <br><br>
+        <label>Upload Hours:</label>` + uploadWindowFieldsHTML("upload_window", window) + `
+        <div style="font-size: 13px; color: #666; margin-top: 4px;">
+            Only allow this user to upload during these hours, in addition to the global upload hours. Leave empty to not restrict them; no days checked means every day. Admins can always upload.
+        </div>`
+		}
+		return ""
+	}() + func() string {
 		if !isEdit {
 			return `
 
@@ -2632,7 +3439,7 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
 	totalStorageGB := fmt.Sprintf("%.2f GB", float64(totalStorage)/(1024*1024*1024))
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -2808,6 +3615,7 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
         }
         .badge-active { background: #e8f5e9; color: #2e7d32; }
         .badge-expired { background: #ffebee; color: #c62828; }
+        .badge-scheduled { background: #e3f2fd; color: #1565c0; }
         .badge-auth { background: #e3f2fd; color: #1976d2; }
         .btn {
             padding: 8px 16px;
@@ -3052,8 +3860,23 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
                 <option value="user-asc">👤 User (A-Z)</option>
                 <option value="user-desc">👤 User (Z-A)</option>
             </select>
+            <select id="fileStatusFilter" onchange="applyStatusFilter()" style="padding: 10px 15px; border: 2px solid #e0e0e0; border-radius: 8px; font-size: 14px; background: white; cursor: pointer;">
+                <option value="">All statuses</option>
+                <option value="active">Active</option>
+                <option value="scheduled">Scheduled</option>
+                <option value="expired">Expired</option>
+                <option value="public">Public links</option>
+                <option value="auth">Login required</option>
+            </select>
+            <select id="fileCategoryFilter" onchange="applyCategoryFilter()" style="padding: 10px 15px; border: 2px solid #e0e0e0; border-radius: 8px; font-size: 14px; background: white; cursor: pointer;">
+                <option value="">All categories</option>` + fileCategoryOptionsHTML("") + `
+            </select>
+            <button onclick="exportFiles('csv')" style="padding: 10px 15px; border: none; border-radius: 8px; font-size: 14px; background: ` + s.getPrimaryColor() + `; color: white; cursor: pointer; font-weight: 500;">⬇️ Export CSV</button>
+            <button onclick="exportFiles('json')" style="padding: 10px 15px; border: none; border-radius: 8px; font-size: 14px; background: ` + s.getPrimaryColor() + `; color: white; cursor: pointer; font-weight: 500;">⬇️ Export JSON</button>
         </div>
 
+` + bulkSelectionHTML("/admin/files/bulk-delete", "🗑️ Move Selected to Trash", "Move %d file(s) to trash?", "#dc3545") + `
+
         <div class="files-section">
             <ul class="file-list">`
 
@@ -3064,6 +3887,7 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
                 </li>`
 	}
 
+	externalLinkLabel := database.DB.GetExternalLinkLabel()
 	for _, f := range files {
 		// Get user info
 		userName := "Deleted user"
@@ -3076,8 +3900,10 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
 		status := `<span class="badge badge-active">Active</span>`
 		if !f.UnlimitedDownloads && f.DownloadsRemaining <= 0 {
 			status = `<span class="badge badge-expired">Expired</span>`
-		} else if !f.UnlimitedTime && f.ExpireAt > 0 && f.ExpireAt < time.Now().Unix() {
+		} else if f.ExpiredReason(time.Now()) == database.FileExpiredByTime {
 			status = `<span class="badge badge-expired">Expired</span>`
+		} else if f.IsScheduled(time.Now()) {
+			status = `<span class="badge badge-scheduled" title="Live from ` + time.Unix(f.ActivateAt, 0).In(database.ServerLocation()).Format(downloadLockTimeLayout) + `">Scheduled</span>`
 		}
 
 		// Auth badge
@@ -3097,12 +3923,22 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
 
 		downloadURL := s.getPublicURL() + "/d/" + f.Id
 
+		// Short checksum, the full one is shown on hover
+		checksumInfo := ""
+		if len(f.SHA256) == 64 {
+			checksumInfo = ` • SHA-256: <code title="` + f.SHA256 + `">` + f.SHA256[:12] + `…</code>`
+		}
+
 		// Note display
 		noteDisplay := ""
 		if f.Comment != "" {
 			noteDisplay = fmt.Sprintf(`<p class="file-note"><strong>📝 Note:</strong> %s</p>`,
 				template.HTMLEscapeString(f.Comment))
 		}
+		if externalLink := database.DB.ExternalFileLink(f); externalLink != "" {
+			noteDisplay += fmt.Sprintf(`<p class="file-note"><strong>🔗 %s:</strong> <a href="%s" target="_blank" rel="noopener noreferrer">%s</a></p>`,
+				template.HTMLEscapeString(externalLinkLabel), template.HTMLEscapeString(externalLink), template.HTMLEscapeString(externalLink))
+		}
 
 		// Get file extension
 		fileExt := filepath.Ext(f.Name)
@@ -3112,25 +3948,29 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
 
 		html += fmt.Sprintf(`
                 <li class="file-item" data-filename="%s" data-extension="%s" data-size="%d" data-timestamp="%d" data-downloads="%d" data-username="%s" data-comment="%s">
+                    %s
                     <div class="file-info">
                         <h3 title="%s">
                             <span style="display: inline-block; max-width: 600px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; vertical-align: bottom;">📄 %s</span>%s%s
                         </h3>
-                        <p>%s • %s • %d downloads • Expires: %s</p>
+                        <p>%s • %s • %d downloads • Expires: %s%s</p>
                         %s
                     </div>
                     <div class="file-actions">
                         <button class="btn btn-secondary" onclick="showDownloadHistory('%s', '%s')">📊 History</button>
+                        <a class="btn btn-secondary" href="/file/versions?file_id=%s" style="text-decoration: none;">🕘 Versions</a>
                         <button class="btn btn-primary" onclick="copyToClipboard('%s', this)">📋 Copy</button>
                         <button class="btn btn-danger" onclick="deleteFile('%s')">🗑️ Delete</button>
                     </div>
                 </li>`,
 			template.HTMLEscapeString(f.Name), fileExt, f.SizeBytes, f.UploadDate, f.DownloadCount, userName, template.HTMLEscapeString(f.Comment),
+			bulkCheckboxHTML(f.Id),
 			template.HTMLEscapeString(f.Name),
 			f.Name, authBadge, status,
-			userName, f.Size, f.DownloadCount, expiryInfo,
+			userName, f.Size, f.DownloadCount, expiryInfo, checksumInfo,
 			noteDisplay,
 			f.Id, f.Name,
+			url.QueryEscape(f.Id),
 			downloadURL,
 			f.Id)
 	}
@@ -3206,7 +4046,8 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
             fetch('/file/downloads?file_id=' + encodeURIComponent(fileId))
                 .then(response => response.json())
                 .then(data => {
-                    if (data.logs && data.logs.length > 0) {
+                    const logs = data.downloadLogs || [];
+                    if (logs.length > 0) {
                         let html = '<table style="width: 100%; border-collapse: collapse;">';
                         html += '<thead><tr style="background: #f5f5f5; border-bottom: 2px solid #ddd;">';
                         html += '<th style="padding: 12px; text-align: left;">Date & Time</th>';
@@ -3214,7 +4055,7 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
                         html += '<th style="padding: 12px; text-align: left;">IP Address</th>';
                         html += '</tr></thead><tbody>';
 
-                        data.logs.forEach(log => {
+                        logs.forEach(log => {
                             const date = new Date(log.downloadedAt * 1000);
                             const dateStr = date.toLocaleString('sv-SE');
                             const downloader = log.email || 'Anonymous';
@@ -3229,7 +4070,7 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
                         });
 
                         html += '</tbody></table>';
-                        html += '<p style="margin-top: 16px; color: #666; font-size: 14px;">Total downloads: ' + data.logs.length + '</p>';
+                        html += '<p style="margin-top: 16px; color: #666; font-size: 14px;">Showing the latest ' + logs.length + ' of ' + data.downloadTotal + ' downloads</p>';
                         document.getElementById('downloadHistoryContent').innerHTML = html;
                     } else {
                         document.getElementById('downloadHistoryContent').innerHTML = '<p style="text-align: center; color: #999;">No downloads yet</p>';
@@ -3245,6 +4086,51 @@ func (s *Server) renderAdminFiles(w http.ResponseWriter, files []*database.FileI
             document.getElementById('downloadHistoryModal').style.display = 'none';
         }
 
+        // Restore server-side filters from the URL
+        (function() {
+            const params = new URLSearchParams(window.location.search);
+            document.getElementById('fileStatusFilter').value = params.get('status') || '';
+            document.getElementById('fileCategoryFilter').value = params.get('category') || '';
+            if (params.get('search')) {
+                document.getElementById('fileSearch').value = params.get('search');
+            }
+        })();
+
+        function applyStatusFilter() {
+            const params = new URLSearchParams(window.location.search);
+            const status = document.getElementById('fileStatusFilter').value;
+            if (status) {
+                params.set('status', status);
+            } else {
+                params.delete('status');
+            }
+            window.location.search = params.toString();
+        }
+
+        function applyCategoryFilter() {
+            const params = new URLSearchParams(window.location.search);
+            const category = document.getElementById('fileCategoryFilter').value;
+            if (category) {
+                params.set('category', category);
+            } else {
+                params.delete('category');
+            }
+            window.location.search = params.toString();
+        }
+
+        // Export the inventory with the same filters as the list
+        function exportFiles(format) {
+            const params = new URLSearchParams(window.location.search);
+            params.set('format', format);
+            const search = document.getElementById('fileSearch').value.trim();
+            if (search) {
+                params.set('search', search);
+            } else {
+                params.delete('search');
+            }
+            window.location.href = '/admin/files/export?' + params.toString();
+        }
+
         // Search and sort files function
         function searchAndSortFiles() {
             const searchTerm = document.getElementById('fileSearch').value.toLowerCase();
@@ -3380,7 +4266,7 @@ func (s *Server) renderAdminDuplicates(w http.ResponseWriter, files []*database.
 	totalPages := (totalFiles + limit - 1) / limit
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -3492,6 +4378,7 @@ func (s *Server) renderAdminDuplicates(w http.ResponseWriter, files []*database.
         .badge-duplicate { background: #ff9800; color: white; }
         .badge-active { background: #e8f5e9; color: #2e7d32; }
         .badge-expired { background: #ffebee; color: #c62828; }
+        .badge-scheduled { background: #e3f2fd; color: #1565c0; }
         .badge-auth { background: #e3f2fd; color: #1976d2; }
         .btn {
             padding: 8px 16px;
@@ -3640,8 +4527,10 @@ func (s *Server) renderAdminDuplicates(w http.ResponseWriter, files []*database.
 		status := `<span class="badge badge-active">Active</span>`
 		if !f.UnlimitedDownloads && f.DownloadsRemaining <= 0 {
 			status = `<span class="badge badge-expired">Expired</span>`
-		} else if !f.UnlimitedTime && f.ExpireAt > 0 && f.ExpireAt < time.Now().Unix() {
+		} else if f.ExpiredReason(time.Now()) == database.FileExpiredByTime {
 			status = `<span class="badge badge-expired">Expired</span>`
+		} else if f.IsScheduled(time.Now()) {
+			status = `<span class="badge badge-scheduled" title="Live from ` + time.Unix(f.ActivateAt, 0).In(database.ServerLocation()).Format(downloadLockTimeLayout) + `">Scheduled</span>`
 		}
 
 		// Duplicate badge
@@ -3781,7 +4670,7 @@ func (s *Server) renderAdminBranding(w http.ResponseWriter, message string) {
 	brandingConfig, _ := database.DB.GetBrandingConfig()
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -3880,7 +4769,7 @@ func (s *Server) renderAdminBranding(w http.ResponseWriter, message string) {
         <h2>Branding Settings</h2>`
 
 	if message != "" {
-		html += `<div class="message">` + message + `</div>`
+		html += `<div class="message">` + template.HTMLEscapeString(message) + `</div>`
 	}
 
 	html += `
@@ -3908,19 +4797,61 @@ func (s *Server) renderAdminBranding(w http.ResponseWriter, message string) {
                 <div class="form-group">
                     <label>Primary Color</label>
                     <div class="color-input">
-                        <input type="color" name="primary_color" value="` + brandingConfig["branding_primary_color"] + `">
-                        <input type="text" value="` + brandingConfig["branding_primary_color"] + `" readonly>
+                        <input type="color" name="primary_color" value="` + template.HTMLEscapeString(brandingConfig["branding_primary_color"]) + `">
+                        <input type="text" value="` + template.HTMLEscapeString(brandingConfig["branding_primary_color"]) + `" readonly>
                     </div>
                 </div>
 
                 <div class="form-group">
                     <label>Secondary Color</label>
                     <div class="color-input">
-                        <input type="color" name="secondary_color" value="` + brandingConfig["branding_secondary_color"] + `">
-                        <input type="text" value="` + brandingConfig["branding_secondary_color"] + `" readonly>
+                        <input type="color" name="secondary_color" value="` + template.HTMLEscapeString(brandingConfig["branding_secondary_color"]) + `">
+                        <input type="text" value="` + template.HTMLEscapeString(brandingConfig["branding_secondary_color"]) + `" readonly>
                     </div>
                 </div>
 
+                <h3 style="margin: 30px 0 15px; color: #333;">Login Page</h3>
+
+                <div class="form-group">
+                    <label>Welcome Text</label>
+                    <input type="text" name="login_welcome_text" value="` + template.HTMLEscapeString(brandingConfig["branding_login_welcome_text"]) + `" placeholder="Secure File Sharing">
+                </div>
+
+                <div class="form-group">
+                    <label>Support Contact</label>
+                    <input type="text" name="login_support_contact" value="` + template.HTMLEscapeString(brandingConfig["branding_login_support_contact"]) + `" placeholder="helpdesk@example.com or +46 8 123 456">
+                </div>
+
+                <div class="form-group">
+                    <label>Background</label>
+                    <input type="text" name="login_background" value="` + template.HTMLEscapeString(brandingConfig["branding_login_background"]) + `" placeholder="#1e293b or https://example.com/background.jpg">
+                    <p style="margin-top: 6px; color: #666; font-size: 13px;">A CSS color or an image URL. Leave empty to use the primary/secondary color gradient.</p>
+                </div>
+
+                <div class="form-group">
+                    <label>SSO Login URL</label>
+                    <input type="text" name="login_sso_url" value="` + template.HTMLEscapeString(brandingConfig["branding_login_sso_url"]) + `" placeholder="https://sso.example.com/login">
+                    <p style="margin-top: 6px; color: #666; font-size: 13px;">Shows a single sign-on button on the login page. Leave empty to hide it.</p>
+                </div>
+
+                <div class="form-group">
+                    <label>SSO Button Label</label>
+                    <input type="text" name="login_sso_label" value="` + template.HTMLEscapeString(brandingConfig["branding_login_sso_label"]) + `" placeholder="Sign in with SSO">
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" name="login_sso_only" ` + func() string {
+		if brandingConfig["branding_login_sso_only"] == "true" {
+			return "checked"
+		}
+		return ""
+	}() + ` style="margin-right: 10px; width: 20px; height: 20px;">
+                        <span>SSO-only mode (hide the password form)</span>
+                    </label>
+                    <p style="margin-top: 6px; color: #666; font-size: 13px;">Requires an SSO login URL. Admins can still sign in with a password at <code>/login/local</code> if SSO is misconfigured.</p>
+                </div>
+
                 <button type="submit" class="btn">Save Changes</button>
             </form>
         </div>
@@ -3935,242 +4866,990 @@ func (s *Server) renderAdminBranding(w http.ResponseWriter, message string) {
 func (s *Server) renderAdminSettings(w http.ResponseWriter, message string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	// Get current settings
-	serverURL, _ := database.DB.GetConfigValue("server_url")
-	if serverURL == "" {
-		serverURL = s.config.ServerURL
-	}
-	// Strip port from URL for display
-	serverURL = stripPortFromURL(serverURL)
-	maxFileSizeMB, _ := database.DB.GetConfigValue("max_file_size_mb")
-	if maxFileSizeMB == "" {
-		maxFileSizeMB = "2000"
-	}
-	defaultQuotaMB, _ := database.DB.GetConfigValue("default_quota_mb")
-	if defaultQuotaMB == "" {
-		defaultQuotaMB = "5000"
-	}
-	trashRetentionDays, _ := database.DB.GetConfigValue("trash_retention_days")
-	if trashRetentionDays == "" {
-		if s.config.TrashRetentionDays > 0 {
-			trashRetentionDays = fmt.Sprintf("%d", s.config.TrashRetentionDays)
-		} else {
-			trashRetentionDays = "5"
-		}
-	}
-	auditLogRetentionDays, _ := database.DB.GetConfigValue("audit_log_retention_days")
-	if auditLogRetentionDays == "" {
-		if s.config.AuditLogRetentionDays > 0 {
-			auditLogRetentionDays = fmt.Sprintf("%d", s.config.AuditLogRetentionDays)
-		} else {
-			auditLogRetentionDays = "90"
-		}
-	}
-	auditLogMaxSizeMB, _ := database.DB.GetConfigValue("audit_log_max_size_mb")
-	if auditLogMaxSizeMB == "" {
-		if s.config.AuditLogMaxSizeMB > 0 {
-			auditLogMaxSizeMB = fmt.Sprintf("%d", s.config.AuditLogMaxSizeMB)
-		} else {
-			auditLogMaxSizeMB = "100"
-		}
-	}
-	serverLogMaxSizeMB, _ := database.DB.GetConfigValue("server_log_max_size_mb")
-	if serverLogMaxSizeMB == "" {
-		if s.config.ServerLogMaxSizeMB > 0 {
-			serverLogMaxSizeMB = fmt.Sprintf("%d", s.config.ServerLogMaxSizeMB)
-		} else {
-			serverLogMaxSizeMB = "50"
-		}
-	}
+	// Get current settings
+	serverURL, _ := database.DB.GetConfigValue("server_url")
+	if serverURL == "" {
+		serverURL = s.config.ServerURL
+	}
+	// Strip port from URL for display
+	serverURL = stripPortFromURL(serverURL)
+	ipRules := getIPAccessRules()
+	maxFileSizeMB, _ := database.DB.GetConfigValue("max_file_size_mb")
+	if maxFileSizeMB == "" {
+		maxFileSizeMB = "2000"
+	}
+	defaultQuotaMB, _ := database.DB.GetConfigValue("default_quota_mb")
+	if defaultQuotaMB == "" {
+		defaultQuotaMB = "5000"
+	}
+	trashRetentionDays, _ := database.DB.GetConfigValue("trash_retention_days")
+	if trashRetentionDays == "" {
+		if s.config.TrashRetentionDays > 0 {
+			trashRetentionDays = fmt.Sprintf("%d", s.config.TrashRetentionDays)
+		} else {
+			trashRetentionDays = "5"
+		}
+	}
+	trashRetentionMinDays, trashRetentionMaxDays := database.DB.GetTrashRetentionOverrideBounds()
+	auditLogRetentionDays, _ := database.DB.GetConfigValue("audit_log_retention_days")
+	if auditLogRetentionDays == "" {
+		if s.config.AuditLogRetentionDays > 0 {
+			auditLogRetentionDays = fmt.Sprintf("%d", s.config.AuditLogRetentionDays)
+		} else {
+			auditLogRetentionDays = "90"
+		}
+	}
+	auditLogMaxSizeMB, _ := database.DB.GetConfigValue("audit_log_max_size_mb")
+	promotionNotificationChecked := ""
+	if isPromotionNotificationEnabled() {
+		promotionNotificationChecked = "checked"
+	}
+	if auditLogMaxSizeMB == "" {
+		if s.config.AuditLogMaxSizeMB > 0 {
+			auditLogMaxSizeMB = fmt.Sprintf("%d", s.config.AuditLogMaxSizeMB)
+		} else {
+			auditLogMaxSizeMB = "100"
+		}
+	}
+	loginMaxPerEmail, loginMaxPerIP, loginWindow := auth.LoginRateLimits()
+	passwordMinLength := auth.PasswordMinLength()
+	passwordHashAlgorithm := auth.PasswordHashAlgorithm()
+	bcryptCost := auth.BcryptCostSetting()
+	argon2MemoryMB, argon2Iterations := auth.Argon2Settings()
+	serverLogMaxSizeMB, _ := database.DB.GetConfigValue("server_log_max_size_mb")
+	if serverLogMaxSizeMB == "" {
+		if s.config.ServerLogMaxSizeMB > 0 {
+			serverLogMaxSizeMB = fmt.Sprintf("%d", s.config.ServerLogMaxSizeMB)
+		} else {
+			serverLogMaxSizeMB = "50"
+		}
+	}
+	maxFileExpiryDays := database.DB.GetMaxFileExpiryDays()
+	deletedAccountRetentionDays := database.DB.GetDeletedAccountRetentionDays()
+	fileRequestRetentionDays := database.DB.GetConfigInt("file_request_retention_days", database.DefaultFileRequestRetentionDays)
+	tokenRetentionInputs := ""
+	for _, tokenType := range database.TokenTypes {
+		tokenRetentionInputs += fmt.Sprintf(`
+                    <label for="%s" style="font-weight: normal; margin-top: 8px;">%s</label>
+                    <input type="number" id="%s" name="%s" value="%d" min="0" required>`,
+			tokenType.RetentionConfigKey(), tokenType.Label, tokenType.RetentionConfigKey(), tokenType.RetentionConfigKey(), database.DB.GetTokenRetentionHours(tokenType))
+	}
+	maxPublicLinks := database.DB.GetConfigInt("max_public_links", 0)
+	activePublicLinks, _ := database.DB.CountActivePublicFiles()
+	publicLinksUsage := fmt.Sprintf("Currently %d active public links", activePublicLinks)
+	if maxPublicLinks > 0 {
+		publicLinksUsage = fmt.Sprintf("Currently %d of %d active public links in use", activePublicLinks, maxPublicLinks)
+	}
+
+	downloadTokenTTL := database.DB.GetConfigInt("download_token_ttl_seconds", DefaultDownloadTokenTTLSeconds)
+	teamZipMaxMB := database.DB.GetConfigInt("team_zip_max_mb", DefaultTeamZipMaxMB)
+	zipDownloadMaxMB := database.DB.GetConfigInt("zip_download_max_mb", DefaultZipDownloadMaxMB)
+	downloadMaxMBps := database.DB.GetConfigInt("download_max_mbps", 0)
+	burnAfterDownloadMode := database.DB.GetBurnAfterDownloadMode()
+	processingWorkers := getProcessingWorkers()
+
+	showChecksumChecked := ""
+	if isChecksumDisplayEnabled() {
+		showChecksumChecked = "checked"
+	}
+
+	// File links in notification emails are on unless disabled
+	emailDeepLinksChecked := "checked"
+	if value, _ := database.DB.GetConfigValue("email_file_deep_links"); value == "false" {
+		emailDeepLinksChecked = ""
+	}
+	// Emailed share links are signed unless disabled
+	emailLinkSigningChecked := ""
+	if emailpkg.IsShareLinkSigningEnabled() {
+		emailLinkSigningChecked = "checked"
+	}
+	emailLinkExpiryHours := database.DB.GetConfigInt("email_link_expiry_hours", 0)
+	siemFormat := getSIEMFormat()
+
+	externalLinkLabel, _ := database.DB.GetConfigValue("external_link_label")
+	externalLinkInNotificationsChecked := ""
+	if database.DB.IsExternalLinkInNotificationsEnabled() {
+		externalLinkInNotificationsChecked = "checked"
+	}
+	emailLinkAllowPlainChecked := ""
+	if value, _ := database.DB.GetConfigValue("email_link_allow_plain"); value == "true" {
+		emailLinkAllowPlainChecked = "checked"
+	}
+	expiredFileGraceHours := database.DB.GetConfigInt("expired_file_grace_hours", database.DefaultExpiredFileGraceHours)
+	expiredFilesAutoTrashChecked := ""
+	if value, _ := database.DB.GetConfigValue("expired_files_auto_trash"); value == "true" {
+		expiredFilesAutoTrashChecked = "checked"
+	}
+	splashViewerTrackingChecked := ""
+	if database.DB.IsSplashViewerTrackingEnabled() {
+		splashViewerTrackingChecked = "checked"
+	}
+	imageConversionChecked := ""
+	if isImageConversionEnabled() {
+		imageConversionChecked = "checked"
+	}
+	convertedDownloadChecked := ""
+	if isConvertedDownloadEnabled() {
+		convertedDownloadChecked = "checked"
+	}
+	imageConversionFormat := getImageConversionFormat()
+	imageConversionMaxMB := database.DB.GetConfigInt("image_conversion_max_mb", DefaultImageConversionMaxMB)
+
+	clamavChecked := ""
+	if database.DB.IsVirusScanningEnabled() {
+		clamavChecked = "checked"
+	}
+	deleteInfectedChecked := ""
+	if database.DB.IsDeleteInfectedEnabled() {
+		deleteInfectedChecked = "checked"
+	}
+	failOpenChecked := ""
+	if database.DB.IsScanFailOpenEnabled() {
+		failOpenChecked = "checked"
+	}
+	clamavStatus := ""
+	unscannedFiles := 0
+	if database.DB.IsVirusScanningEnabled() {
+		clamavStatus = virusScanningStatus()
+		if counts, err := database.DB.CountFilesByScanStatus(); err == nil {
+			unscannedFiles = counts[database.ScanError]
+		}
+	}
+
+	stripMetadataDefaultChecked := ""
+	if database.DB.GetStripMetadataDefault() {
+		stripMetadataDefaultChecked = "checked"
+	}
+	stripDocumentMetadataChecked := ""
+	if database.DB.IsDocumentMetadataStrippingEnabled() {
+		stripDocumentMetadataChecked = "checked"
+	}
+	fileTypePolicy := database.DB.GetUploadFileTypePolicy()
+	verifyFileTypeChecked := ""
+	if fileTypePolicy.VerifyContent {
+		verifyFileTypeChecked = "checked"
+	}
+
+	downloadLogDetailsChecked := ""
+	if !database.DB.IsDownloadLogDetailsDisabled() {
+		downloadLogDetailsChecked = "checked"
+	}
+
+	downloadAnomalyRules := database.DB.GetDownloadAnomalyRules()
+	privateDownloadLogDefaultChecked := ""
+	if database.DB.GetPrivateDownloadLogDefault() {
+		privateDownloadLogDefaultChecked = "checked"
+	}
+
+	recipientLanguageChecked := ""
+	if isRecipientLanguageDetectionEnabled() {
+		recipientLanguageChecked = "checked"
+	}
+
+	uploadApprovalChecked := ""
+	if value, _ := database.DB.GetConfigValue("upload_approval_required"); value == "true" {
+		uploadApprovalChecked = "checked"
+	}
+	uploadApproverEmails, _ := database.DB.GetConfigValue("upload_approver_emails")
+
+	timezone, _ := database.DB.GetConfigValue("timezone")
+
+	currentLocale := deploymentLocale()
+	languageOptions := ""
+	for _, locale := range i18n.Supported() {
+		selected := ""
+		if locale.Code == currentLocale.Code {
+			selected = " selected"
+		}
+		direction := ""
+		if locale.IsRTL() {
+			direction = " (right-to-left)"
+		}
+		languageOptions += `<option value="` + locale.Code + `"` + selected + `>` + locale.Name + direction + `</option>`
+	}
+
+	maxActiveFileRequests := database.DB.GetConfigInt("max_active_file_requests", 0)
+	fileRequestExemptAdminsChecked := ""
+	if value, _ := database.DB.GetConfigValue("file_request_limit_exempt_admins"); value == "true" {
+		fileRequestExemptAdminsChecked = "checked"
+	}
+
+	welcomeEmailDailyCap := database.DB.GetWelcomeEmailDailyCap()
+	emailMaxAttempts := database.DB.GetEmailMaxAttempts()
+	trustedDeviceDays := database.DB.GetTrustedDeviceDays()
+	sessionHours := database.DB.GetSessionHours()
+	rememberMeDays := database.DB.GetRememberMeDays()
+	expiryReminderLeads := database.DB.GetExpiryReminderLeadHours()
+	expiryReminderLeadValues := make([]string, len(expiryReminderLeads))
+	for i, lead := range expiryReminderLeads {
+		expiryReminderLeadValues[i] = strconv.Itoa(lead)
+	}
+	expiryReminderDailyCap := database.DB.GetExpiryReminderDailyCap()
+
+	downloadAccountIdleDays := database.DB.GetConfigInt("download_account_idle_days", 0)
+	downloadAccountGraceDays := database.DB.GetConfigInt("download_account_grace_days", database.DefaultDownloadAccountGraceDays)
+
+	emailChangeExpiryHours, _ := database.DB.GetConfigValue("email_change_expiry_hours")
+	if emailChangeExpiryHours == "" {
+		emailChangeExpiryHours = fmt.Sprintf("%d", database.DefaultEmailChangeExpiryHours)
+	}
+
+	// Get dashboard style preference
+	dashboardStyle, _ := database.DB.GetConfigValue("dashboard_style")
+	if dashboardStyle == "" {
+		dashboardStyle = "colorful" // Default to colorful
+	}
+	dashboardStyleChecked := ""
+	if dashboardStyle == "plain" {
+		dashboardStyleChecked = "checked"
+	}
+	port, _ := database.DB.GetConfigValue("port")
+	if port == "" {
+		port = s.config.Port
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Settings - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 800px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            padding: 30px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            margin-bottom: 20px;
+        }
+        .card h2 {
+            color: #333;
+            margin-bottom: 20px;
+            font-size: 20px;
+        }
+        .form-group {
+            margin-bottom: 20px;
+        }
+        label {
+            display: block;
+            margin-bottom: 8px;
+            color: #333;
+            font-weight: 500;
+            font-size: 14px;
+        }
+        input[type="text"], input[type="number"], input[type="url"] {
+            width: 100%;
+            padding: 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+            transition: border-color 0.3s;
+        }
+        input:focus {
+            outline: none;
+            border-color: ` + s.getPrimaryColor() + `;
+        }
+        .help-text {
+            color: #666;
+            font-size: 12px;
+            margin-top: 4px;
+        }
+        .btn {
+            padding: 12px 24px;
+            border: none;
+            border-radius: 6px;
+            font-size: 14px;
+            font-weight: 600;
+            cursor: pointer;
+            text-decoration: none;
+            display: inline-block;
+        }
+        .btn-primary {
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+        }
+        .btn-primary:hover {
+            opacity: 0.9;
+        }
+        .success {
+            background: #d4edda;
+            border: 1px solid #c3e6cb;
+            color: #155724;
+            padding: 12px;
+            border-radius: 6px;
+            margin-bottom: 20px;
+        }
+        .error {
+            background: #f8d7da;
+            border: 1px solid #f5c6cb;
+            color: #721c24;
+            padding: 12px;
+            border-radius: 6px;
+            margin-bottom: 20px;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <div class="card">
+            <h2>System Settings</h2>`
+
+	if message != "" {
+		if message[:5] == "Error" {
+			html += `<div class="error">` + template.HTMLEscapeString(message) + `</div>`
+		} else {
+			html += `<div class="success">` + template.HTMLEscapeString(message) + `</div>`
+		}
+	}
+
+	// Build full public URL for display
+	fullPublicURL := serverURL + ":" + port
+
+	html += `
+            <div style="background: #fff3cd; border: 2px solid #ffc107; border-radius: 8px; padding: 20px; margin-bottom: 30px;">
+                <h3 style="color: #856404; margin-bottom: 10px; font-size: 16px;">📋 Current Public URL</h3>
+                <p style="color: #856404; font-size: 13px; margin-bottom: 12px;">Share this URL with your users to access the system:</p>
+                <div style="display: flex; align-items: center; gap: 10px;">
+                    <input type="text" id="publicUrl" value="` + fullPublicURL + `" readonly
+                           style="flex: 1; padding: 12px; background: white; border: 2px solid #ffc107; font-family: monospace; font-size: 14px; font-weight: 600; color: #d32f2f;">
+                    <button type="button" onclick="copyPublicURL()" class="btn"
+                            style="background: #ffc107; color: #856404; font-weight: 700; white-space: nowrap;">
+                        📋 COPY URL
+                    </button>
+                </div>
+                <p id="copyStatus" style="color: #28a745; font-size: 12px; margin-top: 8px; display: none;">✓ Copied to clipboard!</p>
+            </div>
+
+            <form method="POST" action="/admin/settings">
+                <div class="form-group">
+                    <label for="server_url">Server URL</label>
+                    <input type="url" id="server_url" name="server_url" value="` + serverURL + `" required>
+                    <p class="help-text">The public URL where this server is accessible (e.g., https://files.manvarg.se). Do not include the port - it's configured separately below.</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="bind_address">Bind Address</label>
+                    <input type="text" id="bind_address" name="bind_address" value="` + template.HTMLEscapeString(s.getConfiguredBindAddress()) + `" placeholder="All interfaces">
+                    <p class="help-text">IP address to listen on, e.g. 127.0.0.1 when the server runs behind a reverse proxy. It doesn't change the links, which are built from the Server URL and port. Leave empty to listen on all interfaces.</p>
+                    <p class="help-text" style="color: #ff6b00; font-weight: 600;">⚠️ Changes require server restart to take effect</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="port">Server Port</label>
+                    <input type="number" id="port" name="port" value="` + port + `" min="1" max="65535" required>
+                    <p class="help-text">Port number for the server to listen on. Ports below 1024 require administrator privileges.</p>
+                    <p class="help-text" style="color: #ff6b00; font-weight: 600;">⚠️ Changes require server restart to take effect</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="max_file_size_mb">Max File Size (MB)</label>
+                    <input type="number" id="max_file_size_mb" name="max_file_size_mb" value="` + maxFileSizeMB + `" min="1" required>
+                    <p class="help-text">Maximum file size users can upload</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="default_quota_mb">Default User Quota (MB)</label>
+                    <input type="number" id="default_quota_mb" name="default_quota_mb" value="` + defaultQuotaMB + `" min="100" required>
+                    <p class="help-text">Default storage quota for new users</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="trash_retention_days">Trash Retention Period (Days)</label>
+                    <input type="number" id="trash_retention_days" name="trash_retention_days" value="` + trashRetentionDays + `" min="1" max="365" required>
+                    <p class="help-text">Number of days to keep deleted files in trash before permanent deletion</p>
+                </div>
+
+                <div class="form-group">
+                    <label>Per-File Trash Retention (Days)</label>
+                    <div style="display: flex; gap: 10px; align-items: center;">
+                        <input type="number" id="trash_retention_min_days" name="trash_retention_min_days" value="` + strconv.Itoa(trashRetentionMinDays) + `" min="1" max="3650" style="width: 120px;">
+                        <span>to</span>
+                        <input type="number" id="trash_retention_max_days" name="trash_retention_max_days" value="` + strconv.Itoa(trashRetentionMaxDays) + `" min="0" max="3650" style="width: 120px;">
+                    </div>
+                    <p class="help-text">Range users can choose from when deleting a file, to keep it in trash longer or shorter than the period above. Set the maximum to 0 to always use the period above</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="delete_undo_seconds">Undo Delete Window (Seconds)</label>
+                    <input type="number" id="delete_undo_seconds" name="delete_undo_seconds" value="` + strconv.Itoa(database.DB.GetConfigInt("delete_undo_seconds", DefaultDeleteUndoSeconds)) + `" min="0" max="` + strconv.Itoa(MaxDeleteUndoSeconds) + `" style="width: 120px;">
+                    <p class="help-text">How long after deleting a file its owner can undo the delete without going to the trash. Set to 0 to turn undo off (default: ` + strconv.Itoa(DefaultDeleteUndoSeconds) + `)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="audit_log_retention_days">Audit Log Retention (Days)</label>
+                    <input type="number" id="audit_log_retention_days" name="audit_log_retention_days" value="` + auditLogRetentionDays + `" min="1" max="3650" required>
+                    <p class="help-text">Number of days to keep audit logs before automatic cleanup (default: 90 days)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="audit_log_max_size_mb">Audit Log Max Size (MB)</label>
+                    <input type="number" id="audit_log_max_size_mb" name="audit_log_max_size_mb" value="` + auditLogMaxSizeMB + `" min="10" max="10000" required>
+                    <p class="help-text">Maximum database size for audit logs before automatic cleanup of oldest entries (default: 100 MB)</p>
+                </div>
+
+                <div class="form-group">
+                    <label>Failed Login Limits</label>
+                    <div style="display: flex; gap: 10px; align-items: center; flex-wrap: wrap;">
+                        <input type="number" id="login_max_attempts_per_email" name="login_max_attempts_per_email" value="` + strconv.Itoa(loginMaxPerEmail) + `" min="0" max="1000" style="width: 100px;">
+                        <span>per email,</span>
+                        <input type="number" id="login_max_attempts_per_ip" name="login_max_attempts_per_ip" value="` + strconv.Itoa(loginMaxPerIP) + `" min="0" max="10000" style="width: 100px;">
+                        <span>per IP address within</span>
+                        <input type="number" id="login_window_minutes" name="login_window_minutes" value="` + strconv.Itoa(int(loginWindow.Minutes())) + `" min="1" max="1440" style="width: 100px;">
+                        <span>minutes</span>
+                    </div>
+                    <p class="help-text">After this many failed logins, further logins for the email or from the IP address are refused with 429 Too Many Requests until older failures fall out of the window. Lockouts are recorded in the audit log as LOGIN_LOCKED. Set a limit to 0 to turn it off (default: ` + strconv.Itoa(auth.DefaultLoginMaxAttemptsPerEmail) + ` per email, ` + strconv.Itoa(auth.DefaultLoginMaxAttemptsPerIP) + ` per IP, ` + strconv.Itoa(auth.DefaultLoginWindowMinutes) + ` minutes)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="password_min_length">Minimum Password Length</label>
+                    <input type="number" id="password_min_length" name="password_min_length" value="` + strconv.Itoa(passwordMinLength) + `" min="` + strconv.Itoa(auth.MinPasswordMinLength) + `" max="` + strconv.Itoa(auth.MaxPasswordLength) + `" required>
+                    <p class="help-text">Applies when users and download accounts are created or change their password. Passwords must also contain a letter and a number or symbol. Existing passwords keep working (default: ` + strconv.Itoa(auth.DefaultPasswordMinLength) + ` characters)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="password_hash_algorithm">Password Hashing</label>
+                    <select id="password_hash_algorithm" name="password_hash_algorithm" style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                        <option value="bcrypt"` + selected(passwordHashAlgorithm == auth.HashAlgorithmBcrypt) + `>bcrypt</option>
+                        <option value="argon2id"` + selected(passwordHashAlgorithm == auth.HashAlgorithmArgon2id) + `>argon2id</option>
+                    </select>
+                    <p class="help-text">Used for new passwords. Stored passwords in the other format, or made with other settings below, keep working and are hashed again the next time their owner logs in, so no one has to reset their password</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="password_bcrypt_cost">bcrypt Cost</label>
+                    <input type="number" id="password_bcrypt_cost" name="password_bcrypt_cost" value="` + strconv.Itoa(bcryptCost) + `" min="` + strconv.Itoa(auth.MinBcryptCost) + `" max="` + strconv.Itoa(auth.MaxBcryptCost) + `" required>
+                    <p class="help-text">Each step doubles the time a login takes (default: ` + strconv.Itoa(auth.BcryptCost) + `)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="password_argon2_memory_mb">argon2id Memory (MB) and Iterations</label>
+                    <div style="display: flex; gap: 10px;">
+                        <input type="number" id="password_argon2_memory_mb" name="password_argon2_memory_mb" value="` + strconv.Itoa(argon2MemoryMB) + `" min="` + strconv.Itoa(auth.MinArgon2MemoryMB) + `" max="` + strconv.Itoa(auth.MaxArgon2MemoryMB) + `" required>
+                        <input type="number" id="password_argon2_iterations" name="password_argon2_iterations" value="` + strconv.Itoa(argon2Iterations) + `" min="` + strconv.Itoa(auth.MinArgon2Iterations) + `" max="` + strconv.Itoa(auth.MaxArgon2Iterations) + `" required>
+                    </div>
+                    <p class="help-text">Every login uses this much memory while the password is checked, so keep it well below the server's free memory (default: ` + strconv.Itoa(auth.DefaultArgon2MemoryMB) + ` MB, ` + strconv.Itoa(auth.DefaultArgon2Iterations) + ` iterations)</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="notify_admins_on_promotion" name="notify_admins_on_promotion" ` + promotionNotificationChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Notify admins when a user is promoted</span>
+                    </label>
+                    <p class="help-text">Promotions and demotions are always recorded in the audit log as USER_PROMOTED and USER_DEMOTED. When this is on, the other admins are also notified of promotions</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="server_log_max_size_mb">Server Log Max Size (MB)</label>
+                    <input type="number" id="server_log_max_size_mb" name="server_log_max_size_mb" value="` + serverLogMaxSizeMB + `" min="10" max="1000" required>
+                    <p class="help-text">Maximum file size for server logs before automatic rotation (default: 50 MB)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="deleted_account_retention_days">Deleted Account Retention (Days)</label>
+                    <input type="number" id="deleted_account_retention_days" name="deleted_account_retention_days" value="` + fmt.Sprintf("%d", deletedAccountRetentionDays) + `" min="1" max="3650" required>
+                    <p class="help-text">Deleted users and download accounts are kept this long, so they can be restored, before they are removed permanently (default: ` + fmt.Sprintf("%d", database.DefaultDeletedAccountRetentionDays) + ` days)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="file_request_retention_days">Expired Upload Request Retention (Days)</label>
+                    <input type="number" id="file_request_retention_days" name="file_request_retention_days" value="` + fmt.Sprintf("%d", fileRequestRetentionDays) + `" min="1" max="3650" required>
+                    <p class="help-text">Expired upload request links show an "expired" message this long, then the request is deleted (default: ` + fmt.Sprintf("%d", database.DefaultFileRequestRetentionDays) + ` days)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="max_file_expiry_days">Maximum File Expiry (Days)</label>
+                    <input type="number" id="max_file_expiry_days" name="max_file_expiry_days" value="` + fmt.Sprintf("%d", maxFileExpiryDays) + `" min="0" required>
+                    <p class="help-text">Longest time a file can be shared. New uploads and edits that expire later, or never, are shortened to this (0 = no limit). <a href="/admin/expiry-policy">Apply to existing files</a></p>
+                </div>
+
+                <div class="form-group">
+                    <label>Expired Token Retention (Hours)</label>` + tokenRetentionInputs + `
+                    <p class="help-text">How long expired sessions, password reset and email change links, and trusted devices are kept before the hourly cleanup removes them. Used reset and change links are removed after the same time (0 = remove immediately)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="max_public_links">Max Active Public Links</label>
+                    <input type="number" id="max_public_links" name="max_public_links" value="` + fmt.Sprintf("%d", maxPublicLinks) + `" min="0" required>
+                    <p class="help-text">Maximum number of active files that can be downloaded without authentication. New public uploads are blocked once reached (0 = unlimited)</p>
+                    <p class="help-text" style="font-weight: 600;">` + publicLinksUsage + `</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_token_ttl_seconds">Download Link Token Lifetime (Seconds)</label>
+                    <input type="number" id="download_token_ttl_seconds" name="download_token_ttl_seconds" value="` + fmt.Sprintf("%d", downloadTokenTTL) + `" min="0" max="86400" required>
+                    <p class="help-text">The download page issues a single-use token so repeated clicks only count as one download. Expired tokens are refreshed by reloading the page (default: 300, 0 = disabled)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_retry_window_minutes">Download Retry Window (Minutes)</label>
+                    <input type="number" id="download_retry_window_minutes" name="download_retry_window_minutes" value="` + strconv.Itoa(int(database.DB.GetDownloadRetryWindow()/time.Minute)) + `" min="0" max="` + strconv.Itoa(database.MaxDownloadRetryWindowMinutes) + `" style="width: 100px;">
+                    <p class="help-text">For files with download retries allowed, the browser that downloaded the file may download it again this long without using up another download, e.g. after a broken-off transfer. Other browsers and logins are always counted (default: ` + strconv.Itoa(database.DefaultDownloadRetryWindowMinutes) + `, 0 = retries are always counted)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_notify_limit">Download Emails per File</label>
+                    <input type="number" id="download_notify_limit" name="download_notify_limit" value="` + strconv.Itoa(database.DB.GetDownloadNotifyLimit()) + `" min="0" style="width: 100px;">
+                    <p class="help-text">How many download emails the owner of a file gets per summary interval. Further downloads are sent as one summary at the end of the interval (default: ` + strconv.Itoa(database.DefaultDownloadNotifyLimit) + `, 0 = email every download)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_digest_interval_minutes">Download Summary Interval (Minutes)</label>
+                    <input type="number" id="download_digest_interval_minutes" name="download_digest_interval_minutes" value="` + strconv.Itoa(int(database.DB.GetDownloadDigestInterval()/time.Minute)) + `" min="5" max="1440" style="width: 100px;">
+                    <p class="help-text">How often batched downloads are summed up in one email (default: ` + strconv.Itoa(database.DefaultDownloadDigestIntervalMinutes) + `)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="team_zip_max_mb">Team ZIP Download Limit (MB)</label>
+                    <input type="number" id="team_zip_max_mb" name="team_zip_max_mb" value="` + fmt.Sprintf("%d", teamZipMaxMB) + `" min="0" required>
+                    <p class="help-text">Team members can download all files shared with a team as one ZIP up to this total size. Files with a password, login, approval, schedule or terms requirement are left out (default: 2048, 0 = disabled)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="zip_download_max_mb">Multi-File ZIP Download Limit (MB)</label>
+                    <input type="number" id="zip_download_max_mb" name="zip_download_max_mb" value="` + strconv.Itoa(zipDownloadMaxMB) + `" min="0" required>
+                    <p class="help-text">Several files can be downloaded as one ZIP with <code>/d/zip?ids=ID1,ID2,...</code> up to this total size. Files with a password or login requirement can only be included by their owner, admins and members of teams they are shared with (default: ` + strconv.Itoa(DefaultZipDownloadMaxMB) + `, 0 = disabled)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_max_mbps">Download Speed Limit per File (MB/s)</label>
+                    <input type="number" id="download_max_mbps" name="download_max_mbps" value="` + strconv.Itoa(downloadMaxMBps) + `" min="0" required>
+                    <p class="help-text">Caps the total speed of all downloads of one file at once, so a popular large file can't saturate the uplink. Owners can set a lower limit per file in its settings; only admins can set a higher one (default: 0 = no limit)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="burn_after_download_mode">Burn After Download</label>
+                    <select id="burn_after_download_mode" name="burn_after_download_mode" style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                        <option value="trash"` + selected(burnAfterDownloadMode == database.BurnModeTrash) + `>Move to trash (can be restored)</option>
+                        <option value="delete"` + selected(burnAfterDownloadMode == database.BurnModeDelete) + `>Delete permanently</option>
+                    </select>
+                    <p class="help-text">What happens to a file marked "burn after download" once it has been downloaded completely. Its link shows that it was used either way; restoring the file from trash makes it downloadable once more</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="processing_workers">Background Processing Workers</label>
+                    <input type="number" id="processing_workers" name="processing_workers" value="` + fmt.Sprintf("%d", processingWorkers) + `" min="1" max="32" required>
+                    <p class="help-text">How many uploaded files are processed at once (checksums, image conversion and other background work). Further work waits in a queue so upload bursts don't overload the server (default: ` + fmt.Sprintf("%d", DefaultProcessingWorkers) + `)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="integrity_scan_interval_hours">File Integrity Scan (Hours)</label>
+                    <input type="number" id="integrity_scan_interval_hours" name="integrity_scan_interval_hours" value="` + strconv.Itoa(int(database.DB.GetIntegrityScanInterval()/time.Hour)) + `" min="0" style="width: 100px;">
+                    <span style="margin-left: 10px;">at most</span>
+                    <input type="number" id="integrity_scan_rate_mb" name="integrity_scan_rate_mb" value="` + strconv.Itoa(database.DB.GetIntegrityScanRateMB()) + `" min="1" max="` + strconv.Itoa(database.MaxIntegrityScanRateMB) + `" style="width: 100px;"> MB/s
+                    <p class="help-text">How often every stored file is read and compared with its SHA-256 checksum. Corrupt and missing files are reported to admins and listed in the <a href="/admin/integrity">integrity report</a>. The read speed limit keeps the scan from slowing down downloads (default: 0 = off, ` + strconv.Itoa(database.DefaultIntegrityScanRateMB) + ` MB/s)</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="show_file_checksum" name="show_file_checksum" ` + showChecksumChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Show SHA-256 checksum on download pages</span>
+                    </label>
+                    <p class="help-text">Recipients see the file's checksum with copyable commands to verify their download. Downloads always include the checksum in the Digest header</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="email_file_deep_links" name="email_file_deep_links" ` + emailDeepLinksChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Link notification emails to the file's history</span>
+                    </label>
+                    <p class="help-text">Download and expiry notifications open the owner's dashboard at the file with its download history shown, after signing in. When off, they link to the dashboard</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="email_link_signing" name="email_link_signing" ` + emailLinkSigningChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Sign share links sent by email</span>
+                    </label>
+                    <p class="help-text">Emailed download links carry a signature tied to the file and the recipient, so an altered link is rejected. Once a file has been emailed, its links only work with the signature, except for its owner, admins and its teams</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="email_link_expiry_hours">Emailed Link Validity (Hours)</label>
+                    <input type="number" id="email_link_expiry_hours" name="email_link_expiry_hours" value="` + fmt.Sprintf("%d", emailLinkExpiryHours) + `" min="0" required>
+                    <p class="help-text">How long a signed link works after the email is sent, on top of the file's own expiry (0 = as long as the file)</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="email_link_allow_plain" name="email_link_allow_plain" ` + emailLinkAllowPlainChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Allow plain links</span>
+                    </label>
+                    <p class="help-text">Links without a signature keep working for emailed files, and are sent when a link can't be signed. When off, such emails are not sent</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="email_footer_text">Email Footer</label>
+                    <textarea id="email_footer_text" name="email_footer_text" rows="3" maxlength="` + fmt.Sprintf("%d", emailpkg.MaxFooterLength) + `" placeholder="e.g. Example AB, Storgatan 1, 111 22 Stockholm" style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; font-family: inherit;">` + template.HTMLEscapeString(emailpkg.GetFooterText()) + `</textarea>
+                    <p class="help-text">Added to the bottom of every email, such as your company name and postal address. Notification emails to users also get a link to unsubscribe from that kind of email; password resets and security notices can't be unsubscribed from. Plain text, at most ` + fmt.Sprintf("%d", emailpkg.MaxFooterLength) + ` characters</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="expired_message_default">Default Expired Page Message</label>
+                    <textarea id="expired_message_default" name="expired_message_default" rows="2" maxlength="500" placeholder="e.g. Contact support@example.com if you still need this file" style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; font-family: inherit;">` + template.HTMLEscapeString(database.DB.GetDefaultExpiredMessage()) + `</textarea>
+                    <p class="help-text">Shown to recipients of expired links when the file's owner hasn't set a message of their own. Plain text, at most 500 characters (empty = no message)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="legal_notice_text">Legal Notice</label>
+                    <textarea id="legal_notice_text" name="legal_notice_text" rows="4" maxlength="` + fmt.Sprintf("%d", maxLegalNoticeLength) + `" placeholder="e.g. This file is confidential and intended only for the named recipient..." style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; font-family: inherit;">` + template.HTMLEscapeString(legalNoticeSetting()) + `</textarea>
+                    <p class="help-text">Shown on the download page of files that use the legal notice page. Recipients must click "I agree" before downloading, and the download log records when and from which IP address they agreed. Plain text, at most ` + fmt.Sprintf("%d", maxLegalNoticeLength) + ` characters (empty = a standard confidentiality notice in the recipient's language)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="expired_file_grace_hours">Overdue Expired File Warning (Hours)</label>
+                    <input type="number" id="expired_file_grace_hours" name="expired_file_grace_hours" value="` + fmt.Sprintf("%d", expiredFileGraceHours) + `" min="0" required>
+                    <p class="help-text">Expired files are moved to trash every 6 hours. Files still not in trash this long after expiring are reported on the admin dashboard and in the audit log (0 = don't check). Downloads of expired files are always refused</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="expired_files_auto_trash" name="expired_files_auto_trash" ` + expiredFilesAutoTrashChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Move overdue expired files to trash automatically</span>
+                    </label>
+                    <p class="help-text">The hourly check moves them to trash itself instead of only reporting them</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="splash_viewer_tracking" name="splash_viewer_tracking" ` + splashViewerTrackingChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Count people viewing download pages</span>
+                    </label>
+                    <p class="help-text">Open download pages check in every few seconds, so owners see how many people are viewing a file and can limit how many may view it at once</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="image_conversion_enabled" name="image_conversion_enabled" ` + imageConversionChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Convert HEIC, HEIF and AVIF images for preview</span>
+                    </label>
+                    <p class="help-text">A JPEG or PNG copy is made in the background and shown on the download page of files without a password or login. The uploaded original is never changed. ` + imageConversionStatus() + `</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="image_conversion_download" name="image_conversion_download" ` + convertedDownloadChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Offer the converted image as an alternative download</span>
+                    </label>
+                    <p class="help-text">Recipients get a "Download as JPEG" (or PNG) link next to the original. It counts as a download like the original</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="image_conversion_format">Converted Image Format</label>
+                    <select id="image_conversion_format" name="image_conversion_format" style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                        <option value="jpeg"` + selected(imageConversionFormat == "jpeg") + `>JPEG (smaller files)</option>
+                        <option value="png"` + selected(imageConversionFormat == "png") + `>PNG (lossless)</option>
+                    </select>
+                    <p class="help-text">Applies to images converted from now on</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="image_conversion_max_mb">Largest Image to Convert (MB)</label>
+                    <input type="number" id="image_conversion_max_mb" name="image_conversion_max_mb" value="` + fmt.Sprintf("%d", imageConversionMaxMB) + `" min="1" required>
+                    <p class="help-text">Larger images are served as uploaded without a preview (default: ` + fmt.Sprintf("%d", DefaultImageConversionMaxMB) + `)</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="clamav_enabled" name="clamav_enabled" ` + clamavChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Scan uploads for viruses with ClamAV</span>
+                    </label>
+                    <p class="help-text">Every upload is streamed to clamd in the background and can't be downloaded until the scan is done. Infected files are quarantined and their owner is emailed. If clamd can't be reached, files show as not scanned. ` + template.HTMLEscapeString(clamavStatus) + `</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="clamav_address">clamd Address</label>
+                    <input type="text" id="clamav_address" name="clamav_address" value="` + template.HTMLEscapeString(database.DB.GetClamAVAddress()) + `" placeholder="/run/clamav/clamd.ctl or 127.0.0.1:3310">
+                    <p class="help-text">A Unix socket path or a host:port TCP address</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="clamav_delete_infected" name="clamav_delete_infected" ` + deleteInfectedChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Delete infected files right away</span>
+                    </label>
+                    <p class="help-text">When unchecked, infected files are kept in quarantine so admins can inspect them; nobody can download them</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="clamav_fail_open" name="clamav_fail_open" ` + failOpenChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Allow downloads of files clamd could not scan</span>
+                    </label>
+                    <p class="help-text">When unchecked, files that could not be scanned, e.g. while clamd was down, can't be downloaded until they are rescanned. Files not scanned: ` + fmt.Sprintf("%d", unscannedFiles) + `</p>
+                    <button type="button" onclick="rescanUnscannedFiles()" class="btn" style="background: #e0e0e0;">Rescan Files Not Scanned</button>
+                    <span id="rescanStatus" class="help-text" style="margin-left: 10px;"></span>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="download_log_details" name="download_log_details" ` + downloadLogDetailsChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Record downloader IP addresses and browsers</span>
+                    </label>
+                    <p class="help-text">When unchecked, the download log of every file only keeps download counts and times. Use this where IP logging must be minimized</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="private_download_log_default" name="private_download_log_default" ` + privateDownloadLogDefaultChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Don't log downloader details for new files by default</span>
+                    </label>
+                    <p class="help-text">Pre-selects the privacy option on uploads. Uploaders can still change it per file</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="strip_metadata_default" name="strip_metadata_default" ` + stripMetadataDefaultChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Remove metadata from uploads by default</span>
+                    </label>
+                    <p class="help-text">Removes EXIF, XMP and IPTC data (GPS location, camera, author) from JPEG, PNG and WebP images after upload; the orientation is kept. Pre-selects the option on uploads, and applies to files received through upload requests. Other formats and files over 100 MB are stored as uploaded</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="strip_document_metadata" name="strip_document_metadata" ` + stripDocumentMetadataChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Also remove document properties</span>
+                    </label>
+                    <p class="help-text">When metadata is removed, also clears the author, last editor, company and custom properties of Word, Excel and PowerPoint files (.docx, .xlsx, .pptx). PDFs are not changed</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="upload_blocked_extensions">Blocked File Extensions</label>
+                    <input type="text" id="upload_blocked_extensions" name="upload_blocked_extensions" value="` + template.HTMLEscapeString(strings.Join(fileTypePolicy.BlockedExtensions, ", ")) + `" placeholder="exe, msi, bat, cmd, com, scr, ps1, vbs">
+                    <p class="help-text">Files with these extensions can't be uploaded, by users or through upload requests (empty = none blocked)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="upload_allowed_extensions">Allowed File Extensions</label>
+                    <input type="text" id="upload_allowed_extensions" name="upload_allowed_extensions" value="` + template.HTMLEscapeString(strings.Join(fileTypePolicy.AllowedExtensions, ", ")) + `" placeholder="pdf, docx, xlsx, jpg, png, zip">
+                    <p class="help-text">If set, only files with these extensions can be uploaded (empty = any extension that isn't blocked)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="upload_blocked_mime_types">Blocked File Types (MIME)</label>
+                    <input type="text" id="upload_blocked_mime_types" name="upload_blocked_mime_types" value="` + template.HTMLEscapeString(strings.Join(fileTypePolicy.BlockedMIMETypes, ", ")) + `" placeholder="application/x-msdownload, application/x-sh">
+                    <p class="help-text">Refused if the type of the file's extension or the type detected from its content matches. Use <code>video/*</code> for a whole family (empty = none blocked)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="upload_allowed_mime_types">Allowed File Types (MIME)</label>
+                    <input type="text" id="upload_allowed_mime_types" name="upload_allowed_mime_types" value="` + template.HTMLEscapeString(strings.Join(fileTypePolicy.AllowedMIMETypes, ", ")) + `" placeholder="application/pdf, image/*">
+                    <p class="help-text">If set, the type of the file's extension or the type detected from its content must match one of these (empty = any type that isn't blocked)</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="upload_verify_file_type" name="upload_verify_file_type" ` + verifyFileTypeChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Verify that file content matches the extension</span>
+                    </label>
+                    <p class="help-text">Refuses renamed files, e.g. a program saved as report.pdf or binary data saved as notes.txt. Checked for images, PDFs, archives, Office documents and text files</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_anomaly_max_per_minute">Lock Downloads After Unusual Activity</label>
+                    <div style="display: flex; gap: 12px; align-items: center; flex-wrap: wrap;">
+                        <span>More than</span>
+                        <input type="number" id="download_anomaly_max_per_minute" name="download_anomaly_max_per_minute" value="` + strconv.Itoa(downloadAnomalyRules.MaxPerMinute) + `" min="0" style="width: 100px;">
+                        <span>downloads per minute, or from more than</span>
+                        <input type="number" id="download_anomaly_max_countries_per_hour" name="download_anomaly_max_countries_per_hour" value="` + strconv.Itoa(downloadAnomalyRules.MaxCountriesPerHour) + `" min="0" style="width: 100px;">
+                        <span>countries per hour, locks the file for</span>
+                        <input type="number" id="download_anomaly_block_minutes" name="download_anomaly_block_minutes" value="` + strconv.Itoa(downloadAnomalyRules.BlockMinutes) + `" min="1" style="width: 100px;">
+                        <span>minutes</span>
+                    </div>
+                    <p class="help-text">Visitors see a "temporarily locked" page and the owner is notified. Owners can unlock the file from their dashboard if the downloads were expected (0 = rule off)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_country_header">Country Header</label>
+                    <input type="text" id="download_country_header" name="download_country_header" value="` + template.HTMLEscapeString(database.DB.GetDownloadCountryHeader()) + `" placeholder="` + database.DefaultDownloadCountryHeader + `">
+                    <p class="help-text">Request header in which your reverse proxy or CDN sends the downloader's two-letter country code. The country rule only works behind a proxy that sets it</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="siem_format">SIEM Access Log</label>
+                    <div style="display: flex; gap: 12px; align-items: center; flex-wrap: wrap;">
+                        <select id="siem_format" name="siem_format" style="width: auto;">
+                            <option value=""` + selected(siemFormat == "") + `>Off</option>
+                            <option value="` + siemFormatCEF + `"` + selected(siemFormat == siemFormatCEF) + `>CEF</option>
+                            <option value="` + siemFormatLEEF + `"` + selected(siemFormat == siemFormatLEEF) + `>LEEF</option>
+                        </select>
+                        <input type="text" id="siem_target" name="siem_target" value="` + template.HTMLEscapeString(getSIEMTarget()) + `" placeholder="udp://siem.example.com:514" style="flex: 1; min-width: 260px;">
+                    </div>
+                    <p class="help-text">Sends every download, opened share link and download login attempt, with file ID, owner, client IP, country and how the visitor authenticated, to your SIEM. Use udp:// or tcp:// for syslog, or an http(s):// URL that records are posted to. Files with private download logs are reported without the visitor's details</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="external_link_template">External System Link</label>
+                    <div style="display: flex; gap: 12px; align-items: center; flex-wrap: wrap;">
+                        <input type="text" id="external_link_label" name="external_link_label" value="` + template.HTMLEscapeString(externalLinkLabel) + `" placeholder="External record" maxlength="50" style="width: 180px;">
+                        <input type="text" id="external_link_template" name="external_link_template" value="` + template.HTMLEscapeString(database.DB.GetExternalLinkTemplate()) + `" placeholder="https://crm.example.com/files?ref={{.FileID}}" maxlength="` + strconv.Itoa(database.MaxExternalLinkTemplateLength) + `" style="flex: 1; min-width: 260px;">
+                    </div>
+                    <label style="display: flex; align-items: center; cursor: pointer; margin-top: 10px; font-weight: normal;">
+                        <input type="checkbox" id="external_link_in_notifications" name="external_link_in_notifications" ` + externalLinkInNotificationsChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Include the link in upload and download notifications to file owners</span>
+                    </label>
+                    <p class="help-text">Links every file to its record in your CRM or document management system, shown in the admin file list. Available fields: ` + template.HTMLEscapeString("{{."+strings.Join(database.ExternalLinkTemplateFields, "}}, {{.")+"}}") + ` (URL-encoded). Leave empty for no link</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="history_page_size">Download History Page Size</label>
+                    <input type="number" id="history_page_size" name="history_page_size" value="` + strconv.Itoa(database.DB.GetHistoryPageSize()) + `" min="1" max="` + strconv.Itoa(database.MaxHistoryPageSize) + `" style="width: 100px;">
+                    <p class="help-text">How many downloads and emails the history of a file shows before "Load more" (1-` + strconv.Itoa(database.MaxHistoryPageSize) + `)</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="upload_approval_required" name="upload_approval_required" ` + uploadApprovalChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Require approval for public uploads</span>
+                    </label>
+                    <p class="help-text">Files uploaded with a public link stay disabled until an approver releases them. Approval can also be enabled per team</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="upload_approver_emails">Designated Approvers</label>
+                    <input type="text" id="upload_approver_emails" name="upload_approver_emails" value="` + template.HTMLEscapeString(uploadApproverEmails) + `" placeholder="approver@example.com, security@example.com">
+                    <p class="help-text">Comma-separated emails of users who may approve any upload, in addition to admins and team admins</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="default_language">Default Language</label>
+                    <select id="default_language" name="default_language" style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">` + languageOptions + `</select>
+                    <p class="help-text">Language of this deployment. Right-to-left languages mirror the layout of all pages, including login and download pages</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="recipient_language_detection" name="recipient_language_detection" ` + recipientLanguageChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Show download pages in the recipient's browser language</span>
+                    </label>
+                    <p class="help-text">Splash and download pages follow the recipient's Accept-Language when a translation exists, otherwise the default language. Recipients can always switch language on the page</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="timezone">Server Timezone</label>
+                    <input type="text" id="timezone" name="timezone" value="` + template.HTMLEscapeString(timezone) + `" placeholder="` + time.Local.String() + `">
+                    <p class="help-text">IANA timezone (e.g. Europe/Stockholm) used for file availability schedules and upload hours. Leave empty to use the server's local time</p>
+                </div>
+
+                <div class="form-group">
+                    <label>Upload Hours</label>` + uploadWindowFieldsHTML("upload_window", database.DB.GetGlobalUploadWindow()) + `
+                    <p class="help-text">Only accept uploads and file request uploads during these hours, e.g. to keep them out of maintenance windows. Leave empty to always accept uploads; no days checked means every day. Users can have their own upload hours on top of these. Admins can always upload, and downloads are never affected</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="max_active_file_requests">Max Active Upload Requests per User</label>
+                    <input type="number" id="max_active_file_requests" name="max_active_file_requests" value="` + fmt.Sprintf("%d", maxActiveFileRequests) + `" min="0" max="10000" required>
+                    <p class="help-text">Maximum number of unused, non-expired upload request links each user can have at once (0 = unlimited)</p>
+                </div>
+
+                <div class="form-group">
+                    <label style="display: flex; align-items: center; cursor: pointer;">
+                        <input type="checkbox" id="file_request_limit_exempt_admins" name="file_request_limit_exempt_admins" ` + fileRequestExemptAdminsChecked + ` style="margin-right: 10px; width: 20px; height: 20px; cursor: pointer;">
+                        <span>Exempt admins from the upload request limit</span>
+                    </label>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_account_idle_days">Deactivate Unused Download Accounts After (Days)</label>
+                    <input type="number" id="download_account_idle_days" name="download_account_idle_days" value="` + fmt.Sprintf("%d", downloadAccountIdleDays) + `" min="0" max="3650" required>
+                    <p class="help-text">Download accounts with no login or download for this many days are deactivated and the admins are notified (0 = never)</p>
+                </div>
+
+                <div class="form-group">
+                    <label for="download_account_grace_days">New Download Account Grace Period (Days)</label>
+                    <input type="number" id="download_account_grace_days" name="download_account_grace_days" value="` + fmt.Sprintf("%d", downloadAccountGraceDays) + `" min="0" max="3650" required>
+                    <p class="help-text">Accounts that have never been used are kept active for this long after they are created or reactivated (default: 30 days)</p>
+                </div>
 
-	// Get dashboard style preference
-	dashboardStyle, _ := database.DB.GetConfigValue("dashboard_style")
-	if dashboardStyle == "" {
-		dashboardStyle = "colorful" // Default to colorful
-	}
-	dashboardStyleChecked := ""
-	if dashboardStyle == "plain" {
-		dashboardStyleChecked = "checked"
-	}
-	port, _ := database.DB.GetConfigValue("port")
-	if port == "" {
-		port = s.config.Port
-	}
+                <div class="form-group">
+                    <label for="email_change_expiry_hours">Email Change Link Validity (Hours)</label>
+                    <input type="number" id="email_change_expiry_hours" name="email_change_expiry_hours" value="` + emailChangeExpiryHours + `" min="1" max="720" required>
+                    <p class="help-text">How long the verification link sent to a user's new email address stays valid (default: 24 hours)</p>
+                </div>
 
-	html := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <meta name="author" content="Ulf Holmström">
-    <title>Settings - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
-    <link rel="stylesheet" href="/static/css/style.css">
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-            background: #f5f5f5;
-        }
-        .container {
-            max-width: 800px;
-            margin: 40px auto;
-            padding: 0 20px;
-        }
-        .card {
-            background: white;
-            border-radius: 12px;
-            padding: 30px;
-            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
-            margin-bottom: 20px;
-        }
-        .card h2 {
-            color: #333;
-            margin-bottom: 20px;
-            font-size: 20px;
-        }
-        .form-group {
-            margin-bottom: 20px;
-        }
-        label {
-            display: block;
-            margin-bottom: 8px;
-            color: #333;
-            font-weight: 500;
-            font-size: 14px;
-        }
-        input[type="text"], input[type="number"], input[type="url"] {
-            width: 100%;
-            padding: 12px;
-            border: 2px solid #e0e0e0;
-            border-radius: 6px;
-            font-size: 14px;
-            transition: border-color 0.3s;
-        }
-        input:focus {
-            outline: none;
-            border-color: ` + s.getPrimaryColor() + `;
-        }
-        .help-text {
-            color: #666;
-            font-size: 12px;
-            margin-top: 4px;
-        }
-        .btn {
-            padding: 12px 24px;
-            border: none;
-            border-radius: 6px;
-            font-size: 14px;
-            font-weight: 600;
-            cursor: pointer;
-            text-decoration: none;
-            display: inline-block;
-        }
-        .btn-primary {
-            background: ` + s.getPrimaryColor() + `;
-            color: white;
-        }
-        .btn-primary:hover {
-            opacity: 0.9;
-        }
-        .success {
-            background: #d4edda;
-            border: 1px solid #c3e6cb;
-            color: #155724;
-            padding: 12px;
-            border-radius: 6px;
-            margin-bottom: 20px;
-        }
-        .error {
-            background: #f8d7da;
-            border: 1px solid #f5c6cb;
-            color: #721c24;
-            padding: 12px;
-            border-radius: 6px;
-            margin-bottom: 20px;
-        }
-    </style>
-</head>
-<body>
-    ` + s.getAdminHeaderHTML("") + `
-    <div class="container">
-        <div class="card">
-            <h2>System Settings</h2>`
+                <div class="form-group">
+                    <label for="welcome_email_daily_cap">Welcome Email Re-sends per User per Day</label>
+                    <input type="number" id="welcome_email_daily_cap" name="welcome_email_daily_cap" value="` + fmt.Sprintf("%d", welcomeEmailDailyCap) + `" min="0" max="100" required>
+                    <p class="help-text">Maximum number of password setup emails a user can be sent in 24 hours when re-sending from Pending Setup (0 = unlimited, default: 3)</p>
+                </div>
 
-	if message != "" {
-		if message[:5] == "Error" {
-			html += `<div class="error">` + message + `</div>`
-		} else {
-			html += `<div class="success">` + message + `</div>`
-		}
-	}
+                <div class="form-group">
+                    <label for="email_max_attempts">Email Send Attempts</label>
+                    <input type="number" id="email_max_attempts" name="email_max_attempts" value="` + strconv.Itoa(emailMaxAttempts) + `" min="` + strconv.Itoa(database.MinEmailMaxAttempts) + `" max="` + strconv.Itoa(database.MaxEmailMaxAttempts) + `" required>
+                    <p class="help-text">How often a queued email is tried before it is marked failed on the <a href="/admin/email-queue">Email Queue</a> page. The wait between attempts doubles from 1 minute up to 1 hour (default: ` + strconv.Itoa(database.DefaultEmailMaxAttempts) + `)</p>
+                </div>
 
-	// Build full public URL for display
-	fullPublicURL := serverURL + ":" + port
+                <div class="form-group">
+                    <label for="session_hours">Login Session Length (Hours)</label>
+                    <input type="number" id="session_hours" name="session_hours" value="` + strconv.Itoa(sessionHours) + `" min="1" max="` + strconv.Itoa(database.MaxSessionHours) + `" required>
+                    <p class="help-text">How long a login lasts when "Keep me logged in" is not ticked. Users are also logged out after 10 minutes without activity (default: ` + strconv.Itoa(database.DefaultSessionHours) + ` hours)</p>
+                </div>
 
-	html += `
-            <div style="background: #fff3cd; border: 2px solid #ffc107; border-radius: 8px; padding: 20px; margin-bottom: 30px;">
-                <h3 style="color: #856404; margin-bottom: 10px; font-size: 16px;">📋 Current Public URL</h3>
-                <p style="color: #856404; font-size: 13px; margin-bottom: 12px;">Share this URL with your users to access the system:</p>
-                <div style="display: flex; align-items: center; gap: 10px;">
-                    <input type="text" id="publicUrl" value="` + fullPublicURL + `" readonly
-                           style="flex: 1; padding: 12px; background: white; border: 2px solid #ffc107; font-family: monospace; font-size: 14px; font-weight: 600; color: #d32f2f;">
-                    <button type="button" onclick="copyPublicURL()" class="btn"
-                            style="background: #ffc107; color: #856404; font-weight: 700; white-space: nowrap;">
-                        📋 COPY URL
-                    </button>
+                <div class="form-group">
+                    <label for="remember_me_days">Keep Me Logged In (Days)</label>
+                    <input type="number" id="remember_me_days" name="remember_me_days" value="` + strconv.Itoa(rememberMeDays) + `" min="0" max="` + strconv.Itoa(database.MaxRememberMeDays) + `" required>
+                    <p class="help-text">How long a login lasts when the user ticks "Keep me logged in", without the inactivity logout. Changes apply to new logins (0 = don't offer the option, default: ` + strconv.Itoa(database.DefaultRememberMeDays) + ` days)</p>
                 </div>
-                <p id="copyStatus" style="color: #28a745; font-size: 12px; margin-top: 8px; display: none;">✓ Copied to clipboard!</p>
-            </div>
 
-            <form method="POST" action="/admin/settings">
                 <div class="form-group">
-                    <label for="server_url">Server URL</label>
-                    <input type="url" id="server_url" name="server_url" value="` + serverURL + `" required>
-                    <p class="help-text">The public URL where this server is accessible (e.g., https://files.manvarg.se). Do not include the port - it's configured separately below.</p>
+                    <label for="trusted_device_days">Remember 2FA Devices (Days)</label>
+                    <input type="number" id="trusted_device_days" name="trusted_device_days" value="` + fmt.Sprintf("%d", trustedDeviceDays) + `" min="0" max="365" required>
+                    <p class="help-text">How long users can skip the 2FA code on a device where they chose "Remember this device" (0 = never remember, default: 30 days)</p>
                 </div>
 
                 <div class="form-group">
-                    <label for="port">Server Port</label>
-                    <input type="number" id="port" name="port" value="` + port + `" min="1" max="65535" required>
-                    <p class="help-text">Port number for the server to listen on. Ports below 1024 require administrator privileges.</p>
-                    <p class="help-text" style="color: #ff6b00; font-weight: 600;">⚠️ Changes require server restart to take effect</p>
+                    <label for="redirect_allowed_paths">Allowed Pages After Login</label>
+                    <textarea id="redirect_allowed_paths" name="redirect_allowed_paths" rows="3" placeholder="/dashboard&#10;/s/">` + template.HTMLEscapeString(strings.Join(getRedirectAllowedPaths(), "\n")) + `</textarea>
+                    <p class="help-text">Path prefixes, one per line, that a login may continue to when a user was sent to the login page from a link. Links to other sites are always refused (empty = any page on this server)</p>
                 </div>
 
                 <div class="form-group">
-                    <label for="max_file_size_mb">Max File Size (MB)</label>
-                    <input type="number" id="max_file_size_mb" name="max_file_size_mb" value="` + maxFileSizeMB + `" min="1" required>
-                    <p class="help-text">Maximum file size users can upload</p>
+                    <label for="admin_ip_allowlist">Admin Access Allowlist</label>
+                    <textarea id="admin_ip_allowlist" name="admin_ip_allowlist" rows="3" placeholder="192.168.1.0/24&#10;10.0.0.5">` + template.HTMLEscapeString(formatIPNetList(ipRules.Admin.Allow)) + `</textarea>
+                    <p class="help-text">IP addresses or CIDR ranges, one per line, that may use the admin pages and admin API (empty = any address)</p>
                 </div>
 
                 <div class="form-group">
-                    <label for="default_quota_mb">Default User Quota (MB)</label>
-                    <input type="number" id="default_quota_mb" name="default_quota_mb" value="` + defaultQuotaMB + `" min="100" required>
-                    <p class="help-text">Default storage quota for new users</p>
+                    <label for="admin_ip_denylist">Admin Access Denylist</label>
+                    <textarea id="admin_ip_denylist" name="admin_ip_denylist" rows="3">` + template.HTMLEscapeString(formatIPNetList(ipRules.Admin.Deny)) + `</textarea>
+                    <p class="help-text">IP addresses or CIDR ranges, one per line, that may never use the admin pages, even if they are on the allowlist</p>
                 </div>
 
                 <div class="form-group">
-                    <label for="trash_retention_days">Trash Retention Period (Days)</label>
-                    <input type="number" id="trash_retention_days" name="trash_retention_days" value="` + trashRetentionDays + `" min="1" max="365" required>
-                    <p class="help-text">Number of days to keep deleted files in trash before permanent deletion</p>
+                    <label for="download_ip_allowlist">Download Allowlist</label>
+                    <textarea id="download_ip_allowlist" name="download_ip_allowlist" rows="3">` + template.HTMLEscapeString(formatIPNetList(ipRules.Download.Allow)) + `</textarea>
+                    <p class="help-text">IP addresses or CIDR ranges, one per line, that may open share links and download files (empty = any address)</p>
                 </div>
 
                 <div class="form-group">
-                    <label for="audit_log_retention_days">Audit Log Retention (Days)</label>
-                    <input type="number" id="audit_log_retention_days" name="audit_log_retention_days" value="` + auditLogRetentionDays + `" min="1" max="3650" required>
-                    <p class="help-text">Number of days to keep audit logs before automatic cleanup (default: 90 days)</p>
+                    <label for="download_ip_denylist">Download Denylist</label>
+                    <textarea id="download_ip_denylist" name="download_ip_denylist" rows="3">` + template.HTMLEscapeString(formatIPNetList(ipRules.Download.Deny)) + `</textarea>
+                    <p class="help-text">IP addresses or CIDR ranges, one per line, that may never open share links or download files. Behind a reverse proxy, add it to <code>trustedProxies</code> in config.json so the real client address is used</p>
                 </div>
 
                 <div class="form-group">
-                    <label for="audit_log_max_size_mb">Audit Log Max Size (MB)</label>
-                    <input type="number" id="audit_log_max_size_mb" name="audit_log_max_size_mb" value="` + auditLogMaxSizeMB + `" min="10" max="10000" required>
-                    <p class="help-text">Maximum database size for audit logs before automatic cleanup of oldest entries (default: 100 MB)</p>
+                    <label for="expiry_reminder_lead_hours">Expiry Reminder Lead Times (Hours)</label>
+                    <input type="text" id="expiry_reminder_lead_hours" name="expiry_reminder_lead_hours" value="` + strings.Join(expiryReminderLeadValues, ",") + `" pattern="[0-9, ]+" required>
+                    <p class="help-text">Comma-separated hours before expiry when recipients of files with expiry reminders enabled are emailed (default: 72,24)</p>
                 </div>
 
                 <div class="form-group">
-                    <label for="server_log_max_size_mb">Server Log Max Size (MB)</label>
-                    <input type="number" id="server_log_max_size_mb" name="server_log_max_size_mb" value="` + serverLogMaxSizeMB + `" min="10" max="1000" required>
-                    <p class="help-text">Maximum file size for server logs before automatic rotation (default: 50 MB)</p>
+                    <label for="expiry_reminder_daily_cap">Expiry Reminders per Recipient per Day</label>
+                    <input type="number" id="expiry_reminder_daily_cap" name="expiry_reminder_daily_cap" value="` + fmt.Sprintf("%d", expiryReminderDailyCap) + `" min="0" max="100" required>
+                    <p class="help-text">Maximum number of expiry reminders one email address can receive in 24 hours (0 = unlimited, default: 5)</p>
                 </div>
 
                 <div class="form-group">
@@ -4186,6 +5865,17 @@ func (s *Server) renderAdminSettings(w http.ResponseWriter, message string) {
             </form>
         </div>
 
+        <div class="card" style="margin-top: 30px;">
+            <h2>Storage Usage</h2>
+            <p style="color: #666; margin-bottom: 20px;">
+                Recount every user's storage usage from their files, in case it no longer matches what they have stored.
+                Check first to see who would change without saving anything.
+            </p>
+            <button type="button" onclick="recalculateStorage(true)" class="btn" style="background: #e0e0e0;">Check Storage Usage</button>
+            <button type="button" onclick="recalculateStorage(false)" class="btn btn-primary" style="margin-left: 10px;">Recalculate Storage Usage</button>
+            <div id="storageReport" style="margin-top: 20px;"></div>
+        </div>
+
         <!-- RESTART SERVER BUTTON - DISABLED UNTIL SYSTEMD IS INSTALLED
              To enable: Uncomment this section after installing systemd service
              See README.md section "Server Restart Feature" for details
@@ -4255,6 +5945,80 @@ func (s *Server) renderAdminSettings(w http.ResponseWriter, message string) {
             }
         }
 
+        function rescanUnscannedFiles() {
+            const status = document.getElementById('rescanStatus');
+            status.textContent = 'Starting...';
+            fetch('/admin/virus-scan/rescan', { method: 'POST' })
+                .then(response => response.json())
+                .then(data => {
+                    status.textContent = data.error ? data.error : data.queued + ' files queued for a new scan.';
+                })
+                .catch(() => {
+                    status.textContent = 'Failed to start the rescan';
+                });
+        }
+
+        function recalculateStorage(dryRun) {
+            if (!dryRun && !confirm('Recalculate storage usage for all users from their files?')) {
+                return;
+            }
+            const report = document.getElementById('storageReport');
+            report.textContent = dryRun ? 'Checking...' : 'Recalculating...';
+
+            const body = new URLSearchParams();
+            if (dryRun) {
+                body.append('dry_run', '1');
+            }
+            fetch('/admin/storage/recalculate', { method: 'POST', body: body })
+                .then(response => response.json())
+                .then(data => {
+                    report.innerHTML = '';
+                    if (data.error) {
+                        report.textContent = data.error;
+                        return;
+                    }
+                    const summary = document.createElement('p');
+                    if (data.changes.length === 0) {
+                        summary.textContent = 'All ' + data.users_checked + ' users already show the right storage usage.';
+                        report.appendChild(summary);
+                        return;
+                    }
+                    summary.textContent = (dryRun ? 'These users would change' : 'Updated storage usage') +
+                        ' (' + data.changes.length + ' of ' + data.users_checked + ' users, ' +
+                        (data.total_diff_mb > 0 ? '+' : '') + data.total_diff_mb + ' MB in total):';
+                    report.appendChild(summary);
+
+                    const table = document.createElement('table');
+                    table.style.width = '100%';
+                    table.style.borderCollapse = 'collapse';
+                    const header = table.insertRow();
+                    ['User', 'Before', 'After', 'Change'].forEach(text => {
+                        const th = document.createElement('th');
+                        th.textContent = text;
+                        th.style.textAlign = 'left';
+                        th.style.padding = '6px';
+                        th.style.borderBottom = '1px solid #ddd';
+                        header.appendChild(th);
+                    });
+                    data.changes.forEach(change => {
+                        const row = table.insertRow();
+                        const cells = change.error
+                            ? [change.email, change.old_mb + ' MB', '-', change.error]
+                            : [change.email, change.old_mb + ' MB', change.new_mb + ' MB', (change.diff_mb > 0 ? '+' : '') + change.diff_mb + ' MB'];
+                        cells.forEach(text => {
+                            const cell = row.insertCell();
+                            cell.textContent = text;
+                            cell.style.padding = '6px';
+                            cell.style.borderBottom = '1px solid #eee';
+                        });
+                    });
+                    report.appendChild(table);
+                })
+                .catch(err => {
+                    report.textContent = 'Request failed: ' + err;
+                });
+        }
+
         /* RESTART SERVER FUNCTION - Uncomment when systemd is installed
         function confirmReboot() {
             if (confirm('Are you sure you want to restart the server?\n\nThis will briefly interrupt service. Continue?')) {
@@ -4282,7 +6046,7 @@ func (s *Server) renderAdminTrash(w http.ResponseWriter, files []*database.FileI
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -4456,8 +6220,14 @@ func (s *Server) renderAdminTrash(w http.ResponseWriter, files []*database.FileI
         </div>
 
         <div class="info-box">
-            ⚠️ Files in trash will be automatically deleted after ` + fmt.Sprintf("%d", s.config.TrashRetentionDays) + ` days. You can restore or permanently delete them here.
-        </div>
+            ⚠️ Files in trash will be automatically deleted after ` + fmt.Sprintf("%d", s.config.TrashRetentionDays) + ` days, unless a different retention was chosen when the file was deleted. You can restore or permanently delete them here.
+        </div>`
+
+	if len(files) > 0 {
+		html += bulkSelectionHTML("/admin/trash/bulk-restore", "♻️ Restore Selected", "Restore %d file(s) from trash?", "#10b981")
+	}
+
+	html += `
 
         <div class="file-list">`
 
@@ -4486,13 +6256,17 @@ func (s *Server) renderAdminTrash(w http.ResponseWriter, files []*database.FileI
 			}
 		}
 
-		// Calculate days left using configured retention period
+		// Calculate days left using the file's own retention or the configured retention period
 		deletedAt := time.Unix(f.DeletedAt, 0)
 		retentionDays := s.config.TrashRetentionDays
 		if retentionDays <= 0 {
 			retentionDays = 5 // fallback to 5 days if not configured
 		}
-		deleteAfter := deletedAt.Add(time.Duration(retentionDays) * 24 * time.Hour)
+		deleteAfter := database.DB.TrashPurgeTime(f, retentionDays)
+		retentionNote := ""
+		if days := database.DB.GetFileTrashRetention(f.Id); days > 0 {
+			retentionNote = fmt.Sprintf(" (kept %d days)", days)
+		}
 		daysLeft := int(time.Until(deleteAfter).Hours() / 24)
 		if daysLeft < 0 {
 			daysLeft = 0
@@ -4506,10 +6280,11 @@ func (s *Server) renderAdminTrash(w http.ResponseWriter, files []*database.FileI
 
 		html += fmt.Sprintf(`
             <div class="file-item">
+                %s
                 <div class="file-info">
                     <h3>📄 %s%s</h3>
                     <p>Owner: %s • Size: %s • Deleted: %s</p>
-                    <p>Deleted by: %s • Auto-delete in: %d days</p>
+                    <p>Deleted by: %s • Auto-delete in: %d days • Purged on: %s%s • <a href="/admin/audit-logs?entity_id=%s">History</a></p>
                 </div>
                 <div class="file-actions">
                     <button class="btn btn-restore" onclick="restoreFile('%s')">
@@ -4520,6 +6295,7 @@ func (s *Server) renderAdminTrash(w http.ResponseWriter, files []*database.FileI
                     </button>
                 </div>
             </div>`,
+			bulkCheckboxHTML(f.Id),
 			template.HTMLEscapeString(f.Name),
 			warningBadge,
 			template.HTMLEscapeString(userName),
@@ -4527,6 +6303,9 @@ func (s *Server) renderAdminTrash(w http.ResponseWriter, files []*database.FileI
 			deletedAt.Format("2006-01-02 15:04"),
 			template.HTMLEscapeString(deletedByName),
 			daysLeft,
+			deleteAfter.Format("2006-01-02 15:04"),
+			retentionNote,
+			url.QueryEscape(f.Id),
 			f.Id,
 			f.Id)
 	}
@@ -4644,6 +6423,23 @@ func stripPortFromURL(urlStr string) string {
 	return parsedURL.String()
 }
 
+// getConfiguredBindAddress returns the bind address saved in settings, which may not be in
+// use until the server is restarted
+func (s *Server) getConfiguredBindAddress() string {
+	configPath := filepath.Join(s.config.DataDir, "config.json")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return s.config.BindAddress
+	}
+	var saved struct {
+		BindAddress *string `json:"bindAddress"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil || saved.BindAddress == nil {
+		return s.config.BindAddress
+	}
+	return *saved.BindAddress
+}
+
 // updateConfigJSON updates a single field in config.json
 func (s *Server) updateConfigJSON(key, value string) error {
 	configPath := filepath.Join(s.config.DataDir, "config.json")
@@ -4701,12 +6497,12 @@ func (s *Server) handleAdminReboot(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(500 * time.Millisecond)
 		log.Println("🔄 Attempting graceful server restart...")
 
-		// Try systemctl restart first
+		// Try systemctl restart first; systemd stops the server with SIGTERM, which drains it
 		cmd := exec.Command("systemctl", "restart", "wulfvault")
 		if err := cmd.Run(); err != nil {
-			// If systemctl doesn't work, just exit (process manager will restart)
-			log.Println("systemctl not available, exiting for process manager restart...")
-			os.Exit(0)
+			// If systemctl doesn't work, shut down gracefully and exit (process manager will restart)
+			log.Println("systemctl not available, shutting down for process manager restart...")
+			s.requestShutdown()
 		}
 	}()
 }
@@ -0,0 +1,437 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// getPublicLinkApprovalStatus returns the approval status of a file's public link,
+// or "" if the link does not need approval
+func (s *Server) getPublicLinkApprovalStatus(fileInfo *database.FileInfo) string {
+	if fileInfo.RequireAuth {
+		return ""
+	}
+	return database.DB.GetFileApprovalStatus(fileInfo.Id)
+}
+
+// requestUploadApprovalIfNeeded puts a public upload in the pending state when the
+// global or team setting requires it, and notifies the approvers.
+// Returns true if the file now awaits approval. If the approval request can't be recorded,
+// the file's link is made to require login instead, so it is never public unapproved, and
+// the error is returned for the uploader.
+func (s *Server) requestUploadApprovalIfNeeded(user *models.User, fileInfo *database.FileInfo, r *http.Request) (bool, error) {
+	if fileInfo.RequireAuth {
+		return false, nil
+	}
+
+	var teamIds []int
+	if teams, err := database.DB.GetFileTeams(fileInfo.Id); err == nil {
+		for _, team := range teams {
+			teamIds = append(teamIds, team.Id)
+		}
+	}
+
+	required, teamId := database.DB.IsUploadApprovalRequired(teamIds)
+	if !required {
+		return false, nil
+	}
+
+	// Admins and designated approvers release their own uploads
+	if user.IsAdmin() || database.DB.IsDesignatedApprover(user.Email) {
+		return false, nil
+	}
+
+	if err := database.DB.CreateFileApproval(fileInfo.Id, teamId, user.Id); err != nil {
+		log.Printf("❌ Failed to create approval request for file %s, its link now requires login: %v", fileInfo.Id, err)
+		if err := database.DB.UpdateFileRequireAuth(fileInfo.Id, true); err != nil {
+			log.Printf("❌ Failed to make file %s require login: %v", fileInfo.Id, err)
+		}
+		fileInfo.RequireAuth = true
+		return false, fmt.Errorf("the approval request could not be created, so the link requires login: %w", err)
+	}
+
+	log.Printf("File %s (%s) by user %d is awaiting approval", fileInfo.Id, fileInfo.Name, user.Id)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionFileApprovalRequested,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name": fileInfo.Name,
+			"team_id":   teamId,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	go func() {
+		approvers, err := database.DB.GetApproverEmails(teamId)
+		if err != nil {
+			log.Printf("Failed to get approvers for file %s: %v", fileInfo.Id, err)
+		}
+		for _, approver := range approvers {
+			if strings.EqualFold(approver, user.Email) {
+				continue
+			}
+			if !database.DB.NotifyUserByEmail(approver, database.NotifyApprovals, "Upload awaiting approval", user.Email+" uploaded "+fileInfo.Name, "/approvals") {
+				continue
+			}
+			if err := email.SendUploadApprovalRequestEmail(approver, fileInfo.Name, user.Email, s.getPublicURL(), s.config.CompanyName); err != nil {
+				log.Printf("Failed to send approval request to %s: %v", approver, err)
+			}
+		}
+	}()
+
+	return true, nil
+}
+
+// canApproveFile checks if a user may decide on an approval request
+func (s *Server) canApproveFile(user *models.User, approval *database.FileApproval) bool {
+	if user.IsAdmin() || database.DB.IsDesignatedApprover(user.Email) {
+		return true
+	}
+
+	// Team admins may release their team's uploads, but not their own
+	if approval.TeamId == 0 || approval.RequestedBy == user.Id {
+		return false
+	}
+	member, err := database.DB.GetTeamMember(approval.TeamId, user.Id)
+	if err != nil {
+		return false
+	}
+	return member.CanReviewUploads()
+}
+
+// handleApprovals lists uploads awaiting approval that the user may decide on
+func (s *Server) handleApprovals(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	pending, err := database.DB.GetPendingApprovals()
+	if err != nil {
+		log.Printf("Failed to get pending approvals: %v", err)
+		http.Error(w, "Failed to load approvals", http.StatusInternalServerError)
+		return
+	}
+
+	var approvals []*database.FileApproval
+	for _, approval := range pending {
+		if s.canApproveFile(user, approval) {
+			approvals = append(approvals, approval)
+		}
+	}
+
+	s.renderApprovals(w, user, approvals)
+}
+
+// handleApprovalDecide approves or rejects a pending upload
+func (s *Server) handleApprovalDecide(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	fileID := r.FormValue("file_id")
+	decision := r.FormValue("decision")
+	note := strings.TrimSpace(r.FormValue("note"))
+
+	if fileID == "" {
+		s.sendError(w, http.StatusBadRequest, "Missing file_id")
+		return
+	}
+
+	var status, action string
+	switch decision {
+	case "approve":
+		status = database.ApprovalStatusApproved
+		action = database.ActionFileApproved
+	case "reject":
+		status = database.ApprovalStatusRejected
+		action = database.ActionFileRejected
+	default:
+		s.sendError(w, http.StatusBadRequest, "Decision must be approve or reject")
+		return
+	}
+
+	approval, err := database.DB.GetFileApproval(fileID)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "Approval request not found")
+		return
+	}
+
+	if !s.canApproveFile(user, approval) {
+		s.sendError(w, http.StatusForbidden, "You are not allowed to approve this file")
+		return
+	}
+
+	if err := database.DB.DecideFileApproval(fileID, status, user.Id, note); err != nil {
+		s.sendError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	log.Printf("File %s %s by %s", fileID, status, user.Email)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     action,
+		EntityType: database.EntityFile,
+		EntityID:   fileID,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":    approval.FileName,
+			"requested_by": approval.RequesterEmail,
+			"note":         note,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	if approval.RequesterEmail != "" {
+		go func() {
+			title := "Upload rejected"
+			if status == database.ApprovalStatusApproved {
+				title = "Upload approved"
+			}
+			if !database.DB.NotifyUserByEmail(approval.RequesterEmail, database.NotifyApprovals, title, "Your upload "+approval.FileName+" was reviewed", "/dashboard") {
+				return
+			}
+			if err := email.SendUploadApprovalDecisionEmail(approval.RequesterEmail, approval.FileName, status == database.ApprovalStatusApproved, note, s.getPublicURL(), s.config.CompanyName); err != nil {
+				log.Printf("Failed to send approval decision to %s: %v", approval.RequesterEmail, err)
+			}
+		}()
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"status":  status,
+	})
+}
+
+// renderApprovals renders the pending approvals page
+func (s *Server) renderApprovals(w http.ResponseWriter, user *models.User, approvals []*database.FileApproval) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Pending Approvals - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1400px;
+            margin: 40px auto;
+            padding: 0 20px;
+            padding-top: 40px;
+        }
+        h2 {
+            margin: 30px 0 20px 0;
+            color: #333;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+        }
+        .file-list {
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 1px 3px rgba(0,0,0,0.08);
+            overflow: hidden;
+        }
+        .file-item {
+            padding: 20px 24px;
+            border-bottom: 3px solid ` + s.getPrimaryColor() + `;
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            gap: 20px;
+        }
+        .file-item:last-child {
+            border-bottom: none;
+        }
+        .file-info {
+            flex: 1;
+            min-width: 0;
+        }
+        .file-info h3 {
+            font-size: 16px;
+            font-weight: 600;
+            color: #333;
+            margin-bottom: 8px;
+            word-wrap: break-word;
+        }
+        .file-info p {
+            font-size: 14px;
+            color: #666;
+            margin: 4px 0;
+        }
+        .file-actions {
+            display: flex;
+            gap: 10px;
+            flex-shrink: 0;
+        }
+        .btn {
+            padding: 10px 20px;
+            border: none;
+            border-radius: 6px;
+            font-size: 14px;
+            font-weight: 600;
+            cursor: pointer;
+            white-space: nowrap;
+        }
+        .btn-approve {
+            background: #4caf50;
+            color: white;
+        }
+        .btn-reject {
+            background: #f44336;
+            color: white;
+        }
+        .empty-state {
+            text-align: center;
+            padding: 60px 20px;
+            color: #999;
+        }
+        .empty-state-icon {
+            font-size: 48px;
+            margin-bottom: 16px;
+        }
+
+        @media screen and (max-width: 768px) {
+            .file-item {
+                flex-direction: column;
+                align-items: flex-start;
+            }
+            .file-actions {
+                width: 100%;
+                flex-direction: column;
+            }
+        }
+    </style>
+</head>
+<body>
+    ` + s.getHeaderHTML(user, user.IsAdmin()) + `
+    <div class="container">
+        <h2>⏳ Pending Approvals</h2>
+
+        <div class="info-box">
+            Files uploaded with a public link must be approved before the link works. Approving releases the link; rejecting keeps it disabled. The uploader is notified either way.
+        </div>
+
+        <div class="file-list">`
+
+	if len(approvals) == 0 {
+		html += `
+            <div class="empty-state">
+                <div class="empty-state-icon">🎉</div>
+                <p>No uploads are waiting for your approval</p>
+            </div>`
+	}
+
+	for _, approval := range approvals {
+		teamInfo := ""
+		if approval.TeamId > 0 {
+			if team, err := database.DB.GetTeamByID(approval.TeamId); err == nil {
+				teamInfo = " • Team: " + template.HTMLEscapeString(team.Name)
+			}
+		}
+
+		html += fmt.Sprintf(`
+            <div class="file-item">
+                <div class="file-info">
+                    <h3>📄 %s</h3>
+                    <p>Uploaded by: %s • Size: %s%s</p>
+                    <p>Requested: %s</p>
+                </div>
+                <div class="file-actions">
+                    <button class="btn btn-approve" onclick="decide('%s', 'approve')">✓ Approve</button>
+                    <button class="btn btn-reject" onclick="decide('%s', 'reject')">✗ Reject</button>
+                </div>
+            </div>`,
+			template.HTMLEscapeString(approval.FileName),
+			template.HTMLEscapeString(approval.RequesterEmail),
+			approval.FileSize,
+			teamInfo,
+			time.Unix(approval.RequestedAt, 0).Format("2006-01-02 15:04"),
+			approval.FileId,
+			approval.FileId)
+	}
+
+	html += `
+        </div>
+    </div>
+
+    <script>
+        async function decide(fileId, decision) {
+            let note = '';
+            if (decision === 'reject') {
+                note = prompt('Reason for rejection (optional, sent to the uploader):');
+                if (note === null) return;
+            } else if (!confirm('Approve this file and activate its public link?')) {
+                return;
+            }
+
+            try {
+                const response = await fetch('/approvals/decide', {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+                    body: 'file_id=' + encodeURIComponent(fileId) + '&decision=' + decision + '&note=' + encodeURIComponent(note)
+                });
+
+                if (response.ok) {
+                    location.reload();
+                } else {
+                    const result = await response.json();
+                    alert('Failed: ' + (result.error || 'Unknown error'));
+                }
+            } catch (error) {
+                alert('Failed: ' + error.message);
+            }
+        }
+    </script>
+    <div style="text-align:center; font-size: 0.8em; margin-top: 2em; padding: 1em; color:#777;">
+        Powered by WulfVault © Ulf Holmström – AGPL-3.0
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
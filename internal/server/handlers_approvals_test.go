@@ -0,0 +1,42 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// Sharing a public file with a team that approves uploads holds its link for approval
+func TestShareFileToTeamRequestsApproval(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "public", []byte("hello"), nil)
+
+	team := &models.Team{Name: "Legal", CreatedBy: owner.Id, StorageQuotaMB: 1000, IsActive: true, RequireApproval: true}
+	if err := database.DB.CreateTeam(team); err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"file_id":"public","team_id":%d}`, team.Id)
+	r := httptest.NewRequest(http.MethodPost, "/api/teams/share-file", strings.NewReader(body))
+	w := serve(s.handleAPIShareFileToTeam, r.WithContext(contextWithUser(r.Context(), owner)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"pending_approval":true`) {
+		t.Fatalf("share with the team: status %d, body %q", w.Code, w.Body.String())
+	}
+	if status := database.DB.GetFileApprovalStatus("public"); status != database.ApprovalStatusPending {
+		t.Errorf("approval status %q, want %q", status, database.ApprovalStatusPending)
+	}
+	if w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/public", nil)); w.Code != http.StatusForbidden {
+		t.Errorf("download before approval: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
@@ -18,41 +20,54 @@ import (
 
 // handleAdminAuditLogs displays the audit log admin page
 func (s *Server) handleAdminAuditLogs(w http.ResponseWriter, r *http.Request) {
-	s.renderAdminAuditLogsPage(w)
+	actions, entityTypes, err := database.DB.GetAuditLogActions()
+	if err != nil {
+		log.Printf("Error fetching audit log actions: %v", err)
+	}
+	s.renderAdminAuditLogsPage(w, actions, entityTypes)
 }
 
-// handleAPIGetAuditLogs returns audit logs with filtering and pagination
-func (s *Server) handleAPIGetAuditLogs(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// auditLogFilterFromRequest reads the audit log filters of the API and CSV export from the query
+// string. Dates are Unix timestamps; status is "success" or "failure".
+func auditLogFilterFromRequest(r *http.Request) *database.AuditLogFilter {
+	query := r.URL.Query()
+	filter := &database.AuditLogFilter{
+		UserEmail:  strings.TrimSpace(query.Get("user_email")),
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+		EntityID:   strings.TrimSpace(query.Get("entity_id")),
+		SearchTerm: query.Get("search"),
 	}
 
-	// Parse query parameters
-	filter := &database.AuditLogFilter{}
+	if userID, err := strconv.ParseInt(query.Get("user_id"), 10, 64); err == nil {
+		filter.UserID = userID
+	}
 
-	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
-		if userID, err := strconv.ParseInt(userIDStr, 10, 64); err == nil {
-			filter.UserID = userID
-		}
+	switch status := query.Get("status"); status {
+	case database.AuditStatusSuccess, database.AuditStatusFailure:
+		filter.Status = status
 	}
 
-	filter.Action = r.URL.Query().Get("action")
-	filter.EntityType = r.URL.Query().Get("entity_type")
-	filter.SearchTerm = r.URL.Query().Get("search")
+	if startDate, err := strconv.ParseInt(query.Get("start_date"), 10, 64); err == nil {
+		filter.StartDate = startDate
+	}
 
-	if startDateStr := r.URL.Query().Get("start_date"); startDateStr != "" {
-		if startDate, err := strconv.ParseInt(startDateStr, 10, 64); err == nil {
-			filter.StartDate = startDate
-		}
+	if endDate, err := strconv.ParseInt(query.Get("end_date"), 10, 64); err == nil {
+		filter.EndDate = endDate
 	}
 
-	if endDateStr := r.URL.Query().Get("end_date"); endDateStr != "" {
-		if endDate, err := strconv.ParseInt(endDateStr, 10, 64); err == nil {
-			filter.EndDate = endDate
-		}
+	return filter
+}
+
+// handleAPIGetAuditLogs returns audit logs with filtering and pagination
+func (s *Server) handleAPIGetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	filter := auditLogFilterFromRequest(r)
+
 	// Pagination
 	limit := 200
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -103,29 +118,7 @@ func (s *Server) handleAPIExportAuditLogs(w http.ResponseWriter, r *http.Request
 	}
 
 	// Get all logs (with same filtering as API)
-	filter := &database.AuditLogFilter{}
-
-	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
-		if userID, err := strconv.ParseInt(userIDStr, 10, 64); err == nil {
-			filter.UserID = userID
-		}
-	}
-
-	filter.Action = r.URL.Query().Get("action")
-	filter.EntityType = r.URL.Query().Get("entity_type")
-	filter.SearchTerm = r.URL.Query().Get("search")
-
-	if startDateStr := r.URL.Query().Get("start_date"); startDateStr != "" {
-		if startDate, err := strconv.ParseInt(startDateStr, 10, 64); err == nil {
-			filter.StartDate = startDate
-		}
-	}
-
-	if endDateStr := r.URL.Query().Get("end_date"); endDateStr != "" {
-		if endDate, err := strconv.ParseInt(endDateStr, 10, 64); err == nil {
-			filter.EndDate = endDate
-		}
-	}
+	filter := auditLogFilterFromRequest(r)
 
 	// No limit for export - get all matching logs
 	filter.Limit = 0
@@ -189,17 +182,29 @@ func (s *Server) handleAPIExportAuditLogs(w http.ResponseWriter, r *http.Request
 	}
 }
 
-// renderAdminAuditLogsPage renders the audit logs admin page
-func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
+// renderAdminAuditLogsPage renders the audit logs admin page. The filters are offered for the
+// actions and entity types that occur in the log.
+func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter, actions, entityTypes []string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	actionOptions := ""
+	for _, action := range actions {
+		actionOptions += `
+                        <option value="` + template.HTMLEscapeString(action) + `">` + template.HTMLEscapeString(strings.ReplaceAll(action, "_", " ")) + `</option>`
+	}
+	entityTypeOptions := ""
+	for _, entityType := range entityTypes {
+		entityTypeOptions += `
+                        <option value="` + template.HTMLEscapeString(entityType) + `">` + template.HTMLEscapeString(entityType) + `</option>`
+	}
+
 	companyName := s.config.CompanyName
 	if companyName == "" {
 		companyName = "WulfVault"
 	}
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -391,18 +396,47 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
         }
 
         .details-cell {
-            max-width: 250px;
+            max-width: 300px;
+        }
+
+        .details-cell summary,
+        .log-card-details summary {
+            cursor: pointer;
+            color: #667eea;
             overflow: hidden;
             text-overflow: ellipsis;
             white-space: nowrap;
-            cursor: pointer;
-            color: #667eea;
         }
 
-        .details-cell:hover {
+        .details-cell summary:hover,
+        .log-card-details summary:hover {
             text-decoration: underline;
         }
 
+        .details-list {
+            display: grid;
+            grid-template-columns: auto 1fr;
+            gap: 4px 12px;
+            margin-top: 8px;
+            padding: 10px;
+            background: #f5f5f5;
+            border-radius: 4px;
+            font-size: 12px;
+        }
+
+        .details-list dt {
+            font-weight: 600;
+            color: #555;
+        }
+
+        .details-list dd {
+            margin: 0;
+            font-family: 'Courier New', monospace;
+            white-space: pre-wrap;
+            word-break: break-word;
+            color: #333;
+        }
+
         .entity-cell {
             max-width: 180px;
             overflow: hidden;
@@ -428,73 +462,6 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
             font-size: 12px;
         }
 
-        .details-modal {
-            display: none;
-            position: fixed;
-            z-index: 1000;
-            left: 0;
-            top: 0;
-            width: 100%;
-            height: 100%;
-            background-color: rgba(0, 0, 0, 0.5);
-            overflow: auto;
-        }
-
-        .details-modal-content {
-            background-color: white;
-            margin: 50px auto;
-            padding: 30px;
-            border-radius: 8px;
-            max-width: 800px;
-            box-shadow: 0 4px 20px rgba(0, 0, 0, 0.3);
-            position: relative;
-        }
-
-        .details-modal-header {
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-            margin-bottom: 20px;
-            padding-bottom: 15px;
-            border-bottom: 2px solid #e0e0e0;
-        }
-
-        .details-modal-header h3 {
-            margin: 0;
-            color: #333;
-        }
-
-        .details-close {
-            color: #aaa;
-            font-size: 28px;
-            font-weight: bold;
-            cursor: pointer;
-            background: none;
-            border: none;
-            padding: 0;
-            width: 30px;
-            height: 30px;
-            line-height: 30px;
-            text-align: center;
-        }
-
-        .details-close:hover {
-            color: #000;
-        }
-
-        .details-json {
-            background: #f5f5f5;
-            padding: 20px;
-            border-radius: 4px;
-            overflow-x: auto;
-            font-family: 'Courier New', monospace;
-            font-size: 13px;
-            line-height: 1.6;
-            white-space: pre-wrap;
-            word-wrap: break-word;
-            color: #333;
-        }
-
         .loading {
             text-align: center;
             padding: 40px;
@@ -576,15 +543,9 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
             background: #f8f9fa;
             border-radius: 4px;
             font-size: 12px;
-            color: #667eea;
-            cursor: pointer;
             word-break: break-word;
         }
 
-        .log-card-details:hover {
-            background: #e9ecef;
-        }
-
         @media (max-width: 768px) {
             .filters-grid {
                 grid-template-columns: 1fr;
@@ -636,12 +597,6 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
             .pagination-buttons .btn {
                 flex: 1;
             }
-
-            .details-modal-content {
-                margin: 20px;
-                padding: 20px;
-                max-width: calc(100% - 40px);
-            }
         }
     </style>
 </head>
@@ -655,39 +610,29 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
                 <div class="filter-group">
                     <label for="action">Action</label>
                     <select id="action">
-                        <option value="">All Actions</option>
-                        <option value="LOGIN_SUCCESS">Login Success</option>
-                        <option value="LOGIN_FAILED">Login Failed</option>
-                        <option value="LOGOUT">Logout</option>
-                        <option value="FILE_UPLOADED">File Uploaded</option>
-                        <option value="FILE_DOWNLOADED">File Downloaded</option>
-                        <option value="FILE_DELETED">File Deleted</option>
-                        <option value="FILE_RESTORED">File Restored</option>
-                        <option value="FILE_PERMANENTLY_DELETED">File Permanently Deleted</option>
-                        <option value="USER_CREATED">User Created</option>
-                        <option value="USER_UPDATED">User Updated</option>
-                        <option value="USER_DELETED">User Deleted</option>
-                        <option value="USER_ACTIVATED">User Activated</option>
-                        <option value="USER_DEACTIVATED">User Deactivated</option>
-                        <option value="TEAM_CREATED">Team Created</option>
-                        <option value="TEAM_MEMBER_ADDED">Team Member Added</option>
-                        <option value="TEAM_MEMBER_REMOVED">Team Member Removed</option>
-                        <option value="PASSWORD_CHANGED">Password Changed</option>
-                        <option value="2FA_ENABLED">2FA Enabled</option>
-                        <option value="2FA_DISABLED">2FA Disabled</option>
-                        <option value="SETTINGS_UPDATED">Settings Updated</option>
+                        <option value="">All Actions</option>` + actionOptions + `
                     </select>
                 </div>
                 <div class="filter-group">
                     <label for="entity_type">Entity Type</label>
                     <select id="entity_type">
-                        <option value="">All Types</option>
-                        <option value="User">User</option>
-                        <option value="File">File</option>
-                        <option value="Team">Team</option>
-                        <option value="Session">Session</option>
-                        <option value="Settings">Settings</option>
-                        <option value="System">System</option>
+                        <option value="">All Types</option>` + entityTypeOptions + `
+                    </select>
+                </div>
+                <div class="filter-group">
+                    <label for="entity_id">Entity ID</label>
+                    <input type="text" id="entity_id" placeholder="e.g. a file ID">
+                </div>
+                <div class="filter-group">
+                    <label for="user_email">User Email</label>
+                    <input type="text" id="user_email" placeholder="name@example.com">
+                </div>
+                <div class="filter-group">
+                    <label for="status">Status</label>
+                    <select id="status">
+                        <option value="">All</option>
+                        <option value="success">Succeeded</option>
+                        <option value="failure">Failed</option>
                     </select>
                 </div>
                 <div class="filter-group">
@@ -708,7 +653,7 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
                     </select>
                 </div>
                 <div class="filter-group">
-                    <label for="search">Search (Email/Details)</label>
+                    <label for="search">Search (Email/Action/Details/ID)</label>
                     <input type="text" id="search" placeholder="Search...">
                 </div>
             </div>
@@ -762,22 +707,20 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
         </div>
     </div>
 
-    <!-- Details Modal -->
-    <div id="detailsModal" class="details-modal">
-        <div class="details-modal-content">
-            <div class="details-modal-header">
-                <h3>📋 Audit Log Details</h3>
-                <button class="details-close" onclick="closeDetailsModal()">&times;</button>
-            </div>
-            <div class="details-json" id="detailsJson"></div>
-        </div>
-    </div>
-
     <script>
         let currentOffset = 0;
         let limit = 20;
         let totalCount = 0;
 
+        // Filters kept in the page URL, so a filtered view can be bookmarked or linked to
+        const filterFields = ['action', 'entity_type', 'entity_id', 'user_email', 'status', 'search', 'start_date', 'end_date'];
+
+        function escapeHtml(text) {
+            const div = document.createElement('div');
+            div.textContent = text == null ? '' : String(text);
+            return div.innerHTML;
+        }
+
         function formatTimestamp(timestamp) {
             const date = new Date(timestamp * 1000);
             return date.toLocaleString();
@@ -786,7 +729,7 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
         function getActionBadgeClass(action) {
             if (action.includes('LOGIN_SUCCESS') || action.includes('CREATED') || action.includes('ENABLED') || action.includes('ACTIVATED')) {
                 return 'badge-success';
-            } else if (action.includes('FAILED') || action.includes('DELETED') || action.includes('DISABLED') || action.includes('DEACTIVATED')) {
+            } else if (action.includes('FAILED') || action.includes('DELETED') || action.includes('DISABLED') || action.includes('DEACTIVATED') || action.includes('LOCKED')) {
                 return 'badge-danger';
             } else if (action.includes('UPDATED') || action.includes('CHANGED')) {
                 return 'badge-warning';
@@ -794,54 +737,107 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
             return 'badge-info';
         }
 
-        function showDetails(details) {
-            const modal = document.getElementById('detailsModal');
-            const jsonDiv = document.getElementById('detailsJson');
-
-            // Unescape the details string
-            const unescaped = details.replace(/\\'/g, "'").replace(/&quot;/g, '"');
-
-            // Try to parse and pretty-print JSON
+        // detailFields returns the details of an entry as [name, value] pairs. Details are
+        // usually a JSON object; anything else is shown as it is.
+        function detailFields(log) {
+            let fields = [];
             try {
-                const parsed = JSON.parse(unescaped);
-                jsonDiv.textContent = JSON.stringify(parsed, null, 2);
+                const parsed = JSON.parse(log.details);
+                if (parsed && typeof parsed === 'object' && !Array.isArray(parsed)) {
+                    fields = Object.entries(parsed).map(([key, value]) =>
+                        [key, typeof value === 'object' ? JSON.stringify(value, null, 2) : String(value)]);
+                } else if (log.details) {
+                    fields = [['details', log.details]];
+                }
             } catch (e) {
-                // If not valid JSON, just show as-is with better formatting
-                jsonDiv.textContent = unescaped;
+                if (log.details) {
+                    fields = [['details', log.details]];
+                }
+            }
+            if (log.error_msg) {
+                fields.push(['error', log.error_msg]);
+            }
+            if (log.entity_id) {
+                fields.push(['entity id', log.entity_id]);
             }
+            if (log.user_agent) {
+                fields.push(['user agent', log.user_agent]);
+            }
+            return fields;
+        }
 
-            modal.style.display = 'block';
+        // renderDetails returns the details as a summary line that expands to all fields
+        function renderDetails(log) {
+            const fields = detailFields(log);
+            if (fields.length === 0) {
+                return '';
+            }
+            const summary = fields.map(([key, value]) => key + ': ' + value).join(', ');
+            return '<details><summary title="Click to show all details">' + escapeHtml(summary) + '</summary>' +
+                '<dl class="details-list">' +
+                fields.map(([key, value]) => '<dt>' + escapeHtml(key) + '</dt><dd>' + escapeHtml(value) + '</dd>').join('') +
+                '</dl></details>';
+        }
+
+        function readFiltersFromURL() {
+            const params = new URLSearchParams(window.location.search);
+            filterFields.forEach(field => {
+                const value = params.get(field);
+                if (value === null) return;
+                const input = document.getElementById(field);
+                // Actions and entity types that aren't in the log yet still need an option
+                if (input.tagName === 'SELECT' && !Array.from(input.options).some(o => o.value === value)) {
+                    input.add(new Option(value.replace(/_/g, ' '), value));
+                }
+                input.value = value;
+            });
+            const pageLimit = parseInt(params.get('limit'));
+            if ([20, 50, 100, 200].includes(pageLimit)) {
+                limit = pageLimit;
+                document.getElementById('items_per_page').value = pageLimit;
+            }
+            const offset = parseInt(params.get('offset'));
+            if (offset > 0) {
+                currentOffset = offset;
+            }
         }
 
-        function closeDetailsModal() {
-            document.getElementById('detailsModal').style.display = 'none';
+        function updateURL() {
+            const params = new URLSearchParams();
+            filterFields.forEach(field => {
+                const value = document.getElementById(field).value.trim();
+                if (value) params.set(field, value);
+            });
+            if (limit !== 20) params.set('limit', limit);
+            if (currentOffset > 0) params.set('offset', currentOffset);
+            const query = params.toString();
+            history.replaceState(null, '', window.location.pathname + (query ? '?' + query : ''));
         }
 
-        // Close modal when clicking outside
-        window.onclick = function(event) {
-            const modal = document.getElementById('detailsModal');
-            if (event.target === modal) {
-                closeDetailsModal();
-            }
+        // filterParams returns the API query for the current filters; dates are sent as Unix timestamps
+        function filterParams() {
+            const params = new URLSearchParams();
+            filterFields.forEach(field => {
+                const value = document.getElementById(field).value.trim();
+                if (!value) return;
+                if (field === 'start_date') {
+                    params.append(field, new Date(value + 'T00:00:00').getTime() / 1000);
+                } else if (field === 'end_date') {
+                    params.append(field, new Date(value + 'T23:59:59').getTime() / 1000);
+                } else {
+                    params.append(field, value);
+                }
+            });
+            return params;
         }
 
         async function loadLogs() {
-            const params = new URLSearchParams();
+            updateURL();
+
+            const params = filterParams();
             params.append('offset', currentOffset);
             params.append('limit', limit);
 
-            const action = document.getElementById('action').value;
-            const entityType = document.getElementById('entity_type').value;
-            const search = document.getElementById('search').value;
-            const startDate = document.getElementById('start_date').value;
-            const endDate = document.getElementById('end_date').value;
-
-            if (action) params.append('action', action);
-            if (entityType) params.append('entity_type', entityType);
-            if (search) params.append('search', search);
-            if (startDate) params.append('start_date', new Date(startDate).getTime() / 1000);
-            if (endDate) params.append('end_date', new Date(endDate + ' 23:59:59').getTime() / 1000);
-
             try {
                 const response = await fetch('/api/v1/admin/audit-logs?' + params.toString());
                 const data = await response.json();
@@ -859,12 +855,6 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
             }
         }
 
-        function truncateText(text, maxLength) {
-            if (!text) return '';
-            if (text.length <= maxLength) return text;
-            return text.substring(0, maxLength) + '...';
-        }
-
         function formatEntityDisplay(entityType, entityId) {
             if (!entityType) return '';
             // For Session entities, just show "Session" without the long ID
@@ -898,20 +888,22 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
                     ? '<span class="badge badge-success">✓</span>'
                     : '<span class="badge badge-danger">✗</span>';
 
-                const entityDisplay = formatEntityDisplay(log.entity_type, log.entity_id);
-                const detailsPreview = truncateText(log.details, 50);
-                const fullDetails = log.details.replace(/'/g, "\\'").replace(/"/g, '&quot;');
-                const actionText = log.action.replace(/_/g, ' ');
+                const entityDisplay = escapeHtml(formatEntityDisplay(log.entity_type, log.entity_id));
+                const entityTitle = escapeHtml(log.entity_type + (log.entity_id ? ' #' + log.entity_id : ''));
+                const details = renderDetails(log);
+                const actionText = escapeHtml(log.action.replace(/_/g, ' '));
+                const userEmail = escapeHtml(log.user_email);
+                const ipAddress = escapeHtml(log.ip_address);
 
                 // Table row
                 tableHtml += '<tr>' +
                     '<td>' + log.id + '</td>' +
                     '<td class="timestamp-cell">' + formatTimestamp(log.timestamp) + '</td>' +
-                    '<td class="user-cell" title="' + log.user_email + '">' + log.user_email + '</td>' +
+                    '<td class="user-cell" title="' + userEmail + '">' + userEmail + '</td>' +
                     '<td><span class="badge ' + badgeClass + '">' + actionText + '</span></td>' +
-                    '<td class="entity-cell" title="' + log.entity_type + (log.entity_id ? ' #' + log.entity_id : '') + '">' + entityDisplay + '</td>' +
-                    '<td class="details-cell" title="Click to view full details" onclick="showDetails(\'' + fullDetails.replace(/\\/g, '\\\\') + '\')">' + detailsPreview + '</td>' +
-                    '<td class="ip-cell">' + log.ip_address + '</td>' +
+                    '<td class="entity-cell" title="' + entityTitle + '">' + entityDisplay + '</td>' +
+                    '<td class="details-cell">' + details + '</td>' +
+                    '<td class="ip-cell">' + ipAddress + '</td>' +
                     '<td>' + statusBadge + '</td>' +
                     '</tr>';
 
@@ -927,7 +919,7 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
                     '</div>' +
                     '<div class="log-card-row">' +
                     '<span class="log-card-label">User:</span>' +
-                    '<span class="log-card-value">' + log.user_email + '</span>' +
+                    '<span class="log-card-value">' + userEmail + '</span>' +
                     '</div>' +
                     '<div class="log-card-row">' +
                     '<span class="log-card-label">Action:</span>' +
@@ -939,11 +931,9 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
                     '</div>' +
                     '<div class="log-card-row">' +
                     '<span class="log-card-label">IP:</span>' +
-                    '<span class="log-card-value">' + log.ip_address + '</span>' +
-                    '</div>' +
-                    '<div class="log-card-details" onclick="showDetails(\'' + fullDetails.replace(/\\/g, '\\\\') + '\')">' +
-                    '📋 ' + detailsPreview +
+                    '<span class="log-card-value">' + ipAddress + '</span>' +
                     '</div>' +
+                    (details ? '<div class="log-card-details">' + details + '</div>' : '') +
                     '</div>';
             });
 
@@ -955,7 +945,7 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
         function updatePagination() {
             document.getElementById('prev-btn').disabled = currentOffset === 0;
             document.getElementById('next-btn').disabled = currentOffset + limit >= totalCount;
-            document.getElementById('pagination-info').textContent = 'Page ' + (Math.floor(currentOffset / limit) + 1) + ' of ' + Math.ceil(totalCount / limit);
+            document.getElementById('pagination-info').textContent = 'Page ' + (Math.floor(currentOffset / limit) + 1) + ' of ' + Math.max(1, Math.ceil(totalCount / limit));
         }
 
         function updateLimit() {
@@ -966,7 +956,7 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
 
         function prevPage() {
             if (currentOffset > 0) {
-                currentOffset -= limit;
+                currentOffset = Math.max(0, currentOffset - limit);
                 loadLogs();
             }
         }
@@ -984,33 +974,26 @@ func (s *Server) renderAdminAuditLogsPage(w http.ResponseWriter) {
         }
 
         function resetFilters() {
-            document.getElementById('action').value = '';
-            document.getElementById('entity_type').value = '';
-            document.getElementById('search').value = '';
-            document.getElementById('start_date').value = '';
-            document.getElementById('end_date').value = '';
+            filterFields.forEach(field => {
+                document.getElementById(field).value = '';
+            });
             currentOffset = 0;
             loadLogs();
         }
 
         function exportCSV() {
-            const params = new URLSearchParams();
-            const action = document.getElementById('action').value;
-            const entityType = document.getElementById('entity_type').value;
-            const search = document.getElementById('search').value;
-            const startDate = document.getElementById('start_date').value;
-            const endDate = document.getElementById('end_date').value;
-
-            if (action) params.append('action', action);
-            if (entityType) params.append('entity_type', entityType);
-            if (search) params.append('search', search);
-            if (startDate) params.append('start_date', new Date(startDate).getTime() / 1000);
-            if (endDate) params.append('end_date', new Date(endDate + ' 23:59:59').getTime() / 1000);
-
-            window.location.href = '/api/v1/admin/audit-logs/export?' + params.toString();
+            window.location.href = '/api/v1/admin/audit-logs/export?' + filterParams().toString();
         }
 
+        // Apply filters with Enter in the text fields
+        document.querySelectorAll('.filters-card input[type="text"]').forEach(input => {
+            input.addEventListener('keydown', event => {
+                if (event.key === 'Enter') applyFilters();
+            });
+        });
+
         // Load logs on page load
+        readFiltersFromURL();
         loadLogs();
     </script>
 </body>
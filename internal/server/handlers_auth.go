@@ -6,15 +6,19 @@
 package server
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/auth"
 	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
 )
 
 // handleLogin handles user and download account login
@@ -40,11 +44,34 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	rememberMe := r.FormValue("remember_me") == "on"
 
 	// Log login attempt start (for debugging double-submit issues)
-	log.Printf("🔐 Login attempt: %s | IP: %s | RememberMe: %v", email, getClientIP(r), rememberMe)
+	ip := clientIP(r)
+	log.Printf("🔐 Login attempt: %s | IP: %s | RememberMe: %v", email, ip, rememberMe)
+
+	// Refuse logins for an email or IP address with too many recent failures, before checking
+	// the password
+	if locked, until := auth.IsLockedOut(email, ip); locked {
+		log.Printf("🔐 Login refused for %s from %s: locked out until %s", email, ip, until.Format(time.RFC3339))
+		s.renderLoginLockedOut(w, r, until)
+		return
+	}
+
+	// With password login turned off for single sign-on, only download accounts may log in with
+	// a password; in SSO-only mode admins may as well, for break-glass access. That is decided
+	// before the password is checked, so the answer is the same whether or not it was right.
+	usersRefused := s.isPasswordLoginDisabled() || (s.isSSOOnlyMode() && !isAdminLogin(email))
+	if usersRefused {
+		if _, err := database.DB.GetDownloadAccountByEmail(email); err != nil {
+			log.Printf("🔐 Password login rejected for %s: single sign-on is required", email)
+			s.renderLoginPage(w, r, "Password login is disabled. Please sign in with single sign-on.")
+			return
+		}
+	}
 
 	// Try to authenticate as any account type (User or DownloadAccount)
-	authResult, err := auth.AuthenticateAnyAccount(email, password)
+	authResult, err := authenticatePassword(email, password, usersRefused)
 	if err != nil {
+		auth.RecordLoginAttempt(email, ip, false)
+
 		// Log failed login attempt
 		database.DB.LogAction(&database.AuditLogEntry{
 			UserID:     0,
@@ -53,11 +80,36 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			EntityType: "Session",
 			EntityID:   "",
 			Details:    fmt.Sprintf("{\"email\":\"%s\",\"success\":false,\"reason\":\"invalid_credentials\"}", email),
-			IPAddress:  getClientIP(r),
+			IPAddress:  clientIP(r),
 			UserAgent:  r.UserAgent(),
 			Success:    false,
 			ErrorMsg:   "Invalid credentials",
 		})
+
+		// This failure may have reached the limit
+		if locked, until := auth.IsLockedOut(email, ip); locked {
+			perEmail, perIP, window := auth.LoginRateLimits()
+			database.DB.LogAction(&database.AuditLogEntry{
+				UserEmail:  email,
+				Action:     database.ActionLoginLocked,
+				EntityType: "Session",
+				Details: database.CreateAuditDetails(map[string]interface{}{
+					"email":                  email,
+					"locked_until":           until.Format(time.RFC3339),
+					"max_attempts_per_email": perEmail,
+					"max_attempts_per_ip":    perIP,
+					"window_minutes":         int(window.Minutes()),
+				}),
+				IPAddress: ip,
+				UserAgent: r.UserAgent(),
+				Success:   false,
+				ErrorMsg:  "Too many failed login attempts",
+			})
+			log.Printf("🔐 Too many failed logins for %s from %s, locked out until %s", email, ip, until.Format(time.RFC3339))
+			s.renderLoginLockedOut(w, r, until)
+			return
+		}
+
 		s.renderLoginPage(w, r, "Invalid credentials")
 		return
 	}
@@ -67,40 +119,18 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		// Regular user login
 		user := authResult.User
 
-		// Check if 2FA is enabled for this user
-		if user.TOTPEnabled {
-			// Store user ID and remember_me preference in temporary cookie
-			pendingData := map[string]interface{}{
-				"user_id":     user.Id,
-				"created_at":  time.Now().Unix(),
-				"remember_me": rememberMe,
-			}
-			pendingJSON, _ := json.Marshal(pendingData)
-
-			http.SetCookie(w, &http.Cookie{
-				Name:     "totp_pending",
-				Value:    base64.StdEncoding.EncodeToString(pendingJSON),
-				Path:     "/",
-				Expires:  time.Now().Add(5 * time.Minute),
-				HttpOnly: true,
-				SameSite: http.SameSiteStrictMode,
-			})
-
-			http.Redirect(w, r, "/2fa/verify", http.StatusSeeOther)
+		// Check if 2FA is enabled for this user (trusted devices skip the code until they expire)
+		if s.startTOTPVerification(w, r, user, rememberMe, r.URL.Query().Get("redirect")) {
 			return
 		}
 
-		// No 2FA, create session directly with appropriate duration
-		sessionDuration := 24 * time.Hour
-		if rememberMe {
-			sessionDuration = 30 * 24 * time.Hour // 30 days
-		}
-
-		sessionID, err := auth.CreateSession(user.Id, sessionDuration)
+		// No 2FA, create session directly with the duration set in the server settings
+		sessionID, sessionDuration, err := auth.CreateLoginSession(user.Id, rememberMe)
 		if err != nil {
 			s.renderLoginPage(w, r, "Failed to create session")
 			return
 		}
+		auth.RecordLoginAttempt(email, ip, true)
 
 		// Log successful login
 		database.DB.LogAction(&database.AuditLogEntry{
@@ -110,7 +140,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			EntityType: "Session",
 			EntityID:   sessionID,
 			Details:    fmt.Sprintf("{\"email\":\"%s\",\"success\":true}", user.Email),
-			IPAddress:  getClientIP(r),
+			IPAddress:  clientIP(r),
 			UserAgent:  r.UserAgent(),
 			Success:    true,
 			ErrorMsg:   "",
@@ -127,7 +157,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		})
 
 		// Redirect
-		redirect := r.URL.Query().Get("redirect")
+		redirect := s.localRedirectPath(r.URL.Query().Get("redirect"))
 		if redirect == "" {
 			if user.IsAdmin() {
 				redirect = "/admin"
@@ -148,6 +178,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			s.renderLoginPage(w, r, "Failed to create session")
 			return
 		}
+		auth.RecordLoginAttempt(email, ip, true)
 
 		// Log successful download account login
 		database.DB.LogAction(&database.AuditLogEntry{
@@ -157,17 +188,14 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			EntityType: "DownloadSession",
 			EntityID:   fmt.Sprintf("%d", downloadAccount.Id),
 			Details:    fmt.Sprintf("{\"email\":\"%s\",\"success\":true,\"account_type\":\"download\"}", downloadAccount.Email),
-			IPAddress:  getClientIP(r),
+			IPAddress:  clientIP(r),
 			UserAgent:  r.UserAgent(),
 			Success:    true,
 			ErrorMsg:   "",
 		})
 
 		// Set download account session cookie with appropriate expiration
-		sessionDuration := 24 * time.Hour
-		if rememberMe {
-			sessionDuration = 30 * 24 * time.Hour // 30 days
-		}
+		sessionDuration := auth.LoginSessionDuration(rememberMe)
 
 		http.SetCookie(w, &http.Cookie{
 			Name:     "download_session",
@@ -185,6 +213,74 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// isAdminLogin reports whether a login name, an email address or user name as accepted by
+// auth.AuthenticateUser, belongs to an admin
+func isAdminLogin(emailOrUsername string) bool {
+	user, err := database.DB.GetUserByEmail(emailOrUsername)
+	if err != nil {
+		if user, err = database.DB.GetUserByName(emailOrUsername); err != nil {
+			return false
+		}
+	}
+	return user.IsAdmin()
+}
+
+// authenticatePassword checks a login's password against user accounts and download accounts,
+// or only against download accounts if downloadAccountsOnly is set
+func authenticatePassword(email, password string, downloadAccountsOnly bool) (*auth.AuthResult, error) {
+	if !downloadAccountsOnly {
+		return auth.AuthenticateAnyAccount(email, password)
+	}
+	account, err := auth.AuthenticateDownloadAccount(email, password)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.AuthResult{
+		DownloadAccount: account,
+		AccountType:     auth.AccountTypeDownloadAccount,
+		AccountID:       account.Id,
+		Email:           account.Email,
+	}, nil
+}
+
+// startTOTPVerification sends a user with 2FA enabled to the code prompt and reports whether it
+// did. Trusted devices skip the code until they expire.
+func (s *Server) startTOTPVerification(w http.ResponseWriter, r *http.Request, user *models.User, rememberMe bool, redirect string) bool {
+	if !user.TOTPEnabled {
+		return false
+	}
+	if trustedDeviceFromRequest(r, user.Id) != nil {
+		log.Printf("🔐 2FA skipped for %s: trusted device", user.Email)
+		return false
+	}
+
+	// Keep the pending login on the server; the cookie only holds a random key to it
+	pending := &totpPendingLogin{
+		UserID:     user.Id,
+		RememberMe: rememberMe,
+		Redirect:   s.localRedirectPath(redirect),
+		ExpiresAt:  time.Now().Add(totpPendingTimeout),
+	}
+	pendingKey, err := addTOTPPendingLogin(pending)
+	if err != nil {
+		log.Printf("Error generating 2FA login key: %v", err)
+		s.renderLoginPage(w, r, "Failed to create session")
+		return true
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "totp_pending",
+		Value:    pendingKey,
+		Path:     "/",
+		Expires:  pending.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.Redirect(w, r, "/2fa/verify", http.StatusSeeOther)
+	return true
+}
+
 // handleLogout handles user logout
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	// Try to get user info before deleting session for audit log
@@ -194,10 +290,17 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	// Get session cookie
 	cookie, err := r.Cookie("session")
 	if err == nil {
-		// Try to get user from session before deleting it
+		// Try to get user from session before deleting it. Logging out while impersonating
+		// ends the impersonation and logs the super admin out too.
 		if user, err := auth.GetUserBySession(cookie.Value); err == nil && user != nil {
 			userEmail = user.Email
 			userID = int64(user.Id)
+			if user.ImpersonatedBy != nil {
+				s.logImpersonationEnded(r, user)
+				auth.DeleteSession(auth.GetImpersonatorSession(cookie.Value))
+				userEmail = user.ImpersonatedBy.Email
+				userID = int64(user.ImpersonatedBy.Id)
+			}
 		}
 
 		// Delete session from database
@@ -212,7 +315,7 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 				EntityType: "Session",
 				EntityID:   cookie.Value,
 				Details:    fmt.Sprintf("{\"email\":\"%s\"}", userEmail),
-				IPAddress:  getClientIP(r),
+				IPAddress:  clientIP(r),
 				UserAgent:  r.UserAgent(),
 				Success:    true,
 				ErrorMsg:   "",
@@ -232,22 +335,107 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
+// handleSignOutEverywhere ends all of the user's login sessions, on this and every other
+// device, and returns them to the login page (POST /settings/sign-out-everywhere)
+func (s *Server) handleSignOutEverywhere(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.ImpersonatedBy != nil {
+		http.Error(w, "Can't sign the user out everywhere while viewing the app as them", http.StatusForbidden)
+		return
+	}
+
+	count, err := database.DB.DeleteUserSessions(user.Id)
+	if err != nil {
+		log.Printf("Failed to sign out %s everywhere: %v", user.Email, err)
+		http.Error(w, "Failed to sign out everywhere", http.StatusInternalServerError)
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionLogoutEverywhere,
+		EntityType: database.EntityUser,
+		EntityID:   strconv.Itoa(user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"sessions": count,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+	log.Printf("🔐 %s signed out everywhere (%d sessions ended)", user.Email, count)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// renderLoginLockedOut renders the login page with 429 Too Many Requests and a Retry-After header
+func (s *Server) renderLoginLockedOut(w http.ResponseWriter, r *http.Request, until time.Time) {
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	minutes := (retryAfter + 59) / 60
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	s.renderLoginPage(w, r, fmt.Sprintf("Too many failed login attempts. Please try again in %d minute(s).", minutes))
+}
+
 // renderLoginPage renders the login page
 func (s *Server) renderLoginPage(w http.ResponseWriter, r *http.Request, errorMsg string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	brandingConfig, _ := database.DB.GetBrandingConfig()
+	welcomeText := brandingConfig["branding_login_welcome_text"]
+	if welcomeText == "" {
+		welcomeText = "Secure File Sharing"
+	}
+	ssoURL := s.ssoLoginURL(r.URL.Query().Get("redirect"))
+	ssoLabel := brandingConfig["branding_login_sso_label"]
+	if ssoLabel == "" {
+		ssoLabel = "Sign in with SSO"
+	}
+
+	// The break-glass path always shows the password form, even in SSO-only mode
+	breakGlass := r.URL.Path == "/login/local" || r.FormValue("local") == "1"
+	showPasswordForm := breakGlass || !s.isSSOOnlyMode()
+
+	background := "linear-gradient(135deg, " + s.getPrimaryColor() + " 0%, " + s.getSecondaryColor() + " 100%)"
+	if custom := loginBackgroundCSS(brandingConfig["branding_login_background"]); custom != "" {
+		background = custom
+	}
+
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
     <title>Login - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-            background: linear-gradient(135deg, ` + s.getPrimaryColor() + ` 0%, ` + s.getSecondaryColor() + ` 100%);
+            background: ` + background + `;
             min-height: 100vh;
             display: flex;
             align-items: center;
@@ -335,12 +523,30 @@ func (s *Server) renderLoginPage(w http.ResponseWriter, r *http.Request, errorMs
             color: #999;
             font-size: 12px;
         }
+        .btn-sso {
+            display: block;
+            text-align: center;
+            text-decoration: none;
+            background: #333;
+        }
+        .divider {
+            text-align: center;
+            color: #999;
+            font-size: 13px;
+            margin: 20px 0;
+        }
+        .support {
+            text-align: center;
+            margin-top: 15px;
+            color: #666;
+            font-size: 13px;
+        }
     </style>
     <script>
         // Prevent double form submission and provide feedback
         document.addEventListener('DOMContentLoaded', function() {
             const loginForm = document.querySelector('form');
-            const submitBtn = document.querySelector('.btn');
+            const submitBtn = document.querySelector('form .btn');
             let isSubmitting = false;
 
             if (loginForm && submitBtn) {
@@ -383,8 +589,6 @@ func (s *Server) renderLoginPage(w http.ResponseWriter, r *http.Request, errorMs
     <div class="login-container">
         <div class="logo">`
 
-	// Get branding config for logo
-	brandingConfig, _ := database.DB.GetBrandingConfig()
 	if logoData, ok := brandingConfig["branding_logo"]; ok && logoData != "" {
 		html += `
             <img src="` + logoData + `" alt="` + s.config.CompanyName + `">`
@@ -394,15 +598,46 @@ func (s *Server) renderLoginPage(w http.ResponseWriter, r *http.Request, errorMs
 	}
 
 	html += `
-            <p>Secure File Sharing</p>
+            <p>` + template.HTMLEscapeString(welcomeText) + `</p>
         </div>`
 
 	if errorMsg != "" {
 		html += `<div class="error">` + errorMsg + `</div>`
 	}
 
-	html += `
-        <form method="POST" action="/login">
+	if ssoURL != "" {
+		html += `
+        <a href="` + template.HTMLEscapeString(ssoURL) + `" class="btn btn-sso">` + template.HTMLEscapeString(ssoLabel) + `</a>`
+		if showPasswordForm {
+			html += `
+        <div class="divider">or sign in with your password</div>`
+		}
+	}
+
+	if showPasswordForm {
+		localField := ""
+		if breakGlass {
+			localField = `
+            <input type="hidden" name="local" value="1">`
+		}
+
+		// Keep the page the user was sent here from, e.g. a file link in a notification email
+		formAction := "/login"
+		if redirect := s.localRedirectPath(r.URL.Query().Get("redirect")); redirect != "" {
+			formAction += "?redirect=" + url.QueryEscape(redirect)
+		}
+
+		// "Keep me logged in" is not offered when the remember-me days are set to 0
+		rememberMeHTML := ""
+		if days := database.DB.GetRememberMeDays(); days > 0 {
+			rememberMeHTML = `<div class="form-group" style="display: flex; align-items: center; margin-bottom: 20px;">
+                <input type="checkbox" id="remember_me" name="remember_me" style="width: auto; margin-right: 8px;">
+                <label for="remember_me" style="margin: 0; font-weight: normal; cursor: pointer;">Keep me logged in (` + strconv.Itoa(days) + ` days)</label>
+            </div>`
+		}
+
+		html += `
+        <form method="POST" action="` + template.HTMLEscapeString(formAction) + `">` + localField + `
             <div class="form-group">
                 <label for="email">Email or Username</label>
                 <input type="text" id="email" name="email" required autofocus>
@@ -411,15 +646,20 @@ func (s *Server) renderLoginPage(w http.ResponseWriter, r *http.Request, errorMs
                 <label for="password">Password</label>
                 <input type="password" id="password" name="password" required>
             </div>
-            <div class="form-group" style="display: flex; align-items: center; margin-bottom: 20px;">
-                <input type="checkbox" id="remember_me" name="remember_me" style="width: auto; margin-right: 8px;">
-                <label for="remember_me" style="margin: 0; font-weight: normal; cursor: pointer;">Keep me logged in (30 days)</label>
-            </div>
+            ` + rememberMeHTML + `
             <button type="submit" class="btn">Login</button>
         </form>
         <div style="text-align: center; margin-top: 15px;">
             <a href="/forgot-password" style="color: ` + s.getPrimaryColor() + `; text-decoration: none; font-size: 14px;">Forgot Password?</a>
-        </div>
+        </div>`
+	}
+
+	if supportContact := brandingConfig["branding_login_support_contact"]; supportContact != "" {
+		html += `
+        <div class="support">Need help? ` + template.HTMLEscapeString(supportContact) + `</div>`
+	}
+
+	html += `
         <div class="footer">
             ` + s.config.FooterText + `
         </div>
@@ -429,3 +669,42 @@ func (s *Server) renderLoginPage(w http.ResponseWriter, r *http.Request, errorMs
 
 	w.Write([]byte(html))
 }
+
+// isSSOOnlyMode reports whether the password form is hidden in favor of the SSO button.
+// It only takes effect when an SSO login URL or OIDC is configured, so admins can't lock
+// everyone out.
+func (s *Server) isSSOOnlyMode() bool {
+	ssoOnly, _ := database.DB.GetConfigValue("branding_login_sso_only")
+	if ssoOnly != "true" {
+		return false
+	}
+	return s.ssoLoginURL("") != ""
+}
+
+// ssoLoginURL returns where the SSO button leads: the SSO login URL set under branding, or the
+// built-in OIDC login if OIDC is configured. It returns "" without single sign-on.
+func (s *Server) ssoLoginURL(redirect string) string {
+	if ssoURL, _ := database.DB.GetConfigValue("branding_login_sso_url"); ssoURL != "" {
+		return ssoURL
+	}
+	if s.oidc == nil {
+		return ""
+	}
+	if redirect = s.localRedirectPath(redirect); redirect != "" {
+		return "/auth/oidc/login?redirect=" + url.QueryEscape(redirect)
+	}
+	return "/auth/oidc/login"
+}
+
+// loginBackgroundCSS turns the configured login background (a color or an image URL)
+// into a CSS background value. Anything that could break out of the style block is ignored.
+func loginBackgroundCSS(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.ContainsAny(value, ";{}<>\"'\\()") {
+		return ""
+	}
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "/") {
+		return "url('" + value + "') center / cover no-repeat fixed"
+	}
+	return value
+}
@@ -9,6 +9,7 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -21,22 +22,36 @@ import (
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/storage"
+	"github.com/Frimurare/WulfVault/internal/webhooks"
 )
 
-// ChunkedUpload represents an ongoing chunked upload session
+// ChunkedUpload represents an ongoing chunked upload session. Each chunk is stored as its own
+// part file in Dir, so a chunk the client sends again replaces the earlier attempt instead of
+// being appended twice.
 type ChunkedUpload struct {
 	ID             string
 	UserID         int
 	Filename       string
 	TotalSize      int64
 	ChunksReceived int64
-	File           *os.File
+	ChunkTotal     int64           // number of chunks the client announced, 0 if it didn't
+	Parts          map[int64]int64 // size of each chunk received, by chunk index
+	Dir            string
 	StartTime      time.Time
 	LastActivity   time.Time
 	Metadata       map[string]string
+	UserEmail      string
+	IPAddress      string
+	UserAgent      string
+	cancelled      bool
 	mu             sync.Mutex
 }
 
+// uploadResumeGrace is how long an upload whose client disconnected mid-chunk is kept for the
+// client to retry. The dashboard retries a failed chunk for about 7.5 minutes before giving up.
+const uploadResumeGrace = 10 * time.Minute
+
 var (
 	activeUploads   = make(map[string]*ChunkedUpload)
 	activeUploadsMu sync.RWMutex
@@ -62,20 +77,81 @@ func (s *Server) handleChunkedUploadInit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Generate upload ID
-	uploadID := generateUploadID()
+	// Refuse uploads outside the upload hours. Uploads that already started may finish.
+	if message := uploadWindowClosedMessage(user); message != "" {
+		log.Printf("❌ Upload rejected: '%s' | User: %d (%s) | Reason: Outside upload hours",
+			req.Filename, user.Id, user.Email)
+		http.Error(w, message, http.StatusForbidden)
+		return
+	}
 
-	// Create temp file for upload
-	tempPath := filepath.Join(s.config.UploadsDir, ".chunks", uploadID)
-	if err := os.MkdirAll(filepath.Dir(tempPath), 0755); err != nil {
-		log.Printf("Failed to create chunks directory: %v", err)
-		http.Error(w, "Server error", http.StatusInternalServerError)
+	// Refuse file types the server settings don't allow by name before any data is sent. The
+	// content is checked when the upload completes.
+	if err := checkUploadFileType(req.Filename, nil); err != nil {
+		log.Printf("❌ Upload rejected: '%s' | User: %d (%s) | Reason: %v",
+			req.Filename, user.Id, user.Email, err)
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
 		return
 	}
 
-	file, err := os.Create(tempPath)
-	if err != nil {
-		log.Printf("Failed to create temp file: %v", err)
+	// Refuse invalid or past expiry dates before any data is sent
+	unlimitedTime := req.Metadata["unlimited_time"] == "true"
+	requestedExpireAt, err := requestedFileExpiry(req.Metadata["expire_date"], req.Metadata["expiration_days"])
+	if err != nil && !unlimitedTime {
+		http.Error(w, "Invalid expiration date: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// An activation time must be valid and before the file expires
+	if _, err := checkRequestedActivation(req.Metadata["activate_at"], requestedExpireAt, unlimitedTime); err != nil {
+		http.Error(w, "Invalid activation time: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Refuse invalid or taken link names before any data is sent
+	if _, err := checkRequestedSlug(req.Metadata["slug"], ""); err != nil {
+		http.Error(w, "Invalid link name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if tmpl := req.Metadata["splash_template"]; tmpl != "" && !database.IsValidSplashTemplate(tmpl) {
+		http.Error(w, "Invalid splash page template", http.StatusBadRequest)
+		return
+	}
+
+	// Refuse uploads that don't fit in the storage quota of the uploader, or of a team the
+	// file is shared with, before any data is sent
+	owner := currentUploader(user)
+	if req.TotalSize > maxUploadBytes(owner) {
+		log.Printf("❌ Upload rejected: '%s' (%s) | User: %d (%s) | Reason: Insufficient storage quota (%d MB / %d MB used)",
+			req.Filename, database.FormatFileSize(req.TotalSize), user.Id, user.Email, owner.StorageUsedMB, owner.StorageQuotaMB)
+		s.sendQuotaExceeded(w, owner, req.TotalSize)
+		return
+	}
+	if err := checkUploadTeamQuotas(owner, metadataTeamIDs(req.Metadata), req.TotalSize); err != nil {
+		log.Printf("❌ Upload rejected: '%s' (%s) | User: %d (%s) | Reason: %v",
+			req.Filename, database.FormatFileSize(req.TotalSize), user.Id, user.Email, err)
+		s.sendError(w, http.StatusRequestEntityTooLarge, "Not enough team storage space: "+strings.TrimPrefix(err.Error(), database.ErrTeamQuotaExceeded.Error()+": "))
+		return
+	}
+
+	// Enforce the deployment-wide cap on public (non-auth) links before any data is sent
+	if req.Metadata["require_auth"] != "true" {
+		if reached, limit := publicLinkLimitReached(); reached {
+			log.Printf("❌ Upload rejected: '%s' | User: %d (%s) | Reason: Public link limit reached (%d)",
+				req.Filename, user.Id, user.Email, limit)
+			http.Error(w, publicLinkLimitMessage(limit), http.StatusForbidden)
+			return
+		}
+	}
+
+	// Generate upload ID
+	uploadID := generateUploadID()
+
+	// Create the directory the chunks are stored in until the upload completes
+	chunkDir := filepath.Join(s.config.UploadsDir, ".chunks", uploadID)
+	if err := os.MkdirAll(chunkDir, 0755); err != nil {
+		log.Printf("Failed to create chunks directory: %v", err)
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
@@ -88,10 +164,14 @@ func (s *Server) handleChunkedUploadInit(w http.ResponseWriter, r *http.Request)
 		Filename:       req.Filename,
 		TotalSize:      req.TotalSize,
 		ChunksReceived: 0,
-		File:           file,
+		Parts:          make(map[int64]int64),
+		Dir:            chunkDir,
 		StartTime:      startTime,
 		LastActivity:   startTime,
 		Metadata:       req.Metadata,
+		UserEmail:      user.Email,
+		IPAddress:      clientIP(r),
+		UserAgent:      r.UserAgent(),
 	}
 
 	activeUploadsMu.Lock()
@@ -100,7 +180,7 @@ func (s *Server) handleChunkedUploadInit(w http.ResponseWriter, r *http.Request)
 
 	fileSizeGB := float64(req.TotalSize) / (1024 * 1024 * 1024)
 	log.Printf("📤 UPLOAD STARTED: '%s' | Size: %.2f GB (%d bytes) | Upload ID: %s | User: %d (%s) | IP: %s",
-		req.Filename, fileSizeGB, req.TotalSize, uploadID, user.Id, user.Email, getClientIP(r))
+		req.Filename, fileSizeGB, req.TotalSize, uploadID, user.Id, user.Email, clientIP(r))
 
 	// Return upload ID
 	json.NewEncoder(w).Encode(map[string]string{
@@ -116,20 +196,34 @@ func (s *Server) handleChunkedUploadChunk(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// The chunk index and count are sent as X-Chunk-Index and X-Chunk-Total headers. Older
+	// clients send the index as the chunk_index parameter and no count.
 	uploadID := r.URL.Query().Get("upload_id")
-	chunkIndexStr := r.URL.Query().Get("chunk_index")
+	chunkIndexStr := r.Header.Get("X-Chunk-Index")
+	if chunkIndexStr == "" {
+		chunkIndexStr = r.URL.Query().Get("chunk_index")
+	}
 
 	if uploadID == "" || chunkIndexStr == "" {
-		http.Error(w, "Missing upload_id or chunk_index", http.StatusBadRequest)
+		http.Error(w, "Missing upload_id or chunk index", http.StatusBadRequest)
 		return
 	}
 
 	chunkIndex, err := strconv.ParseInt(chunkIndexStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid chunk_index", http.StatusBadRequest)
+	if err != nil || chunkIndex < 0 {
+		http.Error(w, "Invalid chunk index", http.StatusBadRequest)
 		return
 	}
 
+	chunkTotal := int64(0)
+	if chunkTotalStr := r.Header.Get("X-Chunk-Total"); chunkTotalStr != "" {
+		chunkTotal, err = strconv.ParseInt(chunkTotalStr, 10, 64)
+		if err != nil || chunkTotal <= 0 || chunkIndex >= chunkTotal {
+			http.Error(w, "Invalid chunk total", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Get upload session
 	activeUploadsMu.RLock()
 	upload, exists := activeUploads[uploadID]
@@ -146,27 +240,76 @@ func (s *Server) handleChunkedUploadChunk(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Lock upload for writing
+	// Receive the chunk into a temp file before locking, so a cancel doesn't wait for a slow
+	// chunk. It only becomes the chunk's part file once it has arrived completely.
+	partFile, err := os.CreateTemp(upload.Dir, "receiving-*")
+	if err != nil {
+		log.Printf("Failed to create chunk file: %v", err)
+		http.Error(w, "Failed to write chunk", http.StatusInternalServerError)
+		return
+	}
+	// Stop reading a chunk that would take the upload past the size announced when it started,
+	// which is what its quota was checked against
 	upload.mu.Lock()
-	defer upload.mu.Unlock()
-
-	// Read chunk data
-	chunkData, err := io.ReadAll(r.Body)
+	allowed := upload.TotalSize - upload.ChunksReceived + upload.Parts[chunkIndex]
+	upload.mu.Unlock()
+	n, err := io.Copy(partFile, io.LimitReader(r.Body, allowed+1))
+	partFile.Close()
+	if err == nil && n > allowed {
+		os.Remove(partFile.Name())
+		if abortChunkedUpload(uploadID) != nil {
+			log.Printf("❌ UPLOAD ABORTED: '%s' | Reason: More data than the announced %s | Upload ID: %s | User: %d (%s) | IP: %s",
+				upload.Filename, database.FormatFileSize(upload.TotalSize), uploadID, user.Id, user.Email, clientIP(r))
+			logUploadCancelled(upload, "size_exceeded", clientIP(r), r.UserAgent())
+		}
+		http.Error(w, "Upload is larger than the size announced when it started", http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
+		os.Remove(partFile.Name())
 		log.Printf("Failed to read chunk: %v", err)
+		// The client went away mid-chunk; discard the upload unless it comes back and resumes
+		s.abortUploadIfNotResumed(uploadID, time.Now())
 		http.Error(w, "Failed to read chunk", http.StatusInternalServerError)
 		return
 	}
 
-	// Write chunk to file
-	n, err := upload.File.Write(chunkData)
-	if err != nil {
+	// Lock upload for writing
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	if upload.cancelled {
+		os.Remove(partFile.Name())
+		http.Error(w, "Upload was cancelled", http.StatusGone)
+		return
+	}
+
+	if chunkTotal > 0 {
+		if upload.ChunkTotal != 0 && upload.ChunkTotal != chunkTotal {
+			os.Remove(partFile.Name())
+			http.Error(w, "Chunk total does not match earlier chunks", http.StatusBadRequest)
+			return
+		}
+		upload.ChunkTotal = chunkTotal
+	}
+
+	// Chunks received at the same time may together still exceed the announced size
+	if upload.ChunksReceived+n-upload.Parts[chunkIndex] > upload.TotalSize {
+		os.Remove(partFile.Name())
+		http.Error(w, "Upload is larger than the size announced when it started", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// A chunk sent again replaces the earlier attempt
+	if err := os.Rename(partFile.Name(), upload.partPath(chunkIndex)); err != nil {
+		os.Remove(partFile.Name())
 		log.Printf("Failed to write chunk: %v", err)
 		http.Error(w, "Failed to write chunk", http.StatusInternalServerError)
 		return
 	}
 
-	upload.ChunksReceived += int64(n)
+	upload.ChunksReceived += n - upload.Parts[chunkIndex]
+	upload.Parts[chunkIndex] = n
 	upload.LastActivity = time.Now()
 
 	// Log all chunks to sysmonitor for detailed tracking
@@ -223,38 +366,73 @@ func (s *Server) handleChunkedUploadComplete(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Close temp file
-	upload.File.Close()
-
-	// Move file to final location
-	tempPath := filepath.Join(s.config.UploadsDir, ".chunks", uploadID)
-	finalPath := filepath.Join(s.config.UploadsDir, uploadID)
+	// An expire_date (end of that day) takes precedence over expiration_days (from now)
+	unlimitedTime := upload.Metadata["unlimited_time"] == "true"
+	expireAt := int64(0)
+	expireAtString := ""
+	if !unlimitedTime {
+		var err error
+		expireAt, err = requestedFileExpiry(upload.Metadata["expire_date"], upload.Metadata["expiration_days"])
+		if err != nil {
+			upload.discard()
+			http.Error(w, "Invalid expiration date: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if expireAt > 0 {
+			expireAtString = database.FormatExpireAt(expireAt)
+		}
+	}
 
-	if err := os.Rename(tempPath, finalPath); err != nil {
-		log.Printf("Failed to move file: %v", err)
-		http.Error(w, "Failed to finalize upload", http.StatusInternalServerError)
+	// Check the quota again, as other uploads may have finished while this one was sent
+	owner := currentUploader(user)
+	if upload.TotalSize > maxUploadBytes(owner) {
+		upload.discard()
+		log.Printf("❌ UPLOAD FAILED: '%s' | Reason: Insufficient storage quota (%d MB / %d MB used) | Upload ID: %s | User: %d (%s) | IP: %s",
+			upload.Filename, owner.StorageUsedMB, owner.StorageQuotaMB, uploadID, user.Id, user.Email, clientIP(r))
+		logUploadCancelled(upload, "quota_exceeded", clientIP(r), r.UserAgent())
+		s.sendQuotaExceeded(w, owner, upload.TotalSize)
 		return
 	}
 
-	// Calculate SHA1
-	sha1Hash, err := database.CalculateFileSHA1(finalPath)
-	if err != nil {
-		log.Printf("Failed to calculate SHA1: %v", err)
-		sha1Hash = ""
+	// Check the content of the file against the allowed and blocked file types. A missing
+	// first chunk is reported as an incomplete upload below.
+	if head, err := upload.head(); err == nil {
+		if err := checkUploadFileType(upload.Filename, head); err != nil {
+			upload.discard()
+			log.Printf("❌ UPLOAD FAILED: '%s' | Reason: %v | Upload ID: %s | User: %d (%s) | IP: %s",
+				upload.Filename, err, uploadID, user.Id, user.Email, clientIP(r))
+			logUploadCancelled(upload, "file_type_rejected", clientIP(r), r.UserAgent())
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
 	}
 
-	// Parse metadata
-	expireAt := int64(0)
-	expireAtString := ""
-	if expireDate, ok := upload.Metadata["expire_date"]; ok && expireDate != "" {
-		expireTime, err := time.Parse("2006-01-02", expireDate)
-		if err == nil {
-			expireTime = expireTime.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-			expireAt = expireTime.Unix()
-			expireAtString = expireTime.Format("2006-01-02 15:04")
+	// Join the chunks into the final file. An upload with missing data is rolled back
+	// entirely, so no partial file is ever shared.
+	hasher := newUploadHasher()
+	err := upload.assemble(uploadID, hasher)
+	upload.discard()
+	if err != nil {
+		log.Printf("❌ UPLOAD FAILED: '%s' | %v | Upload ID: %s | User: %d (%s) | IP: %s",
+			upload.Filename, err, uploadID, user.Id, user.Email, clientIP(r))
+		logUploadCancelled(upload, "incomplete", clientIP(r), r.UserAgent())
+		if errors.Is(err, errUploadIncomplete) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to finalize upload", http.StatusInternalServerError)
 		}
+		return
+	}
+
+	sha1Hash := hasher.SHA1()
+	stripMetadata := wantsMetadataStripping(upload.Metadata["strip_metadata"])
+	sha256Hash := hasher.SHA256()
+	if metadataStrippingApplies(upload.Filename, upload.TotalSize, stripMetadata) {
+		sha256Hash = ""
 	}
 
+	// Parse metadata
+
 	downloadsLimit := 10
 	if limitStr, ok := upload.Metadata["downloads_limit"]; ok && limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil {
@@ -263,10 +441,14 @@ func (s *Server) handleChunkedUploadComplete(w http.ResponseWriter, r *http.Requ
 	}
 
 	requireAuth := upload.Metadata["require_auth"] == "true"
-	unlimitedTime := upload.Metadata["unlimited_time"] == "true"
 	unlimitedDownloads := upload.Metadata["unlimited_downloads"] == "true"
 	filePassword := upload.Metadata["file_password"]
 	fileComment := upload.Metadata["file_comment"]
+	// Validated when the upload started; a time that has passed since means right away
+	activateAt, _ := requestedActivation(upload.Metadata["activate_at"])
+
+	// Apply the deployment's maximum file expiry
+	expireAt, expireAtString, unlimitedTime = limitFileExpiry(expireAt, expireAtString, unlimitedTime)
 
 	// Create file entry in database
 	fileInfo := &database.FileInfo{
@@ -274,6 +456,7 @@ func (s *Server) handleChunkedUploadComplete(w http.ResponseWriter, r *http.Requ
 		Name:               upload.Filename,
 		Size:               database.FormatFileSize(upload.TotalSize),
 		SHA1:               sha1Hash,
+		SHA256:             sha256Hash,
 		FilePasswordPlain:  filePassword,
 		ContentType:        upload.Metadata["filetype"],
 		ExpireAtString:     expireAtString,
@@ -287,19 +470,66 @@ func (s *Server) handleChunkedUploadComplete(w http.ResponseWriter, r *http.Requ
 		UnlimitedDownloads: unlimitedDownloads,
 		UnlimitedTime:      unlimitedTime,
 		RequireAuth:        requireAuth,
+		BurnAfterDownload:  upload.Metadata["burn_after_download"] == "true",
+		ActivateAt:         activateAt,
 	}
 
 	if err := database.DB.SaveFile(fileInfo); err != nil {
 		log.Printf("Failed to save file metadata: %v", err)
-		os.Remove(finalPath)
+		storage.Files.Delete(uploadID)
 		http.Error(w, "Failed to save file metadata", http.StatusInternalServerError)
 		return
 	}
 
+	// Uploaders may override the default download log privacy
+	if value := upload.Metadata["private_download_log"]; value != "" {
+		if err := database.DB.SetFilePrivateDownloadLog(uploadID, value == "true"); err != nil {
+			log.Printf("Warning: Could not set download log privacy: %v", err)
+		}
+	}
+
+	// Recipients must accept the download terms first
+	if upload.Metadata["require_terms"] == "true" {
+		if err := database.DB.SetFileTermsRequired(uploadID, true); err != nil {
+			log.Printf("Warning: Could not set download terms requirement: %v", err)
+		}
+	}
+
+	// Checked when the upload started
+	if tmpl := upload.Metadata["splash_template"]; database.IsValidSplashTemplate(tmpl) {
+		if err := database.DB.SetFileSplashTemplate(uploadID, tmpl); err != nil {
+			log.Printf("Warning: Could not set splash page template: %v", err)
+		}
+	}
+
+	// The link name was checked when the upload started, but another file may have taken it since
+	if slug, err := checkRequestedSlug(upload.Metadata["slug"], ""); err != nil {
+		log.Printf("Warning: Could not set link name of %s: %v", uploadID, err)
+	} else if slug != "" {
+		if err := database.DB.SetFileSlug(uploadID, slug); err != nil {
+			log.Printf("Warning: Could not set link name %q of %s: %v", slug, uploadID, err)
+		}
+	}
+
+	// Download emails to the owner are on unless the uploader turned them off
+	if upload.Metadata["notify_on_download"] == "false" {
+		if err := database.DB.SetFileNotifyOnDownload(uploadID, false); err != nil {
+			log.Printf("Warning: Could not turn off download notifications: %v", err)
+		}
+	}
+
+	// Scan for malware in the background; downloads wait for the result
+	s.queueVirusScan(uploadID)
+
+	// Remove image metadata in the background if asked to. The SHA-256 is calculated again afterwards.
+	s.queueMetadataStripping(uploadID, fileInfo.Name, fileInfo.SizeBytes, stripMetadata)
+
+	// Convert HEIC and similar images for preview in the background
+	s.queueImageConversion(uploadID, fileInfo.Name, fileInfo.SizeBytes)
+
 	// Update user storage
-	fileSizeMB := upload.TotalSize / (1024 * 1024)
-	newStorageUsed := user.StorageUsedMB + fileSizeMB
-	if err := database.DB.UpdateUserStorage(user.Id, newStorageUsed); err != nil {
+	fileSizeMB := database.StorageMB(upload.TotalSize)
+	if err := database.DB.AdjustUserStorage(user.Id, fileSizeMB); err != nil {
 		log.Printf("Warning: Could not update user storage: %v", err)
 	}
 
@@ -344,6 +574,13 @@ func (s *Server) handleChunkedUploadComplete(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	// Hold public links until approved, if required
+	pendingApproval, err := s.requestUploadApprovalIfNeeded(user, fileInfo, r)
+	if err != nil {
+		http.Error(w, "The file was uploaded, but "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Log the action
 	database.DB.LogAction(&database.AuditLogEntry{
 		UserID:     int64(user.Id),
@@ -352,11 +589,12 @@ func (s *Server) handleChunkedUploadComplete(w http.ResponseWriter, r *http.Requ
 		EntityType: "File",
 		EntityID:   uploadID,
 		Details:    fmt.Sprintf(`{"file_name":"%s","size":%d,"chunked":true}`, upload.Filename, upload.TotalSize),
-		IPAddress:  r.RemoteAddr,
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
 	})
+	webhooks.Dispatch(database.WebhookEventFileUploaded, uploadID, upload.Filename, user.Email, clientIP(r))
 
 	// Send email notification for large files (>5GB)
 	fileSizeGB := float64(upload.TotalSize) / (1024 * 1024 * 1024)
@@ -369,12 +607,233 @@ func (s *Server) handleChunkedUploadComplete(w http.ResponseWriter, r *http.Requ
 	avgSpeed := float64(upload.TotalSize) / totalDuration.Seconds() / (1024 * 1024) // MB/s
 
 	log.Printf("✅ UPLOAD COMPLETED: '%s' | Size: %s | Duration: %v | Avg Speed: %.2f MB/s | Upload ID: %s | User: %d (%s) | IP: %s",
-		upload.Filename, database.FormatFileSize(upload.TotalSize), totalDuration.Round(time.Second), avgSpeed, uploadID, user.Id, user.Email, getClientIP(r))
+		upload.Filename, database.FormatFileSize(upload.TotalSize), totalDuration.Round(time.Second), avgSpeed, uploadID, user.Id, user.Email, clientIP(r))
 
 	// Return success
+	quota := storageQuotaOf(currentUploader(user))
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"file_id": uploadID,
+		"success":              true,
+		"file_id":              uploadID,
+		"pending_approval":     pendingApproval,
+		"storage_used_mb":      quota.UsedMB,
+		"storage_quota_mb":     quota.QuotaMB,
+		"storage_remaining_mb": quota.RemainingMB,
+	})
+}
+
+// handleChunkedUploadCancel aborts an upload in progress and removes the data received so far
+func (s *Server) handleChunkedUploadCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uploadID := r.URL.Query().Get("upload_id")
+	if uploadID == "" {
+		http.Error(w, "Missing upload_id", http.StatusBadRequest)
+		return
+	}
+
+	activeUploadsMu.RLock()
+	upload, exists := activeUploads[uploadID]
+	activeUploadsMu.RUnlock()
+
+	if !exists {
+		http.Error(w, "Upload session not found", http.StatusNotFound)
+		return
+	}
+
+	// Verify user owns this upload
+	if upload.UserID != user.Id {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	// The upload may have completed in the meantime
+	if abortChunkedUpload(uploadID) == nil {
+		http.Error(w, "Upload session not found", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("🛑 UPLOAD CANCELLED: '%s' | Discarded: %s of %s | Upload ID: %s | User: %d (%s) | IP: %s",
+		upload.Filename, database.FormatFileSize(upload.ChunksReceived), database.FormatFileSize(upload.TotalSize),
+		uploadID, user.Id, user.Email, clientIP(r))
+	logUploadCancelled(upload, "cancelled", clientIP(r), r.UserAgent())
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"bytes_discarded": upload.ChunksReceived,
+	})
+}
+
+// metadataTeamIDs returns the teams an upload's metadata asks to share it with, as a
+// comma-separated team_ids list. Invalid entries are left out.
+func metadataTeamIDs(metadata map[string]string) []int {
+	var teamIds []int
+	for _, teamIdStr := range strings.Split(metadata["team_ids"], ",") {
+		if teamId, err := strconv.Atoi(strings.TrimSpace(teamIdStr)); err == nil && teamId > 0 {
+			teamIds = append(teamIds, teamId)
+		}
+	}
+	return teamIds
+}
+
+// abortChunkedUpload ends an upload session that will not be completed and deletes its
+// partial data. Returns nil if there is no such session (e.g. it already completed).
+func abortChunkedUpload(uploadID string) *ChunkedUpload {
+	activeUploadsMu.Lock()
+	upload, exists := activeUploads[uploadID]
+	if exists {
+		delete(activeUploads, uploadID)
+	}
+	activeUploadsMu.Unlock()
+
+	if !exists {
+		return nil
+	}
+	upload.discard()
+	return upload
+}
+
+// discard deletes the upload's chunks. A chunk arriving afterwards is refused.
+func (upload *ChunkedUpload) discard() {
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	upload.cancelled = true
+	if err := os.RemoveAll(upload.Dir); err != nil {
+		log.Printf("⚠️  Failed to remove partial upload %s: %v", upload.ID, err)
+	}
+}
+
+// errUploadIncomplete is returned when an upload is completed before all its data arrived
+var errUploadIncomplete = errors.New("upload incomplete")
+
+// partPath returns the path a chunk is stored at until the upload completes
+func (upload *ChunkedUpload) partPath(chunkIndex int64) string {
+	return filepath.Join(upload.Dir, strconv.FormatInt(chunkIndex, 10))
+}
+
+// assemble checks that every chunk arrived and that together they are the size announced when
+// the upload started, then stores them joined as the file fileID, hashing it on the way
+func (upload *ChunkedUpload) assemble(fileID string, hasher *uploadHasher) error {
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	if upload.cancelled {
+		return fmt.Errorf("%w: the upload was cancelled", errUploadIncomplete)
+	}
+	chunkCount := int64(len(upload.Parts))
+	if upload.ChunkTotal > 0 && chunkCount != upload.ChunkTotal {
+		return fmt.Errorf("%w: received %d of %d chunks", errUploadIncomplete, chunkCount, upload.ChunkTotal)
+	}
+	for i := int64(0); i < chunkCount; i++ {
+		if _, ok := upload.Parts[i]; !ok {
+			return fmt.Errorf("%w: chunk %d is missing", errUploadIncomplete, i)
+		}
+	}
+	if upload.ChunksReceived != upload.TotalSize {
+		return fmt.Errorf("%w: received %d of %d bytes", errUploadIncomplete, upload.ChunksReceived, upload.TotalSize)
+	}
+
+	parts := &partsReader{upload: upload, count: chunkCount}
+	defer parts.Close()
+	return storage.Files.Put(fileID, hasher.tee(parts))
+}
+
+// partsReader reads the parts of an upload one after another, opening each when it is reached
+type partsReader struct {
+	upload  *ChunkedUpload
+	count   int64
+	next    int64
+	current *os.File
+}
+
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.current == nil {
+			if p.next >= p.count {
+				return 0, io.EOF
+			}
+			f, err := os.Open(p.upload.partPath(p.next))
+			if err != nil {
+				return 0, err
+			}
+			p.current = f
+			p.next++
+		}
+
+		n, err := p.current.Read(b)
+		if err == io.EOF {
+			p.current.Close()
+			p.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the part being read, if any
+func (p *partsReader) Close() error {
+	if p.current == nil {
+		return nil
+	}
+	err := p.current.Close()
+	p.current = nil
+	return err
+}
+
+// abortUploadIfNotResumed discards an upload whose client disconnected at disconnectedAt,
+// unless a chunk arrives within uploadResumeGrace
+func (s *Server) abortUploadIfNotResumed(uploadID string, disconnectedAt time.Time) {
+	time.AfterFunc(uploadResumeGrace, func() {
+		activeUploadsMu.RLock()
+		upload, exists := activeUploads[uploadID]
+		activeUploadsMu.RUnlock()
+		if !exists {
+			return
+		}
+
+		upload.mu.Lock()
+		resumed := upload.LastActivity.After(disconnectedAt)
+		upload.mu.Unlock()
+		if resumed || abortChunkedUpload(uploadID) == nil {
+			return
+		}
+
+		log.Printf("🛑 UPLOAD DISCONNECTED: '%s' | Discarded: %s of %s | Client did not resume within %v | Upload ID: %s | User: %d (%s)",
+			upload.Filename, database.FormatFileSize(upload.ChunksReceived), database.FormatFileSize(upload.TotalSize),
+			uploadResumeGrace, uploadID, upload.UserID, upload.UserEmail)
+		logUploadCancelled(upload, "client_disconnected", upload.IPAddress, upload.UserAgent)
+	})
+}
+
+// logUploadCancelled records a discarded upload in the audit log
+func logUploadCancelled(upload *ChunkedUpload, reason, ipAddress, userAgent string) {
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(upload.UserID),
+		UserEmail:  upload.UserEmail,
+		Action:     database.ActionFileUploadCancelled,
+		EntityType: database.EntityFile,
+		EntityID:   upload.ID,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":      upload.Filename,
+			"reason":         reason,
+			"bytes_received": upload.ChunksReceived,
+			"total_size":     upload.TotalSize,
+		}),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Success:   true,
 	})
 }
 
@@ -394,12 +853,11 @@ func cleanupStaleUploads() {
 		activeUploadsMu.Lock()
 		for id, upload := range activeUploads {
 			inactiveTime := time.Since(upload.LastActivity)
-			if inactiveTime > 1*time.Hour {
+			if inactiveTime > orphanedChunkMaxAge {
 				percentComplete := float64(upload.ChunksReceived) / float64(upload.TotalSize) * 100
 				totalTime := time.Since(upload.StartTime)
 
-				upload.File.Close()
-				os.Remove(filepath.Join(upload.File.Name()))
+				upload.discard()
 				delete(activeUploads, id)
 
 				log.Printf("🧹 UPLOAD ABANDONED: '%s' | Progress: %.1f%% (%s of %s) | Inactive: %v | Total time: %v | Upload ID: %s",
@@ -412,7 +870,12 @@ func cleanupStaleUploads() {
 	}
 }
 
-// CleanupOrphanedChunks removes chunk files left behind from server restarts
+// orphanedChunkMaxAge is how long the chunks of an upload that was never finished are kept after
+// the last chunk arrived, so the client can resume it
+const orphanedChunkMaxAge = 24 * time.Hour
+
+// CleanupOrphanedChunks removes chunks of uploads that were left behind by server restarts or
+// abandoned by their client
 func CleanupOrphanedChunks(uploadsDir string) {
 	chunksDir := filepath.Join(uploadsDir, ".chunks")
 
@@ -432,7 +895,11 @@ func CleanupOrphanedChunks(uploadsDir string) {
 	cleanedSize := int64(0)
 
 	for _, file := range files {
-		if file.IsDir() {
+		// Uploads still in progress are cleaned up when their session expires
+		activeUploadsMu.RLock()
+		_, active := activeUploads[file.Name()]
+		activeUploadsMu.RUnlock()
+		if active {
 			continue
 		}
 
@@ -442,10 +909,14 @@ func CleanupOrphanedChunks(uploadsDir string) {
 			continue
 		}
 
-		// Remove chunks older than 2 hours (orphaned from crashes/restarts)
-		if now.Sub(info.ModTime()) > 2*time.Hour {
+		// Remove chunks that haven't been added to within the resume window. A directory's
+		// modification time is when its last chunk arrived.
+		if now.Sub(info.ModTime()) > orphanedChunkMaxAge {
 			size := info.Size()
-			if err := os.Remove(filePath); err != nil {
+			if info.IsDir() {
+				size = directorySize(filePath)
+			}
+			if err := os.RemoveAll(filePath); err != nil {
 				log.Printf("⚠️  Failed to remove orphaned chunk %s: %v", file.Name(), err)
 			} else {
 				cleanedCount++
@@ -462,6 +933,21 @@ func CleanupOrphanedChunks(uploadsDir string) {
 	}
 }
 
+// directorySize returns the total size of the files in a directory
+func directorySize(dir string) int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	size := int64(0)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
 func init() {
 	// Start cleanup goroutine
 	go cleanupStaleUploads()
@@ -0,0 +1,322 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// termsAcceptedCookiePrefix is followed by the file ID. The cookie holds "version:acceptedAt".
+const termsAcceptedCookiePrefix = "terms_accepted_"
+
+// termsAcceptance is the terms version a downloader accepted and when
+type termsAcceptance struct {
+	Version    int
+	AcceptedAt int64
+}
+
+// requiredDownloadTerms returns the terms that must be accepted before downloading the file,
+// or nil if the file doesn't require terms or none have been published
+func requiredDownloadTerms(fileInfo *database.FileInfo) *database.DownloadTerms {
+	if !database.DB.IsFileTermsRequired(fileInfo.Id) {
+		return nil
+	}
+	terms, err := database.DB.GetCurrentDownloadTerms()
+	if err != nil {
+		log.Printf("Warning: Could not load download terms: %v", err)
+		return nil
+	}
+	return terms
+}
+
+// termsAcceptanceFromRequest returns the downloader's acceptance of the file's current terms.
+// Acceptance comes from the splash page (?accept_terms=<version>) or the cookie it leaves behind.
+func termsAcceptanceFromRequest(r *http.Request, fileInfo *database.FileInfo) (termsAcceptance, bool) {
+	terms := requiredDownloadTerms(fileInfo)
+	if terms == nil {
+		return termsAcceptance{}, false
+	}
+
+	if version, err := strconv.Atoi(r.URL.Query().Get("accept_terms")); err == nil && version == terms.Version {
+		return termsAcceptance{Version: version, AcceptedAt: time.Now().Unix()}, true
+	}
+
+	cookie, err := r.Cookie(termsAcceptedCookiePrefix + fileInfo.Id)
+	if err != nil {
+		return termsAcceptance{}, false
+	}
+	versionStr, acceptedAtStr, _ := strings.Cut(cookie.Value, ":")
+	version, err := strconv.Atoi(versionStr)
+	if err != nil || version != terms.Version {
+		return termsAcceptance{}, false
+	}
+	acceptedAt, _ := strconv.ParseInt(acceptedAtStr, 10, 64)
+	return termsAcceptance{Version: version, AcceptedAt: acceptedAt}, true
+}
+
+// checkDownloadTerms makes sure the current terms were accepted before a download of a file that
+// requires them. Without acceptance the recipient is sent back to the splash page, which shows the terms.
+func (s *Server) checkDownloadTerms(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo) bool {
+	if requiredDownloadTerms(fileInfo) == nil {
+		return true
+	}
+
+	acceptance, ok := termsAcceptanceFromRequest(r, fileInfo)
+	if !ok {
+		http.Redirect(w, r, "/s/"+fileInfo.Id, http.StatusSeeOther)
+		return false
+	}
+
+	// Remember the acceptance for the password and login steps that may follow
+	if r.URL.Query().Get("accept_terms") != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     termsAcceptedCookiePrefix + fileInfo.Id,
+			Value:    fmt.Sprintf("%d:%d", acceptance.Version, acceptance.AcceptedAt),
+			Path:     "/d/" + fileInfo.Id,
+			Expires:  time.Now().Add(24 * time.Hour),
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return true
+}
+
+// renderDownloadTermsSection renders the terms and the acceptance checkbox for the splash page
+func renderDownloadTermsSection(terms *database.DownloadTerms, title, acceptLabel, primaryColor string) string {
+	return `
+        <div style="margin: 25px 0; padding: 20px; background: #f9f9f9; border-left: 4px solid ` + primaryColor + `; border-radius: 8px; text-align: left;">
+            <h3 style="color: ` + primaryColor + `; font-size: 16px; margin-bottom: 10px;">📜 ` + title + `</h3>
+            <div style="max-height: 220px; overflow-y: auto; padding: 12px; background: white; border: 1px solid #e0e0e0; border-radius: 6px; color: #555; font-size: 14px; line-height: 1.6; white-space: pre-wrap;">` + template.HTMLEscapeString(terms.Content) + `</div>
+            <label style="display: flex; align-items: center; gap: 8px; margin-top: 12px; color: #333; font-size: 14px; cursor: pointer;">
+                <input type="checkbox" id="acceptTerms" data-version="` + strconv.Itoa(terms.Version) + `" style="width: 18px; height: 18px;">
+                <span>` + fmt.Sprintf(acceptLabel, terms.Version) + `</span>
+            </label>
+        </div>`
+}
+
+// handleAdminDownloadTerms shows the published terms versions (GET) or publishes a new version (POST)
+func (s *Server) handleAdminDownloadTerms(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.renderAdminDownloadTerms(w, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.renderAdminDownloadTerms(w, "Error: Invalid form data")
+		return
+	}
+
+	previous, _ := database.DB.GetCurrentDownloadTerms()
+	terms, err := database.DB.PublishDownloadTerms(r.FormValue("content"), admin.Email)
+	if err != nil {
+		s.renderAdminDownloadTerms(w, "Error: "+err.Error())
+		return
+	}
+
+	if previous != nil && previous.Version == terms.Version {
+		s.renderAdminDownloadTerms(w, "The terms are unchanged, so no new version was published")
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionDownloadTermsPublished,
+		EntityType: database.EntitySettings,
+		EntityID:   strconv.Itoa(terms.Version),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"version": terms.Version,
+			"length":  len(terms.Content),
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.renderAdminDownloadTerms(w, fmt.Sprintf("Published terms version %d. Files that require terms now ask for this version", terms.Version))
+}
+
+// renderAdminDownloadTerms renders the download terms editor and version history
+func (s *Server) renderAdminDownloadTerms(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	versions, err := database.DB.GetDownloadTermsVersions()
+	if err != nil {
+		log.Printf("Failed to get download terms: %v", err)
+	}
+
+	currentContent := ""
+	if len(versions) > 0 {
+		currentContent = versions[0].Content
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Download Terms - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        textarea {
+            width: 100%;
+            padding: 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+            font-family: inherit;
+            line-height: 1.5;
+            resize: vertical;
+        }
+        .btn {
+            margin-top: 12px;
+            padding: 10px 20px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+        }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        details {
+            border-bottom: 1px solid #eee;
+            padding: 12px 0;
+        }
+        summary {
+            cursor: pointer;
+            font-size: 14px;
+            color: #333;
+        }
+        .terms-text {
+            margin-top: 10px;
+            padding: 12px;
+            background: #f9f9f9;
+            border-radius: 6px;
+            font-size: 13px;
+            color: #555;
+            white-space: pre-wrap;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <h2>📜 Download Terms</h2>
+
+        <div class="info-box">
+            Files with "Require acceptance of download terms" show these terms on their splash page, and recipients must accept the current version before downloading. The download log records which version each downloader accepted and when. Publishing a change creates a new version; earlier acceptances keep referring to the version that was accepted.
+        </div>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	html += `
+        <div class="card">
+            <h3>Current Terms</h3>
+            <form method="POST">
+                <textarea name="content" rows="12" placeholder="By downloading this file you agree to..." required>` + template.HTMLEscapeString(currentContent) + `</textarea>
+                <button type="submit" class="btn">Publish New Version</button>
+            </form>
+        </div>
+
+        <div class="card">
+            <h3>Version History</h3>`
+
+	if len(versions) == 0 {
+		html += `
+            <p style="color: #999;">No terms have been published yet.</p>`
+	}
+
+	for _, terms := range versions {
+		createdBy := ""
+		if terms.CreatedBy != "" {
+			createdBy = " by " + template.HTMLEscapeString(terms.CreatedBy)
+		}
+		html += fmt.Sprintf(`
+            <details>
+                <summary><strong>Version %d</strong> - published %s%s - accepted in %d downloads</summary>
+                <div class="terms-text">%s</div>
+            </details>`, terms.Version, time.Unix(terms.CreatedAt, 0).Format("2006-01-02 15:04"), createdBy, terms.Acceptances, template.HTMLEscapeString(terms.Content))
+	}
+
+	html += `
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
@@ -117,8 +117,8 @@ func (s *Server) handleDownloadChangePassword(w http.ResponseWriter, r *http.Req
 	}
 
 	// Validate new password
-	if newPassword == "" || len(newPassword) < 6 {
-		s.renderDownloadChangePasswordPage(w, account, "New password must be at least 6 characters")
+	if err := auth.ValidatePasswordStrength(newPassword); err != nil {
+		s.renderDownloadChangePasswordPage(w, account, err.Error())
 		return
 	}
 
@@ -199,7 +199,7 @@ func (s *Server) handleDownloadAccountDeleteSelf(w http.ResponseWriter, r *http.
 		EntityType: "DownloadAccount",
 		EntityID:   fmt.Sprintf("%d", account.Id),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"soft_delete\":true,\"self_deleted\":true}", account.Email, account.Name),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -223,7 +223,7 @@ func (s *Server) renderDownloadDashboard(w http.ResponseWriter, account *models.
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -480,7 +480,7 @@ func (s *Server) renderDownloadChangePasswordPage(w http.ResponseWriter, account
 	}
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -562,11 +562,12 @@ func (s *Server) renderDownloadChangePasswordPage(w http.ResponseWriter, account
                 </div>
                 <div class="form-group">
                     <label>New Password</label>
-                    <input type="password" name="new_password" required minlength="6">
+                    <input type="password" name="new_password" required minlength="` + strconv.Itoa(auth.PasswordMinLength()) + `">
+                    <p style="font-size: 12px; color: #999; margin-top: 4px;">` + auth.PasswordRequirements() + `</p>
                 </div>
                 <div class="form-group">
                     <label>Confirm New Password</label>
-                    <input type="password" name="confirm_password" required minlength="6">
+                    <input type="password" name="confirm_password" required minlength="` + strconv.Itoa(auth.PasswordMinLength()) + `">
                 </div>
                 <button type="submit" class="btn btn-primary">Change Password</button>
             </form>
@@ -9,14 +9,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"net/mail"
 	"strings"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
 	"github.com/Frimurare/WulfVault/internal/email"
-	"github.com/Frimurare/WulfVault/internal/models"
 )
 
 // EmailConfigRequest represents a request for email configuration
@@ -171,15 +172,12 @@ func (s *Server) handleEmailConfigure(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Deactivate all other providers
-	result, err := database.DB.Exec("UPDATE EmailProviderConfig SET IsActive = 0")
-	if err != nil {
-		log.Printf("Failed to deactivate providers: %v", err)
-		s.sendError(w, http.StatusInternalServerError, "Database error")
-		return
-	}
-	rowsAffected, _ := result.RowsAffected()
-	log.Printf("Deactivated %d existing provider(s)", rowsAffected)
+	// Saving enables the provider. The other providers stay enabled as fallbacks, and a newly
+	// configured provider is added at the end of the delivery order.
+	var result sql.Result
+	var existingCount int
+	database.DB.QueryRow("SELECT COUNT(*) FROM EmailProviderConfig WHERE Provider = ?", req.Provider).Scan(&existingCount)
+	isNewProvider := existingCount == 0
 
 	// Save or update configuration
 	now := time.Now().Unix()
@@ -382,6 +380,14 @@ func (s *Server) handleEmailConfigure(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isNewProvider {
+		if err := database.DB.AppendEmailProvider(req.Provider); err != nil {
+			log.Printf("Failed to place %s in the email delivery order: %v", req.Provider, err)
+		}
+	}
+	// New settings get a fresh chance if the old ones made the provider fail
+	email.ResetProviderHealth(req.Provider)
+
 	// Verify the configuration was saved by querying it back
 	var verifyId int
 	var verifyActive int
@@ -407,7 +413,7 @@ func (s *Server) handleEmailConfigure(w http.ResponseWriter, r *http.Request) {
 		EntityType: "Settings",
 		EntityID:   "email",
 		Details:    fmt.Sprintf("{\"provider\":\"%s\",\"from_email\":\"%s\"}", req.Provider, req.FromEmail),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -416,7 +422,7 @@ func (s *Server) handleEmailConfigure(w http.ResponseWriter, r *http.Request) {
 	s.sendJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
-// handleEmailActivate activates a specific email provider
+// handleEmailActivate enables a provider and makes it the first in the delivery order
 func (s *Server) handleEmailActivate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -445,27 +451,18 @@ func (s *Server) handleEmailActivate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Deactivate all providers
-	_, err = database.DB.Exec("UPDATE EmailProviderConfig SET IsActive = 0")
-	if err != nil {
-		log.Printf("Failed to deactivate providers: %v", err)
-		s.sendError(w, http.StatusInternalServerError, "Database error")
-		return
-	}
-
-	// Activate the selected provider
-	result, err := database.DB.Exec("UPDATE EmailProviderConfig SET IsActive = 1 WHERE Provider = ?", req.Provider)
+	// Enable the provider and send through it first; the others stay enabled as fallbacks
+	activated, err := database.DB.MakeEmailProviderPrimary(req.Provider)
 	if err != nil {
 		log.Printf("Failed to activate provider %s: %v", req.Provider, err)
 		s.sendError(w, http.StatusInternalServerError, "Failed to activate provider")
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !activated {
 		s.sendError(w, http.StatusInternalServerError, "Provider activation failed")
 		return
 	}
+	email.ResetProviderHealth(req.Provider)
 
 	log.Printf("✅ Email provider activated: %s", req.Provider)
 
@@ -478,7 +475,7 @@ func (s *Server) handleEmailActivate(w http.ResponseWriter, r *http.Request) {
 		EntityType: "Settings",
 		EntityID:   "email",
 		Details:    fmt.Sprintf("{\"provider\":\"%s\"}", req.Provider),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -487,10 +484,11 @@ func (s *Server) handleEmailActivate(w http.ResponseWriter, r *http.Request) {
 	s.sendJSON(w, http.StatusOK, map[string]string{"status": "success", "provider": req.Provider})
 }
 
-// handleEmailTest tests the email configuration
-// EmailTestRequest represents a request to test email settings
+// EmailTestRequest represents a request to test email settings. Without an API key or SMTP
+// password, the saved settings of the provider are tested.
 type EmailTestRequest struct {
 	Provider      string `json:"provider"`
+	To            string `json:"to"` // defaults to the admin's own address
 	ApiKey        string `json:"apiKey"`
 	FromEmail     string `json:"fromEmail"`
 	FromName      string `json:"fromName"`
@@ -503,6 +501,9 @@ type EmailTestRequest struct {
 	MailgunRegion string `json:"mailgunRegion"`
 }
 
+// handleEmailTest sends a test email with the provider settings in the request (POST) or the
+// saved settings of ?provider=, or of the active provider (GET). SMTP settings are checked by
+// connecting and authenticating first. Failures return the provider's error.
 func (s *Server) handleEmailTest(w http.ResponseWriter, r *http.Request) {
 	// Accept both GET and POST
 	if r.Method != http.MethodGet && r.Method != http.MethodPost {
@@ -517,18 +518,31 @@ func (s *Server) handleEmailTest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var provider email.EmailProvider
-	var err error
-
+	var req EmailTestRequest
 	if r.Method == http.MethodPost {
-		// Test with provided settings (without saving)
-		var req EmailTestRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			s.sendError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
+	} else {
+		req.Provider = r.URL.Query().Get("provider")
+		req.To = r.URL.Query().Get("to")
+	}
+
+	req.To = strings.TrimSpace(req.To)
+	if req.To == "" {
+		req.To = user.Email
+	}
+	if addr, err := mail.ParseAddress(req.To); err != nil || addr.Address != req.To {
+		s.sendError(w, http.StatusBadRequest, "Invalid recipient email address")
+		return
+	}
+
+	var provider email.EmailProvider
+	var err error
 
-		// Create temporary provider for testing
+	if r.Method == http.MethodPost && (req.ApiKey != "" || req.SMTPPassword != "") {
+		// Test with provided settings (without saving)
 		switch req.Provider {
 		case "brevo":
 			if req.ApiKey == "" {
@@ -559,7 +573,7 @@ func (s *Server) handleEmailTest(w http.ResponseWriter, r *http.Request) {
 			if region == "" {
 				region = "us"
 			}
-			provider = email.NewMailgunProvider(req.ApiKey, req.MailgunDomain, region, req.FromEmail, req.FromName)
+			provider = email.NewMailgunProvider(req.ApiKey, req.MailgunDomain, req.FromEmail, req.FromName, region)
 			log.Printf("Testing Mailgun with domain: %s, region: %s", req.MailgunDomain, region)
 
 		case "sendgrid":
@@ -601,32 +615,64 @@ func (s *Server) handleEmailTest(w http.ResponseWriter, r *http.Request) {
 			s.sendError(w, http.StatusBadRequest, "Invalid provider")
 			return
 		}
+	} else if req.Provider != "" {
+		// Saved configuration of the provider, active or not
+		provider, err = email.GetSavedProvider(database.DB, req.Provider)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 	} else {
-		// GET method - use saved configuration
+		// Saved configuration of the active provider
 		provider, err = email.GetActiveProvider(database.DB)
 		if err != nil {
 			log.Printf("No email provider configured: %v", err)
 			s.sendError(w, http.StatusBadRequest, "No email provider configured")
 			return
 		}
+		req.Provider = "active"
 	}
 
-	// Send test email
-	err = provider.SendEmail(
-		user.Email,
-		"WulfVault Email Test",
-		"<h1>Test successful!</h1><p>Your email configuration is working correctly.</p>",
-		"Test successful! Your email configuration is working correctly.",
-	)
+	// Catch wrong hosts, ports, TLS settings and credentials before trying to send
+	if smtpProvider, ok := provider.(*email.SMTPProvider); ok {
+		err = smtpProvider.Verify()
+	}
+	if err == nil {
+		err = provider.SendEmail(
+			req.To,
+			"WulfVault Email Test",
+			"<h1>Test successful!</h1><p>Your email configuration is working correctly.</p>",
+			"Test successful! Your email configuration is working correctly.",
+		)
+	}
+
+	entry := &database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionEmailTestSent,
+		EntityType: database.EntitySettings,
+		EntityID:   "email",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"provider":  req.Provider,
+			"recipient": req.To,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   err == nil,
+	}
+	if err != nil {
+		entry.ErrorMsg = err.Error()
+	}
+	database.DB.LogAction(entry)
 
 	if err != nil {
-		log.Printf("Email test failed: %v", err)
-		s.sendError(w, http.StatusInternalServerError, "Test failed: "+err.Error())
+		log.Printf("Email test with %s failed: %v", req.Provider, err)
+		s.sendError(w, http.StatusBadGateway, "Test failed: "+err.Error())
 		return
 	}
 
-	log.Printf("Test email sent to: %s", user.Email)
-	s.sendJSON(w, http.StatusOK, map[string]string{"status": "success", "message": "Test email sent successfully!"})
+	log.Printf("Test email sent to: %s", req.To)
+	s.sendJSON(w, http.StatusOK, map[string]string{"status": "success", "message": "Test email sent to " + req.To + ". Check the inbox (and spam folder) to confirm delivery."})
 }
 
 func min(a, b int) int {
@@ -670,7 +716,11 @@ func (s *Server) handleSendSplashLink(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user from context
-	user := r.Context().Value("user").(*models.User)
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 
 	// Get file
 	fileInfo, err := database.DB.GetFileByID(req.FileId)
@@ -688,7 +738,12 @@ func (s *Server) handleSendSplashLink(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate splash link
-	splashLink := s.getPublicURL() + "/s/" + fileInfo.Id
+	splashLink, err := email.ShareLinkURL(s.getPublicURL(), fileInfo.Id, req.Email)
+	if err != nil {
+		log.Printf("Failed to create splash link: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to create link: "+err.Error())
+		return
+	}
 
 	// Send email
 	err = email.SendSplashLinkEmail(req.Email, splashLink, fileInfo, req.Message)
@@ -737,12 +792,18 @@ func (s *Server) handleEmailSettings(w http.ResponseWriter, r *http.Request) {
 	err = row.Scan(&resendFromEmail, &resendFromName, &isResendActive)
 	resendConfigured = (err == nil && resendFromEmail != "")
 
+	// Test emails go to the admin unless another address is entered
+	var testRecipient string
+	if user, ok := userFromContext(r.Context()); ok {
+		testRecipient = user.Email
+	}
+
 	// Render page
-	s.renderEmailSettingsPage(w, brevoConfigured, smtpConfigured, mailgunConfigured, sendgridConfigured, resendConfigured, isBrevoActive, isSMTPActive, isMailgunActive, isSendGridActive, isResendActive, brevoFromEmail, smtpFromEmail, mailgunFromEmail, sendgridFromEmail, resendFromEmail, brevoFromName, smtpFromName, mailgunFromName, sendgridFromName, resendFromName)
+	s.renderEmailSettingsPage(w, brevoConfigured, smtpConfigured, mailgunConfigured, sendgridConfigured, resendConfigured, isBrevoActive, isSMTPActive, isMailgunActive, isSendGridActive, isResendActive, brevoFromEmail, smtpFromEmail, mailgunFromEmail, sendgridFromEmail, resendFromEmail, brevoFromName, smtpFromName, mailgunFromName, sendgridFromName, resendFromName, testRecipient)
 }
 
 // renderEmailSettingsPage renders the email settings page
-func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured, smtpConfigured, mailgunConfigured, sendgridConfigured, resendConfigured, isBrevoActive, isSMTPActive, isMailgunActive, isSendGridActive, isResendActive bool, brevoFromEmail, smtpFromEmail, mailgunFromEmail, sendgridFromEmail, resendFromEmail, brevoFromName, smtpFromName, mailgunFromName, sendgridFromName, resendFromName string) {
+func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured, smtpConfigured, mailgunConfigured, sendgridConfigured, resendConfigured, isBrevoActive, isSMTPActive, isMailgunActive, isSendGridActive, isResendActive bool, brevoFromEmail, smtpFromEmail, mailgunFromEmail, sendgridFromEmail, resendFromEmail, brevoFromName, smtpFromName, mailgunFromName, sendgridFromName, resendFromName, testRecipient string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	activeTab := "resend"
@@ -819,7 +880,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
 	}
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -1144,7 +1205,13 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
             <div id="success-message" class="success-message"></div>
             <div id="error-message" class="error-message"></div>
 
-            ` + getActiveProviderBanner(isBrevoActive, isSMTPActive, isMailgunActive, isSendGridActive, isResendActive) + `
+            <div class="form-group">
+                <label for="test-recipient">Send test emails to</label>
+                <input type="email" id="test-recipient" value="` + template.HTMLEscapeString(testRecipient) + `" placeholder="admin@example.com">
+                <small>Test connection checks the provider's settings (for SMTP: the connection, TLS and login) and sends a test email to this address.</small>
+            </div>
+
+            ` + getProviderChainHTML() + `
 
         <div class="tab-buttons">
             <button class="tab-btn ` + activeTabClass("resend", activeTab) + `" data-provider="resend">
@@ -1601,6 +1668,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                         credentials: 'same-origin',
                         body: JSON.stringify({
                             provider: 'brevo',
+                            to: testRecipient(),
                             apiKey: apiKey,
                             fromEmail: fromEmail,
                             fromName: fromName
@@ -1610,7 +1678,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                 }
                 // If API key field is empty but placeholder shows saved (bullets), test saved config
                 else if (apiKeyPlaceholder && apiKeyPlaceholder.includes('•')) {
-                    response = await fetch('/api/email/test', {
+                    response = await fetch('/api/email/test?provider=brevo&to=' + encodeURIComponent(testRecipient()), {
                         method: 'GET',
                         credentials: 'same-origin',
                         signal: AbortSignal.timeout(30000)
@@ -1671,6 +1739,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                         credentials: 'same-origin',
                         body: JSON.stringify({
                             provider: 'smtp',
+                            to: testRecipient(),
                             smtpHost: host,
                             smtpPort: port,
                             smtpUsername: username,
@@ -1684,7 +1753,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                 }
                 // If password field is empty but placeholder shows saved (bullets), test saved config
                 else if (passwordPlaceholder && passwordPlaceholder.includes('•')) {
-                    response = await fetch('/api/email/test', {
+                    response = await fetch('/api/email/test?provider=smtp&to=' + encodeURIComponent(testRecipient()), {
                         method: 'GET',
                         credentials: 'same-origin',
                         signal: AbortSignal.timeout(30000)
@@ -1848,6 +1917,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                         credentials: 'same-origin',
                         body: JSON.stringify({
                             provider: 'mailgun',
+                            to: testRecipient(),
                             apiKey: apiKey,
                             mailgunDomain: domain,
                             mailgunRegion: region,
@@ -1859,7 +1929,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                 }
                 // If API key field is empty but placeholder shows saved (bullets), test saved config
                 else if (apiKeyPlaceholder && apiKeyPlaceholder.includes('•')) {
-                    response = await fetch('/api/email/test', {
+                    response = await fetch('/api/email/test?provider=mailgun&to=' + encodeURIComponent(testRecipient()), {
                         method: 'GET',
                         credentials: 'same-origin',
                         signal: AbortSignal.timeout(30000)
@@ -1987,6 +2057,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                         credentials: 'same-origin',
                         body: JSON.stringify({
                             provider: 'sendgrid',
+                            to: testRecipient(),
                             apiKey: apiKey,
                             fromEmail: fromEmail,
                             fromName: fromName
@@ -1996,7 +2067,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                 }
                 // If API key field is empty but placeholder shows saved (bullets), test saved config
                 else if (apiKeyPlaceholder && apiKeyPlaceholder.includes('•')) {
-                    response = await fetch('/api/email/test', {
+                    response = await fetch('/api/email/test?provider=sendgrid&to=' + encodeURIComponent(testRecipient()), {
                         method: 'GET',
                         credentials: 'same-origin',
                         signal: AbortSignal.timeout(30000)
@@ -2124,6 +2195,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                         credentials: 'same-origin',
                         body: JSON.stringify({
                             provider: 'resend',
+                            to: testRecipient(),
                             apiKey: apiKey,
                             fromEmail: fromEmail,
                             fromName: fromName
@@ -2133,7 +2205,7 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
                 }
                 // If API key field is empty but placeholder shows saved (bullets), test saved config
                 else if (apiKeyPlaceholder && apiKeyPlaceholder.includes('•')) {
-                    response = await fetch('/api/email/test', {
+                    response = await fetch('/api/email/test?provider=resend&to=' + encodeURIComponent(testRecipient()), {
                         method: 'GET',
                         credentials: 'same-origin',
                         signal: AbortSignal.timeout(30000)
@@ -2198,18 +2270,27 @@ func (s *Server) renderEmailSettingsPage(w http.ResponseWriter, brevoConfigured,
             }
         });
 
+        // Address test emails go to, the admin's own unless another is entered
+        function testRecipient() {
+            return document.getElementById('test-recipient').value.trim();
+        }
+
         function showSuccess(message) {
+            document.getElementById('error-message').style.display = 'none';
             const el = document.getElementById('success-message');
             el.textContent = message;
             el.style.display = 'block';
-            setTimeout(() => el.style.display = 'none', 5000);
+            el.scrollIntoView({ block: 'nearest' });
+            setTimeout(() => el.style.display = 'none', 8000);
         }
 
+        // Errors stay until the next message, so a provider's error can be read in full
         function showError(message) {
+            document.getElementById('success-message').style.display = 'none';
             const el = document.getElementById('error-message');
             el.textContent = message;
             el.style.display = 'block';
-            setTimeout(() => el.style.display = 'none', 5000);
+            el.scrollIntoView({ block: 'nearest' });
         }
     </script>
 </body>
@@ -2300,39 +2381,6 @@ func getMailgunRegion() string {
 	return region.String
 }
 
-func getActiveProviderBanner(isBrevoActive, isSMTPActive, isMailgunActive, isSendGridActive, isResendActive bool) string {
-	if isResendActive {
-		return `
-        <div style="background: #d4edda; border: 1px solid #c3e6cb; color: #155724; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
-            <strong>✓ Active Provider:</strong> Resend (recommended) - Email notifications are enabled
-        </div>`
-	} else if isBrevoActive {
-		return `
-        <div style="background: #d4edda; border: 1px solid #c3e6cb; color: #155724; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
-            <strong>✓ Active Provider:</strong> Brevo (Sendinblue) - Email notifications are enabled
-        </div>`
-	} else if isSMTPActive {
-		return `
-        <div style="background: #d4edda; border: 1px solid #c3e6cb; color: #155724; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
-            <strong>✓ Active Provider:</strong> SMTP Server - Email notifications are enabled
-        </div>`
-	} else if isMailgunActive {
-		return `
-        <div style="background: #d4edda; border: 1px solid #c3e6cb; color: #155724; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
-            <strong>✓ Active Provider:</strong> Mailgun - Email notifications are enabled
-        </div>`
-	} else if isSendGridActive {
-		return `
-        <div style="background: #d4edda; border: 1px solid #c3e6cb; color: #155724; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
-            <strong>✓ Active Provider:</strong> SendGrid - Email notifications are enabled
-        </div>`
-	}
-	return `
-        <div style="background: #fff3cd; border: 1px solid #ffc107; color: #856404; padding: 16px; border-radius: 4px; margin-bottom: 20px;">
-            <strong>⚠ No Active Provider:</strong> Configure Resend, Brevo, Mailgun, SendGrid, or SMTP to enable email notifications
-        </div>`
-}
-
 func getActiveProviderBadge(isActive bool) string {
 	if isActive {
 		return `<span style="display: inline-block; background: #28a745; color: white; padding: 2px 8px; border-radius: 12px; font-size: 11px; font-weight: 600; margin-left: 8px;">ACTIVE</span>`
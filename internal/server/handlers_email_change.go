@@ -0,0 +1,235 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/Frimurare/WulfVault/internal/auth"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+)
+
+// handleChangeEmail starts a self-service email change by sending a verification link to the new address
+func (s *Server) handleChangeEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := s.getUserFromSession(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	newEmail := strings.TrimSpace(r.FormValue("new_email"))
+	currentPassword := r.FormValue("current_password")
+
+	if newEmail == "" || currentPassword == "" {
+		s.sendError(w, http.StatusBadRequest, "All fields are required")
+		return
+	}
+
+	if addr, err := mail.ParseAddress(newEmail); err != nil || addr.Address != newEmail {
+		s.sendError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+
+	if strings.EqualFold(newEmail, user.Email) {
+		s.sendError(w, http.StatusBadRequest, "New email must be different from current email")
+		return
+	}
+
+	// Verify current password
+	if _, err := auth.AuthenticateUser(user.Email, currentPassword); err != nil {
+		s.sendError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+
+	inUse, err := database.DB.IsEmailInUse(newEmail)
+	if err != nil {
+		log.Printf("Failed to check email availability: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+	if inUse {
+		s.sendError(w, http.StatusConflict, "This email address is already in use")
+		return
+	}
+
+	request, err := database.DB.CreateEmailChangeRequest(user.Id, user.Email, newEmail)
+	if err != nil {
+		log.Printf("Failed to create email change request: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+
+	if err := email.SendEmailChangeVerificationEmail(newEmail, request.Token, s.getPublicURL(), s.config.CompanyName, database.DB.GetEmailChangeExpiry()); err != nil {
+		log.Printf("Failed to send email change verification to %s: %v", newEmail, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to send verification email. Please contact your administrator.")
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionEmailChangeRequested,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", user.Id),
+		Details:    database.CreateAuditDetails(map[string]interface{}{"new_email": newEmail}),
+		IPAddress:  clientIP(r),
+		UserAgent:  r.UserAgent(),
+		Success:    true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "A verification link has been sent to " + newEmail + ". Your email will be updated once you click it.",
+	})
+}
+
+// handleConfirmEmailChange applies a pending email change when the verification link is opened
+func (s *Server) handleConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		s.renderEmailChangeResultPage(w, false, "Invalid verification link.")
+		return
+	}
+
+	request, err := database.DB.ConfirmEmailChange(token)
+	if err != nil {
+		log.Printf("Email change confirmation failed: %v", err)
+		s.renderEmailChangeResultPage(w, false, "This verification link is invalid, expired, or the address is already in use.")
+		return
+	}
+
+	log.Printf("✅ User %d changed email from %s to %s", request.UserId, request.OldEmail, request.NewEmail)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(request.UserId),
+		UserEmail:  request.NewEmail,
+		Action:     database.ActionEmailChanged,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", request.UserId),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"old_email": request.OldEmail,
+			"new_email": request.NewEmail,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	// Notify the previous address so an unexpected change is noticed
+	database.DB.NotifyUser(request.UserId, database.NotifySecurity, "Email address changed", "Your email address was changed from "+request.OldEmail+" to "+request.NewEmail, "/settings")
+	go func() {
+		if err := email.SendEmailChangedNotification(request.OldEmail, request.NewEmail, s.config.CompanyName); err != nil {
+			log.Printf("Failed to send email change notification to %s: %v", request.OldEmail, err)
+		}
+	}()
+
+	s.renderEmailChangeResultPage(w, true, "Your email address has been changed to "+request.NewEmail+". Use it the next time you log in.")
+}
+
+// renderEmailChangeResultPage renders the outcome of an email change confirmation
+func (s *Server) renderEmailChangeResultPage(w http.ResponseWriter, success bool, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	icon := "✓"
+	title := "Email Changed"
+	iconBackground := "#d4edda"
+	titleColor := "#155724"
+	if !success {
+		icon = "✗"
+		title = "Verification Failed"
+		iconBackground = "#f8d7da"
+		titleColor = "#721c24"
+	}
+
+	page := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>` + title + ` - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: linear-gradient(135deg, ` + s.getPrimaryColor() + ` 0%, ` + s.getSecondaryColor() + ` 100%);
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            padding: 20px;
+        }
+        .container {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 20px 60px rgba(0,0,0,0.3);
+            padding: 50px 40px;
+            max-width: 450px;
+            width: 100%;
+            text-align: center;
+        }
+        .icon {
+            width: 80px;
+            height: 80px;
+            background: ` + iconBackground + `;
+            border-radius: 50%;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            margin: 0 auto 30px;
+            font-size: 40px;
+        }
+        h1 {
+            color: ` + titleColor + `;
+            margin-bottom: 20px;
+            font-size: 28px;
+        }
+        p {
+            color: #666;
+            line-height: 1.6;
+            margin-bottom: 15px;
+        }
+        .btn {
+            display: inline-block;
+            margin-top: 20px;
+            padding: 14px 30px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            text-decoration: none;
+            border-radius: 6px;
+            font-weight: 600;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="icon">` + icon + `</div>
+        <h1>` + title + `</h1>
+        <p>` + html.EscapeString(message) + `</p>
+        <a href="/settings" class="btn">Go to Settings</a>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(page))
+}
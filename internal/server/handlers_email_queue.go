@@ -0,0 +1,263 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+)
+
+// emailQueuePageSize is how many queued emails the admin page lists
+const emailQueuePageSize = 200
+
+// handleAdminEmailQueue lists queued and failed emails (GET) or retries or discards one (POST)
+func (s *Server) handleAdminEmailQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.renderAdminEmailQueue(w, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	emailID, err := strconv.ParseInt(r.FormValue("email_id"), 10, 64)
+	if err != nil {
+		s.renderAdminEmailQueue(w, "Error: Invalid email")
+		return
+	}
+	queued, err := database.DB.GetQueuedEmail(emailID)
+	if err != nil {
+		s.renderAdminEmailQueue(w, "Error: "+err.Error())
+		return
+	}
+
+	var message string
+	action := database.ActionEmailRetried
+	switch r.FormValue("action") {
+	case "retry":
+		var retried bool
+		retried, err = database.DB.RetryQueuedEmail(queued.Id)
+		if err == nil && !retried {
+			s.renderAdminEmailQueue(w, "Error: The email is being sent right now")
+			return
+		}
+		email.WakeQueue()
+		message = "Email queued for another attempt. Reload the page to see whether it was sent."
+	case "delete":
+		err = database.DB.DeleteQueuedEmail(queued.Id)
+		action = database.ActionEmailDiscarded
+		message = "Email removed from the queue."
+	default:
+		s.renderAdminEmailQueue(w, "Error: Unknown action")
+		return
+	}
+	if err != nil {
+		s.renderAdminEmailQueue(w, "Error: "+err.Error())
+		return
+	}
+
+	log.Printf("Queued email %d to %s: %s by %s", queued.Id, queued.Recipient, r.FormValue("action"), admin.Email)
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     action,
+		EntityType: database.EntityEmail,
+		EntityID:   strconv.FormatInt(queued.Id, 10),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"kind":      queued.Kind,
+			"recipient": queued.Recipient,
+			"status":    queued.Status,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.renderAdminEmailQueue(w, message)
+}
+
+// renderAdminEmailQueue renders the emails that are waiting to be sent or failed
+func (s *Server) renderAdminEmailQueue(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	emails, err := database.DB.GetPendingEmails(emailQueuePageSize)
+	if err != nil {
+		log.Printf("Failed to load email queue: %v", err)
+	}
+	waiting, failed, err := database.DB.CountEmailQueue()
+	if err != nil {
+		log.Printf("Failed to count email queue: %v", err)
+	}
+	maxAttempts := database.DB.GetEmailMaxAttempts()
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Email Queue - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        .card p { color: #555; font-size: 14px; line-height: 1.6; margin-bottom: 10px; }
+        .btn {
+            padding: 8px 16px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        .btn-danger { background: #f44336; }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+        }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        table { width: 100%; border-collapse: collapse; font-size: 14px; }
+        th, td { text-align: left; padding: 12px; border-bottom: 1px solid #eee; vertical-align: top; }
+        th { background: #fafafa; color: #555; font-weight: 600; }
+        td form { display: inline; }
+        .muted { color: #888; font-size: 12px; margin-top: 4px; word-break: break-all; }
+        .status-queued, .status-sending { color: #1565c0; font-weight: 600; }
+        .status-failed { color: #c33; font-weight: 600; }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <h2>📬 Email Queue</h2>
+
+        <div class="info-box">
+            Shared file links, welcome emails and file request emails are sent from this queue in the background. An email that can't be sent is tried again after 1, 2, 4 … minutes (at most an hour apart), up to ` + strconv.Itoa(maxAttempts) + ` attempts (<a href="/admin/settings">Server Settings</a>). Emails the provider rejects outright are not retried. Failed emails stay here until you retry or remove them. Sent emails leave the queue; emailed file links are recorded in the file's email log.
+        </div>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	html += fmt.Sprintf(`
+        <div class="card">
+            <h3>Queued and Failed Emails</h3>
+            <p>%d waiting, %d failed</p>`, waiting, failed)
+
+	if len(emails) == 0 {
+		html += `
+            <p>The queue is empty.</p>`
+	} else {
+		html += `
+            <table>
+                <tr><th>Recipient</th><th>Email</th><th>Status</th><th></th></tr>`
+		for _, queued := range emails {
+			status := `<span class="status-` + queued.Status + `">` + strings.ToUpper(queued.Status[:1]) + queued.Status[1:] + `</span>`
+			switch queued.Status {
+			case database.EmailQueueStatusQueued:
+				if queued.Attempts > 0 {
+					status += fmt.Sprintf(`<div class="muted">%d of %d attempts failed, next %s</div>`, queued.Attempts, maxAttempts,
+						time.Unix(queued.NextAttemptAt, 0).Format("2006-01-02 15:04:05"))
+				}
+			case database.EmailQueueStatusFailed:
+				status += fmt.Sprintf(`<div class="muted">After %d attempt(s)</div>`, queued.Attempts)
+			}
+			if queued.LastError != "" {
+				status += `<div class="muted">` + template.HTMLEscapeString(queued.LastError) + `</div>`
+			}
+
+			actions := ""
+			if queued.Status != database.EmailQueueStatusSending {
+				id := strconv.FormatInt(queued.Id, 10)
+				retryLabel := "Send Now"
+				if queued.Status == database.EmailQueueStatusFailed {
+					retryLabel = "Retry"
+				}
+				actions = `
+                        <form method="POST"><input type="hidden" name="action" value="retry"><input type="hidden" name="email_id" value="` + id + `"><button type="submit" class="btn">` + retryLabel + `</button></form>
+                        <form method="POST" onsubmit="return confirm('Remove this email from the queue? It will not be sent.');"><input type="hidden" name="action" value="delete"><input type="hidden" name="email_id" value="` + id + `"><button type="submit" class="btn btn-danger">Remove</button></form>`
+			}
+
+			html += `
+                <tr>
+                    <td><strong>` + template.HTMLEscapeString(queued.Recipient) + `</strong><div class="muted">Queued ` + time.Unix(queued.CreatedAt, 0).Format("2006-01-02 15:04") + `</div></td>
+                    <td>` + template.HTMLEscapeString(queued.Subject) + `<div class="muted">` + template.HTMLEscapeString(database.EmailKindLabel(queued.Kind)) + `</div></td>
+                    <td>` + status + `</td>
+                    <td style="white-space: nowrap;">` + actions + `
+                    </td>
+                </tr>`
+		}
+		html += `
+            </table>`
+	}
+
+	html += `
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
@@ -0,0 +1,263 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+)
+
+// emailSnippetMaxLength is the longest header or footer snippet an admin can save
+const emailSnippetMaxLength = 4000
+
+// handleAdminEmailTemplates shows the email template settings (GET), a preview of a template
+// (GET with ?preview=<template>) or saves the settings (POST)
+func (s *Server) handleAdminEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		if name := r.URL.Query().Get("preview"); name != "" {
+			s.previewEmailTemplate(w, name)
+			return
+		}
+		s.renderAdminEmailTemplates(w, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settings := map[string]string{}
+	for _, name := range email.Templates {
+		subject := strings.TrimSpace(r.FormValue("subject_" + name))
+		if subject != "" {
+			if err := email.ValidateSubject(subject); err != nil {
+				s.renderAdminEmailTemplates(w, "Error: Invalid subject for "+email.TemplateLabel(name)+" emails: "+err.Error())
+				return
+			}
+		}
+		settings[email.SubjectConfigKey(name)] = subject
+	}
+	for _, key := range []string{"email_header_html", "email_footer_html"} {
+		snippet := strings.TrimSpace(r.FormValue(key))
+		if len(snippet) > emailSnippetMaxLength {
+			s.renderAdminEmailTemplates(w, "Error: Header and footer snippets can be at most 4000 characters")
+			return
+		}
+		settings[key] = snippet
+	}
+
+	details := map[string]interface{}{}
+	for key, value := range settings {
+		if err := database.DB.SetConfigValue(key, value); err != nil {
+			s.renderAdminEmailTemplates(w, "Error: "+err.Error())
+			return
+		}
+		details[key] = value != ""
+	}
+
+	log.Printf("Email template settings updated by %s", admin.Email)
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionEmailConfigUpdated,
+		EntityType: database.EntitySettings,
+		EntityID:   "email_templates",
+		Details:    database.CreateAuditDetails(details),
+		IPAddress:  clientIP(r),
+		UserAgent:  r.UserAgent(),
+		Success:    true,
+	})
+
+	s.renderAdminEmailTemplates(w, "Email template settings saved.")
+}
+
+// previewEmailTemplate renders the HTML body of a template with sample data
+func (s *Server) previewEmailTemplate(w http.ResponseWriter, name string) {
+	data := email.NewTemplateData(s.config.CompanyName)
+	data.RecipientEmail = "recipient@example.com"
+	data.SenderName = "Jane Doe"
+	data.SenderEmail = "jane.doe@example.com"
+	data.Message = "Here are the documents we talked about.\nLet me know if anything is missing."
+	data.Link = s.getPublicURL() + "/s/preview"
+	data.File = &email.TemplateFile{
+		Name:          "Quarterly report.pdf",
+		Size:          database.FormatFileSize(4 * 1024 * 1024),
+		Comment:       "Final version",
+		ExpiresAt:     time.Now().AddDate(0, 0, 7).Format("2006-01-02 15:04"),
+		DownloadLimit: 5,
+	}
+	data.RequestTitle = "Signed contract"
+	data.ExpiresAt = time.Now().Add(24 * time.Hour).Format("2006-01-02 15:04")
+
+	subject, htmlBody, _, err := email.RenderEmail(name, data)
+	if err != nil {
+		http.Error(w, "Could not render template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src * data:; style-src 'unsafe-inline'")
+	w.Write([]byte(`<div style="font-family: Arial, sans-serif; padding: 12px 20px; background: #333; color: #fff;">Subject: ` + template.HTMLEscapeString(subject) + `</div>` + htmlBody))
+}
+
+// renderAdminEmailTemplates renders the subject overrides and header and footer snippets of
+// the email templates
+func (s *Server) renderAdminEmailTemplates(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	header, _ := database.DB.GetConfigValue("email_header_html")
+	footer, _ := database.DB.GetConfigValue("email_footer_html")
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Email Templates - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        .card p { color: #555; font-size: 14px; line-height: 1.6; margin-bottom: 10px; }
+        .btn {
+            padding: 10px 20px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            line-height: 1.6;
+        }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        .form-group { margin-bottom: 16px; }
+        .form-group label { display: block; font-size: 13px; font-weight: 600; color: #555; margin-bottom: 6px; }
+        .form-group input[type="text"], .form-group textarea {
+            width: 100%;
+            padding: 10px 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+        }
+        .form-group textarea { font-family: monospace; min-height: 90px; }
+        .help-text { color: #888; font-size: 12px; margin-top: 4px; }
+        code { background: #f3f4f6; padding: 1px 4px; border-radius: 3px; }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <h2>✉️ Email Templates</h2>
+
+        <div class="info-box">
+            Shared file, welcome and file request emails use the company name and primary and secondary colors from <a href="/admin/branding">Branding</a>. Subjects are templates with the fields <code>{{.CompanyName}}</code>, <code>{{.SenderName}}</code>, <code>{{.SenderEmail}}</code>, <code>{{.RecipientEmail}}</code>, <code>{{.File.Name}}</code> (shared file) and <code>{{.RequestTitle}}</code> (file request).
+            To change an email's layout, copy its <code>.html</code> and <code>.txt</code> files from <code>internal/email/templates</code> in the source to <code>` + template.HTMLEscapeString(email.TemplateDir()) + `</code> and edit them there. They replace the built-in files at the next send.
+        </div>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	html += `
+        <form method="POST">
+            <div class="card">
+                <h3>Subjects</h3>
+                <p>Leave a subject empty to use the default.</p>`
+
+	for _, name := range email.Templates {
+		subject, _ := database.DB.GetConfigValue(email.SubjectConfigKey(name))
+		html += `
+                <div class="form-group">
+                    <label for="subject_` + name + `">` + email.TemplateLabel(name) + ` <a href="/admin/email-templates?preview=` + name + `" target="_blank" rel="noopener" style="font-weight: normal;">Preview</a></label>
+                    <input type="text" id="subject_` + name + `" name="subject_` + name + `" value="` + template.HTMLEscapeString(subject) + `" placeholder="` + template.HTMLEscapeString(email.DefaultSubject(name)) + `" maxlength="200">
+                </div>`
+	}
+
+	html += `
+            </div>
+
+            <div class="card">
+                <h3>Header and Footer</h3>
+                <p>HTML shown above and below the content of every templated email, for example a disclaimer or contact details. The plain text part of the email gets the text without tags.</p>
+                <div class="form-group">
+                    <label for="email_header_html">Header snippet</label>
+                    <textarea id="email_header_html" name="email_header_html" maxlength="4000">` + template.HTMLEscapeString(header) + `</textarea>
+                </div>
+                <div class="form-group">
+                    <label for="email_footer_html">Footer snippet</label>
+                    <textarea id="email_footer_html" name="email_footer_html" maxlength="4000">` + template.HTMLEscapeString(footer) + `</textarea>
+                </div>
+                <button type="submit" class="btn">Save</button>
+            </div>
+        </form>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
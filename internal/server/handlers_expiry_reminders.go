@@ -0,0 +1,75 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// handleExpiryReminderUnsubscribe lets a recipient stop expiry reminders from the link in a reminder email.
+// GET only shows the choice, so link scanners that prefetch the URL don't unsubscribe anyone.
+func (s *Server) handleExpiryReminderUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/reminders/unsubscribe/")
+	reminder, err := database.DB.GetExpiryReminderByToken(token)
+	if token == "" || err != nil {
+		s.renderSplashPageUnavailable(w, "🔗", "Invalid Link", "This unsubscribe link is not valid.")
+		return
+	}
+
+	fileName := "this file"
+	if file, err := database.DB.GetFileByID(reminder.FileId); err == nil {
+		fileName = file.Name
+	}
+
+	if r.Method != http.MethodPost {
+		buttonStyle := "padding: 12px 24px; margin: 20px 6px 0; border: none; border-radius: 8px; font-size: 15px; cursor: pointer; color: white; background: " + s.getPrimaryColor() + ";"
+		s.renderSplashPageUnavailable(w, "⏰", "Expiry Reminders",
+			`Stop reminder emails to <strong>`+template.HTMLEscapeString(reminder.RecipientEmail)+`</strong> before shared files expire?
+            <form method="POST">
+                <button type="submit" name="scope" value="file" style="`+buttonStyle+`">Only for `+template.HTMLEscapeString(fileName)+`</button>
+                <button type="submit" name="scope" value="all" style="`+buttonStyle+`">For all files</button>
+            </form>`)
+		return
+	}
+
+	scope := r.FormValue("scope")
+	fileId := reminder.FileId
+	if scope == "all" {
+		fileId = ""
+	}
+
+	if err := database.DB.OptOutOfExpiryReminders(reminder.RecipientEmail, fileId); err != nil {
+		log.Printf("Failed to opt %s out of expiry reminders: %v", reminder.RecipientEmail, err)
+		s.renderSplashPageUnavailable(w, "⚠️", "Something Went Wrong", "Could not update your reminder settings. Please try again later.")
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     0,
+		UserEmail:  reminder.RecipientEmail,
+		Action:     database.ActionExpiryRemindersOptOut,
+		EntityType: database.EntityFile,
+		EntityID:   reminder.FileId,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name": fileName,
+			"all_files": fileId == "",
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	message := "You will no longer get expiry reminders for " + template.HTMLEscapeString(fileName) + "."
+	if fileId == "" {
+		message = "You will no longer get expiry reminders for any shared files."
+	}
+	s.renderSplashPageUnavailable(w, "✅", "Unsubscribed", message)
+}
@@ -0,0 +1,614 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// maxAccessRequestsPerHour limits how many access requests one IP address can send
+const maxAccessRequestsPerHour = 10
+
+// userHasFileAccess checks if a logged-in user may download an auth-required file.
+// Files without the "request access" flow are open to every authenticated user.
+func userHasFileAccess(fileInfo *database.FileInfo, user *models.User) bool {
+	if !database.DB.IsFileAccessRequestsEnabled(fileInfo.Id) {
+		return true
+	}
+	if canManageFileAccess(user, fileInfo) || database.DB.HasFileAccessGrant(fileInfo.Id, user.Email) {
+		return true
+	}
+
+	// Members of teams the file is shared with keep access
+	if teams, err := database.DB.GetFileTeams(fileInfo.Id); err == nil {
+		for _, team := range teams {
+			if isMember, err := database.DB.IsTeamMember(team.Id, user.Id); err == nil && isMember {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// downloadAccountHasFileAccess checks if a download account may download an auth-required file
+func downloadAccountHasFileAccess(fileInfo *database.FileInfo, account *models.DownloadAccount) bool {
+	if !database.DB.IsFileAccessRequestsEnabled(fileInfo.Id) {
+		return true
+	}
+	return database.DB.HasFileAccessGrant(fileInfo.Id, account.Email)
+}
+
+// canManageFileAccess checks if a user may decide who gets access to a file
+func canManageFileAccess(user *models.User, fileInfo *database.FileInfo) bool {
+	return fileInfo.UserId == user.Id || user.IsAdmin()
+}
+
+// handleFileAccessRequest records a recipient's request for access and emails the file owner
+func (s *Server) handleFileAccessRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fileInfo, err := database.DB.GetFileByID(r.FormValue("file_id"))
+	if err != nil {
+		s.renderSplashPageUnavailable(w, "🚫", "File Not Available", "This file could not be found.")
+		return
+	}
+	if !fileInfo.RequireAuth || !database.DB.IsFileAccessRequestsEnabled(fileInfo.Id) {
+		s.renderSplashPageUnavailable(w, "🚫", "Requests Not Accepted", "The owner of this file does not accept access requests.")
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	requesterEmail := strings.TrimSpace(r.FormValue("email"))
+	message := strings.TrimSpace(r.FormValue("message"))
+	if len(message) > 1000 {
+		message = message[:1000]
+	}
+
+	if addr, err := mail.ParseAddress(requesterEmail); err != nil || addr.Address != requesterEmail {
+		s.renderDownloadAuthPage(w, fileInfo, "Please enter a valid email address to request access")
+		return
+	}
+
+	ip := clientIP(r)
+	if database.DB.CountFileAccessRequestsFromIP(ip, time.Now().Add(-time.Hour).Unix()) >= maxAccessRequestsPerHour {
+		s.renderSplashPageUnavailable(w, "⏳", "Too Many Requests", "Too many access requests have been sent from your network. Please try again later.")
+		return
+	}
+
+	if database.DB.HasFileAccessGrant(fileInfo.Id, requesterEmail) {
+		s.renderDownloadAuthPage(w, fileInfo, "This email address already has access. Log in to download the file.")
+		return
+	}
+
+	request, created, err := database.DB.CreateFileAccessRequest(fileInfo.Id, requesterEmail, name, message, ip)
+	if err != nil {
+		log.Printf("Failed to create access request for file %s: %v", fileInfo.Id, err)
+		s.renderDownloadAuthPage(w, fileInfo, "Could not send your request. Please try again later.")
+		return
+	}
+
+	if created {
+		log.Printf("Access to file %s (%s) requested by %s", fileInfo.Id, fileInfo.Name, request.Email)
+
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     0,
+			UserEmail:  request.Email,
+			Action:     database.ActionFileAccessRequested,
+			EntityType: database.EntityFile,
+			EntityID:   fileInfo.Id,
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"file_name":  fileInfo.Name,
+				"request_id": request.Id,
+				"name":       request.Name,
+			}),
+			IPAddress: ip,
+			UserAgent: r.UserAgent(),
+			Success:   true,
+		})
+
+		go func() {
+			owner, err := database.DB.GetUserByID(fileInfo.UserId)
+			if err != nil {
+				log.Printf("Failed to find owner of file %s for access request: %v", fileInfo.Id, err)
+				return
+			}
+			reviewPath := "/file/access-requests/review/" + request.Token
+			if !database.DB.NotifyUser(owner.Id, database.NotifyAccessRequests, "Access requested", request.Name+" ("+request.Email+") asked for access to "+fileInfo.Name, reviewPath) {
+				return
+			}
+			reviewURL := s.getPublicURL() + reviewPath
+			if err := email.SendFileAccessRequestEmail(owner.Email, fileInfo.Name, request.Name, request.Email, request.Message, reviewURL, s.config.CompanyName); err != nil {
+				log.Printf("Failed to send access request to %s: %v", owner.Email, err)
+			}
+		}()
+	}
+
+	s.renderSplashPageUnavailable(w, "📨", "Request Sent",
+		"The file owner has been asked to give "+template.HTMLEscapeString(request.Email)+" access. You will get an email once they have decided.")
+}
+
+// handleFileAccessRequestReview shows the page the approve link in the owner's email points to
+func (s *Server) handleFileAccessRequestReview(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/file/access-requests/review/")
+	request, err := database.DB.GetFileAccessRequestByToken(token)
+	if err != nil {
+		http.Error(w, "Access request not found", http.StatusNotFound)
+		return
+	}
+
+	fileInfo, err := database.DB.GetFileByID(request.FileId)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if !canManageFileAccess(user, fileInfo) {
+		http.Error(w, "Only the file owner can review this request", http.StatusForbidden)
+		return
+	}
+
+	s.renderFileAccessRequestReview(w, user, fileInfo, request)
+}
+
+// handleFileAccessRequestDecide approves or rejects an access request
+func (s *Server) handleFileAccessRequestDecide(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	requestID, _ := strconv.Atoi(r.FormValue("request_id"))
+	request, err := database.DB.GetFileAccessRequestByID(requestID)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "Access request not found")
+		return
+	}
+
+	fileInfo, err := database.DB.GetFileByID(request.FileId)
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if !canManageFileAccess(user, fileInfo) {
+		s.sendError(w, http.StatusForbidden, "Only the file owner can decide on this request")
+		return
+	}
+
+	approved := false
+	switch r.FormValue("decision") {
+	case "approve":
+		approved = true
+	case "reject":
+	default:
+		s.sendError(w, http.StatusBadRequest, "Decision must be approve or reject")
+		return
+	}
+
+	status := database.ApprovalStatusRejected
+	action := database.ActionFileAccessDenied
+	provisionAccount := false
+	if approved {
+		status = database.ApprovalStatusApproved
+		action = database.ActionFileAccessGranted
+		provisionAccount = r.FormValue("create_account") == "true" && !fileAccessAccountExists(request.Email)
+	}
+
+	if err := database.DB.DecideFileAccessRequest(request.Id, status, user.Id, provisionAccount); err != nil {
+		s.sendError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	setPasswordURL := ""
+	if approved {
+		if err := database.DB.GrantFileAccess(fileInfo.Id, request.Email, user.Id); err != nil {
+			log.Printf("Failed to grant access to file %s for %s: %v", fileInfo.Id, request.Email, err)
+			s.sendError(w, http.StatusInternalServerError, "Failed to grant access")
+			return
+		}
+
+		if provisionAccount {
+			setPasswordURL, err = s.provisionAccessDownloadAccount(user, request)
+			if err != nil {
+				log.Printf("Failed to create download account for %s: %v", request.Email, err)
+			}
+		}
+	}
+
+	log.Printf("Access to file %s for %s %s by %s", fileInfo.Id, request.Email, status, user.Email)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     action,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name":           fileInfo.Name,
+			"request_id":          request.Id,
+			"email":               request.Email,
+			"account_provisioned": setPasswordURL != "",
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	go func() {
+		fileURL, err := email.ShareLinkURL(s.getPublicURL(), fileInfo.Id, request.Email)
+		if err != nil {
+			log.Printf("Failed to create share link for access decision to %s: %v", request.Email, err)
+			return
+		}
+		if err := email.SendFileAccessDecisionEmail(request.Email, fileInfo.Name, approved, fileURL, setPasswordURL, s.config.CompanyName); err != nil {
+			log.Printf("Failed to send access decision to %s: %v", request.Email, err)
+		}
+	}()
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success":             true,
+		"status":              status,
+		"account_provisioned": setPasswordURL != "",
+	})
+}
+
+// fileAccessAccountExists reports whether an email can already log in as a user or download account
+func fileAccessAccountExists(emailAddr string) bool {
+	if _, err := database.DB.GetUserByEmail(emailAddr); err == nil {
+		return true
+	}
+	_, err := database.DB.GetDownloadAccountByEmail(emailAddr)
+	return err == nil
+}
+
+// provisionAccessDownloadAccount creates a download account for an approved requester and
+// returns the link where they choose their password
+func (s *Server) provisionAccessDownloadAccount(approver *models.User, request *database.FileAccessRequest) (string, error) {
+	passwordBytes := make([]byte, 24)
+	if _, err := rand.Read(passwordBytes); err != nil {
+		return "", err
+	}
+	hashedPassword, err := hashPassword(hex.EncodeToString(passwordBytes))
+	if err != nil {
+		return "", err
+	}
+
+	name := request.Name
+	if name == "" {
+		name = request.Email
+	}
+	account := &models.DownloadAccount{
+		Name:      name,
+		Email:     request.Email,
+		Password:  hashedPassword,
+		IsActive:  true,
+		CreatedBy: approver.Id,
+	}
+	if err := database.DB.CreateDownloadAccount(account); err != nil {
+		return "", err
+	}
+
+	log.Printf("Download account created for approved access request: %s", account.Email)
+
+	token, err := database.DB.CreatePasswordResetToken(account.Email, database.AccountTypeDownloadAccount)
+	if err != nil {
+		return "", err
+	}
+	return s.getPublicURL() + "/reset-password?token=" + token, nil
+}
+
+// handleFileAccess returns the access list and access requests of a file for the edit dialog
+func (s *Server) handleFileAccess(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	fileInfo, err := database.DB.GetFileByID(r.URL.Query().Get("file_id"))
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if !canManageFileAccess(user, fileInfo) {
+		s.sendError(w, http.StatusForbidden, "Not authorized to view this file")
+		return
+	}
+
+	grants, err := database.DB.GetFileAccessGrants(fileInfo.Id)
+	if err != nil {
+		log.Printf("Error loading access list for file %s: %v", fileInfo.Id, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load access list")
+		return
+	}
+	requests, err := database.DB.GetFileAccessRequests(fileInfo.Id)
+	if err != nil {
+		log.Printf("Error loading access requests for file %s: %v", fileInfo.Id, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load access requests")
+		return
+	}
+
+	grantList := make([]map[string]interface{}, 0, len(grants))
+	for _, grant := range grants {
+		grantList = append(grantList, map[string]interface{}{
+			"email":      grant.Email,
+			"granted_at": grant.GrantedAt,
+		})
+	}
+	requestList := make([]map[string]interface{}, 0, len(requests))
+	for _, request := range requests {
+		requestList = append(requestList, map[string]interface{}{
+			"id":                  request.Id,
+			"email":               request.Email,
+			"name":                request.Name,
+			"message":             request.Message,
+			"status":              request.Status,
+			"requested_at":        request.RequestedAt,
+			"decided_at":          request.DecidedAt,
+			"account_provisioned": request.AccountProvisioned,
+		})
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":  database.DB.IsFileAccessRequestsEnabled(fileInfo.Id),
+		"grants":   grantList,
+		"requests": requestList,
+	})
+}
+
+// handleFileAccessUpdate grants or revokes access to a file for an email address
+func (s *Server) handleFileAccessUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	fileInfo, err := database.DB.GetFileByID(r.FormValue("file_id"))
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if !canManageFileAccess(user, fileInfo) {
+		s.sendError(w, http.StatusForbidden, "Not authorized to change this file")
+		return
+	}
+
+	emailAddr := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	if addr, err := mail.ParseAddress(emailAddr); err != nil || addr.Address != emailAddr {
+		s.sendError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+
+	var action string
+	switch r.FormValue("action") {
+	case "grant":
+		action = database.ActionFileAccessGranted
+		err = database.DB.GrantFileAccess(fileInfo.Id, emailAddr, user.Id)
+	case "revoke":
+		action = database.ActionFileAccessRevoked
+		err = database.DB.RevokeFileAccess(fileInfo.Id, emailAddr)
+	default:
+		s.sendError(w, http.StatusBadRequest, "Action must be grant or revoke")
+		return
+	}
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     action,
+		EntityType: database.EntityFile,
+		EntityID:   fileInfo.Id,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name": fileInfo.Name,
+			"email":     emailAddr,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]string{
+		"message": "Access list updated",
+	})
+}
+
+// renderFileAccessRequestReview renders the approve/reject page for an access request
+func (s *Server) renderFileAccessRequestReview(w http.ResponseWriter, user *models.User, fileInfo *database.FileInfo, request *database.FileAccessRequest) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	requester := template.HTMLEscapeString(request.Email)
+	if request.Name != "" {
+		requester = template.HTMLEscapeString(request.Name) + " &lt;" + requester + "&gt;"
+	}
+
+	messageHTML := ""
+	if request.Message != "" {
+		messageHTML = `<p><strong>Message:</strong> ` + template.HTMLEscapeString(request.Message) + `</p>`
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Access Request - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 700px;
+            margin: 40px auto;
+            padding: 0 20px;
+            padding-top: 40px;
+        }
+        h2 {
+            margin: 30px 0 20px 0;
+            color: #333;
+        }
+        .card {
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 1px 3px rgba(0,0,0,0.08);
+            border-top: 3px solid ` + s.getPrimaryColor() + `;
+            padding: 24px;
+        }
+        .card p {
+            font-size: 14px;
+            color: #555;
+            margin: 6px 0;
+            word-wrap: break-word;
+        }
+        .actions {
+            display: flex;
+            gap: 10px;
+            margin-top: 20px;
+        }
+        .btn {
+            padding: 10px 20px;
+            border: none;
+            border-radius: 6px;
+            font-size: 14px;
+            font-weight: 600;
+            cursor: pointer;
+        }
+        .btn-approve {
+            background: #4caf50;
+            color: white;
+        }
+        .btn-reject {
+            background: #f44336;
+            color: white;
+        }
+        .status {
+            margin-top: 20px;
+            padding: 12px;
+            border-radius: 6px;
+            background: #e3f2fd;
+            color: #0d47a1;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getHeaderHTML(user, user.IsAdmin()) + `
+    <div class="container">
+        <h2>🔑 Access Request</h2>
+
+        <div class="card">
+            <p><strong>File:</strong> ` + template.HTMLEscapeString(fileInfo.Name) + `</p>
+            <p><strong>Requested by:</strong> ` + requester + `</p>
+            <p><strong>Requested:</strong> ` + time.Unix(request.RequestedAt, 0).Format("2006-01-02 15:04") + `</p>
+            ` + messageHTML
+
+	if request.Status == database.ApprovalStatusPending {
+		accountOption := ""
+		if !fileAccessAccountExists(request.Email) {
+			accountOption = `
+            <label style="display: flex; align-items: center; gap: 8px; margin-top: 16px; font-size: 14px; cursor: pointer;">
+                <input type="checkbox" id="createAccount" checked>
+                Create a download account for this email address
+            </label>`
+		}
+
+		html += accountOption + `
+            <div class="actions">
+                <button class="btn btn-approve" onclick="decide('approve')">✓ Grant Access</button>
+                <button class="btn btn-reject" onclick="decide('reject')">✗ Decline</button>
+            </div>
+            <div id="result" class="status" style="display: none;"></div>`
+	} else {
+		outcome := "declined"
+		if request.Status == database.ApprovalStatusApproved {
+			outcome = "approved"
+		}
+		html += `
+            <div class="status">This request was already ` + outcome + ` on ` + time.Unix(request.DecidedAt, 0).Format("2006-01-02 15:04") + `.</div>`
+	}
+
+	html += `
+        </div>
+    </div>
+
+    <script>
+        async function decide(decision) {
+            const createAccount = document.getElementById('createAccount');
+            const body = 'request_id=` + fmt.Sprintf("%d", request.Id) + `&decision=' + decision +
+                '&create_account=' + (createAccount && createAccount.checked ? 'true' : 'false');
+
+            try {
+                const response = await fetch('/file/access-requests/decide', {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+                    body: body
+                });
+                const result = await response.json();
+                if (!response.ok) {
+                    alert('Failed: ' + (result.error || 'Unknown error'));
+                    return;
+                }
+
+                document.querySelectorAll('.actions button').forEach(btn => btn.disabled = true);
+                if (createAccount) createAccount.disabled = true;
+                const status = document.getElementById('result');
+                status.textContent = decision === 'approve'
+                    ? 'Access granted. The requester has been notified' + (result.account_provisioned ? ' and can set a password for their new download account.' : '.')
+                    : 'Request declined. The requester has been notified.';
+                status.style.display = 'block';
+            } catch (error) {
+                alert('Failed: ' + error.message);
+            }
+        }
+    </script>
+    <div style="text-align:center; font-size: 0.8em; margin-top: 2em; padding: 1em; color:#777;">
+        Powered by WulfVault © Ulf Holmström – AGPL-3.0
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
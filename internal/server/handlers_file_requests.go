@@ -8,11 +8,9 @@ package server
 import (
 	"fmt"
 	"html"
-	"io"
+	"html/template"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -20,35 +18,10 @@ import (
 	"github.com/Frimurare/WulfVault/internal/database"
 	"github.com/Frimurare/WulfVault/internal/email"
 	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+	"github.com/Frimurare/WulfVault/internal/webhooks"
 )
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	// RemoteAddr includes port, strip it
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-	return ip
-}
-
 // handleFileRequestCreate creates a new file upload request
 func (s *Server) handleFileRequestCreate(w http.ResponseWriter, r *http.Request) {
 	user, ok := userFromContext(r.Context())
@@ -71,8 +44,13 @@ func (s *Server) handleFileRequestCreate(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	title := r.FormValue("title")
-	message := r.FormValue("message")
+	fileRequest, formError := parseFileRequestForm(r, user)
+	if formError != "" {
+		s.sendError(w, http.StatusBadRequest, formError)
+		return
+	}
+	title := fileRequest.Title
+	message := fileRequest.Message
 	maxFileSizeMB, _ := strconv.Atoi(r.FormValue("max_file_size_mb"))
 	allowedFileTypes := r.FormValue("allowed_file_types")
 	recipientEmail := r.FormValue("recipient_email")
@@ -81,8 +59,11 @@ func (s *Server) handleFileRequestCreate(w http.ResponseWriter, r *http.Request)
 	// Debug logging
 	log.Printf("File request params: title='%s', message='%s', sizeMB=%d", title, message, maxFileSizeMB)
 
-	if title == "" {
-		s.sendError(w, http.StatusBadRequest, "Title is required")
+	if limitMessage, err := checkFileRequestLimit(user); err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to check upload request limit")
+		return
+	} else if limitMessage != "" {
+		s.sendError(w, http.StatusForbidden, limitMessage)
 		return
 	}
 
@@ -91,17 +72,16 @@ func (s *Server) handleFileRequestCreate(w http.ResponseWriter, r *http.Request)
 
 	// Convert MB to bytes for storage
 	maxFileSize := int64(maxFileSizeMB) * 1024 * 1024
-
-	fileRequest := &models.FileRequest{
-		UserId:           user.Id,
-		Title:            title,
-		Message:          message,
-		ExpiresAt:        expiresAt,
-		IsActive:         true,
-		MaxFileSize:      maxFileSize,
-		AllowedFileTypes: allowedFileTypes,
+	if message := s.checkFileRequestMaxSize(maxFileSize); message != "" {
+		s.sendError(w, http.StatusBadRequest, message)
+		return
 	}
 
+	fileRequest.ExpiresAt = expiresAt
+	fileRequest.IsActive = true
+	fileRequest.MaxFileSize = maxFileSize
+	fileRequest.AllowedFileTypes = allowedFileTypes
+
 	if err := database.DB.CreateFileRequest(fileRequest); err != nil {
 		s.sendError(w, http.StatusInternalServerError, "Failed to create file request: "+err.Error())
 		return
@@ -112,142 +92,46 @@ func (s *Server) handleFileRequestCreate(w http.ResponseWriter, r *http.Request)
 	// Send invitation email if recipient email is provided
 	if recipientEmail != "" && strings.TrimSpace(recipientEmail) != "" {
 		go func() {
-			expireTime := time.Unix(fileRequest.ExpiresAt, 0).Format("2006-01-02 15:04")
-			subject := "Action Required: Please upload your file"
-
-			// Get branding for company name
-			brandingConfig, _ := database.DB.GetBrandingConfig()
-			companyName := brandingConfig["branding_company_name"]
-			if companyName == "" {
-				companyName = s.config.CompanyName
+			// Requests with their own brand name, team or color are emailed with it
+			data := email.NewTemplateData(s.config.CompanyName)
+			if fileRequest.BrandName != "" || fileRequest.TeamId > 0 || fileRequest.BrandColor != "" {
+				branding := s.fileRequestBranding(fileRequest)
+				if fileRequest.BrandName != "" || fileRequest.TeamId > 0 {
+					data.CompanyName = branding.Name
+				}
+				if fileRequest.BrandColor != "" {
+					data.SetColors(branding.PrimaryColor, branding.SecondaryColor)
+				}
 			}
-
-			htmlBody := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-	<meta charset="UTF-8">
-</head>
-<body style="margin: 0; padding: 0; font-family: Arial, Helvetica, sans-serif;">
-	<table width="100%%" cellpadding="0" cellspacing="0" style="background-color: #f0f0f0; padding: 20px 0;">
-		<tr>
-			<td align="center">
-				<table width="600" cellpadding="0" cellspacing="0" style="background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0,0,0,0.1);">
-					<!-- Header -->
-					<tr>
-						<td style="background-color: #1e3a5f; padding: 30px; text-align: center;">
-							<h1 style="color: #ffffff; margin: 0; font-size: 24px;">%s</h1>
-							<p style="color: #a0c4e8; margin: 10px 0 0 0; font-size: 14px;">Secure File Transfer</p>
-						</td>
-					</tr>
-
-					<!-- Main Content -->
-					<tr>
-						<td style="padding: 40px 30px;">
-							<!-- What is this -->
-							<div style="background-color: #e8f4fd; border-left: 4px solid #2563eb; padding: 15px; margin-bottom: 25px;">
-								<p style="margin: 0; color: #1e3a5f; font-size: 16px;">
-									<strong>What is this?</strong><br>
-									Someone needs you to upload a file to them securely. Click the green button below to upload your file.
-								</p>
-							</div>
-
-							<!-- Request Title -->
-							<h2 style="color: #1e3a5f; margin: 0 0 15px 0; font-size: 20px;">📋 %s</h2>
-							%s
-
-							<!-- BIG GREEN UPLOAD BUTTON -->
-							<table width="100%%" cellpadding="0" cellspacing="0" style="margin: 30px 0;">
-								<tr>
-									<td align="center">
-										<a href="%s" style="display: inline-block; background-color: #16a34a; color: #ffffff; padding: 20px 50px; text-decoration: none; border-radius: 8px; font-size: 20px; font-weight: bold; border: 3px solid #15803d; box-shadow: 0 4px 12px rgba(22, 163, 74, 0.4); text-transform: uppercase; letter-spacing: 1px;">
-											⬆️ UPLOAD FILE HERE
-										</a>
-									</td>
-								</tr>
-							</table>
-
-							<!-- Expiration Warning -->
-							<div style="background-color: #fef3c7; border: 2px solid #f59e0b; border-radius: 8px; padding: 20px; margin: 25px 0; text-align: center;">
-								<p style="margin: 0; color: #92400e; font-size: 16px; font-weight: bold;">
-									⏰ IMPORTANT: This link expires
-								</p>
-								<p style="margin: 10px 0 0 0; color: #78350f; font-size: 18px; font-weight: bold;">
-									%s
-								</p>
-							</div>
-
-							<!-- Backup Link -->
-							<div style="background-color: #f3f4f6; padding: 15px; border-radius: 6px; margin-top: 20px;">
-								<p style="margin: 0 0 8px 0; color: #374151; font-size: 12px;">
-									<strong>If the button doesn't work, copy this link:</strong>
-								</p>
-								<p style="margin: 0; word-break: break-all; font-size: 11px;">
-									<a href="%s" style="color: #2563eb;">%s</a>
-								</p>
-							</div>
-						</td>
-					</tr>
-
-					<!-- Footer -->
-					<tr>
-						<td style="background-color: #1e3a5f; padding: 20px; text-align: center;">
-							<p style="margin: 0; color: #a0c4e8; font-size: 12px;">
-								This is an automated message from %s
-							</p>
-						</td>
-					</tr>
-				</table>
-			</td>
-		</tr>
-	</table>
-</body>
-</html>
-			`, companyName,
-				html.EscapeString(title),
-				func() string {
-					if message != "" {
-						return fmt.Sprintf(`<p style="color: #374151; font-size: 15px; line-height: 1.6; margin: 0 0 15px 0;">%s</p>`, html.EscapeString(message))
-					}
-					return ""
-				}(),
-				uploadURL, expireTime, uploadURL, uploadURL, companyName)
-
-			textBody := fmt.Sprintf(`ACTION REQUIRED: Please Upload Your File
-============================================
-
-WHAT IS THIS?
-Someone needs you to upload a file to them securely.
-
-REQUEST: %s
-%s
-UPLOAD YOUR FILE HERE:
-%s
-
-⚠️ IMPORTANT: This link expires on %s
-
----
-This is an automated message from %s`,
-				title,
-				func() string {
-					if message != "" {
-						return fmt.Sprintf("\nMESSAGE: %s\n", message)
-					}
-					return ""
-				}(),
-				uploadURL, expireTime, companyName)
-
-			provider, err := email.GetActiveProvider(database.DB)
+			data.RecipientEmail = recipientEmail
+			data.SenderName = user.Name
+			data.SenderEmail = user.Email
+			data.Message = message
+			data.Link = uploadURL
+			data.RequestTitle = title
+			data.ExpiresAt = time.Unix(fileRequest.ExpiresAt, 0).Format("2006-01-02 15:04")
+			subject, htmlBody, textBody, err := email.RenderEmail(email.TemplateFileRequest, data)
 			if err != nil {
+				log.Printf("Failed to render file request invitation email: %v", err)
+				return
+			}
+
+			if _, err := email.GetActiveProvider(database.DB); err != nil {
 				log.Printf("Failed to get email provider: %v", err)
 				return
 			}
 
-			err = provider.SendEmail(recipientEmail, subject, htmlBody, textBody)
+			err = email.Enqueue(&database.QueuedEmail{
+				Kind:      database.EmailKindFileRequest,
+				Recipient: recipientEmail,
+				Subject:   subject,
+				HTMLBody:  htmlBody,
+				TextBody:  textBody,
+			})
 			if err != nil {
-				log.Printf("Failed to send file request invitation email to %s: %v", recipientEmail, err)
+				log.Printf("Failed to queue file request invitation email to %s: %v", recipientEmail, err)
 			} else {
-				log.Printf("File request invitation email sent to %s", recipientEmail)
+				log.Printf("File request invitation email to %s queued", recipientEmail)
 			}
 		}()
 	}
@@ -261,6 +145,9 @@ This is an automated message from %s`,
 		"request_token": fileRequest.RequestToken,
 		"upload_url":    uploadURL,
 		"expires_at":    fileRequest.ExpiresAt,
+		"brand_name":    fileRequest.BrandName,
+		"brand_color":   fileRequest.BrandColor,
+		"team_id":       fileRequest.TeamId,
 	})
 }
 
@@ -305,15 +192,57 @@ func (s *Server) handleFileRequestList(w http.ResponseWriter, r *http.Request) {
 			"is_expired":         req.IsExpired(),
 			"max_file_size_mb":   req.MaxFileSize / (1024 * 1024),
 			"allowed_file_types": req.AllowedFileTypes,
+			"brand_name":         req.BrandName,
+			"brand_color":        req.BrandColor,
+			"team_id":            req.TeamId,
 		})
 	}
 
+	activeCount, err := database.DB.CountActiveFileRequestsByUser(user.Id)
+	if err != nil {
+		log.Printf("Warning: Failed to count active file requests for user %d: %v", user.Id, err)
+	}
+
 	s.sendJSON(w, http.StatusOK, map[string]interface{}{
-		"requests": requestList,
-		"total":    len(requestList),
+		"requests":     requestList,
+		"total":        len(requestList),
+		"active_count": activeCount,
+		"limit":        fileRequestLimitForUser(user),
 	})
 }
 
+// fileRequestLimitForUser returns the maximum number of active upload requests
+// the user may have, or 0 if unlimited
+func fileRequestLimitForUser(user *models.User) int {
+	limit := database.DB.GetConfigInt("max_active_file_requests", 0)
+	if limit <= 0 {
+		return 0
+	}
+	if user.IsAdmin() {
+		if exempt, _ := database.DB.GetConfigValue("file_request_limit_exempt_admins"); exempt == "true" {
+			return 0
+		}
+	}
+	return limit
+}
+
+// checkFileRequestLimit returns a message explaining why the user may not create
+// another upload request, or "" if they are below their limit
+func checkFileRequestLimit(user *models.User) (string, error) {
+	limit := fileRequestLimitForUser(user)
+	if limit <= 0 {
+		return "", nil
+	}
+	activeCount, err := database.DB.CountActiveFileRequestsByUser(user.Id)
+	if err != nil {
+		return "", err
+	}
+	if activeCount >= limit {
+		return fmt.Sprintf("You have reached the maximum of %d active upload requests. Delete one or wait for it to expire before creating a new one.", limit), nil
+	}
+	return "", nil
+}
+
 // handleFileRequestDelete deletes a file request
 func (s *Server) handleFileRequestDelete(w http.ResponseWriter, r *http.Request) {
 	user, ok := userFromContext(r.Context())
@@ -393,8 +322,8 @@ func (s *Server) handleUploadRequestPage(w http.ResponseWriter, r *http.Request)
 
 	// Check if already used
 	if fileRequest.IsUsed() {
-		clientIP := getClientIP(r)
-		s.renderUploadRequestUsed(w, fileRequest, clientIP)
+		ip := clientIP(r)
+		s.renderUploadRequestUsed(w, fileRequest, ip)
 		return
 	}
 
@@ -433,8 +362,8 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 
 	// Check if already used
 	if fileRequest.IsUsed() {
-		clientIP := getClientIP(r)
-		s.sendError(w, http.StatusGone, fmt.Sprintf("This upload link has already been used from IP: %s", clientIP))
+		ip := clientIP(r)
+		s.sendError(w, http.StatusGone, fmt.Sprintf("This upload link has already been used from IP: %s", ip))
 		return
 	}
 
@@ -451,10 +380,44 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// The upload hours of the request owner apply to uploads into their account
+	if message := uploadWindowClosedMessage(user); message != "" {
+		log.Printf("❌ Upload rejected: File request %d of user %d (%s) | Reason: Outside upload hours", fileRequest.Id, user.Id, user.Email)
+		s.sendError(w, http.StatusForbidden, message)
+		return
+	}
+
+	// The upload counts against the request owner's quota. The uploader isn't told the
+	// owner's numbers.
+	if !limitUploadBody(w, r, user) {
+		log.Printf("❌ Upload rejected: File request %d of user %d (%s) | Reason: Insufficient storage quota", fileRequest.Id, user.Id, user.Email)
+		s.sendError(w, http.StatusRequestEntityTooLarge, requestOwnerQuotaMessage)
+		return
+	}
+
+	// Files over the request's size limit are cut off while they are sent instead of being
+	// received in full first. The form parser removes what it spilled to disk when it fails.
+	maxFileSize := s.fileRequestMaxBytes(fileRequest)
+	if !limitFileRequestBody(w, r, maxFileSize) {
+		log.Printf("❌ Upload rejected: File request %d of user %d (%s) | Reason: Announced size %d bytes exceeds the %s limit", fileRequest.Id, user.Id, user.Email, r.ContentLength, database.FormatFileSize(maxFileSize))
+		s.sendFileTooLarge(w, maxFileSize)
+		return
+	}
+
 	// Parse multipart form (32MB max memory buffer, rest spills to disk)
 	// This prevents loading entire large files into RAM
 	err = r.ParseMultipartForm(32 << 20)
 	if err != nil {
+		if bodyLimitOf(err) == maxFileSize+uploadFormOverhead {
+			log.Printf("❌ Upload aborted: File request %d of user %d (%s) | Reason: Upload exceeded the %s limit while it was sent", fileRequest.Id, user.Id, user.Email, database.FormatFileSize(maxFileSize))
+			s.sendFileTooLarge(w, maxFileSize)
+			return
+		}
+		if isBodyTooLarge(err) {
+			log.Printf("❌ Upload aborted: File request %d of user %d (%s) | Reason: Upload exceeded the storage quota while it was sent", fileRequest.Id, user.Id, user.Email)
+			s.sendError(w, http.StatusRequestEntityTooLarge, requestOwnerQuotaMessage)
+			return
+		}
 		s.sendError(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
 		return
 	}
@@ -466,6 +429,13 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 	}
 	defer file.Close()
 
+	// The server's allowed and blocked file types apply to uploads through requests too
+	if err := checkUploadedFileType(header.Filename, file); err != nil {
+		log.Printf("❌ Upload rejected: '%s' | File request %d of user %d (%s) | Reason: %v", header.Filename, fileRequest.Id, user.Id, user.Email, err)
+		s.sendError(w, uploadFileTypeStatus(err), err.Error())
+		return
+	}
+
 	// Get optional comment from uploader
 	comment := r.FormValue("comment")
 	if len(comment) > 1000 {
@@ -474,16 +444,17 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 
 	// Check file size
 	fileSize := header.Size
-	if fileRequest.MaxFileSize > 0 && fileSize > fileRequest.MaxFileSize {
-		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("File too large. Max size: %d MB", fileRequest.MaxFileSize/(1024*1024)))
+	if fileSize > maxFileSize {
+		log.Printf("❌ Upload rejected: '%s' | File request %d of user %d (%s) | Reason: %d bytes exceeds the %s limit", header.Filename, fileRequest.Id, user.Id, user.Email, fileSize, database.FormatFileSize(maxFileSize))
+		s.sendFileTooLarge(w, maxFileSize)
 		return
 	}
 
-	fileSizeMB := fileSize / (1024 * 1024)
+	fileSizeMB := database.StorageMB(fileSize)
 
 	// Check quota of request owner
 	if !user.HasStorageSpace(fileSizeMB) {
-		s.sendError(w, http.StatusBadRequest, "Request owner has insufficient storage quota")
+		s.sendError(w, http.StatusRequestEntityTooLarge, requestOwnerQuotaMessage)
 		return
 	}
 
@@ -494,33 +465,25 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Save file to disk
-	uploadPath := filepath.Join(s.config.UploadsDir, fileID)
-	dst, err := os.Create(uploadPath)
-	if err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Failed to save file")
-		return
-	}
-	defer dst.Close()
-
-	_, err = io.Copy(dst, file)
-	if err != nil {
-		os.Remove(uploadPath)
+	// Store the file, hashing it while it is written
+	hasher := newUploadHasher()
+	if err := storage.Files.Put(fileID, hasher.tee(file)); err != nil {
+		log.Printf("File request upload failed: could not store %s: %v", header.Filename, err)
 		s.sendError(w, http.StatusInternalServerError, "Failed to write file")
 		return
 	}
-
-	// Calculate SHA1
-	sha1Hash, err := database.CalculateFileSHA1(uploadPath)
-	if err != nil {
-		log.Printf("Warning: Could not calculate SHA1: %v", err)
-		sha1Hash = ""
+	sha1Hash := hasher.SHA1()
+	stripMetadata := wantsMetadataStripping("")
+	sha256Hash := hasher.SHA256()
+	if metadataStrippingApplies(header.Filename, fileSize, stripMetadata) {
+		sha256Hash = ""
 	}
 
 	// Default expiration: 30 days
 	expireTime := time.Now().Add(30 * 24 * time.Hour)
 	expireAt := expireTime.Unix()
 	expireAtString := expireTime.Format("2006-01-02 15:04")
+	expireAt, expireAtString, _ = limitFileExpiry(expireAt, expireAtString, false)
 
 	// Save file metadata - file belongs to the request owner
 	fileInfo := &database.FileInfo{
@@ -528,6 +491,7 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 		Name:               header.Filename,
 		Size:               database.FormatFileSize(fileSize),
 		SHA1:               sha1Hash,
+		SHA256:             sha256Hash,
 		ContentType:        header.Header.Get("Content-Type"),
 		ExpireAtString:     expireAtString,
 		ExpireAt:           expireAt,
@@ -543,30 +507,46 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := database.DB.SaveFile(fileInfo); err != nil {
-		os.Remove(uploadPath)
+		storage.Files.Delete(fileID)
 		s.sendError(w, http.StatusInternalServerError, "Failed to save file metadata: "+err.Error())
 		return
 	}
 
+	// Scan for malware in the background; downloads wait for the result
+	s.queueVirusScan(fileInfo.Id)
+
+	// Remove image metadata in the background if the deployment does so by default. The SHA-256
+	// is calculated again afterwards.
+	s.queueMetadataStripping(fileInfo.Id, fileInfo.Name, fileInfo.SizeBytes, stripMetadata)
+
+	// Convert HEIC and similar images for preview in the background
+	s.queueImageConversion(fileInfo.Id, fileInfo.Name, fileInfo.SizeBytes)
+
 	// Update user storage
-	newStorageUsed := user.StorageUsedMB + fileSizeMB
-	if err := database.DB.UpdateUserStorage(user.Id, newStorageUsed); err != nil {
+	if err := database.DB.AdjustUserStorage(user.Id, fileSizeMB); err != nil {
 		log.Printf("Warning: Could not update user storage: %v", err)
 	}
 
 	// Mark file request as used (single-use link)
-	clientIP := getClientIP(r)
-	if err := database.DB.MarkFileRequestAsUsed(fileRequest.Id, clientIP); err != nil {
+	ip := clientIP(r)
+	if err := database.DB.MarkFileRequestAsUsed(fileRequest.Id, ip); err != nil {
 		log.Printf("Warning: Could not mark file request as used: %v", err)
 	}
 
 	// Send email notification to request owner
 	go func() {
-		err := email.SendFileUploadNotification(fileRequest, fileInfo, clientIP, s.getPublicURL(), user.Email)
+		if !database.DB.NotifyUser(user.Id, database.NotifyFileRequests, "File received", fileInfo.Name+" was uploaded through your request \""+fileRequest.Title+"\"", "/dashboard") {
+			return
+		}
+		if _, err := email.GetActiveProvider(database.DB); err != nil {
+			log.Printf("Email not configured, skipping upload notification: %v", err)
+			return
+		}
+		err := email.QueueFileUploadNotification(fileRequest, fileInfo, ip, s.getPublicURL(), user.Email)
 		if err != nil {
-			log.Printf("Failed to send upload notification email: %v", err)
+			log.Printf("Failed to queue upload notification email: %v", err)
 		} else {
-			log.Printf("Upload notification email sent to %s", user.Email)
+			log.Printf("Upload notification email to %s queued", user.Email)
 		}
 	}()
 
@@ -584,16 +564,18 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 			"file_id":       fileID,
 			"file_name":     header.Filename,
 			"file_size":     fileSize,
-			"uploader_ip":   clientIP,
+			"uploader_ip":   ip,
 			"has_comment":   comment != "",
 		}),
-		IPAddress: clientIP,
+		IPAddress: ip,
 		UserAgent: r.UserAgent(),
 		Success:   true,
 	})
+	// The uploader is anonymous, so the event names the request owner who receives the file
+	webhooks.Dispatch(database.WebhookEventFileUploaded, fileID, header.Filename, user.Email, ip)
 
 	log.Printf("File uploaded via request %s: %s (%s) for user %d - link now consumed by IP %s",
-		fileRequest.Title, header.Filename, database.FormatFileSize(fileSize), user.Id, clientIP)
+		fileRequest.Title, header.Filename, database.FormatFileSize(fileSize), user.Id, ip)
 
 	s.sendJSON(w, http.StatusOK, map[string]interface{}{
 		"success":   true,
@@ -609,24 +591,28 @@ func (s *Server) handleUploadRequestSubmit(w http.ResponseWriter, r *http.Reques
 func (s *Server) renderUploadRequestPage(w http.ResponseWriter, fileRequest *models.FileRequest) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	maxFileSizeMB := fileRequest.MaxFileSize / (1024 * 1024)
-	if maxFileSizeMB == 0 {
-		maxFileSizeMB = 100 // Default
-	}
+	maxFileSize := s.fileRequestMaxBytes(fileRequest)
+
+	branding := s.fileRequestBranding(fileRequest)
+	brandName := html.EscapeString(branding.Name)
+	requestTitle := html.EscapeString(fileRequest.Title)
+	allowedTypes := html.EscapeString(fileRequest.AllowedFileTypes)
+	messageHTML := fileRequestMessageHTML(fileRequest.Message)
+	teamName := html.EscapeString(branding.TeamName)
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
-    <title>Upload File - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    <title>Upload File - ` + brandName + `</title>
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-            background: linear-gradient(135deg, ` + s.getPrimaryColor() + ` 0%, ` + s.getSecondaryColor() + ` 100%);
+            background: linear-gradient(135deg, ` + branding.PrimaryColor + ` 0%, ` + branding.SecondaryColor + ` 100%);
             min-height: 100vh;
             display: flex;
             align-items: center;
@@ -646,7 +632,7 @@ func (s *Server) renderUploadRequestPage(w http.ResponseWriter, fileRequest *mod
             margin-bottom: 30px;
         }
         .logo h1 {
-            color: ` + s.getPrimaryColor() + `;
+            color: ` + branding.PrimaryColor + `;
             font-size: 28px;
             margin-bottom: 8px;
         }
@@ -689,12 +675,12 @@ func (s *Server) renderUploadRequestPage(w http.ResponseWriter, fileRequest *mod
             cursor: pointer;
         }
         input[type="file"]:hover {
-            border-color: ` + s.getPrimaryColor() + `;
+            border-color: ` + branding.PrimaryColor + `;
         }
         .btn {
             width: 100%;
             padding: 14px;
-            background: ` + s.getPrimaryColor() + `;
+            background: ` + branding.PrimaryColor + `;
             color: white;
             border: none;
             border-radius: 6px;
@@ -748,7 +734,7 @@ func (s *Server) renderUploadRequestPage(w http.ResponseWriter, fileRequest *mod
         }
         .progress-bar {
             height: 100%;
-            background: ` + s.getPrimaryColor() + `;
+            background: ` + branding.PrimaryColor + `;
             width: 0%;
             transition: width 0.3s;
             display: flex;
@@ -763,20 +749,24 @@ func (s *Server) renderUploadRequestPage(w http.ResponseWriter, fileRequest *mod
 <body>
     <div class="upload-container">
         <div class="logo">
-            <h1>` + s.config.CompanyName + `</h1>
+            <h1>` + brandName + `</h1>
         </div>
 
         <div class="request-info">
-            <h2>📤 ` + fileRequest.Title + `</h2>`
+            <h2>📤 ` + requestTitle + `</h2>`
+
+	if teamName != "" {
+		html += `<p style="font-size: 14px; color: #888;">On behalf of ` + teamName + `</p>`
+	}
 
 	if fileRequest.Message != "" {
-		html += `<p>` + fileRequest.Message + `</p>`
+		html += `<p style="margin-top: 8px;">` + messageHTML + `</p>`
 	}
 
-	html += `<p style="margin-top: 12px;"><strong>Max file size:</strong> ` + fmt.Sprintf("%d MB", maxFileSizeMB) + `</p>`
+	html += `<p style="margin-top: 12px;"><strong>Max file size:</strong> ` + database.FormatFileSize(maxFileSize) + `</p>`
 
 	if fileRequest.AllowedFileTypes != "" {
-		html += `<p><strong>Allowed types:</strong> ` + fileRequest.AllowedFileTypes + `</p>`
+		html += `<p><strong>Allowed types:</strong> ` + allowedTypes + `</p>`
 	}
 
 	html += `
@@ -834,6 +824,13 @@ func (s *Server) renderUploadRequestPage(w http.ResponseWriter, fileRequest *mod
                 return;
             }
 
+            // The server cuts off larger files while they are sent, no need to send them first
+            if (fileInput.files[0].size > ` + strconv.FormatInt(maxFileSize, 10) + `) {
+                errorMsg.textContent = '` + template.JSEscapeString(fileTooLargeMessage(maxFileSize)) + `';
+                errorMsg.style.display = 'block';
+                return;
+            }
+
             const formData = new FormData();
             formData.append('file', fileInput.files[0]);
             const commentField = document.getElementById('comment');
@@ -900,19 +897,22 @@ func (s *Server) renderUploadRequestPage(w http.ResponseWriter, fileRequest *mod
 func (s *Server) renderUploadRequestExpired(w http.ResponseWriter, fileRequest *models.FileRequest) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	branding := s.fileRequestBranding(fileRequest)
+	brandName := html.EscapeString(branding.Name)
+
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
-    <title>Request Expired - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    <title>Request Expired - ` + brandName + `</title>
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-            background: linear-gradient(135deg, ` + s.getPrimaryColor() + ` 0%, ` + s.getSecondaryColor() + ` 100%);
+            background: linear-gradient(135deg, ` + branding.PrimaryColor + ` 0%, ` + branding.SecondaryColor + ` 100%);
             min-height: 100vh;
             display: flex;
             align-items: center;
@@ -929,7 +929,7 @@ func (s *Server) renderUploadRequestExpired(w http.ResponseWriter, fileRequest *
             text-align: center;
         }
         .logo h1 {
-            color: ` + s.getPrimaryColor() + `;
+            color: ` + branding.PrimaryColor + `;
             font-size: 32px;
             margin-bottom: 10px;
         }
@@ -952,7 +952,7 @@ func (s *Server) renderUploadRequestExpired(w http.ResponseWriter, fileRequest *
 <body>
     <div class="container">
         <div class="logo">
-            <h1>` + s.config.CompanyName + `</h1>
+            <h1>` + brandName + `</h1>
         </div>
         <div class="expired-icon">⏰</div>
         <h2>Upload Link Expired</h2>
@@ -969,19 +969,22 @@ func (s *Server) renderUploadRequestExpired(w http.ResponseWriter, fileRequest *
 func (s *Server) renderUploadRequestUsed(w http.ResponseWriter, fileRequest *models.FileRequest, clientIP string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	branding := s.fileRequestBranding(fileRequest)
+	brandName := html.EscapeString(branding.Name)
+
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
-    <title>Link Already Used - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    <title>Link Already Used - ` + brandName + `</title>
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-            background: linear-gradient(135deg, ` + s.getPrimaryColor() + ` 0%, ` + s.getSecondaryColor() + ` 100%);
+            background: linear-gradient(135deg, ` + branding.PrimaryColor + ` 0%, ` + branding.SecondaryColor + ` 100%);
             min-height: 100vh;
             display: flex;
             align-items: center;
@@ -998,7 +1001,7 @@ func (s *Server) renderUploadRequestUsed(w http.ResponseWriter, fileRequest *mod
             text-align: center;
         }
         .logo h1 {
-            color: ` + s.getPrimaryColor() + `;
+            color: ` + branding.PrimaryColor + `;
             font-size: 32px;
             margin-bottom: 10px;
         }
@@ -1032,13 +1035,13 @@ func (s *Server) renderUploadRequestUsed(w http.ResponseWriter, fileRequest *mod
 <body>
     <div class="container">
         <div class="logo">
-            <h1>` + s.config.CompanyName + `</h1>
+            <h1>` + brandName + `</h1>
         </div>
         <div class="used-icon">🔒</div>
         <h2>Upload Link Already Used</h2>
         <p>This upload link has already been used and is no longer accepting files.</p>
         <div class="ip-info">
-            This link was used from IP: ` + fileRequest.UsedByIP + `
+            This link was used from IP: ` + html.EscapeString(fileRequest.UsedByIP) + `
         </div>
         <p style="margin-top: 15px;">Upload request links are single-use for security purposes. Please contact the person who sent you this link and ask them to create a new upload request.</p>
     </div>
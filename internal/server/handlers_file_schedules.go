@@ -0,0 +1,166 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// scheduleDateTimeLayout matches the value of an HTML datetime-local input
+const scheduleDateTimeLayout = "2006-01-02T15:04"
+
+// fileScheduleUnavailableMessage returns why a file can't be downloaded right now
+// because of its availability schedule, or "" if it is available
+func fileScheduleUnavailableMessage(fileInfo *database.FileInfo) string {
+	schedule, err := database.DB.GetFileSchedule(fileInfo.Id)
+	if err != nil || schedule.IsEmpty() {
+		return ""
+	}
+
+	available, next := schedule.Availability(time.Now())
+	if available {
+		return ""
+	}
+	if next.IsZero() {
+		return "This file is no longer available for download."
+	}
+	return "This file is available from " + next.Format("Monday, January 2, 2006 at 15:04 MST") + "."
+}
+
+// fileNotYetLiveMessage returns when a file whose links aren't live yet becomes available, or
+// "" if they are live
+func fileNotYetLiveMessage(fileInfo *database.FileInfo) string {
+	if !fileInfo.IsScheduled(time.Now()) {
+		return ""
+	}
+	activateAt := time.Unix(fileInfo.ActivateAt, 0).In(database.ServerLocation())
+	return "This file is not available yet. It can be downloaded from " + activateAt.Format("Monday, January 2, 2006 at 15:04 MST") + "."
+}
+
+// requestedActivation parses the time an uploaded file's links go live, a datetime-local value
+// in the server's timezone. "" or a time that has passed means right away (0).
+func requestedActivation(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	t, err := time.ParseInLocation(scheduleDateTimeLayout, value, database.ServerLocation())
+	if err != nil {
+		return 0, errors.New("use the format YYYY-MM-DDTHH:MM")
+	}
+	if !t.After(time.Now()) {
+		return 0, nil
+	}
+	return t.Unix(), nil
+}
+
+// checkRequestedActivation parses the activation time of an upload and checks it against the
+// expiry the upload asks for, after the deployment's maximum expiry is applied
+func checkRequestedActivation(value string, requestedExpireAt int64, unlimitedTime bool) (int64, error) {
+	activateAt, err := requestedActivation(value)
+	if err != nil || activateAt == 0 {
+		return activateAt, err
+	}
+	if unlimitedTime {
+		requestedExpireAt = 0
+	}
+	expireAt, _, unlimitedTime := limitFileExpiry(requestedExpireAt, "", unlimitedTime)
+	if err := database.ValidateActivation(activateAt, expireAt, unlimitedTime); err != nil {
+		return 0, err
+	}
+	return activateAt, nil
+}
+
+// fileScheduleFromRequest reads the availability schedule fields of the file edit form
+func fileScheduleFromRequest(r *http.Request) (*database.FileSchedule, error) {
+	schedule := &database.FileSchedule{}
+	if r.FormValue("schedule_enabled") != "true" {
+		return schedule, nil
+	}
+
+	schedule.Timezone = strings.TrimSpace(r.FormValue("schedule_timezone"))
+	schedule.DailyStart = strings.TrimSpace(r.FormValue("daily_start"))
+	schedule.DailyEnd = strings.TrimSpace(r.FormValue("daily_end"))
+	schedule.Days = database.NormalizeScheduleDays(r.FormValue("schedule_days"))
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	loc := schedule.Location()
+	if value := r.FormValue("available_from"); value != "" {
+		t, err := time.ParseInLocation(scheduleDateTimeLayout, value, loc)
+		if err != nil {
+			return nil, errors.New("invalid availability start date")
+		}
+		schedule.AvailableFrom = t.Unix()
+	}
+	if value := r.FormValue("available_until"); value != "" {
+		t, err := time.ParseInLocation(scheduleDateTimeLayout, value, loc)
+		if err != nil {
+			return nil, errors.New("invalid availability end date")
+		}
+		schedule.AvailableUntil = t.Unix()
+	}
+
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	if schedule.IsEmpty() {
+		return nil, errors.New("set a date range, daily hours or days for the schedule")
+	}
+	return schedule, nil
+}
+
+// handleFileSchedule returns a file's availability schedule for the edit dialog
+func (s *Server) handleFileSchedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	fileInfo, err := database.DB.GetFileByID(r.URL.Query().Get("file_id"))
+	if err != nil {
+		s.sendError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if fileInfo.UserId != user.Id && !user.IsAdmin() {
+		s.sendError(w, http.StatusForbidden, "Not authorized to view this file")
+		return
+	}
+
+	schedule, err := database.DB.GetFileSchedule(fileInfo.Id)
+	if err != nil {
+		log.Printf("Error loading schedule for file %s: %v", fileInfo.Id, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load schedule")
+		return
+	}
+
+	loc := schedule.Location()
+	formatDateTime := func(unix int64) string {
+		if unix == 0 {
+			return ""
+		}
+		return time.Unix(unix, 0).In(loc).Format(scheduleDateTimeLayout)
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":          !schedule.IsEmpty(),
+		"available_from":   formatDateTime(schedule.AvailableFrom),
+		"available_until":  formatDateTime(schedule.AvailableUntil),
+		"daily_start":      schedule.DailyStart,
+		"daily_end":        schedule.DailyEnd,
+		"days":             schedule.Days,
+		"timezone":         schedule.Timezone,
+		"default_timezone": database.ServerLocation().String(),
+	})
+}
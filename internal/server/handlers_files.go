@@ -8,10 +8,10 @@ package server
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"html"
 	"html/template"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -23,7 +23,10 @@ import (
 	"github.com/Frimurare/WulfVault/internal/auth"
 	"github.com/Frimurare/WulfVault/internal/database"
 	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/i18n"
 	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+	"github.com/Frimurare/WulfVault/internal/webhooks"
 )
 
 // handleUpload handles file upload
@@ -39,6 +42,13 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse uploads outside the upload hours before any data is read
+	if message := uploadWindowClosedMessage(user); message != "" {
+		log.Printf("❌ Upload rejected: User: %d (%s) | Reason: Outside upload hours", user.Id, user.Email)
+		s.sendError(w, http.StatusForbidden, message)
+		return
+	}
+
 	// Get session cookie to track active transfer
 	sessionCookie, err := r.Cookie("session")
 	if err == nil {
@@ -47,10 +57,25 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		defer s.markTransferInactive(sessionCookie.Value)
 	}
 
+	// Refuse uploads larger than the remaining quota before any data is read, and stop reading
+	// one that turns out larger than it announced
+	user = currentUploader(user)
+	if !limitUploadBody(w, r, user) {
+		log.Printf("❌ Upload rejected: User: %d (%s) | Reason: Insufficient storage quota (request of %d bytes, %d MB / %d MB used)",
+			user.Id, user.Email, r.ContentLength, user.StorageUsedMB, user.StorageQuotaMB)
+		s.sendQuotaExceeded(w, user, 0)
+		return
+	}
+
 	// Parse multipart form (32MB max memory buffer, rest spills to disk)
 	// This prevents loading entire large files into RAM
 	err = r.ParseMultipartForm(32 << 20)
 	if err != nil {
+		if isBodyTooLarge(err) {
+			log.Printf("❌ Upload aborted: User: %d (%s) | Reason: Upload exceeded the storage quota while it was sent", user.Id, user.Email)
+			s.sendQuotaExceeded(w, user, 0)
+			return
+		}
 		s.sendError(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
 		return
 	}
@@ -63,16 +88,24 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	// Get client IP for logging
-	clientIP := getClientIP(r)
+	ip := clientIP(r)
 
 	// Log upload start
 	log.Printf("📤 Upload started: '%s' (%.1f MB) from IP: %s | User: %s (%d)",
 		header.Filename,
 		float64(header.Size)/(1024*1024),
-		clientIP,
+		ip,
 		user.Email,
 		user.Id)
 
+	// Refuse file types the server settings don't allow, by name and by content
+	if err := checkUploadedFileType(header.Filename, file); err != nil {
+		log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: %v",
+			header.Filename, ip, user.Email, user.Id, err)
+		s.sendError(w, uploadFileTypeStatus(err), err.Error())
+		return
+	}
+
 	// Get expiration settings from form
 	expireDate := r.FormValue("expire_date")
 	downloadsLimit, _ := strconv.Atoi(r.FormValue("downloads_limit"))
@@ -107,21 +140,66 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// An expire_date (end of that day) takes precedence over expiration_days (from now)
+	requestedExpireAt, err := requestedFileExpiry(expireDate, r.FormValue("expiration_days"))
+	if err != nil && !unlimitedTime {
+		s.sendError(w, http.StatusBadRequest, "Invalid expiration date: "+err.Error())
+		return
+	}
+
+	// The links of an embargoed file only go live at activate_at, which must be before it expires
+	activateAt, err := checkRequestedActivation(r.FormValue("activate_at"), requestedExpireAt, unlimitedTime)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid activation time: "+err.Error())
+		return
+	}
+
+	// A custom slug gives the file readable links next to its ID
+	slug, err := checkRequestedSlug(r.FormValue("slug"), "")
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid link name: "+err.Error())
+		return
+	}
+
+	splashTemplate := r.FormValue("splash_template")
+	if splashTemplate != "" && !database.IsValidSplashTemplate(splashTemplate) {
+		s.sendError(w, http.StatusBadRequest, "Invalid splash page template")
+		return
+	}
+
+	// Enforce the deployment-wide cap on public (non-auth) links
+	if !requireAuth {
+		if reached, limit := publicLinkLimitReached(); reached {
+			log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: Public link limit reached (%d)",
+				header.Filename, ip, user.Email, user.Id, limit)
+			s.sendError(w, http.StatusForbidden, publicLinkLimitMessage(limit))
+			return
+		}
+	}
+
 	// Check file size
 	fileSize := header.Size
-	fileSizeMB := fileSize / (1024 * 1024)
+	fileSizeMB := database.StorageMB(fileSize)
 
 	// Check quota
 	if !user.HasStorageSpace(fileSizeMB) {
 		log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: Insufficient storage quota (needs %d MB, has %d MB / %d MB)",
 			header.Filename,
-			clientIP,
+			ip,
 			user.Email,
 			user.Id,
 			fileSizeMB,
 			user.StorageQuotaMB-user.StorageUsedMB,
 			user.StorageQuotaMB)
-		s.sendError(w, http.StatusBadRequest, "Insufficient storage quota")
+		s.sendQuotaExceeded(w, user, fileSize)
+		return
+	}
+
+	// Teams the file is shared with must have room for it too
+	if err := checkUploadTeamQuotas(user, teamIds, fileSize); err != nil {
+		log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: %v",
+			header.Filename, ip, user.Email, user.Id, err)
+		s.sendError(w, http.StatusRequestEntityTooLarge, "Not enough team storage space: "+strings.TrimPrefix(err.Error(), database.ErrTeamQuotaExceeded.Error()+": "))
 		return
 	}
 
@@ -129,59 +207,40 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	fileID, err := generateFileID()
 	if err != nil {
 		log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: Failed to generate file ID - %v",
-			header.Filename, clientIP, user.Email, user.Id, err)
+			header.Filename, ip, user.Email, user.Id, err)
 		s.sendError(w, http.StatusInternalServerError, "Failed to generate file ID")
 		return
 	}
 
-	// Save file to disk
-	uploadPath := filepath.Join(s.config.UploadsDir, fileID)
-	dst, err := os.Create(uploadPath)
-	if err != nil {
-		log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: Failed to create file - %v",
-			header.Filename, clientIP, user.Email, user.Id, err)
-		s.sendError(w, http.StatusInternalServerError, "Failed to save file")
-		return
-	}
-	defer dst.Close()
-
-	_, err = io.Copy(dst, file)
-	if err != nil {
-		os.Remove(uploadPath)
+	// Store the file, hashing it while it is written
+	hasher := newUploadHasher()
+	if err := storage.Files.Put(fileID, hasher.tee(file)); err != nil {
 		log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: Failed to write file data - %v",
-			header.Filename, clientIP, user.Email, user.Id, err)
+			header.Filename, ip, user.Email, user.Id, err)
 		s.sendError(w, http.StatusInternalServerError, "Failed to write file")
 		return
 	}
-
-	// Calculate SHA1
-	sha1Hash, err := database.CalculateFileSHA1(uploadPath)
-	if err != nil {
-		log.Printf("Warning: Could not calculate SHA1: %v", err)
-		sha1Hash = ""
+	sha1Hash := hasher.SHA1()
+	stripMetadata := wantsMetadataStripping(r.FormValue("strip_metadata"))
+	sha256Hash := hasher.SHA256()
+	if metadataStrippingApplies(header.Filename, fileSize, stripMetadata) {
+		sha256Hash = ""
 	}
 
 	// Calculate expiration from date
 	var expireAt int64
 	var expireAtString string
 
-	if !unlimitedTime && expireDate != "" {
-		// Parse date from calendar (format: YYYY-MM-DD)
-		expireTime, err := time.Parse("2006-01-02", expireDate)
-		if err == nil {
-			// Set to end of day (23:59:59)
-			expireTime = expireTime.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-			expireAt = expireTime.Unix()
-			expireAtString = expireTime.Format("2006-01-02 15:04")
-		} else {
-			log.Printf("Warning: Could not parse expiration date '%s': %v", expireDate, err)
-			// Default to 7 days if parse fails
-			expireTime := time.Now().Add(7 * 24 * time.Hour)
-			expireAt = expireTime.Unix()
-			expireAtString = expireTime.Format("2006-01-02 15:04")
+	if !unlimitedTime {
+		expireAt = requestedExpireAt
+		if expireAt > 0 {
+			expireAtString = database.FormatExpireAt(expireAt)
 		}
 	}
 
+	// Apply the deployment's maximum file expiry
+	expireAt, expireAtString, unlimitedTime = limitFileExpiry(expireAt, expireAtString, unlimitedTime)
+
 	// Handle downloads limit
 	if unlimitedDownloads {
 		downloadsLimit = 999999 // Set high value for unlimited
@@ -195,6 +254,7 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		Name:               header.Filename,
 		Size:               database.FormatFileSize(fileSize),
 		SHA1:               sha1Hash,
+		SHA256:             sha256Hash,
 		FilePasswordPlain:  filePassword,
 		ContentType:        header.Header.Get("Content-Type"),
 		ExpireAtString:     expireAtString,
@@ -208,21 +268,66 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		UnlimitedDownloads: unlimitedDownloads,
 		UnlimitedTime:      unlimitedTime,
 		RequireAuth:        requireAuth,
+		BurnAfterDownload:  r.FormValue("burn_after_download") == "true",
+		ActivateAt:         activateAt,
 	}
 
 	if err := database.DB.SaveFile(fileInfo); err != nil {
-		os.Remove(uploadPath)
+		storage.Files.Delete(fileID)
 		log.Printf("❌ Upload failed: '%s' from IP: %s | User: %s (%d) | Reason: Failed to save file metadata - %v",
-			header.Filename, clientIP, user.Email, user.Id, err)
+			header.Filename, ip, user.Email, user.Id, err)
 		s.sendError(w, http.StatusInternalServerError, "Failed to save file metadata: "+err.Error())
 		return
 	}
 
+	// Uploaders may override the default download log privacy
+	if value := r.FormValue("private_download_log"); value != "" {
+		if err := database.DB.SetFilePrivateDownloadLog(fileID, value == "true"); err != nil {
+			log.Printf("Warning: Could not set download log privacy: %v", err)
+		}
+	}
+
+	// Recipients must accept the download terms first
+	if r.FormValue("require_terms") == "true" {
+		if err := database.DB.SetFileTermsRequired(fileID, true); err != nil {
+			log.Printf("Warning: Could not set download terms requirement: %v", err)
+		}
+	}
+
+	if splashTemplate != "" {
+		if err := database.DB.SetFileSplashTemplate(fileID, splashTemplate); err != nil {
+			log.Printf("Warning: Could not set splash page template: %v", err)
+		}
+	}
+
+	if slug != "" {
+		if err := database.DB.SetFileSlug(fileID, slug); err != nil {
+			log.Printf("Warning: Could not set link name %q of %s: %v", slug, fileID, err)
+			slug = ""
+		}
+	}
+
+	// Download emails to the owner are on unless the uploader turned them off
+	if r.FormValue("notify_on_download") == "false" {
+		if err := database.DB.SetFileNotifyOnDownload(fileID, false); err != nil {
+			log.Printf("Warning: Could not turn off download notifications: %v", err)
+		}
+	}
+
+	// Scan for malware in the background; downloads wait for the result
+	s.queueVirusScan(fileID)
+
+	// Remove image metadata in the background if asked to. The SHA-256 is calculated again afterwards.
+	s.queueMetadataStripping(fileID, fileInfo.Name, fileInfo.SizeBytes, stripMetadata)
+
+	// Convert HEIC and similar images for preview in the background
+	s.queueImageConversion(fileID, fileInfo.Name, fileInfo.SizeBytes)
+
 	// Log successful upload
 	log.Printf("✅ Upload finished: '%s' (%.1f MB) from IP: %s | User: %s (%d) | File ID: %s | SHA1: %s",
 		header.Filename,
 		float64(fileSize)/(1024*1024),
-		clientIP,
+		ip,
 		user.Email,
 		user.Id,
 		fileID,
@@ -235,8 +340,7 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update user storage
-	newStorageUsed := user.StorageUsedMB + fileSizeMB
-	if err := database.DB.UpdateUserStorage(user.Id, newStorageUsed); err != nil {
+	if err := database.DB.AdjustUserStorage(user.Id, fileSizeMB); err != nil {
 		log.Printf("Warning: Could not update user storage: %v", err)
 	}
 
@@ -262,9 +366,20 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Hold public links until approved, if required
+	pendingApproval, err := s.requestUploadApprovalIfNeeded(user, fileInfo, r)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "The file was uploaded, but "+err.Error())
+		return
+	}
+
 	// Generate share and download links
-	splashLink := s.getPublicURL() + "/s/" + fileID
-	downloadLink := s.getPublicURL() + "/d/" + fileID
+	linkID := fileID
+	if slug != "" {
+		linkID = slug
+	}
+	splashLink := s.getPublicURL() + "/s/" + linkID
+	downloadLink := s.getPublicURL() + "/d/" + linkID
 
 	log.Printf("File uploaded: %s (%s) by user %d", header.Filename, database.FormatFileSize(fileSize), user.Id)
 
@@ -276,146 +391,185 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		EntityType: "File",
 		EntityID:   fileID,
 		Details:    fmt.Sprintf("{\"file_name\":\"%s\",\"size\":%d,\"requires_auth\":%v}", header.Filename, fileSize, requireAuth),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
 	})
+	webhooks.Dispatch(database.WebhookEventFileUploaded, fileID, header.Filename, user.Email, clientIP(r))
 
 	// Send email with download link if recipient email is provided
 	if sendToEmail != "" && strings.TrimSpace(sendToEmail) != "" {
 		go func() {
-			subject := "File ready for download"
-
-			htmlBody := fmt.Sprintf(`
-				<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
-					<h2 style="color: #333;">File Shared With You</h2>
-					<p>A file has been shared with you:</p>
-					<div style="background: #f5f5f5; padding: 15px; border-radius: 5px; margin: 20px 0;">
-						<h3 style="margin-top: 0; color: #2563eb;">%s</h3>
-						<p><strong>Size:</strong> %s</p>
-						%s
-						%s
-					</div>
-					<div style="margin: 30px 0;">
-						<a href="%s" style="background: #2563eb; color: white; padding: 12px 24px; text-decoration: none; border-radius: 5px; display: inline-block;">View & Download File</a>
-					</div>
-					<p style="color: #666; font-size: 14px;">
-						<strong>Direct download link:</strong> <a href="%s">%s</a>
-					</p>
-					<hr style="border: none; border-top: 1px solid #ddd; margin: 30px 0;">
-					<p style="color: #999; font-size: 12px;">This file was sent to you via WulfVault.</p>
-				</div>
-			`, html.EscapeString(header.Filename),
-				database.FormatFileSize(fileSize),
-				func() string {
-					if fileInfo.ExpireAtString != "" && !fileInfo.UnlimitedTime {
-						return fmt.Sprintf("<p><strong>Expires:</strong> %s</p>", html.EscapeString(fileInfo.ExpireAtString))
-					}
-					return ""
-				}(),
-				func() string {
-					if !fileInfo.UnlimitedDownloads {
-						return fmt.Sprintf("<p><strong>Download limit:</strong> %d downloads</p>", fileInfo.DownloadsRemaining)
-					}
-					return ""
-				}(),
-				splashLink, downloadLink, downloadLink)
-
-			textBody := fmt.Sprintf(`File Shared With You
-
-File: %s
-Size: %s
-%s%s
-
-View and download here: %s
-
-Direct download link: %s
-
-This file was sent to you via WulfVault.`,
-				header.Filename,
-				database.FormatFileSize(fileSize),
-				func() string {
-					if fileInfo.ExpireAtString != "" && !fileInfo.UnlimitedTime {
-						return fmt.Sprintf("\nExpires: %s\n", fileInfo.ExpireAtString)
-					}
-					return ""
-				}(),
-				func() string {
-					if !fileInfo.UnlimitedDownloads {
-						return fmt.Sprintf("\nDownload limit: %d downloads\n", fileInfo.DownloadsRemaining)
-					}
-					return ""
-				}(),
-				splashLink, downloadLink)
-
-			provider, err := email.GetActiveProvider(database.DB)
+			data := email.NewTemplateData(s.config.CompanyName)
+			data.RecipientEmail = sendToEmail
+			data.SenderName = user.Name
+			data.SenderEmail = user.Email
+			data.Link = splashLink
+			data.File = &email.TemplateFile{
+				Name:    header.Filename,
+				Size:    database.FormatFileSize(fileSize),
+				Comment: fileInfo.Comment,
+			}
+			if fileInfo.ExpireAtString != "" && !fileInfo.UnlimitedTime {
+				data.File.ExpiresAt = fileInfo.ExpireAtString
+			}
+			if !fileInfo.UnlimitedDownloads {
+				data.File.DownloadLimit = fileInfo.DownloadsRemaining
+			}
+			subject, htmlBody, textBody, err := email.RenderEmail(email.TemplateFileShare, data)
 			if err != nil {
+				log.Printf("Failed to render file download link email: %v", err)
+				return
+			}
+
+			if _, err := email.GetActiveProvider(database.DB); err != nil {
 				log.Printf("Failed to get email provider: %v", err)
 				return
 			}
 
-			err = provider.SendEmail(sendToEmail, subject, htmlBody, textBody)
+			err = email.Enqueue(&database.QueuedEmail{
+				Kind:         database.EmailKindFileLink,
+				Recipient:    sendToEmail,
+				Subject:      subject,
+				HTMLBody:     htmlBody,
+				TextBody:     textBody,
+				FileId:       fileID,
+				FileName:     header.Filename,
+				FileSize:     fileSize,
+				SenderUserId: user.Id,
+				DedupKey:     database.NewEmailSendKey(fileID, sendToEmail),
+			})
 			if err != nil {
-				log.Printf("Failed to send file download link email to %s: %v", sendToEmail, err)
+				log.Printf("Failed to queue file download link email to %s: %v", sendToEmail, err)
 			} else {
-				log.Printf("File download link email sent to %s", sendToEmail)
-
-				// Log email to database
-				err = database.DB.LogEmailSent(fileID, user.Id, sendToEmail, "", header.Filename, fileSize)
-				if err != nil {
-					log.Printf("Failed to log email to database: %v", err)
-				}
+				log.Printf("File download link email to %s queued", sendToEmail)
 			}
 		}()
 	}
 
+	quota := storageQuotaOf(currentUploader(user))
 	s.sendJSON(w, http.StatusOK, map[string]interface{}{
-		"success":         true,
-		"file_id":         fileID,
-		"file_name":       header.Filename,
-		"share_url":       splashLink,
-		"download_url":    downloadLink,
-		"size":            fileSize,
-		"size_formatted":  database.FormatFileSize(fileSize),
-		"expire_at":       expireAtString,
-		"downloads_limit": downloadsLimit,
-		"require_auth":    requireAuth,
-		"has_password":    filePassword != "",
+		"success":              true,
+		"file_id":              fileID,
+		"file_name":            header.Filename,
+		"share_url":            splashLink,
+		"download_url":         downloadLink,
+		"size":                 fileSize,
+		"size_formatted":       database.FormatFileSize(fileSize),
+		"expire_at":            expireAtString,
+		"downloads_limit":      downloadsLimit,
+		"require_auth":         requireAuth,
+		"has_password":         filePassword != "",
+		"pending_approval":     pendingApproval,
+		"storage_used_mb":      quota.UsedMB,
+		"storage_quota_mb":     quota.QuotaMB,
+		"storage_remaining_mb": quota.RemainingMB,
 	})
 }
 
 // handleSplashPage shows the splash page with download button
 func (s *Server) handleSplashPage(w http.ResponseWriter, r *http.Request) {
-	// Extract file ID from URL (/s/ABC123)
+	// Extract file ID or custom slug from URL (/s/ABC123 or /s/q3-report)
 	fileID := r.URL.Path[len("/s/"):]
 
 	if fileID == "" {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
+	fileID = database.DB.ResolveFileID(fileID)
 
-	// Get file from database
+	// Links of burn-after-download files work for one download only
+	if isBurnedLink(fileID) {
+		s.renderBurnedLink(w, r)
+		return
+	}
+
+	// Get file from database. Files the expiry cleanup moved to trash still get the expired page.
 	fileInfo, err := database.DB.GetFileByID(fileID)
 	if err != nil {
-		http.Error(w, "File not found", http.StatusNotFound)
+		fileInfo, err = database.DB.GetExpiredTrashedFile(fileID)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	// Emailed links carry a signature that must match the file, and once a file was emailed
+	// its links need one
+	if err := s.verifyShareLink(w, r, fileInfo); err != nil {
+		if errors.Is(err, email.ErrShareLinkExpired) {
+			s.renderLocalizedSplashPageUnavailable(w, deploymentLocale(), "⌛", "Link Expired", "This link has expired. Please ask the sender for a new link.", expiredMessageHTML(fileInfo), "")
+		} else if errors.Is(err, email.ErrShareLinkMissing) {
+			s.renderSplashPageUnavailable(w, "🔒", "Personal Link Required", "This file was sent with a personal link. Please open the link from your email, or ask the sender for a new one.")
+		} else {
+			s.renderSplashPageUnavailable(w, "🔒", "Invalid Link", "This link is not valid. It may have been altered or copied incompletely. Please ask the sender for a new link.")
+		}
 		return
 	}
 
-	// Check if file has expired
-	if !fileInfo.UnlimitedTime && fileInfo.ExpireAt > 0 && time.Now().Unix() > fileInfo.ExpireAt {
-		s.renderSplashPageExpired(w, fileInfo)
+	// Recipients see the page in their own language, not the sender's
+	locale := recipientLocale(w, r)
+
+	// Check if file has expired or its download limit is reached. A recipient retrying a
+	// download they already made may still get the file.
+	if reason := fileExpiredReason(fileInfo); reason != "" && !(reason == database.FileExpiredByDownloads && hasDownloadRetryGrant(r, fileInfo)) {
+		s.renderSplashPageExpired(w, fileInfo, locale, reason, "")
 		return
 	}
 
-	// Check if download limit is reached
-	if !fileInfo.UnlimitedDownloads && fileInfo.DownloadsRemaining <= 0 {
-		s.renderSplashPageExpired(w, fileInfo)
+	// Public links that await approval must not work yet
+	switch s.getPublicLinkApprovalStatus(fileInfo) {
+	case database.ApprovalStatusPending:
+		s.renderSplashPageUnavailable(w, "⏳", "Awaiting Approval", "This file is waiting to be approved and cannot be downloaded yet. Please try again later.")
+		return
+	case database.ApprovalStatusRejected:
+		s.renderSplashPageUnavailable(w, "🚫", "File Not Available", "This file has not been approved for sharing.")
 		return
 	}
 
+	// Embargoed files can't be downloaded before their links go live
+	if message := fileNotYetLiveMessage(fileInfo); message != "" {
+		s.renderSplashPageUnavailable(w, "⏳", "Not Yet Available", message)
+		return
+	}
+
+	// Files with an availability schedule can only be downloaded inside their window
+	if message := fileScheduleUnavailableMessage(fileInfo); message != "" {
+		s.renderSplashPageUnavailable(w, "🕒", "Not Available Right Now", message)
+		return
+	}
+
+	// Files the virus scan hasn't cleared yet or found malware in can't be downloaded
+	switch fileInfo.ScanStatus {
+	case database.ScanInfected:
+		s.renderSplashPageUnavailable(w, "🦠", "File Quarantined", fileScanUnavailableMessage(fileInfo))
+		return
+	case database.ScanPending:
+		s.renderSplashPageUnavailable(w, "🔍", "Scanning for Viruses", fileScanUnavailableMessage(fileInfo))
+		return
+	case database.ScanError:
+		if message := fileScanUnavailableMessage(fileInfo); message != "" {
+			s.renderSplashPageUnavailable(w, "🔍", "Not Scanned Yet", message)
+			return
+		}
+	}
+
+	// Downloads stay locked for a while after unusual download activity
+	if until, _ := database.DB.GetFileDownloadBlock(fileInfo.Id); !until.IsZero() {
+		s.renderDownloadsLocked(w, locale, until)
+		return
+	}
+
+	// Files with a viewer limit show a busy page while it is reached
+	if !s.checkSplashViewerLimit(w, r, fileInfo) {
+		return
+	}
+
+	s.logSIEMEvent(r, siemEventSplashView, fileInfo, true, siemAuthAnonymous, "")
+
 	// Render splash page
-	s.renderSplashPage(w, fileInfo)
+	s.renderSplashPage(w, r, fileInfo, locale)
 }
 
 // handleDownload handles file download
@@ -428,6 +582,23 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Links with a custom slug (/d/q3-report) continue at the file's ID, where the cookies
+	// of its password, share link and download account are scoped
+	if id := database.DB.ResolveFileID(fileID); id != fileID {
+		target := "/d/" + id
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+
+	// Links of burn-after-download files work for one download only
+	if isBurnedLink(fileID) {
+		s.renderBurnedLink(w, r)
+		return
+	}
+
 	// Get file from database
 	fileInfo, err := database.DB.GetFileByID(fileID)
 	if err != nil {
@@ -435,15 +606,72 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if file has expired by time
-	if !fileInfo.UnlimitedTime && fileInfo.ExpireAt > 0 && time.Now().Unix() > fileInfo.ExpireAt {
+	// Emailed links carry a signature that must match the file, and once a file was emailed
+	// its links need one
+	if err := s.verifyShareLink(w, r, fileInfo); err != nil {
+		if errors.Is(err, email.ErrShareLinkExpired) {
+			http.Error(w, "This link has expired", http.StatusGone)
+		} else if errors.Is(err, email.ErrShareLinkMissing) {
+			http.Error(w, "This file was sent with a personal link. Open the link from your email.", http.StatusForbidden)
+		} else {
+			http.Error(w, "This link is not valid", http.StatusForbidden)
+		}
+		return
+	}
+
+	// Check if file has expired or its download limit is reached
+	switch fileExpiredReason(fileInfo) {
+	case database.FileExpiredByTime:
 		http.Error(w, "File has expired", http.StatusGone)
 		return
+	case database.FileExpiredByDownloads:
+		if !hasDownloadRetryGrant(r, fileInfo) && !hasDownloadRangeGrant(r, fileInfo) {
+			http.Error(w, "Download limit reached", http.StatusGone)
+			return
+		}
+	}
+
+	// Public links that await approval must not work yet
+	switch s.getPublicLinkApprovalStatus(fileInfo) {
+	case database.ApprovalStatusPending:
+		http.Error(w, "File is awaiting approval", http.StatusForbidden)
+		return
+	case database.ApprovalStatusRejected:
+		http.Error(w, "File has not been approved for sharing", http.StatusForbidden)
+		return
 	}
 
-	// Check if download limit is reached
-	if !fileInfo.UnlimitedDownloads && fileInfo.DownloadsRemaining <= 0 {
-		http.Error(w, "Download limit reached", http.StatusGone)
+	// Embargoed files can't be downloaded before their links go live
+	if message := fileNotYetLiveMessage(fileInfo); message != "" {
+		http.Error(w, message, http.StatusForbidden)
+		return
+	}
+
+	// Files with an availability schedule can only be downloaded inside their window
+	if message := fileScheduleUnavailableMessage(fileInfo); message != "" {
+		http.Error(w, message, http.StatusForbidden)
+		return
+	}
+
+	// Files the virus scan hasn't cleared yet or found malware in can't be downloaded
+	if message := fileScanUnavailableMessage(fileInfo); message != "" {
+		http.Error(w, message, http.StatusForbidden)
+		return
+	}
+
+	// Downloads stay locked for a while after unusual download activity
+	if until, _ := database.DB.GetFileDownloadBlock(fileInfo.Id); !until.IsZero() {
+		s.renderDownloadsLocked(w, recipientLocale(w, r), until)
+		return
+	}
+
+	// Files that require terms acceptance send recipients back to the splash page until they accept
+	if !s.checkDownloadTerms(w, r, fileInfo) {
+		return
+	}
+
+	// Files with the legal notice template do the same until the recipient agrees to the notice
+	if !s.checkLegalNotice(w, r, fileInfo) {
 		return
 	}
 
@@ -455,7 +683,7 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("download_session_" + fileInfo.Id)
 		if err == nil {
 			account, err := database.DB.GetDownloadAccountByEmail(cookie.Value)
-			if err == nil && account.IsActive {
+			if err == nil && account.IsActive && downloadAccountHasFileAccess(fileInfo, account) {
 				s.performDownload(w, r, fileInfo, account)
 				return
 			}
@@ -493,30 +721,34 @@ func (s *Server) handlePasswordProtectedDownload(w http.ResponseWriter, r *http.
 		return
 	}
 
+	locale := recipientLocale(w, r)
+
 	// Check if password provided via POST
 	if r.Method == http.MethodPost {
 		if err := r.ParseForm(); err != nil {
-			s.renderPasswordPromptPage(w, fileInfo, "Invalid form data")
+			s.renderPasswordPromptPage(w, fileInfo, locale, i18n.T(locale.Code, "password.invalid_form"))
 			return
 		}
 
 		providedPassword := r.FormValue("file_password")
 		if providedPassword == "" {
-			s.renderPasswordPromptPage(w, fileInfo, "Password required")
+			s.renderPasswordPromptPage(w, fileInfo, locale, i18n.T(locale.Code, "password.required"))
 			return
 		}
 
 		// Verify password
 		if providedPassword != fileInfo.FilePasswordPlain {
-			s.renderPasswordPromptPage(w, fileInfo, "Incorrect password")
+			s.logSIEMEvent(r, siemEventAuth, fileInfo, false, siemAuthPassword, "")
+			s.renderPasswordPromptPage(w, fileInfo, locale, i18n.T(locale.Code, "password.incorrect"))
 			return
 		}
+		s.logSIEMEvent(r, siemEventAuth, fileInfo, true, siemAuthPassword, "")
 
 		// Password correct, set session cookie
 		http.SetCookie(w, &http.Cookie{
 			Name:     "password_verified_" + fileInfo.Id,
 			Value:    "true",
-			Path:     "/d/" + fileInfo.Id,
+			Path:     "/", // the splash page and preview check it too
 			Expires:  time.Now().Add(24 * time.Hour),
 			HttpOnly: true,
 			SameSite: http.SameSiteStrictMode,
@@ -534,7 +766,7 @@ func (s *Server) handlePasswordProtectedDownload(w http.ResponseWriter, r *http.
 	}
 
 	// Show password prompt page
-	s.renderPasswordPromptPage(w, fileInfo, "")
+	s.renderPasswordPromptPage(w, fileInfo, locale, "")
 }
 
 // handleAuthenticatedDownload handles downloads that require authentication
@@ -542,7 +774,7 @@ func (s *Server) handleAuthenticatedDownload(w http.ResponseWriter, r *http.Requ
 	// First check if user is logged in as regular user or admin
 	// NOTE: /d/ route doesn't use requireAuth middleware, so we need to manually check session
 	user, err := s.getUserFromSession(r)
-	if err == nil && user != nil {
+	if err == nil && user != nil && userHasFileAccess(fileInfo, user) {
 		// User is already logged in as regular user/admin - allow download
 		log.Printf("Regular user %s (%s) authenticated for file download", user.Name, user.Email)
 		s.performDownload(w, r, fileInfo, nil)
@@ -554,7 +786,7 @@ func (s *Server) handleAuthenticatedDownload(w http.ResponseWriter, r *http.Requ
 	if err == nil {
 		// User has session, check if valid
 		account, err := database.DB.GetDownloadAccountByEmail(cookie.Value)
-		if err == nil && account.IsActive {
+		if err == nil && account.IsActive && downloadAccountHasFileAccess(fileInfo, account) {
 			// Valid session, perform download
 			s.performDownload(w, r, fileInfo, account)
 			return
@@ -571,6 +803,9 @@ func (s *Server) handleAuthenticatedDownload(w http.ResponseWriter, r *http.Requ
 	s.renderDownloadAuthPage(w, fileInfo, "")
 }
 
+// noFileAccessMessage is shown when someone without a grant tries an access-restricted file
+const noFileAccessMessage = "This email address has not been given access to this file. Use Request Access below to ask the owner."
+
 // handleDownloadAccountCreation handles creation of download account
 func (s *Server) handleDownloadAccountCreation(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo) {
 	if err := r.ParseForm(); err != nil {
@@ -587,20 +822,34 @@ func (s *Server) handleDownloadAccountCreation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// A signed link sent to a specific recipient only works for that email address
+	if !shareLinkRecipientAllowed(r, fileInfo.Id, email) {
+		s.renderDownloadAuthPage(w, fileInfo, "This link was sent to a different email address. Log in with the address the link was sent to.")
+		return
+	}
+
 	// First check if this email belongs to a regular user or admin
 	regularUser, err := database.DB.GetUserByEmail(email)
 	if err == nil {
 		// User exists as regular user/admin - verify password
 		if !auth.CheckPasswordHash(password, regularUser.Password) {
+			s.logSIEMEvent(r, siemEventAuth, fileInfo, false, siemAuthUser, email)
 			s.renderDownloadAuthPage(w, fileInfo, "Invalid credentials")
 			return
 		}
+		auth.UpgradeUserPasswordHash(regularUser, password)
+		if !userHasFileAccess(fileInfo, regularUser) {
+			s.logSIEMEvent(r, siemEventAuth, fileInfo, false, siemAuthUser, email)
+			s.renderDownloadAuthPage(w, fileInfo, noFileAccessMessage)
+			return
+		}
+		s.logSIEMEvent(r, siemEventAuth, fileInfo, true, siemAuthUser, email)
 
 		// Valid regular user - create session and allow download
 		log.Printf("Regular user %s (%s) authenticated for file download", regularUser.Name, regularUser.Email)
 
-		// Create a regular user session (default 24h for download auth)
-		sessionToken, err := auth.CreateSession(regularUser.Id) // Uses default duration
+		// Create a regular user session (the default session hours for download auth)
+		sessionToken, sessionDuration, err := auth.CreateLoginSession(regularUser.Id, false)
 		if err != nil {
 			log.Printf("Warning: Could not create session for user: %v", err)
 			s.renderDownloadAuthPage(w, fileInfo, "Authentication failed")
@@ -612,7 +861,7 @@ func (s *Server) handleDownloadAccountCreation(w http.ResponseWriter, r *http.Re
 			Name:     "session",
 			Value:    sessionToken,
 			Path:     "/",
-			Expires:  time.Now().Add(24 * time.Hour),
+			Expires:  time.Now().Add(sessionDuration),
 			HttpOnly: true,
 			Secure:   false,
 			SameSite: http.SameSiteLaxMode,
@@ -627,11 +876,21 @@ func (s *Server) handleDownloadAccountCreation(w http.ResponseWriter, r *http.Re
 	account, err := database.DB.GetDownloadAccountByEmail(email)
 	isNewAccount := false
 	if err != nil {
+		// Access-restricted files only let granted emails register
+		if database.DB.IsFileAccessRequestsEnabled(fileInfo.Id) && !database.DB.HasFileAccessGrant(fileInfo.Id, email) {
+			s.renderDownloadAuthPage(w, fileInfo, noFileAccessMessage)
+			return
+		}
+
 		// Create new download account - name is required for new accounts
 		if name == "" {
 			s.renderDownloadAuthPage(w, fileInfo, "Name is required for new accounts")
 			return
 		}
+		if err := auth.ValidatePasswordStrength(password); err != nil {
+			s.renderDownloadAuthPage(w, fileInfo, err.Error())
+			return
+		}
 		account, err = createDownloadAccount(name, email, password)
 		if err != nil {
 			s.renderDownloadAuthPage(w, fileInfo, "Failed to create account: "+err.Error())
@@ -648,7 +907,7 @@ func (s *Server) handleDownloadAccountCreation(w http.ResponseWriter, r *http.Re
 			EntityType: "DownloadAccount",
 			EntityID:   fmt.Sprintf("%d", account.Id),
 			Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"self_registration\":true}", email, name),
-			IPAddress:  getClientIP(r),
+			IPAddress:  clientIP(r),
 			UserAgent:  r.UserAgent(),
 			Success:    true,
 			ErrorMsg:   "",
@@ -656,10 +915,18 @@ func (s *Server) handleDownloadAccountCreation(w http.ResponseWriter, r *http.Re
 	} else {
 		// Verify password for existing download account
 		if !checkDownloadPassword(password, account.Password) {
+			s.logSIEMEvent(r, siemEventAuth, fileInfo, false, siemAuthDownloadAccount, email)
 			s.renderDownloadAuthPage(w, fileInfo, "Invalid credentials")
 			return
 		}
+		auth.UpgradeDownloadAccountPasswordHash(account, password)
+		if !downloadAccountHasFileAccess(fileInfo, account) {
+			s.logSIEMEvent(r, siemEventAuth, fileInfo, false, siemAuthDownloadAccount, email)
+			s.renderDownloadAuthPage(w, fileInfo, noFileAccessMessage)
+			return
+		}
 	}
+	s.logSIEMEvent(r, siemEventAuth, fileInfo, true, siemAuthDownloadAccount, email)
 
 	// Set file-specific download session cookie
 	http.SetCookie(w, &http.Cookie{
@@ -692,6 +959,56 @@ func (s *Server) handleDownloadAccountCreation(w http.ResponseWriter, r *http.Re
 	s.performDownloadWithRedirect(w, r, fileInfo, account)
 }
 
+// claimFileDownload counts a download just before the file is sent. The file is read again
+// from the database, so a file that expired or used up its downloads since the request
+// started is refused. Returns false if the request was answered.
+func (s *Server) claimFileDownload(w http.ResponseWriter, fileInfo *database.FileInfo) bool {
+	if !checkFileDownloadBlock(w, fileInfo) {
+		return false
+	}
+
+	claimed, err := database.DB.ClaimFileDownload(fileInfo.Id)
+	if err != nil {
+		log.Printf("Error: Could not update download count for %s: %v", fileInfo.Id, err)
+		http.Error(w, "Download failed, please try again", http.StatusInternalServerError)
+		return false
+	}
+	if !claimed {
+		log.Printf("Refused download of %s: expired or download limit reached", fileInfo.Id)
+		http.Error(w, "This file has expired or reached its download limit", http.StatusGone)
+		return false
+	}
+	return true
+}
+
+// checkFileDownloadBlock refuses a download while downloads of the file are locked by an admin
+// or after unusual download activity. Returns false if the request was answered.
+func checkFileDownloadBlock(w http.ResponseWriter, fileInfo *database.FileInfo) bool {
+	if until, _ := database.DB.GetFileDownloadBlock(fileInfo.Id); !until.IsZero() {
+		log.Printf("Refused download of %s: downloads locked until %s", fileInfo.Id, until.Format(time.RFC3339))
+		http.Error(w, "Downloads of this file are temporarily locked for security reasons", http.StatusLocked)
+		return false
+	}
+	return true
+}
+
+// checkDownloadRangeAllowed refuses further byte ranges of a counted download once downloads of
+// the file are locked, or the file expired or was deleted since. The download limit doesn't
+// apply, as the ranges belong to a download that was already counted. Returns false if the
+// request was answered.
+func checkDownloadRangeAllowed(w http.ResponseWriter, fileInfo *database.FileInfo) bool {
+	if !checkFileDownloadBlock(w, fileInfo) {
+		return false
+	}
+	current, err := database.DB.GetFileByID(fileInfo.Id)
+	if err != nil || fileExpiredReason(current) == database.FileExpiredByTime {
+		log.Printf("Refused byte range of %s: expired or deleted", fileInfo.Id)
+		http.Error(w, "This file has expired", http.StatusGone)
+		return false
+	}
+	return true
+}
+
 // performDownload performs the actual file download
 func (s *Server) performDownload(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount) {
 	// Mark transfer as active to prevent inactivity timeout during download
@@ -708,27 +1025,56 @@ func (s *Server) performDownload(w http.ResponseWriter, r *http.Request, fileInf
 		defer s.markTransferInactive(sessionId)
 	}
 
-	filePath := filepath.Join(s.config.UploadsDir, fileInfo.Id)
+	if !s.checkFileStored(w, fileInfo) {
+		return
+	}
+	convertedPath := s.convertedDownloadPath(r, fileInfo)
+	etag := downloadETag(fileInfo, convertedPath)
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		http.Error(w, "File not found on disk", http.StatusNotFound)
+	// Further byte ranges of a download this browser already made are served without counting
+	// it again, e.g. when a broken-off download is resumed
+	if grant := s.downloadRangeGrant(r, fileInfo, account, etag); grant != nil {
+		if !checkDownloadRangeAllowed(w, fileInfo) {
+			return
+		}
+		s.serveDownloadRange(w, r, fileInfo, account, convertedPath, etag, grant)
+		return
+	}
+
+	// Consume the splash page token so repeated clicks don't count twice
+	if !s.checkDownloadToken(w, r, fileInfo) {
 		return
 	}
 
-	// Update download count
-	if err := database.DB.UpdateFileDownloadCount(fileInfo.Id); err != nil {
-		log.Printf("Warning: Could not update download count: %v", err)
+	// Burn-after-download files are downloaded by one request only
+	if fileInfo.BurnAfterDownload && !s.claimBurnDownload(w, r, fileInfo) {
+		return
+	}
+
+	// Count the download, re-checking expiry and remaining downloads at this moment. Retries of
+	// a download this browser already made are not counted again.
+	retry := s.isDownloadRetry(r, fileInfo, account)
+	if !retry {
+		if !s.claimFileDownload(w, fileInfo) {
+			if fileInfo.BurnAfterDownload {
+				database.DB.ReleaseBurnDownload(fileInfo.Id)
+			}
+			return
+		}
+		s.grantDownloadRetry(w, r, fileInfo, account)
 	}
 
 	// Create download log
+	client := downloadClientFromRequest(r, fileInfo)
 	downloadLog := &models.DownloadLog{
 		FileId:          fileInfo.Id,
 		FileName:        fileInfo.Name,
 		FileSize:        fileInfo.SizeBytes,
 		DownloadedAt:    time.Now().Unix(),
-		IpAddress:       r.RemoteAddr,
-		UserAgent:       r.UserAgent(),
+		IpAddress:       client.ip,
+		UserAgent:       client.userAgent,
 		IsAuthenticated: account != nil,
+		Country:         client.country,
 	}
 
 	if account != nil {
@@ -738,33 +1084,32 @@ func (s *Server) performDownload(w http.ResponseWriter, r *http.Request, fileInf
 		database.DB.UpdateDownloadAccountLastUsed(account.Id)
 	}
 
+	if acceptance, ok := termsAcceptanceFromRequest(r, fileInfo); ok {
+		downloadLog.TermsVersion = acceptance.Version
+		downloadLog.TermsAcceptedAt = acceptance.AcceptedAt
+	}
+	recordNoticeAcceptance(r, fileInfo, client, downloadLog)
+
 	if err := database.DB.CreateDownloadLog(downloadLog); err != nil {
 		log.Printf("Warning: Could not create download log: %v", err)
 	}
+	s.grantDownloadRanges(w, r, fileInfo, account, downloadLog.Id)
+	s.logSIEMEvent(r, siemEventDownload, fileInfo, true, siemAuthStatus(fileInfo, account), downloadLog.Email)
+	s.checkDownloadAnomalies(fileInfo)
 
-	// Send email notification to file owner
-	go func() {
-		owner, err := database.DB.GetUserByID(fileInfo.UserId)
-		if err != nil {
-			log.Printf("Could not get file owner for download notification: %v", err)
-			return
-		}
-
-		clientIP := getClientIP(r)
-		err = email.SendFileDownloadNotification(fileInfo, clientIP, s.getPublicURL(), owner.Email)
-		if err != nil {
-			log.Printf("Failed to send download notification email: %v", err)
-		} else {
-			log.Printf("Download notification email sent to %s", owner.Email)
-		}
-	}()
+	// Send email notification to file owner, who was already told about the download a retry repeats
+	if !retry {
+		dispatchDownloadWebhook(fileInfo, client, downloadLog.Email)
+		go s.notifyOwnerOfDownload(fileInfo, downloadLog, client)
+	}
 
-	// Set headers for download
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileInfo.Name))
-	w.Header().Set("Content-Type", fileInfo.ContentType)
-	w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.SizeBytes, 10))
+	s.setDownloadHeaders(w, r, fileInfo, account, convertedPath)
 
-	log.Printf("File download started: %s (%s) by %s", fileInfo.Name, fileInfo.Size, getDownloaderInfo(account, r.RemoteAddr))
+	if retry {
+		log.Printf("File download retried within the retry window: %s (%s) by %s", fileInfo.Name, fileInfo.Size, getDownloaderInfo(account, client.ip))
+	} else {
+		log.Printf("File download started: %s (%s) by %s", fileInfo.Name, fileInfo.Size, getDownloaderInfo(account, client.ip))
+	}
 
 	// Start timing the download
 	downloadStartTime := time.Now()
@@ -780,14 +1125,26 @@ func (s *Server) performDownload(w http.ResponseWriter, r *http.Request, fileInf
 		userEmail = "anonymous"
 	}
 
-	// Serve the file
-	http.ServeFile(w, r, filePath)
+	// Serve the file or the requested ranges, counting the bytes that actually reach the client
+	bytesSent, err := serveDownloadContent(w, r, fileInfo, convertedPath, etag)
+	if err != nil {
+		log.Printf("Error: Could not open %s for download: %v", fileInfo.Id, err)
+		http.Error(w, "File not found on disk", http.StatusNotFound)
+		if fileInfo.BurnAfterDownload {
+			database.DB.ReleaseBurnDownload(fileInfo.Id)
+		}
+		return
+	}
+
+	if err := database.DB.RecordBytesServed(downloadLog.Id, fileInfo.Id, bytesSent); err != nil {
+		log.Printf("Warning: Could not record bytes served: %v", err)
+	}
 
 	// Calculate download duration
 	downloadDuration := time.Since(downloadStartTime)
 	downloadSeconds := downloadDuration.Seconds()
 
-	log.Printf("File download completed: %s (%s) by %s - took %.2f seconds", fileInfo.Name, fileInfo.Size, getDownloaderInfo(account, r.RemoteAddr), downloadSeconds)
+	log.Printf("File download completed: %s (%s) by %s - sent %s, took %.2f seconds", fileInfo.Name, fileInfo.Size, getDownloaderInfo(account, client.ip), database.FormatFileSize(bytesSent), downloadSeconds)
 
 	// Log the action with download time
 	database.DB.LogAction(&database.AuditLogEntry{
@@ -796,12 +1153,85 @@ func (s *Server) performDownload(w http.ResponseWriter, r *http.Request, fileInf
 		Action:     "FILE_DOWNLOADED",
 		EntityType: "File",
 		EntityID:   fileInfo.Id,
-		Details:    fmt.Sprintf("{\"file_name\":\"%s\",\"size\":%d,\"authenticated\":%v,\"download_time_seconds\":%.2f}", fileInfo.Name, fileInfo.SizeBytes, account != nil, downloadSeconds),
-		IPAddress:  getClientIP(r),
-		UserAgent:  r.UserAgent(),
+		Details:    fmt.Sprintf("{\"file_name\":\"%s\",\"size\":%d,\"bytes_sent\":%d,\"authenticated\":%v,\"download_time_seconds\":%.2f,\"retry\":%v}", fileInfo.Name, fileInfo.SizeBytes, bytesSent, account != nil, downloadSeconds, retry),
+		IPAddress:  client.ip,
+		UserAgent:  client.userAgent,
 		Success:    true,
 		ErrorMsg:   "",
 	})
+
+	// The first complete download of a burn-after-download file removes it
+	if fileInfo.BurnAfterDownload {
+		s.burnAfterDownload(r, fileInfo, convertedPath, bytesSent, userID, userEmail)
+	}
+}
+
+// checkFileStored responds with an error if the file's contents can't be found in storage,
+// before a download is counted
+func (s *Server) checkFileStored(w http.ResponseWriter, fileInfo *database.FileInfo) bool {
+	if _, err := storage.Files.Stat(fileInfo.Id); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error: Could not check %s in storage: %v", fileInfo.Id, err)
+		}
+		http.Error(w, "File not found on disk", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// setDownloadHeaders sets the headers of a download, naming the file from its filename template
+// if it has one
+func (s *Server) setDownloadHeaders(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount, convertedPath string) {
+	downloadName := s.downloadFileName(r, fileInfo, account)
+	if convertedPath != "" {
+		// The converted copy of a HEIC or similar image was requested instead of the original
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", convertedDownloadName(downloadName, convertedPath)))
+		w.Header().Set("Content-Type", convertedContentType(convertedPath))
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadName))
+	w.Header().Set("Content-Type", fileInfo.ContentType)
+	if hash := s.getFileSHA256(fileInfo.Id); hash != "" {
+		setDigestHeaders(w, hash)
+	}
+}
+
+// serveDownloadRange sends further byte ranges of a counted download. They are added to the
+// bytes of its download log, but not counted, logged or notified as another download.
+func (s *Server) serveDownloadRange(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount, convertedPath, etag string, grant *downloadGrant) {
+	s.setDownloadHeaders(w, r, fileInfo, account, convertedPath)
+
+	bytesSent, err := serveDownloadContent(w, r, fileInfo, convertedPath, etag)
+	if err != nil {
+		log.Printf("Error: Could not open %s for download: %v", fileInfo.Id, err)
+		http.Error(w, "File not found on disk", http.StatusNotFound)
+		return
+	}
+
+	if err := database.DB.AddBytesServed(grant.LogID, fileInfo.Id, bytesSent); err != nil {
+		log.Printf("Warning: Could not record bytes served: %v", err)
+	}
+}
+
+// publicLinkLimitReached reports whether the configured max_public_links cap is reached.
+// A limit of 0 means unlimited.
+func publicLinkLimitReached() (bool, int) {
+	limit := database.DB.GetConfigInt("max_public_links", 0)
+	if limit <= 0 {
+		return false, 0
+	}
+	count, err := database.DB.CountActivePublicFiles()
+	if err != nil {
+		log.Printf("Warning: Failed to count public links: %v", err)
+		return false, limit
+	}
+	return count >= limit, limit
+}
+
+// publicLinkLimitMessage is shown when a new public link would exceed the cap
+func publicLinkLimitMessage(limit int) string {
+	return fmt.Sprintf("The maximum number of public links (%d) has been reached. Enable \"Require authentication\" to share this file.", limit)
 }
 
 // API Handlers
@@ -821,7 +1251,7 @@ func (s *Server) handleAPIFiles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get files from database
-	files, err := database.DB.GetFilesByUser(user.Id)
+	files, err := database.DB.GetFilesFiltered(&database.FileFilter{UserId: user.Id, Category: fileCategoryFromRequest(r)})
 	if err != nil {
 		s.sendError(w, http.StatusInternalServerError, "Failed to fetch files")
 		return
@@ -835,6 +1265,7 @@ func (s *Server) handleAPIFiles(w http.ResponseWriter, r *http.Request) {
 			"name":                f.Name,
 			"size":                f.Size,
 			"size_bytes":          f.SizeBytes,
+			"category":            f.Category,
 			"download_url":        s.getPublicURL() + "/d/" + f.Id,
 			"upload_date":         f.UploadDate,
 			"expire_at":           f.ExpireAtString,
@@ -845,6 +1276,7 @@ func (s *Server) handleAPIFiles(w http.ResponseWriter, r *http.Request) {
 			"unlimited_time":      f.UnlimitedTime,
 			"has_password":        f.FilePasswordPlain != "",
 			"file_password":       f.FilePasswordPlain,
+			"burn_after_download": f.BurnAfterDownload,
 		})
 	}
 
@@ -899,25 +1331,57 @@ func hashPassword(password string) (string, error) {
 	return auth.HashPassword(password)
 }
 
+// downloadClient holds what is recorded about the client of a download. The fields other than
+// the country are empty when detailed download logging is disabled for the file.
+type downloadClient struct {
+	ip        string // client IP behind trusted proxies, as stored in the download and audit logs
+	userAgent string
+	country   string // country code from the reverse proxy, used to detect unusual downloads
+}
+
+// downloadClientFromRequest returns the client details to record for a download of the file
+func downloadClientFromRequest(r *http.Request, fileInfo *database.FileInfo) downloadClient {
+	if database.DB.IsDownloadLogPrivate(fileInfo.Id) {
+		return downloadClient{country: downloadCountry(r)}
+	}
+	return downloadClient{
+		ip:        clientIP(r),
+		userAgent: r.UserAgent(),
+		country:   downloadCountry(r),
+	}
+}
+
+// notificationIP returns the downloader IP for the owner's download notification
+func (c downloadClient) notificationIP() string {
+	if c.ip == "" {
+		return "not recorded"
+	}
+	return c.ip
+}
+
 func getDownloaderInfo(account *models.DownloadAccount, ip string) string {
 	if account != nil {
 		return account.Email
 	}
+	if ip == "" {
+		return "anonymous"
+	}
 	return "anonymous (" + ip + ")"
 }
 
 // renderPasswordPromptPage renders the password prompt page for password-protected files
-func (s *Server) renderPasswordPromptPage(w http.ResponseWriter, fileInfo *database.FileInfo, errorMsg string) {
+func (s *Server) renderPasswordPromptPage(w http.ResponseWriter, fileInfo *database.FileInfo, locale i18n.Locale, errorMsg string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	t := func(key string) string { return i18n.T(locale.Code, key) }
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + localeLangAttributes(locale) + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
-    <title>Password Required - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    <title>` + t("password.title") + ` - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + localeDirectionStylesheetHTML(locale) + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -1039,25 +1503,25 @@ func (s *Server) renderPasswordPromptPage(w http.ResponseWriter, fileInfo *datab
 
 	// Add comment if present (moved to top as it's important)
 	if fileInfo.Comment != "" {
-		html += `<p style="margin-top: 8px; padding: 10px; background: #f9f9f9; border-left: 3px solid ` + s.getPrimaryColor() + `; border-radius: 4px; color: #555;"><strong>💬 Note:</strong> ` + template.HTMLEscapeString(fileInfo.Comment) + `</p>`
+		html += `<p style="margin-top: 8px; padding: 10px; background: #f9f9f9; border-left: 3px solid ` + s.getPrimaryColor() + `; border-radius: 4px; color: #555;"><strong>💬 ` + t("password.note") + `:</strong> ` + template.HTMLEscapeString(fileInfo.Comment) + `</p>`
 	}
 
-	html += `<p><strong>Size:</strong> ` + fileInfo.Size + `</p>
-            <p><strong>Downloads:</strong> ` + fmt.Sprintf("%d", fileInfo.DownloadCount) + `</p>`
+	html += `<p><strong>` + t("password.size") + `:</strong> ` + fileInfo.Size + `</p>
+            <p><strong>` + t("splash.downloads") + `:</strong> ` + fmt.Sprintf("%d", fileInfo.DownloadCount) + `</p>`
 
 	if !fileInfo.UnlimitedDownloads {
-		html += `<p><strong>Remaining:</strong> ` + fmt.Sprintf("%d", fileInfo.DownloadsRemaining) + `</p>`
+		html += `<p><strong>` + t("splash.remaining") + `:</strong> ` + fmt.Sprintf("%d", fileInfo.DownloadsRemaining) + `</p>`
 	}
 
 	if fileInfo.ExpireAtString != "" {
-		html += `<p><strong>Expires:</strong> ` + fileInfo.ExpireAtString + `</p>`
+		html += `<p><strong>` + t("splash.expires") + `:</strong> ` + fileInfo.ExpireAtString + `</p>`
 	}
 
 	html += `
         </div>
 
         <div class="info">
-            🔐 This file is password protected. Please enter the password to download.
+            🔐 ` + t("password.info") + `
         </div>`
 
 	if errorMsg != "" {
@@ -1068,19 +1532,19 @@ func (s *Server) renderPasswordPromptPage(w http.ResponseWriter, fileInfo *datab
         <div class="password-section">
             <form method="POST">
                 <div class="form-group">
-                    <label for="file_password">Password</label>
+                    <label for="file_password">` + t("password.label") + `</label>
                     <input type="password" id="file_password" name="file_password" required autofocus>
                 </div>
                 <button type="submit" class="btn">
                     <span style="font-size: 18px; margin-right: 8px;">🔓</span>
-                    <span style="font-size: 16px; font-weight: 700;">Unlock & Download</span>
+                    <span style="font-size: 16px; font-weight: 700;">` + t("password.unlock") + `</span>
                 </button>
             </form>
         </div>
 
         <div style="text-align: center; margin-top: 20px; color: #999; font-size: 12px;">
             ` + s.config.FooterText + `
-        </div>
+        </div>` + languageSwitcherHTML(locale) + `
     </div>
 </body>
 </html>`
@@ -1093,13 +1557,13 @@ func (s *Server) renderDownloadAuthPage(w http.ResponseWriter, fileInfo *databas
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
     <title>Download File - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -1208,6 +1672,20 @@ func (s *Server) renderDownloadAuthPage(w http.ResponseWriter, fileInfo *databas
             margin-bottom: 20px;
             font-size: 13px;
         }
+        textarea {
+            width: 100%;
+            padding: 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+            font-family: inherit;
+            resize: vertical;
+        }
+        .btn-secondary {
+            background: white;
+            color: ` + s.getPrimaryColor() + `;
+            border: 2px solid ` + s.getPrimaryColor() + `;
+        }
     </style>
 </head>
 <body>
@@ -1235,12 +1713,24 @@ func (s *Server) renderDownloadAuthPage(w http.ResponseWriter, fileInfo *databas
 		html += `<p><strong>Expires:</strong> ` + fileInfo.ExpireAtString + `</p>`
 	}
 
+	accessRequestsEnabled := database.DB.IsFileAccessRequestsEnabled(fileInfo.Id)
+
 	html += `
-        </div>
+        </div>`
+
+	if accessRequestsEnabled {
+		html += `
+
+        <div class="info">
+            🔒 Only people the owner has given access can download this file. Log in, or request access below.
+        </div>`
+	} else {
+		html += `
 
         <div class="info">
             🔒 This file requires authentication. Create an account or login to download.
         </div>`
+	}
 
 	if errorMsg != "" {
 		html += `<div class="error">` + errorMsg + `</div>`
@@ -1273,7 +1763,36 @@ func (s *Server) renderDownloadAuthPage(w http.ResponseWriter, fileInfo *databas
                     <span style="font-size: 16px; font-weight: 700;">Login / Create Account & Download</span>
                 </button>
             </form>
-        </div>
+        </div>`
+
+	if accessRequestsEnabled {
+		html += `
+
+        <div class="auth-section">
+            <button type="button" class="btn btn-secondary" onclick="document.getElementById('requestAccessForm').style.display = 'block'; this.style.display = 'none';">
+                🔑 Request Access
+            </button>
+            <form id="requestAccessForm" method="POST" action="/file/access-request" style="display: none;">
+                <h3>🔑 Request Access</h3>
+                <input type="hidden" name="file_id" value="` + fileInfo.Id + `">
+                <div class="form-group">
+                    <label for="request_name">Name</label>
+                    <input type="text" id="request_name" name="name" placeholder="Your name">
+                </div>
+                <div class="form-group">
+                    <label for="request_email">Email</label>
+                    <input type="email" id="request_email" name="email" required>
+                </div>
+                <div class="form-group">
+                    <label for="request_message">Message (optional)</label>
+                    <textarea id="request_message" name="message" rows="3" maxlength="1000" placeholder="Let the owner know who you are"></textarea>
+                </div>
+                <button type="submit" class="btn">Send Request to Owner</button>
+            </form>
+        </div>`
+	}
+
+	html += `
 
         <div style="text-align: center; margin-top: 20px; color: #999; font-size: 12px;">
             ` + s.config.FooterText + `
@@ -1286,8 +1805,9 @@ func (s *Server) renderDownloadAuthPage(w http.ResponseWriter, fileInfo *databas
 }
 
 // renderSplashPage renders the splash page with download button
-func (s *Server) renderSplashPage(w http.ResponseWriter, fileInfo *database.FileInfo) {
+func (s *Server) renderSplashPage(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, locale i18n.Locale) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	t := func(key string) string { return i18n.T(locale.Code, key) }
 
 	// Get branding config
 	brandingConfig, _ := database.DB.GetBrandingConfig()
@@ -1298,17 +1818,59 @@ func (s *Server) renderSplashPage(w http.ResponseWriter, fileInfo *database.File
 
 	downloadURL := s.getPublicURL() + "/d/" + fileInfo.Id
 
+	// Issue a single-use token so double clicks only consume one download
+	tokenTTL := getDownloadTokenTTL()
+	if tokenTTL > 0 {
+		if token := issueDownloadToken(fileInfo.Id, tokenTTL); token != "" {
+			downloadURL += "?dt=" + token
+		}
+	}
+
 	// Get poem of the day
 	poem := models.GetPoemOfTheDay()
 
+	// The owner's splash page template. The minimal one leaves out the details, preview and poem.
+	splashTemplate := database.DB.GetFileSplashTemplate(fileInfo.Id)
+	minimal := splashTemplate == database.SplashTemplateMinimal
+	noticeRequired := splashTemplate == database.SplashTemplateLegalNotice
+
+	// Terms the recipient must accept before the download button works
+	terms := requiredDownloadTerms(fileInfo)
+	termsVersion := 0
+	if terms != nil {
+		termsVersion = terms.Version
+	}
+
+	// Images, PDFs and text are shown inline; HEIC and similar images get a converted preview
+	// and optionally a converted download
+	convertedPath := s.getConvertedImage(fileInfo)
+	previewHTML := ""
+	if !minimal {
+		previewHTML = s.renderFilePreview(r, fileInfo, t("splash.preview"), t("splash.preview_truncated"))
+	}
+	convertedLinkHTML := ""
+	if convertedPath != "" && isConvertedDownloadEnabled() {
+		convertedURL := s.getPublicURL() + "/d/" + fileInfo.Id + "?format=converted"
+		if tokenTTL > 0 {
+			if token := issueDownloadToken(fileInfo.Id, tokenTTL); token != "" {
+				convertedURL += "&dt=" + token
+			}
+		}
+		convertedFormat := strings.ToUpper(strings.TrimPrefix(filepath.Ext(convertedPath), "."))
+		convertedLinkHTML = `
+        <p style="margin-top: 15px;">
+            <a href="` + convertedURL + `" class="download-link" style="color: ` + primaryColor + `; font-size: 15px; font-weight: 600;">🖼️ ` + fmt.Sprintf(t("splash.download_converted"), convertedFormat) + `</a>
+        </p>`
+	}
+
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + localeLangAttributes(locale) + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
-    <title>Download File - ` + companyName + `</title>
-    ` + s.getFaviconHTML() + `
+    <title>` + t("splash.title") + ` - ` + companyName + `</title>
+    ` + s.getFaviconHTML() + localeDirectionStylesheetHTML(locale) + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -1463,7 +2025,7 @@ func (s *Server) renderSplashPage(w http.ResponseWriter, fileInfo *database.File
 	if fileInfo.Comment != "" {
 		html += `
         <div style="margin: 25px 0; padding: 20px; background: #f9f9f9; border-left: 4px solid ` + primaryColor + `; border-radius: 8px; text-align: left;">
-            <h3 style="color: ` + primaryColor + `; font-size: 16px; margin-bottom: 10px;">💬 Note from sender</h3>
+            <h3 style="color: ` + primaryColor + `; font-size: 16px; margin-bottom: 10px;">💬 ` + t("splash.note") + `</h3>
             <p style="color: #555; font-size: 15px; line-height: 1.6;">` + template.HTMLEscapeString(fileInfo.Comment) + `</p>
         </div>`
 	}
@@ -1471,62 +2033,171 @@ func (s *Server) renderSplashPage(w http.ResponseWriter, fileInfo *database.File
 	html += `
         <div class="file-details">
             <div class="detail-item">
-                <h3>File Size</h3>
+                <h3>` + t("splash.file_size") + `</h3>
                 <p>` + fileInfo.Size + `</p>
-            </div>
+            </div>`
+
+	if !minimal {
+		html += `
             <div class="detail-item">
-                <h3>Downloads</h3>
+                <h3>` + t("splash.downloads") + `</h3>
                 <p>` + fmt.Sprintf("%d", fileInfo.DownloadCount) + `</p>
             </div>`
+	}
 
-	if !fileInfo.UnlimitedDownloads {
+	if !fileInfo.UnlimitedDownloads && !minimal {
 		html += `
             <div class="detail-item">
-                <h3>Remaining</h3>
+                <h3>` + t("splash.remaining") + `</h3>
                 <p>` + fmt.Sprintf("%d", fileInfo.DownloadsRemaining) + `</p>
             </div>`
 	}
 
-	if fileInfo.ExpireAtString != "" && !fileInfo.UnlimitedTime {
+	if fileInfo.ExpireAtString != "" && !fileInfo.UnlimitedTime && !minimal {
 		html += `
             <div class="detail-item">
-                <h3>Expires</h3>
+                <h3>` + t("splash.expires") + `</h3>
                 <p style="font-size: 14px;">` + fileInfo.ExpireAtString + `</p>
             </div>`
 	}
 
 	html += `
-        </div>`
+        </div>` + previewHTML
 
 	if fileInfo.RequireAuth {
-		html += `<div class="badge">🔒 Authentication Required</div>`
+		html += `<div class="badge">🔒 ` + t("splash.auth_required") + `</div>`
+	}
+
+	if fileInfo.BurnAfterDownload {
+		html += `<div class="badge">🔥 ` + t("splash.burn_notice") + `</div>`
+	}
+
+	if isChecksumDisplayEnabled() {
+		if hash := s.getFileSHA256(fileInfo.Id); hash != "" {
+			html += renderChecksumSection(fileInfo.Name, hash, primaryColor)
+		}
+	}
+
+	if terms != nil {
+		html += renderDownloadTermsSection(terms, t("terms.title"), t("terms.accept"), primaryColor)
+	}
+
+	if noticeRequired {
+		html += renderLegalNoticeSection(legalNoticeText(locale), t("notice.title"), t("notice.agree"), t("notice.agreed"), primaryColor)
 	}
 
 	// Add Poem of the Day section
-	html += `
+	if !minimal {
+		html += `
         <div class="poem-section">
-            <div class="poem-title">📖 While waiting, here is Poem of the Day</div>
+            <div class="poem-title">📖 ` + t("splash.poem") + `</div>
             <div class="poem-text">` + poem.Text + `</div>
             <div class="poem-author">— ` + poem.Author + `</div>
-        </div>
+        </div>`
+	}
 
-        <a href="` + downloadURL + `" class="download-btn">
+	html += `
+
+        <a href="` + downloadURL + `" class="download-btn download-link" id="downloadBtn">
             <span style="font-size: 24px; margin-right: 10px;">⬇️</span>
-            <span style="font-size: 20px; font-weight: 700;">Download File</span>
-        </a>
+            <span style="font-size: 20px; font-weight: 700;">` + t("splash.download") + `</span>
+        </a>` + convertedLinkHTML + `
 
         <div class="footer">
-            Powered by ` + companyName + `
-        </div>
+            ` + t("splash.powered_by") + ` ` + companyName + `
+        </div>` + languageSwitcherHTML(locale) + `
     </div>
+    <script>
+        (function() {
+            const links = document.querySelectorAll('.download-link');
+            const tokenTTL = ` + strconv.Itoa(int(tokenTTL.Seconds())) + ` * 1000;
+            const loadedAt = Date.now();
+            const termsVersion = ` + strconv.Itoa(termsVersion) + `;
+            const noticeRequired = ` + strconv.FormatBool(noticeRequired) + `;
+            let noticeAgreed = false;
+            let clicked = false;
+
+            if (noticeRequired) {
+                document.getElementById('agreeNotice').addEventListener('click', function() {
+                    noticeAgreed = true;
+                    this.style.display = 'none';
+                    document.getElementById('noticeAgreed').style.display = 'inline-block';
+                });
+            }
+
+            links.forEach(function(btn) { btn.addEventListener('click', function(e) {
+                // The download token has expired - reload to get a fresh one
+                if (tokenTTL > 0 && Date.now() - loadedAt > tokenTTL) {
+                    e.preventDefault();
+                    window.location.reload();
+                    return;
+                }
+                // Ignore repeated clicks
+                if (clicked) {
+                    e.preventDefault();
+                    return;
+                }
+                // The terms must be accepted first
+                if (termsVersion > 0) {
+                    if (!document.getElementById('acceptTerms').checked) {
+                        e.preventDefault();
+                        alert(` + strconv.Quote(t("terms.required")) + `);
+                        return;
+                    }
+                    btn.href += (btn.href.indexOf('?') === -1 ? '?' : '&') + 'accept_terms=' + termsVersion;
+                }
+                // So must the legal notice
+                if (noticeRequired) {
+                    if (!noticeAgreed) {
+                        e.preventDefault();
+                        alert(` + strconv.Quote(t("notice.required")) + `);
+                        return;
+                    }
+                    btn.href += (btn.href.indexOf('?') === -1 ? '?' : '&') + 'accept_notice=1';
+                }
+                clicked = true;
+                links.forEach(function(link) {
+                    link.style.opacity = '0.6';
+                    link.style.cursor = 'default';
+                });
+            }); });
+        })();
+    </script>` + splashViewerHeartbeatJS(fileInfo.Id) + `
 </body>
 </html>`
 
 	w.Write([]byte(html))
 }
 
-// renderSplashPageExpired renders expired file splash page
-func (s *Server) renderSplashPageExpired(w http.ResponseWriter, fileInfo *database.FileInfo) {
+// renderSplashPageExpired renders expired file splash page. It explains whether the file expired
+// by time or by downloads without naming the file, shows the owner's expired page message (or the
+// deployment default), and offers the new link request form when the owner enabled it. formError
+// is shown above the form.
+func (s *Server) renderSplashPageExpired(w http.ResponseWriter, fileInfo *database.FileInfo, locale i18n.Locale, reason, formError string) {
+	message := i18n.T(locale.Code, "expired.message")
+	switch reason {
+	case database.FileExpiredByTime:
+		message = i18n.T(locale.Code, "expired.message_time")
+	case database.FileExpiredByDownloads:
+		message = i18n.T(locale.Code, "expired.message_downloads")
+	}
+
+	bodyHTML := expiredMessageHTML(fileInfo)
+	if database.DB.IsFileReshareRequestsEnabled(fileInfo.Id) {
+		bodyHTML += reshareRequestFormHTML(fileInfo.Id, locale, formError, s.getPrimaryColor())
+	}
+
+	s.renderLocalizedSplashPageUnavailable(w, locale, "⏰", i18n.T(locale.Code, "expired.title"), message, bodyHTML, languageSwitcherHTML(locale))
+}
+
+// renderSplashPageUnavailable renders a splash page explaining why a file cannot be downloaded
+func (s *Server) renderSplashPageUnavailable(w http.ResponseWriter, icon, title, message string) {
+	s.renderLocalizedSplashPageUnavailable(w, deploymentLocale(), icon, title, message, "", "")
+}
+
+// renderLocalizedSplashPageUnavailable renders the unavailable splash page in the given locale,
+// with bodyHTML (if any) below the message and switcherHTML (if any) below the footer
+func (s *Server) renderLocalizedSplashPageUnavailable(w http.ResponseWriter, locale i18n.Locale, icon, title, message, bodyHTML, switcherHTML string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	// Get branding config
@@ -1537,13 +2208,13 @@ func (s *Server) renderSplashPageExpired(w http.ResponseWriter, fileInfo *databa
 	logoData := brandingConfig["branding_logo"]
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + localeLangAttributes(locale) + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
-    <title>File Expired - ` + companyName + `</title>
-    ` + s.getFaviconHTML() + `
+    <title>` + title + ` - ` + companyName + `</title>
+    ` + s.getFaviconHTML() + localeDirectionStylesheetHTML(locale) + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -1610,14 +2281,14 @@ func (s *Server) renderSplashPageExpired(w http.ResponseWriter, fileInfo *databa
 	html += `
         </div>
 
-        <div class="expired-icon">⏰</div>
+        <div class="expired-icon">` + icon + `</div>
 
-        <h2>File No Longer Available</h2>
-        <p>This file has expired and is no longer available for download.</p>
+        <h2>` + title + `</h2>
+        <p>` + message + `</p>` + bodyHTML + `
 
         <div class="footer">
-            Powered by ` + companyName + `
-        </div>
+            ` + i18n.T(locale.Code, "splash.powered_by") + ` ` + companyName + `
+        </div>` + switcherHTML + `
     </div>
 </body>
 </html>`
@@ -1627,54 +2298,67 @@ func (s *Server) renderSplashPageExpired(w http.ResponseWriter, fileInfo *databa
 
 // performDownloadWithRedirect performs a download and redirects to dashboard (for new accounts)
 func (s *Server) performDownloadWithRedirect(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo, account *models.DownloadAccount) {
-	filePath := filepath.Join(s.config.UploadsDir, fileInfo.Id)
+	// The one download of a burn-after-download file must be the request that sends it, so it
+	// is not counted here and fetched again by the redirect page
+	if fileInfo.BurnAfterDownload {
+		s.performDownload(w, r, fileInfo, account)
+		return
+	}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		http.Error(w, "File not found on disk", http.StatusNotFound)
+	if !s.checkFileStored(w, fileInfo) {
 		return
 	}
 
-	// Update download count
-	if err := database.DB.UpdateFileDownloadCount(fileInfo.Id); err != nil {
-		log.Printf("Warning: Could not update download count: %v", err)
+	// Consume the splash page token so repeated clicks don't count twice
+	if !s.checkDownloadToken(w, r, fileInfo) {
+		return
+	}
+
+	// Count the download, re-checking expiry and remaining downloads at this moment. Retries of
+	// a download this browser already made are not counted again.
+	retry := s.isDownloadRetry(r, fileInfo, account)
+	if !retry {
+		if !s.claimFileDownload(w, fileInfo) {
+			return
+		}
+		s.grantDownloadRetry(w, r, fileInfo, account)
 	}
 
 	// Create download log
+	client := downloadClientFromRequest(r, fileInfo)
 	downloadLog := &models.DownloadLog{
 		FileId:            fileInfo.Id,
 		FileName:          fileInfo.Name,
 		FileSize:          fileInfo.SizeBytes,
 		DownloadedAt:      time.Now().Unix(),
-		IpAddress:         r.RemoteAddr,
-		UserAgent:         r.UserAgent(),
+		IpAddress:         client.ip,
+		UserAgent:         client.userAgent,
 		IsAuthenticated:   true,
 		DownloadAccountId: account.Id,
 		Email:             account.Email,
+		Country:           client.country,
+	}
+
+	if acceptance, ok := termsAcceptanceFromRequest(r, fileInfo); ok {
+		downloadLog.TermsVersion = acceptance.Version
+		downloadLog.TermsAcceptedAt = acceptance.AcceptedAt
 	}
+	recordNoticeAcceptance(r, fileInfo, client, downloadLog)
 
 	if err := database.DB.CreateDownloadLog(downloadLog); err != nil {
 		log.Printf("Warning: Could not create download log: %v", err)
 	}
+	s.logSIEMEvent(r, siemEventDownload, fileInfo, true, siemAuthDownloadAccount, account.Email)
+	s.checkDownloadAnomalies(fileInfo)
 
 	// Update account last used
 	database.DB.UpdateDownloadAccountLastUsed(account.Id)
 
-	// Send email notification to file owner
-	go func() {
-		owner, err := database.DB.GetUserByID(fileInfo.UserId)
-		if err != nil {
-			log.Printf("Could not get file owner for download notification: %v", err)
-			return
-		}
-
-		clientIP := getClientIP(r)
-		err = email.SendFileDownloadNotification(fileInfo, clientIP, s.getPublicURL(), owner.Email)
-		if err != nil {
-			log.Printf("Failed to send download notification email: %v", err)
-		} else {
-			log.Printf("Download notification email sent to %s", owner.Email)
-		}
-	}()
+	// Send email notification to file owner, who was already told about the download a retry repeats
+	if !retry {
+		dispatchDownloadWebhook(fileInfo, client, downloadLog.Email)
+		go s.notifyOwnerOfDownload(fileInfo, downloadLog, client)
+	}
 
 	log.Printf("File download initiated: %s (%s) by %s (redirecting to dashboard)", fileInfo.Name, fileInfo.Size, account.Email)
 
@@ -1682,7 +2366,10 @@ func (s *Server) performDownloadWithRedirect(w http.ResponseWriter, r *http.Requ
 	isNewAccount := time.Now().Unix()-account.CreatedAt < 30
 
 	// Render HTML page that downloads file and redirects to dashboard
-	downloadURL := "/d/" + fileInfo.Id
+	directURL := "/d/" + fileInfo.Id + "?direct=1"
+	if s.convertedDownloadPath(r, fileInfo) != "" {
+		directURL += "&format=converted"
+	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
@@ -1692,13 +2379,13 @@ func (s *Server) performDownloadWithRedirect(w http.ResponseWriter, r *http.Requ
 	}
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
     <title>` + pageTitle + ` - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -1777,7 +2464,7 @@ func (s *Server) performDownloadWithRedirect(w http.ResponseWriter, r *http.Requ
             // Create hidden iframe to trigger download
             var iframe = document.createElement('iframe');
             iframe.style.display = 'none';
-            iframe.src = '` + downloadURL + `?direct=1';
+            iframe.src = '` + directURL + `';
             document.body.appendChild(iframe);
 
             // Redirect to dashboard after 3 seconds
@@ -1834,6 +2521,10 @@ func (s *Server) performDownloadWithRedirect(w http.ResponseWriter, r *http.Requ
 
 // sendLargeFileUploadNotification sends an email notification to the user when they upload a large file (>5GB)
 func (s *Server) sendLargeFileUploadNotification(user *models.User, filename string, fileSize int64, fileID string, sha1Hash string) {
+	if !database.DB.NotifyUser(user.Id, database.NotifyUploadReceipts, "Large upload finished", filename+" ("+database.FormatFileSize(fileSize)+") was uploaded successfully", "/dashboard") {
+		return
+	}
+
 	// Get email provider
 	provider, err := email.GetActiveProvider(database.DB)
 	if err != nil {
@@ -1846,7 +2537,11 @@ func (s *Server) sendLargeFileUploadNotification(user *models.User, filename str
 	fileSizeGB := float64(fileSize) / (1024 * 1024 * 1024)
 
 	// Generate share link
-	shareLink := s.getPublicURL() + "/s/" + fileID
+	shareLink, err := email.ShareLinkURL(s.getPublicURL(), fileID, user.Email)
+	if err != nil {
+		log.Printf("Failed to create share link for large file notification: %v", err)
+		return
+	}
 
 	subject := "Large File Upload Confirmation - " + filename
 
@@ -1951,7 +2646,7 @@ If you did not upload this file, please contact your administrator immediately.
 		shareLink,
 	)
 
-	err = provider.SendEmail(user.Email, subject, htmlBody, textBody)
+	err = email.ForCategory(provider, database.NotifyUploadReceipts).SendEmail(user.Email, subject, htmlBody, textBody)
 	if err != nil {
 		log.Printf("Failed to send large file upload notification to %s: %v", user.Email, err)
 	} else {
@@ -701,13 +701,13 @@ func (s *Server) renderUserAccountSettings(w http.ResponseWriter, user *models.U
 	}
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
     <title>Account Settings - ` + s.config.CompanyName + `</title>
-    ` + s.getFaviconHTML() + `
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
@@ -0,0 +1,286 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// handleNotifications shows the user's in-app notifications and notification preferences
+func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	notifications, err := database.DB.GetUserNotifications(user.Id, 50)
+	if err != nil {
+		log.Printf("Failed to load notifications for %s: %v", user.Email, err)
+	}
+
+	// The list is shown now, so everything in it has been read
+	if err := database.DB.MarkUserNotificationsRead(user.Id); err != nil {
+		log.Printf("Failed to mark notifications read for %s: %v", user.Email, err)
+	}
+
+	s.renderNotificationsPage(w, user, notifications, database.DB.GetNotificationPreferences(user.Id), r.URL.Query().Get("saved") == "1")
+}
+
+// handleNotificationPreferences saves the user's notification preferences (POST /notifications/preferences).
+// With unsubscribe_all=true every email except security notifications is turned off.
+func (s *Server) handleNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	unsubscribeAll := r.FormValue("unsubscribe_all") == "true"
+	prefs := database.DB.GetNotificationPreferences(user.Id)
+	for _, category := range database.NotificationCategories {
+		if category.Required || (category.AdminOnly && !user.IsAdmin()) {
+			continue
+		}
+		if unsubscribeAll {
+			pref := prefs[category.Key]
+			pref.Email = false
+			prefs[category.Key] = pref
+			continue
+		}
+		prefs[category.Key] = database.NotificationPreference{
+			Email: r.FormValue("email_"+category.Key) == "true",
+			InApp: r.FormValue("inapp_"+category.Key) == "true",
+		}
+	}
+
+	if err := database.DB.SetNotificationPreferences(user.Id, prefs); err != nil {
+		log.Printf("Failed to save notification preferences for %s: %v", user.Email, err)
+		http.Error(w, "Failed to save notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	emailOff := []string{}
+	for _, category := range database.NotificationCategories {
+		if !prefs[category.Key].Email {
+			emailOff = append(emailOff, category.Key)
+		}
+	}
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionNotificationPrefsUpdated,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"unsubscribe_all": unsubscribeAll,
+			"email_off":       emailOff,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	http.Redirect(w, r, "/notifications?saved=1#preferences", http.StatusSeeOther)
+}
+
+// notificationBellHTML returns the header link to the notifications page with the unread count
+func notificationBellHTML(user *models.User) string {
+	badge := ""
+	if unread := database.DB.CountUnreadUserNotifications(user.Id); unread > 0 {
+		if unread > 99 {
+			badge = ` <span style="background: #f44336; color: white; border-radius: 10px; padding: 1px 7px; font-size: 12px; font-weight: 700;">99+</span>`
+		} else {
+			badge = ` <span style="background: #f44336; color: white; border-radius: 10px; padding: 1px 7px; font-size: 12px; font-weight: 700;">` + strconv.Itoa(unread) + `</span>`
+		}
+	}
+	return `
+            <a href="/notifications" title="Notifications">🔔` + badge + `</a>`
+}
+
+func (s *Server) renderNotificationsPage(w http.ResponseWriter, user *models.User, notifications []*database.UserNotification, prefs map[string]database.NotificationPreference, saved bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	listHTML := ""
+	for _, n := range notifications {
+		style := "border: 1px solid #e0e0e0;"
+		if n.ReadAt == 0 {
+			style = "border: 1px solid " + s.getPrimaryColor() + "; background: #f8fbff;"
+		}
+		title := template.HTMLEscapeString(n.Title)
+		if n.Link != "" {
+			title = `<a href="` + template.HTMLEscapeString(n.Link) + `" style="color: #333;">` + title + `</a>`
+		}
+		listHTML += `
+            <div class="notification" style="` + style + `">
+                <div style="display: flex; justify-content: space-between; gap: 15px;">
+                    <strong>` + title + `</strong>
+                    <span style="color: #999; font-size: 13px; white-space: nowrap;">` + time.Unix(n.CreatedAt, 0).In(database.ServerLocation()).Format("2006-01-02 15:04") + `</span>
+                </div>
+                <p style="color: #666; font-size: 14px; margin-top: 6px;">` + template.HTMLEscapeString(n.Message) + `</p>
+            </div>`
+	}
+	if listHTML == "" {
+		listHTML = `
+            <p style="color: #999;">You have no notifications.</p>`
+	}
+
+	checkbox := func(name string, checked, disabled bool) string {
+		attrs := ""
+		if checked {
+			attrs += " checked"
+		}
+		if disabled {
+			attrs += " disabled"
+		}
+		return `<input type="checkbox" name="` + name + `" value="true"` + attrs + ` style="width: 18px; height: 18px;">`
+	}
+
+	rowsHTML := ""
+	for _, category := range database.NotificationCategories {
+		if category.AdminOnly && !user.IsAdmin() {
+			continue
+		}
+		pref := prefs[category.Key]
+		description := template.HTMLEscapeString(category.Description)
+		if category.Required {
+			description += ` <em>(always sent)</em>`
+		}
+		rowsHTML += `
+                    <tr>
+                        <td>
+                            <strong>` + template.HTMLEscapeString(category.Name) + `</strong>
+                            <div style="color: #666; font-size: 13px;">` + description + `</div>
+                        </td>
+                        <td style="text-align: center;">` + checkbox("email_"+category.Key, pref.Email, category.Required) + `</td>
+                        <td style="text-align: center;">` + checkbox("inapp_"+category.Key, pref.InApp, category.Required) + `</td>
+                    </tr>`
+	}
+
+	savedHTML := ""
+	if saved {
+		savedHTML = `
+            <div style="background: #e8f5e9; border: 1px solid #4caf50; color: #2e7d32; padding: 12px 15px; border-radius: 8px; margin-bottom: 20px;">Notification preferences saved.</div>`
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Notifications - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            padding: 30px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            margin-bottom: 20px;
+        }
+        .card h2 {
+            margin-bottom: 20px;
+            color: #333;
+        }
+        .notification {
+            padding: 15px 20px;
+            border-radius: 8px;
+            margin-bottom: 10px;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+            margin-bottom: 20px;
+        }
+        th, td {
+            padding: 12px;
+            border-bottom: 1px solid #e0e0e0;
+            text-align: left;
+        }
+        th {
+            color: #666;
+            font-size: 13px;
+            text-transform: uppercase;
+        }
+        .btn {
+            color: white;
+            padding: 10px 20px;
+            border: none;
+            border-radius: 6px;
+            cursor: pointer;
+            font-size: 14px;
+            font-weight: 600;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getHeaderHTML(user, user.IsAdmin()) + `
+    <div class="container">
+        <div class="card">
+            <h2>🔔 Notifications</h2>` + listHTML + `
+        </div>
+
+        <div class="card" id="preferences">
+            <h2>Notification Preferences</h2>` + savedHTML + `
+            <p style="color: #666; margin-bottom: 20px;">Choose which notifications you get by email and which are shown here. Security notifications can't be turned off.</p>
+            <form method="POST" action="/notifications/preferences">
+                <table>
+                    <thead>
+                        <tr>
+                            <th>Category</th>
+                            <th style="text-align: center; width: 100px;">Email</th>
+                            <th style="text-align: center; width: 100px;">In-app</th>
+                        </tr>
+                    </thead>
+                    <tbody>` + rowsHTML + `
+                    </tbody>
+                </table>
+                <button type="submit" class="btn" style="background: ` + s.getPrimaryColor() + `;">Save Preferences</button>
+            </form>
+            <form method="POST" action="/notifications/preferences" style="margin-top: 15px;" onsubmit="return confirm('Turn off all emails except security notifications?')">
+                <input type="hidden" name="unsubscribe_all" value="true">
+                <button type="submit" class="btn" style="background: #757575;">Unsubscribe from All Non-Security Emails</button>
+            </form>
+        </div>
+    </div>
+    <div style="text-align:center; font-size: 0.8em; margin-top: 2em; padding: 1em; color:#777;">
+        Powered by WulfVault © Ulf Holmström – AGPL-3.0
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
@@ -8,6 +8,7 @@ package server
 import (
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/Frimurare/WulfVault/internal/auth"
 	"github.com/Frimurare/WulfVault/internal/database"
@@ -124,8 +125,8 @@ func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(password) < 6 {
-		s.renderResetPasswordPage(w, token, "Password must be at least 6 characters")
+	if err := auth.ValidatePasswordStrength(password); err != nil {
+		s.renderResetPasswordPage(w, token, err.Error())
 		return
 	}
 
@@ -327,6 +328,7 @@ func (s *Server) renderResetPasswordPage(w http.ResponseWriter, token, errorMsg
 	if errorMsg != "" {
 		errorHTML = `<div class="error-message">` + errorMsg + `</div>`
 	}
+	minLength := strconv.Itoa(auth.PasswordMinLength())
 
 	// If no token, show error page
 	if token == "" {
@@ -512,8 +514,8 @@ func (s *Server) renderResetPasswordPage(w http.ResponseWriter, token, errorMsg
             const password = document.getElementById('password').value;
             const confirmPassword = document.getElementById('confirm_password').value;
 
-            if (password.length < 6) {
-                alert('Lösenordet måste vara minst 6 tecken långt');
+            if (password.length < ` + minLength + `) {
+                alert('Lösenordet måste vara minst ` + minLength + ` tecken långt');
                 return false;
             }
 
@@ -537,7 +539,7 @@ func (s *Server) renderResetPasswordPage(w http.ResponseWriter, token, errorMsg
 
         <div class="info-box">
             <p><strong>Tips:</strong></p>
-            <p>• Minst 6 tecken</p>
+            <p>• Minst ` + minLength + ` tecken, med minst en bokstav och en siffra eller ett specialtecken</p>
             <p>• Håll in ögat-ikonen för att se lösenordet</p>
             <p>• Se till att båda fälten matchar</p>
         </div>
@@ -545,7 +547,7 @@ func (s *Server) renderResetPasswordPage(w http.ResponseWriter, token, errorMsg
         <form method="POST" action="/reset-password?token=` + token + `" onsubmit="return validateForm()">
             <div class="form-group">
                 <label for="password">Nytt Lösenord</label>
-                <input type="password" id="password" name="password" required minlength="6" autofocus>
+                <input type="password" id="password" name="password" required minlength="` + minLength + `" autofocus>
                 <span class="password-toggle" id="password_icon"
                       onmousedown="togglePassword('password')"
                       onmouseup="togglePassword('password')"
@@ -553,7 +555,7 @@ func (s *Server) renderResetPasswordPage(w http.ResponseWriter, token, errorMsg
             </div>
             <div class="form-group">
                 <label for="confirm_password">Bekräfta Nytt Lösenord</label>
-                <input type="password" id="confirm_password" name="confirm_password" required minlength="6">
+                <input type="password" id="confirm_password" name="confirm_password" required minlength="` + minLength + `">
                 <span class="password-toggle" id="confirm_password_icon"
                       onmousedown="togglePassword('confirm_password')"
                       onmouseup="togglePassword('confirm_password')"
@@ -0,0 +1,151 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/i18n"
+)
+
+// maxReshareRequestsPerHour limits how many new link requests one IP address can send
+const maxReshareRequestsPerHour = 10
+
+// reshareRequestFormHTML returns the "request a new link" form shown on a file's expired page
+func reshareRequestFormHTML(fileId string, locale i18n.Locale, formError, primaryColor string) string {
+	inputStyle := `width: 100%; padding: 10px 12px; border: 2px solid #e0e0e0; border-radius: 8px; font-size: 15px; font-family: inherit; margin-top: 4px;`
+	labelStyle := `display: block; text-align: left; margin-bottom: 12px; color: #555; font-size: 14px; font-weight: 600;`
+
+	errorHTML := ""
+	if formError != "" {
+		errorHTML = `
+            <div style="background: #ffebee; color: #c62828; padding: 10px; border-radius: 8px; margin-bottom: 12px; font-size: 14px;">` + template.HTMLEscapeString(formError) + `</div>`
+	}
+
+	return `
+        <div style="margin-top: 30px; padding-top: 25px; border-top: 1px solid #eee;">
+            <p style="margin-bottom: 15px;">` + i18n.T(locale.Code, "reshare.intro") + `</p>` + errorHTML + `
+            <form method="POST" action="/file/reshare-request">
+                <input type="hidden" name="file_id" value="` + template.HTMLEscapeString(fileId) + `">
+                <label style="` + labelStyle + `">` + i18n.T(locale.Code, "reshare.name") + `
+                    <input type="text" name="name" maxlength="200" style="` + inputStyle + `">
+                </label>
+                <label style="` + labelStyle + `">` + i18n.T(locale.Code, "reshare.email") + `
+                    <input type="email" name="email" required style="` + inputStyle + `">
+                </label>
+                <label style="` + labelStyle + `">` + i18n.T(locale.Code, "reshare.message") + `
+                    <textarea name="message" rows="3" maxlength="1000" style="` + inputStyle + `"></textarea>
+                </label>
+                <button type="submit" style="background: ` + primaryColor + `; color: white; border: none; padding: 12px 30px; border-radius: 8px; font-size: 16px; font-weight: 600; cursor: pointer;">🔁 ` + i18n.T(locale.Code, "reshare.button") + `</button>
+            </form>
+        </div>`
+}
+
+// handleFileReshareRequest records a request for a new link from the expired page and tells the
+// file owner (POST /file/reshare-request). The requester sees the same confirmation whether or
+// not the request was new, so the form can't be used to find out who already asked.
+func (s *Server) handleFileReshareRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	locale := recipientLocale(w, r)
+
+	fileId := r.FormValue("file_id")
+	inTrash := false
+	fileInfo, err := database.DB.GetFileByID(fileId)
+	if err != nil {
+		fileInfo, err = database.DB.GetExpiredTrashedFile(fileId)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		inTrash = true
+	}
+
+	reason := fileInfo.ExpiredReason(time.Now())
+	if reason == "" || !database.DB.IsFileReshareRequestsEnabled(fileInfo.Id) {
+		s.renderSplashPageUnavailable(w, "🚫", "Requests Not Accepted", "The owner of this file does not accept requests for a new link.")
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	requesterEmail := strings.TrimSpace(r.FormValue("email"))
+	message := strings.TrimSpace(r.FormValue("message"))
+	if len(message) > 1000 {
+		message = message[:1000]
+	}
+
+	if addr, err := mail.ParseAddress(requesterEmail); err != nil || addr.Address != requesterEmail {
+		s.renderSplashPageExpired(w, fileInfo, locale, reason, i18n.T(locale.Code, "reshare.invalid_email"))
+		return
+	}
+
+	ip := clientIP(r)
+	if database.DB.CountReshareRequestsFromIP(ip, time.Now().Add(-time.Hour).Unix()) >= maxReshareRequestsPerHour {
+		s.renderSplashPageExpired(w, fileInfo, locale, reason, i18n.T(locale.Code, "reshare.too_many"))
+		return
+	}
+
+	request, created, err := database.DB.CreateReshareRequest(fileInfo.Id, requesterEmail, name, message, reason, ip)
+	if err != nil {
+		log.Printf("Failed to create new link request for file %s: %v", fileInfo.Id, err)
+		http.Error(w, "Could not send your request. Please try again later.", http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		log.Printf("New link for expired file %s (%s) requested by %s", fileInfo.Id, fileInfo.Name, request.Email)
+
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     0,
+			UserEmail:  request.Email,
+			Action:     database.ActionFileReshareRequested,
+			EntityType: database.EntityFile,
+			EntityID:   fileInfo.Id,
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"file_name":  fileInfo.Name,
+				"request_id": request.Id,
+				"name":       request.Name,
+				"reason":     reason,
+			}),
+			IPAddress: ip,
+			UserAgent: r.UserAgent(),
+			Success:   true,
+		})
+
+		go func() {
+			owner, err := database.DB.GetUserByID(fileInfo.UserId)
+			if err != nil {
+				log.Printf("Failed to find owner of file %s for new link request: %v", fileInfo.Id, err)
+				return
+			}
+			requester := request.Email
+			if request.Name != "" {
+				requester = request.Name + " (" + request.Email + ")"
+			}
+			if !database.DB.NotifyUser(owner.Id, database.NotifyReshareRequests, "New link requested", requester+" asked for a new link to "+fileInfo.Name, "/dashboard") {
+				return
+			}
+			if err := email.SendReshareRequestEmail(owner.Email, fileInfo.Name, request.Name, request.Email, request.Message, reason, inTrash, s.getPublicURL(), s.config.CompanyName); err != nil {
+				log.Printf("Failed to send new link request to %s: %v", owner.Email, err)
+			}
+		}()
+	}
+
+	s.renderLocalizedSplashPageUnavailable(w, locale, "📨", i18n.T(locale.Code, "reshare.sent_title"), i18n.T(locale.Code, "reshare.sent_message"), "", languageSwitcherHTML(locale))
+}
@@ -10,13 +10,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Frimurare/WulfVault/internal/auth"
 	"github.com/Frimurare/WulfVault/internal/database"
 	"github.com/Frimurare/WulfVault/internal/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/Frimurare/WulfVault/internal/storage"
 )
 
 // ===========================
@@ -342,9 +344,13 @@ func (s *Server) handleAPICreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Name, email, and password are required", http.StatusBadRequest)
 		return
 	}
+	if err := auth.ValidatePasswordStrength(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := auth.HashPassword(req.Password)
 	if err != nil {
 		http.Error(w, "Error hashing password", http.StatusInternalServerError)
 		return
@@ -361,7 +367,7 @@ func (s *Server) handleAPICreateUser(w http.ResponseWriter, r *http.Request) {
 	user := &models.User{
 		Name:           req.Name,
 		Email:          req.Email,
-		Password:       string(hashedPassword),
+		Password:       hashedPassword,
 		UserLevel:      models.UserRank(req.UserLevel),
 		Permissions:    models.UserPermission(req.Permissions),
 		StorageQuotaMB: req.StorageQuotaMB,
@@ -384,7 +390,7 @@ func (s *Server) handleAPICreateUser(w http.ResponseWriter, r *http.Request) {
 		EntityType: "User",
 		EntityID:   fmt.Sprintf("%d", user.Id),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"user_level\":%d}", user.Email, user.Name, user.UserLevel),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -438,6 +444,7 @@ func (s *Server) handleAPIUpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update fields
+	oldLevel := user.UserLevel
 	user.Name = req.Name
 	user.Email = req.Email
 	user.UserLevel = models.UserRank(req.UserLevel)
@@ -447,12 +454,16 @@ func (s *Server) handleAPIUpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	// Update password if provided
 	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err := auth.ValidatePasswordStrength(req.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hashedPassword, err := auth.HashPassword(req.Password)
 		if err != nil {
 			http.Error(w, "Error hashing password", http.StatusInternalServerError)
 			return
 		}
-		user.Password = string(hashedPassword)
+		user.Password = hashedPassword
 	}
 
 	if err := database.DB.UpdateUser(user); err != nil {
@@ -470,11 +481,12 @@ func (s *Server) handleAPIUpdateUser(w http.ResponseWriter, r *http.Request) {
 		EntityType: "User",
 		EntityID:   fmt.Sprintf("%d", user.Id),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"user_level\":%d}", user.Email, user.Name, user.UserLevel),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
 	})
+	s.logUserLevelChange(currentUser, user, oldLevel, r)
 
 	// Remove sensitive data
 	user.Password = ""
@@ -533,7 +545,7 @@ func (s *Server) handleAPIDeleteUser(w http.ResponseWriter, r *http.Request) {
 		EntityType: "User",
 		EntityID:   fmt.Sprintf("%d", userId),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\"}", deletedUser.Email, deletedUser.Name),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -583,7 +595,7 @@ func (s *Server) handleAPIGetUserFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := database.DB.GetFilesByUser(userId)
+	files, err := database.DB.GetFilesFiltered(&database.FileFilter{UserId: userId, Category: fileCategoryFromRequest(r)})
 	if err != nil {
 		log.Printf("Error fetching user files: %v", err)
 		http.Error(w, "Error fetching files", http.StatusInternalServerError)
@@ -706,6 +718,9 @@ func (s *Server) handleAPIUpdateFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Apply the deployment's maximum file expiry
+	req.ExpireAt, req.ExpireAtString, req.UnlimitedTime = limitFileExpiry(req.ExpireAt, req.ExpireAtString, req.UnlimitedTime)
+
 	// Update file settings
 	if err := database.DB.UpdateFileSettings(fileId, req.DownloadsRemaining, req.ExpireAt,
 		req.ExpireAtString, req.UnlimitedDownloads, req.UnlimitedTime); err != nil {
@@ -729,12 +744,12 @@ func (s *Server) handleAPIUpdateFile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"file":    file,
+		"file":    s.buildFileDetail(file),
 	})
 }
 
 // handleAPIDeleteFile deletes a file
-// DELETE /api/v1/files/{id}
+// DELETE /api/v1/files/{id}?trash_retention_days=N (optional, within the configured bounds)
 func (s *Server) handleAPIDeleteFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -762,7 +777,21 @@ func (s *Server) handleAPIDeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := database.DB.DeleteFile(fileId, user.Id); err != nil {
+	trashRetentionDays := 0
+	if value := r.URL.Query().Get("trash_retention_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid trash_retention_days", http.StatusBadRequest)
+			return
+		}
+		if err := database.DB.ValidateTrashRetentionOverride(days); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		trashRetentionDays = days
+	}
+
+	if err := database.DB.DeleteFileWithRetention(fileId, user.Id, trashRetentionDays); err != nil {
 		log.Printf("Error deleting file: %v", err)
 		http.Error(w, "Error deleting file", http.StatusInternalServerError)
 		return
@@ -807,10 +836,124 @@ func (s *Server) handleAPIGetFile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"file":    file,
+		"file":    s.buildFileDetail(file),
 	})
 }
 
+// fileTeamSummary is a team a file is shared with
+type fileTeamSummary struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// fileDetail is the API representation of a single file. Passwords (plain or hashed)
+// are never included; passwordProtected only tells whether one is set.
+type fileDetail struct {
+	Id                 string            `json:"id"`
+	Name               string            `json:"name"`
+	Size               string            `json:"size"`
+	SizeBytes          int64             `json:"sizeBytes"`
+	ContentType        string            `json:"contentType"`
+	Category           string            `json:"category"`
+	SHA1               string            `json:"sha1"`
+	SHA256             string            `json:"sha256"`
+	UploadDate         int64             `json:"uploadDate"`
+	UserId             int               `json:"userId"`
+	Comment            string            `json:"comment"`
+	ExpiryMode         string            `json:"expiryMode"` // "never" or "date"
+	ExpireAt           int64             `json:"expireAt"`
+	ExpireAtString     string            `json:"expireAtString"`
+	UnlimitedTime      bool              `json:"unlimitedTime"`
+	DownloadCount      int               `json:"downloadCount"`
+	DownloadsRemaining int               `json:"downloadsRemaining"`
+	UnlimitedDownloads bool              `json:"unlimitedDownloads"`
+	RequireAuth        bool              `json:"requireAuth"`
+	PasswordProtected  bool              `json:"passwordProtected"`
+	RequireTerms       bool              `json:"requireTerms"`
+	SplashTemplate     string            `json:"splashTemplate"`
+	ExpiryReminders    bool              `json:"expiryReminders"`
+	PrivateDownloadLog bool              `json:"privateDownloadLog"`
+	BurnAfterDownload  bool              `json:"burnAfterDownload"`
+	Slug               string            `json:"slug,omitempty"`
+	ActivateAt         int64             `json:"activateAt,omitempty"`
+	ApprovalStatus     string            `json:"approvalStatus,omitempty"`
+	Teams              []fileTeamSummary `json:"teams"`
+	Available          bool              `json:"available"`
+	UnavailableReason  string            `json:"unavailableReason,omitempty"`
+	SplashURL          string            `json:"splashUrl"`
+	DownloadURL        string            `json:"downloadUrl"`
+}
+
+// buildFileDetail collects everything the API exposes about a file
+func (s *Server) buildFileDetail(file *database.FileInfo) *fileDetail {
+	detail := &fileDetail{
+		Id:                 file.Id,
+		Name:               file.Name,
+		Size:               file.Size,
+		SizeBytes:          file.SizeBytes,
+		ContentType:        file.ContentType,
+		Category:           file.Category,
+		SHA1:               file.SHA1,
+		SHA256:             s.getFileSHA256(file.Id),
+		UploadDate:         file.UploadDate,
+		UserId:             file.UserId,
+		Comment:            file.Comment,
+		ExpiryMode:         "date",
+		ExpireAt:           file.ExpireAt,
+		ExpireAtString:     file.ExpireAtString,
+		UnlimitedTime:      file.UnlimitedTime,
+		DownloadCount:      file.DownloadCount,
+		DownloadsRemaining: file.DownloadsRemaining,
+		UnlimitedDownloads: file.UnlimitedDownloads,
+		RequireAuth:        file.RequireAuth,
+		PasswordProtected:  file.FilePasswordPlain != "" || file.PasswordHash != "",
+		RequireTerms:       database.DB.IsFileTermsRequired(file.Id),
+		SplashTemplate:     database.DB.GetFileSplashTemplate(file.Id),
+		ExpiryReminders:    database.DB.IsFileExpiryRemindersEnabled(file.Id),
+		PrivateDownloadLog: database.DB.IsDownloadLogPrivate(file.Id),
+		BurnAfterDownload:  file.BurnAfterDownload,
+		ApprovalStatus:     s.getPublicLinkApprovalStatus(file),
+		Teams:              []fileTeamSummary{},
+		Slug:               database.DB.GetFileSlug(file.Id),
+		ActivateAt:         file.ActivateAt,
+		SplashURL:          s.getPublicURL() + "/s/" + fileLinkID(file.Id),
+		DownloadURL:        s.getPublicURL() + "/d/" + fileLinkID(file.Id),
+	}
+
+	if file.UnlimitedTime || file.ExpireAt == 0 {
+		detail.ExpiryMode = "never"
+	}
+
+	if teams, err := database.DB.GetFileTeams(file.Id); err == nil {
+		for _, team := range teams {
+			detail.Teams = append(detail.Teams, fileTeamSummary{Id: team.Id, Name: team.Name})
+		}
+	}
+
+	// Mirror the checks made when the file is downloaded
+	switch expired := file.ExpiredReason(time.Now()); {
+	case expired != "":
+		detail.UnavailableReason = expired
+	case detail.ApprovalStatus == database.ApprovalStatusPending:
+		detail.UnavailableReason = "awaiting_approval"
+	case detail.ApprovalStatus == database.ApprovalStatusRejected:
+		detail.UnavailableReason = "rejected"
+	case file.IsScheduled(time.Now()):
+		detail.UnavailableReason = "scheduled"
+	case fileScheduleUnavailableMessage(file) != "":
+		detail.UnavailableReason = "outside_schedule"
+	case file.ScanStatus == database.ScanInfected:
+		detail.UnavailableReason = "quarantined"
+	case file.ScanStatus == database.ScanPending:
+		detail.UnavailableReason = "virus_scan_pending"
+	case file.ScanStatus == database.ScanError && fileScanUnavailableMessage(file) != "":
+		detail.UnavailableReason = "virus_scan_failed"
+	}
+	detail.Available = detail.UnavailableReason == ""
+
+	return detail
+}
+
 // handleAPIGetFileDownloads returns download history for a file
 // GET /api/v1/files/{id}/downloads
 func (s *Server) handleAPIGetFileDownloads(w http.ResponseWriter, r *http.Request) {
@@ -994,20 +1137,27 @@ func (s *Server) handleAPICreateDownloadAccount(w http.ResponseWriter, r *http.R
 		http.Error(w, "Name, email, and password are required", http.StatusBadRequest)
 		return
 	}
+	if err := auth.ValidatePasswordStrength(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := auth.HashPassword(req.Password)
 	if err != nil {
 		http.Error(w, "Error hashing password", http.StatusInternalServerError)
 		return
 	}
 
+	user, _ := userFromContext(r.Context())
+
 	account := &models.DownloadAccount{
 		Name:      req.Name,
 		Email:     req.Email,
-		Password:  string(hashedPassword),
+		Password:  hashedPassword,
 		IsActive:  req.IsActive,
 		CreatedAt: time.Now().Unix(),
+		CreatedBy: user.Id,
 	}
 
 	if err := database.DB.CreateDownloadAccount(account); err != nil {
@@ -1017,7 +1167,6 @@ func (s *Server) handleAPICreateDownloadAccount(w http.ResponseWriter, r *http.R
 	}
 
 	// Log the action
-	user, _ := userFromContext(r.Context())
 	database.DB.LogAction(&database.AuditLogEntry{
 		UserID:     int64(user.Id),
 		UserEmail:  user.Email,
@@ -1025,7 +1174,7 @@ func (s *Server) handleAPICreateDownloadAccount(w http.ResponseWriter, r *http.R
 		EntityType: "DownloadAccount",
 		EntityID:   fmt.Sprintf("%d", account.Id),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"admin_created\":true}", account.Email, account.Name),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -1078,12 +1227,16 @@ func (s *Server) handleAPIUpdateDownloadAccount(w http.ResponseWriter, r *http.R
 	account.IsActive = req.IsActive
 
 	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err := auth.ValidatePasswordStrength(req.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hashedPassword, err := auth.HashPassword(req.Password)
 		if err != nil {
 			http.Error(w, "Error hashing password", http.StatusInternalServerError)
 			return
 		}
-		account.Password = string(hashedPassword)
+		account.Password = hashedPassword
 	}
 
 	if err := database.DB.UpdateDownloadAccount(account); err != nil {
@@ -1138,7 +1291,7 @@ func (s *Server) handleAPIDeleteDownloadAccount(w http.ResponseWriter, r *http.R
 		EntityType: "DownloadAccount",
 		EntityID:   fmt.Sprintf("%d", accountId),
 		Details:    fmt.Sprintf("{\"email\":\"%s\",\"name\":\"%s\",\"admin_deleted\":true}", account.Email, account.Name),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -1274,6 +1427,19 @@ func (s *Server) handleAPICreateFileRequest(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if message := s.checkFileRequestMaxSize(req.MaxFileSize); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+
+	if limitMessage, err := checkFileRequestLimit(user); err != nil {
+		http.Error(w, "Error checking upload request limit", http.StatusInternalServerError)
+		return
+	} else if limitMessage != "" {
+		http.Error(w, limitMessage, http.StatusForbidden)
+		return
+	}
+
 	fileRequest := &models.FileRequest{
 		Title:            req.Title,
 		Message:          req.Message,
@@ -1341,6 +1507,11 @@ func (s *Server) handleAPIUpdateFileRequest(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if message := s.checkFileRequestMaxSize(req.MaxFileSize); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+
 	fileRequest.Title = req.Title
 	fileRequest.Message = req.Message
 	fileRequest.MaxFileSize = req.MaxFileSize
@@ -1523,7 +1694,7 @@ func (s *Server) handleAPIRestoreFile(w http.ResponseWriter, r *http.Request) {
 		EntityType: "File",
 		EntityID:   fileId,
 		Details:    fmt.Sprintf("{\"filename\":\"%s\",\"size\":\"%s\"}", fileInfo.Name, fileInfo.Size),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 	})
@@ -1570,6 +1741,14 @@ func (s *Server) handleAPIPermanentDeleteFile(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Delete from storage
+	if err := storage.Files.Delete(fileId); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Could not delete file from storage: %v", err)
+		}
+	}
+	s.removeConvertedImages(fileId)
+
 	// Permanently delete file
 	if err := database.DB.PermanentDeleteFile(fileId); err != nil {
 		log.Printf("Error permanently deleting file: %v", err)
@@ -1586,7 +1765,7 @@ func (s *Server) handleAPIPermanentDeleteFile(w http.ResponseWriter, r *http.Req
 		EntityType: "File",
 		EntityID:   fileId,
 		Details:    fmt.Sprintf("{\"filename\":\"%s\",\"size\":\"%s\"}", fileInfo.Name, fileInfo.Size),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 	})
@@ -1692,6 +1871,24 @@ func (s *Server) handleAPIUpdateBranding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Colors are written into the CSS of every page, so reject anything but hex colors and color names
+	if req.PrimaryColor != "" {
+		color, err := normalizeColor(req.PrimaryColor)
+		if err != nil {
+			http.Error(w, "Invalid primaryColor: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.PrimaryColor = color
+	}
+	if req.SecondaryColor != "" {
+		color, err := normalizeColor(req.SecondaryColor)
+		if err != nil {
+			http.Error(w, "Invalid secondaryColor: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.SecondaryColor = color
+	}
+
 	// Update branding config
 	if err := database.DB.SetConfigValue("branding_company_name", req.CompanyName); err != nil {
 		log.Printf("Error updating company name: %v", err)
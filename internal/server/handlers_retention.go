@@ -0,0 +1,277 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/cleanup"
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// retentionPolicy is one row of the data retention overview
+type retentionPolicy struct {
+	Name        string
+	Value       string
+	Description string
+	Job         string // scheduled job that enforces the policy, "" if it is applied as data is written
+	SettingsURL string // "" if the policy can't be changed
+}
+
+// handleAdminRetention shows every retention and cleanup policy with its current value and
+// when it is enforced next (GET /admin/retention)
+func (s *Server) handleAdminRetention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.renderAdminRetention(w, s.retentionPolicies())
+}
+
+// retentionPolicies reads the current retention settings
+func (s *Server) retentionPolicies() []retentionPolicy {
+	trashDays := database.DB.GetConfigInt("trash_retention_days", s.config.TrashRetentionDays)
+	trashValue := fmt.Sprintf("%d days", trashDays)
+	if min, max := database.DB.GetTrashRetentionOverrideBounds(); max > 0 {
+		trashValue += fmt.Sprintf(" (users may choose %d–%d days per file)", min, max)
+	}
+
+	expiredValue := "Moved to trash when they expire"
+	if grace := database.DB.GetExpiredFileGrace(); grace > 0 {
+		expiredValue += fmt.Sprintf("; flagged if still active %d hours after expiry", int(grace.Hours()))
+		if autoTrash, _ := database.DB.GetConfigValue("expired_files_auto_trash"); autoTrash == "true" {
+			expiredValue += " and trashed right away"
+		}
+	}
+
+	auditDays := database.DB.GetConfigInt("audit_log_retention_days", s.config.AuditLogRetentionDays)
+	auditSizeMB := database.DB.GetConfigInt("audit_log_max_size_mb", s.config.AuditLogMaxSizeMB)
+
+	downloadLogValue := "Kept until the file is permanently deleted"
+	if database.DB.IsDownloadLogDetailsDisabled() {
+		downloadLogValue += "; IP addresses and user agents are not recorded"
+	} else if database.DB.GetPrivateDownloadLogDefault() {
+		downloadLogValue += "; IP addresses and user agents are off by default for new files"
+	} else {
+		downloadLogValue += "; IP addresses and user agents are recorded unless the file owner turns them off"
+	}
+
+	idleValue := "Off"
+	if idleDays := database.DB.GetConfigInt("download_account_idle_days", 0); idleDays > 0 {
+		graceDays := database.DB.GetConfigInt("download_account_grace_days", database.DefaultDownloadAccountGraceDays)
+		idleValue = fmt.Sprintf("Deactivated after %d days without use (%d days grace for new accounts)", idleDays, graceDays)
+	}
+
+	tokenValue := ""
+	for i, tokenType := range database.TokenTypes {
+		if i > 0 {
+			tokenValue += ", "
+		}
+		tokenValue += fmt.Sprintf("%s: %d hours", tokenType.Label, database.DB.GetTokenRetentionHours(tokenType))
+	}
+	backupValue := "Off"
+	if schedule := database.DB.GetBackupSchedule(); schedule.IntervalHours > 0 {
+		backupValue = fmt.Sprintf("Every %d hours, the last %d kept", schedule.IntervalHours, schedule.RetentionCount)
+	}
+
+	firstTokenKey := ""
+	if len(database.TokenTypes) > 0 {
+		firstTokenKey = database.TokenTypes[0].RetentionConfigKey()
+	}
+
+	return []retentionPolicy{
+		{
+			Name:        "Trash",
+			Value:       trashValue,
+			Description: "Deleted files can be restored until they are permanently removed from disk.",
+			Job:         cleanup.JobTrash,
+			SettingsURL: "/admin/settings#trash_retention_days",
+		},
+		{
+			Name:        "Expired files",
+			Value:       expiredValue,
+			Description: "Files past their expiry date or download limit are moved to trash.",
+			Job:         cleanup.JobExpiredFiles,
+			SettingsURL: "/admin/settings#expired_file_grace_hours",
+		},
+		{
+			Name:        "Audit logs",
+			Value:       fmt.Sprintf("%d days, at most %d MB", auditDays, auditSizeMB),
+			Description: "Older entries are removed, oldest first when the log grows past its size limit.",
+			Job:         cleanup.JobAuditLogs,
+			SettingsURL: "/admin/settings#audit_log_retention_days",
+		},
+		{
+			Name:        "Server log",
+			Value:       fmt.Sprintf("Rotated at %d MB", database.DB.GetConfigInt("server_log_max_size_mb", s.config.ServerLogMaxSizeMB)),
+			Description: "The log file is rotated when it reaches its size limit.",
+			SettingsURL: "/admin/settings#server_log_max_size_mb",
+		},
+		{
+			Name:        "Download logs",
+			Value:       downloadLogValue,
+			Description: "Download logs are removed with the file, and a download account's logs are removed when the account is deleted under GDPR.",
+			SettingsURL: "/admin/settings#download_log_details",
+		},
+		{
+			Name:        "Deleted accounts",
+			Value:       fmt.Sprintf("%d days", database.DB.GetDeletedAccountRetentionDays()),
+			Description: "Deleted users and download accounts can be restored until they are removed permanently.",
+			Job:         cleanup.JobDeletedAccounts,
+			SettingsURL: "/admin/settings#deleted_account_retention_days",
+		},
+		{
+			Name:        "Idle download accounts",
+			Value:       idleValue,
+			Description: "Download accounts that haven't been used are deactivated and their creators notified.",
+			Job:         cleanup.JobDownloadAccountIdle,
+			SettingsURL: "/admin/settings#download_account_idle_days",
+		},
+		{
+			Name:        "Expired upload requests",
+			Value:       fmt.Sprintf("%d days after expiry", database.DB.GetConfigInt("file_request_retention_days", database.DefaultFileRequestRetentionDays)),
+			Description: "Expired upload request links show an \"expired\" message until the request is deleted.",
+			Job:         cleanup.JobFileRequests,
+			SettingsURL: "/admin/settings#file_request_retention_days",
+		},
+		{
+			Name:        "Expired tokens",
+			Value:       tokenValue,
+			Description: "Expired sessions and links are removed after this long.",
+			Job:         cleanup.JobTokens,
+			SettingsURL: "/admin/settings#" + firstTokenKey,
+		},
+		{
+			Name:        "Unfinished uploads",
+			Value:       fmt.Sprintf("%d hours without activity", int(orphanedChunkMaxAge.Hours())),
+			Description: "Chunks of uploads that were abandoned are deleted. This can't be changed.",
+			Job:         cleanup.JobUploadChunks,
+		},
+		{
+			Name:        "Scheduled backups",
+			Value:       backupValue,
+			Description: "Database snapshots are written to the backup target, and older ones beyond the number to keep are deleted.",
+			Job:         cleanup.JobBackup,
+			SettingsURL: "/admin/backup#schedule",
+		},
+	}
+}
+
+// formatRetentionTime formats a job run time for the retention overview
+func formatRetentionTime(t time.Time, zero string) string {
+	if t.IsZero() {
+		return zero
+	}
+	return t.In(database.ServerLocation()).Format("2006-01-02 15:04")
+}
+
+func (s *Server) renderAdminRetention(w http.ResponseWriter, policies []retentionPolicy) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	now := time.Now()
+	rowsHTML := ""
+	for _, policy := range policies {
+		lastRun := "—"
+		nextRun := "Applied when data is written"
+		if policy.Job != "" {
+			lastRun = formatRetentionTime(cleanup.JobLastRun(policy.Job), "Not run yet")
+			next := cleanup.JobNextRun(policy.Job)
+			switch {
+			case next.IsZero():
+				nextRun = "Not scheduled"
+			case next.Before(now):
+				nextRun = `<span class="due">Due now</span>`
+			default:
+				nextRun = formatRetentionTime(next, "")
+			}
+		}
+
+		change := `<span class="muted">Fixed</span>`
+		if policy.SettingsURL != "" {
+			change = `<a href="` + template.HTMLEscapeString(policy.SettingsURL) + `" class="change">Change</a>`
+		}
+
+		rowsHTML += `
+                <tr>
+                    <td><strong>` + template.HTMLEscapeString(policy.Name) + `</strong><div class="muted">` + template.HTMLEscapeString(policy.Description) + `</div></td>
+                    <td>` + template.HTMLEscapeString(policy.Value) + `</td>
+                    <td>` + lastRun + `</td>
+                    <td>` + nextRun + `</td>
+                    <td>` + change + `</td>
+                </tr>`
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Data Retention - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 12px; }
+        .intro { color: #666; margin-bottom: 20px; font-size: 14px; }
+        table { width: 100%; border-collapse: collapse; font-size: 14px; }
+        th, td { padding: 12px; text-align: left; border-bottom: 1px solid #eee; vertical-align: top; }
+        th { background: #f9f9f9; color: #555; font-weight: 600; }
+        .muted { color: #888; font-size: 13px; margin-top: 4px; }
+        .due { color: #e65100; font-weight: 600; }
+        .change {
+            color: ` + s.getPrimaryColor() + `;
+            font-weight: 500;
+            text-decoration: none;
+        }
+        .change:hover { text-decoration: underline; }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <div class="card">
+            <h2>🗂️ Data Retention</h2>
+            <p class="intro">How long WulfVault keeps files, logs and accounts, and when each cleanup runs next. Times are in ` + template.HTMLEscapeString(database.ServerLocation().String()) + `.</p>
+            <table>
+                <thead>
+                    <tr>
+                        <th>Policy</th>
+                        <th>Current setting</th>
+                        <th>Last run</th>
+                        <th>Next run</th>
+                        <th></th>
+                    </tr>
+                </thead>
+                <tbody>` + rowsHTML + `
+                </tbody>
+            </table>
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
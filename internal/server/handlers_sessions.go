@@ -0,0 +1,97 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// handleActiveSessions lists the user's login sessions (GET) or ends one of them (POST). The
+// session of the request is flagged as current and can't be ended here; logging out does that.
+func (s *Server) handleActiveSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	currentKey := ""
+	if cookie, err := r.Cookie("session"); err == nil {
+		currentKey = database.SessionKey(cookie.Value)
+	}
+
+	if r.Method == http.MethodGet {
+		sessions, err := database.DB.GetSessionsByUser(user.Id)
+		if err != nil {
+			s.sendError(w, http.StatusInternalServerError, "Failed to load sessions")
+			return
+		}
+
+		type sessionInfo struct {
+			*database.Session
+			Device  string `json:"device"`
+			Current bool   `json:"current"`
+		}
+		list := make([]sessionInfo, 0, len(sessions))
+		for _, session := range sessions {
+			list = append(list, sessionInfo{
+				Session: session,
+				Device:  deviceNameFromUserAgent(session.UserAgent),
+				Current: session.Key == currentKey,
+			})
+		}
+
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{
+			"sessions": list,
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	key := r.FormValue("session_id")
+	if key == "" {
+		s.sendError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+	if key == currentKey {
+		s.sendError(w, http.StatusBadRequest, "This is the session you are using. Log out to end it.")
+		return
+	}
+	if err := database.DB.DeleteUserSession(user.Id, key); err != nil {
+		s.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionSessionRevoked,
+		EntityType: database.EntityUser,
+		EntityID:   strconv.Itoa(user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"session_id": key,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
@@ -180,7 +180,7 @@ func (s *Server) renderAdminSysMonitorLogsPage(w http.ResponseWriter) {
 	faviconHTML := s.getFaviconHTML()
 
 	html := `<!DOCTYPE html>
-<html>
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
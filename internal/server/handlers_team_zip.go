@@ -0,0 +1,312 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// DefaultTeamZipMaxMB is the largest team ZIP download when team_zip_max_mb is not configured
+const DefaultTeamZipMaxMB = 2048
+
+// unsafeZipNameChars are replaced in the name of the downloaded ZIP file
+var unsafeZipNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// teamZipMaxBytes returns the largest total size of a team ZIP download (0 = ZIP downloads disabled)
+func teamZipMaxBytes() int64 {
+	maxMB := database.DB.GetConfigInt("team_zip_max_mb", DefaultTeamZipMaxMB)
+	if maxMB <= 0 {
+		return 0
+	}
+	return int64(maxMB) * 1024 * 1024
+}
+
+// zipSkipReason returns why a file can't be included in a ZIP download, or "" if it can. Files
+// that need a step before downloading (password, download account login, emailed signed link,
+// terms) are left out unless the downloader has access to the file anyway (trusted), as are
+// files that can't be downloaded at all right now.
+func (s *Server) zipSkipReason(fileInfo *database.FileInfo, trusted bool) string {
+	switch fileExpiredReason(fileInfo) {
+	case database.FileExpiredByTime:
+		return "expired"
+	case database.FileExpiredByDownloads:
+		return "download limit reached"
+	}
+	if fileInfo.BurnAfterDownload {
+		return "can only be downloaded once, on its own"
+	}
+	if fileInfo.FilePasswordPlain != "" && !trusted {
+		return "password protected"
+	}
+	if fileInfo.RequireAuth && !trusted {
+		return "requires a download account login"
+	}
+	if requiresSignedLink(fileInfo) && !trusted {
+		return "only opens with the link it was emailed with"
+	}
+	switch s.getPublicLinkApprovalStatus(fileInfo) {
+	case database.ApprovalStatusPending:
+		return "awaiting approval"
+	case database.ApprovalStatusRejected:
+		return "not approved"
+	}
+	if message := fileScheduleUnavailableMessage(fileInfo); message != "" {
+		return "not available at this time"
+	}
+	switch fileInfo.ScanStatus {
+	case database.ScanInfected:
+		return "quarantined (malware found)"
+	case database.ScanPending:
+		return "being scanned for viruses"
+	case database.ScanError:
+		if fileScanUnavailableMessage(fileInfo) != "" {
+			return "could not be scanned for viruses"
+		}
+	}
+	if requiredDownloadTerms(fileInfo) != nil {
+		return "requires accepting the download terms"
+	}
+	if requiresLegalNotice(fileInfo) {
+		return "requires agreeing to the legal notice"
+	}
+	if until, _ := database.DB.GetFileDownloadBlock(fileInfo.Id); !until.IsZero() {
+		return "downloads temporarily locked"
+	}
+	if _, err := storage.Files.Stat(fileInfo.Id); err != nil {
+		return "file not found on disk"
+	}
+	return ""
+}
+
+// uniqueZipName returns name, or name with a number added if it is already used in the archive
+func uniqueZipName(name string, used map[string]bool) string {
+	candidate := name
+	ext := filepath.Ext(name)
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// handleTeamFilesZip downloads all files shared with a team as one ZIP archive (/teams/download-zip?id=N).
+// Each included file counts as a download. Files that can't be included are listed in SKIPPED.txt.
+func (s *Server) handleTeamFilesZip(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	teamId, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is team member or admin
+	if !user.IsAdmin() {
+		isMember, err := database.DB.IsTeamMember(teamId, user.Id)
+		if err != nil || !isMember {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+	}
+
+	team, err := database.DB.GetTeamByID(teamId)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+
+	maxBytes := teamZipMaxBytes()
+	if maxBytes == 0 {
+		http.Error(w, "ZIP downloads are disabled", http.StatusNotFound)
+		return
+	}
+
+	teamFiles, err := database.DB.GetTeamFiles(team.Id, nil)
+	if err != nil {
+		log.Printf("Error fetching team files: %v", err)
+		http.Error(w, "Error fetching files", http.StatusInternalServerError)
+		return
+	}
+
+	var included []*database.FileInfo
+	var skipped []string
+	var totalBytes int64
+	for _, tf := range teamFiles {
+		fileInfo, err := database.DB.GetFileByID(tf.FileId)
+		if err != nil {
+			continue
+		}
+		if reason := s.zipSkipReason(fileInfo, false); reason != "" {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", fileInfo.Name, reason))
+			continue
+		}
+		included = append(included, fileInfo)
+		totalBytes += fileInfo.SizeBytes
+	}
+
+	if len(included) == 0 {
+		http.Error(w, "None of the team's files can be downloaded as a ZIP. Files with a password, login, approval, schedule or terms requirement must be downloaded one by one.", http.StatusNotFound)
+		return
+	}
+	if totalBytes > maxBytes {
+		http.Error(w, fmt.Sprintf("The team's files total %s, more than the %s allowed in one ZIP download. Download them one by one instead.",
+			database.FormatFileSize(totalBytes), database.FormatFileSize(maxBytes)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Mark transfer as active to prevent inactivity timeout during download
+	if cookie, err := r.Cookie("session"); err == nil {
+		s.markTransferActive(cookie.Value)
+		defer s.markTransferInactive(cookie.Value)
+	}
+
+	zipName := strings.Trim(unsafeZipNameChars.ReplaceAllString(team.Name, "-"), "-")
+	if zipName == "" {
+		zipName = "team"
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-files.zip\"", zipName))
+
+	log.Printf("Team ZIP download started: %d files (%s) of team %s by %s", len(included), database.FormatFileSize(totalBytes), team.Name, user.Email)
+
+	zw := zip.NewWriter(w)
+	usedNames := make(map[string]bool)
+	var bytesWritten int64
+	downloaded := 0
+	aborted := false
+	for _, fileInfo := range included {
+		// Each file in the archive counts as a download of that file. The count re-checks
+		// expiry and remaining downloads, which may have changed since the list was made.
+		claimed, err := database.DB.ClaimFileDownload(fileInfo.Id)
+		if err != nil {
+			log.Printf("Warning: Could not update download count: %v", err)
+		}
+		if !claimed {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", fileInfo.Name, "no longer available"))
+			continue
+		}
+
+		n, err := s.addFileToZip(r, zw, fileInfo, uniqueZipName(s.downloadFileName(r, fileInfo, nil), usedNames))
+		bytesWritten += n
+		if err != nil {
+			// The response has started, so the client gets a truncated archive
+			log.Printf("Team ZIP download of team %s aborted at %s: %v", team.Name, fileInfo.Name, err)
+			aborted = true
+			break
+		}
+		downloaded++
+		s.recordZipFileDownload(r, fileInfo, user)
+	}
+
+	if !aborted && len(skipped) > 0 {
+		note, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     uniqueZipName("SKIPPED.txt", usedNames),
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err == nil {
+			io.WriteString(note, "These files are shared with the team but could not be included in this ZIP.\nDownload them one by one from the team page.\n\n"+strings.Join(skipped, "\n")+"\n")
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Team ZIP download of team %s failed: %v", team.Name, err)
+	}
+
+	log.Printf("Team ZIP download completed: %d of %d files (%s) of team %s by %s", downloaded, len(included), database.FormatFileSize(bytesWritten), team.Name, user.Email)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionTeamFilesDownloaded,
+		EntityType: database.EntityTeam,
+		EntityID:   strconv.Itoa(team.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"team_name": team.Name,
+			"files":     downloaded,
+			"skipped":   len(skipped),
+			"bytes":     bytesWritten,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   !aborted,
+	})
+}
+
+// recordZipFileDownload logs the download of a file included in a ZIP archive, the same way
+// as a download of the file on its own. user is nil for anonymous downloads.
+func (s *Server) recordZipFileDownload(r *http.Request, fileInfo *database.FileInfo, user *models.User) {
+	client := downloadClientFromRequest(r, fileInfo)
+	downloadLog := &models.DownloadLog{
+		FileId:          fileInfo.Id,
+		FileName:        fileInfo.Name,
+		FileSize:        fileInfo.SizeBytes,
+		DownloadedAt:    time.Now().Unix(),
+		IpAddress:       client.ip,
+		UserAgent:       client.userAgent,
+		IsAuthenticated: user != nil,
+		Country:         client.country,
+	}
+	authStatus := siemAuthStatus(fileInfo, nil)
+	if user != nil {
+		authStatus = siemAuthUser
+		// Files with a private download log don't record who downloaded them
+		if client.ip != "" {
+			downloadLog.Email = user.Email
+		}
+	}
+	if err := database.DB.CreateDownloadLog(downloadLog); err != nil {
+		log.Printf("Warning: Could not create download log: %v", err)
+	}
+	s.logSIEMEvent(r, siemEventDownload, fileInfo, true, authStatus, downloadLog.Email)
+	s.checkDownloadAnomalies(fileInfo)
+	dispatchDownloadWebhook(fileInfo, client, downloadLog.Email)
+}
+
+// addFileToZip copies a stored file into the archive and returns how many bytes were written.
+// Files are stored uncompressed since most shared files are compressed already. The file's
+// bandwidth limit, if any, applies to the copy.
+func (s *Server) addFileToZip(r *http.Request, zw *zip.Writer, fileInfo *database.FileInfo, name string) (int64, error) {
+	src, err := storage.Files.Get(fileInfo.Id)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: time.Unix(fileInfo.UploadDate, 0),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	limiter, release := acquireDownloadLimiter(fileInfo)
+	defer release()
+	if limiter != nil {
+		return io.Copy(writerFunc(func(b []byte) (int, error) {
+			return limiter.write(r.Context(), dst, b)
+		}), src)
+	}
+	return io.Copy(dst, src)
+}
@@ -7,11 +7,14 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
@@ -23,6 +26,11 @@ import (
 func (s *Server) handleAdminTeams(w http.ResponseWriter, r *http.Request) {
 	_, _ = userFromContext(r.Context())
 
+	// Files may have been deleted since the usage was last counted
+	if err := database.DB.RecalculateAllTeamStorage(); err != nil {
+		log.Printf("Warning: Could not refresh team storage usage: %v", err)
+	}
+
 	teams, err := database.DB.GetAllTeams()
 	if err != nil {
 		log.Printf("Error fetching teams: %v", err)
@@ -59,9 +67,10 @@ func (s *Server) handleAPITeamCreate(w http.ResponseWriter, r *http.Request) {
 	user, _ := userFromContext(r.Context())
 
 	var req struct {
-		Name           string `json:"name"`
-		Description    string `json:"description"`
-		StorageQuotaMB int64  `json:"storageQuotaMB"`
+		Name            string `json:"name"`
+		Description     string `json:"description"`
+		StorageQuotaMB  int64  `json:"storageQuotaMB"`
+		RequireApproval bool   `json:"requireApproval"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -79,11 +88,12 @@ func (s *Server) handleAPITeamCreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	team := &models.Team{
-		Name:           req.Name,
-		Description:    req.Description,
-		CreatedBy:      user.Id,
-		StorageQuotaMB: req.StorageQuotaMB,
-		IsActive:       true,
+		Name:            req.Name,
+		Description:     req.Description,
+		CreatedBy:       user.Id,
+		StorageQuotaMB:  req.StorageQuotaMB,
+		IsActive:        true,
+		RequireApproval: req.RequireApproval,
 	}
 
 	if err := database.DB.CreateTeam(team); err != nil {
@@ -99,8 +109,8 @@ func (s *Server) handleAPITeamCreate(w http.ResponseWriter, r *http.Request) {
 		Action:     "TEAM_CREATED",
 		EntityType: "Team",
 		EntityID:   fmt.Sprintf("%d", team.Id),
-		Details:    fmt.Sprintf("{\"name\":\"%s\",\"storage_quota_mb\":%d}", team.Name, team.StorageQuotaMB),
-		IPAddress:  getClientIP(r),
+		Details:    fmt.Sprintf("{\"name\":\"%s\",\"storage_quota_mb\":%d,\"require_approval\":%v}", team.Name, team.StorageQuotaMB, team.RequireApproval),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -121,10 +131,11 @@ func (s *Server) handleAPITeamUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		TeamId         int    `json:"teamId"`
-		Name           string `json:"name"`
-		Description    string `json:"description"`
-		StorageQuotaMB int64  `json:"storageQuotaMB"`
+		TeamId          int    `json:"teamId"`
+		Name            string `json:"name"`
+		Description     string `json:"description"`
+		StorageQuotaMB  int64  `json:"storageQuotaMB"`
+		RequireApproval bool   `json:"requireApproval"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -141,6 +152,7 @@ func (s *Server) handleAPITeamUpdate(w http.ResponseWriter, r *http.Request) {
 	team.Name = req.Name
 	team.Description = req.Description
 	team.StorageQuotaMB = req.StorageQuotaMB
+	team.RequireApproval = req.RequireApproval
 
 	if err := database.DB.UpdateTeam(team); err != nil {
 		log.Printf("Error updating team: %v", err)
@@ -156,8 +168,8 @@ func (s *Server) handleAPITeamUpdate(w http.ResponseWriter, r *http.Request) {
 		Action:     "TEAM_UPDATED",
 		EntityType: "Team",
 		EntityID:   fmt.Sprintf("%d", team.Id),
-		Details:    fmt.Sprintf("{\"name\":\"%s\",\"storage_quota_mb\":%d}", team.Name, team.StorageQuotaMB),
-		IPAddress:  getClientIP(r),
+		Details:    fmt.Sprintf("{\"name\":\"%s\",\"storage_quota_mb\":%d,\"require_approval\":%v}", team.Name, team.StorageQuotaMB, team.RequireApproval),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -170,13 +182,15 @@ func (s *Server) handleAPITeamUpdate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleAPITeamDelete deletes a team (Admin only)
+// handleAPITeamDelete deletes a team (system admins and team owners)
 func (s *Server) handleAPITeamDelete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	user, _ := userFromContext(r.Context())
+
 	var req struct {
 		TeamId int `json:"teamId"`
 	}
@@ -186,6 +200,11 @@ func (s *Server) handleAPITeamDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canManageTeam(user, req.TeamId) {
+		http.Error(w, "Only team owners can delete the team", http.StatusForbidden)
+		return
+	}
+
 	// Get team details before deletion for audit log
 	team, err := database.DB.GetTeamByID(req.TeamId)
 	if err != nil {
@@ -200,7 +219,6 @@ func (s *Server) handleAPITeamDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log the action
-	user, _ := userFromContext(r.Context())
 	database.DB.LogAction(&database.AuditLogEntry{
 		UserID:     int64(user.Id),
 		UserEmail:  user.Email,
@@ -208,7 +226,7 @@ func (s *Server) handleAPITeamDelete(w http.ResponseWriter, r *http.Request) {
 		EntityType: "Team",
 		EntityID:   fmt.Sprintf("%d", req.TeamId),
 		Details:    fmt.Sprintf("{\"name\":\"%s\"}", team.Name),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -274,19 +292,14 @@ func (s *Server) handleAPITeamAddMember(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check permission: admin OR team owner/admin
-	canManage := false
-	if user.IsAdmin() {
-		canManage = true
-	} else {
-		member, err := database.DB.GetTeamMember(req.TeamId, user.Id)
-		if err == nil && member.CanManageMembers() {
-			canManage = true
-		}
+	if !models.TeamRole(req.Role).IsValid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
 	}
 
-	if !canManage {
-		http.Error(w, "You don't have permission to add members", http.StatusForbidden)
+	// Check permission: admin OR team owner
+	if !canManageTeam(user, req.TeamId) {
+		http.Error(w, "Only team owners can add members", http.StatusForbidden)
 		return
 	}
 
@@ -325,7 +338,7 @@ func (s *Server) handleAPITeamAddMember(w http.ResponseWriter, r *http.Request)
 		EntityType: "TeamMember",
 		EntityID:   fmt.Sprintf("%d", req.TeamId),
 		Details:    fmt.Sprintf("{\"team_id\":%d,\"user_id\":%d,\"user_email\":\"%s\",\"role\":%d}", req.TeamId, req.UserId, targetUser.Email, req.Role),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -333,7 +346,7 @@ func (s *Server) handleAPITeamAddMember(w http.ResponseWriter, r *http.Request)
 
 	// Send invitation email
 	team, _ := database.DB.GetTeamByID(req.TeamId)
-	if team != nil {
+	if team != nil && database.DB.NotifyUser(targetUser.Id, database.NotifyTeams, "Added to a team", "You were added to the team "+team.Name, "/teams") {
 		companyName := s.config.CompanyName
 		if companyName == "" {
 			companyName = "WulfVault"
@@ -370,20 +383,19 @@ func (s *Server) handleAPITeamRemoveMember(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Check permission: admin OR team owner/admin
-	canManage := false
-	if user.IsAdmin() {
-		canManage = true
-	} else {
-		member, err := database.DB.GetTeamMember(req.TeamId, user.Id)
-		if err == nil && member.CanManageMembers() {
-			canManage = true
-		}
+	// Check permission: admin OR team owner
+	if !canManageTeam(user, req.TeamId) {
+		http.Error(w, "Only team owners can remove members", http.StatusForbidden)
+		return
 	}
 
-	if !canManage {
-		http.Error(w, "You don't have permission to remove members", http.StatusForbidden)
-		return
+	// A team must keep an owner
+	if role, err := database.DB.GetTeamRole(req.TeamId, req.UserId); err == nil && role == models.TeamRoleOwner {
+		owners, err := database.DB.CountTeamOwners(req.TeamId)
+		if err != nil || owners <= 1 {
+			http.Error(w, database.ErrLastTeamOwner.Error(), http.StatusConflict)
+			return
+		}
 	}
 
 	// Get user details for audit log
@@ -407,7 +419,7 @@ func (s *Server) handleAPITeamRemoveMember(w http.ResponseWriter, r *http.Reques
 		EntityType: "TeamMember",
 		EntityID:   fmt.Sprintf("%d", req.TeamId),
 		Details:    fmt.Sprintf("{\"team_id\":%d,\"user_id\":%d,\"user_email\":\"%s\"}", req.TeamId, req.UserId, removedUserEmail),
-		IPAddress:  getClientIP(r),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
@@ -438,35 +450,42 @@ func (s *Server) handleAPIShareFileToTeam(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Check if user is team member
-	isMember, err := database.DB.IsTeamMember(req.TeamId, user.Id)
-	if err != nil || !isMember {
-		http.Error(w, "You must be a team member to share files", http.StatusForbidden)
-		return
-	}
-
-	// Check if user owns the file
 	file, err := database.DB.GetFileByID(req.FileId)
 	if err != nil {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
 
-	if file.UserId != user.Id && !user.HasPermissionEditOtherUploads() {
-		http.Error(w, "You don't own this file", http.StatusForbidden)
+	// Team members can only share their own files
+	if !canShareFileToTeam(user, file, req.TeamId) {
+		http.Error(w, "You can only share your own files with teams you belong to", http.StatusForbidden)
 		return
 	}
 
 	// Share file to team
 	if err := database.DB.ShareFileToTeam(req.FileId, req.TeamId, user.Id); err != nil {
+		if errors.Is(err, database.ErrTeamQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
 		log.Printf("Error sharing file to team: %v", err)
 		http.Error(w, "Error sharing file (file may already be shared)", http.StatusInternalServerError)
 		return
 	}
 
+	// The team may require approval of public links
+	pendingApproval := false
+	if database.DB.GetFileApprovalStatus(file.Id) == "" {
+		if pendingApproval, err = s.requestUploadApprovalIfNeeded(user, file, r); err != nil {
+			http.Error(w, "File shared, but "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
+		"success":          true,
+		"pending_approval": pendingApproval,
 	})
 }
 
@@ -489,25 +508,16 @@ func (s *Server) handleAPIUnshareFileFromTeam(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Check if user is team member or admin
-	if !user.IsAdmin() {
-		isMember, err := database.DB.IsTeamMember(req.TeamId, user.Id)
-		if err != nil || !isMember {
-			http.Error(w, "Access denied", http.StatusForbidden)
-			return
-		}
-
-		// Also check if user owns the file
-		file, err := database.DB.GetFileByID(req.FileId)
-		if err != nil {
-			http.Error(w, "File not found", http.StatusNotFound)
-			return
-		}
+	file, err := database.DB.GetFileByID(req.FileId)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
 
-		if file.UserId != user.Id {
-			http.Error(w, "You don't own this file", http.StatusForbidden)
-			return
-		}
+	// Members can only unshare their own files; team owners and admins can unshare any
+	if !canUnshareFileFromTeam(user, file, req.TeamId) {
+		http.Error(w, "You can only unshare your own files from this team", http.StatusForbidden)
+		return
 	}
 
 	if err := database.DB.UnshareFileFromTeam(req.FileId, req.TeamId); err != nil {
@@ -551,11 +561,14 @@ func (s *Server) handleUserTeams(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		s.renderTeamFiles(w, user, team)
+		s.renderTeamFiles(w, r, user, team)
 		return
 	}
 
-	// Show all teams
+	// Show all teams, with up-to-date storage usage
+	if err := database.DB.RecalculateAllTeamStorage(); err != nil {
+		log.Printf("Warning: Could not refresh team storage usage: %v", err)
+	}
 	teams, err := database.DB.GetTeamsByUser(user.Id)
 	if err != nil {
 		log.Printf("Error fetching user teams: %v", err)
@@ -586,7 +599,7 @@ func (s *Server) handleAPITeamFiles(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	teamFiles, err := database.DB.GetTeamFiles(teamId)
+	teamFiles, err := database.DB.GetTeamFiles(teamId, nil)
 	if err != nil {
 		log.Printf("Error fetching team files: %v", err)
 		http.Error(w, "Error fetching files", http.StatusInternalServerError)
@@ -687,7 +700,7 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -995,6 +1008,12 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
 			storageUsed := fmt.Sprintf("%.1f GB", float64(team.StorageUsedMB)/1024)
 			storageTotal := fmt.Sprintf("%.1f GB", float64(team.StorageQuotaMB)/1024)
 
+			approvalInfo := ""
+			if team.RequireApproval {
+				approvalInfo = `
+                    <span>🛡️ Approval required</span>`
+			}
+
 			html += fmt.Sprintf(`
             <div class="team-item">
                 <div class="team-header">
@@ -1005,12 +1024,12 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
                 <div class="team-stats">
                     <span>👤 %d members</span>
                     <span>💾 %s / %s (%d%%)</span>
-                    <span>📅 Created: %s</span>
+                    <span>📅 Created: %s</span>%s
                 </div>
                 <div class="team-actions">
                     <button class="btn-action" onclick="window.location.href='/teams?id=%d'">📁 Files</button>
                     <button class="btn-action" onclick="viewMembers(%d, '%s')">👥 Members</button>
-                    <button class="btn-action" onclick="editTeam(%d)">✏️ Edit</button>
+                    <button class="btn-action" onclick="editTeam(%d, %t)">✏️ Edit</button>
                     <button class="btn-action btn-danger" onclick="deleteTeam(%d, '%s')">🗑️ Delete</button>
                 </div>
             </div>`,
@@ -1019,8 +1038,8 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
 				team.Description,
 				team.MemberCount,
 				storageUsed, storageTotal, storagePercent,
-				team.GetReadableCreatedAt(),
-				team.Id, team.Id, team.Name, team.Id, team.Id, team.Name)
+				team.GetReadableCreatedAt(), approvalInfo,
+				team.Id, team.Id, team.Name, team.Id, team.RequireApproval, team.Id, team.Name)
 		}
 		html += `
         </div>`
@@ -1046,6 +1065,13 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
                 <input type="number" id="teamQuota" value="10240" min="1" required>
                 <small style="color: #666;">Default: 10240 MB (10 GB)</small>
             </div>
+            <div class="form-group">
+                <label style="display: flex; align-items: center; gap: 8px; cursor: pointer;">
+                    <input type="checkbox" id="teamRequireApproval" style="width: auto;">
+                    Require approval for public uploads
+                </label>
+                <small style="color: #666;">Public links for files shared with this team stay disabled until a team admin or approver releases them</small>
+            </div>
             <div class="modal-actions">
                 <button class="btn btn-secondary" onclick="closeModal()">Cancel</button>
                 <button class="btn" onclick="saveTeam()">Save</button>
@@ -1099,17 +1125,19 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
             document.getElementById('teamName').value = '';
             document.getElementById('teamDescription').value = '';
             document.getElementById('teamQuota').value = '10240';
+            document.getElementById('teamRequireApproval').checked = false;
             currentTeamId = null;
             document.getElementById('teamModal').classList.add('active');
         }
 
-        function editTeam(teamId) {
+        function editTeam(teamId, requireApproval) {
             // Fetch team data and populate form
             fetch('/api/teams/members?teamId=' + teamId)
                 .then(r => r.json())
                 .then(data => {
                     // For now, just show a basic edit form
                     document.getElementById('modalTitle').textContent = 'Edit Team';
+                    document.getElementById('teamRequireApproval').checked = requireApproval;
                     currentTeamId = teamId;
                     document.getElementById('teamModal').classList.add('active');
                 });
@@ -1133,7 +1161,8 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
             const body = {
                 name: name,
                 description: description,
-                storageQuotaMB: quota
+                storageQuotaMB: quota,
+                requireApproval: document.getElementById('teamRequireApproval').checked
             };
 
             if (currentTeamId) {
@@ -1194,7 +1223,9 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
 
                     if (data.members && data.members.length > 0) {
                         data.members.forEach(m => {
-                            const role = m.role === 0 ? 'Owner' : m.role === 1 ? 'Admin' : 'Member';
+                            const role = '<select onchange="setMemberRole(' + teamId + ', ' + m.userId + ', this)" data-role="' + m.role + '">' +
+                                ['Owner', 'Admin', 'Member'].map((label, value) => '<option value="' + value + '"' + (m.role === value ? ' selected' : '') + '>' + label + '</option>').join('') +
+                                '</select>';
                             const joinedDate = new Date(m.joinedAt * 1000).toLocaleDateString();
                             html += '<tr><td>' + m.userName + '</td><td>' + m.userEmail + '</td><td>' + role + '</td><td>' + joinedDate + '</td><td><button onclick="removeMember(' + teamId + ', ' + m.userId + ', \'' + m.userName + '\')">Remove</button></td></tr>';
                         });
@@ -1284,6 +1315,25 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
             });
         }
 
+        function setMemberRole(teamId, userId, select) {
+            const role = parseInt(select.value);
+            fetch('/api/teams/set-role', {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({teamId: teamId, userId: userId, role: role})
+            })
+            .then(async r => {
+                if (!r.ok) {
+                    throw new Error(await r.text());
+                }
+                select.dataset.role = role;
+            })
+            .catch(err => {
+                alert('Error: ' + err.message);
+                select.value = select.dataset.role;
+            });
+        }
+
         function removeMember(teamId, userId, userName) {
             if (!confirm('Remove ' + userName + ' from this team?')) {
                 return;
@@ -1294,13 +1344,21 @@ func (s *Server) renderAdminTeams(w http.ResponseWriter, teams []struct {
                 headers: {'Content-Type': 'application/json'},
                 body: JSON.stringify({teamId: teamId, userId: userId})
             })
-            .then(r => r.json())
+            .then(async r => {
+                if (!r.ok) {
+                    throw new Error(await r.text());
+                }
+                return r.json();
+            })
             .then(data => {
                 if (data.success) {
                     alert('Member removed!');
                     closeMembersModal();
                     location.reload();
                 }
+            })
+            .catch(err => {
+                alert('Error: ' + err.message);
             });
         }
     </script>
@@ -1316,7 +1374,7 @@ func (s *Server) renderUserTeams(w http.ResponseWriter, user *models.User, teams
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -1506,24 +1564,88 @@ func (s *Server) renderUserTeams(w http.ResponseWriter, user *models.User, teams
 	w.Write([]byte(html))
 }
 
-// renderTeamFiles displays all files shared with a specific team using dashboard-style list
-func (s *Server) renderTeamFiles(w http.ResponseWriter, user *models.User, team *models.Team) {
+// teamFileSortOptions are the sort choices of the team files page, as "field_order"
+var teamFileSortOptions = []struct{ Value, Label string }{
+	{"date_desc", "Date (Newest First)"},
+	{"date_asc", "Date (Oldest First)"},
+	{"name_asc", "Name (A-Z)"},
+	{"name_desc", "Name (Z-A)"},
+	{"size_desc", "Size (Largest First)"},
+	{"size_asc", "Size (Smallest First)"},
+	{"owner_asc", "Owner (A-Z)"},
+	{"owner_desc", "Owner (Z-A)"},
+}
+
+// teamFileFilterFromRequest reads the search, sort and pagination of the team files page
+func teamFileFilterFromRequest(r *http.Request) (*database.TeamFileFilter, string) {
+	sort := r.URL.Query().Get("sort")
+	valid := false
+	for _, option := range teamFileSortOptions {
+		if option.Value == sort {
+			valid = true
+		}
+	}
+	if !valid {
+		sort = "date_desc"
+	}
+	sortBy, sortOrder, _ := strings.Cut(sort, "_")
+
+	filter := &database.TeamFileFilter{
+		SearchTerm: strings.TrimSpace(r.URL.Query().Get("q")),
+		SortBy:     sortBy,
+		SortOrder:  sortOrder,
+		Limit:      25,
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && limit <= 250 {
+		filter.Limit = limit
+	}
+	if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && offset > 0 {
+		filter.Offset = offset
+	}
+	return filter, sort
+}
+
+// renderTeamFiles displays the files shared with a specific team using dashboard-style list,
+// searched, sorted and paginated on the server
+func (s *Server) renderTeamFiles(w http.ResponseWriter, r *http.Request, user *models.User, team *models.Team) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	filter, sort := teamFileFilterFromRequest(r)
+
 	// Get team files
-	teamFiles, err := database.DB.GetTeamFiles(team.Id)
+	totalFiles, err := database.DB.GetTeamFileCount(team.Id, filter)
+	if err != nil {
+		log.Printf("Error counting team files: %v", err)
+	}
+	teamFiles, err := database.DB.GetTeamFiles(team.Id, filter)
 	if err != nil {
 		log.Printf("Error fetching team files: %v", err)
 		teamFiles = []*models.TeamFile{}
 	}
 
+	// Calculate pagination
+	limit, offset := filter.Limit, filter.Offset
+	hasNext := offset+limit < totalFiles
+	hasPrev := offset > 0
+	currentPage := (offset / limit) + 1
+	totalPages := (totalFiles + limit - 1) / limit
+	pageURL := func(offset int) string {
+		return "/teams?" + url.Values{
+			"id":     {strconv.Itoa(team.Id)},
+			"q":      {filter.SearchTerm},
+			"sort":   {sort},
+			"limit":  {strconv.Itoa(limit)},
+			"offset": {strconv.Itoa(offset)},
+		}.Encode()
+	}
+
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta name="author" content="Ulf Holmström">
-    <title>` + team.Name + ` - Files - WulfVault</title>
+    <title>` + template.HTMLEscapeString(team.Name) + ` - Files - WulfVault</title>
     ` + s.getFaviconHTML() + `
     <link rel="stylesheet" href="/static/css/style.css">
     <style>
@@ -1680,66 +1802,91 @@ func (s *Server) renderTeamFiles(w http.ResponseWriter, user *models.User, team
     <div class="container">
         <div class="page-header">
             <div>
-                <h2>📁 ` + team.Name + ` - Shared Files</h2>
+                <h2>📁 ` + template.HTMLEscapeString(team.Name) + ` - Shared Files</h2>
                 <p class="subtitle">Files shared with this team</p>
             </div>
-            <a href="/teams" class="back-btn">← Back to Teams</a>
+            <div>`
+
+	if teamZipMaxBytes() > 0 && (totalFiles > 0 || filter.SearchTerm != "") {
+		html += `
+                <a href="/teams/download-zip?id=` + strconv.Itoa(team.Id) + `" class="btn-download" style="margin-right: 10px;" title="Files with a password, login, approval, schedule or terms requirement are left out">📦 Download All as ZIP</a>`
+	}
+
+	html += `
+                <a href="/teams" class="back-btn">← Back to Teams</a>
+            </div>
         </div>`
 
-	if len(teamFiles) == 0 {
+	if totalFiles == 0 && filter.SearchTerm == "" {
 		html += `
         <div class="empty-state">
             <h3>No files shared yet</h3>
             <p>Files shared with this team will appear here</p>
         </div>`
 	} else {
+		sortOptions := ""
+		for _, option := range teamFileSortOptions {
+			sortOptions += `
+                    <option value="` + option.Value + `"` + selected(option.Value == sort) + `>` + option.Label + `</option>`
+		}
+		perPageOptions := ""
+		for _, n := range []int{5, 25, 50, 100, 200, 250} {
+			perPageOptions += fmt.Sprintf(`
+                    <option value="%d"%s>%d</option>`, n, selected(n == limit), n)
+		}
+
 		// Add search, sorting, and pagination controls
 		html += `
-        <div style="margin-bottom: 20px; background: white; padding: 15px; border-radius: 8px; box-shadow: 0 1px 3px rgba(0,0,0,0.08); display: flex; gap: 20px; flex-wrap: wrap; align-items: center;">
+        <form method="GET" action="/teams" id="teamFilesForm" style="margin-bottom: 20px; background: white; padding: 15px; border-radius: 8px; box-shadow: 0 1px 3px rgba(0,0,0,0.08); display: flex; gap: 20px; flex-wrap: wrap; align-items: center;">
+            <input type="hidden" name="id" value="` + strconv.Itoa(team.Id) + `">
             <div style="flex: 1; min-width: 250px;">
                 <label style="font-weight: 600; margin-right: 10px; color: #333;">🔍 Search:</label>
-                <input type="text" id="searchInput" placeholder="Search files, owner, description..." oninput="filterAndPaginate()" style="padding: 8px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; width: 100%; max-width: 400px;">
+                <input type="text" name="q" value="` + template.HTMLEscapeString(filter.SearchTerm) + `" placeholder="Search files, owner, description..." style="padding: 8px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; width: 100%; max-width: 400px;">
             </div>
             <div>
                 <label style="font-weight: 600; margin-right: 10px; color: #333;">🔄 Sort by:</label>
-                <select id="sortSelect" onchange="sortFiles()" style="padding: 8px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; cursor: pointer;">
-                    <option value="date_desc">Date (Newest First)</option>
-                    <option value="date_asc">Date (Oldest First)</option>
-                    <option value="name_asc">Name (A-Z)</option>
-                    <option value="name_desc">Name (Z-A)</option>
-                    <option value="size_desc">Size (Largest First)</option>
-                    <option value="size_asc">Size (Smallest First)</option>
-                    <option value="user_asc">Owner (A-Z)</option>
-                    <option value="user_desc">Owner (Z-A)</option>
+                <select name="sort" onchange="this.form.submit()" style="padding: 8px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; cursor: pointer;">` + sortOptions + `
                 </select>
             </div>
             <div>
                 <label style="font-weight: 600; margin-right: 10px; color: #333;">📄 Per page:</label>
-                <select id="perPageSelect" onchange="changePerPage()" style="padding: 8px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; cursor: pointer;">
-                    <option value="5">5</option>
-                    <option value="25" selected>25</option>
-                    <option value="50">50</option>
-                    <option value="100">100</option>
-                    <option value="200">200</option>
-                    <option value="250">250</option>
+                <select name="limit" onchange="this.form.submit()" style="padding: 8px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; cursor: pointer;">` + perPageOptions + `
                 </select>
             </div>
-        </div>
+        </form>
 
         <!-- File counter and pagination -->
         <div style="margin-bottom: 15px; display: flex; justify-content: space-between; align-items: center; flex-wrap: wrap; gap: 10px;">
             <div id="fileCounter" style="font-weight: 600; color: #333; font-size: 14px;">
-                Showing <span id="visibleCount">0</span> of <span id="totalCount">0</span> files
+                Showing ` + fmt.Sprintf("%d", len(teamFiles)) + ` of ` + fmt.Sprintf("%d", totalFiles) + ` files
             </div>
-            <div id="paginationControls" style="display: flex; gap: 8px; align-items: center;">
-                <button onclick="prevPage()" id="prevBtn" style="padding: 6px 12px; background: ` + s.getPrimaryColor() + `; color: white; border: none; border-radius: 4px; cursor: pointer; font-size: 13px;">← Prev</button>
-                <span id="pageInfo" style="font-size: 14px; color: #666; min-width: 80px; text-align: center;">Page 1 of 1</span>
-                <button onclick="nextPage()" id="nextBtn" style="padding: 6px 12px; background: ` + s.getPrimaryColor() + `; color: white; border: none; border-radius: 4px; cursor: pointer; font-size: 13px;">Next →</button>
+            <div id="paginationControls" style="display: flex; gap: 8px; align-items: center;">`
+
+		if hasPrev {
+			html += `
+                <a href="` + template.HTMLEscapeString(pageURL(max(0, offset-limit))) + `" style="padding: 6px 12px; background: ` + s.getPrimaryColor() + `; color: white; border-radius: 4px; font-size: 13px; text-decoration: none;">← Prev</a>`
+		}
+		html += `
+                <span style="font-size: 14px; color: #666; min-width: 80px; text-align: center;">Page ` + fmt.Sprintf("%d of %d", currentPage, max(totalPages, 1)) + `</span>`
+		if hasNext {
+			html += `
+                <a href="` + template.HTMLEscapeString(pageURL(offset+limit)) + `" style="padding: 6px 12px; background: ` + s.getPrimaryColor() + `; color: white; border-radius: 4px; font-size: 13px; text-decoration: none;">Next →</a>`
+		}
+
+		html += `
             </div>
         </div>
 
         <div class="file-list" id="fileList">`
 
+		if len(teamFiles) == 0 {
+			html += `
+            <div class="empty-state">
+                <h3>No matching files</h3>
+                <p>No files shared with this team match your search</p>
+            </div>`
+		}
+
 		for _, tf := range teamFiles {
 			file, err := database.DB.GetFileByID(tf.FileId)
 			if err != nil {
@@ -1779,7 +1926,7 @@ func (s *Server) renderTeamFiles(w http.ResponseWriter, user *models.User, team
 			// Add delete button if user is admin
 			deleteButton := ""
 			if user.IsAdmin() {
-				deleteButton = fmt.Sprintf(`<button onclick="deleteTeamFile('%s', '%s', event)" class="btn-delete" style="background: #ef4444; color: white; padding: 8px 16px; border: none; border-radius: 6px; font-size: 14px; font-weight: 500; cursor: pointer; transition: all 0.2s; margin-left: 10px;">🗑️ Delete</button>`, file.Id, file.Name)
+				deleteButton = fmt.Sprintf(`<button onclick="deleteTeamFile('%s', '%s', event)" class="btn-delete" style="background: #ef4444; color: white; padding: 8px 16px; border: none; border-radius: 6px; font-size: 14px; font-weight: 500; cursor: pointer; transition: all 0.2s; margin-left: 10px;">🗑️ Delete</button>`, file.Id, template.HTMLEscapeString(template.JSEscapeString(file.Name)))
 			}
 
 			// Add file description/comment if it exists
@@ -1788,11 +1935,11 @@ func (s *Server) renderTeamFiles(w http.ResponseWriter, user *models.User, team
 				descriptionHTML = fmt.Sprintf(`
                 <div class="file-description" style="margin-top: 8px; padding: 8px 12px; background: #f0f9ff; border-left: 3px solid %s; border-radius: 4px; font-size: 14px; color: #1e40af;">
                     💬 %s
-                </div>`, s.getPrimaryColor(), file.Comment)
+                </div>`, s.getPrimaryColor(), template.HTMLEscapeString(file.Comment))
 			}
 
 			html += fmt.Sprintf(`
-            <div class="file-item" data-filename="%s" data-owner="%s" data-size="%d" data-date="%d" data-comment="%s">
+            <div class="file-item">
                 <div class="file-header">
                     <span class="file-name" title="%s"><span class="file-icon">📄</span>%s</span>
                     <div>
@@ -1808,7 +1955,7 @@ func (s *Server) renderTeamFiles(w http.ResponseWriter, user *models.User, team
                     <span>⬇️ Downloads: %d</span>
                 </div>
                 %s
-            </div>`, file.Name, ownerName, file.SizeBytes, tf.SharedAt, template.HTMLEscapeString(file.Comment), file.Name, file.Name, file.Id, deleteButton, ownerName, sharedByName, sharedDate, sizeStr, file.DownloadCount, descriptionHTML)
+            </div>`, template.HTMLEscapeString(file.Name), template.HTMLEscapeString(file.Name), file.Id, deleteButton, template.HTMLEscapeString(ownerName), template.HTMLEscapeString(sharedByName), sharedDate, sizeStr, file.DownloadCount, descriptionHTML)
 		}
 
 		html += `
@@ -1819,140 +1966,6 @@ func (s *Server) renderTeamFiles(w http.ResponseWriter, user *models.User, team
     </div>
 
     <script>
-        let currentPage = 1;
-        let perPage = 25;
-        let allItems = [];
-        let filteredItems = [];
-
-        // Initialize on page load
-        document.addEventListener('DOMContentLoaded', function() {
-            allItems = Array.from(document.getElementsByClassName('file-item'));
-            filteredItems = [...allItems];
-            updateDisplay();
-        });
-
-        function filterAndPaginate() {
-            const searchTerm = document.getElementById('searchInput').value.toLowerCase();
-            const items = document.getElementsByClassName('file-item');
-
-            filteredItems = [];
-            for (let item of items) {
-                const filename = item.dataset.filename.toLowerCase();
-                const owner = item.dataset.owner.toLowerCase();
-                const comment = (item.dataset.comment || '').toLowerCase();
-
-                if (filename.includes(searchTerm) || owner.includes(searchTerm) || comment.includes(searchTerm)) {
-                    filteredItems.push(item);
-                }
-            }
-
-            currentPage = 1; // Reset to first page when filtering
-            updateDisplay();
-        }
-
-        function sortFiles() {
-            const sortBy = document.getElementById('sortSelect').value;
-            const fileList = document.getElementById('fileList');
-            const items = Array.from(fileList.getElementsByClassName('file-item'));
-
-            items.sort((a, b) => {
-                switch(sortBy) {
-                    case 'name_asc':
-                        return a.dataset.filename.localeCompare(b.dataset.filename);
-                    case 'name_desc':
-                        return b.dataset.filename.localeCompare(a.dataset.filename);
-                    case 'size_asc':
-                        return parseInt(a.dataset.size) - parseInt(b.dataset.size);
-                    case 'size_desc':
-                        return parseInt(b.dataset.size) - parseInt(a.dataset.size);
-                    case 'user_asc':
-                        return a.dataset.owner.localeCompare(b.dataset.owner);
-                    case 'user_desc':
-                        return b.dataset.owner.localeCompare(a.dataset.owner);
-                    case 'date_asc':
-                        return parseInt(a.dataset.date) - parseInt(b.dataset.date);
-                    case 'date_desc':
-                        return parseInt(b.dataset.date) - parseInt(a.dataset.date);
-                    default:
-                        return 0;
-                }
-            });
-
-            // Clear and re-append
-            fileList.innerHTML = '';
-            items.forEach(item => fileList.appendChild(item));
-
-            // Update filtered items array to match new sort order
-            allItems = items;
-            filterAndPaginate(); // Re-apply filter and pagination
-        }
-
-        function changePerPage() {
-            perPage = parseInt(document.getElementById('perPageSelect').value);
-            currentPage = 1; // Reset to first page
-            updateDisplay();
-        }
-
-        function prevPage() {
-            if (currentPage > 1) {
-                currentPage--;
-                updateDisplay();
-            }
-        }
-
-        function nextPage() {
-            const totalPages = Math.ceil(filteredItems.length / perPage);
-            if (currentPage < totalPages) {
-                currentPage++;
-                updateDisplay();
-            }
-        }
-
-        function updateDisplay() {
-            const totalFiles = allItems.length;
-            const totalFiltered = filteredItems.length;
-            const totalPages = Math.ceil(totalFiltered / perPage);
-
-            // Calculate start and end indices for current page
-            const startIdx = (currentPage - 1) * perPage;
-            const endIdx = Math.min(startIdx + perPage, totalFiltered);
-
-            // Hide all items first
-            allItems.forEach(item => item.style.display = 'none');
-
-            // Show only items for current page
-            for (let i = startIdx; i < endIdx; i++) {
-                filteredItems[i].style.display = '';
-            }
-
-            // Update counter
-            const visibleCount = endIdx - startIdx;
-            document.getElementById('visibleCount').textContent = visibleCount;
-            document.getElementById('totalCount').textContent = totalFiltered;
-
-            // Update pagination controls
-            document.getElementById('pageInfo').textContent = 'Page ' + currentPage + ' of ' + Math.max(1, totalPages);
-            document.getElementById('prevBtn').disabled = currentPage === 1;
-            document.getElementById('nextBtn').disabled = currentPage >= totalPages;
-
-            // Update button styles for disabled state
-            if (currentPage === 1) {
-                document.getElementById('prevBtn').style.opacity = '0.5';
-                document.getElementById('prevBtn').style.cursor = 'not-allowed';
-            } else {
-                document.getElementById('prevBtn').style.opacity = '1';
-                document.getElementById('prevBtn').style.cursor = 'pointer';
-            }
-
-            if (currentPage >= totalPages) {
-                document.getElementById('nextBtn').style.opacity = '0.5';
-                document.getElementById('nextBtn').style.cursor = 'not-allowed';
-            } else {
-                document.getElementById('nextBtn').style.opacity = '1';
-                document.getElementById('nextBtn').style.cursor = 'pointer';
-            }
-        }
-
         function deleteTeamFile(fileId, fileName, event) {
             event.preventDefault();
             event.stopPropagation();
@@ -0,0 +1,192 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// trustedDeviceCookie holds the signed device token. It is separate from the session cookie so
+// logging out doesn't forget the device.
+const trustedDeviceCookie = "trusted_device"
+
+// trustedDeviceFromRequest returns the trusted device the request comes from, or nil
+func trustedDeviceFromRequest(r *http.Request, userId int) *database.TrustedDevice {
+	if database.DB.GetTrustedDeviceDays() <= 0 {
+		return nil
+	}
+	cookie, err := r.Cookie(trustedDeviceCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	device, err := database.DB.ValidateTrustedDevice(userId, cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return device
+}
+
+// rememberDevice trusts the current browser for the configured number of days after a successful 2FA check
+func (s *Server) rememberDevice(w http.ResponseWriter, r *http.Request, user *models.User) {
+	days := database.DB.GetTrustedDeviceDays()
+	if days <= 0 {
+		return
+	}
+
+	duration := time.Duration(days) * 24 * time.Hour
+	device, token, err := database.DB.CreateTrustedDevice(user.Id, deviceNameFromUserAgent(r.UserAgent()), clientIP(r), duration)
+	if err != nil {
+		log.Printf("Failed to create trusted device for %s: %v", user.Email, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     trustedDeviceCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Unix(device.ExpiresAt, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionTrustedDeviceAdded,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"device_id":  device.Id,
+			"device":     device.Name,
+			"expires_at": device.ExpiresAt,
+			"days":       days,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+}
+
+// deviceNameFromUserAgent gives a short "Browser on OS" label for the trusted device list
+func deviceNameFromUserAgent(userAgent string) string {
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	os := "unknown OS"
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	return browser + " on " + os
+}
+
+// handleTrustedDevices lists the user's trusted devices (GET) or revokes one or all of them (POST)
+func (s *Server) handleTrustedDevices(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		devices, err := database.DB.GetTrustedDevices(user.Id)
+		if err != nil {
+			s.sendError(w, http.StatusInternalServerError, "Failed to load trusted devices")
+			return
+		}
+		if devices == nil {
+			devices = []*database.TrustedDevice{}
+		}
+
+		currentId := 0
+		if current := trustedDeviceFromRequest(r, user.Id); current != nil {
+			currentId = current.Id
+		}
+
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{
+			"devices":          devices,
+			"current_id":       currentId,
+			"remember_enabled": database.DB.GetTrustedDeviceDays() > 0,
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	details := map[string]interface{}{}
+	if r.FormValue("all") == "true" {
+		count, err := database.DB.RevokeAllTrustedDevices(user.Id)
+		if err != nil {
+			s.sendError(w, http.StatusInternalServerError, "Failed to revoke trusted devices")
+			return
+		}
+		details["all"] = true
+		details["count"] = count
+	} else {
+		deviceId, err := strconv.Atoi(r.FormValue("device_id"))
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid device ID")
+			return
+		}
+		if err := database.DB.RevokeTrustedDevice(user.Id, deviceId); err != nil {
+			s.sendError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		details["device_id"] = deviceId
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionTrustedDeviceRevoked,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", user.Id),
+		Details:    database.CreateAuditDetails(details),
+		IPAddress:  clientIP(r),
+		UserAgent:  r.UserAgent(),
+		Success:    true,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
@@ -7,12 +7,15 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/database"
@@ -28,7 +31,7 @@ func (s *Server) handleUserDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.renderUserDashboard(w, user)
+	s.renderUserDashboard(w, user, fileCategoryFromRequest(r))
 }
 
 // handleUserFiles returns the user's files as JSON
@@ -78,11 +81,26 @@ func (s *Server) handleFileEdit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	expirationDays, _ := strconv.Atoi(r.FormValue("expiration_days"))
+	expireDate := strings.TrimSpace(r.FormValue("expire_date"))
 	downloadsLimit, _ := strconv.Atoi(r.FormValue("downloads_limit"))
 	teamIDStr := r.FormValue("team_id")
 	fileComment := r.FormValue("file_comment")
 	requireAuth := r.FormValue("require_auth") == "true"
+	accessRequestsEnabled := requireAuth && r.FormValue("access_requests_enabled") == "true"
+	expiryReminders := r.FormValue("expiry_reminders") == "true"
+	privateDownloadLog := r.FormValue("private_download_log")
+	requireTerms := r.FormValue("require_terms")
+	splashTemplate := r.FormValue("splash_template")
+	reshareRequests := r.FormValue("reshare_requests")
+	downloadRetry := r.FormValue("download_retry")
+	burnAfterDownload := r.FormValue("burn_after_download")
+	notifyOnDownload := r.FormValue("notify_on_download")
+	maxViewers := r.FormValue("max_viewers")
+	downloadLimit := r.FormValue("download_limit_mbps")
+	filenameTemplate, hasFilenameTemplate := r.Form["filename_template"]
+	expiredMessage, hasExpiredMessage := r.Form["expired_message"]
 	filePassword := r.FormValue("file_password")
+	slug, hasSlug := r.Form["slug"]
 
 	// Get file to verify ownership
 	fileInfo, err := database.DB.GetFileByID(fileID)
@@ -97,15 +115,120 @@ func (s *Server) handleFileEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update expiration
+	schedule, err := fileScheduleFromRequest(r)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid schedule: "+err.Error())
+		return
+	}
+
+	// Check the team share before changing anything, so a full team doesn't leave a half-saved edit
+	teamID := 0
+	if teamIDStr != "" {
+		teamID, err = strconv.Atoi(teamIDStr)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid team")
+			return
+		}
+		if !canShareFileToTeam(user, fileInfo, teamID) {
+			s.sendError(w, http.StatusForbidden, "You can only share your own files with teams you belong to")
+			return
+		}
+		if err := database.DB.CheckTeamQuota(teamID, fileID); err != nil {
+			if errors.Is(err, database.ErrTeamQuotaExceeded) {
+				s.sendError(w, http.StatusRequestEntityTooLarge, err.Error())
+			} else {
+				s.sendError(w, http.StatusInternalServerError, "Failed to check team storage")
+			}
+			return
+		}
+	}
+
+	if splashTemplate != "" && !database.IsValidSplashTemplate(splashTemplate) {
+		s.sendError(w, http.StatusBadRequest, "Invalid splash page template")
+		return
+	}
+
+	if hasFilenameTemplate {
+		if err := validateFilenameTemplate(strings.TrimSpace(filenameTemplate[0]), fileInfo); err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid download file name: "+err.Error())
+			return
+		}
+	}
+
+	if hasExpiredMessage {
+		message, err := normalizeExpiredMessage(expiredMessage[0])
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid expired page message: "+err.Error())
+			return
+		}
+		expiredMessage[0] = message
+	}
+
+	// A custom slug gives the file readable links next to its ID
+	if hasSlug {
+		normalized, err := checkRequestedSlug(slug[0], fileID)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid link name: "+err.Error())
+			return
+		}
+		slug[0] = normalized
+	}
+
+	// Only admins can let a file be downloaded faster than the server's limit
+	downloadLimitMBps := -1
+	if downloadLimit != "" {
+		downloadLimitMBps, err = strconv.Atoi(downloadLimit)
+		if err != nil || downloadLimitMBps < 0 {
+			s.sendError(w, http.StatusBadRequest, "Invalid download speed limit")
+			return
+		}
+		globalLimit := database.DB.GetConfigInt("download_max_mbps", 0)
+		if globalLimit > 0 && downloadLimitMBps > globalLimit && !user.IsAdmin() {
+			s.sendError(w, http.StatusBadRequest, fmt.Sprintf("The download speed limit can't be higher than the server's limit of %d MB/s", globalLimit))
+			return
+		}
+	}
+
+	// Turning an auth-required file into a public link counts toward the public link cap
+	if fileInfo.RequireAuth && !requireAuth {
+		if reached, limit := publicLinkLimitReached(); reached {
+			s.sendError(w, http.StatusForbidden, publicLinkLimitMessage(limit))
+			return
+		}
+	}
+
+	// Update expiration. An expire_date (YYYY-MM-DD) takes precedence over expiration_days, which
+	// counts from now; without either the file no longer expires by time. Sending the date the
+	// file already expires on keeps its exact expiry time, so re-editing doesn't reset the clock.
 	var newExpireAt int64
 	var newExpireAtString string
-	unlimitedTime := expirationDays == 0
+	unlimitedTime := expirationDays <= 0
+
+	if expireDate != "" {
+		unlimitedTime = false
+		if expireDate == expireDateOf(fileInfo) {
+			newExpireAt = fileInfo.ExpireAt
+		} else {
+			expireTime, err := parseExpireDate(expireDate)
+			if err != nil {
+				s.sendError(w, http.StatusBadRequest, "Invalid expiration date: "+err.Error())
+				return
+			}
+			newExpireAt = expireTime.Unix()
+		}
+		newExpireAtString = database.FormatExpireAt(newExpireAt)
+	} else if expirationDays > 0 {
+		newExpireAt = time.Now().Add(time.Duration(expirationDays) * 24 * time.Hour).Unix()
+		newExpireAtString = database.FormatExpireAt(newExpireAt)
+	}
+
+	// Apply the deployment's maximum file expiry
+	newExpireAt, newExpireAtString, unlimitedTime = limitFileExpiry(newExpireAt, newExpireAtString, unlimitedTime)
 
-	if expirationDays > 0 {
-		expireTime := time.Now().Add(time.Duration(expirationDays) * 24 * time.Hour)
-		newExpireAt = expireTime.Unix()
-		newExpireAtString = expireTime.Format("2006-01-02 15:04")
+	// A file that goes live later must still be live once it does
+	if err := database.ValidateActivation(schedule.AvailableFrom, newExpireAt, unlimitedTime); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid schedule: "+err.Error())
+		return
 	}
 
 	unlimitedDownloads := downloadsLimit == 0
@@ -131,29 +254,130 @@ func (s *Server) handleFileEdit(w http.ResponseWriter, r *http.Request) {
 		// Don't fail the request, just log the error
 	}
 
+	// Restricting a file to granted emails only applies while it requires authentication
+	if err := database.DB.SetFileAccessRequestsEnabled(fileID, accessRequestsEnabled); err != nil {
+		log.Printf("Warning: Failed to update access requests setting: %v", err)
+	}
+
+	// Expiry reminders only make sense for files that expire
+	if err := database.DB.SetFileExpiryRemindersEnabled(fileID, expiryReminders && !unlimitedTime); err != nil {
+		log.Printf("Warning: Failed to update expiry reminders setting: %v", err)
+	}
+
+	// Keep downloader IPs and user agents out of the download log
+	if privateDownloadLog != "" {
+		if err := database.DB.SetFilePrivateDownloadLog(fileID, privateDownloadLog == "true"); err != nil {
+			log.Printf("Warning: Failed to update download log privacy: %v", err)
+		}
+	}
+
+	// Require acceptance of the download terms
+	if requireTerms != "" {
+		if err := database.DB.SetFileTermsRequired(fileID, requireTerms == "true"); err != nil {
+			log.Printf("Warning: Failed to update download terms requirement: %v", err)
+		}
+	}
+
+	if splashTemplate != "" {
+		if err := database.DB.SetFileSplashTemplate(fileID, splashTemplate); err != nil {
+			log.Printf("Warning: Failed to update splash page template: %v", err)
+		}
+	}
+
+	// Message shown on the expired page
+	if hasExpiredMessage {
+		if err := database.DB.SetFileExpiredMessage(fileID, expiredMessage[0]); err != nil {
+			log.Printf("Warning: Failed to update expired page message: %v", err)
+		}
+	}
+
+	// Let visitors of the expired link ask for a new one
+	if reshareRequests != "" {
+		if err := database.DB.SetFileReshareRequestsEnabled(fileID, reshareRequests == "true"); err != nil {
+			log.Printf("Warning: Failed to update new link requests: %v", err)
+		}
+	}
+
+	// Let the recipient retry a broken-off download without it counting again
+	if downloadRetry != "" {
+		if err := database.DB.SetFileDownloadRetryEnabled(fileID, downloadRetry == "true"); err != nil {
+			log.Printf("Warning: Failed to update download retries: %v", err)
+		}
+	}
+
+	// Remove the file after its first complete download
+	if burnAfterDownload != "" {
+		if err := database.DB.SetFileBurnAfterDownload(fileID, burnAfterDownload == "true"); err != nil {
+			log.Printf("Warning: Failed to update burn after download: %v", err)
+		}
+	}
+
+	if hasSlug {
+		if err := database.DB.SetFileSlug(fileID, slug[0]); err != nil {
+			s.sendError(w, http.StatusConflict, "Failed to update link name: "+err.Error())
+			return
+		}
+	}
+
+	// Email the owner when the file is downloaded
+	if notifyOnDownload != "" {
+		if err := database.DB.SetFileNotifyOnDownload(fileID, notifyOnDownload == "true"); err != nil {
+			log.Printf("Warning: Failed to update download notifications: %v", err)
+		}
+	}
+
+	// Limit the total bandwidth of the file's downloads
+	if downloadLimitMBps >= 0 {
+		if err := database.DB.SetFileDownloadLimit(fileID, downloadLimitMBps); err != nil {
+			log.Printf("Warning: Failed to update download speed limit: %v", err)
+		}
+	}
+
+	// Limit how many people may have the splash page open at once
+	if maxViewers != "" {
+		if n, err := strconv.Atoi(maxViewers); err == nil && n >= 0 {
+			if err := database.DB.SetFileMaxViewers(fileID, n); err != nil {
+				log.Printf("Warning: Failed to update viewer limit: %v", err)
+			}
+		}
+	}
+
+	// Name the downloaded file from a template (an empty template uses the stored name)
+	if hasFilenameTemplate {
+		if err := database.DB.SetFileFilenameTemplate(fileID, strings.TrimSpace(filenameTemplate[0])); err != nil {
+			log.Printf("Warning: Failed to update filename template: %v", err)
+		}
+	}
+
 	// Update password (empty string will clear the password)
 	if err := database.DB.UpdateFilePassword(fileID, filePassword); err != nil {
 		log.Printf("Warning: Failed to update file password: %v", err)
 		// Don't fail the request, just log the error
 	}
 
+	// Update availability schedule (an empty schedule clears it)
+	if err := database.DB.SetFileSchedule(fileID, schedule); err != nil {
+		log.Printf("Warning: Failed to update file schedule: %v", err)
+	}
+
 	// Share to team if team_id is provided
-	if teamIDStr != "" {
-		teamID, err := strconv.Atoi(teamIDStr)
-		if err == nil {
-			// Check if user is team member
-			isMember, err := database.DB.IsTeamMember(teamID, user.Id)
-			if err == nil && isMember {
-				// Share file to team
-				if err := database.DB.ShareFileToTeam(fileID, teamID, user.Id); err != nil {
-					// Log error but don't fail the request (file was already updated)
-					log.Printf("Warning: Failed to share file to team: %v", err)
-				} else {
-					log.Printf("File %s shared to team %d by user %d", fileInfo.Name, teamID, user.Id)
-				}
-			} else {
-				log.Printf("Warning: User %d is not a member of team %d, skipping team share", user.Id, teamID)
-			}
+	sharedToTeam := false
+	if shared, _ := database.DB.IsFileSharedWithTeam(fileID, teamID); teamID > 0 && !shared {
+		if err := database.DB.ShareFileToTeam(fileID, teamID, user.Id); err != nil {
+			s.sendError(w, http.StatusInternalServerError, "File updated, but sharing it with the team failed: "+err.Error())
+			return
+		}
+		sharedToTeam = true
+		log.Printf("File %s shared to team %d by user %d", fileInfo.Name, teamID, user.Id)
+	}
+
+	// A file that becomes public, or is shared with a team that approves uploads, may need
+	// approval before its link works
+	if (fileInfo.RequireAuth || sharedToTeam) && !requireAuth && database.DB.GetFileApprovalStatus(fileID) == "" {
+		fileInfo.RequireAuth = false
+		if _, err := s.requestUploadApprovalIfNeeded(user, fileInfo, r); err != nil {
+			s.sendError(w, http.StatusInternalServerError, "File updated, but "+err.Error())
+			return
 		}
 	}
 
@@ -191,23 +415,74 @@ func (s *Server) handleFileDownloadHistory(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Get download logs
-	downloadLogs, err := database.DB.GetDownloadLogsByFileID(fileID)
+	downloadPage, emailPage, err := parseHistoryPages(r)
 	if err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Failed to get download logs")
+		s.sendError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	// "Load more" only asks for the next page of one list
+	section := r.URL.Query().Get("section")
+
+	// Get download logs
+	var downloadLogs []*models.DownloadLog
+	downloadTotal := 0
+	if section == "" || section == "downloads" {
+		downloadLogs, downloadTotal, err = database.DB.GetDownloadLogsByFileIDPage(fileID, downloadPage)
+		if err != nil {
+			s.sendError(w, http.StatusInternalServerError, "Failed to get download logs")
+			return
+		}
+	}
+
 	// Get email logs
-	emailLogs, err := database.DB.GetEmailLogsByFileID(fileID)
+	var emailLogs []*models.EmailLog
+	emailTotal := 0
+	if section == "" || section == "emails" {
+		emailLogs, emailTotal, err = database.DB.GetEmailLogsByFileIDPage(fileID, emailPage)
+		if err != nil {
+			s.sendError(w, http.StatusInternalServerError, "Failed to get email logs")
+			return
+		}
+	}
+
+	if section != "" {
+		s.sendJSON(w, http.StatusOK, map[string]interface{}{
+			"downloadLogs":  downloadLogs,
+			"downloadTotal": downloadTotal,
+			"emailLogs":     emailLogs,
+			"emailTotal":    emailTotal,
+			"pageSize":      downloadPage.Limit,
+		})
+		return
+	}
+
+	// Get expiry reminders sent to email recipients
+	expiryReminders, err := database.DB.GetExpiryRemindersByFileID(fileID)
 	if err != nil {
-		s.sendError(w, http.StatusInternalServerError, "Failed to get email logs")
+		s.sendError(w, http.StatusInternalServerError, "Failed to get expiry reminders")
+		return
+	}
+
+	// Get requests for a new link sent from the expired page
+	reshareRequests, err := database.DB.GetReshareRequestsByFileID(fileID)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to get new link requests")
 		return
 	}
 
 	s.sendJSON(w, http.StatusOK, map[string]interface{}{
-		"downloadLogs": downloadLogs,
-		"emailLogs":    emailLogs,
+		"downloadLogs":               downloadLogs,
+		"downloadTotal":              downloadTotal,
+		"emailLogs":                  emailLogs,
+		"emailTotal":                 emailTotal,
+		"pageSize":                   downloadPage.Limit,
+		"expiryReminders":            expiryReminders,
+		"reshareRequests":            reshareRequests,
+		"privateDownloadLog":         database.DB.IsDownloadLogPrivate(fileID),
+		"metadataStripped":           database.DB.IsFileMetadataStripped(fileID),
+		"downloadLogDetailsDisabled": database.DB.IsDownloadLogDetailsDisabled(),
+		"activeViewers":              activeSplashViewers(fileID),
 	})
 }
 
@@ -243,16 +518,27 @@ func (s *Server) handleFileDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep the file in trash longer or shorter than usual, if requested
+	trashRetentionDays := 0
+	if value := r.FormValue("trash_retention_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid trash retention")
+			return
+		}
+		if err := database.DB.ValidateTrashRetentionOverride(days); err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		trashRetentionDays = days
+	}
+
 	// Soft delete (move to trash)
-	if err := database.DB.DeleteFile(fileID, user.Id); err != nil {
+	if err := database.DB.DeleteFileWithRetention(fileID, user.Id, trashRetentionDays); err != nil {
 		s.sendError(w, http.StatusInternalServerError, "Failed to delete file")
 		return
 	}
 
-	// Recalculate user storage
-	newStorage, _ := database.DB.CalculateUserStorage(user.Id)
-	database.DB.UpdateUserStorage(user.Id, newStorage)
-
 	log.Printf("File deleted: %s by user %d", fileInfo.Name, user.Id)
 
 	// Log the action
@@ -262,19 +548,40 @@ func (s *Server) handleFileDelete(w http.ResponseWriter, r *http.Request) {
 		Action:     "FILE_DELETED",
 		EntityType: "File",
 		EntityID:   fileID,
-		Details:    fmt.Sprintf("{\"file_name\":\"%s\",\"size\":%d}", fileInfo.Name, fileInfo.SizeBytes),
-		IPAddress:  getClientIP(r),
+		Details:    fmt.Sprintf("{\"file_name\":\"%s\",\"size\":%d,\"trash_retention_days\":%d}", fileInfo.Name, fileInfo.SizeBytes, trashRetentionDays),
+		IPAddress:  clientIP(r),
 		UserAgent:  r.UserAgent(),
 		Success:    true,
 		ErrorMsg:   "",
 	})
 
-	s.sendJSON(w, http.StatusOK, map[string]string{
+	response := map[string]interface{}{
 		"message": "File deleted successfully",
-	})
+	}
+	// For a short while the delete can be undone with /file/undelete
+	if window := getDeleteUndoWindow(); window > 0 {
+		if token := issueDeleteUndoToken(fileID, user.Id, window); token != "" {
+			response["undo_token"] = token
+			response["undo_expires_in"] = int(window / time.Second)
+		}
+	}
+	s.sendJSON(w, http.StatusOK, response)
+}
+
+// trashRetentionDataAttrs returns the dashboard <body> attributes that let the delete dialog ask
+// how long to keep a file in trash, or "" when per-file trash retention is turned off
+func trashRetentionDataAttrs(defaultDays int) string {
+	minDays, maxDays := database.DB.GetTrashRetentionOverrideBounds()
+	if maxDays == 0 {
+		return ""
+	}
+	if defaultDays <= 0 {
+		defaultDays = 5
+	}
+	return fmt.Sprintf(` data-trash-retention-min="%d" data-trash-retention-max="%d" data-trash-retention-default="%d"`, minDays, maxDays, defaultDays)
 }
 
-// handleFileEmail sends a file link via email
+// handleFileEmail queues an email with a file link
 func (s *Server) handleFileEmail(w http.ResponseWriter, r *http.Request) {
 	user, ok := userFromContext(r.Context())
 	if !ok {
@@ -320,170 +627,49 @@ func (s *Server) handleFileEmail(w http.ResponseWriter, r *http.Request) {
 	// Construct file URL
 	fileURL := fmt.Sprintf("%s/s/%s", s.getPublicURL(), fileInfo.Id)
 
-	// Get active email provider
-	provider, err := email.GetActiveProvider(database.DB)
-	if err != nil {
+	// Emails are only queued if there is a provider to send them
+	if _, err := email.GetActiveProvider(database.DB); err != nil {
 		s.sendError(w, http.StatusInternalServerError, "No active email provider configured")
 		return
 	}
 
-	// Get branding config for email styling
-	brandingConfig, _ := database.DB.GetBrandingConfig()
-	primaryColor := brandingConfig["branding_primary_color"]
-	if primaryColor == "" {
-		primaryColor = "#2563eb"
+	data := email.NewTemplateData(s.config.CompanyName)
+	data.RecipientEmail = request.Recipient
+	data.SenderName = user.Name
+	data.SenderEmail = user.Email
+	data.Message = request.Message
+	data.Link = fileURL
+	data.File = &email.TemplateFile{
+		Name:    fileInfo.Name,
+		Size:    database.FormatFileSize(fileInfo.SizeBytes),
+		Comment: fileInfo.Comment,
 	}
-	companyName := brandingConfig["branding_company_name"]
-	if companyName == "" {
-		companyName = s.config.CompanyName
-	}
-
-	// Construct email content
-	subject := fmt.Sprintf("%s has shared a file with you via %s", user.Name, companyName)
-
-	htmlBody := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-	<meta charset="UTF-8">
-</head>
-<body style="margin: 0; padding: 0; font-family: Arial, Helvetica, sans-serif;">
-	<table width="100%%" cellpadding="0" cellspacing="0" style="background-color: #f0f0f0; padding: 20px 0;">
-		<tr>
-			<td align="center">
-				<table width="600" cellpadding="0" cellspacing="0" style="background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0,0,0,0.1);">
-					<!-- Header -->
-					<tr>
-						<td style="background-color: #1e3a5f; padding: 30px; text-align: center;">
-							<h1 style="color: #ffffff; margin: 0; font-size: 24px;">%s</h1>
-							<p style="color: #a0c4e8; margin: 10px 0 0 0; font-size: 14px;">Secure File Transfer</p>
-						</td>
-					</tr>
-
-					<!-- Main Content -->
-					<tr>
-						<td style="padding: 40px 30px;">
-							<!-- What is this -->
-							<div style="background-color: #e8f4fd; border-left: 4px solid #2563eb; padding: 15px; margin-bottom: 25px;">
-								<p style="margin: 0; color: #1e3a5f; font-size: 16px;">
-									<strong>What is this?</strong><br>
-									<strong>%s</strong> has sent you a file. Click the blue button below to download it.
-								</p>
-							</div>
-
-							<!-- File Info Box -->
-							<div style="background-color: #f8fafc; border: 2px solid #e2e8f0; border-radius: 8px; padding: 20px; margin-bottom: 20px;">
-								<h3 style="margin: 0 0 10px 0; color: #1e3a5f; font-size: 18px;">📄 %s</h3>
-								<p style="margin: 0; color: #64748b; font-size: 14px;">Size: %.2f MB</p>
-							</div>
-
-							%s
-							%s
-
-							<!-- BIG BLUE DOWNLOAD BUTTON -->
-							<table width="100%%" cellpadding="0" cellspacing="0" style="margin: 30px 0;">
-								<tr>
-									<td align="center">
-										<a href="%s" style="display: inline-block; background-color: #2563eb; color: #ffffff; padding: 20px 50px; text-decoration: none; border-radius: 8px; font-size: 20px; font-weight: bold; border: 3px solid #1d4ed8; box-shadow: 0 4px 12px rgba(37, 99, 235, 0.4); text-transform: uppercase; letter-spacing: 1px;">
-											⬇️ DOWNLOAD FILE
-										</a>
-									</td>
-								</tr>
-							</table>
-
-							<!-- Backup Link -->
-							<div style="background-color: #f3f4f6; padding: 15px; border-radius: 6px; margin-top: 20px;">
-								<p style="margin: 0 0 8px 0; color: #374151; font-size: 12px;">
-									<strong>If the button doesn't work, copy this link:</strong>
-								</p>
-								<p style="margin: 0; word-break: break-all; font-size: 11px;">
-									<a href="%s" style="color: #2563eb;">%s</a>
-								</p>
-							</div>
-						</td>
-					</tr>
-
-					<!-- Footer -->
-					<tr>
-						<td style="background-color: #1e3a5f; padding: 20px; text-align: center;">
-							<p style="margin: 0; color: #a0c4e8; font-size: 12px;">
-								This is an automated message from %s
-							</p>
-						</td>
-					</tr>
-				</table>
-			</td>
-		</tr>
-	</table>
-</body>
-</html>
-	`, companyName, user.Name, fileInfo.Name, float64(fileInfo.SizeBytes)/(1024*1024),
-		func() string {
-			if fileInfo.Comment != "" {
-				return fmt.Sprintf(`
-							<!-- File Description -->
-							<div style="background-color: #f0f9ff; border-left: 4px solid #2563eb; padding: 15px; margin-bottom: 15px; border-radius: 0 8px 8px 0;">
-								<p style="margin: 0 0 8px 0; color: #1d4ed8; font-weight: 600; font-size: 14px;">📝 File Description:</p>
-								<p style="margin: 0; color: #334155; font-size: 14px; line-height: 1.5;">%s</p>
-							</div>`, template.HTMLEscapeString(fileInfo.Comment))
-			}
-			return ""
-		}(),
-		func() string {
-			if request.Message != "" {
-				return fmt.Sprintf(`
-							<!-- Message from sender -->
-							<div style="background-color: #fef3c7; border-left: 4px solid #f59e0b; padding: 15px; margin-bottom: 15px; border-radius: 0 8px 8px 0;">
-								<p style="margin: 0 0 8px 0; color: #92400e; font-weight: 600; font-size: 14px;">💬 Message from %s:</p>
-								<p style="margin: 0; color: #78350f; font-size: 14px; line-height: 1.5;">%s</p>
-							</div>`, user.Name, template.HTMLEscapeString(request.Message))
-			}
-			return ""
-		}(),
-		fileURL, fileURL, fileURL, companyName)
-
-	textBody := fmt.Sprintf(
-		`FILE SHARED WITH YOU
-====================
-
-WHAT IS THIS?
-%s has sent you a file. Use the link below to download it.
-
-FILE: %s
-SIZE: %.2f MB
-
-%s%sDOWNLOAD YOUR FILE:
-%s
-
----
-This is an automated message from %s`,
-		user.Name, fileInfo.Name, float64(fileInfo.SizeBytes)/(1024*1024),
-		func() string {
-			if fileInfo.Comment != "" {
-				return fmt.Sprintf("FILE DESCRIPTION:\n%s\n\n", fileInfo.Comment)
-			}
-			return ""
-		}(),
-		func() string {
-			if request.Message != "" {
-				return fmt.Sprintf("MESSAGE FROM %s:\n%s\n\n", user.Name, request.Message)
-			}
-			return ""
-		}(),
-		fileURL, companyName,
-	)
-
-	// Send email
-	if err := provider.SendEmail(request.Recipient, subject, htmlBody, textBody); err != nil {
-		log.Printf("Failed to send email to %s: %v", request.Recipient, err)
-		s.sendError(w, http.StatusInternalServerError, "Failed to send email: "+err.Error())
+	subject, htmlBody, textBody, err := email.RenderEmail(email.TemplateFileShare, data)
+	if err != nil {
+		log.Printf("Failed to render file email: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to create email: "+err.Error())
 		return
 	}
 
-	// Log the email send to database
-	if err := database.DB.LogEmailSent(fileInfo.Id, user.Id, request.Recipient, request.Message, fileInfo.Name, fileInfo.SizeBytes); err != nil {
-		log.Printf("Warning: Failed to log email send: %v", err)
-		// Don't fail the request if logging fails
+	// Queue the email. The queue worker sends it, retries it if sending fails and logs the
+	// outcome in the file's email log.
+	queued := &database.QueuedEmail{
+		Kind:          database.EmailKindFileLink,
+		Recipient:     request.Recipient,
+		Subject:       subject,
+		HTMLBody:      htmlBody,
+		TextBody:      textBody,
+		FileId:        fileInfo.Id,
+		FileName:      fileInfo.Name,
+		FileSize:      fileInfo.SizeBytes,
+		SenderUserId:  user.Id,
+		SenderMessage: request.Message,
+		DedupKey:      database.NewEmailSendKey(fileInfo.Id, request.Recipient),
+	}
+	if err := email.Enqueue(queued); err != nil {
+		log.Printf("Failed to queue email to %s: %v", request.Recipient, err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to queue email: "+err.Error())
+		return
 	}
 
 	// Audit log for email sent
@@ -494,25 +680,27 @@ This is an automated message from %s`,
 		EntityType: database.EntityFile,
 		EntityID:   fileInfo.Id,
 		Details: database.CreateAuditDetails(map[string]interface{}{
-			"recipient":  request.Recipient,
-			"file_name":  fileInfo.Name,
-			"file_size":  fileInfo.SizeBytes,
+			"recipient":   request.Recipient,
+			"file_name":   fileInfo.Name,
+			"file_size":   fileInfo.SizeBytes,
 			"has_message": request.Message != "",
+			"queue_id":    queued.Id,
 		}),
-		IPAddress: r.RemoteAddr,
+		IPAddress: clientIP(r),
 		UserAgent: r.UserAgent(),
 		Success:   true,
 	})
 
-	log.Printf("File link emailed: %s to %s by user %d", fileInfo.Name, request.Recipient, user.Id)
+	log.Printf("File link email queued: %s to %s by user %d", fileInfo.Name, request.Recipient, user.Id)
 
 	s.sendJSON(w, http.StatusOK, map[string]string{
-		"message": "Email sent successfully",
+		"status":  "queued",
+		"message": "Email queued for delivery",
 	})
 }
 
 // renderUserDashboard renders the user dashboard HTML
-func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{}) {
+func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{}, category string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	user := userModel.(*models.User)
@@ -527,11 +715,77 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 	joke := models.GetJokeOfTheDay()
 
 	// Get user's files (including team files)
-	files, err := database.DB.GetFilesByUserWithTeams(user.Id)
+	files, err := database.DB.GetFilesByUserWithTeams(user.Id, &database.FileFilter{Category: category})
 	if err != nil {
 		log.Printf("Warning: Failed to get files with teams for user %d: %v", user.Id, err)
 		// Fallback to user's own files only
-		files, _ = database.DB.GetFilesByUser(user.Id)
+		files, _ = database.DB.GetFilesFiltered(&database.FileFilter{UserId: user.Id, Category: category})
+	}
+
+	// Upload form default for keeping downloader details out of the download log
+	downloadLogDetailsDisabled := database.DB.IsDownloadLogDetailsDisabled()
+	privateDownloadLogAttrs := ""
+	editPrivateDownloadLogAttrs := ""
+	privateDownloadLogHelp := "Only download counts and times are recorded"
+	if downloadLogDetailsDisabled {
+		privateDownloadLogAttrs = "checked disabled"
+		editPrivateDownloadLogAttrs = "disabled"
+		privateDownloadLogHelp += " (set for all files by the administrator)"
+	} else if database.DB.GetPrivateDownloadLogDefault() {
+		privateDownloadLogAttrs = "checked"
+	}
+
+	// Upload form default for removing image metadata
+	stripMetadataAttrs := ""
+	if database.DB.GetStripMetadataDefault() {
+		stripMetadataAttrs = "checked"
+	}
+	stripMetadataHelp := "Removes EXIF data such as GPS location and camera details from JPEG, PNG and WebP images"
+	if database.DB.IsDocumentMetadataStrippingEnabled() {
+		stripMetadataHelp += ", and author and company properties from Word, Excel and PowerPoint files"
+	}
+
+	// Download terms are only enforced once an administrator has published them
+	requireTermsHelp := "Recipients must accept the current download terms before downloading"
+	if terms, _ := database.DB.GetCurrentDownloadTerms(); terms == nil {
+		requireTermsHelp = "No download terms have been published yet, so downloads are not blocked until an administrator publishes them"
+	}
+
+	// Retries are only free within the administrator's retry window
+	downloadRetryHelp := "A recipient whose download broke off can download the file again from the same browser and login within " + strconv.Itoa(int(database.DB.GetDownloadRetryWindow()/time.Minute)) + " minutes without using up another download, even after the last download. Anyone else is counted as usual"
+	if database.DB.GetDownloadRetryWindow() == 0 {
+		downloadRetryHelp = "The administrator has turned the retry window off, so retries are counted like any other download"
+	}
+
+	// Upload requests can be branded with a team the user is a member of
+	fileRequestTeamSelectHTML := ""
+	if teamOptions := fileRequestTeamOptionsHTML(user.Id); teamOptions != "" {
+		fileRequestTeamSelectHTML = `
+                        <select id="requestTeam" style="width: 100%; margin-top: 12px; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                            <option value="0">Not on behalf of a team</option>` + teamOptions + `
+                        </select>`
+	}
+
+	// Viewer limits only apply while the administrator has splash page viewer tracking on
+	viewerTracking := database.DB.IsSplashViewerTrackingEnabled()
+	maxViewersHTML := ""
+	if viewerTracking {
+		maxViewersHTML = `
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; color: #555; font-weight: 500;">👥 Maximum viewers at once:</label>
+                <input type="number" id="editMaxViewers" min="0" value="0" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">How many people may have the download page open at the same time. Others see a busy page that retries by itself (0 = no limit)</p>
+            </div>
+`
+	}
+
+	// Files without their own download speed limit use the server's limit
+	downloadLimitHelp := "0 = no limit"
+	if globalLimit := database.DB.GetConfigInt("download_max_mbps", 0); globalLimit > 0 {
+		downloadLimitHelp = fmt.Sprintf("0 = the server's limit of %d MB/s", globalLimit)
+		if !user.IsAdmin() {
+			downloadLimitHelp += ", which only admins can exceed"
+		}
 	}
 
 	// Get team names for all files
@@ -545,6 +799,13 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 		fileTeams = make(map[string][]string) // Empty map as fallback
 	}
 
+	// Get approval status for public uploads
+	fileApprovals, err := database.DB.GetFileApprovalStatuses(fileIds)
+	if err != nil {
+		log.Printf("Warning: Failed to get approval statuses for files: %v", err)
+		fileApprovals = make(map[string]string)
+	}
+
 	// Collect all unique team names for the team filter dropdown
 	allTeamNames := make(map[string]bool)
 	for _, teams := range fileTeams {
@@ -584,12 +845,17 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 		totalDownloads += f.DownloadCount
 	}
 
+	bytesServed, err := database.DB.GetBytesSentByUser(user.Id)
+	if err != nil {
+		log.Printf("Warning: Failed to get bytes served for user %d: %v", user.Id, err)
+	}
+
 	// Stats with real data
 	storageUsedGB := fmt.Sprintf("%.1f", float64(storageUsed)/1000)
 	storageQuotaGB := fmt.Sprintf("%.1f", float64(storageQuota)/1000)
 
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -888,7 +1154,7 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
         }
     </style>
 </head>
-<body data-user-id="` + fmt.Sprintf("%d", user.Id) + `">
+<body data-user-id="` + fmt.Sprintf("%d", user.Id) + `"` + trashRetentionDataAttrs(s.config.TrashRetentionDays) + `>
     ` + s.getHeaderHTML(user, user.IsAdmin()) + `
     <div class="container">
         <div class="joke-section">
@@ -912,6 +1178,7 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             <div class="stat-card">
                 <h3>Total Downloads</h3>
                 <div class="value">` + fmt.Sprintf("%d", totalDownloads) + `</div>
+                <p style="margin-top: 8px; color: #999; font-size: 14px;">` + database.FormatFileSize(bytesServed) + ` transferred</p>
             </div>
         </div>
 
@@ -939,6 +1206,14 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                         </p>
                     </div>
 
+                    <div class="form-group">
+                        <label for="fileSlug">🔗 Link name (optional)</label>
+                        <input type="text" id="fileSlug" name="slug" maxlength="64" placeholder="e.g. q3-report">
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            Share the file as <code>/s/link-name</code> instead of a random ID: 3-64 letters, digits, hyphens and underscores
+                        </p>
+                    </div>
+
                     <div class="form-row">
                         <div class="form-group">
                             <label for="expireDate">📅 Expiration Date</label>
@@ -957,6 +1232,14 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                         </div>
                     </div>
 
+                    <div class="form-group">
+                        <label for="activateAt">🚀 Link goes live (optional)</label>
+                        <input type="datetime-local" id="activateAt" name="activate_at">
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            Until then the link shows that the file isn't available yet. Leave empty to share right away. Times are in the server's timezone (` + database.ServerLocation().String() + `)
+                        </p>
+                    </div>
+
                     <div class="form-group">
                         <label>🔗 Link Type</label>
                         <div style="display: flex; gap: 16px; margin-top: 8px;">
@@ -981,6 +1264,64 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                         </label>
                     </div>
 
+                    <div class="form-group">
+                        <label>
+                            <input type="checkbox" id="privateDownloadLog" ` + privateDownloadLogAttrs + `>
+                            🕶️ Don't log downloader IP addresses and browsers
+                        </label>
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            ` + privateDownloadLogHelp + `
+                        </p>
+                    </div>
+
+                    <div class="form-group">
+                        <label>
+                            <input type="checkbox" id="stripMetadata" ` + stripMetadataAttrs + `>
+                            🧹 Remove metadata before sharing
+                        </label>
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            ` + stripMetadataHelp + `
+                        </p>
+                    </div>
+
+                    <div class="form-group">
+                        <label>
+                            <input type="checkbox" id="burnAfterDownload">
+                            🔥 Burn after download
+                        </label>
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            The file is removed as soon as it has been downloaded once, whatever its download limit. Later visitors are told the link was used.
+                        </p>
+                    </div>
+
+                    <div class="form-group">
+                        <label>
+                            <input type="checkbox" id="notifyOnDownload" checked>
+                            📬 Email me when the file is downloaded
+                        </label>
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            You get the downloader's email address if they signed in, their IP address and the time. Files downloaded often are summed up in one email instead.
+                        </p>
+                    </div>
+
+                    <div class="form-group">
+                        <label>
+                            <input type="checkbox" id="requireTerms">
+                            📜 Require acceptance of download terms
+                        </label>
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            ` + requireTermsHelp + `
+                        </p>
+                    </div>
+
+                    <div class="form-group">
+                        <label for="splashTemplate">🖼️ Download page</label>
+                        <select id="splashTemplate" name="splash_template" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; background: white;">` + splashTemplateOptionsHTML(database.SplashTemplateDetailed) + `</select>
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">
+                            How the page recipients open looks. With the legal notice, the download log records when and from where each recipient agreed
+                        </p>
+                    </div>
+
                     <div class="form-group">
                         <label>
                             <input type="checkbox" id="enablePassword" onchange="togglePasswordField()">
@@ -1081,7 +1422,17 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 
                     <div style="margin-bottom: 20px;">
                         <label style="display: block; margin-bottom: 8px; color: #333; font-weight: 600;">Message (optional)</label>
-                        <textarea id="requestMessage" placeholder="Additional instructions for the uploader..." style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; min-height: 80px; resize: vertical;"></textarea>
+                        <textarea id="requestMessage" maxlength="2000" placeholder="Additional instructions for the uploader..." style="width: 100%; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; min-height: 80px; resize: vertical;"></textarea>
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">Shown on the upload page. Line breaks are kept</p>
+                    </div>
+
+                    <div style="margin-bottom: 20px;">
+                        <label style="display: block; margin-bottom: 8px; color: #333; font-weight: 600;">Upload page branding (optional)</label>
+                        <div style="display: flex; gap: 12px;">
+                            <input type="text" id="requestBrandName" maxlength="100" placeholder="Name shown on the page" style="flex: 1; padding: 12px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                            <input type="color" id="requestBrandColor" value="` + s.getPrimaryColor() + `" title="Page color" oninput="this.dataset.changed = '1'" style="width: 56px; height: 46px; padding: 4px; border: 2px solid #e0e0e0; border-radius: 6px; cursor: pointer;">
+                        </div>` + fileRequestTeamSelectHTML + `
+                        <p style="color: #666; font-size: 12px; margin-top: 4px;">Leave the name empty to show the team or company name</p>
                     </div>
 
                     <div style="margin-bottom: 20px; padding: 14px; background: #fff3cd; border: 2px solid #ffc107; border-radius: 8px;">
@@ -1144,6 +1495,9 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                         <option value="size-desc">📦 Largest First</option>
                         <option value="size-asc">📦 Smallest First</option>
                     </select>
+                    <select id="fileCategoryFilter" onchange="applyCategoryFilter()" style="padding: 10px 15px; border: 2px solid #e0e0e0; border-radius: 8px; font-size: 14px; background: white; cursor: pointer;">
+                        <option value="">All categories</option>` + fileCategoryOptionsHTML(category) + `
+                    </select>
                     <select id="perPageSelect" onchange="changePerPage()" style="padding: 10px 15px; border: 2px solid ` + s.getPrimaryColor() + `; border-radius: 8px; font-size: 14px; background: white; cursor: pointer; font-weight: 500;">
                         <option value="5">5 per page</option>
                         <option value="25" selected>25 per page</option>
@@ -1166,7 +1520,12 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                 </div>
             </div>`
 
-	if len(files) == 0 {
+	if len(files) == 0 && category != "" {
+		html += `
+            <div class="empty-state">
+                No files in this category.
+            </div>`
+	} else if len(files) == 0 {
 		html += `
             <div class="empty-state">
                 No files uploaded yet. Start by uploading your first file!
@@ -1176,20 +1535,40 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             <ul class="file-list">`
 		for _, f := range files {
 			// Both URL types
-			splashURL := s.getPublicURL() + "/s/" + f.Id
-			directURL := s.getPublicURL() + "/d/" + f.Id
+			linkID := fileLinkID(f.Id)
+			splashURL := s.getPublicURL() + "/s/" + linkID
+			directURL := s.getPublicURL() + "/d/" + linkID
 			// Escape URLs for safe use in JavaScript
 			splashURLEscaped := template.HTMLEscapeString(splashURL)
 			directURLEscaped := template.HTMLEscapeString(directURL)
 			status := "Active"
 			statusColor := "#4caf50"
-
-			if !f.UnlimitedDownloads && f.DownloadsRemaining <= 0 {
+			lockedUntil, lockReason := database.DB.GetFileDownloadBlock(f.Id)
+
+			if f.ScanStatus == database.ScanInfected {
+				status = "Quarantined (malware found)"
+				statusColor = "#c62828"
+			} else if !lockedUntil.IsZero() {
+				status = "Downloads locked until " + lockedUntil.In(database.ServerLocation()).Format(downloadLockTimeLayout) + " (" + template.HTMLEscapeString(lockReason) + ")"
+				if f.UserId == user.Id || user.IsAdmin() {
+					status += ` <button class="btn btn-secondary" onclick="unblockDownloads('` + f.Id + `', '` + template.JSEscapeString(f.Name) + `')" style="font-size: 11px; padding: 4px 8px; margin-left: 8px;">🔓 Unlock</button>`
+				}
+				statusColor = "#e65100"
+			} else if !f.UnlimitedDownloads && f.DownloadsRemaining <= 0 {
 				status = "Expired (downloads)"
 				statusColor = "#f44336"
-			} else if !f.UnlimitedTime && f.ExpireAt > 0 && f.ExpireAt < time.Now().Unix() {
+			} else if f.ExpiredReason(time.Now()) == database.FileExpiredByTime {
 				status = "Expired (time)"
 				statusColor = "#f44336"
+			} else if f.IsScheduled(time.Now()) {
+				status = "Scheduled (live from " + time.Unix(f.ActivateAt, 0).In(database.ServerLocation()).Format(downloadLockTimeLayout) + ")"
+				statusColor = "#1e88e5"
+			} else if !f.RequireAuth && fileApprovals[f.Id] == database.ApprovalStatusPending {
+				status = "Pending approval"
+				statusColor = "#ff9800"
+			} else if !f.RequireAuth && fileApprovals[f.Id] == database.ApprovalStatusRejected {
+				status = "Rejected"
+				statusColor = "#f44336"
 			}
 
 			expiryInfo := ""
@@ -1212,6 +1591,10 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 			if f.FilePasswordPlain != "" {
 				passwordBadge = `<span style="background: #9c27b0; color: white; padding: 2px 8px; border-radius: 4px; font-size: 12px; margin-left: 8px;">🔐 Password Protected</span>`
 			}
+			if f.BurnAfterDownload {
+				passwordBadge += `<span style="background: #e65100; color: white; padding: 2px 8px; border-radius: 4px; font-size: 12px; margin-left: 8px;">🔥 Burn After Download</span>`
+			}
+			passwordBadge += scanStatusBadge(f)
 
 			// Team badges
 			teamBadges := ""
@@ -1234,6 +1617,16 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 				}
 			}
 
+			// Show how many people have the splash page open right now
+			viewersBadge := ""
+			if viewerTracking {
+				viewerCount := fmt.Sprintf("%d", activeSplashViewers(f.Id))
+				if maxViewers := database.DB.GetFileMaxViewers(f.Id); maxViewers > 0 {
+					viewerCount += fmt.Sprintf(" / %d", maxViewers)
+				}
+				viewersBadge = `<span style="background: #607d8b; color: white; padding: 2px 8px; border-radius: 4px; font-size: 12px; margin-left: 8px;" title="People with the download page open right now">👁️ ` + viewerCount + ` viewing</span>`
+			}
+
 			// Determine file type (my file vs team file)
 			fileType := "my"
 			if isTeamFile && f.UserId != user.Id {
@@ -1275,7 +1668,7 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 			}
 
 			html += fmt.Sprintf(`
-                <li class="file-item" data-file-type="%s" data-teams="%s" data-filename="%s" data-extension="%s" data-size="%d" data-timestamp="%d" data-downloads="%d" data-comment="%s">
+                <li class="file-item" data-file-id="%s" data-file-type="%s" data-teams="%s" data-filename="%s" data-extension="%s" data-size="%d" data-timestamp="%d" data-downloads="%d" data-comment="%s">
                     <div class="file-info">
                         <h3 title="%s">
                             <span style="display: inline-block; max-width: 600px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; vertical-align: bottom;">📄 %s</span>%s%s%s
@@ -1300,10 +1693,13 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                             <button class="btn btn-secondary" onclick="showDownloadHistory('%s', '%s')" title="View download history" style="flex: 0 0 auto;">
                                 📊 History
                             </button>
+                            <a class="btn btn-secondary" href="/file/versions?file_id=%s" title="Upload a new version or restore an earlier one" style="flex: 0 0 auto; text-decoration: none;">
+                                🕘 Versions
+                            </a>
                             <button class="btn btn-primary" onclick="showEmailModal('%s', '%s', '%s')" title="Send file link via email" style="background: #007bff; flex: 0 0 auto;">
                                 📧 Email
                             </button>
-                            <button class="btn btn-secondary" onclick="showEditModal('%s', '%s', %d, %d, %t, %t, '%s', %t, '%s')" title="Edit file settings" style="flex: 0 0 auto;">
+                            <button class="btn btn-secondary" onclick="showEditModal('%s', '%s', %d, %d, %t, %t, '%s', %t, '%s', %t, %t, %t, '%s', %d, '%s', %t, '%s', %t, %d, '%s', %t, %t, '%s')" title="Edit file settings" style="flex: 0 0 auto;">
                                 ✏️ Edit
                             </button>
                             <button class="btn btn-danger" onclick="deleteFile('%s', '%s')" style="flex: 0 0 auto; background: #dc3545 !important; color: white;">
@@ -1311,10 +1707,10 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                             </button>
                         </div>
                     </div>
-                </li>`, fileType, dataTeamsAttr, template.HTMLEscapeString(f.Name), fileExt, f.SizeBytes, f.UploadDate, f.DownloadCount, template.HTMLEscapeString(f.Comment), template.HTMLEscapeString(f.Name), template.HTMLEscapeString(f.Name), authBadge, passwordBadge, teamBadges, commentDisplay, f.Size, f.DownloadCount, expiryInfo, statusColor, status, passwordDisplay,
+                </li>`, template.HTMLEscapeString(f.Id), fileType, dataTeamsAttr, template.HTMLEscapeString(f.Name), fileExt, f.SizeBytes, f.UploadDate, f.DownloadCount, template.HTMLEscapeString(f.Comment), template.HTMLEscapeString(f.Name), template.HTMLEscapeString(f.Name), authBadge, passwordBadge, teamBadges+viewersBadge, commentDisplay, f.Size, f.DownloadCount, expiryInfo, statusColor, status, passwordDisplay,
 				splashURL, splashURL, splashURLEscaped,
 				directURL, directURL, directURLEscaped,
-				f.Id, template.JSEscapeString(f.Name), f.Id, template.JSEscapeString(f.Name), template.JSEscapeString(splashURL), f.Id, template.JSEscapeString(f.Name), f.DownloadsRemaining, f.ExpireAt, f.UnlimitedDownloads, f.UnlimitedTime, template.JSEscapeString(f.Comment), f.RequireAuth, template.JSEscapeString(f.FilePasswordPlain), f.Id, template.JSEscapeString(f.Name))
+				f.Id, template.JSEscapeString(f.Name), url.QueryEscape(f.Id), f.Id, template.JSEscapeString(f.Name), template.JSEscapeString(splashURL), f.Id, template.JSEscapeString(f.Name), f.DownloadsRemaining, f.ExpireAt, f.UnlimitedDownloads, f.UnlimitedTime, template.JSEscapeString(f.Comment), f.RequireAuth, template.JSEscapeString(f.FilePasswordPlain), database.DB.IsFileExpiryRemindersEnabled(f.Id), database.DB.IsFilePrivateDownloadLog(f.Id), database.DB.IsFileTermsRequired(f.Id), template.JSEscapeString(database.DB.GetFileSplashTemplate(f.Id)), database.DB.GetFileMaxViewers(f.Id), template.JSEscapeString(database.DB.GetFileFilenameTemplate(f.Id)), database.DB.IsFileReshareRequestsEnabled(f.Id), template.JSEscapeString(database.DB.GetFileExpiredMessage(f.Id)), database.DB.IsFileDownloadRetryEnabled(f.Id), f.DownloadLimitMBps, expireDateOf(f), f.BurnAfterDownload, database.DB.IsFileNotifyOnDownload(f.Id), template.JSEscapeString(database.DB.GetFileSlug(f.Id)), f.Id, template.JSEscapeString(f.Name))
 		}
 		html += `
             </ul>`
@@ -1347,7 +1743,7 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 
     <!-- Edit File Modal -->
     <div id="editModal" style="display: none; position: fixed; top: 0; left: 0; right: 0; bottom: 0; background: rgba(0,0,0,0.5); z-index: 1000; align-items: center; justify-content: center;">
-        <div style="background: white; padding: 40px; border-radius: 12px; max-width: 500px; width: 90%;">
+        <div style="background: white; padding: 40px; border-radius: 12px; max-width: 500px; width: 90%; max-height: 90vh; overflow-y: auto;">
             <h2 style="margin-bottom: 24px; color: #333;">Edit File Settings</h2>
 
             <input type="hidden" id="editFileId">
@@ -1365,9 +1761,14 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             </div>
 
             <div id="editTimeLimitSection" style="margin-bottom: 20px;">
-                <label style="display: block; margin-bottom: 8px; font-weight: 500;">Days Until Expiration:</label>
-                <input type="number" id="editExpirationDays" value="7" min="0" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px;">
-                <p style="font-size: 12px; color: #999; margin-top: 4px;">Days from now until file expires</p>
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">Expiration Date:</label>
+                <input type="date" id="editExpireDate" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">The file expires at the end of this day. Keeping the date keeps the current expiry time.</p>
+                <label style="display: block; margin-top: 12px; font-weight: 500;">
+                    <input type="checkbox" id="editExpiryReminders">
+                    ⏰ Remind email recipients before this file expires
+                </label>
+                <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">Everyone you emailed this file to gets a reminder before it expires. Recipients can unsubscribe.</p>
             </div>
 
             <div style="margin-bottom: 20px;">
@@ -1380,6 +1781,18 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             <div id="editDownloadLimitSection" style="margin-bottom: 20px;">
                 <label style="display: block; margin-bottom: 8px; font-weight: 500;">Downloads Remaining:</label>
                 <input type="number" id="editDownloadsLimit" value="5" min="0" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                <div style="display: flex; gap: 8px; align-items: center; margin-top: 10px;">
+                    <input type="number" id="editResetDownloads" value="5" min="1" max="` + strconv.Itoa(database.MaxDownloadLimitReset) + `" style="width: 100px; padding: 8px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                    <button type="button" onclick="resetDownloads('set')" style="padding: 8px 14px; background: #f5f5f5; color: #333; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 13px; font-weight: 600; cursor: pointer;">🔄 Reset to this</button>
+                    <button type="button" onclick="resetDownloads('add')" style="padding: 8px 14px; background: #f5f5f5; color: #333; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 13px; font-weight: 600; cursor: pointer;">➕ Add</button>
+                </div>
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">Applies right away, also to a file whose downloads ran out. Downloads made meanwhile are not lost</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; color: #555; font-weight: 500;">🔗 Link name (optional):</label>
+                <input type="text" id="editSlug" maxlength="64" placeholder="e.g. q3-report" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">Share the file as <code>/s/link-name</code> and <code>/d/link-name</code>: 3-64 letters, digits, hyphens and underscores. Links with the file ID keep working. Leave empty to use the file ID only</p>
             </div>
 
             <div style="margin-bottom: 20px;">
@@ -1390,10 +1803,97 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 
             <div style="margin-bottom: 20px; padding-top: 20px; border-top: 2px solid #e0e0e0;">
                 <label style="display: block; margin-bottom: 8px; font-weight: 500;">
-                    <input type="checkbox" id="editRequireAuth">
+                    <input type="checkbox" id="editRequireAuth" onchange="toggleEditAccess()">
                     🔒 Require authentication to download
                 </label>
                 <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">If enabled, only logged-in users can download this file</p>
+                <div id="editAccessSection" style="display: none; margin-top: 12px; margin-left: 24px;">
+                    <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                        <input type="checkbox" id="editAccessRequests" onchange="toggleEditAccess()">
+                        🔑 Only people I give access (recipients can request access)
+                    </label>
+                    <div id="editAccessDetails" style="display: none;">
+                        <div id="editAccessRequestList" style="margin-bottom: 12px;"></div>
+                        <div id="editAccessGrantList" style="margin-bottom: 12px;"></div>
+                        <div style="display: flex; gap: 8px;">
+                            <input type="email" id="editAccessEmail" placeholder="name@example.com" style="flex: 1; padding: 8px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                            <button type="button" onclick="grantFileAccess()" style="padding: 8px 16px; background: ` + s.getPrimaryColor() + `; color: white; border: none; border-radius: 4px; font-size: 13px; cursor: pointer;">➕ Give Access</button>
+                        </div>
+                        <p style="font-size: 12px; color: #999; margin-top: 4px;">Recipients without access see a Request Access button. You get an email for each request and can approve it there or here.</p>
+                    </div>
+                </div>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                    <input type="checkbox" id="editPrivateDownloadLog" ` + editPrivateDownloadLogAttrs + `>
+                    🕶️ Don't log downloader IP addresses and browsers
+                </label>
+                <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">` + privateDownloadLogHelp + `</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                    <input type="checkbox" id="editRequireTerms">
+                    📜 Require acceptance of download terms
+                </label>
+                <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">` + requireTermsHelp + `</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; color: #555; font-weight: 500;">🖼️ Download page:</label>
+                <select id="editSplashTemplate" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; background: white;">` + splashTemplateOptionsHTML(database.SplashTemplateDetailed) + `</select>
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">With the legal notice, recipients must click "I agree" before downloading, and the download log records when and from where they agreed</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                    <input type="checkbox" id="editReshareRequests">
+                    🔁 Let recipients request a new link after expiry
+                </label>
+                <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">The expired page shows a Request a New Link button. You get a notification with the recipient's message; requests are listed in the file's history</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                    <input type="checkbox" id="editDownloadRetry">
+                    🔄 Allow download retries
+                </label>
+                <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">` + downloadRetryHelp + `</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                    <input type="checkbox" id="editBurnAfterDownload">
+                    🔥 Burn after download
+                </label>
+                <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">The file is removed as soon as it has been downloaded once, whatever its download limit. Download retries don't apply</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                    <input type="checkbox" id="editNotifyOnDownload">
+                    📬 Email me when the file is downloaded
+                </label>
+                <p style="font-size: 12px; color: #999; margin-top: 4px; margin-left: 24px;">Files downloaded often are summed up in one email instead</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; color: #555; font-weight: 500;">⏰ Message after expiry (optional):</label>
+                <textarea id="editExpiredMessage" rows="2" maxlength="500" placeholder="e.g. Contact sales@example.com for a new copy" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; font-family: inherit; resize: vertical;"></textarea>
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">Shown to recipients who open the link after it expired. Plain text only. Leave empty to show the server's default message, if any</p>
+            </div>
+` + maxViewersHTML + `
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; color: #555; font-weight: 500;">🚦 Download speed limit (MB/s):</label>
+                <input type="number" id="editDownloadLimit" min="0" value="0" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px;">
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">Total speed of all downloads of this file at once. ` + downloadLimitHelp + `</p>
+            </div>
+
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; color: #555; font-weight: 500;">🏷️ Download file name (optional):</label>
+                <input type="text" id="editFilenameTemplate" placeholder="e.g. {{.BaseName}}-{{.Date}}-{{.RecipientEmail}}" maxlength="200" style="width: 100%; padding: 10px; border: 2px solid #e0e0e0; border-radius: 6px; font-size: 14px; font-family: monospace;">
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">Name the downloaded file for each download. Fields: {{.BaseName}}, {{.Ext}}, {{.FileName}}, {{.Date}}, {{.Time}}, {{.RecipientEmail}}, {{.RecipientName}}. Recipient fields are only known when the recipient logs in; otherwise the file keeps its own name. Leave empty to always use the file's own name</p>
             </div>
 
             <div style="margin-bottom: 20px;">
@@ -1408,6 +1908,47 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                 </div>
             </div>
 
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 8px; font-weight: 500;">
+                    <input type="checkbox" id="editScheduleEnabled" onchange="toggleEditSchedule()">
+                    🕒 Only available during a schedule
+                </label>
+                <div id="editScheduleSection" style="display: none; margin-top: 12px; margin-left: 24px;">
+                    <div style="display: flex; gap: 12px; margin-bottom: 12px;">
+                        <div style="flex: 1;">
+                            <label style="display: block; margin-bottom: 6px; color: #555; font-size: 13px;">Available from:</label>
+                            <input type="datetime-local" id="editAvailableFrom" style="width: 100%; padding: 8px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                        </div>
+                        <div style="flex: 1;">
+                            <label style="display: block; margin-bottom: 6px; color: #555; font-size: 13px;">Available until:</label>
+                            <input type="datetime-local" id="editAvailableUntil" style="width: 100%; padding: 8px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                        </div>
+                    </div>
+                    <div style="display: flex; gap: 12px; margin-bottom: 12px;">
+                        <div style="flex: 1;">
+                            <label style="display: block; margin-bottom: 6px; color: #555; font-size: 13px;">Daily from:</label>
+                            <input type="time" id="editDailyStart" style="width: 100%; padding: 8px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                        </div>
+                        <div style="flex: 1;">
+                            <label style="display: block; margin-bottom: 6px; color: #555; font-size: 13px;">Daily until:</label>
+                            <input type="time" id="editDailyEnd" style="width: 100%; padding: 8px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                        </div>
+                    </div>
+                    <div id="editScheduleDays" style="display: flex; flex-wrap: wrap; gap: 10px; margin-bottom: 12px; font-size: 13px;">
+                        <label><input type="checkbox" value="1"> Mon</label>
+                        <label><input type="checkbox" value="2"> Tue</label>
+                        <label><input type="checkbox" value="3"> Wed</label>
+                        <label><input type="checkbox" value="4"> Thu</label>
+                        <label><input type="checkbox" value="5"> Fri</label>
+                        <label><input type="checkbox" value="6"> Sat</label>
+                        <label><input type="checkbox" value="0"> Sun</label>
+                    </div>
+                    <label style="display: block; margin-bottom: 6px; color: #555; font-size: 13px;">Timezone:</label>
+                    <input type="text" id="editScheduleTimezone" placeholder="Europe/Stockholm" style="width: 100%; padding: 8px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                    <p style="font-size: 12px; color: #999; margin-top: 4px;">Leave fields empty to not restrict them. No days checked means every day. Outside the schedule recipients see when the file becomes available. Expiry still applies.</p>
+                </div>
+            </div>
+
             <div style="margin-bottom: 20px; padding-top: 20px; border-top: 2px solid #e0e0e0;">
                 <label style="display: block; margin-bottom: 12px; font-weight: 500;">👥 Team Sharing:</label>
 
@@ -1510,6 +2051,14 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                 <p id="historyFileName" style="color: #666; font-weight: 600;"></p>
             </div>
 
+            <div style="display: flex; gap: 12px; align-items: center; flex-wrap: wrap; margin-bottom: 8px; font-size: 14px; color: #555;">
+                <label for="historyFrom">From</label>
+                <input type="date" id="historyFrom" onchange="loadDownloadHistory()" style="padding: 6px 10px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                <label for="historyTo">to</label>
+                <input type="date" id="historyTo" onchange="loadDownloadHistory()" style="padding: 6px 10px; border: 2px solid #e0e0e0; border-radius: 6px;">
+                <span style="color: #999; font-size: 12px;">Filters downloads and emails</span>
+            </div>
+
             <div id="downloadHistoryContent" style="margin-top: 20px;">
                 <p style="text-align: center; color: #999;">Loading...</p>
             </div>
@@ -1524,70 +2073,215 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 
     <script src="/static/js/dashboard.js?v=6.1.6"></script>
     <script>
+        let historyFileId = null;
+        let historyPrivateDownloadLog = false;
+        let historyShown = { downloads: 0, emails: 0 };
+        let historyTotals = { downloads: 0, emails: 0 };
+
         function showDownloadHistory(fileId, fileName) {
+            historyFileId = fileId;
             document.getElementById('historyFileName').textContent = fileName;
+            document.getElementById('historyFrom').value = '';
+            document.getElementById('historyTo').value = '';
             document.getElementById('downloadHistoryModal').style.display = 'flex';
+            loadDownloadHistory();
+        }
+
+        // historyURL builds the history request for the open file and the chosen dates
+        function historyURL(extra) {
+            const params = new URLSearchParams({ file_id: historyFileId });
+            const from = document.getElementById('historyFrom').value;
+            const to = document.getElementById('historyTo').value;
+            if (from) params.set('from', from);
+            if (to) params.set('to', to);
+            Object.keys(extra || {}).forEach(key => params.set(key, extra[key]));
+            return '/file/downloads?' + params.toString();
+        }
+
+        function downloadHistoryRows(logs) {
+            let html = '';
+            logs.forEach(log => {
+                const date = new Date(log.downloadedAt * 1000);
+                const dateStr = date.toLocaleString('sv-SE');
+                const downloader = log.email ? escapeHtml(log.email) : 'Anonymous';
+                const ip = log.ipAddress ? escapeHtml(log.ipAddress) : (historyPrivateDownloadLog ? 'Not logged' : 'N/A');
+                const authBadge = log.isAuthenticated ? ' <span style="background: #2196f3; color: white; padding: 2px 6px; border-radius: 3px; font-size: 11px;">🔒 Auth</span>' : '';
+                const termsBadge = log.termsVersion > 0 ? ' <span style="background: #6d4c41; color: white; padding: 2px 6px; border-radius: 3px; font-size: 11px;" title="Accepted ' + new Date(log.termsAcceptedAt * 1000).toLocaleString('sv-SE') + '">📜 Terms v' + log.termsVersion + '</span>' : '';
+                const noticeBadge = log.noticeAcceptedAt > 0 ? ' <span style="background: #f57c00; color: white; padding: 2px 6px; border-radius: 3px; font-size: 11px;" title="Agreed ' + new Date(log.noticeAcceptedAt * 1000).toLocaleString('sv-SE') + (log.noticeAcceptedIp ? ' from ' + escapeHtml(log.noticeAcceptedIp) : '') + '">⚖️ Notice agreed</span>' : '';
+
+                html += '<tr style="border-bottom: 1px solid #eee;">';
+                html += '<td style="padding: 12px;">' + dateStr + '</td>';
+                html += '<td style="padding: 12px;">' + downloader + authBadge + termsBadge + noticeBadge + '</td>';
+                html += '<td style="padding: 12px; font-family: monospace; font-size: 12px;">' + ip + '</td>';
+                html += '</tr>';
+            });
+            return html;
+        }
+
+        function emailHistoryRows(logs) {
+            let html = '';
+            logs.forEach(log => {
+                const date = new Date(log.sentAt * 1000);
+                const dateStr = date.toLocaleString('sv-SE');
+                const message = log.message ? escapeHtml(log.message) : '<em style="color: #999;">No message</em>';
+
+                html += '<tr style="border-bottom: 1px solid #eee;">';
+                html += '<td style="padding: 12px;">' + dateStr + '</td>';
+                html += '<td style="padding: 12px;">' + escapeHtml(log.recipientEmail);
+                if (log.status === 'failed') {
+                    html += ' <span style="color: #f44336; font-size: 12px; font-weight: 600;">❌ Failed</span>';
+                }
+                html += '</td>';
+                html += '<td style="padding: 12px; max-width: 300px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap;" title="' + escapeHtml(log.message || '') + '">' + message + '</td>';
+                html += '</tr>';
+            });
+            return html;
+        }
+
+        // historyMoreHTML shows how much of a list is loaded, with a button for the next page
+        function historyMoreHTML(section) {
+            let html = '<span>Showing ' + historyShown[section] + ' of ' + historyTotals[section] + '</span>';
+            if (historyShown[section] < historyTotals[section]) {
+                html += ' <button onclick="loadMoreHistory(\'' + section + '\')" style="margin-left: 12px; padding: 6px 14px; background: ` + s.getPrimaryColor() + `; color: white; border: none; border-radius: 6px; font-size: 13px; cursor: pointer;">Load more</button>';
+            }
+            return html;
+        }
+
+        function loadMoreHistory(section) {
+            const more = document.getElementById(section === 'downloads' ? 'historyDownloadMore' : 'historyEmailMore');
+            const extra = { section: section };
+            extra[section === 'downloads' ? 'download_offset' : 'email_offset'] = historyShown[section];
+            more.innerHTML = '<span>Loading...</span>';
+
+            fetch(historyURL(extra))
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        more.innerHTML = '<span style="color: #f44336;">' + escapeHtml(data.error) + '</span>';
+                        return;
+                    }
+                    const logs = (section === 'downloads' ? data.downloadLogs : data.emailLogs) || [];
+                    const rows = section === 'downloads' ? downloadHistoryRows(logs) : emailHistoryRows(logs);
+                    document.getElementById(section === 'downloads' ? 'historyDownloadRows' : 'historyEmailRows').insertAdjacentHTML('beforeend', rows);
+                    historyShown[section] += logs.length;
+                    historyTotals[section] = section === 'downloads' ? data.downloadTotal : data.emailTotal;
+                    more.innerHTML = historyMoreHTML(section);
+                })
+                .catch(error => {
+                    more.innerHTML = '<span style="color: #f44336;">Error loading history</span>';
+                    console.error('Error:', error);
+                });
+        }
+
+        function loadDownloadHistory() {
             document.getElementById('downloadHistoryContent').innerHTML = '<p style="text-align: center; color: #999;">Loading...</p>';
 
-            fetch('/file/downloads?file_id=' + encodeURIComponent(fileId))
+            fetch(historyURL())
                 .then(response => response.json())
                 .then(data => {
+                    if (data.error) {
+                        document.getElementById('downloadHistoryContent').innerHTML = '<p style="text-align: center; color: #f44336;">' + escapeHtml(data.error) + '</p>';
+                        return;
+                    }
+
                     const downloadLogs = data.downloadLogs || [];
                     const emailLogs = data.emailLogs || [];
+                    const expiryReminders = data.expiryReminders || [];
+                    const reshareRequests = data.reshareRequests || [];
+                    historyPrivateDownloadLog = data.privateDownloadLog;
+                    historyShown = { downloads: downloadLogs.length, emails: emailLogs.length };
+                    historyTotals = { downloads: data.downloadTotal || 0, emails: data.emailTotal || 0 };
 
-                    if (downloadLogs.length === 0 && emailLogs.length === 0) {
-                        document.getElementById('downloadHistoryContent').innerHTML = '<p style="text-align: center; color: #999;">No activity yet</p>';
-                        return;
+                    let html = '';
+
+                    // Tell the owner when downloader details are not being recorded
+                    if (data.privateDownloadLog) {
+                        const reason = data.downloadLogDetailsDisabled ? 'by the administrator for all files' : 'for this file';
+                        html += '<div style="margin-bottom: 20px; padding: 12px 16px; background: #f3e5f5; border-left: 4px solid #8e24aa; border-radius: 6px; color: #4a148c; font-size: 14px;">🕶️ Detailed download logging is disabled ' + reason + '. Only download counts and times are recorded, not IP addresses or browsers.</div>';
+                    }
+                    if (data.metadataStripped) {
+                        html += '<div style="margin-bottom: 20px; padding: 12px 16px; background: #e8f5e9; border-left: 4px solid #43a047; border-radius: 6px; color: #1b5e20; font-size: 14px;">🧹 Metadata such as location and camera details was removed from this file after upload.</div>';
                     }
 
-                    let html = '';
+                    if (downloadLogs.length === 0 && emailLogs.length === 0 && expiryReminders.length === 0 && reshareRequests.length === 0) {
+                        const filtered = document.getElementById('historyFrom').value || document.getElementById('historyTo').value;
+                        document.getElementById('downloadHistoryContent').innerHTML = html + '<p style="text-align: center; color: #999;">' + (filtered ? 'No activity in this period' : 'No activity yet') + '</p>';
+                        return;
+                    }
 
                     // Show download logs
                     if (downloadLogs.length > 0) {
-                        html += '<h3 style="margin-top: 0; margin-bottom: 15px; color: #333; font-size: 16px;">📥 Downloads (' + downloadLogs.length + ')</h3>';
-                        html += '<table style="width: 100%; border-collapse: collapse; margin-bottom: 30px;">';
+                        html += '<h3 style="margin-top: 0; margin-bottom: 15px; color: #333; font-size: 16px;">📥 Downloads (' + historyTotals.downloads + ')</h3>';
+                        html += '<table style="width: 100%; border-collapse: collapse;">';
                         html += '<thead><tr style="background: #f5f5f5; border-bottom: 2px solid #ddd;">';
                         html += '<th style="padding: 12px; text-align: left;">Date & Time</th>';
                         html += '<th style="padding: 12px; text-align: left;">Downloaded By</th>';
                         html += '<th style="padding: 12px; text-align: left;">IP Address</th>';
+                        html += '</tr></thead><tbody id="historyDownloadRows">' + downloadHistoryRows(downloadLogs) + '</tbody></table>';
+                        html += '<div id="historyDownloadMore" style="margin: 12px 0 30px; color: #666; font-size: 13px;">' + historyMoreHTML('downloads') + '</div>';
+                    }
+
+                    // Show email logs
+                    if (emailLogs.length > 0) {
+                        html += '<h3 style="margin-top: 0; margin-bottom: 15px; color: #333; font-size: 16px;">📧 Emails Sent (' + historyTotals.emails + ')</h3>';
+                        html += '<table style="width: 100%; border-collapse: collapse;">';
+                        html += '<thead><tr style="background: #f5f5f5; border-bottom: 2px solid #ddd;">';
+                        html += '<th style="padding: 12px; text-align: left;">Date & Time</th>';
+                        html += '<th style="padding: 12px; text-align: left;">Recipient</th>';
+                        html += '<th style="padding: 12px; text-align: left;">Message</th>';
+                        html += '</tr></thead><tbody id="historyEmailRows">' + emailHistoryRows(emailLogs) + '</tbody></table>';
+                        html += '<div id="historyEmailMore" style="margin-top: 12px; color: #666; font-size: 13px;">' + historyMoreHTML('emails') + '</div>';
+                    }
+
+                    // Show expiry reminders
+                    if (expiryReminders.length > 0) {
+                        html += '<h3 style="margin-top: 30px; margin-bottom: 15px; color: #333; font-size: 16px;">⏰ Expiry Reminders (' + expiryReminders.length + ')</h3>';
+                        html += '<table style="width: 100%; border-collapse: collapse;">';
+                        html += '<thead><tr style="background: #f5f5f5; border-bottom: 2px solid #ddd;">';
+                        html += '<th style="padding: 12px; text-align: left;">Date & Time</th>';
+                        html += '<th style="padding: 12px; text-align: left;">Recipient</th>';
+                        html += '<th style="padding: 12px; text-align: left;">Sent Before Expiry</th>';
                         html += '</tr></thead><tbody>';
 
-                        downloadLogs.forEach(log => {
-                            const date = new Date(log.downloadedAt * 1000);
+                        expiryReminders.forEach(reminder => {
+                            const date = new Date(reminder.sentAt * 1000);
                             const dateStr = date.toLocaleString('sv-SE');
-                            const downloader = log.email || 'Anonymous';
-                            const ip = log.ipAddress || 'N/A';
-                            const authBadge = log.isAuthenticated ? ' <span style="background: #2196f3; color: white; padding: 2px 6px; border-radius: 3px; font-size: 11px;">🔒 Auth</span>' : '';
 
                             html += '<tr style="border-bottom: 1px solid #eee;">';
                             html += '<td style="padding: 12px;">' + dateStr + '</td>';
-                            html += '<td style="padding: 12px;">' + downloader + authBadge + '</td>';
-                            html += '<td style="padding: 12px; font-family: monospace; font-size: 12px;">' + ip + '</td>';
+                            html += '<td style="padding: 12px;">' + reminder.recipientEmail;
+                            if (reminder.status === 'failed') {
+                                html += ' <span style="color: #f44336; font-size: 12px; font-weight: 600;">❌ Failed</span>';
+                            }
+                            html += '</td>';
+                            html += '<td style="padding: 12px;">' + reminder.leadHours + ' hours</td>';
                             html += '</tr>';
                         });
 
                         html += '</tbody></table>';
                     }
 
-                    // Show email logs
-                    if (emailLogs.length > 0) {
-                        html += '<h3 style="margin-top: 0; margin-bottom: 15px; color: #333; font-size: 16px;">📧 Emails Sent (' + emailLogs.length + ')</h3>';
+                    // Show requests for a new link from the expired page
+                    if (reshareRequests.length > 0) {
+                        html += '<h3 style="margin-top: 30px; margin-bottom: 15px; color: #333; font-size: 16px;">🔁 New Link Requests (' + reshareRequests.length + ')</h3>';
                         html += '<table style="width: 100%; border-collapse: collapse;">';
                         html += '<thead><tr style="background: #f5f5f5; border-bottom: 2px solid #ddd;">';
                         html += '<th style="padding: 12px; text-align: left;">Date & Time</th>';
-                        html += '<th style="padding: 12px; text-align: left;">Recipient</th>';
+                        html += '<th style="padding: 12px; text-align: left;">Requested By</th>';
                         html += '<th style="padding: 12px; text-align: left;">Message</th>';
                         html += '</tr></thead><tbody>';
 
-                        emailLogs.forEach(log => {
-                            const date = new Date(log.sentAt * 1000);
+                        reshareRequests.forEach(request => {
+                            const date = new Date(request.requestedAt * 1000);
                             const dateStr = date.toLocaleString('sv-SE');
-                            const message = log.message || '<em style="color: #999;">No message</em>';
+                            const requester = request.name ? escapeHtml(request.name) + ' &lt;' + escapeHtml(request.email) + '&gt;' : escapeHtml(request.email);
+                            const reason = request.reason === 'download_limit_reached' ? 'Download limit reached' : 'Expired';
+                            const message = request.message ? escapeHtml(request.message) : '<em style="color: #999;">No message</em>';
 
                             html += '<tr style="border-bottom: 1px solid #eee;">';
-                            html += '<td style="padding: 12px;">' + dateStr + '</td>';
-                            html += '<td style="padding: 12px;">' + log.recipientEmail + '</td>';
-                            html += '<td style="padding: 12px; max-width: 300px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap;" title="' + (log.message || '') + '">' + message + '</td>';
+                            html += '<td style="padding: 12px;">' + dateStr + '<div style="color: #999; font-size: 12px;">' + reason + '</div></td>';
+                            html += '<td style="padding: 12px;">' + requester + '</td>';
+                            html += '<td style="padding: 12px; max-width: 300px; white-space: pre-wrap;">' + message + '</td>';
                             html += '</tr>';
                         });
 
@@ -1669,7 +2363,7 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 
                 const result = await response.json();
                 if (response.ok) {
-                    alert('Email sent successfully!');
+                    alert('Email queued for delivery!');
                     closeEmailModal();
                 } else {
                     alert('Error: ' + (result.error || 'Failed to send email'));
@@ -1683,7 +2377,7 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
         }
 
         // Edit File Modal Functions
-        function showEditModal(fileId, fileName, downloadsRemaining, expireAt, unlimitedDownloads, unlimitedTime, fileComment, requireAuth, filePassword) {
+        function showEditModal(fileId, fileName, downloadsRemaining, expireAt, unlimitedDownloads, unlimitedTime, fileComment, requireAuth, filePassword, expiryReminders, privateDownloadLog, requireTerms, splashTemplate, maxViewers, filenameTemplate, reshareRequests, expiredMessage, downloadRetry, downloadLimit, expireDate, burnAfterDownload, notifyOnDownload, slug) {
             // Store file info
             const fileIdInput = document.getElementById('editFileId');
             if (!fileIdInput) {
@@ -1696,6 +2390,7 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 
             // Set comment/note
             document.getElementById('editFileComment').value = fileComment || '';
+            document.getElementById('editSlug').value = slug || '';
 
             // Set unlimited checkboxes
             document.getElementById('editUnlimitedTime').checked = unlimitedTime;
@@ -1704,19 +2399,57 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             // Set require auth checkbox
             document.getElementById('editRequireAuth').checked = requireAuth;
 
+            // Set expiry reminders checkbox
+            document.getElementById('editExpiryReminders').checked = expiryReminders;
+
+            // Set download log privacy checkbox (always on when the administrator disabled detailed logging)
+            const privateDownloadLogInput = document.getElementById('editPrivateDownloadLog');
+            privateDownloadLogInput.checked = privateDownloadLog || privateDownloadLogInput.disabled;
+
+            // Set download terms checkbox
+            document.getElementById('editRequireTerms').checked = requireTerms;
+            document.getElementById('editSplashTemplate').value = splashTemplate || 'detailed';
+
+            // Set new link request checkbox
+            document.getElementById('editReshareRequests').checked = reshareRequests;
+
+            // Set download retry checkbox
+            document.getElementById('editDownloadRetry').checked = downloadRetry;
+
+            // Set burn after download checkbox
+            document.getElementById('editBurnAfterDownload').checked = burnAfterDownload;
+            document.getElementById('editNotifyOnDownload').checked = notifyOnDownload;
+
+            // Set expired page message
+            document.getElementById('editExpiredMessage').value = expiredMessage || '';
+
+            // Set download file name template
+            document.getElementById('editFilenameTemplate').value = filenameTemplate || '';
+
+            // Set viewer limit (only shown while viewer tracking is on)
+            const maxViewersInput = document.getElementById('editMaxViewers');
+            if (maxViewersInput) {
+                maxViewersInput.value = maxViewers || 0;
+            }
+
+            // Set download bandwidth limit
+            document.getElementById('editDownloadLimit').value = downloadLimit || 0;
+
             // Set password protection
             const hasPassword = filePassword && filePassword.length > 0;
             document.getElementById('editEnablePassword').checked = hasPassword;
             document.getElementById('editFilePassword').value = filePassword || '';
             toggleEditPasswordField();
 
-            // Calculate days until expiration
-            if (expireAt > 0 && !unlimitedTime) {
-                const now = Math.floor(Date.now() / 1000);
-                const daysRemaining = Math.max(0, Math.ceil((expireAt - now) / (24 * 60 * 60)));
-                document.getElementById('editExpirationDays').value = daysRemaining;
+            // Show the date the file expires on, or a week from now for files that never expire
+            const expireDateInput = document.getElementById('editExpireDate');
+            expireDateInput.min = localDateString(new Date());
+            if (expireDate) {
+                expireDateInput.value = expireDate;
             } else {
-                document.getElementById('editExpirationDays').value = 7;
+                const weekFromNow = new Date();
+                weekFromNow.setDate(weekFromNow.getDate() + 7);
+                expireDateInput.value = localDateString(weekFromNow);
             }
 
             // Set downloads limit
@@ -1733,6 +2466,8 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             // Load user's teams and current file teams
             loadUserTeamsForEdit();
             loadCurrentFileTeams(fileId);
+            loadFileSchedule(fileId);
+            loadFileAccess(fileId);
 
             // Show modal
             document.getElementById('editModal').style.display = 'flex';
@@ -1813,6 +2548,9 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             .then(response => response.json())
             .then(data => {
                 if (data.success) {
+                    if (data.pending_approval) {
+                        alert('The file was added to the team. Its public link waits for approval.');
+                    }
                     loadCurrentFileTeams(fileId);
                     select.value = '';
                     location.reload(); // Reload to update badges
@@ -1852,10 +2590,167 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             });
         }
 
+        function loadFileSchedule(fileId) {
+            document.getElementById('editScheduleEnabled').checked = false;
+            toggleEditSchedule();
+
+            fetch('/file/schedule?file_id=' + encodeURIComponent(fileId), {
+                credentials: 'same-origin'
+            })
+            .then(response => response.json())
+            .then(schedule => {
+                if (schedule.error) return;
+                const days = schedule.days ? schedule.days.split(',') : [];
+                document.getElementById('editScheduleEnabled').checked = schedule.enabled;
+                document.getElementById('editAvailableFrom').value = schedule.available_from || '';
+                document.getElementById('editAvailableUntil').value = schedule.available_until || '';
+                document.getElementById('editDailyStart').value = schedule.daily_start || '';
+                document.getElementById('editDailyEnd').value = schedule.daily_end || '';
+                document.getElementById('editScheduleTimezone').value = schedule.timezone || '';
+                document.getElementById('editScheduleTimezone').placeholder = 'Server default (' + schedule.default_timezone + ')';
+                document.querySelectorAll('#editScheduleDays input').forEach(cb => {
+                    cb.checked = days.includes(cb.value);
+                });
+                toggleEditSchedule();
+            })
+            .catch(error => console.error('Error loading schedule:', error));
+        }
+
+        function loadFileAccess(fileId) {
+            document.getElementById('editAccessRequests').checked = false;
+            document.getElementById('editAccessEmail').value = '';
+            toggleEditAccess();
+
+            fetch('/file/access?file_id=' + encodeURIComponent(fileId), {
+                credentials: 'same-origin'
+            })
+            .then(response => response.json())
+            .then(access => {
+                if (access.error) return;
+                document.getElementById('editAccessRequests').checked = access.enabled;
+                toggleEditAccess();
+                renderFileAccess(fileId, access);
+            })
+            .catch(error => console.error('Error loading access list:', error));
+        }
+
+        function renderFileAccess(fileId, access) {
+            const requestList = document.getElementById('editAccessRequestList');
+            const grantList = document.getElementById('editAccessGrantList');
+            requestList.innerHTML = '';
+            grantList.innerHTML = '';
+
+            const pending = access.requests.filter(req => req.status === 'pending');
+            if (pending.length > 0) {
+                requestList.innerHTML = '<div style="margin-bottom: 6px; font-size: 13px; color: #666; font-weight: 500;">Pending requests:</div>';
+                pending.forEach(req => {
+                    const row = document.createElement('div');
+                    row.style.cssText = 'display: flex; align-items: center; justify-content: space-between; gap: 8px; padding: 8px; background: #fff3cd; border: 1px solid #ffc107; border-radius: 4px; margin-bottom: 6px; font-size: 13px;';
+                    const label = document.createElement('span');
+                    label.textContent = (req.name ? req.name + ' <' + req.email + '>' : req.email) + (req.message ? ' – ' + req.message : '');
+                    label.style.wordBreak = 'break-word';
+                    const buttons = document.createElement('span');
+                    buttons.style.cssText = 'display: flex; gap: 4px; flex-shrink: 0;';
+                    [['approve', '✓', '#4caf50'], ['reject', '✗', '#f44336']].forEach(([decision, text, color]) => {
+                        const btn = document.createElement('button');
+                        btn.type = 'button';
+                        btn.textContent = text;
+                        btn.style.cssText = 'padding: 4px 10px; background: ' + color + '; color: white; border: none; border-radius: 4px; cursor: pointer;';
+                        btn.onclick = () => decideAccessRequest(fileId, req.id, decision);
+                        buttons.appendChild(btn);
+                    });
+                    row.appendChild(label);
+                    row.appendChild(buttons);
+                    requestList.appendChild(row);
+                });
+            }
+
+            if (access.grants.length === 0) {
+                grantList.innerHTML = '<div style="color: #999; font-style: italic; font-size: 13px;">Nobody has been given access yet</div>';
+                return;
+            }
+            grantList.innerHTML = '<div style="margin-bottom: 6px; font-size: 13px; color: #666; font-weight: 500;">Has access:</div>';
+            access.grants.forEach(grant => {
+                const row = document.createElement('div');
+                row.style.cssText = 'display: flex; align-items: center; justify-content: space-between; padding: 6px 8px; background: #e8f5e9; border: 1px solid #a5d6a7; border-radius: 4px; margin-bottom: 6px; font-size: 13px;';
+                const label = document.createElement('span');
+                label.textContent = grant.email;
+                const btn = document.createElement('button');
+                btn.type = 'button';
+                btn.textContent = '✕ Revoke';
+                btn.style.cssText = 'padding: 4px 10px; background: #dc3545; color: white; border: none; border-radius: 4px; font-size: 12px; cursor: pointer;';
+                btn.onclick = () => updateFileAccess(fileId, 'revoke', grant.email);
+                row.appendChild(label);
+                row.appendChild(btn);
+                grantList.appendChild(row);
+            });
+        }
+
+        function toggleEditAccess() {
+            const requireAuth = document.getElementById('editRequireAuth').checked;
+            const restricted = document.getElementById('editAccessRequests').checked;
+            document.getElementById('editAccessSection').style.display = requireAuth ? 'block' : 'none';
+            document.getElementById('editAccessDetails').style.display = requireAuth && restricted ? 'block' : 'none';
+        }
+
+        function grantFileAccess() {
+            const fileId = document.getElementById('editFileId').value;
+            const email = document.getElementById('editAccessEmail').value.trim();
+            if (!email) return;
+            updateFileAccess(fileId, 'grant', email);
+        }
+
+        function updateFileAccess(fileId, action, email) {
+            fetch('/file/access/update', {
+                method: 'POST',
+                headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+                body: 'file_id=' + encodeURIComponent(fileId) + '&action=' + action + '&email=' + encodeURIComponent(email),
+                credentials: 'same-origin'
+            })
+            .then(response => response.json())
+            .then(result => {
+                if (result.error) {
+                    alert('Error: ' + result.error);
+                    return;
+                }
+                document.getElementById('editAccessEmail').value = '';
+                loadFileAccess(fileId);
+            })
+            .catch(error => alert('Error updating access: ' + error));
+        }
+
+        function decideAccessRequest(fileId, requestId, decision) {
+            const createAccount = decision === 'approve' && confirm('Also create a download account for this person if they do not have one?');
+            fetch('/file/access-requests/decide', {
+                method: 'POST',
+                headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+                body: 'request_id=' + requestId + '&decision=' + decision + '&create_account=' + (createAccount ? 'true' : 'false'),
+                credentials: 'same-origin'
+            })
+            .then(response => response.json())
+            .then(result => {
+                if (result.error) {
+                    alert('Error: ' + result.error);
+                    return;
+                }
+                loadFileAccess(fileId);
+            })
+            .catch(error => alert('Error deciding request: ' + error));
+        }
+
+        function toggleEditSchedule() {
+            const checkbox = document.getElementById('editScheduleEnabled');
+            document.getElementById('editScheduleSection').style.display = checkbox.checked ? 'block' : 'none';
+        }
+
         function closeEditModal() {
             document.getElementById('editModal').style.display = 'none';
         }
 
+        function localDateString(date) {
+            return date.getFullYear() + '-' + String(date.getMonth() + 1).padStart(2, '0') + '-' + String(date.getDate()).padStart(2, '0');
+        }
+
         function toggleEditTimeLimit() {
             const checkbox = document.getElementById('editUnlimitedTime');
             const section = document.getElementById('editTimeLimitSection');
@@ -1904,9 +2799,10 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                 return;
             }
 
-            let expirationDays = 0;
-            if (!unlimitedTime) {
-                expirationDays = parseInt(document.getElementById('editExpirationDays').value) || 0;
+            const expireDate = unlimitedTime ? '' : document.getElementById('editExpireDate').value;
+            if (!unlimitedTime && !expireDate) {
+                alert('Please choose an expiration date or check "Never expire".');
+                return;
             }
 
             let downloadsLimit = 0;
@@ -1916,10 +2812,32 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
 
             const formData = new FormData();
             formData.append('file_id', fileId);
-            formData.append('expiration_days', expirationDays);
+            if (expireDate) {
+                formData.append('expire_date', expireDate);
+            } else {
+                formData.append('expiration_days', 0);
+            }
             formData.append('downloads_limit', downloadsLimit);
             formData.append('file_comment', fileComment);
             formData.append('require_auth', requireAuth ? 'true' : 'false');
+            formData.append('access_requests_enabled', document.getElementById('editAccessRequests').checked ? 'true' : 'false');
+            formData.append('expiry_reminders', document.getElementById('editExpiryReminders').checked ? 'true' : 'false');
+            if (!document.getElementById('editPrivateDownloadLog').disabled) {
+                formData.append('private_download_log', document.getElementById('editPrivateDownloadLog').checked ? 'true' : 'false');
+            }
+            formData.append('require_terms', document.getElementById('editRequireTerms').checked ? 'true' : 'false');
+            formData.append('splash_template', document.getElementById('editSplashTemplate').value);
+            formData.append('reshare_requests', document.getElementById('editReshareRequests').checked ? 'true' : 'false');
+            formData.append('download_retry', document.getElementById('editDownloadRetry').checked ? 'true' : 'false');
+            formData.append('burn_after_download', document.getElementById('editBurnAfterDownload').checked ? 'true' : 'false');
+            formData.append('notify_on_download', document.getElementById('editNotifyOnDownload').checked ? 'true' : 'false');
+            formData.append('slug', document.getElementById('editSlug').value.trim());
+            formData.append('filename_template', document.getElementById('editFilenameTemplate').value.trim());
+            formData.append('expired_message', document.getElementById('editExpiredMessage').value.trim());
+            if (document.getElementById('editMaxViewers')) {
+                formData.append('max_viewers', document.getElementById('editMaxViewers').value || '0');
+            }
+            formData.append('download_limit_mbps', document.getElementById('editDownloadLimit').value || '0');
 
             // Only send password if checkbox is enabled
             if (enablePassword) {
@@ -1932,6 +2850,17 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                 formData.append('team_id', teamId);
             }
 
+            if (document.getElementById('editScheduleEnabled').checked) {
+                const days = Array.from(document.querySelectorAll('#editScheduleDays input:checked')).map(cb => cb.value);
+                formData.append('schedule_enabled', 'true');
+                formData.append('available_from', document.getElementById('editAvailableFrom').value);
+                formData.append('available_until', document.getElementById('editAvailableUntil').value);
+                formData.append('daily_start', document.getElementById('editDailyStart').value);
+                formData.append('daily_end', document.getElementById('editDailyEnd').value);
+                formData.append('schedule_days', days.join(','));
+                formData.append('schedule_timezone', document.getElementById('editScheduleTimezone').value);
+            }
+
             fetch('/file/edit', {
                 method: 'POST',
                 body: formData,
@@ -2035,6 +2964,18 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
             updatePagination();
         }
 
+        // Category filtering happens server-side
+        function applyCategoryFilter() {
+            const params = new URLSearchParams(window.location.search);
+            const category = document.getElementById('fileCategoryFilter').value;
+            if (category) {
+                params.set('category', category);
+            } else {
+                params.delete('category');
+            }
+            window.location.search = params.toString();
+        }
+
         // Search and sort files function
         function searchAndSortFiles() {
             const searchTerm = document.getElementById('fileSearch').value.toLowerCase();
@@ -2140,8 +3081,35 @@ func (s *Server) renderUserDashboard(w http.ResponseWriter, userModel interface{
                 item.setAttribute('data-search-hidden', 'false');
             });
             updatePagination();
+            openLinkedFile();
         });
 
+        // Notification emails link to /dashboard?file=ID to show that file's history
+        function openLinkedFile() {
+            const params = new URLSearchParams(window.location.search);
+            const fileId = params.get('file');
+            if (!fileId) {
+                return;
+            }
+
+            // Only files the user may see are on the dashboard, so links to other files do nothing
+            const item = Array.from(document.querySelectorAll('.file-item')).find(el => el.getAttribute('data-file-id') === fileId);
+            if (item) {
+                const index = getVisibleItems().indexOf(item);
+                if (index >= 0) {
+                    currentPage = Math.floor(index / perPage) + 1;
+                    updatePagination();
+                }
+                item.scrollIntoView({ behavior: 'smooth', block: 'center' });
+                showDownloadHistory(fileId, item.getAttribute('data-filename'));
+            }
+
+            // Don't reopen the history when the page is reloaded
+            params.delete('file');
+            const query = params.toString();
+            window.history.replaceState(null, '', window.location.pathname + (query ? '?' + query : '') + window.location.hash);
+        }
+
         function changePerPage() {
             perPage = parseInt(document.getElementById('perPageSelect').value);
             currentPage = 1; // Reset to first page
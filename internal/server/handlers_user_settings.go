@@ -56,8 +56,27 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
 			</button>`
 	}
 
+	// Trusted devices only matter while 2FA is on
+	trustedDevicesHTML := ""
+	if user.TOTPEnabled {
+		trustedDevicesHTML = `
+
+            <div class="setting-item" style="display: block;">
+                <div style="display: flex; justify-content: space-between; align-items: center;">
+                    <div class="setting-info">
+                        <h3>Trusted Devices</h3>
+                        <p>Devices where you chose "Remember this device" skip the 2FA code until they expire</p>
+                    </div>
+                    <button onclick="revokeTrustedDevices(0)" style="background: #f44336; color: white; padding: 10px 20px; border: none; border-radius: 6px; cursor: pointer; font-size: 14px; font-weight: 600;">
+                        Revoke All
+                    </button>
+                </div>
+                <div id="trustedDeviceList" style="margin-top: 15px; font-size: 14px; color: #666;">Loading...</div>
+            </div>`
+	}
+
 	html := `<!DOCTYPE html>
-<html lang="en">
+<html ` + htmlLangAttributes() + `>
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -314,6 +333,11 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
                     <h3>Email</h3>
                     <p>` + user.Email + `</p>
                 </div>
+                <div>
+                    <button onclick="changeEmail()" style="background: ` + s.getPrimaryColor() + `; color: white; padding: 10px 20px; border: none; border-radius: 6px; cursor: pointer; font-size: 14px; font-weight: 600;">
+                        Change Email
+                    </button>
+                </div>
             </div>
 
             <div class="setting-item">
@@ -347,6 +371,49 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
                 <div>
                     ` + totpActionButton + `
                 </div>
+            </div>` + trustedDevicesHTML + `
+
+            <div class="setting-item">
+                <div class="setting-info">
+                    <h3>API Tokens</h3>
+                    <p>Let scripts and CI pipelines upload and manage files as you</p>
+                </div>
+                <div>
+                    <a href="/settings/api-tokens" style="background: ` + s.getPrimaryColor() + `; color: white; padding: 10px 20px; border: none; border-radius: 6px; cursor: pointer; font-size: 14px; font-weight: 600; text-decoration: none; display: inline-block;">
+                        Manage API Tokens
+                    </a>
+                </div>
+            </div>
+
+            <div class="setting-item" style="display: block;">
+                <div style="display: flex; justify-content: space-between; align-items: center;">
+                    <div class="setting-info">
+                        <h3>Active Sessions</h3>
+                        <p>Where you are logged in. End a session you don't recognize, or sign out on every device, e.g. after using a shared computer</p>
+                    </div>
+                    <form method="POST" action="/settings/sign-out-everywhere" onsubmit="return confirm('Sign out on all devices, including this one?');">
+                        <button type="submit" style="background: #f44336; color: white; padding: 10px 20px; border: none; border-radius: 6px; cursor: pointer; font-size: 14px; font-weight: 600;">
+                            Sign Out Everywhere
+                        </button>
+                    </form>
+                </div>
+                <div id="sessionList" style="margin-top: 15px; font-size: 14px; color: #666;">Loading...</div>
+            </div>
+        </div>
+
+        <div class="card">
+            <h2>Notifications</h2>
+
+            <div class="setting-item">
+                <div class="setting-info">
+                    <h3>Notification Preferences</h3>
+                    <p>Choose which notifications you get by email and in the app</p>
+                </div>
+                <div>
+                    <a href="/notifications#preferences" style="background: ` + s.getPrimaryColor() + `; color: white; padding: 10px 20px; border: none; border-radius: 6px; cursor: pointer; font-size: 14px; font-weight: 600; text-decoration: none; display: inline-block;">
+                        Manage Notifications
+                    </a>
+                </div>
             </div>
         </div>
 
@@ -392,6 +459,7 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
             <div class="form-group">
                 <label for="new-password">New Password</label>
                 <input type="password" id="new-password" required autocomplete="new-password">
+                <p style="font-size: 12px; color: #999; margin-top: 4px;">` + auth.PasswordRequirements() + `</p>
             </div>
             <div class="form-group">
                 <label for="confirm-password">Confirm New Password</label>
@@ -402,13 +470,37 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
         </div>
     </div>
 
+    <!-- Change Email Modal -->
+    <div id="changeEmailModal" class="modal">
+        <div class="modal-content">
+            <span class="close-btn" onclick="closeModal('changeEmailModal')">&times;</span>
+            <h3>Change Email</h3>
+            <p style="color: #666; font-size: 14px; margin-bottom: 15px;">A verification link will be sent to the new address. Your email is only changed after you click it.</p>
+            <div id="changeEmailMessage"></div>
+            <div class="form-group">
+                <label for="new-email">New Email</label>
+                <input type="email" id="new-email" required autocomplete="email">
+            </div>
+            <div class="form-group">
+                <label for="email-current-password">Current Password</label>
+                <input type="password" id="email-current-password" required autocomplete="current-password">
+            </div>
+            <button onclick="confirmChangeEmail()" class="btn btn-primary">Send Verification Link</button>
+            <button onclick="closeModal('changeEmailModal')" class="btn btn-secondary" style="margin-left: 10px;">Cancel</button>
+        </div>
+    </div>
+
     <!-- Enable 2FA Modal -->
     <div id="enable2FAModal" class="modal">
         <div class="modal-content">
             <span class="close-btn" onclick="closeModal('enable2FAModal')">&times;</span>
             <h3>Enable Two-Factor Authentication</h3>
             <div id="enable2FAContent">
-                <p>Click "Generate QR Code" to start setting up 2FA</p>
+                <p>Enter your password and click "Generate QR Code" to start setting up 2FA</p>
+                <div class="form-group">
+                    <label for="enable-2fa-password">Password</label>
+                    <input type="password" id="enable-2fa-password" required>
+                </div>
                 <button onclick="generateQRCode()" class="btn btn-primary">Generate QR Code</button>
             </div>
         </div>
@@ -460,8 +552,8 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
                 return;
             }
 
-            if (newPassword.length < 8) {
-                messageDiv.innerHTML = '<div class="alert alert-error">New password must be at least 8 characters</div>';
+            if (newPassword.length < ` + strconv.Itoa(auth.PasswordMinLength()) + `) {
+                messageDiv.innerHTML = '<div class="alert alert-error">Password must be at least ` + strconv.Itoa(auth.PasswordMinLength()) + ` characters</div>';
                 return;
             }
 
@@ -498,6 +590,43 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
             }
         }
 
+        function changeEmail() {
+            document.getElementById('changeEmailModal').style.display = 'flex';
+            document.getElementById('changeEmailMessage').innerHTML = '';
+            document.getElementById('new-email').value = '';
+            document.getElementById('email-current-password').value = '';
+        }
+
+        async function confirmChangeEmail() {
+            const newEmail = document.getElementById('new-email').value.trim();
+            const currentPassword = document.getElementById('email-current-password').value;
+            const messageDiv = document.getElementById('changeEmailMessage');
+
+            if (!newEmail || !currentPassword) {
+                messageDiv.innerHTML = '<div class="alert alert-error">All fields are required</div>';
+                return;
+            }
+
+            try {
+                const response = await fetch('/settings/change-email', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+                    body: 'new_email=' + encodeURIComponent(newEmail) +
+                          '&current_password=' + encodeURIComponent(currentPassword),
+                    credentials: 'same-origin'
+                });
+                const data = await response.json();
+
+                if (data.success) {
+                    messageDiv.innerHTML = '<div class="alert alert-success">' + data.message + '</div>';
+                } else {
+                    messageDiv.innerHTML = '<div class="alert alert-error">' + data.error + '</div>';
+                }
+            } catch (error) {
+                messageDiv.innerHTML = '<div class="alert alert-error">Error: ' + error.message + '</div>';
+            }
+        }
+
         function enable2FA() {
             document.getElementById('enable2FAModal').style.display = 'flex';
         }
@@ -511,9 +640,17 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
         }
 
         async function generateQRCode() {
+            const password = document.getElementById('enable-2fa-password').value;
+            if (!password) {
+                alert('Please enter your password');
+                return;
+            }
+
             try {
                 const response = await fetch('/2fa/setup', {
                     method: 'POST',
+                    headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+                    body: 'password=' + encodeURIComponent(password),
                     credentials: 'same-origin'
                 });
                 const data = await response.json();
@@ -529,7 +666,8 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
                         </div>
                         <div class="secret-text">
                             <strong>Manual Entry Key:</strong><br>
-                            ${data.secret}
+                            ${data.secret}<br>
+                            <a href="${data.otpauth_url}">Open in authenticator app</a>
                         </div>
                         <h4>Backup Codes (Save these!)</h4>
                         <div class="backup-codes">
@@ -542,7 +680,7 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
                         <button onclick="verify2FA()" class="btn btn-primary">Verify and Enable</button>
                     ` + "`" + `;
                 } else {
-                    alert('Failed to generate QR code');
+                    alert('Error: ' + (data.error || 'Failed to generate QR code'));
                 }
             } catch (error) {
                 alert('Error: ' + error.message);
@@ -637,6 +775,151 @@ func (s *Server) renderUserSettingsPage(w http.ResponseWriter, user *models.User
             }
         }
 
+        async function loadTrustedDevices() {
+            const list = document.getElementById('trustedDeviceList');
+            if (!list) return;
+
+            try {
+                const response = await fetch('/settings/trusted-devices', { credentials: 'same-origin' });
+                const data = await response.json();
+                if (!data.remember_enabled) {
+                    list.textContent = 'Remembering devices is turned off by the administrator.';
+                    return;
+                }
+                if (data.devices.length === 0) {
+                    list.textContent = 'No trusted devices.';
+                    return;
+                }
+
+                list.innerHTML = '';
+                data.devices.forEach(device => {
+                    const row = document.createElement('div');
+                    row.style.cssText = 'display: flex; justify-content: space-between; align-items: center; padding: 10px 0; border-top: 1px solid #eee;';
+
+                    const info = document.createElement('div');
+                    const name = document.createElement('strong');
+                    name.textContent = device.name + (device.id === data.current_id ? ' (this device)' : '');
+                    const meta = document.createElement('div');
+                    meta.style.fontSize = '12px';
+                    meta.textContent = 'Last used ' + new Date(device.lastUsedAt * 1000).toLocaleString('sv-SE') +
+                        ' from ' + (device.ipAddress || 'unknown IP') +
+                        ' • Expires ' + new Date(device.expiresAt * 1000).toLocaleDateString('sv-SE');
+                    info.appendChild(name);
+                    info.appendChild(meta);
+
+                    const button = document.createElement('button');
+                    button.textContent = 'Revoke';
+                    button.className = 'btn btn-secondary';
+                    button.onclick = () => revokeTrustedDevices(device.id);
+
+                    row.appendChild(info);
+                    row.appendChild(button);
+                    list.appendChild(row);
+                });
+            } catch (error) {
+                list.textContent = 'Failed to load trusted devices';
+            }
+        }
+
+        // deviceId 0 revokes every trusted device
+        async function revokeTrustedDevices(deviceId) {
+            const message = deviceId ? 'Revoke this device? It will need a 2FA code at the next login.' : 'Revoke all trusted devices? Every device will need a 2FA code at the next login.';
+            if (!confirm(message)) {
+                return;
+            }
+
+            try {
+                const response = await fetch('/settings/trusted-devices', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+                    body: deviceId ? 'device_id=' + deviceId : 'all=true',
+                    credentials: 'same-origin'
+                });
+                const data = await response.json();
+                if (!response.ok) {
+                    alert('Error: ' + data.error);
+                    return;
+                }
+                loadTrustedDevices();
+            } catch (error) {
+                alert('Error: ' + error.message);
+            }
+        }
+
+        loadTrustedDevices();
+
+        async function loadSessions() {
+            const list = document.getElementById('sessionList');
+
+            try {
+                const response = await fetch('/settings/sessions', { credentials: 'same-origin' });
+                const data = await response.json();
+                if (!response.ok) {
+                    list.textContent = 'Failed to load sessions';
+                    return;
+                }
+
+                list.innerHTML = '';
+                data.sessions.forEach(session => {
+                    const row = document.createElement('div');
+                    row.style.cssText = 'display: flex; justify-content: space-between; align-items: center; padding: 10px 0; border-top: 1px solid #eee;';
+
+                    const info = document.createElement('div');
+                    const name = document.createElement('strong');
+                    name.textContent = session.device + (session.current ? ' (this session)' : '');
+                    const meta = document.createElement('div');
+                    meta.style.fontSize = '12px';
+                    meta.title = session.userAgent;
+                    const lastSeen = session.lastSeen || session.createdAt;
+                    meta.textContent = 'Signed in ' + (session.createdAt ? new Date(session.createdAt * 1000).toLocaleString('sv-SE') : 'earlier') +
+                        ' • Last seen ' + (lastSeen ? new Date(lastSeen * 1000).toLocaleString('sv-SE') : 'unknown') +
+                        ' from ' + (session.ipAddress || 'unknown IP') +
+                        (session.rememberMe ? ' • Kept logged in' : '') +
+                        ' • Expires ' + new Date(session.validUntil * 1000).toLocaleString('sv-SE');
+                    info.appendChild(name);
+                    info.appendChild(meta);
+                    row.appendChild(info);
+
+                    // The current session ends by logging out, so it can't be revoked by mistake
+                    if (!session.current) {
+                        const button = document.createElement('button');
+                        button.textContent = 'Revoke';
+                        button.className = 'btn btn-secondary';
+                        button.onclick = () => revokeSession(session.id);
+                        row.appendChild(button);
+                    }
+                    list.appendChild(row);
+                });
+            } catch (error) {
+                list.textContent = 'Failed to load sessions';
+            }
+        }
+
+        async function revokeSession(sessionId) {
+            if (!confirm('End this session? That device will have to log in again.')) {
+                return;
+            }
+
+            try {
+                const response = await fetch('/settings/sessions', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+                    body: 'session_id=' + encodeURIComponent(sessionId),
+                    credentials: 'same-origin'
+                });
+                const data = await response.json();
+                if (!response.ok) {
+                    alert('Error: ' + data.error);
+                    return;
+                }
+                loadSessions();
+            } catch (error) {
+                alert('Error: ' + error.message);
+            }
+        }
+
+        loadSessions();
+
         // Close modal when clicking outside
         window.onclick = function(event) {
             if (event.target.classList.contains('modal')) {
@@ -689,11 +972,11 @@ func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(newPassword) < 8 {
+	if err := auth.ValidatePasswordStrength(newPassword); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"error":   "New password must be at least 8 characters",
+			"error":   err.Error(),
 		})
 		return
 	}
@@ -739,6 +1022,8 @@ func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	database.DB.NotifyUser(user.Id, database.NotifySecurity, "Password changed", "Your password was changed from "+clientIP(r), "/settings")
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
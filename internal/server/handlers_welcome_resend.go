@@ -0,0 +1,360 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	emailpkg "github.com/Frimurare/WulfVault/internal/email"
+)
+
+// welcomeResendResult is the outcome of re-sending the welcome email to one user
+type welcomeResendResult struct {
+	UserId int    `json:"user_id"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleAdminPendingSetup lists users who never completed their password setup
+func (s *Server) handleAdminPendingSetup(w http.ResponseWriter, r *http.Request) {
+	pending, err := database.DB.GetUsersPendingPasswordSetup()
+	if err != nil {
+		log.Printf("Failed to get users pending password setup: %v", err)
+		http.Error(w, "Failed to load users", http.StatusInternalServerError)
+		return
+	}
+
+	s.renderAdminPendingSetup(w, pending)
+}
+
+// handleAdminResendWelcome re-sends welcome emails with fresh setup links to the selected users
+func (s *Server) handleAdminResendWelcome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	var userIds []int
+	for _, value := range strings.Split(r.FormValue("user_ids"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && id > 0 {
+			userIds = append(userIds, id)
+		}
+	}
+	if len(userIds) == 0 {
+		s.sendError(w, http.StatusBadRequest, "No users selected")
+		return
+	}
+
+	pending, err := database.DB.GetUsersPendingPasswordSetup()
+	if err != nil {
+		log.Printf("Failed to get users pending password setup: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load users")
+		return
+	}
+	pendingByID := make(map[int]*database.PendingPasswordSetup, len(pending))
+	for _, p := range pending {
+		pendingByID[p.UserId] = p
+	}
+
+	dailyCap := database.DB.GetWelcomeEmailDailyCap()
+	results := make([]welcomeResendResult, 0, len(userIds))
+	queued, skipped, failed := []string{}, []string{}, []string{}
+
+	for _, userId := range userIds {
+		p, ok := pendingByID[userId]
+		if !ok {
+			results = append(results, welcomeResendResult{UserId: userId, Status: "skipped", Reason: "User has already completed password setup"})
+			skipped = append(skipped, fmt.Sprintf("user #%d", userId))
+			continue
+		}
+
+		result := welcomeResendResult{UserId: userId, Email: p.Email}
+		if dailyCap > 0 && p.SentLast24h >= dailyCap {
+			result.Status = "skipped"
+			result.Reason = fmt.Sprintf("Daily limit of %d emails reached", dailyCap)
+			skipped = append(skipped, p.Email)
+			results = append(results, result)
+			continue
+		}
+
+		// Setup links expire quickly, so every re-send gets a fresh token
+		resetToken, err := database.DB.CreatePasswordResetToken(p.Email, database.AccountTypeUser)
+		if err != nil {
+			log.Printf("Failed to create reset token for %s: %v", p.Email, err)
+			result.Status = "failed"
+			result.Reason = "Could not create setup link"
+			failed = append(failed, p.Email)
+			results = append(results, result)
+			continue
+		}
+
+		if err := emailpkg.QueueWelcomeEmail(p.Email, resetToken, s.getPublicURL(), s.config.CompanyName, admin.Name, admin.Email); err != nil {
+			log.Printf("Failed to queue welcome email to %s: %v", p.Email, err)
+			// An email that was never queued does not count toward the recipient's daily cap
+			database.DB.DeletePasswordResetToken(resetToken)
+			result.Status = "failed"
+			result.Reason = err.Error()
+			failed = append(failed, p.Email)
+		} else {
+			log.Printf("Welcome email to %s queued by admin %s", p.Email, admin.Email)
+			result.Status = "queued"
+			queued = append(queued, p.Email)
+		}
+		results = append(results, result)
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionWelcomeEmailsResent,
+		EntityType: database.EntityUser,
+		EntityID:   "batch",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"requested": len(userIds),
+			"queued":    queued,
+			"skipped":   skipped,
+			"failed":    failed,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   len(failed) == 0,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"queued":  len(queued),
+		"skipped": len(skipped),
+		"failed":  len(failed),
+	})
+}
+
+// renderAdminPendingSetup renders the list of users who never set their password
+func (s *Server) renderAdminPendingSetup(w http.ResponseWriter, pending []*database.PendingPasswordSetup) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	dailyCap := database.DB.GetWelcomeEmailDailyCap()
+	capText := "There is no daily limit on re-sends."
+	if dailyCap > 0 {
+		capText = fmt.Sprintf("Each user can be sent at most %d setup emails per 24 hours.", dailyCap)
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Pending Password Setup - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .actions {
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            margin-bottom: 24px;
+            margin-top: 30px;
+        }
+        .btn {
+            padding: 10px 20px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            text-decoration: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        .btn:disabled {
+            opacity: 0.5;
+            cursor: not-allowed;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+        }
+        table {
+            width: 100%;
+            background: white;
+            border-radius: 12px;
+            overflow: hidden;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            border-collapse: collapse;
+        }
+        th {
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            padding: 12px 16px;
+            text-align: left;
+            font-size: 14px;
+        }
+        td {
+            padding: 12px 16px;
+            border-bottom: 1px solid #eee;
+            font-size: 14px;
+            color: #333;
+        }
+        .result-queued { color: #2e7d32; font-weight: 600; }
+        .result-skipped { color: #e65100; font-weight: 600; }
+        .result-failed { color: #c62828; font-weight: 600; }
+        .empty-state {
+            text-align: center;
+            padding: 60px 20px;
+            color: #999;
+            background: white;
+            border-radius: 12px;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <div class="actions">
+            <h2>📨 Pending Password Setup</h2>
+            <a href="/admin/users" class="btn">← Back to Users</a>
+        </div>
+
+        <div class="info-box">
+            These users were sent a welcome email but never chose a password, so they cannot log in. Re-sending creates a new setup link. ` + capText + `
+        </div>`
+
+	if len(pending) == 0 {
+		html += `
+        <div class="empty-state">
+            <p>🎉 Every user has completed their password setup</p>
+        </div>`
+	} else {
+		html += `
+        <div style="margin-bottom: 16px;">
+            <button class="btn" id="resendButton" onclick="resendSelected()">📨 Re-send Welcome Email to Selected</button>
+        </div>
+        <table>
+            <thead>
+                <tr>
+                    <th><input type="checkbox" id="selectAll" onchange="document.querySelectorAll('.user-select').forEach(cb => cb.checked = this.checked)" checked></th>
+                    <th>Name</th>
+                    <th>Email</th>
+                    <th>Created</th>
+                    <th>Last Email</th>
+                    <th>Sent (24h)</th>
+                    <th>Result</th>
+                </tr>
+            </thead>
+            <tbody>`
+
+		for _, p := range pending {
+			html += fmt.Sprintf(`
+                <tr>
+                    <td><input type="checkbox" class="user-select" value="%d" checked></td>
+                    <td>%s</td>
+                    <td>%s</td>
+                    <td>%s</td>
+                    <td>%s</td>
+                    <td>%d</td>
+                    <td id="result-%d">-</td>
+                </tr>`,
+				p.UserId,
+				template.HTMLEscapeString(p.Name),
+				template.HTMLEscapeString(p.Email),
+				time.Unix(p.CreatedAt, 0).Format("2006-01-02 15:04"),
+				time.Unix(p.LastSentAt, 0).Format("2006-01-02 15:04"),
+				p.SentLast24h,
+				p.UserId)
+		}
+
+		html += `
+            </tbody>
+        </table>`
+	}
+
+	html += `
+    </div>
+
+    <script>
+        async function resendSelected() {
+            const ids = Array.from(document.querySelectorAll('.user-select:checked')).map(cb => cb.value);
+            if (ids.length === 0) {
+                alert('Select at least one user');
+                return;
+            }
+            if (!confirm('Re-send the welcome email to ' + ids.length + ' user(s)?')) {
+                return;
+            }
+
+            const button = document.getElementById('resendButton');
+            button.disabled = true;
+            button.textContent = 'Sending...';
+
+            try {
+                const response = await fetch('/admin/users/resend-welcome', {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+                    body: 'user_ids=' + encodeURIComponent(ids.join(','))
+                });
+                const result = await response.json();
+                if (!response.ok) {
+                    alert('Failed: ' + (result.error || 'Unknown error'));
+                    return;
+                }
+
+                result.results.forEach(r => {
+                    const cell = document.getElementById('result-' + r.user_id);
+                    if (!cell) return;
+                    cell.className = 'result-' + r.status;
+                    cell.textContent = r.status.charAt(0).toUpperCase() + r.status.slice(1) + (r.reason ? ': ' + r.reason : '');
+                });
+                alert('Queued: ' + result.queued + ', skipped: ' + result.skipped + ', failed: ' + result.failed);
+            } catch (error) {
+                alert('Failed: ' + error.message);
+            } finally {
+                button.disabled = false;
+                button.textContent = '📨 Re-send Welcome Email to Selected';
+            }
+        }
+    </script>
+    <div style="text-align:center; font-size: 0.8em; margin-top: 2em; padding: 1em; color:#777;">
+        Powered by WulfVault © Ulf Holmström – AGPL-3.0
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
@@ -0,0 +1,216 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// DefaultZipDownloadMaxMB is the largest multi-file ZIP download when zip_download_max_mb is
+// not configured
+const DefaultZipDownloadMaxMB = 2048
+
+// MaxZipDownloadFiles is how many files one multi-file ZIP download may name
+const MaxZipDownloadFiles = 100
+
+// zipDownloadMaxBytes returns the largest total size of a multi-file ZIP download (0 = disabled)
+func zipDownloadMaxBytes() int64 {
+	maxMB := database.DB.GetConfigInt("zip_download_max_mb", DefaultZipDownloadMaxMB)
+	if maxMB <= 0 {
+		return 0
+	}
+	return int64(maxMB) * 1024 * 1024
+}
+
+// canAccessFileDirectly reports whether a logged-in user has access to a file without its
+// share link: they own it, are an admin, or belong to a team it is shared with
+func canAccessFileDirectly(user *models.User, fileInfo *database.FileInfo) bool {
+	if user == nil {
+		return false
+	}
+	if user.IsAdmin() || fileInfo.UserId == user.Id {
+		return true
+	}
+	teams, err := database.DB.GetTeamsForFile(fileInfo.Id)
+	if err != nil {
+		return false
+	}
+	for _, team := range teams {
+		if isMember, err := database.DB.IsTeamMember(team.Id, user.Id); err == nil && isMember {
+			return true
+		}
+	}
+	return false
+}
+
+// handleFilesZip downloads several files as one ZIP archive that is streamed as it is built
+// (/d/zip?ids=a,b,c). Anyone may include files that can be downloaded with just their link;
+// files with a password or download account login can only be included by their owner, an
+// admin or members of a team they are shared with. Each included file counts as one download.
+// Files that expired or can't be downloaded right now are listed in SKIPPED.txt.
+func (s *Server) handleFilesZip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxBytes := zipDownloadMaxBytes()
+	if maxBytes == 0 {
+		http.Error(w, "ZIP downloads are disabled", http.StatusNotFound)
+		return
+	}
+
+	var fileIDs []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			fileIDs = append(fileIDs, id)
+		}
+	}
+	if len(fileIDs) == 0 {
+		http.Error(w, "No files selected", http.StatusBadRequest)
+		return
+	}
+	if len(fileIDs) > MaxZipDownloadFiles {
+		http.Error(w, fmt.Sprintf("A ZIP download can include at most %d files", MaxZipDownloadFiles), http.StatusBadRequest)
+		return
+	}
+
+	// Logging in is optional; it only gives access to more files
+	user, err := s.getUserFromSession(r)
+	if err != nil {
+		user = nil
+	}
+
+	var included []*database.FileInfo
+	var skipped []string
+	var totalBytes int64
+	for _, id := range fileIDs {
+		fileInfo, err := database.DB.GetFileByID(id)
+		if err != nil {
+			http.Error(w, "File not found: "+id, http.StatusNotFound)
+			return
+		}
+
+		trusted := canAccessFileDirectly(user, fileInfo)
+		if !trusted && (fileInfo.FilePasswordPlain != "" || fileInfo.RequireAuth) {
+			http.Error(w, "You don't have access to all of the selected files", http.StatusForbidden)
+			return
+		}
+		if reason := s.zipSkipReason(fileInfo, trusted); reason != "" {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", fileInfo.Name, reason))
+			continue
+		}
+		included = append(included, fileInfo)
+		totalBytes += fileInfo.SizeBytes
+	}
+
+	if len(included) == 0 {
+		http.Error(w, "None of the selected files can be downloaded right now:\n"+strings.Join(skipped, "\n"), http.StatusGone)
+		return
+	}
+	if totalBytes > maxBytes {
+		http.Error(w, fmt.Sprintf("The selected files total %s, more than the %s allowed in one ZIP download. Download them one by one or in smaller groups instead.",
+			database.FormatFileSize(totalBytes), database.FormatFileSize(maxBytes)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Mark transfer as active to prevent inactivity timeout during download
+	if cookie, err := r.Cookie("session"); err == nil && user != nil {
+		s.markTransferActive(cookie.Value)
+		defer s.markTransferInactive(cookie.Value)
+	}
+
+	downloader := clientIP(r)
+	if user != nil {
+		downloader = user.Email
+	}
+
+	zipName := strings.Trim(unsafeZipNameChars.ReplaceAllString(s.config.CompanyName, "-"), "-")
+	if zipName == "" {
+		zipName = "WulfVault"
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-files-%s.zip\"", zipName, time.Now().Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+
+	log.Printf("ZIP download started: %d files (%s) by %s", len(included), database.FormatFileSize(totalBytes), downloader)
+
+	zw := zip.NewWriter(w)
+	usedNames := make(map[string]bool)
+	var bytesWritten int64
+	var downloadedIDs []string
+	aborted := false
+	for _, fileInfo := range included {
+		// Each file in the archive counts as a download of that file. The count re-checks
+		// expiry and remaining downloads, which may have changed since the list was made.
+		claimed, err := database.DB.ClaimFileDownload(fileInfo.Id)
+		if err != nil {
+			log.Printf("Warning: Could not update download count: %v", err)
+		}
+		if !claimed {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", fileInfo.Name, "no longer available"))
+			continue
+		}
+
+		n, err := s.addFileToZip(r, zw, fileInfo, uniqueZipName(s.downloadFileName(r, fileInfo, nil), usedNames))
+		bytesWritten += n
+		if err != nil {
+			// The response has started, so the client gets a truncated archive
+			log.Printf("ZIP download by %s aborted at %s: %v", downloader, fileInfo.Name, err)
+			aborted = true
+			break
+		}
+		downloadedIDs = append(downloadedIDs, fileInfo.Id)
+		s.recordZipFileDownload(r, fileInfo, user)
+	}
+
+	if !aborted && len(skipped) > 0 {
+		note, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     uniqueZipName("SKIPPED.txt", usedNames),
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err == nil {
+			io.WriteString(note, "These files could not be included in this ZIP.\n\n"+strings.Join(skipped, "\n")+"\n")
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("ZIP download by %s failed: %v", downloader, err)
+	}
+
+	log.Printf("ZIP download completed: %d of %d files (%s) by %s", len(downloadedIDs), len(included), database.FormatFileSize(bytesWritten), downloader)
+
+	entry := &database.AuditLogEntry{
+		UserEmail:  "anonymous",
+		Action:     database.ActionFilesZipDownloaded,
+		EntityType: database.EntityFile,
+		EntityID:   strings.Join(downloadedIDs, ","),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"files":   len(downloadedIDs),
+			"skipped": len(skipped),
+			"bytes":   bytesWritten,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   !aborted,
+	}
+	if user != nil {
+		entry.UserID = int64(user.Id)
+		entry.UserEmail = user.Email
+	}
+	database.DB.LogAction(entry)
+}
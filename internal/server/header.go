@@ -107,7 +107,7 @@ func (s *Server) getDownloadUserHeaderHTML() string {
         </nav>
     </div>`
 
-	return `<link rel="stylesheet" href="/static/css/style.css"><style>` + headerCSS + `</style>` + headerHTML
+	return `<link rel="stylesheet" href="/static/css/style.css">` + directionStylesheetHTML() + `<style>` + headerCSS + `</style>` + headerHTML
 }
 
 // getHeaderHTML generates consistent header HTML for all pages
@@ -386,7 +386,9 @@ func (s *Server) getHeaderHTML(user *models.User, forAdmin bool) string {
                 <a class="dropdown-toggle">Files</a>
                 <div class="dropdown-content">
                     <a href="/admin/files">All Files</a>
+                    <a href="/approvals">Pending Approvals</a>
                     <a href="/admin/duplicates">Duplicate Files</a>
+                    <a href="/admin/integrity">File Integrity</a>
                     <a href="/admin/trash">Trash</a>
                 </div>
             </div>
@@ -396,11 +398,19 @@ func (s *Server) getHeaderHTML(user *models.User, forAdmin bool) string {
                     <a href="/admin/settings">Server Settings</a>
                     <a href="/admin/branding">Branding</a>
                     <a href="/admin/email-settings">Email</a>
+                    <a href="/admin/email-templates">Email Templates</a>
+                    <a href="/admin/email-queue">Email Queue</a>
+                    <a href="/admin/sso">Single Sign-On</a>
+                    <a href="/admin/webhooks">Webhooks</a>
+                    <a href="/admin/download-terms">Download Terms</a>
+                    <a href="/admin/retention">Data Retention</a>
+                    <a href="/admin/maintenance">Maintenance Mode</a>
+                    <a href="/admin/backup">Backup & Restore</a>
                     <a href="/admin/audit-logs">Audit Logs</a>
                     <a href="/admin/server-logs">Server Logs</a>
                     <a href="/admin/sysmonitor-logs">SysMonitor Logs</a>
                 </div>
-            </div>
+            </div>` + notificationBellHTML(user) + `
             <a href="/settings">My Account</a>
             <a href="/logout" style="margin-left: auto;">Logout</a>
             <span>v` + s.config.Version + `</span>`
@@ -408,7 +418,12 @@ func (s *Server) getHeaderHTML(user *models.User, forAdmin bool) string {
 		// Regular user navigation
 		headerHTML += `
             <a href="/dashboard">Dashboard</a>
-            <a href="/teams">Teams</a>
+            <a href="/teams">Teams</a>`
+		if database.DB.IsDesignatedApprover(user.Email) {
+			headerHTML += `
+            <a href="/approvals">Approvals</a>`
+		}
+		headerHTML += notificationBellHTML(user) + `
             <a href="/settings">Settings</a>
             <a href="/logout" style="margin-left: auto;">Logout</a>
             <span>v` + s.config.Version + `</span>`
@@ -417,7 +432,18 @@ func (s *Server) getHeaderHTML(user *models.User, forAdmin bool) string {
 	headerHTML += `
         </nav>
     </div>
-    <div class="mobile-nav-overlay"></div>
+    <div class="mobile-nav-overlay"></div>`
+
+	headerHTML += impersonationBannerHTML(user)
+
+	if user.IsAdmin() && maintenanceEnabled.Load() {
+		headerHTML += `
+    <div style="background: #ff9800; color: white; text-align: center; padding: 8px 20px; font-size: 14px; font-weight: 600;">
+        🚧 Maintenance mode is on - only admins can use the server. <a href="/admin/maintenance" style="color: white; text-decoration: underline;">Manage</a>
+    </div>`
+	}
+
+	headerHTML += `
     <script>
         // Mobile navigation toggle
         document.addEventListener('DOMContentLoaded', function() {
@@ -460,6 +486,6 @@ func (s *Server) getHeaderHTML(user *models.User, forAdmin bool) string {
         });
     </script>`
 
-	return `<link rel="stylesheet" href="/static/css/style.css"><style>` + headerCSS + `</style>` + headerHTML
+	return `<link rel="stylesheet" href="/static/css/style.css">` + directionStylesheetHTML() + `<style>` + headerCSS + `</style>` + headerHTML
 }
 
@@ -0,0 +1,125 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/config"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// newTestServer gives the test a fresh database and local file storage in a temporary
+// directory, and a server using them
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	if err := database.Initialize(filepath.Join(dir, "data")); err != nil {
+		t.Fatalf("database.Initialize: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+	t.Cleanup(waitForBackgroundWork(runtime.NumGoroutine()))
+	if err := os.MkdirAll(filepath.Join(dir, "uploads"), 0755); err != nil {
+		t.Fatalf("creating uploads directory: %v", err)
+	}
+	storage.Files = storage.NewLocalStorage(filepath.Join(dir, "uploads"))
+
+	return New(&config.Config{
+		ServerURL:      "http://localhost:8080",
+		DataDir:        filepath.Join(dir, "data"),
+		UploadsDir:     filepath.Join(dir, "uploads"),
+		MaxFileSizeMB:  100,
+		DefaultQuotaMB: 1000,
+	})
+}
+
+// waitForBackgroundWork returns a cleanup that gives goroutines a test's handlers started, such
+// as owner notifications, time to finish before the next test replaces the database
+func waitForBackgroundWork(goroutines int) func() {
+	return func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+// createTestUser adds an active user with the given storage quota
+func createTestUser(t *testing.T, email string, level models.UserRank, quotaMB int64) *models.User {
+	t.Helper()
+	user := &models.User{
+		Name:           email,
+		Email:          email,
+		UserLevel:      level,
+		StorageQuotaMB: quotaMB,
+		IsActive:       true,
+	}
+	if err := database.DB.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+// createTestFile stores content as a public file of owner that can be downloaded any number of
+// times. setup may change the file before it is saved.
+func createTestFile(t *testing.T, owner *models.User, id string, content []byte, setup func(*database.FileInfo)) *database.FileInfo {
+	t.Helper()
+	if err := storage.Files.Put(id, bytes.NewReader(content)); err != nil {
+		t.Fatalf("storing %s: %v", id, err)
+	}
+	fileInfo := &database.FileInfo{
+		Id:                 id,
+		Name:               id + ".txt",
+		Size:               database.FormatFileSize(int64(len(content))),
+		SizeBytes:          int64(len(content)),
+		ContentType:        "text/plain",
+		UploadDate:         time.Now().Unix(),
+		UserId:             owner.Id,
+		UnlimitedDownloads: true,
+		UnlimitedTime:      true,
+	}
+	if setup != nil {
+		setup(fileInfo)
+	}
+	if err := database.DB.SaveFile(fileInfo); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	return fileInfo
+}
+
+// getFile reloads a file from the database
+func getFile(t *testing.T, id string) *database.FileInfo {
+	t.Helper()
+	fileInfo, err := database.DB.GetFileByID(id)
+	if err != nil {
+		t.Fatalf("GetFileByID(%s): %v", id, err)
+	}
+	return fileInfo
+}
+
+// serve runs a request through a handler and returns the response
+func serve(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// withCookies adds the cookies a previous response set to a request
+func withCookies(r *http.Request, from *httptest.ResponseRecorder) *http.Request {
+	for _, cookie := range from.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
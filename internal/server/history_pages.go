@@ -0,0 +1,69 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// historyDateLayout is the format of the from and to dates of the file history filter
+const historyDateLayout = "2006-01-02"
+
+// parseHistoryPages reads the page of download and email logs a file history request asks for.
+// limit defaults to the configured page size, download_offset and email_offset page through
+// each list, and from/to (YYYY-MM-DD, both inclusive) limit the dates shown.
+func parseHistoryPages(r *http.Request) (database.LogPage, database.LogPage, error) {
+	query := r.URL.Query()
+	page := database.LogPage{Limit: database.DB.GetHistoryPageSize()}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return page, page, errors.New("Invalid limit")
+		}
+		page.Limit = min(limit, database.MaxHistoryPageSize)
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, err := time.ParseInLocation(historyDateLayout, value, database.ServerLocation())
+		if err != nil {
+			return page, page, errors.New("Invalid from date, use YYYY-MM-DD")
+		}
+		page.From = from.Unix()
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := time.ParseInLocation(historyDateLayout, value, database.ServerLocation())
+		if err != nil {
+			return page, page, errors.New("Invalid to date, use YYYY-MM-DD")
+		}
+		page.To = to.AddDate(0, 0, 1).Unix()
+	}
+	if page.From > 0 && page.To > 0 && page.To <= page.From {
+		return page, page, errors.New("The from date must be before the to date")
+	}
+
+	downloadPage, emailPage := page, page
+	for _, offset := range []struct {
+		key  string
+		page *database.LogPage
+	}{{"download_offset", &downloadPage}, {"email_offset", &emailPage}} {
+		if value := query.Get(offset.key); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return page, page, fmt.Errorf("Invalid %s", offset.key)
+			}
+			offset.page.Offset = n
+		}
+	}
+
+	return downloadPage, emailPage, nil
+}
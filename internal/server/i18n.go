@@ -0,0 +1,106 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/i18n"
+)
+
+// recipientLanguageCookie remembers the language a recipient picked with the page's switcher
+const recipientLanguageCookie = "recipient_lang"
+
+// deploymentLocale returns the configured default language of this deployment
+func deploymentLocale() i18n.Locale {
+	code, _ := database.DB.GetConfigValue("default_language")
+	return i18n.Get(code)
+}
+
+// htmlLangAttributes returns the lang and dir attributes for a page's <html> tag
+func htmlLangAttributes() string {
+	return localeLangAttributes(deploymentLocale())
+}
+
+// localeLangAttributes returns the lang and dir attributes for a page rendered in the given locale
+func localeLangAttributes(locale i18n.Locale) string {
+	return `lang="` + locale.Code + `" dir="` + locale.Direction + `"`
+}
+
+// directionStylesheetHTML links the stylesheet that mirrors the layout for right-to-left languages
+func directionStylesheetHTML() string {
+	return localeDirectionStylesheetHTML(deploymentLocale())
+}
+
+// localeDirectionStylesheetHTML links the right-to-left stylesheet if the given locale needs it
+func localeDirectionStylesheetHTML(locale i18n.Locale) string {
+	if !locale.IsRTL() {
+		return ""
+	}
+	return `<link rel="stylesheet" href="/static/css/rtl.css">`
+}
+
+// isRecipientLanguageDetectionEnabled reports whether splash and download pages follow the
+// recipient's browser language instead of the deployment default
+func isRecipientLanguageDetectionEnabled() bool {
+	value, _ := database.DB.GetConfigValue("recipient_language_detection")
+	return value != "false"
+}
+
+// recipientLocale picks the language of the pages shown to a share's recipient: a language chosen
+// with the page's switcher (?lang=), then the browser's Accept-Language, then the deployment default.
+// The sender's language plays no part, since recipients are often outside the organisation.
+func recipientLocale(w http.ResponseWriter, r *http.Request) i18n.Locale {
+	w.Header().Add("Vary", "Accept-Language, Cookie")
+
+	if code := r.URL.Query().Get("lang"); code != "" {
+		if locale, ok := i18n.Lookup(code); ok && i18n.HasCatalog(locale.Code) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     recipientLanguageCookie,
+				Value:    locale.Code,
+				Path:     "/",
+				Expires:  time.Now().Add(365 * 24 * time.Hour),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			return locale
+		}
+	}
+
+	if cookie, err := r.Cookie(recipientLanguageCookie); err == nil {
+		if locale, ok := i18n.Lookup(cookie.Value); ok && i18n.HasCatalog(locale.Code) {
+			return locale
+		}
+	}
+
+	if isRecipientLanguageDetectionEnabled() {
+		if locale, ok := i18n.Match(r.Header.Get("Accept-Language")); ok {
+			return locale
+		}
+	}
+
+	return deploymentLocale()
+}
+
+// languageSwitcherHTML renders the language picker shown at the bottom of recipient pages
+func languageSwitcherHTML(locale i18n.Locale) string {
+	options := ""
+	for _, l := range i18n.Translated() {
+		selected := ""
+		if l.Code == locale.Code {
+			selected = " selected"
+		}
+		options += `<option value="` + l.Code + `"` + selected + `>` + template.HTMLEscapeString(l.Name) + `</option>`
+	}
+	return `
+        <div style="margin-top: 20px; font-size: 13px; color: #999;">
+            <label for="languageSwitcher">🌐 ` + i18n.T(locale.Code, "splash.language") + `</label>
+            <select id="languageSwitcher" onchange="var p = new URLSearchParams(location.search); p.set('lang', this.value); location.search = p.toString();" style="margin-left: 6px; padding: 4px 8px; border: 1px solid #ddd; border-radius: 6px; font-size: 13px; color: #555; background: white;">` + options + `</select>
+        </div>`
+}
@@ -0,0 +1,236 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// DefaultImageConversionMaxMB is the largest image converted when image_conversion_max_mb is not configured
+const DefaultImageConversionMaxMB = 50
+
+// imageConversionTimeout stops a decoder that hangs on a broken file
+const imageConversionTimeout = 2 * time.Minute
+
+// convertibleImageExtensions are the formats most recipients can't open without extra software
+var convertibleImageExtensions = map[string]string{
+	".heic": "heic",
+	".heif": "heif",
+	".avif": "avif",
+}
+
+// imageDecoder is an external program that can convert the formats above
+type imageDecoder struct {
+	binary string
+	args   func(src, format, dst string) []string
+}
+
+// imageDecoders are tried in order; the first one installed is used
+var imageDecoders = []imageDecoder{
+	{binary: "heif-convert", args: func(src, format, dst string) []string { return []string{"-q", "90", src, dst} }},
+	{binary: "magick", args: func(src, format, dst string) []string { return []string{format + ":" + src + "[0]", dst} }},
+	{binary: "convert", args: func(src, format, dst string) []string { return []string{format + ":" + src + "[0]", dst} }},
+}
+
+// isImageConversionEnabled reports whether HEIC/HEIF/AVIF uploads get a converted copy for preview (default off)
+func isImageConversionEnabled() bool {
+	value, _ := database.DB.GetConfigValue("image_conversion_enabled")
+	return value == "true"
+}
+
+// isConvertedDownloadEnabled reports whether recipients are offered the converted copy as a download (default off)
+func isConvertedDownloadEnabled() bool {
+	value, _ := database.DB.GetConfigValue("image_conversion_download")
+	return value == "true"
+}
+
+// getImageConversionFormat returns the configured output format, "jpeg" or "png"
+func getImageConversionFormat() string {
+	value, _ := database.DB.GetConfigValue("image_conversion_format")
+	if value == "png" {
+		return "png"
+	}
+	return "jpeg"
+}
+
+// getImageConversionMaxBytes returns the size of the largest image that is converted
+func getImageConversionMaxBytes() int64 {
+	maxMB := database.DB.GetConfigInt("image_conversion_max_mb", DefaultImageConversionMaxMB)
+	if maxMB < 1 {
+		maxMB = 1
+	}
+	return int64(maxMB) * 1024 * 1024
+}
+
+// convertedImageExtension returns the file extension used for a conversion format
+func convertedImageExtension(format string) string {
+	if format == "png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// findImageDecoder returns the first installed decoder
+func findImageDecoder() (imageDecoder, string, bool) {
+	for _, decoder := range imageDecoders {
+		if path, err := exec.LookPath(decoder.binary); err == nil {
+			return decoder, path, true
+		}
+	}
+	return imageDecoder{}, "", false
+}
+
+// isConvertibleImage reports whether a file name has one of the convertible image formats
+func isConvertibleImage(fileName string) bool {
+	_, ok := convertibleImageExtensions[strings.ToLower(filepath.Ext(fileName))]
+	return ok
+}
+
+// convertedImagesDir holds converted copies next to the uploads. The originals are never changed.
+func (s *Server) convertedImagesDir() string {
+	return filepath.Join(s.config.UploadsDir, ".converted")
+}
+
+// convertedImagePath returns the path of a file's converted copy, or "" if there is none yet
+func (s *Server) convertedImagePath(fileID string) string {
+	for _, ext := range []string{".jpg", ".png"} {
+		path := filepath.Join(s.convertedImagesDir(), fileID+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// removeConvertedImages deletes a file's converted copies
+func (s *Server) removeConvertedImages(fileID string) {
+	for _, ext := range []string{".jpg", ".png"} {
+		os.Remove(filepath.Join(s.convertedImagesDir(), fileID+ext))
+	}
+}
+
+// queueImageConversion converts a HEIC/HEIF/AVIF upload to JPEG or PNG in the background.
+// Files over the size cap are skipped, and without an installed decoder nothing happens.
+func (s *Server) queueImageConversion(fileID, fileName string, sizeBytes int64) {
+	if !isImageConversionEnabled() || !isConvertibleImage(fileName) {
+		return
+	}
+	if sizeBytes > getImageConversionMaxBytes() || s.convertedImagePath(fileID) != "" {
+		return
+	}
+	decoder, decoderPath, ok := findImageDecoder()
+	if !ok {
+		return
+	}
+
+	format := getImageConversionFormat()
+	fileProcessing.Submit("convert:"+fileID, func() error {
+		if err := os.MkdirAll(s.convertedImagesDir(), 0755); err != nil {
+			return err
+		}
+
+		// The decoder reads from disk, so the original is copied out of storage first
+		src := filepath.Join(s.convertedImagesDir(), "src-"+fileID)
+		defer os.Remove(src)
+		if err := copyStoredFile(fileID, src); err != nil {
+			return err
+		}
+
+		dst := filepath.Join(s.convertedImagesDir(), fileID+convertedImageExtension(format))
+		// The decoder picks the output format from the extension, so the temp file keeps it
+		tmp := filepath.Join(s.convertedImagesDir(), "tmp-"+fileID+convertedImageExtension(format))
+		defer os.Remove(tmp)
+
+		ctx, cancel := context.WithTimeout(context.Background(), imageConversionTimeout)
+		defer cancel()
+
+		inputFormat := convertibleImageExtensions[strings.ToLower(filepath.Ext(fileName))]
+		output, err := exec.CommandContext(ctx, decoderPath, decoder.args(src, inputFormat, tmp)...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", decoder.binary, err, strings.TrimSpace(string(output)))
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			return err
+		}
+
+		log.Printf("Converted %s (%s) to %s for preview", fileName, fileID, format)
+		return nil
+	})
+}
+
+// copyStoredFile writes a stored file to a path on disk
+func copyStoredFile(fileID, path string) error {
+	src, err := storage.Files.Get(fileID)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// getConvertedImage returns the converted copy of a file, queueing the conversion for
+// files uploaded before it was enabled. It returns "" while no copy is available.
+func (s *Server) getConvertedImage(fileInfo *database.FileInfo) string {
+	if !isImageConversionEnabled() || !isConvertibleImage(fileInfo.Name) {
+		return ""
+	}
+	path := s.convertedImagePath(fileInfo.Id)
+	if path == "" {
+		s.queueImageConversion(fileInfo.Id, fileInfo.Name, fileInfo.SizeBytes)
+	}
+	return path
+}
+
+// convertedDownloadPath returns the converted copy to serve instead of the original when
+// the recipient asked for it with ?format=converted, or "" to serve the original
+func (s *Server) convertedDownloadPath(r *http.Request, fileInfo *database.FileInfo) string {
+	if r.URL.Query().Get("format") != "converted" || !isConvertedDownloadEnabled() {
+		return ""
+	}
+	return s.getConvertedImage(fileInfo)
+}
+
+// convertedDownloadName replaces the original extension with the converted one
+func convertedDownloadName(fileName, convertedPath string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + filepath.Ext(convertedPath)
+}
+
+// convertedContentType returns the MIME type of a converted copy
+func convertedContentType(convertedPath string) string {
+	if filepath.Ext(convertedPath) == ".png" {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// imageConversionStatus describes the decoder availability for the admin settings page
+func imageConversionStatus() string {
+	if decoder, _, ok := findImageDecoder(); ok {
+		return "Decoder found: " + decoder.binary
+	}
+	return "No decoder found. Install libheif (heif-convert) or ImageMagick to enable conversion; until then files are served as uploaded"
+}
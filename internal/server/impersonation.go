@@ -0,0 +1,217 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/auth"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// handleAdminImpersonate lets a super admin view the app as another user for support
+// (POST /admin/users/impersonate). The admin's session is kept and restored on exit.
+func (s *Server) handleAdminImpersonate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if admin.ImpersonatedBy != nil {
+		http.Error(w, "Exit the current impersonation first", http.StatusForbidden)
+		return
+	}
+	if !admin.IsSuperAdmin() {
+		http.Error(w, "Only the super admin can view the app as another user", http.StatusForbidden)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	target, err := database.DB.GetUserByID(userID)
+	if err != nil || target.DeletedAt != 0 {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	switch {
+	case target.Id == admin.Id:
+		http.Error(w, "You can't impersonate yourself", http.StatusBadRequest)
+		return
+	case target.IsSuperAdmin():
+		http.Error(w, "Super admins can't be impersonated", http.StatusForbidden)
+		return
+	case !target.IsActive:
+		http.Error(w, "Inactive users can't be impersonated", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		http.Error(w, "Impersonation needs a login session", http.StatusForbidden)
+		return
+	}
+	sessionID, err := auth.CreateImpersonationSession(target.Id, admin.Id, cookie.Value)
+	if err != nil {
+		log.Printf("Failed to start impersonation of user %d by %s: %v", target.Id, admin.Email, err)
+		http.Error(w, "Failed to start impersonation", http.StatusInternalServerError)
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionImpersonationStarted,
+		EntityType: database.EntityUser,
+		EntityID:   strconv.Itoa(target.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"impersonated_user_id":    target.Id,
+			"impersonated_user_email": target.Email,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+	log.Printf("👁️  Impersonation started: %s (ID: %d) is viewing the app as %s (ID: %d)", admin.Email, admin.Id, target.Email, target.Id)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  time.Now().Add(auth.ImpersonationDuration),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// handleImpersonationExit ends an impersonation and returns the super admin to their own
+// session (POST /impersonation/exit)
+func (s *Server) handleImpersonationExit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := userFromContext(r.Context())
+	if !ok || user.ImpersonatedBy == nil {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	admin := user.ImpersonatedBy
+
+	cookie, _ := r.Cookie("session")
+	adminSessionID := auth.GetImpersonatorSession(cookie.Value)
+	auth.DeleteSession(cookie.Value)
+	s.logImpersonationEnded(r, user)
+
+	// The admin logs in again if their own session ended in the meantime
+	if _, err := auth.GetUserBySession(adminSessionID); err != nil {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "session",
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+		})
+		http.Redirect(w, r, "/login?redirect="+url.QueryEscape("/admin/users"), http.StatusSeeOther)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    adminSessionID,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	log.Printf("👁️  Impersonation ended: %s (ID: %d) stopped viewing the app as %s (ID: %d)", admin.Email, admin.Id, user.Email, user.Id)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// logImpersonationEnded records the end of an impersonation in the audit log
+func (s *Server) logImpersonationEnded(r *http.Request, user *models.User) {
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.ImpersonatedBy.Id),
+		UserEmail:  user.ImpersonatedBy.Email,
+		Action:     database.ActionImpersonationEnded,
+		EntityType: database.EntityUser,
+		EntityID:   strconv.Itoa(user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"impersonated_user_id":    user.Id,
+			"impersonated_user_email": user.Email,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+}
+
+// serveImpersonated runs next for a request a super admin makes while viewing the app as user.
+// Requests that change something are recorded in the audit log with both of them; chunks of
+// an upload are covered by the requests that start and complete it, and exiting is logged as
+// IMPERSONATION_ENDED.
+func serveImpersonated(w http.ResponseWriter, r *http.Request, user *models.User, next http.HandlerFunc) {
+	switch {
+	case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions,
+		r.URL.Path == "/api/upload/chunk", r.URL.Path == "/impersonation/exit":
+		next(w, r)
+		return
+	}
+
+	recorder := &responseWriter{ResponseWriter: w}
+	next(recorder, r)
+
+	status := recorder.statusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionImpersonatedRequest,
+		EntityType: database.EntityUser,
+		EntityID:   strconv.Itoa(user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"impersonator_id":    user.ImpersonatedBy.Id,
+			"impersonator_email": user.ImpersonatedBy.Email,
+			"method":             r.Method,
+			"path":               r.URL.Path,
+			"status":             status,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   status < 400,
+	})
+}
+
+// impersonationBannerHTML returns the bar shown while a super admin views the app as another
+// user, with the control to stop, or "" otherwise
+func impersonationBannerHTML(user *models.User) string {
+	if user.ImpersonatedBy == nil {
+		return ""
+	}
+	return `
+    <div style="background: #6a1b9a; color: white; text-align: center; padding: 8px 20px; font-size: 14px; font-weight: 600;">
+        👁️ You are viewing the app as ` + template.HTMLEscapeString(user.Name) + ` (` + template.HTMLEscapeString(user.Email) + `). Changes you make are recorded in the audit log with your name.
+        <form method="POST" action="/impersonation/exit" style="display: inline; margin-left: 12px;">
+            <button type="submit" style="background: white; color: #6a1b9a; border: none; padding: 4px 12px; border-radius: 4px; font-weight: 600; cursor: pointer;">Exit impersonation</button>
+        </form>
+    </div>`
+}
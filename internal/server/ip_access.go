@@ -0,0 +1,263 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// Settings holding the IP access lists, as addresses or CIDR ranges one per line
+const (
+	configAdminIPAllowlist    = "admin_ip_allowlist"
+	configAdminIPDenylist     = "admin_ip_denylist"
+	configDownloadIPAllowlist = "download_ip_allowlist"
+	configDownloadIPDenylist  = "download_ip_denylist"
+)
+
+// ipAccessDeniedMessage is all a refused client is told, so it learns nothing about the lists
+const ipAccessDeniedMessage = "Access denied"
+
+// downloadPathPrefixes are the public download routes the download lists apply to
+var downloadPathPrefixes = []string{"/s/", "/d/", "/preview/", "/splash/heartbeat", "/api/v1/download/"}
+
+// ipAccessList lets an address through unless it is denied, or an allowlist is set and it isn't
+// on it. Deny wins over allow.
+type ipAccessList struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// ipAccessRules are the admin and download access lists
+type ipAccessRules struct {
+	Admin    ipAccessList
+	Download ipAccessList
+}
+
+// trustedProxyNets are the reverse proxies whose forwarded client addresses are believed
+var trustedProxyNets atomic.Pointer[[]*net.IPNet]
+
+// currentIPAccessRules caches the access lists so the checks don't hit the database on every request
+var currentIPAccessRules atomic.Pointer[ipAccessRules]
+
+// parseIPNetList parses addresses and CIDR ranges separated by commas or new lines. A single
+// address is treated as a range of one.
+func parseIPNetList(value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' || r == ' ' }) {
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", entry)
+			}
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// formatIPNetList returns a list as it is stored, one range per line, with single addresses
+// written without a prefix length
+func formatIPNetList(nets []*net.IPNet) string {
+	entries := make([]string, len(nets))
+	for i, ipNet := range nets {
+		if ones, bits := ipNet.Mask.Size(); ones == bits {
+			entries[i] = ipNet.IP.String()
+		} else {
+			entries[i] = ipNet.String()
+		}
+	}
+	return strings.Join(entries, "\n")
+}
+
+// ipInNets reports whether an address is in any of the ranges
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Permits reports whether the list lets an address through. An address that can't be parsed
+// only gets through when there are no lists.
+func (l ipAccessList) Permits(address string) bool {
+	if len(l.Allow) == 0 && len(l.Deny) == 0 {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil || ipInNets(ip, l.Deny) {
+		return false
+	}
+	return len(l.Allow) == 0 || ipInNets(ip, l.Allow)
+}
+
+// loadIPAccessList reads one access list from the settings. Entries that don't parse were never
+// saved by the settings page, so a list that fails to parse is skipped with a warning.
+func loadIPAccessList(allowKey, denyKey string) ipAccessList {
+	var list ipAccessList
+	for key, target := range map[string]*[]*net.IPNet{allowKey: &list.Allow, denyKey: &list.Deny} {
+		value, _ := database.DB.GetConfigValue(key)
+		nets, err := parseIPNetList(value)
+		if err != nil {
+			log.Printf("Warning: Ignoring %s: %v", key, err)
+			continue
+		}
+		*target = nets
+	}
+	return list
+}
+
+// loadIPAccessRules (re)loads the access lists from the settings
+func loadIPAccessRules() {
+	rules := &ipAccessRules{
+		Admin:    loadIPAccessList(configAdminIPAllowlist, configAdminIPDenylist),
+		Download: loadIPAccessList(configDownloadIPAllowlist, configDownloadIPDenylist),
+	}
+	currentIPAccessRules.Store(rules)
+	if len(rules.Admin.Allow) > 0 || len(rules.Admin.Deny) > 0 {
+		log.Printf("🔒 Admin access is restricted by IP address")
+	}
+}
+
+// getIPAccessRules returns the cached access lists
+func getIPAccessRules() *ipAccessRules {
+	if rules := currentIPAccessRules.Load(); rules != nil {
+		return rules
+	}
+	return &ipAccessRules{}
+}
+
+// setTrustedProxies sets the reverse proxies whose forwarded client addresses are believed
+func setTrustedProxies(proxies []string) error {
+	nets, err := parseIPNetList(strings.Join(proxies, ","))
+	if err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	trustedProxyNets.Store(&nets)
+	return nil
+}
+
+// isTrustedProxy reports whether an address is one of the trusted reverse proxies
+func isTrustedProxy(address string) bool {
+	nets := trustedProxyNets.Load()
+	if nets == nil {
+		return false
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && ipInNets(ip, *nets)
+}
+
+// clientIP returns the address of the client that sent the request, without the port. This is
+// the one place client addresses come from, so logs, lockouts and access lists all agree.
+// X-Forwarded-For and X-Real-IP are only believed when the request comes from a trusted proxy,
+// since anyone can send them.
+func clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	// Each proxy appends the address it got the request from, so the client is the last
+	// address that isn't one of our own proxies
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ips := strings.Split(forwarded, ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip = strings.TrimSpace(ips[i])
+			if !isTrustedProxy(ip) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return ip
+}
+
+// isDownloadPath reports whether a path is a public download route
+func isDownloadPath(path string) bool {
+	for _, prefix := range downloadPathPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipAccessMiddleware refuses public downloads from addresses the download lists don't let through.
+// Admin routes are checked in requireAdmin, which covers admin API routes outside /admin too.
+func (s *Server) ipAccessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isDownloadPath(r.URL.Path) {
+			if ip := clientIP(r); !getIPAccessRules().Download.Permits(ip) {
+				log.Printf("⚠️  Download refused for IP %s | Path: %s", ip, r.URL.Path)
+				http.Error(w, ipAccessDeniedMessage, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkAdminIPAccess refuses admin requests from addresses the admin lists don't let through,
+// and records the attempt in the audit log. It returns false if the request was refused.
+func (s *Server) checkAdminIPAccess(w http.ResponseWriter, r *http.Request) bool {
+	ip := clientIP(r)
+	if getIPAccessRules().Admin.Permits(ip) {
+		return true
+	}
+
+	var userID int64
+	userEmail := ""
+	if user, err := s.getUserFromSession(r); err == nil {
+		userID = int64(user.Id)
+		userEmail = user.Email
+	}
+	log.Printf("⚠️  Admin access refused for IP %s | Path: %s | User: %s", ip, r.URL.Path, userEmail)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     userID,
+		UserEmail:  userEmail,
+		Action:     database.ActionAdminAccessDenied,
+		EntityType: database.EntitySystem,
+		EntityID:   r.URL.Path,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"path":   r.URL.Path,
+			"method": r.Method,
+		}),
+		IPAddress: ip,
+		UserAgent: r.UserAgent(),
+		Success:   false,
+		ErrorMsg:  "IP address not allowed",
+	})
+
+	http.Error(w, ipAccessDeniedMessage, http.StatusForbidden)
+	return false
+}
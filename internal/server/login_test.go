@@ -0,0 +1,71 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/auth"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// setTestPassword sets the password of a user created with createTestUser
+func setTestPassword(t *testing.T, user *models.User, password string) {
+	t.Helper()
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	user.Password = hash
+	if err := database.DB.UpdateUser(user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+}
+
+// login posts the login form
+func login(s *Server, email, password string) *httptest.ResponseRecorder {
+	form := url.Values{"email": {email}, "password": {password}}
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serve(s.handleLogin, r)
+}
+
+// In SSO-only mode the login of a user who must use single sign-on is refused the same way
+// whether or not the password was right, while admins keep the break-glass login
+func TestSSOOnlyLoginRefusedBeforePasswordCheck(t *testing.T) {
+	s := newTestServer(t)
+	user := createTestUser(t, "user@example.com", models.UserLevelUser, 1000)
+	setTestPassword(t, user, "user-password")
+	admin := createTestUser(t, "admin@example.com", models.UserLevelAdmin, 1000)
+	setTestPassword(t, admin, "admin-password")
+
+	database.DB.SetConfigValue("branding_login_sso_only", "true")
+	database.DB.SetConfigValue("branding_login_sso_url", "https://sso.example.com/login")
+
+	right := login(s, "user@example.com", "user-password")
+	wrong := login(s, "user@example.com", "wrong-password")
+	if right.Code != wrong.Code || right.Body.String() != wrong.Body.String() {
+		t.Errorf("right and wrong password answered differently: status %d and %d", right.Code, wrong.Code)
+	}
+	if !strings.Contains(wrong.Body.String(), "sign in with single sign-on") {
+		t.Errorf("user login was not refused for single sign-on")
+	}
+	if cookies := right.Result().Cookies(); len(cookies) > 0 {
+		t.Errorf("refused login set cookies %v", cookies)
+	}
+
+	if w := login(s, "admin@example.com", "admin-password"); w.Code != http.StatusSeeOther {
+		t.Errorf("admin break-glass login: status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	if w := login(s, "admin@example.com", "wrong-password"); !strings.Contains(w.Body.String(), "Invalid credentials") {
+		t.Errorf("admin login with a wrong password was not refused as invalid")
+	}
+}
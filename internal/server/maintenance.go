@@ -0,0 +1,384 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// DefaultMaintenanceRetryAfterMinutes is the Retry-After hint sent while in maintenance mode
+const DefaultMaintenanceRetryAfterMinutes = 15
+
+// maintenanceEnabled caches the persisted maintenance_mode flag so the middleware
+// doesn't hit the database on every request
+var maintenanceEnabled atomic.Bool
+
+// maintenanceExemptPaths stay reachable in maintenance mode so admins can still sign in
+var maintenanceExemptPaths = []string{"/login", "/login/local", "/logout", "/2fa/verify", "/health", "/static/"}
+
+// loadMaintenanceMode restores the maintenance flag saved before the last restart
+func loadMaintenanceMode() {
+	value, _ := database.DB.GetConfigValue("maintenance_mode")
+	maintenanceEnabled.Store(value == "true")
+	if value == "true" {
+		log.Printf("🚧 Maintenance mode is enabled - only admins can use the server")
+	}
+}
+
+// isMaintenanceExemptPath reports whether a path is served even in maintenance mode
+func isMaintenanceExemptPath(path string) bool {
+	for _, exempt := range maintenanceExemptPaths {
+		if path == exempt || (strings.HasSuffix(exempt, "/") && strings.HasPrefix(path, exempt)) {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceMiddleware answers every non-admin request with 503 while maintenance mode is on.
+// Requests that were already running when it was enabled (such as large downloads) are not affected.
+func (s *Server) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !maintenanceEnabled.Load() || isMaintenanceExemptPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if user, err := s.getUserFromSession(r); err == nil && user.IsAdmin() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.renderMaintenancePage(w, r)
+	})
+}
+
+// getMaintenanceSettings returns the message and Retry-After (in minutes) shown to users
+func getMaintenanceSettings() (string, int) {
+	message, _ := database.DB.GetConfigValue("maintenance_message")
+	if message == "" {
+		message = "We're performing scheduled maintenance. Please check back shortly."
+	}
+	return message, database.DB.GetConfigInt("maintenance_retry_after_minutes", DefaultMaintenanceRetryAfterMinutes)
+}
+
+// renderMaintenancePage sends the branded "under maintenance" response
+func (s *Server) renderMaintenancePage(w http.ResponseWriter, r *http.Request) {
+	message, retryMinutes := getMaintenanceSettings()
+	w.Header().Set("Retry-After", strconv.Itoa(retryMinutes*60))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if strings.HasPrefix(r.URL.Path, "/api/") || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		s.sendJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"error":       message,
+			"maintenance": true,
+		})
+		return
+	}
+
+	brandingConfig, _ := database.DB.GetBrandingConfig()
+	logoHTML := `<h1 style="color: ` + s.getPrimaryColor() + `; font-size: 28px; margin-bottom: 20px;">` + template.HTMLEscapeString(s.config.CompanyName) + `</h1>`
+	if logoData := brandingConfig["branding_logo"]; logoData != "" {
+		logoHTML = `<img src="` + logoData + `" alt="` + template.HTMLEscapeString(s.config.CompanyName) + `" style="max-height: 80px; max-width: 260px; margin-bottom: 20px;">`
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Under Maintenance - ` + template.HTMLEscapeString(s.config.CompanyName) + `</title>
+    ` + s.getFaviconHTML() + directionStylesheetHTML() + `
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: linear-gradient(135deg, ` + s.getPrimaryColor() + ` 0%, ` + s.getSecondaryColor() + ` 100%);
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            padding: 20px;
+        }
+        .container {
+            background: white;
+            border-radius: 16px;
+            box-shadow: 0 20px 60px rgba(0,0,0,0.3);
+            max-width: 520px;
+            width: 100%;
+            padding: 48px 40px;
+            text-align: center;
+        }
+        .icon { font-size: 64px; margin-bottom: 16px; }
+        h2 { color: #333; font-size: 24px; margin-bottom: 16px; }
+        p { color: #666; font-size: 15px; line-height: 1.6; }
+        .retry { margin-top: 24px; color: #999; font-size: 13px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        ` + logoHTML + `
+        <div class="icon">🚧</div>
+        <h2>Under Maintenance</h2>
+        <p>` + strings.ReplaceAll(template.HTMLEscapeString(message), "\n", "<br>") + `</p>
+        <p class="retry">Please try again in about ` + fmt.Sprintf("%d", retryMinutes) + ` minutes.</p>
+    </div>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(html))
+}
+
+// handleAdminMaintenance shows and (for the super admin) changes maintenance mode
+func (s *Server) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.renderAdminMaintenance(w, user.IsSuperAdmin(), "")
+		return
+	}
+
+	if !user.IsSuperAdmin() {
+		http.Error(w, "Only the super admin can change maintenance mode", http.StatusForbidden)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.renderAdminMaintenance(w, true, "Error: Invalid form data")
+		return
+	}
+
+	message := strings.TrimSpace(r.FormValue("maintenance_message"))
+	retryMinutes, err := strconv.Atoi(r.FormValue("maintenance_retry_after_minutes"))
+	if err != nil || retryMinutes < 1 || retryMinutes > 1440 {
+		s.renderAdminMaintenance(w, true, "Error: Retry-After must be between 1 and 1440 minutes")
+		return
+	}
+	enable := r.FormValue("enabled") == "true"
+
+	if err := database.DB.SetConfigValue("maintenance_message", message); err != nil {
+		s.renderAdminMaintenance(w, true, "Error: Failed to save maintenance settings")
+		return
+	}
+	database.DB.SetConfigValue("maintenance_retry_after_minutes", strconv.Itoa(retryMinutes))
+
+	wasEnabled := maintenanceEnabled.Load()
+	flag := "false"
+	if enable {
+		flag = "true"
+	}
+	if err := database.DB.SetConfigValue("maintenance_mode", flag); err != nil {
+		s.renderAdminMaintenance(w, true, "Error: Failed to save maintenance mode")
+		return
+	}
+	maintenanceEnabled.Store(enable)
+
+	if enable != wasEnabled {
+		action := database.ActionMaintenanceDisabled
+		if enable {
+			action = database.ActionMaintenanceEnabled
+			log.Printf("🚧 Maintenance mode enabled by %s", user.Email)
+		} else {
+			log.Printf("✅ Maintenance mode disabled by %s", user.Email)
+		}
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     action,
+			EntityType: database.EntitySystem,
+			EntityID:   "maintenance",
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"message":             message,
+				"retry_after_minutes": retryMinutes,
+			}),
+			IPAddress: clientIP(r),
+			UserAgent: r.UserAgent(),
+			Success:   true,
+		})
+	}
+
+	if enable {
+		s.renderAdminMaintenance(w, true, "Maintenance mode is ON. Only admins can use the server.")
+	} else {
+		s.renderAdminMaintenance(w, true, "Maintenance mode is OFF. The server is open to everyone.")
+	}
+}
+
+// renderAdminMaintenance renders the maintenance mode page
+func (s *Server) renderAdminMaintenance(w http.ResponseWriter, canChange bool, message string) {
+	maintenanceMessage, _ := database.DB.GetConfigValue("maintenance_message")
+	retryMinutes := database.DB.GetConfigInt("maintenance_retry_after_minutes", DefaultMaintenanceRetryAfterMinutes)
+	enabled := maintenanceEnabled.Load()
+
+	statusHTML := `<div style="background: #e8f5e9; border: 2px solid #4caf50; border-radius: 8px; padding: 16px; margin-bottom: 24px; color: #2e7d32; font-weight: 600;">✅ The server is open to everyone</div>`
+	if enabled {
+		statusHTML = `<div style="background: #fff3e0; border: 2px solid #ff9800; border-radius: 8px; padding: 16px; margin-bottom: 24px; color: #e65100; font-weight: 600;">🚧 Maintenance mode is ON - users see the maintenance page, admins can still sign in</div>`
+	}
+
+	disabledAttr := ""
+	if !canChange {
+		disabledAttr = " disabled"
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Maintenance Mode - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 800px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            padding: 30px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            margin-bottom: 20px;
+        }
+        .card h2 {
+            color: #333;
+            margin-bottom: 20px;
+            font-size: 20px;
+        }
+        .form-group {
+            margin-bottom: 20px;
+        }
+        label {
+            display: block;
+            margin-bottom: 8px;
+            color: #333;
+            font-weight: 500;
+            font-size: 14px;
+        }
+        input[type="number"], textarea {
+            width: 100%;
+            padding: 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+            font-family: inherit;
+        }
+        .help-text {
+            color: #666;
+            font-size: 12px;
+            margin-top: 4px;
+        }
+        .btn {
+            padding: 12px 24px;
+            border: none;
+            border-radius: 6px;
+            font-size: 14px;
+            font-weight: 600;
+            cursor: pointer;
+        }
+        .btn:disabled {
+            opacity: 0.5;
+            cursor: not-allowed;
+        }
+        .btn-primary {
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+        }
+        .btn-warning {
+            background: #ff9800;
+            color: white;
+        }
+        .success {
+            background: #d4edda;
+            border: 1px solid #c3e6cb;
+            color: #155724;
+            padding: 12px;
+            border-radius: 6px;
+            margin-bottom: 20px;
+        }
+        .error {
+            background: #f8d7da;
+            border: 1px solid #f5c6cb;
+            color: #721c24;
+            padding: 12px;
+            border-radius: 6px;
+            margin-bottom: 20px;
+        }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <div class="card">
+            <h2>🚧 Maintenance Mode</h2>`
+
+	if message != "" {
+		if strings.HasPrefix(message, "Error") {
+			html += `<div class="error">` + template.HTMLEscapeString(message) + `</div>`
+		} else {
+			html += `<div class="success">` + template.HTMLEscapeString(message) + `</div>`
+		}
+	}
+
+	html += statusHTML
+
+	if !canChange {
+		html += `
+            <p class="help-text" style="margin-bottom: 20px;">Only the super admin can turn maintenance mode on or off.</p>`
+	}
+
+	toggleButton := `<button type="submit" name="enabled" value="true" class="btn btn-warning"` + disabledAttr + ` onclick="return confirm('Take the server offline for everyone except admins?')">Enable Maintenance Mode</button>`
+	if enabled {
+		toggleButton = `<button type="submit" name="enabled" value="true" class="btn btn-primary"` + disabledAttr + `>Save Message</button>
+                <button type="submit" name="enabled" value="false" class="btn btn-warning"` + disabledAttr + `>Disable Maintenance Mode</button>`
+	}
+
+	html += `
+            <form method="POST" action="/admin/maintenance">
+                <div class="form-group">
+                    <label for="maintenance_message">Message shown to users</label>
+                    <textarea id="maintenance_message" name="maintenance_message" rows="4" placeholder="We're performing scheduled maintenance. Please check back shortly."` + disabledAttr + `>` + template.HTMLEscapeString(maintenanceMessage) + `</textarea>
+                </div>
+
+                <div class="form-group">
+                    <label for="maintenance_retry_after_minutes">Expected Downtime (Minutes)</label>
+                    <input type="number" id="maintenance_retry_after_minutes" name="maintenance_retry_after_minutes" value="` + strconv.Itoa(retryMinutes) + `" min="1" max="1440" required` + disabledAttr + `>
+                    <p class="help-text">Sent as the Retry-After header and shown on the maintenance page (default: 15)</p>
+                </div>
+
+                ` + toggleButton + `
+            </form>
+            <p class="help-text" style="margin-top: 20px;">While enabled, every request from users and recipients gets a 503 response. Downloads that are already in progress are allowed to finish. The setting survives restarts until it is disabled here.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
@@ -0,0 +1,427 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// maxMetadataStripBytes is the largest file whose metadata is removed. Files are cleaned in
+// memory, and images and documents this large are rare.
+const maxMetadataStripBytes = 100 * 1024 * 1024
+
+// errNoMetadataSupport is returned for files whose format isn't one of the supported ones
+var errNoMetadataSupport = errors.New("unsupported format")
+
+// wantsMetadataStripping returns whether an upload asked for its metadata to be removed. The
+// uploader's choice is "true" or "false"; without one the deployment default applies.
+func wantsMetadataStripping(value string) bool {
+	if value == "" {
+		return database.DB.GetStripMetadataDefault()
+	}
+	return value == "true"
+}
+
+// metadataStripper removes metadata from one file format. It returns the cleaned content and
+// whether anything was removed.
+type metadataStripper func(data []byte) ([]byte, bool, error)
+
+// imageMetadataStrippers handle the image formats that commonly carry camera and location data
+var imageMetadataStrippers = map[string]metadataStripper{
+	".jpg":  stripJPEGMetadata,
+	".jpeg": stripJPEGMetadata,
+	".png":  stripPNGMetadata,
+	".webp": stripWebPMetadata,
+}
+
+// documentMetadataStrippers handle Office documents, whose properties name the author and company
+var documentMetadataStrippers = map[string]metadataStripper{
+	".docx": stripOfficeMetadata,
+	".xlsx": stripOfficeMetadata,
+	".pptx": stripOfficeMetadata,
+}
+
+// findMetadataStripper returns the stripper for a file name, or nil if the format is skipped
+func findMetadataStripper(fileName string) metadataStripper {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if stripper, ok := imageMetadataStrippers[ext]; ok {
+		return stripper
+	}
+	if stripper, ok := documentMetadataStrippers[ext]; ok && database.DB.IsDocumentMetadataStrippingEnabled() {
+		return stripper
+	}
+	return nil
+}
+
+// metadataStrippingApplies reports whether queueMetadataStripping will process an upload. The
+// SHA-256 of such uploads isn't stored at upload time, since the file is about to change.
+func metadataStrippingApplies(fileName string, sizeBytes int64, strip bool) bool {
+	return strip && sizeBytes <= maxMetadataStripBytes && findMetadataStripper(fileName) != nil
+}
+
+// queueMetadataStripping removes the metadata of an upload in the background and then
+// calculates its SHA-256, so the checksum shown to recipients is that of the cleaned file
+func (s *Server) queueMetadataStripping(fileID, fileName string, sizeBytes int64, strip bool) {
+	if !metadataStrippingApplies(fileName, sizeBytes, strip) {
+		return
+	}
+	stripper := findMetadataStripper(fileName)
+
+	fileProcessing.Submit("strip:"+fileID, func() error {
+		// Checksum the result either way, including when the file was left as uploaded
+		defer s.queueFileSHA256(fileID)
+
+		f, err := storage.Files.Get(fileID)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		cleaned, changed, err := stripper(data)
+		if errors.Is(err, errNoMetadataSupport) {
+			// The extension didn't match the content; leave the file alone
+			log.Printf("Skipped metadata stripping of %s (%s): content is not a supported format", fileName, fileID)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("stripping metadata of %s: %w", fileName, err)
+		}
+
+		if changed {
+			if err := storage.Files.Put(fileID, bytes.NewReader(cleaned)); err != nil {
+				return err
+			}
+		}
+
+		hasher, err := hashStoredFile(fileID)
+		if err != nil {
+			return err
+		}
+		if err := database.DB.SetFileMetadataStripped(fileID, int64(len(cleaned)), hasher.SHA1()); err != nil {
+			return err
+		}
+
+		log.Printf("Stripped metadata of %s (%s): %d -> %d bytes", fileName, fileID, len(data), len(cleaned))
+		return nil
+	})
+}
+
+// JPEG segments that are kept: JFIF/JFXX headers, color profiles and the Adobe segment, which
+// decoders need to get CMYK colors right. Other APPn segments (EXIF, XMP, IPTC) and comments go.
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xE0:
+		return true
+	case marker == 0xE2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker == 0xEE:
+		return true
+	case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+		return false
+	}
+	return true
+}
+
+// stripJPEGMetadata removes EXIF, XMP, IPTC and comment segments from a JPEG. The EXIF
+// orientation is written back on its own, so photos taken on their side still display upright.
+func stripJPEGMetadata(data []byte) ([]byte, bool, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, false, errNoMetadataSupport
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	changed := false
+	wroteOrientation := false
+
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF || pos+1 >= len(data) {
+			return nil, false, errors.New("malformed JPEG segment")
+		}
+		marker := data[pos+1]
+		// Fill bytes before a marker
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		// Markers without a payload
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[pos : pos+2])
+			pos += 2
+			continue
+		}
+		if pos+4 > len(data) {
+			return nil, false, errors.New("truncated JPEG segment")
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, false, errors.New("truncated JPEG segment")
+		}
+
+		// The image data starts at SOS and runs to the end; it has no metadata
+		if marker == 0xDA {
+			out.Write(data[pos:])
+			break
+		}
+
+		payload := data[pos+4 : end]
+		if keepJPEGSegment(marker, payload) {
+			out.Write(data[pos:end])
+		} else {
+			// The orientation takes the place of the EXIF segment it came from
+			if orientation := exifOrientation(payload); marker == 0xE1 && orientation > 1 && !wroteOrientation {
+				out.Write(jpegOrientationSegment(orientation))
+				wroteOrientation = true
+			}
+			changed = true
+		}
+		pos = end
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+	return out.Bytes(), true, nil
+}
+
+// exifOrientation returns the orientation tag of an EXIF segment, or 0 if it has none
+func exifOrientation(payload []byte) uint16 {
+	if !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+		return 0
+	}
+	tiff := payload[6:]
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// Tag 0x0112 (orientation) of type SHORT, stored in the entry itself
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if value := order.Uint16(tiff[entry+8:]); value >= 1 && value <= 8 {
+				return value
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// jpegOrientationSegment returns an APP1 segment with an EXIF block holding only the orientation
+func jpegOrientationSegment(orientation uint16) []byte {
+	segment := []byte{0xFF, 0xE1, 0x00, 0x22}
+	segment = append(segment, "Exif\x00\x00"...)
+	segment = append(segment, 'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08) // TIFF header, IFD0 at 8
+	segment = append(segment, 0x00, 0x01)                                   // one entry
+	segment = append(segment, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	segment = append(segment, byte(orientation>>8), byte(orientation), 0x00, 0x00)
+	segment = append(segment, 0x00, 0x00, 0x00, 0x00) // no next IFD
+	return segment
+}
+
+// pngMetadataChunks are the PNG chunks that carry text, EXIF and timestamps
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+// stripPNGMetadata removes text, EXIF and timestamp chunks from a PNG
+func stripPNGMetadata(data []byte) ([]byte, bool, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return nil, false, errNoMetadataSupport
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(signature)
+	changed := false
+
+	pos := len(signature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, false, errors.New("truncated PNG chunk")
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, false, errors.New("truncated PNG chunk")
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		if pngMetadataChunks[chunkType] {
+			changed = true
+		} else {
+			out.Write(data[pos:end])
+		}
+		pos = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+	return out.Bytes(), true, nil
+}
+
+// stripWebPMetadata removes the EXIF and XMP chunks of a WebP and clears their flags in the
+// extended header
+func stripWebPMetadata(data []byte) ([]byte, bool, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, false, errNoMetadataSupport
+	}
+
+	body := bytes.NewBuffer(make([]byte, 0, len(data)))
+	changed := false
+
+	pos := 12
+	for pos+8 <= len(data) {
+		chunkType := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2 // chunks are padded to an even size
+		if size < 0 || end > len(data) {
+			return nil, false, errors.New("truncated WebP chunk")
+		}
+
+		switch chunkType {
+		case "EXIF", "XMP ":
+			changed = true
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			body.Write(chunk)
+		default:
+			body.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+
+	out := make([]byte, 12, 12+body.Len())
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:8], uint32(4+body.Len()))
+	copy(out[8:], "WEBP")
+	return append(out, body.Bytes()...), true, nil
+}
+
+// Office document properties that name people and organizations
+var (
+	officeAppPropertiesPattern = regexp.MustCompile(`(?s)<(Company|Manager)>.*?</(Company|Manager)>|<(Company|Manager)\s*/>`)
+	officeCoreProperties       = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:dcmitype="http://purl.org/dc/dcmitype/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"></cp:coreProperties>`
+	officeCustomProperties = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"></Properties>`
+)
+
+// stripOfficeMetadata empties the core and custom properties of a .docx, .xlsx or .pptx
+// (author, last editor, title, dates) and removes the company and manager. The parts are kept
+// with no content, since the document's relationships still point at them.
+func stripOfficeMetadata(data []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return nil, false, errNoMetadataSupport
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, false, errNoMetadataSupport
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	writer := zip.NewWriter(out)
+	changed := false
+
+	for _, file := range reader.File {
+		var replacement []byte
+		switch file.Name {
+		case "docProps/core.xml":
+			replacement = []byte(officeCoreProperties)
+		case "docProps/custom.xml":
+			replacement = []byte(officeCustomProperties)
+		case "docProps/app.xml":
+			content, err := readZipFile(file)
+			if err != nil {
+				return nil, false, err
+			}
+			replacement = officeAppPropertiesPattern.ReplaceAll(content, nil)
+		}
+
+		if replacement == nil {
+			if err := writer.Copy(file); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: file.Modified})
+		if err != nil {
+			return nil, false, err
+		}
+		if _, err := w.Write(replacement); err != nil {
+			return nil, false, err
+		}
+		changed = true
+	}
+	if err := writer.Close(); err != nil {
+		return nil, false, err
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+	return out.Bytes(), true, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
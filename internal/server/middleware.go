@@ -165,7 +165,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		if r.URL.Path == "/api/upload/chunk" && statusCode == 200 {
 			LogSysMonitor("✅ [%d] %s %s | %v | Req: %s | IP: %s",
 				statusCode, r.Method, r.URL.Path, duration.Round(time.Millisecond),
-				requestSize, getClientIPFromRequest(r))
+				requestSize, clientIP(r))
 			return
 		}
 
@@ -186,7 +186,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 			duration.Round(time.Millisecond),
 			requestSize,
 			responseSize,
-			getClientIPFromRequest(r),
+			clientIP(r),
 			getUserAgentFromRequest(r))
 	})
 }
@@ -205,20 +205,6 @@ func formatBytesHTTP(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-// getClientIPFromRequest extracts the real client IP from the request
-func getClientIPFromRequest(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
-	}
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
-}
-
 // getUserAgentFromRequest extracts the User-Agent from the request
 func getUserAgentFromRequest(r *http.Request) string {
 	ua := r.Header.Get("User-Agent")
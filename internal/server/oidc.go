@@ -0,0 +1,501 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/auth"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// oidcLoginTimeout is how long a user has to sign in at the provider
+const oidcLoginTimeout = 10 * time.Minute
+
+// oidcStateCookie ties a pending login to the browser that started it
+const oidcStateCookie = "oidc_state"
+
+// oidcPendingLogin is a login that was sent to the provider and hasn't come back yet
+type oidcPendingLogin struct {
+	Nonce        string
+	CodeVerifier string
+	Redirect     string
+	ExpiresAt    time.Time
+}
+
+var (
+	oidcPendingLogins   = make(map[string]*oidcPendingLogin)
+	oidcPendingLoginsMu sync.Mutex
+)
+
+// randomOIDCValue returns a random string for the state, nonce and PKCE code verifier
+func randomOIDCValue() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// takeOIDCPendingLogin removes and returns the pending login for state, or nil if there is
+// none or it expired. Each state can only be used once.
+func takeOIDCPendingLogin(state string) *oidcPendingLogin {
+	oidcPendingLoginsMu.Lock()
+	defer oidcPendingLoginsMu.Unlock()
+
+	now := time.Now()
+	for s, pending := range oidcPendingLogins {
+		if now.After(pending.ExpiresAt) {
+			delete(oidcPendingLogins, s)
+		}
+	}
+
+	pending := oidcPendingLogins[state]
+	delete(oidcPendingLogins, state)
+	return pending
+}
+
+// isPasswordLoginDisabled reports whether user accounts must sign in with OpenID Connect. It
+// only takes effect while OIDC is configured, so removing the configuration restores password
+// login. The password form stays on the login page for download accounts.
+func (s *Server) isPasswordLoginDisabled() bool {
+	if s.oidc == nil {
+		return false
+	}
+	disabled, _ := database.DB.GetConfigValue("sso_password_login_disabled")
+	return disabled == "true"
+}
+
+// handleOIDCLogin sends the browser to the OpenID Connect provider to sign in
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	state, err1 := randomOIDCValue()
+	nonce, err2 := randomOIDCValue()
+	verifier, err3 := randomOIDCValue()
+	if err1 != nil || err2 != nil || err3 != nil {
+		log.Printf("Error generating OIDC state: %v %v %v", err1, err2, err3)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := s.oidc.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		log.Printf("🔐 OIDC login failed: %v", err)
+		s.renderLoginPage(w, r, "Single sign-on is unavailable right now. Please try again later.")
+		return
+	}
+
+	expiresAt := time.Now().Add(oidcLoginTimeout)
+	oidcPendingLoginsMu.Lock()
+	oidcPendingLogins[state] = &oidcPendingLogin{
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Redirect:     s.localRedirectPath(r.URL.Query().Get("redirect")),
+		ExpiresAt:    expiresAt,
+	}
+	oidcPendingLoginsMu.Unlock()
+
+	// Lax, not Strict: the provider sends the browser back with a top-level GET
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handleOIDCCallback completes a login when the provider sends the browser back. The user is
+// matched by email, or created as a regular user with the default quota on their first login.
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/auth/oidc/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("🔐 OIDC login failed at the provider: %s %s", providerErr, query.Get("error_description"))
+		s.renderLoginPage(w, r, "Single sign-on failed: "+template.HTMLEscapeString(providerErr))
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if state == "" || err != nil || cookie.Value != state {
+		s.renderLoginPage(w, r, "Single sign-on failed: the login request is invalid or was started in another browser. Please try again.")
+		return
+	}
+	pending := takeOIDCPendingLogin(state)
+	if pending == nil {
+		s.renderLoginPage(w, r, "Single sign-on failed: the login request expired. Please try again.")
+		return
+	}
+
+	identity, err := s.oidc.Exchange(query.Get("code"), pending.Nonce, pending.CodeVerifier)
+	if err != nil {
+		log.Printf("🔐 OIDC login failed: %v", err)
+		database.DB.LogAction(&database.AuditLogEntry{
+			Action:     database.ActionLoginFailed,
+			EntityType: database.EntityUser,
+			Details:    database.CreateAuditDetails(map[string]interface{}{"method": "oidc", "reason": err.Error()}),
+			IPAddress:  clientIP(r),
+			UserAgent:  r.UserAgent(),
+			Success:    false,
+			ErrorMsg:   err.Error(),
+		})
+		s.renderLoginPage(w, r, "Single sign-on failed. Please try again or contact your administrator.")
+		return
+	}
+
+	user, err := database.DB.GetUserByEmailIgnoreCase(identity.Email)
+	if err != nil {
+		user, err = s.provisionOIDCUser(r, identity)
+		if err != nil {
+			log.Printf("🔐 Could not create user %s from single sign-on: %v", identity.Email, err)
+			s.renderLoginPage(w, r, "Single sign-on succeeded, but your account could not be created. Please contact your administrator.")
+			return
+		}
+	}
+
+	if !user.IsActive {
+		log.Printf("🔐 OIDC login rejected for %s: account is inactive", user.Email)
+		s.renderLoginPage(w, r, "Your account is inactive. Please contact your administrator.")
+		return
+	}
+
+	// Users who turned on 2FA still enter their code
+	if s.startTOTPVerification(w, r, user, false, pending.Redirect) {
+		return
+	}
+
+	sessionID, sessionDuration, err := auth.CreateLoginSession(user.Id, false)
+	if err != nil {
+		s.renderLoginPage(w, r, "Failed to create session")
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionLoginSuccess,
+		EntityType: "Session",
+		EntityID:   sessionID,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"email":   user.Email,
+			"success": true,
+			"method":  "oidc",
+			"subject": identity.Subject,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+	log.Printf("🔐 %s signed in with single sign-on", user.Email)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  time.Now().Add(sessionDuration),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	redirect := pending.Redirect
+	if redirect == "" {
+		if user.IsAdmin() {
+			redirect = "/admin"
+		} else {
+			redirect = "/dashboard"
+		}
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// provisionOIDCUser creates a regular user for someone signing in with single sign-on for the
+// first time. The account gets a random password nobody knows; it can be reset like any other.
+func (s *Server) provisionOIDCUser(r *http.Request, identity *auth.OIDCIdentity) (*models.User, error) {
+	password, err := randomOIDCValue()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(identity.Name)
+	if name == "" {
+		name = identity.Email
+		if at := strings.Index(name, "@"); at > 0 {
+			name = name[:at]
+		}
+	}
+
+	user := &models.User{
+		Name:           name,
+		Email:          identity.Email,
+		Password:       hashedPassword,
+		UserLevel:      models.UserLevelUser,
+		Permissions:    models.UserPermissionNone,
+		StorageQuotaMB: int64(database.DB.GetConfigInt("default_quota_mb", int(s.config.DefaultQuotaMB))),
+		IsActive:       true,
+		CreatedAt:      time.Now().Unix(),
+	}
+	if err := database.DB.CreateUser(user); err != nil {
+		return nil, err
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(user.Id),
+		UserEmail:  user.Email,
+		Action:     database.ActionUserCreated,
+		EntityType: database.EntityUser,
+		EntityID:   strconv.Itoa(user.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"email":      user.Email,
+			"name":       user.Name,
+			"user_level": user.UserLevel,
+			"method":     "oidc",
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+	log.Printf("🔐 Created user %s on first single sign-on", user.Email)
+	return user, nil
+}
+
+// handleAdminSSO shows the single sign-on configuration and turns password login for user
+// accounts on or off
+func (s *Server) handleAdminSSO(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.renderAdminSSO(w, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if s.oidc == nil {
+		s.renderAdminSSO(w, "Error: OpenID Connect is not configured")
+		return
+	}
+
+	disabled := r.FormValue("password_login_disabled") == "true"
+	if err := database.DB.SetConfigValue("sso_password_login_disabled", strconv.FormatBool(disabled)); err != nil {
+		s.renderAdminSSO(w, "Error: "+err.Error())
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionSettingsUpdated,
+		EntityType: database.EntitySettings,
+		EntityID:   "sso_password_login_disabled",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"password_login_disabled": disabled,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	message := "Password login is enabled again."
+	if disabled {
+		message = "Password login is disabled. Users must sign in with single sign-on."
+	}
+	s.renderAdminSSO(w, message)
+}
+
+// renderAdminSSO renders the single sign-on admin page
+func (s *Server) renderAdminSSO(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Single Sign-On - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        .card p { color: #555; font-size: 14px; line-height: 1.6; margin-bottom: 10px; }
+        .btn {
+            padding: 8px 16px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+        }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        table { width: 100%; border-collapse: collapse; font-size: 14px; margin-bottom: 10px; }
+        th, td { text-align: left; padding: 10px 12px; border-bottom: 1px solid #eee; vertical-align: top; }
+        th { width: 200px; color: #555; font-weight: 600; }
+        td { word-break: break-all; }
+        code { background: #f0f0f0; padding: 2px 6px; border-radius: 4px; font-size: 13px; }
+        label { font-size: 14px; color: #333; }
+        .muted { color: #888; font-size: 13px; margin: 8px 0 16px; }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <h2>🔑 Single Sign-On</h2>
+
+        <div class="info-box">
+            Users can sign in through an OpenID Connect provider such as Keycloak, Entra ID, Okta or Google. They are matched to existing accounts by email address; anyone signing in for the first time gets a regular user account with the default storage quota.
+        </div>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	if s.oidc == nil {
+		html += `
+        <div class="card">
+            <h3>OpenID Connect is not configured</h3>
+            <p>Set these environment variables and restart the server. Register <code>` + template.HTMLEscapeString(s.getPublicURL()+"/auth/oidc/callback") + `</code> as the redirect URI at the provider.</p>
+            <table>
+                <tr><th>OIDC_ISSUER_URL</th><td>The issuer, e.g. <code>https://login.example.com/realms/company</code></td></tr>
+                <tr><th>OIDC_CLIENT_ID</th><td>The client ID registered at the provider</td></tr>
+                <tr><th>OIDC_CLIENT_SECRET</th><td>The client secret (optional for public clients)</td></tr>
+                <tr><th>OIDC_REDIRECT_URI</th><td>Only needed if the server URL isn't the address users reach the server at</td></tr>
+            </table>
+        </div>
+    </div>
+</body>
+</html>`
+		w.Write([]byte(html))
+		return
+	}
+
+	oidcCfg := s.config.OIDC
+	redirectURI := oidcCfg.RedirectURI
+	if redirectURI == "" {
+		redirectURI = s.getPublicURL() + "/auth/oidc/callback"
+	}
+	secretStatus := "Not set (public client)"
+	if oidcCfg.ClientSecret != "" {
+		secretStatus = "Set"
+	}
+	checked := ""
+	if s.isPasswordLoginDisabled() {
+		checked = " checked"
+	}
+
+	html += `
+        <div class="card">
+            <h3>OpenID Connect</h3>
+            <table>
+                <tr><th>Issuer</th><td>` + template.HTMLEscapeString(oidcCfg.IssuerURL) + `</td></tr>
+                <tr><th>Client ID</th><td>` + template.HTMLEscapeString(oidcCfg.ClientID) + `</td></tr>
+                <tr><th>Client secret</th><td>` + secretStatus + `</td></tr>
+                <tr><th>Redirect URI</th><td><code>` + template.HTMLEscapeString(redirectURI) + `</code></td></tr>
+            </table>
+            <p>The login page shows a single sign-on button, unless a different SSO login URL is set under Branding.</p>
+        </div>
+
+        <div class="card">
+            <h3>Password login</h3>
+            <form method="POST" action="/admin/sso">
+                <label><input type="checkbox" name="password_login_disabled" value="true"` + checked + `> Disable password login for user accounts</label>
+                <p class="muted">Users and admins must then sign in with single sign-on. Download accounts keep signing in with their password. Setting up the first admin is not affected, and password login comes back if OpenID Connect is removed from the configuration.</p>
+                <button type="submit" class="btn">Save</button>
+            </form>
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
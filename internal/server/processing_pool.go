@@ -0,0 +1,128 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"log"
+	"sync"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// DefaultProcessingWorkers is used when processing_workers is not configured
+const DefaultProcessingWorkers = 2
+
+// processingJob is one piece of background work on an uploaded file, such as a checksum,
+// preview or conversion. Jobs with the same key are only queued once.
+type processingJob struct {
+	key string
+	run func() error
+}
+
+// processingStats is a snapshot of the processing pool shown to admins
+type processingStats struct {
+	Queued    int
+	Active    int
+	Workers   int
+	Completed int64
+	Failed    int64
+}
+
+// Saturation returns how many of the workers are busy, in percent
+func (p processingStats) Saturation() float64 {
+	if p.Workers == 0 {
+		return 0
+	}
+	return float64(p.Active) / float64(p.Workers) * 100
+}
+
+// processingPool runs background file work on a bounded number of workers. Uploads only
+// enqueue jobs and return; a burst of uploads makes the queue longer instead of starting
+// unbounded CPU-heavy work at once.
+type processingPool struct {
+	mu        sync.Mutex
+	queue     []processingJob
+	pending   map[string]bool // keys that are queued or running
+	active    int
+	completed int64
+	failed    int64
+}
+
+var fileProcessing = &processingPool{pending: make(map[string]bool)}
+
+// getProcessingWorkers returns the configured number of concurrent processing workers
+func getProcessingWorkers() int {
+	workers := database.DB.GetConfigInt("processing_workers", DefaultProcessingWorkers)
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// Submit queues a job unless one with the same key is already queued or running.
+// It reports whether the job was queued.
+func (p *processingPool) Submit(key string, run func() error) bool {
+	workers := getProcessingWorkers()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending[key] {
+		return false
+	}
+	p.pending[key] = true
+	p.queue = append(p.queue, processingJob{key: key, run: run})
+	p.dispatchLocked(workers)
+	return true
+}
+
+// dispatchLocked starts queued jobs while there are free workers. p.mu must be held.
+func (p *processingPool) dispatchLocked(workers int) {
+	for p.active < workers && len(p.queue) > 0 {
+		job := p.queue[0]
+		p.queue = p.queue[1:]
+		p.active++
+		go p.work(job)
+	}
+}
+
+func (p *processingPool) work(job processingJob) {
+	err := job.run()
+	if err != nil {
+		log.Printf("Warning: Background processing of %s failed: %v", job.key, err)
+	}
+
+	// Read outside the lock so a changed worker limit applies to the next job
+	workers := getProcessingWorkers()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.active--
+	delete(p.pending, job.key)
+	if err != nil {
+		p.failed++
+	} else {
+		p.completed++
+	}
+	p.dispatchLocked(workers)
+}
+
+// Stats returns the current queue depth and worker usage
+func (p *processingPool) Stats() processingStats {
+	workers := getProcessingWorkers()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return processingStats{
+		Queued:    len(p.queue),
+		Active:    p.active,
+		Workers:   workers,
+		Completed: p.completed,
+		Failed:    p.failed,
+	}
+}
@@ -0,0 +1,101 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// redirectAuthPaths are never used as a redirect target after login, so a login can't loop
+// back into the login or log the user straight out again
+var redirectAuthPaths = []string{"/login", "/logout", "/2fa/"}
+
+// getRedirectAllowedPaths returns the path prefixes users may be sent to after login, or nil
+// if any path on this server is allowed
+func getRedirectAllowedPaths() []string {
+	value, _ := database.DB.GetConfigValue("redirect_allowed_paths")
+	var prefixes []string
+	for _, prefix := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if prefix = strings.TrimSpace(prefix); strings.HasPrefix(prefix, "/") {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// localRedirectPath returns the path a user may be sent to after login, or "" so the login
+// can't be used to send users to another site. Full URLs are only accepted if they point at
+// this server's public URL, and are reduced to their path and query.
+func (s *Server) localRedirectPath(redirect string) string {
+	if redirect == "" {
+		return ""
+	}
+	target := s.redirectTarget(redirect)
+	if target == "" {
+		log.Printf("Rejected login redirect target %q", redirect)
+	}
+	return target
+}
+
+// redirectTarget validates and normalizes a redirect target, see localRedirectPath
+func (s *Server) redirectTarget(redirect string) string {
+	// Browsers ignore tabs and newlines in URLs and treat backslashes as slashes, which turns
+	// "/\evil.com" or "/\t/evil.com" into a link to another site
+	if strings.ContainsAny(redirect, "\\") || strings.IndexFunc(redirect, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+		return ""
+	}
+
+	parsed, err := url.Parse(redirect)
+	if err != nil || parsed.Opaque != "" || parsed.User != nil {
+		return ""
+	}
+	if parsed.Scheme != "" || parsed.Host != "" {
+		public, err := url.Parse(s.getPublicURL())
+		if err != nil || !strings.EqualFold(parsed.Scheme, public.Scheme) || !strings.EqualFold(parsed.Host, public.Host) {
+			return ""
+		}
+	}
+
+	if !strings.HasPrefix(parsed.Path, "/") || strings.HasPrefix(parsed.Path, "//") {
+		return ""
+	}
+
+	// Resolve "." and ".." so "/dashboard/../admin" can't slip past the allowed paths
+	cleaned := path.Clean(parsed.Path)
+	if strings.HasSuffix(parsed.Path, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	for _, authPath := range redirectAuthPaths {
+		if cleaned == strings.TrimSuffix(authPath, "/") || strings.HasPrefix(cleaned, authPath) {
+			return ""
+		}
+	}
+
+	if allowed := getRedirectAllowedPaths(); len(allowed) > 0 {
+		ok := false
+		for _, prefix := range allowed {
+			if cleaned == prefix || strings.HasPrefix(cleaned, strings.TrimSuffix(prefix, "/")+"/") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return ""
+		}
+	}
+
+	target := (&url.URL{Path: cleaned, RawQuery: parsed.RawQuery}).String()
+	if strings.HasPrefix(target, "//") {
+		return ""
+	}
+	return target
+}
@@ -10,12 +10,14 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
 	"github.com/Frimurare/WulfVault/internal/auth"
 	"github.com/Frimurare/WulfVault/internal/config"
 	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
 	"github.com/Frimurare/WulfVault/internal/models"
 )
 
@@ -24,17 +26,28 @@ type Server struct {
 	templates        *template.Template
 	activeTransfers  map[string]bool // sessionId -> has active transfer
 	transfersMutex   sync.RWMutex
+	oidc             *auth.OIDCProvider // nil unless OpenID Connect is configured
+
+	httpServer        *http.Server
+	httpServerMu      sync.Mutex
+	shutdownRequested chan struct{} // closed when an admin restarts the server from the web UI
+	shutdownOnce      sync.Once
 }
 
 // New creates a new web server instance
 func New(cfg *config.Config) *Server {
-	return &Server{
-		config:          cfg,
-		activeTransfers: make(map[string]bool),
+	s := &Server{
+		config:            cfg,
+		activeTransfers:   make(map[string]bool),
+		shutdownRequested: make(chan struct{}),
+	}
+	if cfg.OIDC.Enabled() {
+		s.oidc = auth.NewOIDCProvider(cfg.OIDC, s.getPublicURL()+"/auth/oidc/callback")
 	}
+	return s
 }
 
-// Start starts the HTTP server
+// Start starts the HTTP server. It returns nil once Shutdown has stopped the server.
 func (s *Server) Start() error {
 	// Load templates
 	if err := s.loadTemplates(); err != nil {
@@ -44,28 +57,46 @@ func (s *Server) Start() error {
 	// Cleanup orphaned chunks from previous runs/crashes
 	CleanupOrphanedChunks(s.config.UploadsDir)
 
+	// Restore maintenance mode if it was left on
+	loadMaintenanceMode()
+
+	// Client addresses decide who may reach the admin pages and downloads
+	if err := setTrustedProxies(s.config.GetTrustedProxies()); err != nil {
+		return err
+	}
+	loadIPAccessRules()
+
 	// Setup routes
 	mux := http.NewServeMux()
 
 	// Load branding configuration
 	s.loadBrandingConfig()
 
+	// Links in email footers point at the public URL
+	email.SetPublicURL(s.getPublicURL())
+
 	// Public routes
 	mux.HandleFunc("/", s.handleHome)
 	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/login/local", s.handleLogin) // Break-glass password login when SSO-only mode is enabled
 	mux.HandleFunc("/logout", s.handleLogout)
+	mux.HandleFunc("/auth/oidc/login", s.handleOIDCLogin)
+	mux.HandleFunc("/auth/oidc/callback", s.handleOIDCCallback)
 	mux.HandleFunc("/forgot-password", s.handleForgotPassword)
 	mux.HandleFunc("/reset-password", s.handleResetPassword)
 	mux.HandleFunc("/s/", s.handleSplashPage)
+	mux.HandleFunc("/splash/heartbeat", s.handleSplashViewerHeartbeat)
 	mux.HandleFunc("/d/", s.handleDownload)
+	mux.HandleFunc("/d/zip", s.handleFilesZip)
+	mux.HandleFunc("/preview/", s.handleFilePreview)
 	mux.HandleFunc("/health", s.handleHealth)
 
 	// 2FA routes
 	mux.HandleFunc("/2fa/verify", s.handle2FAVerify)
-	mux.HandleFunc("/2fa/setup", s.requireAuth(s.handle2FASetup))
-	mux.HandleFunc("/2fa/enable", s.requireAuth(s.handle2FAEnable))
-	mux.HandleFunc("/2fa/disable", s.requireAuth(s.handle2FADisable))
-	mux.HandleFunc("/2fa/regenerate-backup-codes", s.requireAuth(s.handle2FARegenerateBackupCodes))
+	mux.HandleFunc("/2fa/setup", s.browserOnly(s.requireAuth(s.handle2FASetup)))
+	mux.HandleFunc("/2fa/enable", s.browserOnly(s.requireAuth(s.handle2FAEnable)))
+	mux.HandleFunc("/2fa/disable", s.browserOnly(s.requireAuth(s.handle2FADisable)))
+	mux.HandleFunc("/2fa/regenerate-backup-codes", s.browserOnly(s.requireAuth(s.handle2FARegenerateBackupCodes)))
 
 	// Public file request routes
 	mux.HandleFunc("/upload-request/", s.handleUploadRequest)
@@ -85,9 +116,17 @@ func (s *Server) Start() error {
 	// User routes (require authentication)
 	mux.HandleFunc("/dashboard", s.requireAuth(s.handleUserDashboard))
 	mux.HandleFunc("/settings", s.requireAuth(s.handleUserSettings))
-	mux.HandleFunc("/settings/delete-account", s.requireAuth(s.handleUserAccountDelete))
+	mux.HandleFunc("/settings/delete-account", s.browserOnly(s.requireAuth(s.handleUserAccountDelete)))
 	mux.HandleFunc("/settings/account", s.requireAuth(s.handleUserAccountSettings))
-	mux.HandleFunc("/change-password", s.requireAuth(s.handleChangePassword))
+	mux.HandleFunc("/settings/trusted-devices", s.browserOnly(s.requireAuth(s.handleTrustedDevices)))
+	mux.HandleFunc("/settings/sessions", s.browserOnly(s.requireAuth(s.handleActiveSessions)))
+	mux.HandleFunc("/settings/sign-out-everywhere", s.browserOnly(s.requireAuth(s.handleSignOutEverywhere)))
+	mux.HandleFunc("/settings/api-tokens", s.browserOnly(s.requireAuth(s.handleAPITokens)))
+	mux.HandleFunc("/notifications", s.requireAuth(s.handleNotifications))
+	mux.HandleFunc("/notifications/preferences", s.requireAuth(s.handleNotificationPreferences))
+	mux.HandleFunc("/change-password", s.browserOnly(s.requireAuth(s.handleChangePassword)))
+	mux.HandleFunc("/settings/change-email", s.browserOnly(s.requireAuth(s.handleChangeEmail)))
+	mux.HandleFunc("/settings/confirm-email", s.handleConfirmEmailChange)
 
 	// GDPR API routes (require authentication)
 	mux.HandleFunc("/api/v1/user/export-data", s.requireAuth(s.handleUserDataExport))
@@ -97,11 +136,27 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/api/upload/init", s.requireAuth(s.handleChunkedUploadInit))
 	mux.HandleFunc("/api/upload/chunk", s.requireAuth(s.handleChunkedUploadChunk))
 	mux.HandleFunc("/api/upload/complete", s.requireAuth(s.handleChunkedUploadComplete))
+	mux.HandleFunc("/api/upload/cancel", s.requireAuth(s.handleChunkedUploadCancel))
 	log.Println("✅ Chunked upload endpoints initialized")
 
 	mux.HandleFunc("/files", s.requireAuth(s.handleUserFiles))
 	mux.HandleFunc("/file/delete", s.requireAuth(s.handleFileDelete))
+	mux.HandleFunc("/file/undelete", s.requireAuth(s.handleFileUndelete))
 	mux.HandleFunc("/file/edit", s.requireAuth(s.handleFileEdit))
+	mux.HandleFunc("/file/versions", s.requireAuth(s.handleFileVersions))
+	mux.HandleFunc("/file/versions/upload", s.requireAuth(s.handleFileVersionUpload))
+	mux.HandleFunc("/file/versions/restore", s.requireAuth(s.handleFileVersionRestore))
+	mux.HandleFunc("/file/schedule", s.requireAuth(s.handleFileSchedule))
+	mux.HandleFunc("/file/unblock-downloads", s.requireAuth(s.handleFileUnblockDownloads))
+	mux.HandleFunc("/file/reset-downloads", s.requireAuth(s.handleFileResetDownloads))
+	mux.HandleFunc("/file/access", s.requireAuth(s.handleFileAccess))
+	mux.HandleFunc("/file/access/update", s.requireAuth(s.handleFileAccessUpdate))
+	mux.HandleFunc("/file/access-request", s.handleFileAccessRequest)
+	mux.HandleFunc("/file/reshare-request", s.handleFileReshareRequest)
+	mux.HandleFunc("/file/access-requests/review/", s.requireAuth(s.handleFileAccessRequestReview))
+	mux.HandleFunc("/file/access-requests/decide", s.requireAuth(s.handleFileAccessRequestDecide))
+	mux.HandleFunc("/reminders/unsubscribe/", s.handleExpiryReminderUnsubscribe)
+	mux.HandleFunc("/email/unsubscribe/", s.handleEmailUnsubscribe)
 	mux.HandleFunc("/file/downloads", s.requireAuth(s.handleFileDownloadHistory))
 	mux.HandleFunc("/file/email", s.requireAuth(s.handleFileEmail))
 	mux.HandleFunc("/file-request/create", s.requireAuth(s.handleFileRequestCreate))
@@ -110,6 +165,11 @@ func (s *Server) Start() error {
 
 	// Teams routes (require authentication)
 	mux.HandleFunc("/teams", s.requireAuth(s.handleUserTeams))
+	mux.HandleFunc("/teams/download-zip", s.requireAuth(s.handleTeamFilesZip))
+
+	// Upload approval routes (require authentication, access checked per file)
+	mux.HandleFunc("/approvals", s.requireAuth(s.handleApprovals))
+	mux.HandleFunc("/approvals/decide", s.requireAuth(s.handleApprovalDecide))
 
 	// Admin routes (require admin authentication)
 	mux.HandleFunc("/admin", s.requireAdmin(s.handleAdminDashboard))
@@ -117,21 +177,45 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/admin/users/create", s.requireAdmin(s.handleAdminUserCreate))
 	mux.HandleFunc("/admin/users/edit", s.requireAdmin(s.handleAdminUserEdit))
 	mux.HandleFunc("/admin/users/delete", s.requireAdmin(s.handleAdminUserDelete))
+	mux.HandleFunc("/admin/users/impersonate", s.requireAdmin(s.handleAdminImpersonate))
+	mux.HandleFunc("/impersonation/exit", s.requireAuth(s.handleImpersonationExit))
+	mux.HandleFunc("/admin/users/pending-setup", s.requireAdmin(s.handleAdminPendingSetup))
+	mux.HandleFunc("/admin/users/resend-welcome", s.requireAdmin(s.handleAdminResendWelcome))
 	mux.HandleFunc("/admin/download-accounts/toggle", s.requireAdmin(s.handleAdminToggleDownloadAccount))
 	mux.HandleFunc("/admin/download-accounts/create", s.requireAdmin(s.handleAdminCreateDownloadAccount))
 	mux.HandleFunc("/admin/download-accounts/edit", s.requireAdmin(s.handleAdminEditDownloadAccount))
 	mux.HandleFunc("/admin/download-accounts/delete", s.requireAdmin(s.handleAdminDeleteDownloadAccount))
 	mux.HandleFunc("/admin/files", s.requireAdmin(s.handleAdminFiles))
+	mux.HandleFunc("/admin/files/export", s.requireAdmin(s.handleAdminFilesExport))
+	mux.HandleFunc("/admin/files/bulk-delete", s.requireAdmin(s.handleAdminBulkDelete))
 	mux.HandleFunc("/admin/duplicates", s.requireAdmin(s.handleAdminDuplicates))
 	mux.HandleFunc("/admin/trash", s.requireAdmin(s.handleAdminTrash))
 	mux.HandleFunc("/admin/trash/restore", s.requireAdmin(s.handleAdminRestoreFile))
+	mux.HandleFunc("/admin/trash/bulk-restore", s.requireAdmin(s.handleAdminBulkRestore))
 	mux.HandleFunc("/admin/trash/delete", s.requireAdmin(s.handleAdminPermanentDelete))
 	mux.HandleFunc("/admin/trash/empty-all", s.requireAdmin(s.handleAdminEmptyAllTrash))
 	mux.HandleFunc("/admin/branding", s.requireAdmin(s.handleAdminBranding))
 	mux.HandleFunc("/admin/settings", s.requireAdmin(s.handleAdminSettings))
+	mux.HandleFunc("/admin/maintenance", s.requireAdmin(s.handleAdminMaintenance))
+	mux.HandleFunc("/admin/backup", s.requireAdmin(s.handleAdminBackupPage))
+	mux.HandleFunc("/admin/backup/download", s.browserOnly(s.requireAdmin(s.handleAdminBackup)))
+	mux.HandleFunc("/admin/backup/restore", s.browserOnly(s.requireAdmin(s.handleAdminRestore)))
+	mux.HandleFunc("/admin/backup/schedule", s.requireAdmin(s.handleAdminBackupSchedule))
+	mux.HandleFunc("/admin/backup/run", s.requireAdmin(s.handleAdminBackupRun))
 	mux.HandleFunc("/admin/email-settings", s.requireAdmin(s.handleEmailSettings))
+	mux.HandleFunc("/admin/download-terms", s.requireAdmin(s.handleAdminDownloadTerms))
+	mux.HandleFunc("/admin/retention", s.requireAdmin(s.handleAdminRetention))
+	mux.HandleFunc("/admin/expiry-policy", s.requireAdmin(s.handleAdminExpiryPolicy))
+	mux.HandleFunc("/admin/integrity", s.requireAdmin(s.handleAdminIntegrity))
+	mux.HandleFunc("/admin/webhooks", s.requireAdmin(s.handleAdminWebhooks))
+	mux.HandleFunc("/admin/email-queue", s.requireAdmin(s.handleAdminEmailQueue))
+	mux.HandleFunc("/admin/email-templates", s.requireAdmin(s.handleAdminEmailTemplates))
+	mux.HandleFunc("/admin/sso", s.requireAdmin(s.handleAdminSSO))
+	mux.HandleFunc("/admin/expired-files/trash", s.requireAdmin(s.handleAdminTrashExpiredFiles))
 	mux.HandleFunc("/admin/teams", s.requireAdmin(s.handleAdminTeams))
 	mux.HandleFunc("/admin/reboot", s.requireAdmin(s.handleAdminReboot))
+	mux.HandleFunc("/admin/storage/recalculate", s.requireAdmin(s.handleAdminRecalculateStorage))
+	mux.HandleFunc("/admin/virus-scan/rescan", s.requireAdmin(s.handleAdminVirusRescan))
 	mux.HandleFunc("/admin/audit-logs", s.requireAdmin(s.handleAdminAuditLogs))
 	mux.HandleFunc("/admin/server-logs", s.requireAdmin(s.handleAdminServerLogs))
 	mux.HandleFunc("/admin/sysmonitor-logs", s.requireAdmin(s.handleAdminSysMonitorLogs))
@@ -150,6 +234,8 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/api/teams/remove-member", s.requireAuth(s.handleAPITeamRemoveMember))
 	mux.HandleFunc("/api/teams/share-file", s.requireAuth(s.handleAPIShareFileToTeam))
 	mux.HandleFunc("/api/teams/unshare-file", s.requireAuth(s.handleAPIUnshareFileFromTeam))
+	mux.HandleFunc("/api/teams/set-role", s.requireAuth(s.handleAPITeamSetRole))
+	mux.HandleFunc("/api/teams/delete", s.requireAuth(s.handleAPITeamDelete))
 
 	// Teams Admin API routes (require admin)
 	mux.HandleFunc("/api/admin/teams/create", s.requireAdmin(s.handleAPITeamCreate))
@@ -160,6 +246,7 @@ func (s *Server) Start() error {
 	// Email API routes
 	mux.HandleFunc("/api/email/configure", s.requireAuth(s.requireAdmin(s.handleEmailConfigure)))
 	mux.HandleFunc("/api/email/activate", s.requireAuth(s.requireAdmin(s.handleEmailActivate)))
+	mux.HandleFunc("/api/email/providers/order", s.requireAuth(s.requireAdmin(s.handleEmailProviderOrder)))
 	mux.HandleFunc("/api/email/test", s.requireAuth(s.requireAdmin(s.handleEmailTest)))
 	mux.HandleFunc("/api/email/send-splash-link", s.requireAuth(s.handleSendSplashLink))
 
@@ -197,18 +284,33 @@ func (s *Server) Start() error {
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
 	// Server configuration
-	addr := ":" + s.config.Port
+	if err := config.ValidateBindAddress(s.config.BindAddress); err != nil {
+		return err
+	}
+	addr := s.config.ListenAddress()
+	timeouts := s.config.HTTPTimeouts
 	server := &http.Server{
 		Addr:              addr,
-		Handler:           loggingMiddleware(mux), // Use the new enhanced logging middleware
-		ReadHeaderTimeout: 60 * time.Second,       // Time to read request headers only (not body)
-		WriteTimeout:      8 * time.Hour,          // Extended for very large file uploads on slow connections (up to 8 hours)
-		IdleTimeout:       120 * time.Second,      // Keep-alive timeout
+		Handler:           s.transferTimeoutMiddleware(loggingMiddleware(s.ipAccessMiddleware(s.maintenanceMiddleware(mux)))),
+		ReadHeaderTimeout: timeouts.ReadHeaderTimeout(), // Time to read request headers only (not body)
+		ReadTimeout:       timeouts.ReadTimeout(),       // Time to read the whole request; uploads get the transfer timeout
+		WriteTimeout:      timeouts.WriteTimeout(),      // Time to write the response; downloads get the transfer timeout
+		IdleTimeout:       timeouts.IdleTimeout(),       // Keep-alive timeout
 	}
 
 	log.Printf("🚀 Server starting on %s", addr)
+	log.Printf("⏱️  Timeouts: header %s, read %s, write %s, idle %s, file transfers %s",
+		server.ReadHeaderTimeout, server.ReadTimeout, server.WriteTimeout, server.IdleTimeout, timeouts.TransferTimeout())
 	log.Printf("📍 Server URL: %s", s.config.ServerURL)
-	return server.ListenAndServe()
+
+	s.httpServerMu.Lock()
+	s.httpServer = server
+	s.httpServerMu.Unlock()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
 
 // loadTemplates loads all HTML templates
@@ -223,23 +325,29 @@ func (s *Server) loadTemplates() error {
 // Middleware: Require authentication
 func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Scripts authenticate with an API token instead of a session
+		if token, ok := bearerToken(r); ok {
+			s.serveWithAPIToken(w, r, token, false, next)
+			return
+		}
+
 		cookie, err := r.Cookie("session")
 		if err != nil {
-			http.Redirect(w, r, "/login?redirect="+r.URL.Path, http.StatusSeeOther)
+			http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
 			return
 		}
 
 		user, err := s.getUserFromSession(r)
 		if err != nil {
-			http.Redirect(w, r, "/login?redirect="+r.URL.Path, http.StatusSeeOther)
+			http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
 			return
 		}
 
 		// Check for inactivity timeout (10 minutes), but only if no active transfer
-		// Skip inactivity check for "Remember Me" sessions (sessions with >2 days validity)
+		// Skip inactivity check for "Remember Me" sessions (logins with "Keep me logged in" ticked)
 		isRememberMeSession := auth.IsLongSession(cookie.Value)
 		if !s.hasActiveTransfer(cookie.Value) && !isRememberMeSession {
-			timeSinceLastActivity := time.Since(time.Unix(user.LastOnline, 0))
+			timeSinceLastActivity := time.Since(time.Unix(lastActivityOf(user), 0))
 			if timeSinceLastActivity > auth.InactivityTimeout {
 				// Force logout due to inactivity
 				auth.DeleteSession(cookie.Value)
@@ -255,8 +363,14 @@ func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 			}
 		}
 
+		auth.TouchSession(cookie.Value, clientIP(r), r.UserAgent())
+
 		// Store user in context (simple approach: we'll pass it via request context)
 		r = r.WithContext(contextWithUser(r.Context(), user))
+		if user.ImpersonatedBy != nil {
+			serveImpersonated(w, r, user, next)
+			return
+		}
 		next(w, r)
 	}
 }
@@ -264,9 +378,19 @@ func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 // Middleware: Require admin authentication
 func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.checkAdminIPAccess(w, r) {
+			return
+		}
+
+		// Scripts authenticate with an API token instead of a session
+		if token, ok := bearerToken(r); ok {
+			s.serveWithAPIToken(w, r, token, true, next)
+			return
+		}
+
 		cookie, err := r.Cookie("session")
 		if err != nil {
-			log.Printf("⚠️  Admin auth failed: No session cookie | Path: %s | IP: %s", r.URL.Path, getClientIP(r))
+			log.Printf("⚠️  Admin auth failed: No session cookie | Path: %s | IP: %s", r.URL.Path, clientIP(r))
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
@@ -285,10 +409,10 @@ func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		// Check for inactivity timeout (10 minutes), but only if no active transfer
-		// Skip inactivity check for "Remember Me" sessions (sessions with >2 days validity)
+		// Skip inactivity check for "Remember Me" sessions (logins with "Keep me logged in" ticked)
 		isRememberMeSession := auth.IsLongSession(cookie.Value)
 		if !s.hasActiveTransfer(cookie.Value) && !isRememberMeSession {
-			timeSinceLastActivity := time.Since(time.Unix(user.LastOnline, 0))
+			timeSinceLastActivity := time.Since(time.Unix(lastActivityOf(user), 0))
 			if timeSinceLastActivity > auth.InactivityTimeout {
 				// Force logout due to inactivity
 				auth.DeleteSession(cookie.Value)
@@ -304,11 +428,25 @@ func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
 			}
 		}
 
+		auth.TouchSession(cookie.Value, clientIP(r), r.UserAgent())
 		r = r.WithContext(contextWithUser(r.Context(), user))
+		if user.ImpersonatedBy != nil {
+			serveImpersonated(w, r, user, next)
+			return
+		}
 		next(w, r)
 	}
 }
 
+// lastActivityOf returns when the person behind a session was last active: the user, or the
+// super admin viewing the app as them
+func lastActivityOf(user *models.User) int64 {
+	if user.ImpersonatedBy != nil {
+		return user.ImpersonatedBy.LastOnline
+	}
+	return user.LastOnline
+}
+
 // getUserFromSession retrieves user from session cookie
 func (s *Server) getUserFromSession(r *http.Request) (*models.User, error) {
 	cookie, err := r.Cookie("session")
@@ -348,27 +486,13 @@ func (s *Server) loadBrandingConfig() {
 
 // Helper functions for color fallbacks
 func (s *Server) getPrimaryColor() string {
-	color := s.config.PrimaryColor
-	// Reject empty, white, or near-white colors
-	if color == "" || color == "#ffffff" || color == "#fff" ||
-		color == "#FFFFFF" || color == "#FFF" ||
-		color == "white" || color == "White" || color == "WHITE" ||
-		color == "#fefefe" || color == "#FEFEFE" {
-		return "#2563eb" // Default blue
-	}
-	return color
+	// Reject empty, white, near-white or invalid colors
+	return brandingColorOrDefault(s.config.PrimaryColor, "#2563eb") // Default blue
 }
 
 func (s *Server) getSecondaryColor() string {
-	color := s.config.SecondaryColor
-	// Reject empty, white, or near-white colors
-	if color == "" || color == "#ffffff" || color == "#fff" ||
-		color == "#FFFFFF" || color == "#FFF" ||
-		color == "white" || color == "White" || color == "WHITE" ||
-		color == "#fefefe" || color == "#FEFEFE" {
-		return "#1e40af" // Default darker blue
-	}
-	return color
+	// Reject empty, white, near-white or invalid colors
+	return brandingColorOrDefault(s.config.SecondaryColor, "#1e40af") // Default darker blue
 }
 
 // getPublicURL returns the full server URL including port
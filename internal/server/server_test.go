@@ -0,0 +1,30 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/config"
+)
+
+func TestGetPublicURL(t *testing.T) {
+	tests := []struct {
+		name, serverURL, port, bindAddress, want string
+	}{
+		{"port added", "http://files.example.com", "8080", "", "http://files.example.com:8080"},
+		{"standard port", "https://files.example.com", "443", "", "https://files.example.com"},
+		{"port in URL", "http://files.example.com:9000", "8080", "", "http://files.example.com:9000"},
+		{"bind address keeps the port", "http://files.example.com", "8080", "127.0.0.1", "http://files.example.com:8080"},
+		{"bind address, standard port", "https://files.example.com", "443", "127.0.0.1", "https://files.example.com"},
+	}
+	for _, tt := range tests {
+		s := &Server{config: &config.Config{ServerURL: tt.serverURL, Port: tt.port, BindAddress: tt.bindAddress}}
+		if got := s.getPublicURL(); got != tt.want {
+			t.Errorf("%s: getPublicURL() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+)
+
+// shareLinkCookie keeps the verified token of a signed share link for the file's splash page
+// and downloads, so that redirects and forms that drop the token still carry it
+const shareLinkCookie = "share_link_"
+
+// requiresSignedLink reports whether a file's links only work with their signature: a signed
+// link to it was emailed and plain links are not allowed
+func requiresSignedLink(fileInfo *database.FileInfo) bool {
+	return email.IsShareLinkSigningEnabled() && !email.IsPlainShareLinkAllowed() && database.DB.HasSignedLinkSent(fileInfo.Id)
+}
+
+// checkShareLink verifies the share link token of a request, taken from the link or kept from
+// an earlier visit, and returns it ("" if there is none). Files that need a signed link can
+// only be opened without one by their owner, an admin or members of a team they are shared
+// with. Returns email.ErrShareLinkInvalid, email.ErrShareLinkExpired or email.ErrShareLinkMissing
+// if the link must not be used.
+func (s *Server) checkShareLink(r *http.Request, fileInfo *database.FileInfo) (string, error) {
+	if !email.IsShareLinkSigningEnabled() {
+		return "", nil
+	}
+
+	token := r.URL.Query().Get(email.ShareLinkTokenParam)
+	if token == "" {
+		if cookie, err := r.Cookie(shareLinkCookie + fileInfo.Id); err == nil {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		if !requiresSignedLink(fileInfo) {
+			return "", nil
+		}
+		if user, err := s.getUserFromSession(r); err == nil && canAccessFileDirectly(user, fileInfo) {
+			return "", nil
+		}
+		log.Printf("Rejected plain link for file %s from %s: the file was emailed with a signed link", fileInfo.Id, clientIP(r))
+		return "", email.ErrShareLinkMissing
+	}
+
+	if _, err := email.VerifyShareLinkToken(token, fileInfo.Id); err != nil {
+		log.Printf("Rejected share link for file %s from %s: %v", fileInfo.Id, clientIP(r), err)
+		if !errors.Is(err, email.ErrShareLinkExpired) {
+			return "", email.ErrShareLinkInvalid
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// verifyShareLink checks the share link of a splash page or download request, see
+// checkShareLink, and keeps a valid token for the file's other pages
+func (s *Server) verifyShareLink(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo) error {
+	token, err := s.checkShareLink(r, fileInfo)
+	if err != nil || token == "" {
+		return err
+	}
+
+	for _, path := range []string{"/s/", "/d/", "/preview/"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     shareLinkCookie + fileInfo.Id,
+			Value:    token,
+			Path:     path + fileInfo.Id,
+			Expires:  time.Now().Add(24 * time.Hour),
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return nil
+}
+
+// shareLinkRecipientAllowed reports whether emailAddr may log in to download a file that was
+// opened through a signed share link sent to a specific recipient
+func shareLinkRecipientAllowed(r *http.Request, fileID, emailAddr string) bool {
+	cookie, err := r.Cookie(shareLinkCookie + fileID)
+	if err != nil {
+		return true
+	}
+	recipientHash, err := email.VerifyShareLinkToken(cookie.Value, fileID)
+	if err != nil {
+		return false
+	}
+	return email.ShareLinkRecipientMatches(recipientHash, emailAddr)
+}
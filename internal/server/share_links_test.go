@@ -0,0 +1,143 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// emailShareLink signs a link to fileID the way an emailed link is, and returns its token
+func emailShareLink(t *testing.T, fileID, recipient string) string {
+	t.Helper()
+	link, err := email.ShareLinkURL("http://localhost:8080", fileID, recipient)
+	if err != nil {
+		t.Fatalf("ShareLinkURL: %v", err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", link, err)
+	}
+	token := parsed.Query().Get(email.ShareLinkTokenParam)
+	if token == "" {
+		t.Fatalf("link %q is not signed", link)
+	}
+	return token
+}
+
+// expiredShareLinkToken signs a token for fileID that expired an hour ago
+func expiredShareLinkToken(t *testing.T, fileID string) string {
+	t.Helper()
+	secret, err := database.DB.GetConfigValue("email_link_secret")
+	if err != nil || secret == "" {
+		t.Fatalf("no share link secret: %v", err)
+	}
+	expiresAt := time.Now().Add(-time.Hour).Unix()
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s|%d|%s", fileID, expiresAt, "")
+	return fmt.Sprintf("%d..%s", expiresAt, hex.EncodeToString(mac.Sum(nil)[:16]))
+}
+
+// shareLinkRequest requests path, with a share link token if token is set
+func shareLinkRequest(handler http.HandlerFunc, path, token string) *httptest.ResponseRecorder {
+	if token != "" {
+		path += "?" + email.ShareLinkTokenParam + "=" + url.QueryEscape(token)
+	}
+	return serve(handler, httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+// Once a file was emailed with a signed link, stripping or replacing the token must not get
+// around the link's expiry and recipient
+func TestShareLinkRequiredOnceEmailed(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "emailed", []byte("hello"), nil)
+	createTestFile(t, owner, "plain", []byte("hello"), nil)
+
+	token := emailShareLink(t, "emailed", "bob@example.com")
+	expired := expiredShareLinkToken(t, "emailed")
+
+	splash := shareLinkRequest(s.handleSplashPage, "/s/emailed", token).Body.String()
+	for _, refused := range []string{"Personal Link Required", "Link Expired", "Invalid Link"} {
+		if strings.Contains(splash, refused) {
+			t.Errorf("splash page with the emailed token: %q shown", refused)
+		}
+	}
+	if w := shareLinkRequest(s.handleDownload, "/d/emailed", token); w.Code != http.StatusOK {
+		t.Errorf("download with the emailed token: status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	for name, tc := range map[string]struct {
+		token, splash string
+		status        int
+	}{
+		"tokenless": {"", "Personal Link Required", http.StatusForbidden},
+		"expired":   {expired, "Link Expired", http.StatusGone},
+		"altered":   {token + "0", "Invalid Link", http.StatusForbidden},
+	} {
+		if w := shareLinkRequest(s.handleSplashPage, "/s/emailed", tc.token); !strings.Contains(w.Body.String(), tc.splash) {
+			t.Errorf("%s splash page: %q not shown", name, tc.splash)
+		}
+		if w := shareLinkRequest(s.handleDownload, "/d/emailed", tc.token); w.Code != tc.status {
+			t.Errorf("%s download: status %d, want %d", name, w.Code, tc.status)
+		}
+	}
+	if got := getFile(t, "emailed").DownloadCount; got != 1 {
+		t.Errorf("DownloadCount = %d, want 1", got)
+	}
+
+	// Files that were never emailed keep their plain links
+	if w := shareLinkRequest(s.handleDownload, "/d/plain", ""); w.Code != http.StatusOK {
+		t.Errorf("plain link of a file that wasn't emailed: status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// Public ZIP downloads leave the emailed file out
+	w := serve(s.handleFilesZip, httptest.NewRequest(http.MethodGet, "/d/zip?ids=emailed", nil))
+	if w.Code == http.StatusOK {
+		t.Errorf("ZIP of the emailed file: status %d, want it refused", w.Code)
+	}
+}
+
+// The token of the splash page visit is kept for the download, which doesn't carry it
+func TestShareLinkKeptForDownload(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "emailed", []byte("hello"), nil)
+	token := emailShareLink(t, "emailed", "")
+
+	splash := shareLinkRequest(s.handleSplashPage, "/s/emailed", token)
+	r := withCookies(httptest.NewRequest(http.MethodGet, "/d/emailed", nil), splash)
+	if w := serve(s.handleDownload, r); w.Code != http.StatusOK {
+		t.Errorf("download after the splash page: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// Plain links keep working when allowed in settings
+func TestShareLinkPlainAllowed(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "emailed", []byte("hello"), nil)
+	emailShareLink(t, "emailed", "bob@example.com")
+
+	if err := database.DB.SetConfigValue("email_link_allow_plain", "true"); err != nil {
+		t.Fatalf("SetConfigValue: %v", err)
+	}
+	if w := shareLinkRequest(s.handleDownload, "/d/emailed", ""); w.Code != http.StatusOK {
+		t.Errorf("plain link with plain links allowed: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
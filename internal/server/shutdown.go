@@ -0,0 +1,36 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"context"
+	"log"
+)
+
+// Shutdown stops accepting connections and waits for in-flight requests, such as uploads and
+// downloads, to finish or for ctx to be done. Start returns once it is called.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.httpServerMu.Lock()
+	server := s.httpServer
+	s.httpServerMu.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	log.Printf("⏳ Waiting for in-flight requests to finish...")
+	return server.Shutdown(ctx)
+}
+
+// ShutdownRequested is closed when an admin asks for a restart and no service manager
+// restarts the server, so main shuts it down gracefully and a process manager starts it again
+func (s *Server) ShutdownRequested() <-chan struct{} {
+	return s.shutdownRequested
+}
+
+// requestShutdown asks main to shut the server down gracefully
+func (s *Server) requestShutdown() {
+	s.shutdownOnce.Do(func() { close(s.shutdownRequested) })
+}
@@ -0,0 +1,347 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// Formats of the file access records sent to a SIEM
+const (
+	siemFormatCEF  = "cef"
+	siemFormatLEEF = "leef"
+)
+
+// Events sent to the SIEM, used as the CEF signature ID and LEEF event ID
+const (
+	siemEventDownload   = "file_download"
+	siemEventSplashView = "splash_view"
+	siemEventAuth       = "download_auth"
+)
+
+var siemEventNames = map[string]string{
+	siemEventDownload:   "File downloaded",
+	siemEventSplashView: "Share link opened",
+	siemEventAuth:       "Download authentication",
+}
+
+// How a visitor was authenticated when accessing a file
+const (
+	siemAuthAnonymous       = "anonymous"
+	siemAuthPassword        = "file_password"
+	siemAuthDownloadAccount = "download_account"
+	siemAuthUser            = "user"
+)
+
+// siemQueueSize is how many records may wait for delivery. Records are dropped when the
+// destination can't keep up, so a slow SIEM never holds up downloads.
+const siemQueueSize = 1000
+
+// siemEvent is one file access as sent to the SIEM
+type siemEvent struct {
+	Event      string
+	Success    bool
+	Time       time.Time
+	FileId     string
+	FileName   string
+	OwnerId    int
+	User       string // email of the downloader, if known
+	IP         string // empty when the file's download log is private
+	UserAgent  string
+	Country    string
+	AuthStatus string
+}
+
+// getSIEMFormat returns the configured record format, or "" if SIEM logging is off
+func getSIEMFormat() string {
+	format, _ := database.DB.GetConfigValue("siem_format")
+	if format != siemFormatCEF && format != siemFormatLEEF {
+		return ""
+	}
+	return format
+}
+
+// getSIEMTarget returns where records are sent: udp://host:port or tcp://host:port for syslog,
+// or an http(s) URL that records are POSTed to
+func getSIEMTarget() string {
+	target, _ := database.DB.GetConfigValue("siem_target")
+	return strings.TrimSpace(target)
+}
+
+// validateSIEMTarget checks a SIEM destination entered in the settings
+func validateSIEMTarget(target string) error {
+	if target == "" {
+		return nil
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return errors.New("invalid SIEM destination")
+	}
+	switch parsed.Scheme {
+	case "udp", "tcp":
+		if _, port, err := net.SplitHostPort(parsed.Host); err != nil || port == "" {
+			return errors.New("syslog destinations need a host and port, e.g. udp://siem.example.com:514")
+		}
+	case "http", "https":
+		if parsed.Host == "" {
+			return errors.New("invalid SIEM destination URL")
+		}
+	default:
+		return errors.New("SIEM destination must start with udp://, tcp://, http:// or https://")
+	}
+	return nil
+}
+
+// siemAuthStatus returns how the visitor of a download was authenticated
+func siemAuthStatus(fileInfo *database.FileInfo, account *models.DownloadAccount) string {
+	switch {
+	case account != nil:
+		return siemAuthDownloadAccount
+	case fileInfo.RequireAuth:
+		return siemAuthUser
+	case fileInfo.FilePasswordPlain != "":
+		return siemAuthPassword
+	}
+	return siemAuthAnonymous
+}
+
+// logSIEMEvent queues a file access for the SIEM if SIEM logging is on. The client IP is
+// resolved like in the audit log. Files whose download log is private are reported without
+// the visitor's IP, browser and email.
+func (s *Server) logSIEMEvent(r *http.Request, event string, fileInfo *database.FileInfo, success bool, authStatus, userEmail string) {
+	if getSIEMFormat() == "" || getSIEMTarget() == "" {
+		return
+	}
+
+	client := downloadClientFromRequest(r, fileInfo)
+	if client.ip == "" {
+		userEmail = ""
+	}
+	s.siem().enqueue(&siemEvent{
+		Event:      event,
+		Success:    success,
+		Time:       time.Now(),
+		FileId:     fileInfo.Id,
+		FileName:   fileInfo.Name,
+		OwnerId:    fileInfo.UserId,
+		User:       userEmail,
+		IP:         client.ip,
+		UserAgent:  client.userAgent,
+		Country:    client.country,
+		AuthStatus: authStatus,
+	})
+}
+
+// siemSender delivers records to the SIEM in the background
+type siemSender struct {
+	version string
+	queue   chan *siemEvent
+	conn    net.Conn
+	target  string // target of conn
+	client  *http.Client
+}
+
+var (
+	siemSenderOnce     sync.Once
+	siemSenderInstance *siemSender
+)
+
+// siem returns the sender of SIEM records, starting it on first use
+func (s *Server) siem() *siemSender {
+	siemSenderOnce.Do(func() {
+		siemSenderInstance = &siemSender{
+			version: s.config.Version,
+			queue:   make(chan *siemEvent, siemQueueSize),
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}
+		go siemSenderInstance.run()
+	})
+	return siemSenderInstance
+}
+
+func (ss *siemSender) enqueue(event *siemEvent) {
+	select {
+	case ss.queue <- event:
+	default:
+		log.Printf("SIEM queue full, dropped %s event for file %s", event.Event, event.FileId)
+	}
+}
+
+func (ss *siemSender) run() {
+	for event := range ss.queue {
+		format, target := getSIEMFormat(), getSIEMTarget()
+		if format == "" || target == "" {
+			continue
+		}
+
+		ownerEmail := ""
+		if owner, err := database.DB.GetUserByID(event.OwnerId); err == nil {
+			ownerEmail = owner.Email
+		}
+
+		var record string
+		if format == siemFormatLEEF {
+			record = ss.formatLEEF(event, ownerEmail)
+		} else {
+			record = ss.formatCEF(event, ownerEmail)
+		}
+		if err := ss.send(target, record); err != nil {
+			log.Printf("Failed to send %s event for file %s to SIEM: %v", event.Event, event.FileId, err)
+		}
+	}
+}
+
+// send delivers a record over syslog (RFC 5424, newline-framed over TCP) or as an HTTP POST
+func (ss *siemSender) send(target, record string) error {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Scheme == "http" || parsed.Scheme == "https" {
+		resp, err := ss.client.Post(target, "text/plain; charset=utf-8", bytes.NewBufferString(record))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("SIEM returned %s", resp.Status)
+		}
+		return nil
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	// Facility local0, severity informational
+	message := fmt.Sprintf("<134>1 %s %s WulfVault - - - %s\n", time.Now().UTC().Format(time.RFC3339), hostname, record)
+
+	// Reconnect once if a kept-open connection has gone away
+	for attempt := 0; attempt < 2; attempt++ {
+		if ss.conn == nil || ss.target != target {
+			if ss.conn != nil {
+				ss.conn.Close()
+			}
+			ss.conn, err = net.DialTimeout(parsed.Scheme, parsed.Host, 10*time.Second)
+			if err != nil {
+				ss.conn = nil
+				return err
+			}
+			ss.target = target
+		}
+		ss.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err = ss.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		ss.conn.Close()
+		ss.conn = nil
+	}
+	return err
+}
+
+// siemSeverity is 3 (low) for normal access and 6 (medium) for failed authentication
+func siemSeverity(event *siemEvent) int {
+	if !event.Success {
+		return 6
+	}
+	return 3
+}
+
+func siemOutcome(event *siemEvent) string {
+	if event.Success {
+		return "success"
+	}
+	return "failure"
+}
+
+// cefHeaderEscaper and cefValueEscaper escape CEF header fields and extension values
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// formatCEF formats an event as an ArcSight Common Event Format record
+func (ss *siemSender) formatCEF(event *siemEvent, ownerEmail string) string {
+	extensions := []struct{ key, value string }{
+		{"rt", strconv.FormatInt(event.Time.UnixMilli(), 10)},
+		{"outcome", siemOutcome(event)},
+		{"src", event.IP},
+		{"suser", event.User},
+		{"fname", event.FileName},
+		{"requestClientApplication", event.UserAgent},
+	}
+	// Fields without a CEF key go into the labelled custom strings cs1-cs4
+	customStrings := []struct{ label, value string }{
+		{"fileId", event.FileId},
+		{"owner", ownerEmail},
+		{"country", event.Country},
+		{"authStatus", event.AuthStatus},
+	}
+
+	parts := []string{}
+	for _, ext := range extensions {
+		if ext.value != "" {
+			parts = append(parts, ext.key+"="+cefValueEscaper.Replace(ext.value))
+		}
+	}
+	for i, cs := range customStrings {
+		if cs.value != "" {
+			key := "cs" + strconv.Itoa(i+1)
+			parts = append(parts, key+"Label="+cs.label, key+"="+cefValueEscaper.Replace(cs.value))
+		}
+	}
+
+	return fmt.Sprintf("CEF:0|Frimurare|WulfVault|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(ss.version), event.Event, cefHeaderEscaper.Replace(siemEventNames[event.Event]),
+		siemSeverity(event), strings.Join(parts, " "))
+}
+
+// leefEscaper keeps values from breaking the tab-separated LEEF attributes
+var leefEscaper = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ", "|", "/")
+
+// formatLEEF formats an event as an IBM QRadar Log Event Extended Format 1.0 record
+func (ss *siemSender) formatLEEF(event *siemEvent, ownerEmail string) string {
+	attributes := []struct{ key, value string }{
+		{"devTime", event.Time.UTC().Format("Jan 02 2006 15:04:05 MST")},
+		{"devTimeFormat", "MMM dd yyyy HH:mm:ss z"},
+		{"cat", siemEventNames[event.Event]},
+		{"sev", strconv.Itoa(siemSeverity(event))},
+		{"outcome", siemOutcome(event)},
+		{"src", event.IP},
+		{"usrName", event.User},
+		{"fileId", event.FileId},
+		{"fileName", event.FileName},
+		{"owner", ownerEmail},
+		{"country", event.Country},
+		{"authStatus", event.AuthStatus},
+		{"userAgent", event.UserAgent},
+	}
+
+	parts := []string{}
+	for _, attr := range attributes {
+		if attr.value != "" {
+			parts = append(parts, attr.key+"="+leefEscaper.Replace(attr.value))
+		}
+	}
+
+	return fmt.Sprintf("LEEF:1.0|Frimurare|WulfVault|%s|%s|%s",
+		leefEscaper.Replace(ss.version), event.Event, strings.Join(parts, "\t"))
+}
@@ -0,0 +1,154 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/i18n"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// noticeAcceptedCookiePrefix is followed by the file ID. The cookie holds when the notice was accepted.
+const noticeAcceptedCookiePrefix = "notice_accepted_"
+
+// maxLegalNoticeLength is the longest legal notice an admin can save
+const maxLegalNoticeLength = 5000
+
+// splashTemplateLabels are the names of the splash page templates shown to file owners
+var splashTemplateLabels = map[string]string{
+	database.SplashTemplateDetailed:    "Detailed - file details, preview and poem",
+	database.SplashTemplateMinimal:     "Minimal - just the file and the download button",
+	database.SplashTemplateLegalNotice: "Legal notice - recipients must click \"I agree\" before downloading",
+}
+
+// splashTemplateOptionsHTML renders the <option>s of a splash page template select
+func splashTemplateOptionsHTML(selected string) string {
+	html := ""
+	for _, tmpl := range database.SplashTemplates {
+		attrs := ""
+		if tmpl == selected {
+			attrs = " selected"
+		}
+		html += `<option value="` + tmpl + `"` + attrs + `>` + template.HTMLEscapeString(splashTemplateLabels[tmpl]) + `</option>`
+	}
+	return html
+}
+
+// requiresLegalNotice reports whether recipients must agree to the legal notice before downloading the file
+func requiresLegalNotice(fileInfo *database.FileInfo) bool {
+	return database.DB.GetFileSplashTemplate(fileInfo.Id) == database.SplashTemplateLegalNotice
+}
+
+// legalNoticeText returns the legal notice recipients agree to: the text set by an admin, or the
+// default notice in the recipient's language
+func legalNoticeText(locale i18n.Locale) string {
+	if text := legalNoticeSetting(); text != "" {
+		return text
+	}
+	return i18n.T(locale.Code, "notice.text")
+}
+
+// legalNoticeSetting returns the legal notice set by an admin, or "" if none is set
+func legalNoticeSetting() string {
+	text, _ := database.DB.GetConfigValue("legal_notice_text")
+	return strings.TrimSpace(text)
+}
+
+// normalizeLegalNotice trims a legal notice and checks it is plain text of at most maxLegalNoticeLength characters
+func normalizeLegalNotice(text string) (string, error) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if !utf8.ValidString(text) {
+		return "", errors.New("the notice contains invalid characters")
+	}
+	if utf8.RuneCountInString(text) > maxLegalNoticeLength {
+		return "", fmt.Errorf("the notice can be at most %d characters", maxLegalNoticeLength)
+	}
+	for _, r := range text {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return "", errors.New("the notice contains invalid characters")
+		}
+	}
+	return text, nil
+}
+
+// noticeAcceptedAt returns when the downloader agreed to the file's legal notice. Acceptance comes
+// from the splash page (?accept_notice=1) or the cookie it leaves behind.
+func noticeAcceptedAt(r *http.Request, fileInfo *database.FileInfo) (int64, bool) {
+	if !requiresLegalNotice(fileInfo) {
+		return 0, false
+	}
+
+	if r.URL.Query().Get("accept_notice") == "1" {
+		return time.Now().Unix(), true
+	}
+
+	cookie, err := r.Cookie(noticeAcceptedCookiePrefix + fileInfo.Id)
+	if err != nil {
+		return 0, false
+	}
+	acceptedAt, err := strconv.ParseInt(cookie.Value, 10, 64)
+	if err != nil || acceptedAt <= 0 {
+		return 0, false
+	}
+	return acceptedAt, true
+}
+
+// checkLegalNotice makes sure the legal notice was accepted before a download of a file that shows
+// one. Without acceptance the recipient is sent back to the splash page, which shows the notice.
+func (s *Server) checkLegalNotice(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo) bool {
+	if !requiresLegalNotice(fileInfo) {
+		return true
+	}
+
+	acceptedAt, ok := noticeAcceptedAt(r, fileInfo)
+	if !ok {
+		http.Redirect(w, r, "/s/"+fileInfo.Id, http.StatusSeeOther)
+		return false
+	}
+
+	// Remember the acceptance for the password and login steps that may follow
+	if r.URL.Query().Get("accept_notice") != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     noticeAcceptedCookiePrefix + fileInfo.Id,
+			Value:    strconv.FormatInt(acceptedAt, 10),
+			Path:     "/d/" + fileInfo.Id,
+			Expires:  time.Now().Add(24 * time.Hour),
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return true
+}
+
+// recordNoticeAcceptance adds the recipient's acceptance of the legal notice to a download log
+// entry. The address is the one recorded for the download, so it stays out of private download logs.
+func recordNoticeAcceptance(r *http.Request, fileInfo *database.FileInfo, client downloadClient, downloadLog *models.DownloadLog) {
+	if acceptedAt, ok := noticeAcceptedAt(r, fileInfo); ok {
+		downloadLog.NoticeAcceptedAt = acceptedAt
+		downloadLog.NoticeAcceptedIP = client.ip
+	}
+}
+
+// renderLegalNoticeSection renders the legal notice and its "I agree" button for the splash page
+func renderLegalNoticeSection(text, title, agreeLabel, agreedLabel, primaryColor string) string {
+	return `
+        <div style="margin: 25px 0; padding: 20px; background: #fff8e1; border-left: 4px solid #f57c00; border-radius: 8px; text-align: left;">
+            <h3 style="color: #e65100; font-size: 16px; margin-bottom: 10px;">⚖️ ` + title + `</h3>
+            <div style="max-height: 220px; overflow-y: auto; padding: 12px; background: white; border: 1px solid #e0e0e0; border-radius: 6px; color: #555; font-size: 14px; line-height: 1.6; white-space: pre-wrap;">` + template.HTMLEscapeString(text) + `</div>
+            <button type="button" id="agreeNotice" style="margin-top: 12px; padding: 10px 24px; background: ` + primaryColor + `; color: white; border: none; border-radius: 8px; font-size: 15px; font-weight: 600; cursor: pointer;">` + agreeLabel + `</button>
+            <span id="noticeAgreed" style="display: none; margin-top: 12px; color: #2e7d32; font-size: 14px; font-weight: 600;">✓ ` + agreedLabel + `</span>
+        </div>`
+}
@@ -0,0 +1,197 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+const (
+	// splashViewerHeartbeat is how often an open splash page reports that it is still open
+	splashViewerHeartbeat = 15 * time.Second
+	// splashViewerTTL is how long a viewer counts after its last heartbeat
+	splashViewerTTL = 45 * time.Second
+	// splashViewerCookie identifies a browser, so reloading the page doesn't take another slot
+	splashViewerCookie = "splash_viewer"
+)
+
+var (
+	// splashViewers maps file ID -> viewer ID -> last heartbeat
+	splashViewers   = make(map[string]map[string]time.Time)
+	splashViewersMu sync.Mutex
+)
+
+// pruneSplashViewersLocked forgets viewers whose heartbeat stopped. splashViewersMu must be held.
+func pruneSplashViewersLocked(now time.Time) {
+	for fileID, viewers := range splashViewers {
+		for viewerID, lastSeen := range viewers {
+			if now.Sub(lastSeen) > splashViewerTTL {
+				delete(viewers, viewerID)
+			}
+		}
+		if len(viewers) == 0 {
+			delete(splashViewers, fileID)
+		}
+	}
+}
+
+// admitSplashViewer records a viewer of a file's splash page. A viewer that is already counted
+// is refreshed; a new viewer is only admitted while fewer than maxViewers are active (0 = no limit).
+func admitSplashViewer(fileID, viewerID string, maxViewers int) bool {
+	now := time.Now()
+	splashViewersMu.Lock()
+	defer splashViewersMu.Unlock()
+
+	pruneSplashViewersLocked(now)
+
+	viewers := splashViewers[fileID]
+	if _, active := viewers[viewerID]; !active && maxViewers > 0 && len(viewers) >= maxViewers {
+		return false
+	}
+	if viewers == nil {
+		viewers = make(map[string]time.Time)
+		splashViewers[fileID] = viewers
+	}
+	viewers[viewerID] = now
+	return true
+}
+
+// releaseSplashViewer frees a viewer's slot when the page is closed
+func releaseSplashViewer(fileID, viewerID string) {
+	splashViewersMu.Lock()
+	defer splashViewersMu.Unlock()
+
+	if viewers, ok := splashViewers[fileID]; ok {
+		delete(viewers, viewerID)
+		if len(viewers) == 0 {
+			delete(splashViewers, fileID)
+		}
+	}
+}
+
+// activeSplashViewers returns how many people have a file's splash page open right now
+func activeSplashViewers(fileID string) int {
+	now := time.Now()
+	splashViewersMu.Lock()
+	defer splashViewersMu.Unlock()
+
+	count := 0
+	for _, lastSeen := range splashViewers[fileID] {
+		if now.Sub(lastSeen) <= splashViewerTTL {
+			count++
+		}
+	}
+	return count
+}
+
+// splashViewerID returns the browser's viewer ID, setting the cookie if it has none yet
+func splashViewerID(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(splashViewerCookie); err == nil && len(cookie.Value) == 32 {
+		return cookie.Value
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return ""
+	}
+	viewerID := hex.EncodeToString(idBytes)
+	http.SetCookie(w, &http.Cookie{
+		Name:     splashViewerCookie,
+		Value:    viewerID,
+		Path:     "/",
+		Expires:  time.Now().Add(24 * time.Hour),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return viewerID
+}
+
+// checkSplashViewerLimit counts the request as a viewer of the file's splash page.
+// Returns false, after rendering a busy page that retries by itself, if the file's viewer limit is reached.
+func (s *Server) checkSplashViewerLimit(w http.ResponseWriter, r *http.Request, fileInfo *database.FileInfo) bool {
+	if !database.DB.IsSplashViewerTrackingEnabled() {
+		return true
+	}
+
+	viewerID := splashViewerID(w, r)
+	if viewerID == "" {
+		return true
+	}
+	if admitSplashViewer(fileInfo.Id, viewerID, database.DB.GetFileMaxViewers(fileInfo.Id)) {
+		return true
+	}
+
+	w.Header().Set("Refresh", "15")
+	s.renderSplashPageUnavailable(w, "👥", "Too Many Viewers", "This file is being viewed by the maximum number of people allowed at once. This page will try again automatically in a few seconds.")
+	return false
+}
+
+// splashViewerHeartbeatJS returns the script that keeps an open splash page counted as a viewer,
+// or "" when viewer tracking is off
+func splashViewerHeartbeatJS(fileID string) string {
+	if !database.DB.IsSplashViewerTrackingEnabled() {
+		return ""
+	}
+	return `
+    <script>
+        (function() {
+            const url = '/splash/heartbeat?id=' + encodeURIComponent('` + template.JSEscapeString(fileID) + `');
+            setInterval(function() {
+                fetch(url, { method: 'POST', credentials: 'same-origin' }).then(function(response) {
+                    // Our slot was given to someone else while the page was asleep
+                    if (response.status === 409) { window.location.reload(); }
+                }).catch(function() {});
+            }, ` + strconv.Itoa(int(splashViewerHeartbeat/time.Millisecond)) + `);
+            window.addEventListener('pagehide', function() {
+                navigator.sendBeacon(url + '&leave=1');
+            });
+        })();
+    </script>`
+}
+
+// handleSplashViewerHeartbeat keeps an open splash page counted as a viewer (/splash/heartbeat?id=FILE),
+// or frees its slot when called with leave=1
+func (s *Server) handleSplashViewerHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !database.DB.IsSplashViewerTrackingEnabled() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	cookie, err := r.Cookie(splashViewerCookie)
+	if err != nil {
+		http.Error(w, "No viewer session", http.StatusBadRequest)
+		return
+	}
+	fileInfo, err := database.DB.GetFileByID(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("leave") == "1" {
+		releaseSplashViewer(fileInfo.Id, cookie.Value)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if !admitSplashViewer(fileInfo.Id, cookie.Value, database.DB.GetFileMaxViewers(fileInfo.Id)) {
+		http.Error(w, "Viewer limit reached", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,114 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// storageChange is a user whose stored storage usage differs from what their files add up to
+type storageChange struct {
+	UserID   int    `json:"user_id"`
+	Email    string `json:"email"`
+	OldMB    int64  `json:"old_mb"`
+	NewMB    int64  `json:"new_mb"`
+	DiffMB   int64  `json:"diff_mb"`
+	Updated  bool   `json:"updated"`
+	ErrorMsg string `json:"error,omitempty"`
+}
+
+// handleAdminRecalculateStorage recounts every user's storage usage from their files and
+// reports who changed and by how much. With dry_run=1 nothing is written.
+func (s *Server) handleAdminRecalculateStorage(w http.ResponseWriter, r *http.Request) {
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	dryRun := r.FormValue("dry_run") == "1" || r.FormValue("dry_run") == "true"
+
+	users, err := database.DB.GetAllUsers()
+	if err != nil {
+		log.Printf("Error fetching users for storage recalculation: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+
+	changes := []storageChange{}
+	var totalDiffMB int64
+	failed := 0
+	for _, user := range users {
+		usedMB, err := database.DB.CalculateUserStorage(user.Id)
+		if err != nil {
+			log.Printf("Warning: Could not calculate storage for user %d: %v", user.Id, err)
+			changes = append(changes, storageChange{UserID: user.Id, Email: user.Email, OldMB: user.StorageUsedMB, ErrorMsg: "Failed to calculate storage"})
+			failed++
+			continue
+		}
+		if usedMB == user.StorageUsedMB {
+			continue
+		}
+
+		change := storageChange{
+			UserID: user.Id,
+			Email:  user.Email,
+			OldMB:  user.StorageUsedMB,
+			NewMB:  usedMB,
+			DiffMB: usedMB - user.StorageUsedMB,
+		}
+		if !dryRun {
+			if err := database.DB.UpdateUserStorage(user.Id, usedMB); err != nil {
+				log.Printf("Warning: Could not update storage for user %d: %v", user.Id, err)
+				change.ErrorMsg = "Failed to update storage"
+				failed++
+			} else {
+				change.Updated = true
+			}
+		}
+		totalDiffMB += change.DiffMB
+		changes = append(changes, change)
+	}
+
+	if dryRun {
+		log.Printf("Storage recalculation dry run by %s: %d of %d user(s) would change", admin.Email, len(changes), len(users))
+	} else {
+		log.Printf("Storage recalculated by %s: %d of %d user(s) changed", admin.Email, len(changes)-failed, len(users))
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionStorageRecalculated,
+		EntityType: database.EntitySystem,
+		EntityID:   "storage",
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"dry_run":       dryRun,
+			"users_checked": len(users),
+			"users_changed": len(changes) - failed,
+			"failed":        failed,
+			"total_diff_mb": totalDiffMB,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   failed == 0,
+	})
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"dry_run":       dryRun,
+		"users_checked": len(users),
+		"total_diff_mb": totalDiffMB,
+		"changes":       changes,
+	})
+}
@@ -0,0 +1,127 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// canManageTeam reports whether the user may add and remove members, change their roles and
+// delete the team: system admins and the team's owners
+func canManageTeam(user *models.User, teamId int) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	role, err := database.DB.GetTeamRole(teamId, user.Id)
+	return err == nil && role == models.TeamRoleOwner
+}
+
+// canShareFileToTeam reports whether the user may share the file with the team. Every role
+// may share, but only their own files, unless the user may edit other users' uploads.
+func canShareFileToTeam(user *models.User, file *database.FileInfo, teamId int) bool {
+	if file.UserId != user.Id && !user.HasPermissionEditOtherUploads() {
+		return false
+	}
+	role, err := database.DB.GetTeamRole(teamId, user.Id)
+	return err == nil && (&models.TeamMember{Role: role}).CanManageFiles()
+}
+
+// canUnshareFileFromTeam reports whether the user may remove the file from the team. Members
+// may only remove their own files; team owners and admins may remove any.
+func canUnshareFileFromTeam(user *models.User, file *database.FileInfo, teamId int) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	role, err := database.DB.GetTeamRole(teamId, user.Id)
+	if err != nil {
+		return false
+	}
+	member := &models.TeamMember{Role: role}
+	if file.UserId == user.Id {
+		return member.CanManageFiles()
+	}
+	return member.CanUnshareOthersFiles()
+}
+
+// handleAPITeamSetRole changes a member's role in a team (system admins and team owners)
+func (s *Server) handleAPITeamSetRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _ := userFromContext(r.Context())
+
+	var req struct {
+		TeamId int `json:"teamId"`
+		UserId int `json:"userId"`
+		Role   int `json:"role"` // 0=Owner, 1=Admin, 2=Member
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	role := models.TeamRole(req.Role)
+	if !role.IsValid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if !canManageTeam(user, req.TeamId) {
+		http.Error(w, "Only team owners can change roles", http.StatusForbidden)
+		return
+	}
+
+	member, err := database.DB.GetTeamMember(req.TeamId, req.UserId)
+	if err != nil {
+		http.Error(w, database.ErrNotTeamMember.Error(), http.StatusNotFound)
+		return
+	}
+	oldRole := member.Role
+
+	if err := database.DB.SetTeamRole(req.TeamId, req.UserId, role); err != nil {
+		if errors.Is(err, database.ErrLastTeamOwner) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Error changing team role: %v", err)
+		http.Error(w, "Error changing role", http.StatusInternalServerError)
+		return
+	}
+
+	if oldRole != role {
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     int64(user.Id),
+			UserEmail:  user.Email,
+			Action:     database.ActionTeamMemberRoleChanged,
+			EntityType: "TeamMember",
+			EntityID:   fmt.Sprintf("%d", req.TeamId),
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"team_id":    req.TeamId,
+				"user_id":    req.UserId,
+				"user_email": member.UserEmail,
+				"old_role":   oldRole.String(),
+				"new_role":   role.String(),
+			}),
+			IPAddress: clientIP(r),
+			UserAgent: r.UserAgent(),
+			Success:   true,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
@@ -0,0 +1,161 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// throttleChunkSize is how much a throttled download writes at a time, so the pauses between
+// writes stay short and concurrent downloads of a file take turns
+const throttleChunkSize = 32 * 1024
+
+// bandwidthLimiter is a token bucket shared by all downloads of one file, so the file's limit
+// caps their total rate. Downloads take tokens before they write; when the bucket runs dry
+// they reserve tokens ahead and wait until those would have been refilled.
+type bandwidthLimiter struct {
+	mu          sync.Mutex
+	bytesPerSec float64
+	available   float64 // tokens in the bucket, negative while downloads wait for reserved tokens
+	last        time.Time
+
+	users int // downloads using the limiter, guarded by fileLimitersMu
+}
+
+var (
+	// fileLimiters holds the limiters of files that are being downloaded, keyed by file ID
+	fileLimiters   = make(map[string]*bandwidthLimiter)
+	fileLimitersMu sync.Mutex
+)
+
+// downloadLimitBytesPerSec returns the download bandwidth limit of a file: its own limit if it
+// has one, otherwise the global limit (0 = no limit)
+func downloadLimitBytesPerSec(fileInfo *database.FileInfo) int64 {
+	limitMBps := fileInfo.DownloadLimitMBps
+	if limitMBps <= 0 {
+		limitMBps = database.DB.GetConfigInt("download_max_mbps", 0)
+	}
+	if limitMBps <= 0 {
+		return 0
+	}
+	return int64(limitMBps) * 1024 * 1024
+}
+
+// acquireDownloadLimiter returns the limiter shared by the downloads of a file and a function
+// that must be called when the download is done. It returns nil if the file has no limit.
+func acquireDownloadLimiter(fileInfo *database.FileInfo) (*bandwidthLimiter, func()) {
+	bytesPerSec := downloadLimitBytesPerSec(fileInfo)
+	if bytesPerSec == 0 {
+		return nil, func() {}
+	}
+
+	fileLimitersMu.Lock()
+	defer fileLimitersMu.Unlock()
+
+	limiter, ok := fileLimiters[fileInfo.Id]
+	if !ok {
+		limiter = &bandwidthLimiter{last: time.Now()}
+		fileLimiters[fileInfo.Id] = limiter
+	}
+	limiter.users++
+	// A changed limit applies to downloads already running
+	limiter.setRate(float64(bytesPerSec))
+
+	return limiter, func() {
+		fileLimitersMu.Lock()
+		defer fileLimitersMu.Unlock()
+		limiter.users--
+		if limiter.users == 0 {
+			delete(fileLimiters, fileInfo.Id)
+		}
+	}
+}
+
+func (l *bandwidthLimiter) setRate(bytesPerSec float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bytesPerSec = bytesPerSec
+}
+
+// wait takes n tokens from the bucket and waits until they are available, or until the
+// download is cancelled
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	// The bucket holds a tenth of a second of data, so an idle file can't burst much above its limit
+	burst := math.Max(l.bytesPerSec/10, throttleChunkSize)
+	l.available = math.Min(l.available+now.Sub(l.last).Seconds()*l.bytesPerSec, burst)
+	l.last = now
+	l.available -= float64(n)
+	delay := time.Duration(-l.available / l.bytesPerSec * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// write writes b to dst in chunks, waiting for the limiter before each one
+func (l *bandwidthLimiter) write(ctx context.Context, dst io.Writer, b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := b[:min(len(b), throttleChunkSize)]
+		if err := l.wait(ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := dst.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// throttledWriter wraps a ResponseWriter and limits how fast the body is sent. It has no
+// ReadFrom, so the sendfile fast path, which would bypass the limit, is not used.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *bandwidthLimiter
+}
+
+func newThrottledWriter(w http.ResponseWriter, r *http.Request, limiter *bandwidthLimiter) *throttledWriter {
+	return &throttledWriter{ResponseWriter: w, ctx: r.Context(), limiter: limiter}
+}
+
+func (t *throttledWriter) Write(b []byte) (int, error) {
+	return t.limiter.write(t.ctx, t.ResponseWriter, b)
+}
+
+func (t *throttledWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writerFunc turns a function into an io.Writer
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
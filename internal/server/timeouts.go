@@ -0,0 +1,65 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// transferPaths are the routes that upload or download files. The server's ReadTimeout and
+// WriteTimeout would cut these off on slow connections, so they get the transfer timeout instead.
+// Chunk uploads and assembling the last chunk can both take long for multi-gigabyte files.
+var transferPaths = []string{
+	"/d/",
+	"/preview/",
+	"/api/v1/download/",
+	"/teams/download-zip",
+	"/upload",
+	"/upload-request/",
+	"/api/upload/chunk",
+	"/api/upload/complete",
+	"/file/versions/upload",
+	"/api/v1/upload",
+	"/api/v1/user/export-data",
+	"/admin/files/export",
+	"/api/v1/admin/audit-logs/export",
+	"/api/v1/admin/server-logs/export",
+	"/admin/backup/download",
+	"/admin/backup/restore",
+}
+
+// isTransferPath reports whether a request path uploads or downloads a file
+func isTransferPath(path string) bool {
+	for _, prefix := range transferPaths {
+		if path == prefix || (strings.HasSuffix(prefix, "/") && strings.HasPrefix(path, prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// transferTimeoutMiddleware extends the read and write deadlines of file uploads and downloads
+// to the transfer timeout. It must wrap the handlers that replace the ResponseWriter, since
+// deadlines can only be changed through the server's own writer.
+func (s *Server) transferTimeoutMiddleware(next http.Handler) http.Handler {
+	timeout := s.config.HTTPTimeouts.TransferTimeout()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isTransferPath(r.URL.Path) {
+			deadline := time.Now().Add(timeout)
+			rc := http.NewResponseController(w)
+			if err := rc.SetReadDeadline(deadline); err != nil {
+				log.Printf("Could not extend read deadline of %s: %v", r.URL.Path, err)
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil {
+				log.Printf("Could not extend write deadline of %s: %v", r.URL.Path, err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
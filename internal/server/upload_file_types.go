@@ -0,0 +1,174 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// fileTypeSniffLen is how much of a file http.DetectContentType looks at
+const fileTypeSniffLen = 512
+
+// sniffedTypesByExtension lists, for extensions whose content http.DetectContentType recognizes
+// reliably, the type their content sniffs as. Other extensions are only checked against the
+// allow and block lists, except text extensions, whose content must sniff as text.
+var sniffedTypesByExtension = map[string]string{
+	"jpg": "image/jpeg", "jpeg": "image/jpeg", "jpe": "image/jpeg",
+	"png": "image/png", "gif": "image/gif", "webp": "image/webp", "bmp": "image/bmp", "ico": "image/x-icon",
+	"pdf": "application/pdf",
+	"zip": "application/zip", "docx": "application/zip", "xlsx": "application/zip", "pptx": "application/zip",
+	"odt": "application/zip", "ods": "application/zip", "odp": "application/zip", "epub": "application/zip",
+	"gz": "application/x-gzip", "tgz": "application/x-gzip", "rar": "application/x-rar-compressed",
+	"webm": "video/webm", "avi": "video/avi", "wav": "audio/wave", "ogg": "application/ogg", "wasm": "application/wasm",
+}
+
+var textExtensions = map[string]bool{
+	"txt": true, "csv": true, "tsv": true, "md": true, "log": true, "json": true, "xml": true,
+	"html": true, "htm": true, "ini": true, "yaml": true, "yml": true,
+}
+
+// uploadFileTypeError is why a file may not be uploaded, worded for the uploader
+type uploadFileTypeError struct {
+	message string
+}
+
+func (e *uploadFileTypeError) Error() string { return e.message }
+
+func fileTypeRejected(format string, args ...interface{}) error {
+	return &uploadFileTypeError{message: fmt.Sprintf(format, args...)}
+}
+
+// uploadFileTypeStatus returns the HTTP status for an error of checkUploadedFileType: 415 if the
+// file type is refused, 500 if the file couldn't be read
+func uploadFileTypeStatus(err error) int {
+	var typeErr *uploadFileTypeError
+	if errors.As(err, &typeErr) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// fileExtension returns a file name's last extension in lower case, without the dot
+func fileExtension(filename string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// baseMIMEType returns a MIME type without its parameters, in lower case
+func baseMIMEType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+// mimeTypeMatches reports whether a MIME type matches one of the patterns, where "image/*"
+// matches every image type
+func mimeTypeMatches(mimeType string, patterns []string) bool {
+	if mimeType == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern == mimeType || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUploadFileType checks a file against the allowed and blocked file types in the server
+// settings, using its name and the first bytes of its content. With head nil only the name is
+// checked, so uploads sent in chunks are refused before any data is sent; the content is
+// checked once it has arrived.
+func checkUploadFileType(filename string, head []byte) error {
+	policy := database.DB.GetUploadFileTypePolicy()
+	if !policy.Active() {
+		return nil
+	}
+
+	ext := fileExtension(filename)
+	if ext != "" && slices.Contains(policy.BlockedExtensions, ext) {
+		return fileTypeRejected(".%s files can't be uploaded on this server", ext)
+	}
+	if len(policy.AllowedExtensions) > 0 && !slices.Contains(policy.AllowedExtensions, ext) {
+		allowed := "." + strings.Join(policy.AllowedExtensions, ", .")
+		if ext == "" {
+			return fileTypeRejected("Files without an extension can't be uploaded. Allowed file types: %s", allowed)
+		}
+		return fileTypeRejected(".%s files can't be uploaded. Allowed file types: %s", ext, allowed)
+	}
+
+	declaredType := ""
+	if ext != "" {
+		declaredType = baseMIMEType(mime.TypeByExtension("." + ext))
+	}
+	if mimeTypeMatches(declaredType, policy.BlockedMIMETypes) {
+		return fileTypeRejected("Files of type %s can't be uploaded on this server", declaredType)
+	}
+	if head == nil {
+		return nil
+	}
+
+	sniffedType := baseMIMEType(http.DetectContentType(head))
+	if mimeTypeMatches(sniffedType, policy.BlockedMIMETypes) {
+		return fileTypeRejected("Files of type %s can't be uploaded on this server", sniffedType)
+	}
+	if len(policy.AllowedMIMETypes) > 0 && !mimeTypeMatches(declaredType, policy.AllowedMIMETypes) && !mimeTypeMatches(sniffedType, policy.AllowedMIMETypes) {
+		return fileTypeRejected("Files of type %s can't be uploaded. Allowed types: %s", sniffedType, strings.Join(policy.AllowedMIMETypes, ", "))
+	}
+
+	// Catch renamed files, e.g. a program saved as report.pdf
+	if policy.VerifyContent && len(head) > 0 {
+		expected, known := sniffedTypesByExtension[ext]
+		mismatch := (known && sniffedType != expected) || (textExtensions[ext] && !strings.HasPrefix(sniffedType, "text/"))
+		if mismatch {
+			return fileTypeRejected("The content of %s doesn't match its .%s extension (it looks like %s). Renamed files can't be uploaded.", filename, ext, sniffedType)
+		}
+	}
+	return nil
+}
+
+// readFileHead returns the first bytes of an uploaded file for checkUploadFileType and rewinds it
+func readFileHead(file io.ReadSeeker) ([]byte, error) {
+	head := make([]byte, fileTypeSniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// checkUploadedFileType checks an uploaded file's name and content against the allowed and
+// blocked file types
+func checkUploadedFileType(filename string, file io.ReadSeeker) error {
+	head, err := readFileHead(file)
+	if err != nil {
+		return err
+	}
+	return checkUploadFileType(filename, head)
+}
+
+// head returns the first bytes of a chunked upload, from its first chunk
+func (upload *ChunkedUpload) head() ([]byte, error) {
+	f, err := os.Open(upload.partPath(0))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readFileHead(f)
+}
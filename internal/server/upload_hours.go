@@ -0,0 +1,109 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// uploadWindowClosedMessage returns why a user can't upload right now because of the global
+// upload window or their own, or "" if uploads are open. Admins can always upload.
+// Downloads are never affected by upload windows.
+func uploadWindowClosedMessage(user *models.User) string {
+	if user.IsAdmin() {
+		return ""
+	}
+
+	windows := []*database.FileSchedule{database.DB.GetGlobalUploadWindow()}
+	if window, err := database.DB.GetUserUploadWindow(user.Id); err == nil {
+		windows = append(windows, window)
+	}
+
+	closed := false
+	var opens time.Time
+	for _, window := range windows {
+		if window.IsEmpty() {
+			continue
+		}
+		available, next := window.Availability(time.Now())
+		if available {
+			continue
+		}
+		closed = true
+		// When several windows are closed, uploads open at the earliest when the last one does
+		if next.After(opens) {
+			opens = next
+		}
+	}
+
+	if !closed {
+		return ""
+	}
+	if opens.IsZero() {
+		return "Uploads are currently closed."
+	}
+	return "Uploads are currently closed. They open again " + opens.In(database.ServerLocation()).Format("Monday, January 2, 2006 at 15:04 MST") + "."
+}
+
+// uploadWindowFromForm reads the upload window fields of a settings form, named after prefix
+// (prefix_start, prefix_end, prefix_days and prefix_timezone)
+func uploadWindowFromForm(r *http.Request, prefix string) (*database.FileSchedule, error) {
+	window := &database.FileSchedule{
+		DailyStart: strings.TrimSpace(r.FormValue(prefix + "_start")),
+		DailyEnd:   strings.TrimSpace(r.FormValue(prefix + "_end")),
+		Timezone:   strings.TrimSpace(r.FormValue(prefix + "_timezone")),
+	}
+	window.Days = database.NormalizeScheduleDays(strings.Join(r.Form[prefix+"_days"], ","))
+	if err := window.Validate(); err != nil {
+		return nil, err
+	}
+	if window.IsEmpty() {
+		window.Timezone = ""
+	}
+	return window, nil
+}
+
+// uploadWindowFieldsHTML renders the inputs of an upload window for a settings form
+func uploadWindowFieldsHTML(prefix string, window *database.FileSchedule) string {
+	checkedDays := map[string]bool{}
+	for _, day := range strings.Split(window.Days, ",") {
+		checkedDays[day] = true
+	}
+
+	html := `
+                    <div style="display: flex; gap: 12px;">
+                        <div style="flex: 1;">
+                            <label for="` + prefix + `_start" style="font-weight: normal;">Daily from:</label>
+                            <input type="time" id="` + prefix + `_start" name="` + prefix + `_start" value="` + template.HTMLEscapeString(window.DailyStart) + `">
+                        </div>
+                        <div style="flex: 1;">
+                            <label for="` + prefix + `_end" style="font-weight: normal;">Daily until:</label>
+                            <input type="time" id="` + prefix + `_end" name="` + prefix + `_end" value="` + template.HTMLEscapeString(window.DailyEnd) + `">
+                        </div>
+                    </div>
+                    <div style="display: flex; flex-wrap: wrap; gap: 10px; margin: 8px 0; font-size: 13px;">`
+	for _, day := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		value := strconv.Itoa(int(day))
+		checked := ""
+		if checkedDays[value] {
+			checked = " checked"
+		}
+		html += `
+                        <label style="display: flex; align-items: center; gap: 4px; font-weight: normal;"><input type="checkbox" name="` + prefix + `_days" value="` + value + `"` + checked + ` style="width: auto; margin: 0;"> ` + day.String()[:3] + `</label>`
+	}
+	html += `
+                    </div>
+                    <label for="` + prefix + `_timezone" style="font-weight: normal;">Timezone:</label>
+                    <input type="text" id="` + prefix + `_timezone" name="` + prefix + `_timezone" value="` + template.HTMLEscapeString(window.Timezone) + `" placeholder="Server timezone (` + template.HTMLEscapeString(database.ServerLocation().String()) + `)">`
+	return html
+}
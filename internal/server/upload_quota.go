@@ -0,0 +1,173 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// uploadFormOverhead is how much a multipart upload may send on top of the file itself: the
+// other form fields and the multipart boundaries
+const uploadFormOverhead = 1 << 20
+
+// storageQuota is the storage usage of the account an upload counts against, returned with
+// uploads and with quota rejections
+type storageQuota struct {
+	UsedMB      int64 `json:"storage_used_mb"`
+	QuotaMB     int64 `json:"storage_quota_mb"`
+	RemainingMB int64 `json:"storage_remaining_mb"`
+}
+
+func storageQuotaOf(user *models.User) storageQuota {
+	quota := storageQuota{UsedMB: user.StorageUsedMB, QuotaMB: user.StorageQuotaMB}
+	if quota.QuotaMB > quota.UsedMB {
+		quota.RemainingMB = quota.QuotaMB - quota.UsedMB
+	}
+	return quota
+}
+
+// maxUploadBytes returns the size of the largest file that fits in a user's quota, or -1 if
+// none does. Files count in started MB (database.StorageMB), so exactly the remaining MBs fit.
+func maxUploadBytes(user *models.User) int64 {
+	if user.StorageQuotaMB <= 0 || user.StorageUsedMB > user.StorageQuotaMB {
+		return -1
+	}
+	return (user.StorageQuotaMB - user.StorageUsedMB) * database.BytesPerMB
+}
+
+// currentUploader reloads the user an upload counts against, so the check sees uploads that
+// finished since the session was loaded
+func currentUploader(user *models.User) *models.User {
+	if fresh, err := database.DB.GetUserByID(user.Id); err == nil {
+		return fresh
+	}
+	return user
+}
+
+// sendQuotaExceeded rejects an upload that doesn't fit in the uploader's storage quota with
+// 413 and the numbers involved. sizeBytes is 0 when only the request size is known.
+func (s *Server) sendQuotaExceeded(w http.ResponseWriter, user *models.User, sizeBytes int64) {
+	quota := storageQuotaOf(user)
+	message := fmt.Sprintf("Not enough storage space for this upload: only %d MB of your %d MB quota remain (%d MB used)", quota.RemainingMB, quota.QuotaMB, quota.UsedMB)
+	if sizeBytes > 0 {
+		message = fmt.Sprintf("Not enough storage space: the file needs %s, but only %d MB of your %d MB quota remain (%d MB used)",
+			database.FormatFileSize(sizeBytes), quota.RemainingMB, quota.QuotaMB, quota.UsedMB)
+	}
+	s.sendJSON(w, http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"error":                message,
+		"storage_used_mb":      quota.UsedMB,
+		"storage_quota_mb":     quota.QuotaMB,
+		"storage_remaining_mb": quota.RemainingMB,
+		"file_size":            sizeBytes,
+	})
+}
+
+// requestOwnerQuotaMessage is shown to someone uploading through a file request that doesn't
+// fit in the storage quota of the request's owner
+const requestOwnerQuotaMessage = "The recipient doesn't have enough storage space for this file"
+
+// limitUploadBody checks the size a multipart upload announces against the owner's quota
+// before anything is read, and caps the body so an upload that announced less (or nothing)
+// is cut off as soon as it is too large. Returns false if the upload was rejected.
+func limitUploadBody(w http.ResponseWriter, r *http.Request, owner *models.User) bool {
+	maxBytes := maxUploadBytes(owner)
+	if maxBytes < 0 || r.ContentLength > maxBytes+uploadFormOverhead {
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+uploadFormOverhead)
+	return true
+}
+
+// isBodyTooLarge reports whether reading a request failed because limitUploadBody cut it off
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// checkUploadTeamQuotas returns an error naming the first team among teamIds that has no room
+// for a new file of sizeBytes. Teams the user isn't a member of are skipped, as the upload
+// won't be shared with them.
+func checkUploadTeamQuotas(user *models.User, teamIds []int, sizeBytes int64) error {
+	for _, teamId := range teamIds {
+		if isMember, err := database.DB.IsTeamMember(teamId, user.Id); err != nil || !isMember {
+			continue
+		}
+		if err := database.DB.CheckTeamQuotaForSize(teamId, sizeBytes); errors.Is(err, database.ErrTeamQuotaExceeded) {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxFileSizeBytes returns the largest file the server accepts through upload requests, the
+// system-wide max_file_size_mb setting
+func (s *Server) maxFileSizeBytes() int64 {
+	maxMB := database.DB.GetConfigInt("max_file_size_mb", s.config.MaxFileSizeMB)
+	if maxMB <= 0 {
+		maxMB = s.config.MaxFileSizeMB
+	}
+	return int64(maxMB) * database.BytesPerMB
+}
+
+// fileRequestMaxBytes returns the largest file an upload request accepts: the request's own
+// limit, or the system-wide one if the request has none. A request can't go beyond the
+// system-wide limit, even if it was created before that limit was lowered.
+func (s *Server) fileRequestMaxBytes(fileRequest *models.FileRequest) int64 {
+	maxBytes := s.maxFileSizeBytes()
+	if fileRequest.MaxFileSize > 0 && fileRequest.MaxFileSize < maxBytes {
+		return fileRequest.MaxFileSize
+	}
+	return maxBytes
+}
+
+// checkFileRequestMaxSize returns why maxBytes can't be the size limit of an upload request,
+// or "" if it can. 0 means the request uses the system-wide limit.
+func (s *Server) checkFileRequestMaxSize(maxBytes int64) string {
+	if maxBytes < 0 {
+		return "Max file size can't be negative"
+	}
+	if systemMax := s.maxFileSizeBytes(); maxBytes > systemMax {
+		return "Max file size can't exceed the server limit of " + database.FormatFileSize(systemMax)
+	}
+	return ""
+}
+
+// fileTooLargeMessage tells an uploader the size limit a file went over
+func fileTooLargeMessage(maxBytes int64) string {
+	return "File too large. The maximum file size for this upload link is " + database.FormatFileSize(maxBytes)
+}
+
+// sendFileTooLarge rejects a file over an upload request's size limit with 413 and the limit
+func (s *Server) sendFileTooLarge(w http.ResponseWriter, maxBytes int64) {
+	s.sendJSON(w, http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"error":         fileTooLargeMessage(maxBytes),
+		"max_file_size": maxBytes,
+	})
+}
+
+// limitFileRequestBody caps a multipart upload to an upload request at the size of the largest
+// file it accepts plus the form overhead. Returns false if the announced size is already over.
+func limitFileRequestBody(w http.ResponseWriter, r *http.Request, maxBytes int64) bool {
+	if r.ContentLength > maxBytes+uploadFormOverhead {
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+uploadFormOverhead)
+	return true
+}
+
+// bodyLimitOf returns the limit a request body was cut off at, or 0 if err isn't a cut-off
+func bodyLimitOf(err error) int64 {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr.Limit
+	}
+	return 0
+}
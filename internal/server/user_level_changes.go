@@ -0,0 +1,86 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	emailpkg "github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// isPromotionNotificationEnabled reports whether admins are told when another user gets more
+// privileges. On unless turned off in the server settings.
+func isPromotionNotificationEnabled() bool {
+	value, _ := database.DB.GetConfigValue("notify_admins_on_promotion")
+	return value != "false"
+}
+
+// logUserLevelChange records a change of a user's level as USER_PROMOTED or USER_DEMOTED, next to
+// the general USER_UPDATED entry, and tells the other admins about promotions. Lower ranks have
+// more privileges. Does nothing if the level didn't change.
+func (s *Server) logUserLevelChange(admin *models.User, target *models.User, oldLevel models.UserRank, r *http.Request) {
+	if target.UserLevel == oldLevel {
+		return
+	}
+
+	before := (&models.User{UserLevel: oldLevel}).GetReadableUserLevel()
+	after := target.GetReadableUserLevel()
+	promoted := target.UserLevel < oldLevel
+
+	action := database.ActionUserDemoted
+	if promoted {
+		action = database.ActionUserPromoted
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     action,
+		EntityType: database.EntityUser,
+		EntityID:   fmt.Sprintf("%d", target.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"email":           target.Email,
+			"name":            target.Name,
+			"old_user_level":  int(oldLevel),
+			"new_user_level":  int(target.UserLevel),
+			"old_level_label": before,
+			"new_level_label": after,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	log.Printf("User %s changed from %s to %s by %s", target.Email, before, after, admin.Email)
+
+	if !promoted || !isPromotionNotificationEnabled() {
+		return
+	}
+
+	go func() {
+		users, err := database.DB.GetAllUsers()
+		if err != nil {
+			log.Printf("Could not list admins for promotion notification: %v", err)
+			return
+		}
+		message := fmt.Sprintf("%s (%s) was changed from %s to %s by %s", target.Name, target.Email, before, after, admin.Email)
+		for _, u := range users {
+			if !u.IsAdmin() || !u.IsActive || u.Id == admin.Id || u.Id == target.Id {
+				continue
+			}
+			if !database.DB.NotifyUser(u.Id, database.NotifyAdminReports, "User promoted", message, "/admin/users") {
+				continue
+			}
+			if err := emailpkg.SendUserPromotedEmail(u.Email, target.Name, target.Email, before, after, admin.Email, s.getPublicURL(), s.config.CompanyName); err != nil {
+				log.Printf("Failed to send promotion notification to %s: %v", u.Email, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,210 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/clamav"
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/email"
+	"github.com/Frimurare/WulfVault/internal/storage"
+)
+
+// virusScanTimeout stops a scan of a very large file or a clamd that stopped answering
+const virusScanTimeout = 15 * time.Minute
+
+// queueVirusScan scans an upload with ClamAV in the background. The file can't be downloaded
+// while the scan is pending; if clamd can't scan it, the file stays blocked until an admin
+// rescans it, unless admins chose to let such files through.
+func (s *Server) queueVirusScan(fileID string) {
+	if !database.DB.IsVirusScanningEnabled() {
+		return
+	}
+	if err := database.DB.SetFileScanStatus(fileID, database.ScanPending, ""); err != nil {
+		log.Printf("Warning: Could not mark %s for a virus scan: %v", fileID, err)
+		return
+	}
+
+	// The version is part of the key, so a new version uploaded during a scan is scanned too
+	key := fmt.Sprintf("scan:%s:v%d", fileID, database.DB.GetFileVersion(fileID))
+	fileProcessing.Submit(key, func() error {
+		result, err := scanStoredFile(fileID)
+		if err != nil {
+			if dbErr := database.DB.SetFileScanStatus(fileID, database.ScanError, ""); dbErr != nil {
+				log.Printf("Warning: Could not record virus scan error of %s: %v", fileID, dbErr)
+			}
+			return fmt.Errorf("virus scan of %s: %w", fileID, err)
+		}
+		if !result.Infected {
+			return database.DB.SetFileScanStatus(fileID, database.ScanClean, "")
+		}
+		s.quarantineFile(fileID, result.Signature)
+		return nil
+	})
+}
+
+// scanStoredFile streams a stored file to clamd
+func scanStoredFile(fileID string) (clamav.Result, error) {
+	content, err := storage.Files.Get(fileID)
+	if err != nil {
+		return clamav.Result{}, err
+	}
+	defer content.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), virusScanTimeout)
+	defer cancel()
+	return clamav.Scan(ctx, database.DB.GetClamAVAddress(), content)
+}
+
+// quarantineFile blocks downloads of a file the virus scan found malware in, or deletes it if
+// admins chose so, and tells the owner
+func (s *Server) quarantineFile(fileID, signature string) {
+	fileInfo, err := database.DB.GetFileByID(fileID)
+	if err != nil {
+		log.Printf("Error: Infected file %s is gone before it could be quarantined: %v", fileID, err)
+		return
+	}
+	if err := database.DB.SetFileScanStatus(fileID, database.ScanInfected, signature); err != nil {
+		log.Printf("Error: Could not quarantine infected file %s: %v", fileID, err)
+	}
+
+	action := "Quarantined: the file is kept, but downloads are blocked"
+	if database.DB.IsDeleteInfectedEnabled() {
+		action = "Deleted"
+		if err = storage.Files.Delete(fileID); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Could not delete infected file %s from storage: %v", fileID, err)
+		}
+		s.removeConvertedImages(fileID)
+		err = database.DB.PermanentDeleteFile(fileID)
+	}
+	errorMessage := ""
+	if err != nil {
+		log.Printf("Error: Could not delete infected file %s: %v", fileID, err)
+		errorMessage = err.Error()
+	}
+	log.Printf("Virus scan found %s in %s (%s): %s", signature, fileInfo.Name, fileID, action)
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     0,
+		UserEmail:  "system",
+		Action:     database.ActionFileInfected,
+		EntityType: database.EntityFile,
+		EntityID:   fileID,
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"file_name": fileInfo.Name,
+			"owner_id":  fileInfo.UserId,
+			"signature": signature,
+			"deleted":   action == "Deleted",
+		}),
+		Success:  err == nil,
+		ErrorMsg: errorMessage,
+	})
+
+	owner, err := database.DB.GetUserByID(fileInfo.UserId)
+	if err != nil {
+		log.Printf("Could not get file owner for malware notification: %v", err)
+		return
+	}
+	if !database.DB.NotifyUser(owner.Id, database.NotifySecurity, "Malware found",
+		fmt.Sprintf("The virus scan found %s in %s. %s.", signature, fileInfo.Name, action), "/dashboard") {
+		return
+	}
+	if err := email.SendFileInfectedEmail(owner.Email, fileInfo.Name, signature, action, s.getPublicURL(), s.config.CompanyName); err != nil {
+		log.Printf("Failed to send malware notification to %s: %v", owner.Email, err)
+	}
+}
+
+// fileScanUnavailableMessage returns why a file can't be downloaded because of its virus scan,
+// or "" if it may be downloaded
+func fileScanUnavailableMessage(fileInfo *database.FileInfo) string {
+	switch fileInfo.ScanStatus {
+	case database.ScanInfected:
+		return "This file was found to contain malware and has been quarantined."
+	case database.ScanPending:
+		return "This file is being scanned for viruses. Please try again in a moment."
+	case database.ScanError:
+		if !database.DB.IsScanFailOpenEnabled() {
+			return "This file could not be scanned for viruses yet. Please try again later."
+		}
+	}
+	return ""
+}
+
+// handleAdminVirusRescan scans the files clamd could not scan before again
+func (s *Server) handleAdminVirusRescan(w http.ResponseWriter, r *http.Request) {
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		s.sendError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !database.DB.IsVirusScanningEnabled() {
+		s.sendError(w, http.StatusBadRequest, "Virus scanning is turned off")
+		return
+	}
+
+	ids, err := database.DB.GetFileIDsByScanStatus(database.ScanError)
+	if err != nil {
+		log.Printf("Error fetching files to rescan: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to fetch files")
+		return
+	}
+	for _, id := range ids {
+		s.queueVirusScan(id)
+	}
+	log.Printf("Virus rescan of %d files started by %s", len(ids), admin.Email)
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"queued":  len(ids),
+	})
+}
+
+// scanStatusBadge returns the dashboard badge of a file's virus scan status, "" if it wasn't scanned
+func scanStatusBadge(fileInfo *database.FileInfo) string {
+	const style = `padding: 2px 8px; border-radius: 4px; font-size: 12px; margin-left: 8px;`
+	switch fileInfo.ScanStatus {
+	case database.ScanPending:
+		return `<span style="background: #607d8b; color: white; ` + style + `" title="Downloads are blocked until the scan is done">🔍 Scanning</span>`
+	case database.ScanClean:
+		return `<span style="background: #4caf50; color: white; ` + style + `" title="No malware found">🛡️ Scanned</span>`
+	case database.ScanInfected:
+		title := "Quarantined: " + database.DB.GetFileScanSignature(fileInfo.Id)
+		return `<span style="background: #c62828; color: white; ` + style + `" title="` + template.HTMLEscapeString(title) + `">🦠 Infected</span>`
+	case database.ScanError:
+		title := "clamd could not scan the file. Downloads are blocked until an admin rescans it"
+		if database.DB.IsScanFailOpenEnabled() {
+			title = "clamd could not scan the file"
+		}
+		return `<span style="background: #9e9e9e; color: white; ` + style + `" title="` + title + `">⚠️ Not Scanned</span>`
+	}
+	return ""
+}
+
+// virusScanningStatus describes the clamd connection for the admin settings page
+func virusScanningStatus() string {
+	address := database.DB.GetClamAVAddress()
+	if address == "" {
+		return "No clamd address configured."
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	version, err := clamav.Version(ctx, address)
+	if err != nil {
+		return "clamd is not reachable: " + err.Error()
+	}
+	return "Connected: " + version
+}
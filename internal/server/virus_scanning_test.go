@@ -0,0 +1,39 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+)
+
+// Files clamd could not scan are blocked unless admins let them through
+func TestUnscannedFileBlockedByDefault(t *testing.T) {
+	s := newTestServer(t)
+	owner := createTestUser(t, "owner@example.com", models.UserLevelUser, 1000)
+	createTestFile(t, owner, "unscanned", []byte("hello"), nil)
+	if err := database.DB.SetFileScanStatus("unscanned", database.ScanError, ""); err != nil {
+		t.Fatalf("SetFileScanStatus: %v", err)
+	}
+
+	if w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/unscanned", nil)); w.Code != http.StatusForbidden {
+		t.Errorf("download of an unscanned file: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if reason := s.zipSkipReason(getFile(t, "unscanned"), false); reason == "" {
+		t.Errorf("unscanned file not left out of ZIP downloads")
+	}
+
+	if err := database.DB.SetConfigValue("clamav_fail_open", "true"); err != nil {
+		t.Fatalf("SetConfigValue: %v", err)
+	}
+	if w := serve(s.handleDownload, httptest.NewRequest(http.MethodGet, "/d/unscanned", nil)); w.Code != http.StatusOK {
+		t.Errorf("download of an unscanned file with fail-open: status %d, want %d", w.Code, http.StatusOK)
+	}
+}
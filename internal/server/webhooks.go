@@ -0,0 +1,393 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+	"github.com/Frimurare/WulfVault/internal/models"
+	"github.com/Frimurare/WulfVault/internal/webhooks"
+)
+
+// validateWebhookURL checks an endpoint entered on the webhooks page
+func validateWebhookURL(endpoint string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return errors.New("invalid webhook URL")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("webhook URL must start with http:// or https://")
+	}
+	return nil
+}
+
+// dispatchDownloadWebhook sends the file.downloaded event of a counted download. Like the SIEM,
+// webhooks don't get the downloader's IP and email of files whose download log is private.
+func dispatchDownloadWebhook(fileInfo *database.FileInfo, client downloadClient, userEmail string) {
+	if client.ip == "" {
+		userEmail = ""
+	}
+	webhooks.Dispatch(database.WebhookEventFileDownloaded, fileInfo.Id, fileInfo.Name, userEmail, client.ip)
+}
+
+// handleAdminWebhooks lists webhooks (GET) or creates, pauses, tests or deletes one (POST)
+func (s *Server) handleAdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.renderAdminWebhooks(w, "", "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := userFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.FormValue("action") == "create" {
+		s.createWebhook(w, r, admin)
+		return
+	}
+
+	webhookID, err := strconv.Atoi(r.FormValue("webhook_id"))
+	if err != nil {
+		s.renderAdminWebhooks(w, "Error: Invalid webhook", "")
+		return
+	}
+	webhook, err := database.DB.GetWebhook(webhookID)
+	if err != nil {
+		s.renderAdminWebhooks(w, "Error: "+err.Error(), "")
+		return
+	}
+
+	var message string
+	action := database.ActionWebhookUpdated
+	switch r.FormValue("action") {
+	case "pause":
+		err = database.DB.SetWebhookActive(webhook.Id, false)
+		message = "Webhook paused. No events are sent to it until it is resumed."
+	case "resume":
+		err = database.DB.SetWebhookActive(webhook.Id, true)
+		message = "Webhook resumed."
+	case "delete":
+		err = database.DB.DeleteWebhook(webhook.Id)
+		action = database.ActionWebhookDeleted
+		message = "Webhook deleted."
+	case "test":
+		webhooks.SendPing(webhook)
+		s.renderAdminWebhooks(w, "Test event sent. Reload the page to see whether it was delivered.", "")
+		return
+	default:
+		s.renderAdminWebhooks(w, "Error: Unknown action", "")
+		return
+	}
+	if err != nil {
+		s.renderAdminWebhooks(w, "Error: "+err.Error(), "")
+		return
+	}
+
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     action,
+		EntityType: database.EntityWebhook,
+		EntityID:   strconv.Itoa(webhook.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"url":    webhook.URL,
+			"action": r.FormValue("action"),
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.renderAdminWebhooks(w, message, "")
+}
+
+// createWebhook registers a webhook from the form. A secret is generated if none was entered,
+// and shown once.
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request, admin *models.User) {
+	endpoint := strings.TrimSpace(r.FormValue("url"))
+	if err := validateWebhookURL(endpoint); err != nil {
+		s.renderAdminWebhooks(w, "Error: "+err.Error(), "")
+		return
+	}
+
+	events := r.Form["events"]
+
+	secret := strings.TrimSpace(r.FormValue("secret"))
+	if secret == "" {
+		secretBytes := make([]byte, 24)
+		if _, err := rand.Read(secretBytes); err != nil {
+			s.renderAdminWebhooks(w, "Error: Failed to generate secret", "")
+			return
+		}
+		secret = hex.EncodeToString(secretBytes)
+	}
+
+	webhook, err := database.DB.CreateWebhook(endpoint, secret, events)
+	if err != nil {
+		s.renderAdminWebhooks(w, "Error: "+err.Error(), "")
+		return
+	}
+
+	log.Printf("Webhook %d registered for %s by %s", webhook.Id, webhook.URL, admin.Email)
+	database.DB.LogAction(&database.AuditLogEntry{
+		UserID:     int64(admin.Id),
+		UserEmail:  admin.Email,
+		Action:     database.ActionWebhookCreated,
+		EntityType: database.EntityWebhook,
+		EntityID:   strconv.Itoa(webhook.Id),
+		Details: database.CreateAuditDetails(map[string]interface{}{
+			"url":    webhook.URL,
+			"events": webhook.Events,
+		}),
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   true,
+	})
+
+	s.renderAdminWebhooks(w, "Webhook added.", secret)
+}
+
+// renderAdminWebhooks renders the webhook list and the form to add one. newSecret is shown once,
+// right after a webhook was added.
+func (s *Server) renderAdminWebhooks(w http.ResponseWriter, message, newSecret string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	hooks, err := database.DB.GetWebhooks()
+	if err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+	}
+
+	html := `<!DOCTYPE html>
+<html ` + htmlLangAttributes() + `>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="author" content="Ulf Holmström">
+    <title>Webhooks - ` + s.config.CompanyName + `</title>
+    ` + s.getFaviconHTML() + `
+    <link rel="stylesheet" href="/static/css/style.css">
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+            background: #f5f5f5;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 40px auto;
+            padding: 0 20px;
+        }
+        .card {
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            padding: 24px;
+            margin-bottom: 24px;
+        }
+        h2 { margin-bottom: 20px; }
+        h3 { margin-bottom: 12px; color: #333; }
+        .card p { color: #555; font-size: 14px; line-height: 1.6; margin-bottom: 10px; }
+        .btn {
+            padding: 8px 16px;
+            background: ` + s.getPrimaryColor() + `;
+            color: white;
+            border: none;
+            border-radius: 6px;
+            font-weight: 500;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        .btn-secondary { background: #757575; }
+        .btn-danger { background: #f44336; }
+        .info-box {
+            background: #e3f2fd;
+            border: 1px solid #2196f3;
+            color: #0d47a1;
+            padding: 15px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+        }
+        .message {
+            padding: 12px 16px;
+            border-radius: 8px;
+            margin-bottom: 20px;
+            font-size: 14px;
+            background: #e8f5e9;
+            border: 1px solid #4caf50;
+            color: #1b5e20;
+        }
+        .message.error {
+            background: #fee;
+            border-color: #fcc;
+            color: #c33;
+        }
+        .secret {
+            background: #fff8e1;
+            border: 1px solid #ffc107;
+        }
+        .secret code {
+            display: block;
+            background: #333;
+            color: #fff;
+            padding: 12px;
+            border-radius: 6px;
+            font-size: 14px;
+            word-break: break-all;
+            margin: 10px 0;
+        }
+        .form-group { margin-bottom: 16px; }
+        .form-group label { display: block; font-size: 13px; font-weight: 600; color: #555; margin-bottom: 6px; }
+        .form-group input[type="text"], .form-group input[type="url"] {
+            width: 100%;
+            padding: 10px 12px;
+            border: 2px solid #e0e0e0;
+            border-radius: 6px;
+            font-size: 14px;
+        }
+        .events { display: flex; gap: 20px; flex-wrap: wrap; font-size: 14px; }
+        table { width: 100%; border-collapse: collapse; font-size: 14px; }
+        th, td { text-align: left; padding: 12px; border-bottom: 1px solid #eee; vertical-align: top; }
+        th { background: #fafafa; color: #555; font-weight: 600; }
+        td form { display: inline; }
+        .muted { color: #888; font-size: 12px; margin-top: 4px; word-break: break-all; }
+        .failed { color: #c33; }
+        .paused { color: #888; font-weight: 600; }
+    </style>
+</head>
+<body>
+    ` + s.getAdminHeaderHTML("") + `
+    <div class="container">
+        <h2>🪝 Webhooks</h2>
+
+        <div class="info-box">
+            Webhooks POST a JSON event to your endpoint when files are uploaded, downloaded or expire. Each request carries an <code>` + webhooks.SignatureHeader + `</code> header with <code>sha256=</code> and the HMAC-SHA256 of the body, keyed with the webhook's secret, so your endpoint can check it came from this server. Failed deliveries are retried twice and then recorded in the audit log.
+        </div>`
+
+	if message != "" {
+		class := "message"
+		if strings.HasPrefix(message, "Error") {
+			class = "message error"
+		}
+		html += `
+        <div class="` + class + `">` + template.HTMLEscapeString(message) + `</div>`
+	}
+
+	if newSecret != "" {
+		html += `
+        <div class="card secret">
+            <h3>Signing secret</h3>
+            <p>Configure your endpoint with this secret to verify signatures. It isn't shown again.</p>
+            <code>` + template.HTMLEscapeString(newSecret) + `</code>
+        </div>`
+	}
+
+	eventCheckboxes := ""
+	for _, event := range database.WebhookEvents {
+		eventCheckboxes += fmt.Sprintf(`
+                        <label><input type="checkbox" name="events" value="%s" checked> %s <code>%s</code></label>`,
+			event, database.WebhookEventLabel(event), event)
+	}
+
+	html += `
+        <div class="card">
+            <h3>Add Webhook</h3>
+            <form method="POST">
+                <input type="hidden" name="action" value="create">
+                <div class="form-group">
+                    <label for="webhookURL">Endpoint URL</label>
+                    <input type="url" id="webhookURL" name="url" placeholder="https://dashboard.example.com/hooks/wulfvault" required>
+                </div>
+                <div class="form-group">
+                    <label for="webhookSecret">Secret</label>
+                    <input type="text" id="webhookSecret" name="secret" placeholder="Leave empty to generate one" autocomplete="off">
+                </div>
+                <div class="form-group">
+                    <label>Events</label>
+                    <div class="events">` + eventCheckboxes + `
+                    </div>
+                </div>
+                <button type="submit" class="btn">Add Webhook</button>
+            </form>
+        </div>
+
+        <div class="card">
+            <h3>Registered Webhooks</h3>`
+
+	if len(hooks) == 0 {
+		html += `
+            <p>No webhooks registered.</p>`
+	} else {
+		html += `
+            <table>
+                <tr><th>Endpoint</th><th>Events</th><th>Last delivery</th><th></th></tr>`
+		for _, hook := range hooks {
+			var eventLabels []string
+			for _, event := range hook.Events {
+				eventLabels = append(eventLabels, database.WebhookEventLabel(event))
+			}
+
+			lastDelivery := "Never"
+			if hook.LastDeliveryAt > 0 {
+				lastDelivery = time.Unix(hook.LastDeliveryAt, 0).Format("2006-01-02 15:04")
+				if hook.LastStatus == "OK" {
+					lastDelivery += `<div class="muted">Delivered</div>`
+				} else {
+					lastDelivery += `<div class="muted failed">Failed: ` + template.HTMLEscapeString(hook.LastStatus) + `</div>`
+				}
+			}
+
+			status := ""
+			toggleAction, toggleLabel := "pause", "Pause"
+			if !hook.IsActive {
+				status = ` <span class="paused">(paused)</span>`
+				toggleAction, toggleLabel = "resume", "Resume"
+			}
+			id := strconv.Itoa(hook.Id)
+
+			html += `
+                <tr>
+                    <td><strong>` + template.HTMLEscapeString(hook.URL) + `</strong>` + status + `<div class="muted">Added ` + time.Unix(hook.CreatedAt, 0).Format("2006-01-02") + `</div></td>
+                    <td>` + template.HTMLEscapeString(strings.Join(eventLabels, ", ")) + `</td>
+                    <td>` + lastDelivery + `</td>
+                    <td style="white-space: nowrap;">
+                        <form method="POST"><input type="hidden" name="action" value="test"><input type="hidden" name="webhook_id" value="` + id + `"><button type="submit" class="btn">Test</button></form>
+                        <form method="POST"><input type="hidden" name="action" value="` + toggleAction + `"><input type="hidden" name="webhook_id" value="` + id + `"><button type="submit" class="btn btn-secondary">` + toggleLabel + `</button></form>
+                        <form method="POST" onsubmit="return confirm('Delete this webhook?');"><input type="hidden" name="action" value="delete"><input type="hidden" name="webhook_id" value="` + id + `"><button type="submit" class="btn btn-danger">Delete</button></form>
+                    </td>
+                </tr>`
+		}
+		html += `
+            </table>`
+	}
+
+	html += `
+        </div>
+    </div>
+</body>
+</html>`
+
+	w.Write([]byte(html))
+}
@@ -0,0 +1,85 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage keeps files in a directory on disk, named by their file ID
+type LocalStorage struct {
+	Dir string
+}
+
+// NewLocalStorage returns storage in dir, which must exist
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{Dir: dir}
+}
+
+func (l *LocalStorage) path(id string) string {
+	return filepath.Join(l.Dir, id)
+}
+
+// Put writes the file to a temporary file first and renames it into place, so readers never
+// see a partly written file
+func (l *LocalStorage) Put(id string, r io.Reader) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(l.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path(id)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Get opens the file. The *os.File it returns can seek.
+func (l *LocalStorage) Get(id string) (io.ReadCloser, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	return os.Open(l.path(id))
+}
+
+// Delete removes the file
+func (l *LocalStorage) Delete(id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	return os.Remove(l.path(id))
+}
+
+// Stat returns the file's size and modification time
+func (l *LocalStorage) Stat(id string) (*FileInfo, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(l.path(id))
+	if err != nil {
+		return nil, err
+	}
+	return &FileInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
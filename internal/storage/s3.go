@@ -0,0 +1,459 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/config"
+)
+
+const (
+	// s3PartSize is how much of an upload is buffered per request. Files up to this size are
+	// stored with a single PUT, larger ones with a multipart upload of parts this size.
+	s3PartSize = 16 << 20
+	// s3UnsignedPayload lets object bodies stream without hashing them first
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// s3EmptyPayloadHash is the SHA-256 of an empty body
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Storage keeps files as objects in an S3-compatible bucket, such as AWS S3 or MinIO.
+// Requests are signed with AWS Signature Version 4.
+type S3Storage struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string
+	pathStyle bool
+	client    *http.Client
+}
+
+// NewS3Storage returns storage in the configured bucket. Without an endpoint, AWS S3 in the
+// configured region is used.
+func NewS3Storage(cfg config.S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 storage needs a bucket")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("S3 storage needs an access key and a secret key")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	return &S3Storage{
+		endpoint:  parsed,
+		bucket:    cfg.Bucket,
+		region:    region,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		pathStyle: cfg.PathStyle,
+		// No overall timeout: downloads of large files stream for as long as they take
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 60 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+		}},
+	}, nil
+}
+
+// key returns the object key of a file
+func (s *S3Storage) key(id string) string {
+	if s.prefix == "" {
+		return id
+	}
+	return s.prefix + "/" + id
+}
+
+// objectURL returns the URL of an object, with the bucket in the path (path style, which
+// MinIO usually needs) or in the host name
+func (s *S3Storage) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if s.pathStyle {
+		u.Path = basePath + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+	return &u
+}
+
+// do signs and sends a request. payloadHash is the hex SHA-256 of body, or s3UnsignedPayload.
+func (s *S3Storage) do(method, key string, query url.Values, header http.Header, body io.Reader, contentLength int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = contentLength
+	}
+	s.sign(req, payloadHash, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 headers to a request
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but unreserved characters, as Signature Version 4 expects
+func s3Escape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EscapePath escapes each segment of a path
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes a query sorted by name, as Signature Version 4 expects
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Error turns an unexpected response into an error and closes its body
+func s3Error(op, id string, resp *http.Response) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return notFound(op, id)
+	}
+
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("S3 %s %s: %s: %s", op, id, body.Code, body.Message)
+	}
+	return fmt.Errorf("S3 %s %s: HTTP %d", op, id, resp.StatusCode)
+}
+
+// Put uploads the file in one request if it fits in a part, otherwise as a multipart upload,
+// so uploads of any size are streamed without knowing their length in advance
+func (s *S3Storage) Put(id string, r io.Reader) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	buf := make([]byte, s3PartSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.putObject(id, buf[:n])
+	}
+	if err != nil {
+		return err
+	}
+	return s.putMultipart(id, buf, r)
+}
+
+func (s *S3Storage) putObject(id string, data []byte) error {
+	resp, err := s.do(http.MethodPut, s.key(id), nil, nil, bytes.NewReader(data), int64(len(data)), s3UnsignedPayload)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", id, resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart uploads a file larger than one part. first is the already read first part.
+// The upload is aborted if any part fails, so no incomplete parts are left in the bucket.
+func (s *S3Storage) putMultipart(id string, first []byte, r io.Reader) error {
+	key := s.key(id)
+
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, 0, s3EmptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", id, resp)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("S3 put %s: could not start multipart upload: %v", id, err)
+	}
+
+	if err := s.uploadParts(id, initiated.UploadID, first, r); err != nil {
+		abort, abortErr := s.do(http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil, nil, 0, s3EmptyPayloadHash)
+		if abortErr == nil {
+			abort.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *S3Storage) uploadParts(id, uploadID string, buf []byte, r io.Reader) error {
+	key := s.key(id)
+	var parts []s3CompletedPart
+
+	data := buf
+	for partNumber := 1; len(data) > 0; partNumber++ {
+		query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+		resp, err := s.do(http.MethodPut, key, query, nil, bytes.NewReader(data), int64(len(data)), s3UnsignedPayload)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return s3Error("put", id, resp)
+		}
+		parts = append(parts, s3CompletedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+		resp.Body.Close()
+
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		data = buf[:n]
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	bodyHash := sha256.Sum256(body)
+	header := http.Header{"Content-Type": {"application/xml"}}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, header, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(bodyHash[:]))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", id, resp)
+	}
+
+	// S3 reports some failures of the completion in the body of a 200 response
+	data, _ = io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var failed struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}
+	if xml.Unmarshal(data, &failed) == nil && failed.Code != "" {
+		return fmt.Errorf("S3 put %s: %s: %s", id, failed.Code, failed.Message)
+	}
+	return nil
+}
+
+// Get returns a reader that fetches the object with ranged GET requests, starting a new one
+// whenever it is seeked, so byte ranges of large files are served without reading them whole
+func (s *S3Storage) Get(id string) (io.ReadCloser, error) {
+	info, err := s.Stat(id)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{storage: s, id: id, size: info.Size}, nil
+}
+
+// Delete removes the object. S3 doesn't report whether it existed.
+func (s *S3Storage) Delete(id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, s.key(id), nil, nil, nil, 0, s3EmptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error("delete", id, resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Stat returns the object's size and modification time
+func (s *S3Storage) Stat(id string) (*FileInfo, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(http.MethodHead, s.key(id), nil, nil, nil, 0, s3EmptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error("stat", id, resp)
+	}
+	resp.Body.Close()
+
+	info := &FileInfo{Size: resp.ContentLength}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info, nil
+}
+
+// s3Object reads an object from its current offset
+type s3Object struct {
+	storage *S3Storage
+	id      string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", o.offset)}}
+		resp, err := o.storage.do(http.MethodGet, o.storage.key(o.id), nil, header, nil, 0, s3EmptyPayloadHash)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+			return 0, s3Error("get", o.id, resp)
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	if err == io.EOF && o.offset < o.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = o.offset + offset
+	case io.SeekEnd:
+		target = o.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if target < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if target != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = target
+	return target, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
@@ -0,0 +1,75 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+// Package storage keeps the contents of uploaded files, either in the uploads directory or in
+// an S3-compatible bucket. Files are stored under their file ID; everything else about them
+// lives in the database.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/config"
+)
+
+// Storage persists file contents by file ID. Errors for files that don't exist match
+// fs.ErrNotExist, so os.IsNotExist and errors.Is work for every backend.
+type Storage interface {
+	// Put stores the contents of r under id, replacing any earlier file. The file only
+	// becomes visible once all of r was stored.
+	Put(id string, r io.Reader) error
+	// Get opens a file for reading. The returned reader also implements io.Seeker, so
+	// downloads can serve byte ranges.
+	Get(id string) (io.ReadCloser, error)
+	// Delete removes a file
+	Delete(id string) error
+	// Stat returns the size and modification time of a file
+	Stat(id string) (*FileInfo, error)
+}
+
+// FileInfo describes a stored file
+type FileInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Files is the storage the server keeps uploads in, set by Initialize
+var Files Storage
+
+// Initialize sets Files to the backend chosen in the configuration. Local storage keeps
+// files in uploadsDir.
+func Initialize(cfg config.StorageConfig, uploadsDir string) error {
+	switch cfg.Backend {
+	case "", config.StorageBackendLocal:
+		Files = NewLocalStorage(uploadsDir)
+	case config.StorageBackendS3:
+		s3, err := NewS3Storage(cfg.S3)
+		if err != nil {
+			return err
+		}
+		Files = s3
+	default:
+		return fmt.Errorf("unknown storage backend %q (use %q or %q)", cfg.Backend, config.StorageBackendLocal, config.StorageBackendS3)
+	}
+	return nil
+}
+
+// validateID rejects IDs that could point outside the storage
+func validateID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return errors.New("invalid file ID")
+	}
+	return nil
+}
+
+// notFound returns the error for a file that doesn't exist
+func notFound(op, id string) error {
+	return &fs.PathError{Op: op, Path: id, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,76 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// encryptedSecretPrefix marks secrets stored encrypted. Secrets stored before encryption was
+// added are plain base32 and can't start with it.
+const encryptedSecretPrefix = "enc:"
+
+// IsEncryptedSecret reports whether a stored secret was encrypted with EncryptSecret
+func IsEncryptedSecret(stored string) bool {
+	return strings.HasPrefix(stored, encryptedSecretPrefix)
+}
+
+// EncryptSecret encrypts a TOTP secret for storage with AES-256-GCM
+func EncryptSecret(secret string, key []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return encryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret returns the TOTP secret of a stored value. Secrets stored before encryption
+// was added are returned as they are.
+func DecryptSecret(stored string, key []byte) (string, error) {
+	if !IsEncryptedSecret(stored) {
+		return stored, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedSecretPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("encrypted TOTP secret too short")
+	}
+
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	secret, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
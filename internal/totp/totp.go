@@ -101,6 +101,13 @@ func ValidateBackupCode(code, hashedCode string) bool {
 	return err == nil
 }
 
+// NormalizeBackupCode undoes FormatBackupCode and the way people tend to type codes: it
+// removes spaces and hyphens and uppercases the rest. Generated codes never contain either.
+func NormalizeBackupCode(code string) string {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
 // FormatBackupCode formats a backup code for display (e.g., "ABCD-EFGH")
 func FormatBackupCode(code string) string {
 	if len(code) <= 4 {
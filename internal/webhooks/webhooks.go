@@ -0,0 +1,191 @@
+// WulfVault - Secure File Transfer System
+// Copyright (c) 2025 Ulf Holmström (Frimurare)
+// Licensed under the GNU Affero General Public License v3.0 (AGPL-3.0)
+// You must retain this notice in any copy or derivative work.
+
+// Package webhooks POSTs file events to the endpoints admins registered. Deliveries are made
+// in the background and retried a few times, so a slow or broken endpoint never holds up
+// uploads or downloads.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Frimurare/WulfVault/internal/database"
+)
+
+// EventPing is sent by the admin page's test button. Webhooks don't subscribe to it.
+const EventPing = "ping"
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body, keyed with the webhook's secret
+const SignatureHeader = "X-WulfVault-Signature"
+
+const (
+	// maxAttempts is how often a delivery is tried before it is given up and audit logged
+	maxAttempts = 3
+	// firstRetryDelay doubles with every further attempt
+	firstRetryDelay = 5 * time.Second
+	// queueSize is how many deliveries may wait. Deliveries are dropped when endpoints
+	// can't keep up.
+	queueSize = 1000
+)
+
+// Event is the JSON body POSTed to a webhook
+type Event struct {
+	Type      string `json:"event"`
+	FileID    string `json:"file_id,omitempty"`
+	FileName  string `json:"file_name,omitempty"`
+	UserEmail string `json:"user_email,omitempty"` // uploader, downloader or owner, if known
+	IP        string `json:"ip,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// delivery is one event on its way to one webhook
+type delivery struct {
+	webhook *database.Webhook
+	id      string
+	event   string
+	body    []byte
+	attempt int
+}
+
+var (
+	startOnce sync.Once
+	queue     chan *delivery
+	client    = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Dispatch queues an event for every active webhook that subscribes to it
+func Dispatch(eventType, fileID, fileName, userEmail, ip string) {
+	webhooks, err := database.DB.GetWebhooksForEvent(eventType)
+	if err != nil {
+		log.Printf("Warning: Could not load webhooks for %s: %v", eventType, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(&Event{
+		Type:      eventType,
+		FileID:    fileID,
+		FileName:  fileName,
+		UserEmail: userEmail,
+		IP:        ip,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		log.Printf("Warning: Could not encode webhook event %s: %v", eventType, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		enqueue(&delivery{webhook: webhook, id: newDeliveryID(), event: eventType, body: body, attempt: 1})
+	}
+}
+
+// SendPing queues a test event for one webhook, whether or not it is active
+func SendPing(webhook *database.Webhook) {
+	body, _ := json.Marshal(&Event{Type: EventPing, Timestamp: time.Now().UTC().Format(time.RFC3339)})
+	enqueue(&delivery{webhook: webhook, id: newDeliveryID(), event: EventPing, body: body, attempt: 1})
+}
+
+// Sign returns the signature header value of a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// enqueue hands a delivery to the worker, starting it on first use
+func enqueue(d *delivery) {
+	startOnce.Do(func() {
+		queue = make(chan *delivery, queueSize)
+		go run()
+	})
+
+	select {
+	case queue <- d:
+	default:
+		log.Printf("Webhook queue full, dropped %s event for %s", d.event, d.webhook.URL)
+	}
+}
+
+// run delivers queued events one at a time. Failed deliveries are queued again after their
+// backoff, so waiting for a retry doesn't hold up other deliveries.
+func run() {
+	for d := range queue {
+		err := send(d)
+		if err == nil {
+			database.DB.RecordWebhookDelivery(d.webhook.Id, "OK")
+			continue
+		}
+
+		if d.attempt < maxAttempts {
+			delay := firstRetryDelay << (d.attempt - 1)
+			log.Printf("Webhook delivery %s to %s failed (attempt %d of %d), retrying in %s: %v", d.id, d.webhook.URL, d.attempt, maxAttempts, delay, err)
+			d.attempt++
+			time.AfterFunc(delay, func() { enqueue(d) })
+			continue
+		}
+
+		log.Printf("Webhook delivery %s to %s failed after %d attempts: %v", d.id, d.webhook.URL, maxAttempts, err)
+		database.DB.RecordWebhookDelivery(d.webhook.Id, err.Error())
+		database.DB.LogAction(&database.AuditLogEntry{
+			UserID:     0,
+			UserEmail:  "system",
+			Action:     database.ActionWebhookFailed,
+			EntityType: database.EntityWebhook,
+			EntityID:   strconv.Itoa(d.webhook.Id),
+			Details: database.CreateAuditDetails(map[string]interface{}{
+				"url":         d.webhook.URL,
+				"event":       d.event,
+				"delivery_id": d.id,
+				"attempts":    maxAttempts,
+			}),
+			Success:  false,
+			ErrorMsg: err.Error(),
+		})
+	}
+}
+
+// send POSTs a delivery's body and fails on anything but a 2xx response
+func send(d *delivery) error {
+	req, err := http.NewRequest(http.MethodPost, d.webhook.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "WulfVault-Webhook")
+	req.Header.Set("X-WulfVault-Event", d.event)
+	req.Header.Set("X-WulfVault-Delivery", d.id)
+	req.Header.Set(SignatureHeader, Sign(d.webhook.Secret, d.body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
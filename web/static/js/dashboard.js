@@ -157,6 +157,26 @@ if (uploadForm) {
         formData.set('unlimited_time', document.getElementById('unlimitedTime').checked ? 'true' : 'false');
         formData.set('unlimited_downloads', document.getElementById('unlimitedDownloads').checked ? 'true' : 'false');
         formData.set('require_auth', document.getElementById('requireAuth').checked ? 'true' : 'false');
+        const privateDownloadLog = document.getElementById('privateDownloadLog');
+        if (privateDownloadLog && !privateDownloadLog.disabled) {
+            formData.set('private_download_log', privateDownloadLog.checked ? 'true' : 'false');
+        }
+        const requireTerms = document.getElementById('requireTerms');
+        if (requireTerms) {
+            formData.set('require_terms', requireTerms.checked ? 'true' : 'false');
+        }
+        const stripMetadata = document.getElementById('stripMetadata');
+        if (stripMetadata) {
+            formData.set('strip_metadata', stripMetadata.checked ? 'true' : 'false');
+        }
+        const burnAfterDownload = document.getElementById('burnAfterDownload');
+        if (burnAfterDownload) {
+            formData.set('burn_after_download', burnAfterDownload.checked ? 'true' : 'false');
+        }
+        const notifyOnDownload = document.getElementById('notifyOnDownload');
+        if (notifyOnDownload) {
+            formData.set('notify_on_download', notifyOnDownload.checked ? 'true' : 'false');
+        }
 
         // Handle password field - only include if checkbox is checked
         const enablePasswordCheckbox = document.getElementById('enablePassword');
@@ -218,10 +238,18 @@ if (uploadForm) {
             expire_date: formData.get('expire_date') || '',
             downloads_limit: formData.get('downloads_limit') || '10',
             require_auth: formData.get('require_auth') || 'false',
+            private_download_log: formData.get('private_download_log') || '',
+            require_terms: formData.get('require_terms') || 'false',
+            splash_template: formData.get('splash_template') || '',
+            strip_metadata: formData.get('strip_metadata') || '',
+            burn_after_download: formData.get('burn_after_download') || 'false',
+            notify_on_download: formData.get('notify_on_download') || '',
             unlimited_time: formData.get('unlimited_time') || 'false',
             unlimited_downloads: formData.get('unlimited_downloads') || 'false',
             file_password: formData.get('file_password') || '',
             file_comment: formData.get('file_comment') || '',
+            slug: (formData.get('slug') || '').trim(),
+            activate_at: formData.get('activate_at') || '',
             client_ip: '', // Server will fill this
             user_agent: navigator.userAgent
         };
@@ -239,6 +267,10 @@ if (uploadForm) {
 
 // Reset upload form
 function resetUploadForm() {
+    if (window.activeUpload) {
+        cancelActiveUpload();
+    }
+
     uploadForm.reset();
     uploadOptions.style.display = 'none';
 
@@ -337,15 +369,31 @@ function fallbackCopyToClipboard(text, button) {
 
 // Delete file function
 function deleteFile(fileId, fileName) {
-    if (!confirm(`Delete "${fileName}"?`)) return;
+    let body = 'file_id=' + fileId;
+
+    // When the administrator allows it, ask how long the file should stay in trash
+    const retention = document.body.dataset;
+    if (retention.trashRetentionMax) {
+        const days = prompt(`Delete "${fileName}"?\n\nKeep it in trash for how many days? (${retention.trashRetentionMin}–${retention.trashRetentionMax})`, retention.trashRetentionDefault);
+        if (days === null) return;
+        if (days.trim() !== '' && days.trim() !== retention.trashRetentionDefault) {
+            body += '&trash_retention_days=' + encodeURIComponent(days.trim());
+        }
+    } else if (!confirm(`Delete "${fileName}"?`)) {
+        return;
+    }
 
     fetch('/file/delete', {
         method: 'POST',
         headers: {'Content-Type': 'application/x-www-form-urlencoded'},
-        body: 'file_id=' + fileId
+        body: body
     })
     .then(res => res.json())
     .then(data => {
+        if (data.error) {
+            showError(data.error);
+            return;
+        }
         showSuccess('File moved to trash');
         setTimeout(() => window.location.reload(), 1000);
     })
@@ -354,6 +402,65 @@ function deleteFile(fileId, fileName) {
     });
 }
 
+// Lift a download lock set after unusual download activity
+function unblockDownloads(fileId, fileName) {
+    if (!confirm(`Unlock downloads of "${fileName}"?\n\nOnly do this if you expected the recent downloads.`)) {
+        return;
+    }
+
+    fetch('/file/unblock-downloads', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/x-www-form-urlencoded'},
+        body: 'file_id=' + encodeURIComponent(fileId)
+    })
+    .then(res => res.json())
+    .then(data => {
+        if (data.error) {
+            showError(data.error);
+            return;
+        }
+        showSuccess('Downloads unlocked');
+        setTimeout(() => window.location.reload(), 1000);
+    })
+    .catch(err => {
+        showError('Failed to unlock downloads');
+    });
+}
+
+// Set or top up the remaining downloads of the file in the edit modal right away
+function resetDownloads(mode) {
+    const fileId = document.getElementById('editFileId').value;
+    const downloads = parseInt(document.getElementById('editResetDownloads').value) || 0;
+    if (downloads < 1) {
+        showError('Enter how many downloads to allow');
+        return;
+    }
+
+    const data = new FormData();
+    data.append('file_id', fileId);
+    data.append('downloads', downloads);
+    data.append('mode', mode);
+
+    fetch('/file/reset-downloads', {
+        method: 'POST',
+        body: data,
+        credentials: 'same-origin'
+    })
+    .then(res => res.json())
+    .then(result => {
+        if (result.error) {
+            showError(result.error);
+            return;
+        }
+        // Keep the modal in step, so saving it doesn't overwrite the new value
+        document.getElementById('editDownloadsLimit').value = result.downloads_remaining;
+        showSuccess(result.downloads_remaining + ' downloads remaining' + (result.restored ? ' (restored from trash)' : ''));
+    })
+    .catch(err => {
+        showError('Failed to reset downloads');
+    });
+}
+
 // Show success message
 function showSuccess(message) {
     const toast = document.createElement('div');
@@ -406,6 +513,7 @@ function showCreateRequestModal() {
         // Reset form
         document.getElementById('fileRequestForm').reset();
         document.getElementById('requestMaxSize').value = 1; // Default 1 GB
+        delete document.getElementById('requestBrandColor').dataset.changed;
     }
 }
 
@@ -423,6 +531,9 @@ function submitFileRequest(event) {
     const message = document.getElementById('requestMessage').value;
     const maxSizeGB = document.getElementById('requestMaxSize').value;
     const recipientEmail = document.getElementById('requestRecipientEmail').value;
+    const brandName = document.getElementById('requestBrandName').value;
+    const brandColorInput = document.getElementById('requestBrandColor');
+    const teamSelect = document.getElementById('requestTeam');
 
     // Convert GB to MB for backend (backend expects MB)
     const maxSizeMB = Math.round(parseFloat(maxSizeGB) * 1024);
@@ -436,6 +547,14 @@ function submitFileRequest(event) {
     if (recipientEmail) {
         data.append('recipient_email', recipientEmail);
     }
+    data.append('brand_name', brandName);
+    // Only send the color if it was picked, so the page otherwise follows the server's branding
+    if (brandColorInput.dataset.changed) {
+        data.append('brand_color', brandColorInput.value);
+    }
+    if (teamSelect) {
+        data.append('team_id', teamSelect.value);
+    }
 
     fetch('/file-request/create', {
         method: 'POST',
@@ -476,13 +595,22 @@ function loadFileRequests() {
             const container = document.getElementById('requestsList');
             if (!container) return;
 
+            // Show how many of the allowed active requests are in use
+            let usage = '';
+            if (data.limit > 0) {
+                const atLimit = data.active_count >= data.limit;
+                usage = '<p style="margin-top: 12px; font-size: 14px; color: ' + (atLimit ? '#f44336' : '#666') + ';">' +
+                        (atLimit ? '⚠️ ' : '') + data.active_count + ' of ' + data.limit + ' active upload requests in use' +
+                        (atLimit ? ' - delete one or wait for it to expire to create a new request' : '') + '</p>';
+            }
+
             if (!data.requests || data.requests.length === 0) {
-                container.innerHTML = '<p style="color: #999; font-style: italic;">No upload requests yet</p>';
+                container.innerHTML = usage + '<p style="color: #999; font-style: italic;">No upload requests yet</p>';
                 return;
             }
 
             const now = Math.floor(Date.now() / 1000);
-            let html = '<div style="margin-top: 20px;">';
+            let html = usage + '<div style="margin-top: 20px;">';
 
             data.requests.forEach(req => {
                 const expiresAt = req.expires_at;
@@ -585,11 +713,55 @@ function escapeHtml(text) {
 // CHUNKED UPLOAD IMPLEMENTATION
 // ============================================================================
 
+// Upload in progress: { id, controller, cancelled }
+window.activeUpload = null;
+
+// Cancel the upload in progress; the server removes the data received so far
+function cancelActiveUpload() {
+    const upload = window.activeUpload;
+    if (!upload || upload.cancelled) return;
+
+    upload.cancelled = true;
+    upload.controller.abort();
+    if (upload.id) {
+        fetch(`/api/upload/cancel?upload_id=${upload.id}`, {
+            method: 'POST',
+            credentials: 'same-origin'
+        }).catch(error => console.error('Failed to cancel upload:', error));
+    }
+}
+
+// Discard the partial upload if the page is closed or reloaded mid-upload
+window.addEventListener('pagehide', () => {
+    const upload = window.activeUpload;
+    if (upload && upload.id && !upload.cancelled) {
+        upload.cancelled = true;
+        navigator.sendBeacon(`/api/upload/cancel?upload_id=${upload.id}`);
+    }
+});
+
+// Returns the error message of a failed upload request: the "error" field of a JSON response
+// (such as a storage quota rejection), or the plain text the server sent
+async function responseErrorMessage(response) {
+    const text = (await response.text()).trim();
+    try {
+        const data = JSON.parse(text);
+        if (data && data.error) {
+            return data.error;
+        }
+    } catch (e) {
+        // Not JSON
+    }
+    return text;
+}
+
 async function uploadFileInChunks(file, metadata, uploadButton) {
     const CHUNK_SIZE = 25 * 1024 * 1024; // 25MB chunks
     const totalChunks = Math.ceil(file.size / CHUNK_SIZE);
     let retryCount = 0;
     const MAX_RETRIES = 50; // 50 retries = ~7.5 minutes total retry time (enough for router restarts)
+    const upload = { id: null, controller: new AbortController(), cancelled: false };
+    window.activeUpload = upload;
 
     try {
         // Step 1: Initialize upload
@@ -597,6 +769,7 @@ async function uploadFileInChunks(file, metadata, uploadButton) {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'same-origin',
+            signal: upload.controller.signal,
             body: JSON.stringify({
                 filename: file.name,
                 total_size: file.size,
@@ -605,10 +778,12 @@ async function uploadFileInChunks(file, metadata, uploadButton) {
         });
 
         if (!initResponse.ok) {
-            throw new Error('Failed to initialize upload');
+            const message = await responseErrorMessage(initResponse);
+            throw new Error(message || 'Failed to initialize upload');
         }
 
         const { upload_id } = await initResponse.json();
+        upload.id = upload_id;
         console.log(`Upload initialized: ${upload_id}, ${totalChunks} chunks`);
 
         // Step 2: Upload chunks
@@ -622,12 +797,24 @@ async function uploadFileInChunks(file, metadata, uploadButton) {
 
             while (!chunkUploaded && attempts < MAX_RETRIES) {
                 try {
-                    const chunkResponse = await fetch(`/api/upload/chunk?upload_id=${upload_id}&chunk_index=${chunkIndex}`, {
+                    // A chunk sent again replaces the earlier attempt on the server
+                    const chunkResponse = await fetch(`/api/upload/chunk?upload_id=${upload_id}`, {
                         method: 'POST',
+                        headers: {
+                            'X-Chunk-Index': String(chunkIndex),
+                            'X-Chunk-Total': String(totalChunks)
+                        },
                         body: chunk,
-                        credentials: 'same-origin'
+                        credentials: 'same-origin',
+                        signal: upload.controller.signal
                     });
 
+                    if (chunkResponse.status === 413) {
+                        // The server discarded the upload; sending the chunk again won't help
+                        const tooLarge = new Error(await responseErrorMessage(chunkResponse));
+                        tooLarge.final = true;
+                        throw tooLarge;
+                    }
                     if (!chunkResponse.ok) {
                         throw new Error(`Chunk ${chunkIndex} upload failed`);
                     }
@@ -643,6 +830,9 @@ async function uploadFileInChunks(file, metadata, uploadButton) {
                     console.log(`Chunk ${chunkIndex + 1}/${totalChunks} uploaded (${percentComplete}%)`);
 
                 } catch (error) {
+                    if (upload.cancelled || error.final) {
+                        throw error;
+                    }
                     attempts++;
                     retryCount++;
                     console.error(`Chunk ${chunkIndex} failed (attempt ${attempts}/${MAX_RETRIES}):`, error);
@@ -666,11 +856,13 @@ async function uploadFileInChunks(file, metadata, uploadButton) {
         });
 
         if (!completeResponse.ok) {
-            throw new Error('Failed to complete upload');
+            const message = await responseErrorMessage(completeResponse);
+            throw new Error(message || 'Failed to complete upload');
         }
 
         const result = await completeResponse.json();
         console.log('Upload completed successfully:', result);
+        window.activeUpload = null;
 
         // Mark transfer as inactive
         if (window.inactivityTracker) {
@@ -681,11 +873,25 @@ async function uploadFileInChunks(file, metadata, uploadButton) {
         showUploadSuccess();
 
     } catch (error) {
+        window.activeUpload = null;
+
         // Mark transfer as inactive
         if (window.inactivityTracker) {
             window.inactivityTracker.markTransferInactive();
         }
 
+        if (upload.cancelled) {
+            console.log('Upload cancelled');
+            if (window.musingInterval) {
+                clearInterval(window.musingInterval);
+            }
+            hideUploadProgressOverlay();
+            uploadButton.textContent = '📤 Upload File';
+            uploadButton.disabled = false;
+            showSuccess('Upload cancelled');
+            return;
+        }
+
         console.error('Upload failed:', error);
         showUploadError(error, retryCount);
 
@@ -833,6 +1039,27 @@ function showUploadProgressOverlay(filename, filesize) {
     musingText.textContent = getRandomUploadMusing();
     musingsDiv.appendChild(musingText);
 
+    // Cancel button
+    const cancelButton = document.createElement('button');
+    cancelButton.id = 'uploadCancelButton';
+    cancelButton.textContent = '✖️ Cancel Upload';
+    cancelButton.style.cssText = `
+        margin-top: 30px;
+        padding: 12px 32px;
+        font-size: 16px;
+        font-weight: bold;
+        color: #e5e7eb;
+        background: transparent;
+        border: 2px solid #6b7280;
+        border-radius: 10px;
+        cursor: pointer;
+    `;
+    cancelButton.onclick = () => {
+        if (confirm('Cancel this upload? The data sent so far will be discarded.')) {
+            cancelActiveUpload();
+        }
+    };
+
     progressBarContainer.appendChild(progressBarFill);
     container.appendChild(statusText);
     container.appendChild(fileInfo);
@@ -841,6 +1068,7 @@ function showUploadProgressOverlay(filename, filesize) {
     container.appendChild(speedInfo);
     container.appendChild(retryInfo);
     container.appendChild(musingsDiv);
+    container.appendChild(cancelButton);
     overlay.appendChild(container);
 
     // Rotate musing every minute
@@ -927,6 +1155,12 @@ function showUploadSuccess() {
         clearInterval(window.musingInterval);
     }
 
+    // Nothing left to cancel
+    const cancelButton = document.getElementById('uploadCancelButton');
+    if (cancelButton) {
+        cancelButton.remove();
+    }
+
     // Change to green and show 100%
     statusText.textContent = 'UPLOAD COMPLETE - 100%';
     statusText.style.color = '#10b981';
@@ -995,6 +1229,12 @@ function showUploadError(error, retryCount) {
         clearInterval(window.musingInterval);
     }
 
+    // Nothing left to cancel
+    const cancelButton = document.getElementById('uploadCancelButton');
+    if (cancelButton) {
+        cancelButton.remove();
+    }
+
     // Change to red error state
     statusText.textContent = 'UPLOAD FAILED';
     statusText.style.color = '#ef4444';